@@ -31,6 +31,22 @@ const (
 	Service_ListUsers_FullMethodName               = "/service/ListUsers"
 	Service_GPOListScript_FullMethodName           = "/service/GPOListScript"
 	Service_CertAutoEnrollScript_FullMethodName    = "/service/CertAutoEnrollScript"
+	Service_TicketStatus_FullMethodName            = "/service/TicketStatus"
+	Service_PolicyExport_FullMethodName            = "/service/PolicyExport"
+	Service_PolicyImport_FullMethodName            = "/service/PolicyImport"
+	Service_MountStatus_FullMethodName             = "/service/MountStatus"
+	Service_IgnoredEntries_FullMethodName          = "/service/IgnoredEntries"
+	Service_RestartHints_FullMethodName            = "/service/RestartHints"
+	Service_PrivilegeFiles_FullMethodName          = "/service/PrivilegeFiles"
+	Service_SystemAdminIdentities_FullMethodName   = "/service/SystemAdminIdentities"
+	Service_EffectivePolicy_FullMethodName         = "/service/EffectivePolicy"
+	Service_PolicyDrift_FullMethodName             = "/service/PolicyDrift"
+	Service_PolicyFetchDryRun_FullMethodName       = "/service/PolicyFetchDryRun"
+	Service_VerifyPolicy_FullMethodName            = "/service/VerifyPolicy"
+	Service_SimulatePolicy_FullMethodName          = "/service/SimulatePolicy"
+	Service_TraceExport_FullMethodName             = "/service/TraceExport"
+	Service_PolicyPurge_FullMethodName             = "/service/PolicyPurge"
+	Service_SudoAccess_FullMethodName              = "/service/SudoAccess"
 )
 
 // ServiceClient is the client API for Service service.
@@ -49,6 +65,22 @@ type ServiceClient interface {
 	ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (Service_ListUsersClient, error)
 	GPOListScript(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Service_GPOListScriptClient, error)
 	CertAutoEnrollScript(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Service_CertAutoEnrollScriptClient, error)
+	TicketStatus(ctx context.Context, in *TicketStatusRequest, opts ...grpc.CallOption) (Service_TicketStatusClient, error)
+	PolicyExport(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Service_PolicyExportClient, error)
+	PolicyImport(ctx context.Context, in *PolicyImportRequest, opts ...grpc.CallOption) (Service_PolicyImportClient, error)
+	MountStatus(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Service_MountStatusClient, error)
+	IgnoredEntries(ctx context.Context, in *IgnoredEntriesRequest, opts ...grpc.CallOption) (Service_IgnoredEntriesClient, error)
+	RestartHints(ctx context.Context, in *RestartHintsRequest, opts ...grpc.CallOption) (Service_RestartHintsClient, error)
+	PrivilegeFiles(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Service_PrivilegeFilesClient, error)
+	SystemAdminIdentities(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Service_SystemAdminIdentitiesClient, error)
+	EffectivePolicy(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Service_EffectivePolicyClient, error)
+	PolicyDrift(ctx context.Context, in *PolicyDriftRequest, opts ...grpc.CallOption) (Service_PolicyDriftClient, error)
+	PolicyFetchDryRun(ctx context.Context, in *PolicyFetchRequest, opts ...grpc.CallOption) (Service_PolicyFetchDryRunClient, error)
+	VerifyPolicy(ctx context.Context, in *VerifyPolicyRequest, opts ...grpc.CallOption) (Service_VerifyPolicyClient, error)
+	SimulatePolicy(ctx context.Context, in *SimulatePolicyRequest, opts ...grpc.CallOption) (Service_SimulatePolicyClient, error)
+	TraceExport(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Service_TraceExportClient, error)
+	PolicyPurge(ctx context.Context, in *PolicyPurgeRequest, opts ...grpc.CallOption) (Service_PolicyPurgeClient, error)
+	SudoAccess(ctx context.Context, in *SudoAccessRequest, opts ...grpc.CallOption) (Service_SudoAccessClient, error)
 }
 
 type serviceClient struct {
@@ -455,6 +487,534 @@ func (x *serviceCertAutoEnrollScriptClient) Recv() (*StringResponse, error) {
 	return m, nil
 }
 
+func (c *serviceClient) TicketStatus(ctx context.Context, in *TicketStatusRequest, opts ...grpc.CallOption) (Service_TicketStatusClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[12], Service_TicketStatus_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceTicketStatusClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_TicketStatusClient interface {
+	Recv() (*TicketStatusResponse, error)
+	grpc.ClientStream
+}
+
+type serviceTicketStatusClient struct {
+	grpc.ClientStream
+}
+
+func (x *serviceTicketStatusClient) Recv() (*TicketStatusResponse, error) {
+	m := new(TicketStatusResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) PolicyExport(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Service_PolicyExportClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[13], Service_PolicyExport_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &servicePolicyExportClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_PolicyExportClient interface {
+	Recv() (*PolicyExportResponse, error)
+	grpc.ClientStream
+}
+
+type servicePolicyExportClient struct {
+	grpc.ClientStream
+}
+
+func (x *servicePolicyExportClient) Recv() (*PolicyExportResponse, error) {
+	m := new(PolicyExportResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) PolicyImport(ctx context.Context, in *PolicyImportRequest, opts ...grpc.CallOption) (Service_PolicyImportClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[14], Service_PolicyImport_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &servicePolicyImportClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_PolicyImportClient interface {
+	Recv() (*Empty, error)
+	grpc.ClientStream
+}
+
+type servicePolicyImportClient struct {
+	grpc.ClientStream
+}
+
+func (x *servicePolicyImportClient) Recv() (*Empty, error) {
+	m := new(Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) MountStatus(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Service_MountStatusClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[15], Service_MountStatus_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceMountStatusClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_MountStatusClient interface {
+	Recv() (*MountStatusResponse, error)
+	grpc.ClientStream
+}
+
+type serviceMountStatusClient struct {
+	grpc.ClientStream
+}
+
+func (x *serviceMountStatusClient) Recv() (*MountStatusResponse, error) {
+	m := new(MountStatusResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) IgnoredEntries(ctx context.Context, in *IgnoredEntriesRequest, opts ...grpc.CallOption) (Service_IgnoredEntriesClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[16], Service_IgnoredEntries_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceIgnoredEntriesClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_IgnoredEntriesClient interface {
+	Recv() (*IgnoredEntryResponse, error)
+	grpc.ClientStream
+}
+
+type serviceIgnoredEntriesClient struct {
+	grpc.ClientStream
+}
+
+func (x *serviceIgnoredEntriesClient) Recv() (*IgnoredEntryResponse, error) {
+	m := new(IgnoredEntryResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) RestartHints(ctx context.Context, in *RestartHintsRequest, opts ...grpc.CallOption) (Service_RestartHintsClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[17], Service_RestartHints_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceRestartHintsClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_RestartHintsClient interface {
+	Recv() (*RestartHintResponse, error)
+	grpc.ClientStream
+}
+
+type serviceRestartHintsClient struct {
+	grpc.ClientStream
+}
+
+func (x *serviceRestartHintsClient) Recv() (*RestartHintResponse, error) {
+	m := new(RestartHintResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) PrivilegeFiles(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Service_PrivilegeFilesClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[18], Service_PrivilegeFiles_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &servicePrivilegeFilesClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_PrivilegeFilesClient interface {
+	Recv() (*PrivilegeFileResponse, error)
+	grpc.ClientStream
+}
+
+type servicePrivilegeFilesClient struct {
+	grpc.ClientStream
+}
+
+func (x *servicePrivilegeFilesClient) Recv() (*PrivilegeFileResponse, error) {
+	m := new(PrivilegeFileResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) SystemAdminIdentities(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Service_SystemAdminIdentitiesClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[19], Service_SystemAdminIdentities_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceSystemAdminIdentitiesClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_SystemAdminIdentitiesClient interface {
+	Recv() (*SystemAdminIdentitiesResponse, error)
+	grpc.ClientStream
+}
+
+type serviceSystemAdminIdentitiesClient struct {
+	grpc.ClientStream
+}
+
+func (x *serviceSystemAdminIdentitiesClient) Recv() (*SystemAdminIdentitiesResponse, error) {
+	m := new(SystemAdminIdentitiesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) EffectivePolicy(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Service_EffectivePolicyClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[20], Service_EffectivePolicy_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceEffectivePolicyClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_EffectivePolicyClient interface {
+	Recv() (*StringResponse, error)
+	grpc.ClientStream
+}
+
+type serviceEffectivePolicyClient struct {
+	grpc.ClientStream
+}
+
+func (x *serviceEffectivePolicyClient) Recv() (*StringResponse, error) {
+	m := new(StringResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) PolicyDrift(ctx context.Context, in *PolicyDriftRequest, opts ...grpc.CallOption) (Service_PolicyDriftClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[21], Service_PolicyDrift_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &servicePolicyDriftClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_PolicyDriftClient interface {
+	Recv() (*GPODriftResponse, error)
+	grpc.ClientStream
+}
+
+type servicePolicyDriftClient struct {
+	grpc.ClientStream
+}
+
+func (x *servicePolicyDriftClient) Recv() (*GPODriftResponse, error) {
+	m := new(GPODriftResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) PolicyFetchDryRun(ctx context.Context, in *PolicyFetchRequest, opts ...grpc.CallOption) (Service_PolicyFetchDryRunClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[22], Service_PolicyFetchDryRun_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &servicePolicyFetchDryRunClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_PolicyFetchDryRunClient interface {
+	Recv() (*GPOFetchResponse, error)
+	grpc.ClientStream
+}
+
+type servicePolicyFetchDryRunClient struct {
+	grpc.ClientStream
+}
+
+func (x *servicePolicyFetchDryRunClient) Recv() (*GPOFetchResponse, error) {
+	m := new(GPOFetchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) VerifyPolicy(ctx context.Context, in *VerifyPolicyRequest, opts ...grpc.CallOption) (Service_VerifyPolicyClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[23], Service_VerifyPolicy_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceVerifyPolicyClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_VerifyPolicyClient interface {
+	Recv() (*ManagedFileResponse, error)
+	grpc.ClientStream
+}
+
+type serviceVerifyPolicyClient struct {
+	grpc.ClientStream
+}
+
+func (x *serviceVerifyPolicyClient) Recv() (*ManagedFileResponse, error) {
+	m := new(ManagedFileResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) SimulatePolicy(ctx context.Context, in *SimulatePolicyRequest, opts ...grpc.CallOption) (Service_SimulatePolicyClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[24], Service_SimulatePolicy_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceSimulatePolicyClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_SimulatePolicyClient interface {
+	Recv() (*StringResponse, error)
+	grpc.ClientStream
+}
+
+type serviceSimulatePolicyClient struct {
+	grpc.ClientStream
+}
+
+func (x *serviceSimulatePolicyClient) Recv() (*StringResponse, error) {
+	m := new(StringResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) TraceExport(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Service_TraceExportClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[25], Service_TraceExport_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceTraceExportClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_TraceExportClient interface {
+	Recv() (*PolicyExportResponse, error)
+	grpc.ClientStream
+}
+
+type serviceTraceExportClient struct {
+	grpc.ClientStream
+}
+
+func (x *serviceTraceExportClient) Recv() (*PolicyExportResponse, error) {
+	m := new(PolicyExportResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) PolicyPurge(ctx context.Context, in *PolicyPurgeRequest, opts ...grpc.CallOption) (Service_PolicyPurgeClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[26], Service_PolicyPurge_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &servicePolicyPurgeClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_PolicyPurgeClient interface {
+	Recv() (*StaleFileResponse, error)
+	grpc.ClientStream
+}
+
+type servicePolicyPurgeClient struct {
+	grpc.ClientStream
+}
+
+func (x *servicePolicyPurgeClient) Recv() (*StaleFileResponse, error) {
+	m := new(StaleFileResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *serviceClient) SudoAccess(ctx context.Context, in *SudoAccessRequest, opts ...grpc.CallOption) (Service_SudoAccessClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[27], Service_SudoAccess_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceSudoAccessClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Service_SudoAccessClient interface {
+	Recv() (*SudoAccessResponse, error)
+	grpc.ClientStream
+}
+
+type serviceSudoAccessClient struct {
+	grpc.ClientStream
+}
+
+func (x *serviceSudoAccessClient) Recv() (*SudoAccessResponse, error) {
+	m := new(SudoAccessResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // ServiceServer is the server API for Service service.
 // All implementations must embed UnimplementedServiceServer
 // for forward compatibility
@@ -471,311 +1031,711 @@ type ServiceServer interface {
 	ListUsers(*ListUsersRequest, Service_ListUsersServer) error
 	GPOListScript(*Empty, Service_GPOListScriptServer) error
 	CertAutoEnrollScript(*Empty, Service_CertAutoEnrollScriptServer) error
+	TicketStatus(*TicketStatusRequest, Service_TicketStatusServer) error
+	PolicyExport(*Empty, Service_PolicyExportServer) error
+	PolicyImport(*PolicyImportRequest, Service_PolicyImportServer) error
+	MountStatus(*Empty, Service_MountStatusServer) error
+	IgnoredEntries(*IgnoredEntriesRequest, Service_IgnoredEntriesServer) error
+	RestartHints(*RestartHintsRequest, Service_RestartHintsServer) error
+	PrivilegeFiles(*Empty, Service_PrivilegeFilesServer) error
+	SystemAdminIdentities(*Empty, Service_SystemAdminIdentitiesServer) error
+	EffectivePolicy(*Empty, Service_EffectivePolicyServer) error
+	PolicyDrift(*PolicyDriftRequest, Service_PolicyDriftServer) error
+	PolicyFetchDryRun(*PolicyFetchRequest, Service_PolicyFetchDryRunServer) error
+	VerifyPolicy(*VerifyPolicyRequest, Service_VerifyPolicyServer) error
+	SimulatePolicy(*SimulatePolicyRequest, Service_SimulatePolicyServer) error
+	TraceExport(*Empty, Service_TraceExportServer) error
+	PolicyPurge(*PolicyPurgeRequest, Service_PolicyPurgeServer) error
+	SudoAccess(*SudoAccessRequest, Service_SudoAccessServer) error
+	mustEmbedUnimplementedServiceServer()
+}
+
+// UnimplementedServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedServiceServer struct {
+}
+
+func (UnimplementedServiceServer) Cat(*Empty, Service_CatServer) error {
+	return status.Errorf(codes.Unimplemented, "method Cat not implemented")
+}
+func (UnimplementedServiceServer) Version(*Empty, Service_VersionServer) error {
+	return status.Errorf(codes.Unimplemented, "method Version not implemented")
+}
+func (UnimplementedServiceServer) Status(*Empty, Service_StatusServer) error {
+	return status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedServiceServer) Stop(*StopRequest, Service_StopServer) error {
+	return status.Errorf(codes.Unimplemented, "method Stop not implemented")
+}
+func (UnimplementedServiceServer) UpdatePolicy(*UpdatePolicyRequest, Service_UpdatePolicyServer) error {
+	return status.Errorf(codes.Unimplemented, "method UpdatePolicy not implemented")
+}
+func (UnimplementedServiceServer) DumpPolicies(*DumpPoliciesRequest, Service_DumpPoliciesServer) error {
+	return status.Errorf(codes.Unimplemented, "method DumpPolicies not implemented")
+}
+func (UnimplementedServiceServer) DumpPoliciesDefinitions(*DumpPolicyDefinitionsRequest, Service_DumpPoliciesDefinitionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method DumpPoliciesDefinitions not implemented")
+}
+func (UnimplementedServiceServer) GetDoc(*GetDocRequest, Service_GetDocServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetDoc not implemented")
+}
+func (UnimplementedServiceServer) ListDoc(*Empty, Service_ListDocServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListDoc not implemented")
+}
+func (UnimplementedServiceServer) ListUsers(*ListUsersRequest, Service_ListUsersServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListUsers not implemented")
+}
+func (UnimplementedServiceServer) GPOListScript(*Empty, Service_GPOListScriptServer) error {
+	return status.Errorf(codes.Unimplemented, "method GPOListScript not implemented")
+}
+func (UnimplementedServiceServer) CertAutoEnrollScript(*Empty, Service_CertAutoEnrollScriptServer) error {
+	return status.Errorf(codes.Unimplemented, "method CertAutoEnrollScript not implemented")
+}
+func (UnimplementedServiceServer) TicketStatus(*TicketStatusRequest, Service_TicketStatusServer) error {
+	return status.Errorf(codes.Unimplemented, "method TicketStatus not implemented")
+}
+func (UnimplementedServiceServer) PolicyExport(*Empty, Service_PolicyExportServer) error {
+	return status.Errorf(codes.Unimplemented, "method PolicyExport not implemented")
+}
+func (UnimplementedServiceServer) PolicyImport(*PolicyImportRequest, Service_PolicyImportServer) error {
+	return status.Errorf(codes.Unimplemented, "method PolicyImport not implemented")
+}
+func (UnimplementedServiceServer) MountStatus(*Empty, Service_MountStatusServer) error {
+	return status.Errorf(codes.Unimplemented, "method MountStatus not implemented")
+}
+func (UnimplementedServiceServer) IgnoredEntries(*IgnoredEntriesRequest, Service_IgnoredEntriesServer) error {
+	return status.Errorf(codes.Unimplemented, "method IgnoredEntries not implemented")
+}
+func (UnimplementedServiceServer) RestartHints(*RestartHintsRequest, Service_RestartHintsServer) error {
+	return status.Errorf(codes.Unimplemented, "method RestartHints not implemented")
+}
+func (UnimplementedServiceServer) PrivilegeFiles(*Empty, Service_PrivilegeFilesServer) error {
+	return status.Errorf(codes.Unimplemented, "method PrivilegeFiles not implemented")
+}
+func (UnimplementedServiceServer) SystemAdminIdentities(*Empty, Service_SystemAdminIdentitiesServer) error {
+	return status.Errorf(codes.Unimplemented, "method SystemAdminIdentities not implemented")
+}
+func (UnimplementedServiceServer) EffectivePolicy(*Empty, Service_EffectivePolicyServer) error {
+	return status.Errorf(codes.Unimplemented, "method EffectivePolicy not implemented")
+}
+func (UnimplementedServiceServer) PolicyDrift(*PolicyDriftRequest, Service_PolicyDriftServer) error {
+	return status.Errorf(codes.Unimplemented, "method PolicyDrift not implemented")
+}
+func (UnimplementedServiceServer) PolicyFetchDryRun(*PolicyFetchRequest, Service_PolicyFetchDryRunServer) error {
+	return status.Errorf(codes.Unimplemented, "method PolicyFetchDryRun not implemented")
+}
+func (UnimplementedServiceServer) VerifyPolicy(*VerifyPolicyRequest, Service_VerifyPolicyServer) error {
+	return status.Errorf(codes.Unimplemented, "method VerifyPolicy not implemented")
+}
+func (UnimplementedServiceServer) SimulatePolicy(*SimulatePolicyRequest, Service_SimulatePolicyServer) error {
+	return status.Errorf(codes.Unimplemented, "method SimulatePolicy not implemented")
+}
+func (UnimplementedServiceServer) TraceExport(*Empty, Service_TraceExportServer) error {
+	return status.Errorf(codes.Unimplemented, "method TraceExport not implemented")
+}
+func (UnimplementedServiceServer) PolicyPurge(*PolicyPurgeRequest, Service_PolicyPurgeServer) error {
+	return status.Errorf(codes.Unimplemented, "method PolicyPurge not implemented")
+}
+func (UnimplementedServiceServer) SudoAccess(*SudoAccessRequest, Service_SudoAccessServer) error {
+	return status.Errorf(codes.Unimplemented, "method SudoAccess not implemented")
+}
+func (UnimplementedServiceServer) mustEmbedUnimplementedServiceServer() {}
+
+// UnsafeServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ServiceServer will
+// result in compilation errors.
+type UnsafeServiceServer interface {
 	mustEmbedUnimplementedServiceServer()
 }
 
-// UnimplementedServiceServer must be embedded to have forward compatible implementations.
-type UnimplementedServiceServer struct {
+func RegisterServiceServer(s grpc.ServiceRegistrar, srv ServiceServer) {
+	s.RegisterService(&Service_ServiceDesc, srv)
+}
+
+func _Service_Cat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).Cat(m, &serviceCatServer{ServerStream: stream})
+}
+
+type Service_CatServer interface {
+	Send(*StringResponse) error
+	grpc.ServerStream
+}
+
+type serviceCatServer struct {
+	grpc.ServerStream
+}
+
+func (x *serviceCatServer) Send(m *StringResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Service_Version_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).Version(m, &serviceVersionServer{ServerStream: stream})
+}
+
+type Service_VersionServer interface {
+	Send(*StringResponse) error
+	grpc.ServerStream
+}
+
+type serviceVersionServer struct {
+	grpc.ServerStream
+}
+
+func (x *serviceVersionServer) Send(m *StringResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Service_Status_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).Status(m, &serviceStatusServer{ServerStream: stream})
+}
+
+type Service_StatusServer interface {
+	Send(*StringResponse) error
+	grpc.ServerStream
+}
+
+type serviceStatusServer struct {
+	grpc.ServerStream
+}
+
+func (x *serviceStatusServer) Send(m *StringResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Service_Stop_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StopRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).Stop(m, &serviceStopServer{ServerStream: stream})
+}
+
+type Service_StopServer interface {
+	Send(*Empty) error
+	grpc.ServerStream
+}
+
+type serviceStopServer struct {
+	grpc.ServerStream
+}
+
+func (x *serviceStopServer) Send(m *Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Service_UpdatePolicy_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(UpdatePolicyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).UpdatePolicy(m, &serviceUpdatePolicyServer{ServerStream: stream})
+}
+
+type Service_UpdatePolicyServer interface {
+	Send(*Empty) error
+	grpc.ServerStream
+}
+
+type serviceUpdatePolicyServer struct {
+	grpc.ServerStream
+}
+
+func (x *serviceUpdatePolicyServer) Send(m *Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Service_DumpPolicies_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DumpPoliciesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).DumpPolicies(m, &serviceDumpPoliciesServer{ServerStream: stream})
+}
+
+type Service_DumpPoliciesServer interface {
+	Send(*StringResponse) error
+	grpc.ServerStream
+}
+
+type serviceDumpPoliciesServer struct {
+	grpc.ServerStream
+}
+
+func (x *serviceDumpPoliciesServer) Send(m *StringResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Service_DumpPoliciesDefinitions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DumpPolicyDefinitionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).DumpPoliciesDefinitions(m, &serviceDumpPoliciesDefinitionsServer{ServerStream: stream})
+}
+
+type Service_DumpPoliciesDefinitionsServer interface {
+	Send(*DumpPolicyDefinitionsResponse) error
+	grpc.ServerStream
+}
+
+type serviceDumpPoliciesDefinitionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *serviceDumpPoliciesDefinitionsServer) Send(m *DumpPolicyDefinitionsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Service_GetDoc_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetDocRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).GetDoc(m, &serviceGetDocServer{ServerStream: stream})
+}
+
+type Service_GetDocServer interface {
+	Send(*StringResponse) error
+	grpc.ServerStream
+}
+
+type serviceGetDocServer struct {
+	grpc.ServerStream
+}
+
+func (x *serviceGetDocServer) Send(m *StringResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Service_ListDoc_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).ListDoc(m, &serviceListDocServer{ServerStream: stream})
+}
+
+type Service_ListDocServer interface {
+	Send(*ListDocReponse) error
+	grpc.ServerStream
+}
+
+type serviceListDocServer struct {
+	grpc.ServerStream
+}
+
+func (x *serviceListDocServer) Send(m *ListDocReponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Service_ListUsers_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListUsersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).ListUsers(m, &serviceListUsersServer{ServerStream: stream})
+}
+
+type Service_ListUsersServer interface {
+	Send(*StringResponse) error
+	grpc.ServerStream
+}
+
+type serviceListUsersServer struct {
+	grpc.ServerStream
 }
 
-func (UnimplementedServiceServer) Cat(*Empty, Service_CatServer) error {
-	return status.Errorf(codes.Unimplemented, "method Cat not implemented")
-}
-func (UnimplementedServiceServer) Version(*Empty, Service_VersionServer) error {
-	return status.Errorf(codes.Unimplemented, "method Version not implemented")
+func (x *serviceListUsersServer) Send(m *StringResponse) error {
+	return x.ServerStream.SendMsg(m)
 }
-func (UnimplementedServiceServer) Status(*Empty, Service_StatusServer) error {
-	return status.Errorf(codes.Unimplemented, "method Status not implemented")
+
+func _Service_GPOListScript_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).GPOListScript(m, &serviceGPOListScriptServer{ServerStream: stream})
 }
-func (UnimplementedServiceServer) Stop(*StopRequest, Service_StopServer) error {
-	return status.Errorf(codes.Unimplemented, "method Stop not implemented")
+
+type Service_GPOListScriptServer interface {
+	Send(*StringResponse) error
+	grpc.ServerStream
 }
-func (UnimplementedServiceServer) UpdatePolicy(*UpdatePolicyRequest, Service_UpdatePolicyServer) error {
-	return status.Errorf(codes.Unimplemented, "method UpdatePolicy not implemented")
+
+type serviceGPOListScriptServer struct {
+	grpc.ServerStream
 }
-func (UnimplementedServiceServer) DumpPolicies(*DumpPoliciesRequest, Service_DumpPoliciesServer) error {
-	return status.Errorf(codes.Unimplemented, "method DumpPolicies not implemented")
+
+func (x *serviceGPOListScriptServer) Send(m *StringResponse) error {
+	return x.ServerStream.SendMsg(m)
 }
-func (UnimplementedServiceServer) DumpPoliciesDefinitions(*DumpPolicyDefinitionsRequest, Service_DumpPoliciesDefinitionsServer) error {
-	return status.Errorf(codes.Unimplemented, "method DumpPoliciesDefinitions not implemented")
+
+func _Service_CertAutoEnrollScript_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).CertAutoEnrollScript(m, &serviceCertAutoEnrollScriptServer{ServerStream: stream})
 }
-func (UnimplementedServiceServer) GetDoc(*GetDocRequest, Service_GetDocServer) error {
-	return status.Errorf(codes.Unimplemented, "method GetDoc not implemented")
+
+type Service_CertAutoEnrollScriptServer interface {
+	Send(*StringResponse) error
+	grpc.ServerStream
 }
-func (UnimplementedServiceServer) ListDoc(*Empty, Service_ListDocServer) error {
-	return status.Errorf(codes.Unimplemented, "method ListDoc not implemented")
+
+type serviceCertAutoEnrollScriptServer struct {
+	grpc.ServerStream
 }
-func (UnimplementedServiceServer) ListUsers(*ListUsersRequest, Service_ListUsersServer) error {
-	return status.Errorf(codes.Unimplemented, "method ListUsers not implemented")
+
+func (x *serviceCertAutoEnrollScriptServer) Send(m *StringResponse) error {
+	return x.ServerStream.SendMsg(m)
 }
-func (UnimplementedServiceServer) GPOListScript(*Empty, Service_GPOListScriptServer) error {
-	return status.Errorf(codes.Unimplemented, "method GPOListScript not implemented")
+
+func _Service_TicketStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TicketStatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).TicketStatus(m, &serviceTicketStatusServer{ServerStream: stream})
 }
-func (UnimplementedServiceServer) CertAutoEnrollScript(*Empty, Service_CertAutoEnrollScriptServer) error {
-	return status.Errorf(codes.Unimplemented, "method CertAutoEnrollScript not implemented")
+
+type Service_TicketStatusServer interface {
+	Send(*TicketStatusResponse) error
+	grpc.ServerStream
 }
-func (UnimplementedServiceServer) mustEmbedUnimplementedServiceServer() {}
 
-// UnsafeServiceServer may be embedded to opt out of forward compatibility for this service.
-// Use of this interface is not recommended, as added methods to ServiceServer will
-// result in compilation errors.
-type UnsafeServiceServer interface {
-	mustEmbedUnimplementedServiceServer()
+type serviceTicketStatusServer struct {
+	grpc.ServerStream
 }
 
-func RegisterServiceServer(s grpc.ServiceRegistrar, srv ServiceServer) {
-	s.RegisterService(&Service_ServiceDesc, srv)
+func (x *serviceTicketStatusServer) Send(m *TicketStatusResponse) error {
+	return x.ServerStream.SendMsg(m)
 }
 
-func _Service_Cat_Handler(srv interface{}, stream grpc.ServerStream) error {
+func _Service_PolicyExport_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(Empty)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ServiceServer).Cat(m, &serviceCatServer{ServerStream: stream})
+	return srv.(ServiceServer).PolicyExport(m, &servicePolicyExportServer{ServerStream: stream})
 }
 
-type Service_CatServer interface {
-	Send(*StringResponse) error
+type Service_PolicyExportServer interface {
+	Send(*PolicyExportResponse) error
 	grpc.ServerStream
 }
 
-type serviceCatServer struct {
+type servicePolicyExportServer struct {
 	grpc.ServerStream
 }
 
-func (x *serviceCatServer) Send(m *StringResponse) error {
+func (x *servicePolicyExportServer) Send(m *PolicyExportResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _Service_Version_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(Empty)
+func _Service_PolicyImport_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PolicyImportRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ServiceServer).Version(m, &serviceVersionServer{ServerStream: stream})
+	return srv.(ServiceServer).PolicyImport(m, &servicePolicyImportServer{ServerStream: stream})
 }
 
-type Service_VersionServer interface {
-	Send(*StringResponse) error
+type Service_PolicyImportServer interface {
+	Send(*Empty) error
 	grpc.ServerStream
 }
 
-type serviceVersionServer struct {
+type servicePolicyImportServer struct {
 	grpc.ServerStream
 }
 
-func (x *serviceVersionServer) Send(m *StringResponse) error {
+func (x *servicePolicyImportServer) Send(m *Empty) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _Service_Status_Handler(srv interface{}, stream grpc.ServerStream) error {
+func _Service_MountStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(Empty)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ServiceServer).Status(m, &serviceStatusServer{ServerStream: stream})
+	return srv.(ServiceServer).MountStatus(m, &serviceMountStatusServer{ServerStream: stream})
 }
 
-type Service_StatusServer interface {
-	Send(*StringResponse) error
+type Service_MountStatusServer interface {
+	Send(*MountStatusResponse) error
 	grpc.ServerStream
 }
 
-type serviceStatusServer struct {
+type serviceMountStatusServer struct {
 	grpc.ServerStream
 }
 
-func (x *serviceStatusServer) Send(m *StringResponse) error {
+func (x *serviceMountStatusServer) Send(m *MountStatusResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _Service_Stop_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(StopRequest)
+func _Service_IgnoredEntries_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(IgnoredEntriesRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ServiceServer).Stop(m, &serviceStopServer{ServerStream: stream})
+	return srv.(ServiceServer).IgnoredEntries(m, &serviceIgnoredEntriesServer{ServerStream: stream})
 }
 
-type Service_StopServer interface {
-	Send(*Empty) error
+type Service_IgnoredEntriesServer interface {
+	Send(*IgnoredEntryResponse) error
 	grpc.ServerStream
 }
 
-type serviceStopServer struct {
+type serviceIgnoredEntriesServer struct {
 	grpc.ServerStream
 }
 
-func (x *serviceStopServer) Send(m *Empty) error {
+func (x *serviceIgnoredEntriesServer) Send(m *IgnoredEntryResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _Service_UpdatePolicy_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(UpdatePolicyRequest)
+func _Service_RestartHints_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RestartHintsRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ServiceServer).UpdatePolicy(m, &serviceUpdatePolicyServer{ServerStream: stream})
+	return srv.(ServiceServer).RestartHints(m, &serviceRestartHintsServer{ServerStream: stream})
 }
 
-type Service_UpdatePolicyServer interface {
-	Send(*Empty) error
+type Service_RestartHintsServer interface {
+	Send(*RestartHintResponse) error
 	grpc.ServerStream
 }
 
-type serviceUpdatePolicyServer struct {
+type serviceRestartHintsServer struct {
 	grpc.ServerStream
 }
 
-func (x *serviceUpdatePolicyServer) Send(m *Empty) error {
+func (x *serviceRestartHintsServer) Send(m *RestartHintResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _Service_DumpPolicies_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(DumpPoliciesRequest)
+func _Service_PrivilegeFiles_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ServiceServer).DumpPolicies(m, &serviceDumpPoliciesServer{ServerStream: stream})
+	return srv.(ServiceServer).PrivilegeFiles(m, &servicePrivilegeFilesServer{ServerStream: stream})
 }
 
-type Service_DumpPoliciesServer interface {
-	Send(*StringResponse) error
+type Service_PrivilegeFilesServer interface {
+	Send(*PrivilegeFileResponse) error
 	grpc.ServerStream
 }
 
-type serviceDumpPoliciesServer struct {
+type servicePrivilegeFilesServer struct {
 	grpc.ServerStream
 }
 
-func (x *serviceDumpPoliciesServer) Send(m *StringResponse) error {
+func (x *servicePrivilegeFilesServer) Send(m *PrivilegeFileResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _Service_DumpPoliciesDefinitions_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(DumpPolicyDefinitionsRequest)
+func _Service_SystemAdminIdentities_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ServiceServer).DumpPoliciesDefinitions(m, &serviceDumpPoliciesDefinitionsServer{ServerStream: stream})
+	return srv.(ServiceServer).SystemAdminIdentities(m, &serviceSystemAdminIdentitiesServer{ServerStream: stream})
 }
 
-type Service_DumpPoliciesDefinitionsServer interface {
-	Send(*DumpPolicyDefinitionsResponse) error
+type Service_SystemAdminIdentitiesServer interface {
+	Send(*SystemAdminIdentitiesResponse) error
 	grpc.ServerStream
 }
 
-type serviceDumpPoliciesDefinitionsServer struct {
+type serviceSystemAdminIdentitiesServer struct {
 	grpc.ServerStream
 }
 
-func (x *serviceDumpPoliciesDefinitionsServer) Send(m *DumpPolicyDefinitionsResponse) error {
+func (x *serviceSystemAdminIdentitiesServer) Send(m *SystemAdminIdentitiesResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _Service_GetDoc_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(GetDocRequest)
+func _Service_EffectivePolicy_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ServiceServer).GetDoc(m, &serviceGetDocServer{ServerStream: stream})
+	return srv.(ServiceServer).EffectivePolicy(m, &serviceEffectivePolicyServer{ServerStream: stream})
 }
 
-type Service_GetDocServer interface {
+type Service_EffectivePolicyServer interface {
 	Send(*StringResponse) error
 	grpc.ServerStream
 }
 
-type serviceGetDocServer struct {
+type serviceEffectivePolicyServer struct {
 	grpc.ServerStream
 }
 
-func (x *serviceGetDocServer) Send(m *StringResponse) error {
+func (x *serviceEffectivePolicyServer) Send(m *StringResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _Service_ListDoc_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(Empty)
+func _Service_PolicyDrift_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PolicyDriftRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ServiceServer).ListDoc(m, &serviceListDocServer{ServerStream: stream})
+	return srv.(ServiceServer).PolicyDrift(m, &servicePolicyDriftServer{ServerStream: stream})
 }
 
-type Service_ListDocServer interface {
-	Send(*ListDocReponse) error
+type Service_PolicyDriftServer interface {
+	Send(*GPODriftResponse) error
 	grpc.ServerStream
 }
 
-type serviceListDocServer struct {
+type servicePolicyDriftServer struct {
 	grpc.ServerStream
 }
 
-func (x *serviceListDocServer) Send(m *ListDocReponse) error {
+func (x *servicePolicyDriftServer) Send(m *GPODriftResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _Service_ListUsers_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(ListUsersRequest)
+func _Service_PolicyFetchDryRun_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PolicyFetchRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ServiceServer).ListUsers(m, &serviceListUsersServer{ServerStream: stream})
+	return srv.(ServiceServer).PolicyFetchDryRun(m, &servicePolicyFetchDryRunServer{ServerStream: stream})
 }
 
-type Service_ListUsersServer interface {
-	Send(*StringResponse) error
+type Service_PolicyFetchDryRunServer interface {
+	Send(*GPOFetchResponse) error
 	grpc.ServerStream
 }
 
-type serviceListUsersServer struct {
+type servicePolicyFetchDryRunServer struct {
 	grpc.ServerStream
 }
 
-func (x *serviceListUsersServer) Send(m *StringResponse) error {
+func (x *servicePolicyFetchDryRunServer) Send(m *GPOFetchResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _Service_GPOListScript_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(Empty)
+func _Service_VerifyPolicy_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(VerifyPolicyRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ServiceServer).GPOListScript(m, &serviceGPOListScriptServer{ServerStream: stream})
+	return srv.(ServiceServer).VerifyPolicy(m, &serviceVerifyPolicyServer{ServerStream: stream})
 }
 
-type Service_GPOListScriptServer interface {
+type Service_VerifyPolicyServer interface {
+	Send(*ManagedFileResponse) error
+	grpc.ServerStream
+}
+
+type serviceVerifyPolicyServer struct {
+	grpc.ServerStream
+}
+
+func (x *serviceVerifyPolicyServer) Send(m *ManagedFileResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Service_SimulatePolicy_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SimulatePolicyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).SimulatePolicy(m, &serviceSimulatePolicyServer{ServerStream: stream})
+}
+
+type Service_SimulatePolicyServer interface {
 	Send(*StringResponse) error
 	grpc.ServerStream
 }
 
-type serviceGPOListScriptServer struct {
+type serviceSimulatePolicyServer struct {
 	grpc.ServerStream
 }
 
-func (x *serviceGPOListScriptServer) Send(m *StringResponse) error {
+func (x *serviceSimulatePolicyServer) Send(m *StringResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _Service_CertAutoEnrollScript_Handler(srv interface{}, stream grpc.ServerStream) error {
+func _Service_TraceExport_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(Empty)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ServiceServer).CertAutoEnrollScript(m, &serviceCertAutoEnrollScriptServer{ServerStream: stream})
+	return srv.(ServiceServer).TraceExport(m, &serviceTraceExportServer{ServerStream: stream})
 }
 
-type Service_CertAutoEnrollScriptServer interface {
-	Send(*StringResponse) error
+type Service_TraceExportServer interface {
+	Send(*PolicyExportResponse) error
 	grpc.ServerStream
 }
 
-type serviceCertAutoEnrollScriptServer struct {
+type serviceTraceExportServer struct {
 	grpc.ServerStream
 }
 
-func (x *serviceCertAutoEnrollScriptServer) Send(m *StringResponse) error {
+func (x *serviceTraceExportServer) Send(m *PolicyExportResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Service_PolicyPurge_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PolicyPurgeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).PolicyPurge(m, &servicePolicyPurgeServer{ServerStream: stream})
+}
+
+type Service_PolicyPurgeServer interface {
+	Send(*StaleFileResponse) error
+	grpc.ServerStream
+}
+
+type servicePolicyPurgeServer struct {
+	grpc.ServerStream
+}
+
+func (x *servicePolicyPurgeServer) Send(m *StaleFileResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Service_SudoAccess_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SudoAccessRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).SudoAccess(m, &serviceSudoAccessServer{ServerStream: stream})
+}
+
+type Service_SudoAccessServer interface {
+	Send(*SudoAccessResponse) error
+	grpc.ServerStream
+}
+
+type serviceSudoAccessServer struct {
+	grpc.ServerStream
+}
+
+func (x *serviceSudoAccessServer) Send(m *SudoAccessResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
@@ -847,6 +1807,86 @@ var Service_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Service_CertAutoEnrollScript_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "TicketStatus",
+			Handler:       _Service_TicketStatus_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PolicyExport",
+			Handler:       _Service_PolicyExport_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PolicyImport",
+			Handler:       _Service_PolicyImport_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "MountStatus",
+			Handler:       _Service_MountStatus_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "IgnoredEntries",
+			Handler:       _Service_IgnoredEntries_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "RestartHints",
+			Handler:       _Service_RestartHints_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PrivilegeFiles",
+			Handler:       _Service_PrivilegeFiles_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SystemAdminIdentities",
+			Handler:       _Service_SystemAdminIdentities_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "EffectivePolicy",
+			Handler:       _Service_EffectivePolicy_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PolicyDrift",
+			Handler:       _Service_PolicyDrift_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PolicyFetchDryRun",
+			Handler:       _Service_PolicyFetchDryRun_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "VerifyPolicy",
+			Handler:       _Service_VerifyPolicy_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SimulatePolicy",
+			Handler:       _Service_SimulatePolicy_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "TraceExport",
+			Handler:       _Service_TraceExport_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PolicyPurge",
+			Handler:       _Service_PolicyPurge_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SudoAccess",
+			Handler:       _Service_SudoAccess_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "adsys.proto",
 }
@@ -35,6 +35,9 @@ type daemonConfig struct {
 	Socket             string
 	ClientTimeout      int  `mapstructure:"client_timeout"`
 	DetectCachedTicket bool `mapstructure:"detect_cached_ticket"`
+
+	CCacheName   string `mapstructure:"ccache_name"`
+	OTLPEndpoint string `mapstructure:"otlp_endpoint"`
 }
 
 // New registers commands and return a new App.
@@ -106,6 +109,7 @@ func New() *App {
 
 	// subcommands
 	a.installDoc()
+	a.installKrb5()
 	a.installPolicy()
 	a.installService()
 	a.installVersion()
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/spf13/cobra"
+	"github.com/ubuntu/adsys"
+	"github.com/ubuntu/adsys/internal/ad"
+	"github.com/ubuntu/adsys/internal/adsysservice"
+	"github.com/ubuntu/adsys/internal/cmdhandler"
+)
+
+func (a *App) installKrb5() {
+	mainCmd := &cobra.Command{
+		Use:   "krb5 COMMAND",
+		Short: gotext.Get("Kerberos ticket management"),
+		Args:  cmdhandler.SubcommandsRequiredWithSuggestions,
+		RunE:  cmdhandler.NoCmd,
+	}
+	a.rootCmd.AddCommand(mainCmd)
+
+	var isMachine *bool
+	cmd := &cobra.Command{
+		Use:   "status [USER_NAME]",
+		Short: gotext.Get("Print the Kerberos ticket status of current or given user/machine"),
+		Args:  cmdhandler.ZeroOrNArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 || *isMachine {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return a.users(true), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			var target string
+			if len(args) > 0 {
+				target = args[0]
+			}
+			return a.ticketStatus(target, *isMachine)
+		},
+	}
+	isMachine = cmd.Flags().BoolP("machine", "m", false, gotext.Get("show the ticket status of the machine."))
+	mainCmd.AddCommand(cmd)
+}
+
+// ticketStatus prints the state of the kerberos ticket for the current or given user, or the machine.
+func (a *App) ticketStatus(target string, isMachine bool) error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Status for current user or machine
+	if target == "" {
+		if isMachine {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return fmt.Errorf("failed to retrieve client hostname: %w", err)
+			}
+			target = hostname
+		} else {
+			u, err := user.Current()
+			if err != nil {
+				return fmt.Errorf("failed to retrieve current user: %w", err)
+			}
+			target = u.Username
+		}
+	}
+
+	stream, err := client.TicketStatus(a.ctx, &adsys.TicketStatusRequest{
+		Target:     target,
+		IsComputer: isMachine,
+	})
+	if err != nil {
+		return err
+	}
+
+	var status *adsys.TicketStatusResponse
+	for {
+		r, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		status = r
+	}
+	if status == nil {
+		return errors.New(gotext.Get("no ticket status received from service"))
+	}
+
+	fmt.Print(formatTicketStatus(target, status))
+	return nil
+}
+
+// formatTicketStatus returns a human readable kerberos ticket status.
+func formatTicketStatus(target string, status *adsys.TicketStatusResponse) string {
+	expiry := time.Unix(status.GetExpiry(), 0).Format("2006-01-02 15:04:05 MST")
+
+	var state string
+	switch ad.TicketState(status.GetState()) {
+	case ad.TicketAbsent:
+		return gotext.Get("No Kerberos ticket found for %s\n", target)
+	case ad.TicketExpired:
+		state = gotext.Get("expired since %s", expiry)
+	case ad.TicketValid:
+		state = gotext.Get("valid until %s", expiry)
+	default:
+		state = status.GetState()
+	}
+
+	return gotext.Get("Kerberos ticket for %s\n  Path: %s\n  Principal: %s\n  Status: %s\n", target, status.GetPath(), status.GetPrincipal(), state)
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys"
+)
+
+func TestFormatTicketStatus(t *testing.T) {
+	t.Parallel()
+
+	expiry := time.Date(2024, time.May, 2, 10, 30, 0, 0, time.UTC)
+	expiryStr := expiry.Local().Format("2006-01-02 15:04:05 MST")
+
+	tests := map[string]struct {
+		state string
+
+		want string
+	}{
+		"No ticket": {state: "absent", want: "No Kerberos ticket found for bob@example.com\n"},
+		"Expired ticket": {state: "expired",
+			want: fmt.Sprintf("Kerberos ticket for bob@example.com\n  Path: /tmp/krb5cc_1000\n  Principal: bob@EXAMPLE.COM\n  Status: expired since %s\n", expiryStr)},
+		"Valid ticket": {state: "valid",
+			want: fmt.Sprintf("Kerberos ticket for bob@example.com\n  Path: /tmp/krb5cc_1000\n  Principal: bob@EXAMPLE.COM\n  Status: valid until %s\n", expiryStr)},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := formatTicketStatus("bob@example.com", &adsys.TicketStatusResponse{
+				State:     tc.state,
+				Path:      "/tmp/krb5cc_1000",
+				Principal: "bob@EXAMPLE.COM",
+				Expiry:    expiry.Unix(),
+			})
+			require.Equal(t, tc.want, got, "formatTicketStatus should return the expected message")
+		})
+	}
+}
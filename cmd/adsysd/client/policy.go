@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"os/user"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -19,6 +20,7 @@ import (
 	"github.com/ubuntu/adsys/internal/cmdhandler"
 	"github.com/ubuntu/adsys/internal/consts"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/telemetry"
 	"github.com/ubuntu/decorate"
 	"golang.org/x/sys/unix"
 )
@@ -116,6 +118,7 @@ The command is a no-op if the ticket is not present on disk or the detect_cached
 	debugCmd.AddCommand(ticketPathCmd)
 
 	var updateMachine, updateAll *bool
+	var updateTrace *string
 	updateCmd := &cobra.Command{
 		Use:   "update [USER_NAME KERBEROS_TICKET_PATH]",
 		Short: gotext.Get("Updates/Create a policy for current user or given user with its kerberos ticket"),
@@ -142,22 +145,24 @@ The command is a no-op if the ticket is not present on disk or the detect_cached
 			if len(args) > 0 {
 				user, krb5cc = args[0], args[1]
 			}
-			return a.update(*updateMachine, *updateAll, user, krb5cc)
+			return a.update(*updateMachine, *updateAll, user, krb5cc, *updateTrace)
 		},
 	}
 	updateMachine = updateCmd.Flags().BoolP("machine", "m", false, gotext.Get("machine updates the policy of the computer."))
 	updateAll = updateCmd.Flags().BoolP("all", "a", false, gotext.Get("all updates the policy of the computer and all the logged in users. -m or USER_NAME/TICKET cannot be used with this option."))
+	updateTrace = updateCmd.Flags().String("trace", "", gotext.Get("write to this archive a trace bundle of what each policy manager received and changed during the update."))
 	policyCmd.AddCommand(updateCmd)
 	cmdhandler.RegisterAlias(updateCmd, &a.rootCmd)
 
-	var purgeMachine, purgeAll *bool
+	var purgeMachine, purgeAll, purgeStale, purgeApply *bool
+	var purgeTypes *[]string
 	purgeCmd := &cobra.Command{
 		Use:   "purge [USER_NAME]",
 		Short: gotext.Get("Purges policies for the current user or a specified one"),
 		Args:  cmdhandler.ZeroOrNArgs(1),
 		ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
-			// All and machine options don’t take arguments
-			if *purgeAll || *purgeMachine || len(args) != 0 {
+			// All, machine and stale options don’t take arguments
+			if *purgeAll || *purgeMachine || *purgeStale || len(args) != 0 {
 				return nil, cobra.ShellCompDirectiveNoFileComp
 			}
 
@@ -169,14 +174,199 @@ The command is a no-op if the ticket is not present on disk or the detect_cached
 			if len(args) > 0 {
 				user = args[0]
 			}
+			if *purgeStale {
+				if user != "" {
+					return errors.New(gotext.Get("user arguments cannot be used with stale files purge"))
+				}
+				return a.purgeStaleFiles(*purgeTypes, *purgeApply)
+			}
+			if *purgeApply || len(*purgeTypes) > 0 {
+				return errors.New(gotext.Get("--type and --apply can only be used with --stale"))
+			}
 			return a.purge(*purgeMachine, *purgeAll, user)
 		},
 	}
 	purgeMachine = purgeCmd.Flags().BoolP("machine", "m", false, gotext.Get("machine purges the policy of the computer."))
 	purgeAll = purgeCmd.Flags().BoolP("all", "a", false, gotext.Get("all purges the policy of the computer and all the logged in users. -m or USER_NAME cannot be used with this option."))
+	purgeStale = purgeCmd.Flags().BoolP("stale", "", false, gotext.Get("list the files managed by the policy types no applied policy uses anymore, instead of purging policies."))
+	purgeTypes = purgeCmd.Flags().StringSliceP("type", "", nil, gotext.Get("restrict the stale files to this policy type, like mount. Can be repeated."))
+	purgeApply = purgeCmd.Flags().BoolP("apply", "", false, gotext.Get("remove the stale files instead of only listing them."))
 	purgeCmd.MarkFlagsMutuallyExclusive("machine", "all")
+	purgeCmd.MarkFlagsMutuallyExclusive("machine", "stale")
+	purgeCmd.MarkFlagsMutuallyExclusive("all", "stale")
 	policyCmd.AddCommand(purgeCmd)
 
+	exportCmd := &cobra.Command{
+		Use:   "export FILE",
+		Short: gotext.Get("Export the cached GPOs and policies to a compressed archive"),
+		Args:  cobra.ExactArgs(1),
+		RunE:  func(_ *cobra.Command, args []string) error { return a.exportPolicies(args[0]) },
+	}
+	policyCmd.AddCommand(exportCmd)
+
+	var exitReplay *bool
+	importCmd := &cobra.Command{
+		Use:   "import [FILE]",
+		Short: gotext.Get("Import exported GPOs and policies to replay them"),
+		Long: gotext.Get(`Import an archive created by the export command and switch the daemon to replay mode.
+In replay mode, policies updates are computed from the imported cache and logged, but never applied to the system.
+Use --exit to leave replay mode.`),
+		Args: cmdhandler.ZeroOrNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			var path string
+			if len(args) > 0 {
+				path = args[0]
+			}
+			return a.importPolicies(path, *exitReplay)
+		},
+	}
+	exitReplay = importCmd.Flags().BoolP("exit", "", false, gotext.Get("leave replay mode and apply policies again. FILE cannot be used with this option."))
+	policyCmd.AddCommand(importCmd)
+
+	mountsCmd := &cobra.Command{
+		Use:   "mounts",
+		Short: gotext.Get("Print the status of the system shares set by the mount policy"),
+		Args:  cobra.NoArgs,
+		RunE:  func(_ *cobra.Command, _ []string) error { return a.mountStatus() },
+	}
+	policyCmd.AddCommand(mountsCmd)
+
+	var statusMachine, showIgnored *bool
+	statusCmd := &cobra.Command{
+		Use:   "status [USER_NAME]",
+		Short: gotext.Get("Print the status of the last policy refresh for current or given user/machine"),
+		Args:  cmdhandler.ZeroOrNArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+
+			return a.users(true), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			var target string
+			if len(args) > 0 {
+				target = args[0]
+			}
+			return a.policyStatus(target, *statusMachine, *showIgnored)
+		},
+	}
+	statusMachine = statusCmd.Flags().BoolP("machine", "m", false, gotext.Get("show the status of the machine policies."))
+	showIgnored = statusCmd.Flags().BoolP("ignored", "", false, gotext.Get("list the policy entries which were ignored, and why."))
+	policyCmd.AddCommand(statusCmd)
+
+	var driftMachine *bool
+	driftCmd := &cobra.Command{
+		Use:   "drift [USER_NAME]",
+		Short: gotext.Get("Compare the cached version of each GPO for current or given user/machine with the one on the AD controller"),
+		Args:  cmdhandler.ZeroOrNArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+
+			return a.users(true), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			var target string
+			if len(args) > 0 {
+				target = args[0]
+			}
+			return a.policyDrift(target, *driftMachine)
+		},
+	}
+	driftMachine = driftCmd.Flags().BoolP("machine", "m", false, gotext.Get("compare the versions of the machine GPOs."))
+	policyCmd.AddCommand(driftCmd)
+
+	var fetchMachine *bool
+	fetchCmd := &cobra.Command{
+		Use:   "fetch [USER_NAME]",
+		Short: gotext.Get("List the GPOs which would be fetched from the AD controller for current or given user/machine"),
+		Args:  cmdhandler.ZeroOrNArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+
+			return a.users(true), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			var target string
+			if len(args) > 0 {
+				target = args[0]
+			}
+			return a.policyFetchDryRun(target, *fetchMachine)
+		},
+	}
+	fetchMachine = fetchCmd.Flags().BoolP("machine", "m", false, gotext.Get("list the machine GPOs."))
+	// Only the dry-run is supported: the GPOs are downloaded and applied with the update command.
+	fetchCmd.Flags().Bool("dry-run", false, gotext.Get("only report the GPOs, without downloading nor applying them."))
+	if err := fetchCmd.MarkFlagRequired("dry-run"); err != nil {
+		panic(err)
+	}
+	policyCmd.AddCommand(fetchCmd)
+
+	var privilegesRaw, privilegesSystemAdmins *bool
+	privilegesCmd := &cobra.Command{
+		Use:   "privileges",
+		Short: gotext.Get("Print the sudoers and polkit files managed by the privilege policy"),
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if *privilegesSystemAdmins {
+				return a.systemAdminIdentities()
+			}
+			return a.privilegeFiles(*privilegesRaw)
+		},
+	}
+	privilegesRaw = privilegesCmd.Flags().BoolP("raw", "", false, gotext.Get("print the content of the files, as written on disk."))
+	privilegesSystemAdmins = privilegesCmd.Flags().BoolP("system-admins", "", false, gotext.Get("print the system polkit configuration files setting administrators instead."))
+	privilegesCmd.MarkFlagsMutuallyExclusive("raw", "system-admins")
+	policyCmd.AddCommand(privilegesCmd)
+
+	canSudoCmd := &cobra.Command{
+		Use:   "can-sudo USER_NAME",
+		Short: gotext.Get("Report whether the privilege policy grants sudo to a user, and which rule decides it"),
+		Args:  cobra.ExactArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+
+			return a.users(false), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(_ *cobra.Command, args []string) error { return a.canSudo(args[0]) },
+	}
+	policyCmd.AddCommand(canSudoCmd)
+
+	var verifyFix *bool
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: gotext.Get("Check that the files managed by the machine policy were not modified since they were written"),
+		Args:  cobra.NoArgs,
+		RunE:  func(_ *cobra.Command, _ []string) error { return a.verifyPolicy(*verifyFix) },
+	}
+	verifyFix = verifyCmd.Flags().BoolP("fix", "", false, gotext.Get("apply the machine policy again if any file was modified."))
+	policyCmd.AddCommand(verifyCmd)
+
+	effectiveCmd := &cobra.Command{
+		Use:   "effective",
+		Short: gotext.Get("Print the policy in effect on the machine as a YAML document"),
+		Args:  cobra.NoArgs,
+		RunE:  func(_ *cobra.Command, _ []string) error { return a.effectivePolicy() },
+	}
+	policyCmd.AddCommand(effectiveCmd)
+
+	var simulateComputer, simulateOU *string
+	simulateCmd := &cobra.Command{
+		Use:   "simulate",
+		Short: gotext.Get("Print the policy a computer would get in another organizational unit as a YAML document"),
+		Args:  cobra.NoArgs,
+		RunE:  func(_ *cobra.Command, _ []string) error { return a.simulatePolicy(*simulateComputer, *simulateOU) },
+	}
+	simulateComputer = simulateCmd.Flags().StringP("computer", "", "", gotext.Get("name of the computer in AD. Defaults to the current machine."))
+	simulateOU = simulateCmd.Flags().StringP("ou", "", "", gotext.Get("distinguished name of the organizational unit the computer would be placed in."))
+	policyCmd.AddCommand(simulateCmd)
+
 	a.rootCmd.AddCommand(policyCmd)
 }
 
@@ -319,6 +509,27 @@ func (a *App) dumpCertEnrollScript() error {
 	return os.WriteFile("cert-autoenroll", []byte(script), 0600)
 }
 
+// exportKrb5ContextInitFailure exports the failures to initialize the krb5 context if err is one and an OTLP
+// endpoint is configured.
+func (a *App) exportKrb5ContextInitFailure(err error) {
+	var initErr *ad.Krb5ContextInitError
+	if !errors.As(err, &initErr) || a.config.OTLPEndpoint == "" {
+		return
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Warningf(a.ctx, "Can't export the krb5 context initialization failure: %v", err)
+		return
+	}
+	exporter, err := telemetry.New(a.config.OTLPEndpoint, hostname, telemetry.WithServiceName("adsysctl"))
+	if err != nil {
+		log.Warningf(a.ctx, "Can't export the krb5 context initialization failure: %v", err)
+		return
+	}
+	ad.ExportKrb5ContextInitFailures(a.ctx, exporter)
+}
+
 // printTicketPath prints the path to the Kerberos ccache of the given (or current) user to stdout.
 // The function is a no-op if the detect_cached_ticket setting is not enabled.
 // No error is raised if the inferred ticket is not present on disk.
@@ -356,7 +567,8 @@ func (a *App) printTicketPath(username string) (err error) {
 		return fmt.Errorf(gotext.Get("failed to set privileges to UID %d: %v", uid, err))
 	}
 
-	krb5ccPath, err := ad.TicketPath()
+	krb5ccPath, err := ad.TicketPath(ad.WithCCacheName(a.config.CCacheName))
+	a.exportKrb5ContextInitFailure(err)
 	if errors.Is(err, ad.ErrTicketNotPresent) {
 		log.Debugf(a.ctx, "No ticket found for user %s: %s", username, err)
 		return nil
@@ -459,7 +671,7 @@ func (s *stringsBuilderWithError) Println(l string) {
 	_, s.err = s.Builder.WriteString(l)
 }
 
-func (a *App) update(isComputer, updateAll bool, target, krb5cc string) error {
+func (a *App) update(isComputer, updateAll bool, target, krb5cc, tracePath string) error {
 	// incompatible options
 	if updateAll && (isComputer || target != "" || krb5cc != "") {
 		return errors.New(gotext.Get("machine or user arguments cannot be used with update all"))
@@ -493,10 +705,15 @@ func (a *App) update(isComputer, updateAll bool, target, krb5cc string) error {
 		target = u.Username
 		krb5cc = strings.TrimPrefix(os.Getenv("KRB5CCNAME"), "FILE:")
 		if krb5cc == "" && a.config.DetectCachedTicket {
-			krb5cc, err = ad.TicketPath()
+			krb5cc, err = ad.TicketPath(ad.WithCCacheName(a.config.CCacheName))
+			a.exportKrb5ContextInitFailure(err)
 			// Don't return an error as we might still have a cached ticket
 			// under /run/adsys/krb5cc
-			if err != nil {
+			var initErr *ad.Krb5ContextInitError
+			if errors.As(err, &initErr) {
+				// No Kerberos operation can succeed on the machine: make it stand out.
+				log.Error(a.ctx, err)
+			} else if err != nil {
 				log.Warningf(a.ctx, "Failed to get ticket path: %v", err)
 			}
 		}
@@ -506,7 +723,124 @@ func (a *App) update(isComputer, updateAll bool, target, krb5cc string) error {
 		IsComputer: isComputer,
 		All:        updateAll,
 		Target:     target,
-		Krb5Cc:     krb5cc})
+		Krb5Cc:     krb5cc,
+		Trace:      tracePath != ""})
+	if err != nil {
+		return err
+	}
+
+	if _, err = stream.Recv(); errors.Is(err, io.EOF) {
+		err = nil
+	}
+
+	// The trace bundle is exported even if the update failed, as it records the failure.
+	if tracePath != "" {
+		if errExport := a.exportTrace(tracePath); errExport != nil {
+			return errors.Join(err, errExport)
+		}
+	}
+
+	return err
+}
+
+// exportTrace writes the archive of the most recent trace bundle of the daemon to path.
+func (a *App) exportTrace(path string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't export trace bundle to %s", path))
+
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.TraceExport(a.ctx, &adsys.Empty{})
+	if err != nil {
+		return err
+	}
+
+	return writeArchive(path, stream)
+}
+
+// exportPolicies writes the archive of the daemon GPOs and policies cache to path.
+func (a *App) exportPolicies(path string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't export policies to %s", path))
+
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.PolicyExport(a.ctx, &adsys.Empty{})
+	if err != nil {
+		return err
+	}
+
+	return writeArchive(path, stream)
+}
+
+// archiveStream is a stream of archive chunks sent by the daemon.
+type archiveStream interface {
+	Recv() (*adsys.PolicyExportResponse, error)
+}
+
+// writeArchive writes to path the archive received from stream.
+func writeArchive(path string, stream archiveStream) (err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// Don't leave a truncated archive behind
+	defer func() {
+		if err != nil {
+			_ = os.Remove(path)
+		}
+	}()
+
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(r.GetData()); err != nil {
+			return err
+		}
+	}
+
+	return f.Close()
+}
+
+// importPolicies imports the archive at path in the daemon, which then replays it, or leaves replay mode.
+func (a *App) importPolicies(path string, exitReplay bool) (err error) {
+	// incompatible options
+	if exitReplay && path != "" {
+		return errors.New(gotext.Get("an archive cannot be imported when leaving replay mode"))
+	}
+	if !exitReplay && path == "" {
+		return errors.New(gotext.Get("an archive to import is required"))
+	}
+
+	// The archive is read by the daemon, which doesn't share our working directory.
+	if path != "" {
+		if path, err = filepath.Abs(path); err != nil {
+			return err
+		}
+	}
+
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.PolicyImport(a.ctx, &adsys.PolicyImportRequest{
+		Path:       path,
+		ExitReplay: exitReplay,
+	})
 	if err != nil {
 		return err
 	}
@@ -518,6 +852,607 @@ func (a *App) update(isComputer, updateAll bool, target, krb5cc string) error {
 	return nil
 }
 
+// mountStatus prints whether each system share set by the mount policy is mounted.
+func (a *App) mountStatus() error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.MountStatus(a.ctx, &adsys.Empty{})
+	if err != nil {
+		return err
+	}
+
+	var shares []*adsys.MountStatusResponse
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		shares = append(shares, r)
+	}
+
+	fmt.Print(formatMountStatus(shares))
+	return nil
+}
+
+// formatMountStatus returns a human readable status of the system shares.
+func formatMountStatus(shares []*adsys.MountStatusResponse) string {
+	if len(shares) == 0 {
+		return gotext.Get("No system share is set by the mount policy\n")
+	}
+
+	var out strings.Builder
+	for _, s := range shares {
+		state := s.GetState()
+		if s.GetError() != "" {
+			state = fmt.Sprintf("%s (%s)", state, s.GetError())
+		}
+		out.WriteString(gotext.Get("%s on %s type %s: %s\n", s.GetShare(), s.GetTarget(), s.GetType(), state))
+	}
+	return out.String()
+}
+
+// effectivePolicy prints the YAML document of the policy in effect on the machine.
+func (a *App) effectivePolicy() error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.EffectivePolicy(a.ctx, &adsys.Empty{})
+	if err != nil {
+		return err
+	}
+
+	doc, err := singleMsg(stream)
+	if err != nil {
+		return err
+	}
+	fmt.Print(doc)
+
+	return nil
+}
+
+// simulatePolicy prints the YAML document of the policy computer would get once placed in the organizational
+// unit ou.
+func (a *App) simulatePolicy(computer, ou string) error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.SimulatePolicy(a.ctx, &adsys.SimulatePolicyRequest{
+		Computer: computer,
+		Ou:       ou,
+	})
+	if err != nil {
+		return err
+	}
+
+	doc, err := singleMsg(stream)
+	if err != nil {
+		return err
+	}
+	fmt.Print(doc)
+
+	return nil
+}
+
+// privilegeFiles prints the sudoers and polkit files managed by the privilege policy, with their content if raw
+// is set.
+func (a *App) privilegeFiles(raw bool) error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.PrivilegeFiles(a.ctx, &adsys.Empty{})
+	if err != nil {
+		return err
+	}
+
+	var files []*adsys.PrivilegeFileResponse
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		files = append(files, r)
+	}
+
+	fmt.Print(formatPrivilegeFiles(files, raw))
+	return nil
+}
+
+// systemAdminIdentities prints all the system polkit configuration files setting administrators, and the one
+// preserved by the privilege policy.
+func (a *App) systemAdminIdentities() error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.SystemAdminIdentities(a.ctx, &adsys.Empty{})
+	if err != nil {
+		return err
+	}
+
+	var files []*adsys.SystemAdminIdentitiesResponse
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		files = append(files, r)
+	}
+
+	fmt.Print(formatSystemAdminIdentities(files))
+	return nil
+}
+
+// canSudo prints whether the privilege policy grants sudo to user, and which rule decides it.
+func (a *App) canSudo(user string) error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.SudoAccess(a.ctx, &adsys.SudoAccessRequest{User: user})
+	if err != nil {
+		return err
+	}
+
+	r, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(formatSudoAccess(user, r))
+	return nil
+}
+
+// formatSudoAccess returns whether the privilege policy grants sudo to user, with the deciding rule and the polkit
+// administrator identity matching the user.
+func formatSudoAccess(user string, r *adsys.SudoAccessResponse) string {
+	var out strings.Builder
+	switch {
+	case !r.GetMatched():
+		out.WriteString(gotext.Get("%s: no ADSys rule applies, the local sudo configuration decides\n", user))
+	case r.GetGranted():
+		out.WriteString(gotext.Get("%s: sudo granted as %s by %s:%d\n", user, r.GetIdentity(), r.GetPath(), r.GetLine()))
+		out.WriteString(fmt.Sprintf("  %s\n", r.GetRule()))
+	default:
+		out.WriteString(gotext.Get("%s: sudo denied as %s by %s:%d\n", user, r.GetIdentity(), r.GetPath(), r.GetLine()))
+		out.WriteString(fmt.Sprintf("  %s\n", r.GetRule()))
+	}
+
+	if r.GetPolkitIdentity() != "" {
+		out.WriteString(gotext.Get("%s: polkit administrator as %s\n", user, r.GetPolkitIdentity()))
+	}
+	return out.String()
+}
+
+// formatSystemAdminIdentities returns the administrators set by each system polkit configuration file, marking the
+// one preserved by the privilege policy.
+func formatSystemAdminIdentities(files []*adsys.SystemAdminIdentitiesResponse) string {
+	if len(files) == 0 {
+		return gotext.Get("No system polkit configuration file sets administrators\n")
+	}
+
+	var out strings.Builder
+	for _, f := range files {
+		if f.GetPreserved() {
+			out.WriteString(gotext.Get("%s: %s (preserved)\n", f.GetPath(), f.GetValue()))
+			continue
+		}
+		out.WriteString(gotext.Get("%s: %s (ignored)\n", f.GetPath(), f.GetValue()))
+	}
+	return out.String()
+}
+
+// formatPrivilegeFiles returns whether each privilege file is present, followed by its content if raw is set.
+func formatPrivilegeFiles(files []*adsys.PrivilegeFileResponse, raw bool) string {
+	var out strings.Builder
+	for _, f := range files {
+		if !f.GetExists() {
+			out.WriteString(gotext.Get("%s: absent\n", f.GetPath()))
+			continue
+		}
+		if !raw {
+			out.WriteString(gotext.Get("%s: present\n", f.GetPath()))
+			continue
+		}
+		out.WriteString(fmt.Sprintf("==> %s <==\n", f.GetPath()))
+		out.WriteString(f.GetContent())
+		if !strings.HasSuffix(f.GetContent(), "\n") {
+			out.WriteString("\n")
+		}
+	}
+	return out.String()
+}
+
+// policyStatus prints the entries ignored during the last policy refresh of the target, and the policies whose
+// changes need a relogin or reboot to take full effect.
+func (a *App) policyStatus(target string, isMachine, showIgnored bool) error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Status for current user
+	if target == "" {
+		if isMachine {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return fmt.Errorf("failed to retrieve client hostname: %w", err)
+			}
+			target = hostname
+		} else {
+			u, err := user.Current()
+			if err != nil {
+				return fmt.Errorf("failed to retrieve current user: %w", err)
+			}
+			target = u.Username
+		}
+	}
+
+	stream, err := client.IgnoredEntries(a.ctx, &adsys.IgnoredEntriesRequest{
+		Target:     target,
+		IsComputer: isMachine,
+	})
+	if err != nil {
+		return err
+	}
+
+	var entries []*adsys.IgnoredEntryResponse
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		entries = append(entries, r)
+	}
+
+	fmt.Print(formatIgnoredEntries(entries, showIgnored))
+
+	hintsStream, err := client.RestartHints(a.ctx, &adsys.RestartHintsRequest{
+		Target:     target,
+		IsComputer: isMachine,
+	})
+	if err != nil {
+		return err
+	}
+
+	var hints []*adsys.RestartHintResponse
+	for {
+		r, err := hintsStream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		hints = append(hints, r)
+	}
+
+	fmt.Print(formatRestartHints(hints))
+	return nil
+}
+
+// formatRestartHints returns the policies whose changes need a relogin or reboot to take full effect, one per line
+// prefixed by what needs to be restarted.
+func formatRestartHints(hints []*adsys.RestartHintResponse) string {
+	if len(hints) == 0 {
+		return gotext.Get("No policy change of the last refresh needs a relogin or reboot\n")
+	}
+
+	var out strings.Builder
+	for _, h := range hints {
+		hint := fmt.Sprintf("%s: %s", h.GetKind(), h.GetRule())
+		if h.GetDetails() != "" {
+			hint = fmt.Sprintf("%s (%s)", hint, h.GetDetails())
+		}
+		out.WriteString(hint + "\n")
+	}
+	return out.String()
+}
+
+// formatIgnoredEntries returns a human readable list of the ignored entries, or only their count if showIgnored
+// is false.
+func formatIgnoredEntries(entries []*adsys.IgnoredEntryResponse, showIgnored bool) string {
+	if len(entries) == 0 {
+		return gotext.Get("No policy entry was ignored during the last refresh\n")
+	}
+	if !showIgnored {
+		return gotext.Get("%d policy entries were ignored during the last refresh. Use --ignored to list them.\n", len(entries))
+	}
+
+	var out strings.Builder
+	for _, e := range entries {
+		entry := fmt.Sprintf("%s/%s", e.GetRule(), e.GetKey())
+		if e.GetValue() != "" {
+			entry = fmt.Sprintf("%s: %q", entry, e.GetValue())
+		}
+		reason := e.GetReason()
+		if e.GetDetails() != "" {
+			reason = fmt.Sprintf("%s (%s)", reason, e.GetDetails())
+		}
+		out.WriteString(gotext.Get("%s: %s\n", entry, reason))
+	}
+	return out.String()
+}
+
+func (a *App) policyDrift(target string, isMachine bool) error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Drift for current user
+	if target == "" {
+		if isMachine {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return fmt.Errorf("failed to retrieve client hostname: %w", err)
+			}
+			target = hostname
+		} else {
+			u, err := user.Current()
+			if err != nil {
+				return fmt.Errorf("failed to retrieve current user: %w", err)
+			}
+			target = u.Username
+		}
+	}
+
+	stream, err := client.PolicyDrift(a.ctx, &adsys.PolicyDriftRequest{
+		Target:     target,
+		IsComputer: isMachine,
+	})
+	if err != nil {
+		return err
+	}
+
+	var drifts []*adsys.GPODriftResponse
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		drifts = append(drifts, r)
+	}
+
+	fmt.Print(formatDrift(drifts))
+	return nil
+}
+
+// formatDrift returns a human readable comparison of the cached and current version of each GPO.
+func formatDrift(drifts []*adsys.GPODriftResponse) string {
+	if len(drifts) == 0 {
+		return gotext.Get("No GPO applies\n")
+	}
+
+	var out strings.Builder
+	for _, d := range drifts {
+		gpo := fmt.Sprintf("%s (%s)", d.GetName(), d.GetId())
+		switch {
+		case !d.GetCached():
+			out.WriteString(gotext.Get("%s: not cached (current version %d)\n", gpo, d.GetCurrentVersion()))
+		case d.GetCachedVersion() < d.GetCurrentVersion():
+			out.WriteString(gotext.Get("%s: drifted (cached version %d, current version %d)\n", gpo, d.GetCachedVersion(), d.GetCurrentVersion()))
+		default:
+			out.WriteString(gotext.Get("%s: up to date (version %d)\n", gpo, d.GetCurrentVersion()))
+		}
+	}
+	return out.String()
+}
+
+// policyFetchDryRun prints the GPOs which would be fetched for the target, with their version and size, and if
+// they would be downloaded.
+func (a *App) policyFetchDryRun(target string, isMachine bool) error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// GPOs for current user
+	if target == "" {
+		if isMachine {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return fmt.Errorf("failed to retrieve client hostname: %w", err)
+			}
+			target = hostname
+		} else {
+			u, err := user.Current()
+			if err != nil {
+				return fmt.Errorf("failed to retrieve current user: %w", err)
+			}
+			target = u.Username
+		}
+	}
+
+	stream, err := client.PolicyFetchDryRun(a.ctx, &adsys.PolicyFetchRequest{
+		Target:     target,
+		IsComputer: isMachine,
+	})
+	if err != nil {
+		return err
+	}
+
+	var gpos []*adsys.GPOFetchResponse
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		gpos = append(gpos, r)
+	}
+
+	fmt.Print(formatFetch(gpos))
+	return nil
+}
+
+// formatFetch returns one line per GPO, in order, with the total size to download.
+func formatFetch(gpos []*adsys.GPOFetchResponse) string {
+	if len(gpos) == 0 {
+		return gotext.Get("No GPO applies\n")
+	}
+
+	var out strings.Builder
+	var n, size int64
+	for _, g := range gpos {
+		gpo := fmt.Sprintf("%s (%s)", g.GetName(), g.GetId())
+		if !g.GetDownload() {
+			out.WriteString(gotext.Get("%s: up to date (version %d, %d bytes)\n", gpo, g.GetVersion(), g.GetSize()))
+			continue
+		}
+		out.WriteString(gotext.Get("%s: would be downloaded (version %d, %d bytes)\n", gpo, g.GetVersion(), g.GetSize()))
+		n++
+		size += g.GetSize()
+	}
+	out.WriteString(gotext.Get("%d GPO(s) to download, %d bytes\n", n, size))
+	return out.String()
+}
+
+// verifyPolicy prints whether each file managed by the machine policy drifted from what adsys would write,
+// applying the machine policy again if fix is set and any file drifted.
+func (a *App) verifyPolicy(fix bool) error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.VerifyPolicy(a.ctx, &adsys.VerifyPolicyRequest{Fix: fix})
+	if err != nil {
+		return err
+	}
+
+	var files []*adsys.ManagedFileResponse
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		files = append(files, r)
+	}
+
+	fmt.Print(formatVerify(files))
+	return nil
+}
+
+// formatVerify returns a human readable status of each managed file, compared to what the policy would write.
+func formatVerify(files []*adsys.ManagedFileResponse) string {
+	if len(files) == 0 {
+		return gotext.Get("No managed file to verify\n")
+	}
+
+	var out strings.Builder
+	for _, f := range files {
+		var status string
+		switch {
+		case !f.GetDrifted() && f.GetExists():
+			status = gotext.Get("up to date")
+		case !f.GetDrifted():
+			status = gotext.Get("absent, as expected")
+		case !f.GetExists():
+			status = gotext.Get("missing")
+		case !f.GetExpected():
+			status = gotext.Get("unexpected")
+		default:
+			status = gotext.Get("modified")
+		}
+		if f.GetFixed() {
+			status = gotext.Get("%s (fixed)", status)
+		}
+		out.WriteString(fmt.Sprintf("%s (%s): %s\n", f.GetPath(), f.GetRule(), status))
+	}
+	return out.String()
+}
+
+// purgeStaleFiles prints the files managed by the policy types no applied policy uses anymore, restricted to types
+// if set, removing them if apply is set.
+func (a *App) purgeStaleFiles(types []string, apply bool) error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.PolicyPurge(a.ctx, &adsys.PolicyPurgeRequest{Types: types, Apply: apply})
+	if err != nil {
+		return err
+	}
+
+	var files []*adsys.StaleFileResponse
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		files = append(files, r)
+	}
+
+	fmt.Print(formatStaleFiles(files, apply))
+	return nil
+}
+
+// formatStaleFiles returns a human readable list of the stale managed files, and if they were removed.
+func formatStaleFiles(files []*adsys.StaleFileResponse, apply bool) string {
+	if len(files) == 0 {
+		return gotext.Get("No stale managed file\n")
+	}
+
+	var out strings.Builder
+	for _, f := range files {
+		status := gotext.Get("would be removed")
+		if f.GetRemoved() {
+			status = gotext.Get("removed")
+		}
+		out.WriteString(fmt.Sprintf("%s (%s): %s\n", f.GetPath(), f.GetRule(), status))
+	}
+	if !apply {
+		out.WriteString(gotext.Get("Run again with --apply to remove them.\n"))
+	}
+	return out.String()
+}
+
 func (a *App) purge(isComputer, purgeAll bool, target string) error {
 	// incompatible options
 	if purgeAll && target != "" {
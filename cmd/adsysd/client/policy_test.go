@@ -6,6 +6,7 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys"
 	"github.com/ubuntu/adsys/internal/testutils"
 )
 
@@ -51,3 +52,347 @@ Policies from user configuration:
 	want := testutils.LoadWithUpdateFromGolden(t, got)
 	require.Equal(t, want, got, "colorizePolicies returned expected formatted output")
 }
+
+func TestFormatMountStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		shares []*adsys.MountStatusResponse
+
+		want string
+	}{
+		"No share": {want: "No system share is set by the mount policy\n"},
+		"Mounted and unmounted shares": {
+			shares: []*adsys.MountStatusResponse{
+				{Share: "//example.com/share", Target: "/adsys/cifs/example.com/share", Type: "cifs", State: "mounted"},
+				{Share: "example.com:/export", Target: "/adsys/nfs/example.com/export", Type: "nfs", State: "unmounted"},
+			},
+			want: "//example.com/share on /adsys/cifs/example.com/share type cifs: mounted\n" +
+				"example.com:/export on /adsys/nfs/example.com/export type nfs: unmounted\n"},
+		"Failed share with its error": {
+			shares: []*adsys.MountStatusResponse{
+				{Share: "//example.com/share", Target: "/adsys/cifs/example.com/share", Type: "cifs", State: "failed", Error: "mount error(13): Permission denied"},
+			},
+			want: "//example.com/share on /adsys/cifs/example.com/share type cifs: failed (mount error(13): Permission denied)\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := formatMountStatus(tc.shares)
+			require.Equal(t, tc.want, got, "formatMountStatus should return the expected status")
+		})
+	}
+}
+
+func TestFormatIgnoredEntries(t *testing.T) {
+	t.Parallel()
+
+	entries := []*adsys.IgnoredEntryResponse{
+		{Rule: "privilege", Key: "client-admins", Value: "::?", Reason: "invalid-value", Details: "only made of invalid characters"},
+		{Rule: "proxy", Key: "proxy/foo", Reason: "unsupported-key"},
+	}
+
+	tests := map[string]struct {
+		entries     []*adsys.IgnoredEntryResponse
+		showIgnored bool
+
+		want string
+	}{
+		"No ignored entry":              {want: "No policy entry was ignored during the last refresh\n"},
+		"No ignored entry when listing": {showIgnored: true, want: "No policy entry was ignored during the last refresh\n"},
+		"Count of ignored entries": {
+			entries: entries,
+			want:    "2 policy entries were ignored during the last refresh. Use --ignored to list them.\n"},
+		"List ignored entries with their reason": {
+			entries:     entries,
+			showIgnored: true,
+			want: "privilege/client-admins: \"::?\": invalid-value (only made of invalid characters)\n" +
+				"proxy/proxy/foo: unsupported-key\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := formatIgnoredEntries(tc.entries, tc.showIgnored)
+			require.Equal(t, tc.want, got, "formatIgnoredEntries should return the expected output")
+		})
+	}
+}
+
+func TestFormatRestartHints(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		hints []*adsys.RestartHintResponse
+
+		want string
+	}{
+		"No restart hint": {want: "No policy change of the last refresh needs a relogin or reboot\n"},
+		"List restart hints with their details": {
+			hints: []*adsys.RestartHintResponse{
+				{Rule: "environment", Kind: "relogin", Details: "environment variables changed"},
+				{Rule: "proxy", Kind: "relogin"},
+			},
+			want: "relogin: environment (environment variables changed)\n" +
+				"relogin: proxy\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := formatRestartHints(tc.hints)
+			require.Equal(t, tc.want, got, "formatRestartHints should return the expected output")
+		})
+	}
+}
+
+func TestFormatDrift(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		drifts []*adsys.GPODriftResponse
+
+		want string
+	}{
+		"No GPO": {want: "No GPO applies\n"},
+		"Up to date GPO": {
+			drifts: []*adsys.GPODriftResponse{{Name: "GPO1", Id: "{GPOId1}", Cached: true, CachedVersion: 3, CurrentVersion: 3}},
+			want:   "GPO1 ({GPOId1}): up to date (version 3)\n"},
+		"Drifted GPO": {
+			drifts: []*adsys.GPODriftResponse{{Name: "GPO1", Id: "{GPOId1}", Cached: true, CachedVersion: 3, CurrentVersion: 5}},
+			want:   "GPO1 ({GPOId1}): drifted (cached version 3, current version 5)\n"},
+		"Not cached GPO": {
+			drifts: []*adsys.GPODriftResponse{{Name: "GPO1", Id: "{GPOId1}", CurrentVersion: 5}},
+			want:   "GPO1 ({GPOId1}): not cached (current version 5)\n"},
+		"Multiple GPOs in order": {
+			drifts: []*adsys.GPODriftResponse{
+				{Name: "GPO1", Id: "{GPOId1}", Cached: true, CachedVersion: 3, CurrentVersion: 5},
+				{Name: "GPO2", Id: "{GPOId2}", Cached: true, CachedVersion: 2, CurrentVersion: 2},
+				{Name: "GPO3", Id: "{GPOId3}", CurrentVersion: 1},
+			},
+			want: "GPO1 ({GPOId1}): drifted (cached version 3, current version 5)\n" +
+				"GPO2 ({GPOId2}): up to date (version 2)\n" +
+				"GPO3 ({GPOId3}): not cached (current version 1)\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := formatDrift(tc.drifts)
+			require.Equal(t, tc.want, got, "formatDrift should return the expected output")
+		})
+	}
+}
+
+func TestFormatFetch(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		gpos []*adsys.GPOFetchResponse
+
+		want string
+	}{
+		"No GPO": {want: "No GPO applies\n"},
+		"Up to date GPO": {
+			gpos: []*adsys.GPOFetchResponse{{Name: "GPO1", Id: "{GPOId1}", Version: 3, Size: 1024}},
+			want: "GPO1 ({GPOId1}): up to date (version 3, 1024 bytes)\n" +
+				"0 GPO(s) to download, 0 bytes\n"},
+		"GPO to download": {
+			gpos: []*adsys.GPOFetchResponse{{Name: "GPO1", Id: "{GPOId1}", Version: 5, Size: 1024, Download: true}},
+			want: "GPO1 ({GPOId1}): would be downloaded (version 5, 1024 bytes)\n" +
+				"1 GPO(s) to download, 1024 bytes\n"},
+		"Multiple GPOs in order": {
+			gpos: []*adsys.GPOFetchResponse{
+				{Name: "GPO1", Id: "{GPOId1}", Version: 5, Size: 1024, Download: true},
+				{Name: "GPO2", Id: "{GPOId2}", Version: 2, Size: 512},
+				{Name: "GPO3", Id: "{GPOId3}", Version: 1, Size: 2048, Download: true},
+			},
+			want: "GPO1 ({GPOId1}): would be downloaded (version 5, 1024 bytes)\n" +
+				"GPO2 ({GPOId2}): up to date (version 2, 512 bytes)\n" +
+				"GPO3 ({GPOId3}): would be downloaded (version 1, 2048 bytes)\n" +
+				"2 GPO(s) to download, 3072 bytes\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := formatFetch(tc.gpos)
+			require.Equal(t, tc.want, got, "formatFetch should return the expected output")
+		})
+	}
+}
+
+func TestFormatPrivilegeFiles(t *testing.T) {
+	t.Parallel()
+
+	files := []*adsys.PrivilegeFileResponse{
+		{Path: "/etc/sudoers.d/99-adsys-privilege-enforcement", Content: "# This file is managed by adsys.\n\"alice@domain.com\"	ALL=(ALL:ALL) ALL\n", Exists: true},
+		{Path: "/etc/polkit-1/localauthority.conf.d/99-adsys-privilege-enforcement.conf", Content: "[Configuration]\nAdminIdentities=unix-user:alice@domain.com", Exists: true},
+		{Path: "/etc/polkit-1/rules.d/10-adsys-privilege-enforcement.rules"},
+	}
+
+	tests := map[string]struct {
+		raw bool
+
+		want string
+	}{
+		"Files presence": {
+			want: "/etc/sudoers.d/99-adsys-privilege-enforcement: present\n" +
+				"/etc/polkit-1/localauthority.conf.d/99-adsys-privilege-enforcement.conf: present\n" +
+				"/etc/polkit-1/rules.d/10-adsys-privilege-enforcement.rules: absent\n"},
+		"Raw files content": {
+			raw: true,
+			want: "==> /etc/sudoers.d/99-adsys-privilege-enforcement <==\n" +
+				"# This file is managed by adsys.\n\"alice@domain.com\"	ALL=(ALL:ALL) ALL\n" +
+				"==> /etc/polkit-1/localauthority.conf.d/99-adsys-privilege-enforcement.conf <==\n" +
+				"[Configuration]\nAdminIdentities=unix-user:alice@domain.com\n" +
+				"/etc/polkit-1/rules.d/10-adsys-privilege-enforcement.rules: absent\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := formatPrivilegeFiles(files, tc.raw)
+			require.Equal(t, tc.want, got, "formatPrivilegeFiles should return the expected output")
+		})
+	}
+}
+
+func TestFormatSudoAccess(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		access *adsys.SudoAccessResponse
+
+		want string
+	}{
+		"Granted to the user": {
+			access: &adsys.SudoAccessResponse{Matched: true, Granted: true, Path: "/etc/sudoers.d/99-adsys-privilege-enforcement", Line: 2,
+				Rule: `"alice@domain.com" ALL=(ALL:ALL) ALL`, Identity: "alice@domain.com", PolkitIdentity: "unix-user:alice@domain.com"},
+			want: "alice@domain.com: sudo granted as alice@domain.com by /etc/sudoers.d/99-adsys-privilege-enforcement:2\n" +
+				"  \"alice@domain.com\" ALL=(ALL:ALL) ALL\n" +
+				"alice@domain.com: polkit administrator as unix-user:alice@domain.com\n"},
+		"Granted to a group of the user": {
+			access: &adsys.SudoAccessResponse{Matched: true, Granted: true, Path: "/etc/sudoers.d/99-adsys-privilege-enforcement", Line: 3,
+				Rule: `"%admins@domain.com" ALL=(ALL:ALL) ALL`, Identity: "%admins@domain.com"},
+			want: "alice@domain.com: sudo granted as %admins@domain.com by /etc/sudoers.d/99-adsys-privilege-enforcement:3\n" +
+				"  \"%admins@domain.com\" ALL=(ALL:ALL) ALL\n"},
+		"Denied to the user": {
+			access: &adsys.SudoAccessResponse{Matched: true, Path: "/etc/sudoers.d/99-adsys-privilege-enforcement", Line: 4,
+				Rule: `"alice@domain.com" ALL=(ALL:ALL) !ALL`, Identity: "alice@domain.com"},
+			want: "alice@domain.com: sudo denied as alice@domain.com by /etc/sudoers.d/99-adsys-privilege-enforcement:4\n" +
+				"  \"alice@domain.com\" ALL=(ALL:ALL) !ALL\n"},
+		"No rule applies": {
+			access: &adsys.SudoAccessResponse{},
+			want:   "alice@domain.com: no ADSys rule applies, the local sudo configuration decides\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := formatSudoAccess("alice@domain.com", tc.access)
+			require.Equal(t, tc.want, got, "formatSudoAccess should return the expected output")
+		})
+	}
+}
+
+func TestFormatSystemAdminIdentities(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		files []*adsys.SystemAdminIdentitiesResponse
+
+		want string
+	}{
+		"All files setting administrators": {
+			files: []*adsys.SystemAdminIdentitiesResponse{
+				{Path: "/etc/polkit-1/localauthority.conf.d/10-tool-a.conf", Value: "unix-group:toola"},
+				{Path: "/etc/polkit-1/localauthority.conf.d/50-tool-b.conf", Value: "unix-user:toolb", Preserved: true},
+			},
+			want: "/etc/polkit-1/localauthority.conf.d/10-tool-a.conf: unix-group:toola (ignored)\n" +
+				"/etc/polkit-1/localauthority.conf.d/50-tool-b.conf: unix-user:toolb (preserved)\n"},
+		"No file preserved": {
+			files: []*adsys.SystemAdminIdentitiesResponse{
+				{Path: "/etc/polkit-1/localauthority.conf.d/10-tool-a.conf", Value: "unix-group:toola"},
+			},
+			want: "/etc/polkit-1/localauthority.conf.d/10-tool-a.conf: unix-group:toola (ignored)\n"},
+		"No files": {want: "No system polkit configuration file sets administrators\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := formatSystemAdminIdentities(tc.files)
+			require.Equal(t, tc.want, got, "formatSystemAdminIdentities should return the expected output")
+		})
+	}
+}
+
+func TestFormatVerify(t *testing.T) {
+	t.Parallel()
+
+	sudoers := "/etc/sudoers.d/99-adsys-privilege-enforcement"
+
+	tests := map[string]struct {
+		files []*adsys.ManagedFileResponse
+
+		want string
+	}{
+		"Up to date file":         {files: []*adsys.ManagedFileResponse{{Rule: "privilege", Path: sudoers, Exists: true, Expected: true}}, want: sudoers + " (privilege): up to date\n"},
+		"Absent file as expected": {files: []*adsys.ManagedFileResponse{{Rule: "privilege", Path: sudoers}}, want: sudoers + " (privilege): absent, as expected\n"},
+		"Modified file":           {files: []*adsys.ManagedFileResponse{{Rule: "privilege", Path: sudoers, Exists: true, Expected: true, Drifted: true}}, want: sudoers + " (privilege): modified\n"},
+		"Missing file":            {files: []*adsys.ManagedFileResponse{{Rule: "privilege", Path: sudoers, Expected: true, Drifted: true}}, want: sudoers + " (privilege): missing\n"},
+		"Unexpected file":         {files: []*adsys.ManagedFileResponse{{Rule: "privilege", Path: sudoers, Exists: true, Drifted: true}}, want: sudoers + " (privilege): unexpected\n"},
+		"Fixed file":              {files: []*adsys.ManagedFileResponse{{Rule: "privilege", Path: sudoers, Exists: true, Expected: true, Drifted: true, Fixed: true}}, want: sudoers + " (privilege): modified (fixed)\n"},
+		"Multiple files": {
+			files: []*adsys.ManagedFileResponse{
+				{Rule: "privilege", Path: sudoers, Exists: true, Expected: true},
+				{Rule: "proxy", Path: "/home/alice/.wine/user.reg", Exists: true, Expected: true, Drifted: true},
+			},
+			want: sudoers + " (privilege): up to date\n/home/alice/.wine/user.reg (proxy): modified\n"},
+
+		"No managed file": {want: "No managed file to verify\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := formatVerify(tc.files)
+			require.Equal(t, tc.want, got, "formatVerify should return the expected output")
+		})
+	}
+}
+
+func TestFormatStaleFiles(t *testing.T) {
+	t.Parallel()
+
+	mount := "/etc/systemd/system/adsys-home-share.mount"
+	sysctl := "/etc/sysctl.d/99-adsys.conf"
+
+	tests := map[string]struct {
+		files []*adsys.StaleFileResponse
+		apply bool
+
+		want string
+	}{
+		"Listed files": {
+			files: []*adsys.StaleFileResponse{{Rule: "mount", Path: mount}, {Rule: "sysctl", Path: sysctl}},
+			want:  mount + " (mount): would be removed\n" + sysctl + " (sysctl): would be removed\nRun again with --apply to remove them.\n"},
+		"Removed files": {
+			files: []*adsys.StaleFileResponse{{Rule: "mount", Path: mount, Removed: true}, {Rule: "sysctl", Path: sysctl, Removed: true}},
+			apply: true,
+			want:  mount + " (mount): removed\n" + sysctl + " (sysctl): removed\n"},
+
+		"No stale file":                  {want: "No stale managed file\n"},
+		"No stale file to remove either": {apply: true, want: "No stale managed file\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := formatStaleFiles(tc.files, tc.apply)
+			require.Equal(t, tc.want, got, "formatStaleFiles should return the expected output")
+		})
+	}
+}
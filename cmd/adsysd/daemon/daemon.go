@@ -4,7 +4,7 @@ package daemon
 import (
 	"context"
 	"fmt"
-	"runtime"
+	"reflect"
 	"time"
 
 	"github.com/leonelquinteros/gotext"
@@ -29,8 +29,9 @@ type App struct {
 	rootCmd cobra.Command
 	viper   *viper.Viper
 
-	config daemonConfig
-	daemon *daemon.Daemon
+	config  daemonConfig
+	daemon  *daemon.Daemon
+	service *adsysservice.Service
 
 	ready chan struct{}
 }
@@ -43,7 +44,9 @@ type daemonConfig struct {
 	StateDir string `mapstructure:"state_dir"`
 	RunDir   string `mapstructure:"run_dir"`
 
+	RootDir        string `mapstructure:"root_dir"`
 	DconfDir       string `mapstructure:"dconf_dir"`
+	DconfProfile   string `mapstructure:"dconf_profile"`
 	SudoersDir     string `mapstructure:"sudoers_dir"`
 	PolicyKitDir   string `mapstructure:"policykit_dir"`
 	ApparmorDir    string `mapstructure:"apparmor_dir"`
@@ -51,11 +54,54 @@ type daemonConfig struct {
 	SystemUnitDir  string `mapstructure:"systemunit_dir"`
 	GlobalTrustDir string `mapstructure:"global_trust_dir"`
 
+	DconfGroupProfiles map[string][]string `mapstructure:"dconf_group_profiles"`
+	ExtensionsDir      string              `mapstructure:"extensions_dir"`
+
+	MountSourceAddress  string            `mapstructure:"mount_source_address"`
+	WineProxyPrefixes   map[string]string `mapstructure:"wine_proxy_prefixes"`
+	ProxyCheckURL       string            `mapstructure:"proxy_check_url"`
+	ProxyNoAutoBypass   bool              `mapstructure:"proxy_no_auto_bypass"`
+	ProxyExclusionsFile string            `mapstructure:"proxy_exclusions_file"`
+	DockerProxyUsers    map[string]string `mapstructure:"docker_proxy_users"`
+	DockerProxyDaemon   bool              `mapstructure:"docker_proxy_daemon"`
+	AllowAdminLockout   bool              `mapstructure:"allow_admin_lockout"`
+	PrivilegeReportOnly bool              `mapstructure:"privilege_report_only"`
+	SudoersPerGroup     bool              `mapstructure:"sudoers_per_group"`
+	SudoersAliases      int               `mapstructure:"sudoers_alias_threshold"`
+	SudoersConflicts    bool              `mapstructure:"sudoers_conflicts_check"`
+	PolkitAdminRules    bool              `mapstructure:"polkit_admin_rules"`
+	ProtectedLocalUsers []string          `mapstructure:"protected_local_users"`
+	WriteCooldown       time.Duration     `mapstructure:"write_cooldown"`
+	RefreshTimeout      time.Duration     `mapstructure:"refresh_timeout"`
+	Transactional       bool              `mapstructure:"transactional_refresh"`
+	OTLPEndpoint        string            `mapstructure:"otlp_endpoint"`
+	TamperHandling      map[string]string `mapstructure:"tamper_handling"`
+	UnknownKeys         string            `mapstructure:"unknown_keys"`
+	MachineKrb5CCName   string            `mapstructure:"machine_krb5ccname"`
+
 	AdBackend     string         `mapstructure:"ad_backend"`
 	SSSdConfig    sss.Config     `mapstructure:"sssd"`
 	WinbindConfig winbind.Config `mapstructure:"winbind"`
 
 	ServiceTimeout int `mapstructure:"service_timeout"`
+
+	MaxCacheAge      time.Duration `mapstructure:"max_cache_age"`
+	StaleCacheAction string        `mapstructure:"stale_cache_action"`
+
+	SMBMinProtocol string `mapstructure:"smb_min_protocol"`
+	SMBMaxProtocol string `mapstructure:"smb_max_protocol"`
+
+	ComputerAttributes []string `mapstructure:"computer_attributes"`
+
+	TicketRenewalMargin     time.Duration `mapstructure:"ticket_renewal_margin"`
+	TicketRenewalMaxBackoff time.Duration `mapstructure:"ticket_renewal_max_backoff"`
+
+	DCFailureThreshold int           `mapstructure:"dc_failure_threshold"`
+	DCDownCooldown     time.Duration `mapstructure:"dc_down_cooldown"`
+
+	MaxConcurrentUserApplies int `mapstructure:"max_concurrent_user_applies"`
+
+	LogBufferSize int `mapstructure:"log_buffer_size"`
 }
 
 // New registers commands and return a new App.
@@ -83,7 +129,7 @@ func New() *App {
 				// Config reload
 
 				// No change in config file: skip.
-				if a.config == newConfig {
+				if reflect.DeepEqual(a.config, newConfig) {
 					return nil
 				}
 
@@ -116,15 +162,48 @@ func New() *App {
 				adsysservice.WithStateDir(a.config.StateDir),
 				adsysservice.WithRunDir(a.config.RunDir),
 				adsysservice.WithDconfDir(a.config.DconfDir),
+				adsysservice.WithDconfProfile(a.config.DconfProfile),
+				adsysservice.WithDconfGroupProfiles(a.config.DconfGroupProfiles),
+				adsysservice.WithExtensionsDir(a.config.ExtensionsDir),
+				adsysservice.WithRootDir(a.config.RootDir),
 				adsysservice.WithSudoersDir(a.config.SudoersDir),
 				adsysservice.WithPolicyKitDir(a.config.PolicyKitDir),
 				adsysservice.WithApparmorDir(a.config.ApparmorDir),
 				adsysservice.WithApparmorFsDir(a.config.ApparmorFsDir),
 				adsysservice.WithSystemUnitDir(a.config.SystemUnitDir),
 				adsysservice.WithGlobalTrustDir(a.config.GlobalTrustDir),
+				adsysservice.WithMountSourceAddress(a.config.MountSourceAddress),
+				adsysservice.WithWineProxyPrefixes(a.config.WineProxyPrefixes),
+				adsysservice.WithProxyCheckURL(a.config.ProxyCheckURL),
+				adsysservice.WithProxyNoAutoBypass(a.config.ProxyNoAutoBypass),
+				adsysservice.WithProxyExclusionsFile(a.config.ProxyExclusionsFile),
+				adsysservice.WithDockerProxyUsers(a.config.DockerProxyUsers),
+				adsysservice.WithDockerProxyDaemon(a.config.DockerProxyDaemon),
+				adsysservice.WithAllowAdminLockout(a.config.AllowAdminLockout),
+				adsysservice.WithPrivilegeReportOnly(a.config.PrivilegeReportOnly),
+				adsysservice.WithSudoersPerGroup(a.config.SudoersPerGroup),
+				adsysservice.WithSudoersAliasThreshold(a.config.SudoersAliases),
+				adsysservice.WithSudoersConflictsCheck(a.config.SudoersConflicts),
+				adsysservice.WithPolkitAdminRules(a.config.PolkitAdminRules),
+				adsysservice.WithProtectedLocalUsers(a.config.ProtectedLocalUsers),
+				adsysservice.WithWriteCooldown(a.config.WriteCooldown),
+				adsysservice.WithRefreshTimeout(a.config.RefreshTimeout),
+				adsysservice.WithTransactionalRefresh(a.config.Transactional),
+				adsysservice.WithOTLPEndpoint(a.config.OTLPEndpoint),
+				adsysservice.WithTamperHandling(a.config.TamperHandling),
+				adsysservice.WithUnknownKeys(a.config.UnknownKeys),
+				adsysservice.WithMachineKrb5CCName(a.config.MachineKrb5CCName),
 				adsysservice.WithADBackend(a.config.AdBackend),
 				adsysservice.WithSSSConfig(a.config.SSSdConfig),
 				adsysservice.WithWinbindConfig(a.config.WinbindConfig),
+				adsysservice.WithMaxCacheAge(a.config.MaxCacheAge),
+				adsysservice.WithStaleCacheAction(a.config.StaleCacheAction),
+				adsysservice.WithSMBProtocols(a.config.SMBMinProtocol, a.config.SMBMaxProtocol),
+				adsysservice.WithComputerAttributes(a.config.ComputerAttributes),
+				adsysservice.WithTicketRenewal(a.config.TicketRenewalMargin, a.config.TicketRenewalMaxBackoff),
+				adsysservice.WithDCCircuitBreaker(a.config.DCFailureThreshold, a.config.DCDownCooldown),
+				adsysservice.WithMaxConcurrentUserApplies(a.config.MaxConcurrentUserApplies),
+				adsysservice.WithLogBufferSize(a.config.LogBufferSize),
 			)
 			if err != nil {
 				close(a.ready)
@@ -140,6 +219,7 @@ func New() *App {
 				return err
 			}
 			a.daemon = d
+			a.service = adsys
 			close(a.ready)
 			return a.daemon.Listen()
 		},
@@ -171,6 +251,7 @@ func New() *App {
 	a.installVersion()
 	a.installRunScripts()
 	a.installMount()
+	a.installExpirePrivileges()
 	return &a
 }
 
@@ -200,11 +281,16 @@ func (a App) UsageError() bool {
 	return !a.rootCmd.SilenceUsage
 }
 
-// Hup prints all goroutine stack traces and return false to signal you shouldn't quit.
-func (a App) Hup() (shouldQuit bool) {
-	buf := make([]byte, 1<<16)
-	runtime.Stack(buf, true)
-	fmt.Printf("%s", buf)
+// Hup requests an immediate policy refresh if the service is running and return false to signal you shouldn't quit.
+func (a *App) Hup() (shouldQuit bool) {
+	select {
+	case <-a.ready:
+		if a.service != nil {
+			a.service.RefreshPolicies(context.Background())
+		}
+	default:
+	}
+
 	return false
 }
 
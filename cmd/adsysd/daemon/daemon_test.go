@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -135,72 +136,51 @@ func TestAppRunFailsOnServiceCreationAndQuit(t *testing.T) {
 }
 
 func TestAppCanSigHupWhenExecute(t *testing.T) {
-	r, w, err := os.Pipe()
-	require.NoError(t, err, "Setup: pipe shouldn’t fail")
-
 	a, wait := startDaemon(t, true)
 
 	defer wait()
 	defer a.Quit()
 
-	orig := os.Stdout
-	os.Stdout = w
-
-	err = a.IsReady(time.Second)
+	err := a.IsReady(time.Second)
 	require.NoError(t, err, "Daemon should start within second")
 
-	a.Hup()
+	require.False(t, a.Hup(), "Hup should not request to quit")
+}
 
-	os.Stdout = orig
-	w.Close()
+func TestAppSigHupRefreshesPoliciesAndKeepsRunning(t *testing.T) {
+	a, wait := startDaemon(t, true, "-v")
 
-	var out bytes.Buffer
-	_, err = io.Copy(&out, r)
-	require.NoError(t, err, "Couldn’t copy stdout to buffer")
-	require.NotEmpty(t, out.String(), "Stacktrace is printed")
+	defer wait()
+	defer a.Quit()
+
+	err := a.IsReady(time.Second)
+	require.NoError(t, err, "Daemon should start within second")
+
+	out := captureLogs(t)
+	require.False(t, a.Hup(), "Hup should not request to quit")
+	time.Sleep(100 * time.Millisecond) // let the refresh start
+	logs := out()
+	require.Contains(t, logs, "Manual policy refresh requested", "Hup should request a policy refresh")
+
+	// The daemon still serves requests after the refresh was requested.
+	conn, err := net.Dial("unix", os.Getenv("ADSYS_SOCKET"))
+	require.NoError(t, err, "Daemon should still be running after Hup")
+	conn.Close()
 }
 
 func TestAppCanSigHupAfterExecute(t *testing.T) {
-	r, w, err := os.Pipe()
-	require.NoError(t, err, "Setup: pipe shouldn’t fail")
-
 	t.Setenv("ADSYS_SERVICE_TIMEOUT", "1")
 	a, wait := startDaemon(t, true)
 	wait()
 	a.Quit()
 
-	orig := os.Stdout
-	os.Stdout = w
-
-	a.Hup()
-
-	os.Stdout = orig
-	w.Close()
-
-	var out bytes.Buffer
-	_, err = io.Copy(&out, r)
-	require.NoError(t, err, "Couldn’t copy stdout to buffer")
-	require.NotEmpty(t, out.String(), "Stacktrace is printed")
+	require.False(t, a.Hup(), "Hup should not request to quit")
 }
 
 func TestAppCanSigHupWithoutExecute(t *testing.T) {
-	r, w, err := os.Pipe()
-	require.NoError(t, err, "Setup: pipe shouldn’t fail")
-
 	a := daemon.New()
 
-	orig := os.Stdout
-	os.Stdout = w
-
-	a.Hup()
-
-	os.Stdout = orig
-	w.Close()
-
-	var out bytes.Buffer
-	_, err = io.Copy(&out, r)
-	require.NoError(t, err, "Couldn’t copy stdout to buffer")
-	require.NotEmpty(t, out.String(), "Stacktrace is printed")
+	require.False(t, a.Hup(), "Hup should not request to quit")
 }
 
 func TestAppTimeout(t *testing.T) {
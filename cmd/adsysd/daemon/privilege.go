@@ -0,0 +1,41 @@
+package daemon
+
+import (
+	"context"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/spf13/cobra"
+	"github.com/ubuntu/adsys/internal/consts"
+	"github.com/ubuntu/adsys/internal/policies/privilege"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+)
+
+func (a *App) installExpirePrivileges() {
+	cmd := &cobra.Command{
+		Use:    "expire-privileges SUDOERS_FILE POLKIT_FILE",
+		Short:  gotext.Get("Remove expired temporary administrators from the given sudoers and polkit files"),
+		Args:   cobra.ExactArgs(2),
+		Hidden: true,
+		RunE: func(_ *cobra.Command, args []string) error {
+			ctx := context.Background()
+			// Record the rewritten files like the daemon, so that it doesn't consider them as modified locally.
+			if len(a.config.TamperHandling) > 0 {
+				stateDir := a.config.StateDir
+				if stateDir == "" {
+					stateDir = consts.DefaultStateDir
+				}
+				modes := make(map[string]tamper.Mode)
+				for rule, mode := range a.config.TamperHandling {
+					modes[rule] = tamper.Mode(mode)
+				}
+				g, err := tamper.New(stateDir, modes)
+				if err != nil {
+					return err
+				}
+				ctx = tamper.WithGuard(ctx, g)
+			}
+			return privilege.RemoveExpiredGrants(ctx, args[0], args[1])
+		},
+	}
+	a.rootCmd.AddCommand(cmd)
+}
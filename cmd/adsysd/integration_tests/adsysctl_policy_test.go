@@ -1326,6 +1326,188 @@ func TestPolicyDebugTicketPath(t *testing.T) {
 	}
 }
 
+func TestPolicyPrivileges(t *testing.T) {
+	tests := map[string]struct {
+		raw              bool
+		noFiles          bool
+		systemAnswer     string
+		daemonNotStarted bool
+
+		wantErr bool
+	}{
+		"List privilege files":             {},
+		"Print raw content of the files":   {raw: true},
+		"Absent files are listed as such":  {noFiles: true},
+		"Absent files are listed when raw": {raw: true, noFiles: true},
+
+		"Error on privilege files denied": {systemAnswer: "polkit_no", wantErr: true},
+		"Error on daemon not responding":  {daemonNotStarted: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.systemAnswer == "" {
+				tc.systemAnswer = "polkit_yes"
+			}
+			dbusAnswer(t, tc.systemAnswer)
+
+			dir := t.TempDir()
+			sudoersConf := filepath.Join(dir, "sudoers.d", "99-adsys-privilege-enforcement")
+			policyKitConf := filepath.Join(dir, "polkit-1", "localauthority.conf.d", "99-adsys-privilege-enforcement.conf")
+			policyKitRules := filepath.Join(dir, "polkit-1", "rules.d", "10-adsys-privilege-enforcement.rules")
+			if !tc.noFiles {
+				for _, d := range []string{"sudoers.d", "polkit-1"} {
+					testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), d), filepath.Join(dir, d))
+				}
+			}
+			conf := createConf(t, confWithAdsysDir(dir))
+
+			if !tc.daemonNotStarted {
+				defer runDaemon(t, conf)()
+			}
+
+			args := []string{"policy", "privileges"}
+			if tc.raw {
+				args = append(args, "--raw")
+			}
+			got, err := runClient(t, conf, args...)
+			if tc.wantErr {
+				require.Error(t, err, "client should exit with an error")
+				return
+			}
+			require.NoError(t, err, "client should exit with no error")
+
+			var want string
+			switch {
+			case tc.noFiles:
+				want = fmt.Sprintf("%s: absent\n%s: absent\n%s: absent\n", sudoersConf, policyKitConf, policyKitRules)
+			case tc.raw:
+				sudoersContent, err := os.ReadFile(filepath.Join(testutils.TestFamilyPath(t), "sudoers.d", "99-adsys-privilege-enforcement"))
+				require.NoError(t, err, "Setup: can't read sudoers fixture")
+				policyKitContent, err := os.ReadFile(filepath.Join(testutils.TestFamilyPath(t), "polkit-1", "localauthority.conf.d", "99-adsys-privilege-enforcement.conf"))
+				require.NoError(t, err, "Setup: can't read polkit fixture")
+				want = fmt.Sprintf("==> %s <==\n%s==> %s <==\n%s%s: absent\n", sudoersConf, sudoersContent, policyKitConf, policyKitContent, policyKitRules)
+			default:
+				want = fmt.Sprintf("%s: present\n%s: present\n%s: absent\n", sudoersConf, policyKitConf, policyKitRules)
+			}
+			require.Equal(t, want, got, "PrivilegeFiles should return the privilege files on disk")
+		})
+	}
+}
+
+func TestPolicyEffective(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get current hostname")
+
+	tests := map[string]struct {
+		systemAnswer      string
+		noMachineGPORules bool
+		noPolkitConf      bool
+		daemonNotStarted  bool
+
+		wantErr bool
+	}{
+		"Export effective policy":                          {},
+		"Subscription disabled only exports dconf content": {systemAnswer: "subscription_disabled"},
+		"No privilege configuration on disk":               {noPolkitConf: true},
+
+		"Error on machine cache not available": {noMachineGPORules: true, wantErr: true},
+		"Error on daemon not responding":       {daemonNotStarted: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.systemAnswer == "" {
+				tc.systemAnswer = "polkit_yes"
+			}
+			dbusAnswer(t, tc.systemAnswer)
+
+			dir := t.TempDir()
+			dstDir := filepath.Join(dir, "cache", "policies")
+			require.NoError(t, os.MkdirAll(dstDir, 0700), "Setup: couldn't create policies directory")
+			if !tc.noMachineGPORules {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "policies", "machine"), filepath.Join(dstDir, hostname))
+			}
+			if !tc.noPolkitConf {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "polkit-1"), filepath.Join(dir, "polkit-1"))
+			}
+			conf := createConf(t, confWithAdsysDir(dir))
+
+			if !tc.daemonNotStarted {
+				defer runDaemon(t, conf)()
+			}
+
+			got, err := runClient(t, conf, "policy", "effective")
+			if tc.wantErr {
+				require.Error(t, err, "client should exit with an error")
+				return
+			}
+			require.NoError(t, err, "client should exit with no error")
+
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "EffectivePolicy returned expected output")
+		})
+	}
+}
+
+func TestPolicyVerify(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get current hostname")
+
+	tests := map[string]struct {
+		systemAnswer      string
+		tamperedSudoers   bool
+		noMachineGPORules bool
+		daemonNotStarted  bool
+
+		wantSudoers string
+		wantPolkit  string
+		wantErr     bool
+	}{
+		"Tampered file is reported as modified":      {tamperedSudoers: true, wantSudoers: "modified", wantPolkit: "missing"},
+		"Files not written are reported as missing":  {wantSudoers: "missing", wantPolkit: "missing"},
+		"Subscription disabled expects no privilege": {systemAnswer: "subscription_disabled", tamperedSudoers: true, wantSudoers: "unexpected", wantPolkit: "absent, as expected"},
+
+		"Error on verify denied":               {systemAnswer: "polkit_no", wantErr: true},
+		"Error on machine cache not available": {noMachineGPORules: true, wantErr: true},
+		"Error on daemon not responding":       {daemonNotStarted: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.systemAnswer == "" {
+				tc.systemAnswer = "polkit_yes"
+			}
+			dbusAnswer(t, tc.systemAnswer)
+
+			dir := t.TempDir()
+			dstDir := filepath.Join(dir, "cache", "policies")
+			require.NoError(t, os.MkdirAll(dstDir, 0700), "Setup: couldn't create policies directory")
+			if !tc.noMachineGPORules {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "policies", "machine"), filepath.Join(dstDir, hostname))
+			}
+			if tc.tamperedSudoers {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "sudoers.d"), filepath.Join(dir, "sudoers.d"))
+			}
+			conf := createConf(t, confWithAdsysDir(dir))
+
+			if !tc.daemonNotStarted {
+				defer runDaemon(t, conf)()
+			}
+
+			got, err := runClient(t, conf, "policy", "verify")
+			if tc.wantErr {
+				require.Error(t, err, "client should exit with an error")
+				return
+			}
+			require.NoError(t, err, "client should exit with no error")
+
+			want := fmt.Sprintf("%s (privilege): %s\n%s (privilege): %s\n%s (privilege): absent, as expected\n",
+				filepath.Join(dir, "sudoers.d", "99-adsys-privilege-enforcement"), tc.wantSudoers,
+				filepath.Join(dir, "polkit-1", "localauthority.conf.d", "99-adsys-privilege-enforcement.conf"), tc.wantPolkit,
+				filepath.Join(dir, "polkit-1", "rules.d", "10-adsys-privilege-enforcement.rules"))
+			require.Equal(t, want, got, "VerifyPolicy should report the state of the managed files")
+		})
+	}
+}
+
 func TestPolicyCompletion(t *testing.T) {
 	blockFileCompletionDirective := fmt.Sprintf(":%d", cobra.ShellCompDirectiveNoFileComp)
 
@@ -1356,6 +1538,12 @@ func TestPolicyCompletion(t *testing.T) {
 		"Purge with all doesn't allow further completion":     {args: "purge --all"},
 		"Purge for machines doesn't allow further completion": {args: "purge -m"},
 		"Purge with user doesn't allow further completion":    {args: "purge adsystestuser@example.com"},
+
+		"Drift returns list of available users":            {args: "drift", wantOut: "adsystestuser@example.com otheruser@example.com"},
+		"Drift with user doesn't allow further completion": {args: "drift adsystestuser@example.com"},
+
+		"Fetch returns list of available users":            {args: "fetch", wantOut: "adsystestuser@example.com otheruser@example.com"},
+		"Fetch with user doesn't allow further completion": {args: "fetch adsystestuser@example.com"},
 	}
 
 	for name, tc := range tests {
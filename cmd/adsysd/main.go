@@ -2,9 +2,11 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"runtime"
 	"sync"
 	"syscall"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/ubuntu/adsys/cmd/adsysd/client"
 	"github.com/ubuntu/adsys/cmd/adsysd/daemon"
 	"github.com/ubuntu/adsys/internal/consts"
+	"github.com/ubuntu/adsys/internal/grpc/grpcerror"
 	"github.com/ubuntu/adsys/po"
 	"github.com/ubuntu/go-i18n"
 )
@@ -54,7 +57,7 @@ func run(a app) int {
 		if a.UsageError() {
 			return 2
 		}
-		return 1
+		return grpcerror.ExitCode(err)
 	}
 
 	return 0
@@ -62,7 +65,7 @@ func run(a app) int {
 
 func installSignalHandler(a app) func() {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(c, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGINT, syscall.SIGTERM)
 
 	wg := sync.WaitGroup{}
 	wg.Add(1)
@@ -80,6 +83,8 @@ func installSignalHandler(a app) func() {
 					a.Quit()
 					return
 				}
+			case syscall.SIGUSR1:
+				printStacks()
 			default:
 				// channel was closed: we exited
 				if !ok {
@@ -95,3 +100,10 @@ func installSignalHandler(a app) func() {
 		wg.Wait()
 	}
 }
+
+// printStacks prints all goroutine stack traces.
+func printStacks() {
+	buf := make([]byte, 1<<16)
+	n := runtime.Stack(buf, true)
+	fmt.Printf("%s", buf[:n])
+}
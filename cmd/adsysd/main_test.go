@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"errors"
+	"io"
 	"os"
 	"os/exec"
 	"syscall"
@@ -9,12 +11,14 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/grpc/grpcerror"
 )
 
 type myApp struct {
 	done chan struct{}
 
 	runError         bool
+	runErrorCode     grpcerror.Code
 	usageErrorReturn bool
 	hupReturn        bool
 }
@@ -22,7 +26,11 @@ type myApp struct {
 func (a *myApp) Run() error {
 	<-a.done
 	if a.runError {
-		return errors.New("Error requested")
+		err := errors.New("Error requested")
+		if a.runErrorCode != "" {
+			return grpcerror.Format(grpcerror.WithCode(err, grpcerror.Mapping{err: a.runErrorCode}), "adsys")
+		}
+		return err
 	}
 	return nil
 }
@@ -42,6 +50,7 @@ func (a *myApp) Quit() {
 func TestRun(t *testing.T) {
 	tests := map[string]struct {
 		runError         bool
+		runErrorCode     grpcerror.Code
 		usageErrorReturn bool
 		hupReturn        bool
 		sendSig          syscall.Signal
@@ -53,11 +62,19 @@ func TestRun(t *testing.T) {
 		"Run and return usage error":             {usageErrorReturn: true, runError: true, wantReturnCode: 2},
 		"Run and usage error only does not fail": {usageErrorReturn: true, runError: false, wantReturnCode: 0},
 
+		// Daemon error codes
+		"Run and return missing ticket error":     {runError: true, runErrorCode: grpcerror.CodeKrbNoTicket, wantReturnCode: 10},
+		"Run and return unreachable server error": {runError: true, runErrorCode: grpcerror.CodeDCUnreachable, wantReturnCode: 11},
+		"Run and return policy parse error":       {runError: true, runErrorCode: grpcerror.CodePolicyParse, wantReturnCode: 12},
+		"Run and return stale cache error":        {runError: true, runErrorCode: grpcerror.CodeStaleCache, wantReturnCode: 13},
+		"Run and return unknown code error":       {runError: true, runErrorCode: "ERR_UNKNOWN", wantReturnCode: 1},
+
 		// Signals handling
 		"Send SIGINT exits":           {sendSig: syscall.SIGINT},
 		"Send SIGTERM exits":          {sendSig: syscall.SIGTERM},
 		"Send SIGHUP without exiting": {sendSig: syscall.SIGHUP},
 		"Send SIGHUP with exit":       {sendSig: syscall.SIGHUP, hupReturn: true},
+		"Send SIGUSR1 prints stacks":  {sendSig: syscall.SIGUSR1},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -66,6 +83,7 @@ func TestRun(t *testing.T) {
 			a := myApp{
 				done:             make(chan struct{}),
 				runError:         tc.runError,
+				runErrorCode:     tc.runErrorCode,
 				usageErrorReturn: tc.usageErrorReturn,
 				hupReturn:        tc.hupReturn,
 			}
@@ -105,6 +123,32 @@ func TestRun(t *testing.T) {
 				// if SIGHUP returns false: do nothing and still wait.
 				// Otherwise, it means that we wanted to stop
 				require.Equal(t, tc.hupReturn, exited, "Expect to exit only on SIGHUP returning True")
+			case syscall.SIGUSR1:
+				r, w, err := os.Pipe()
+				require.NoError(t, err, "Setup: pipe shouldn’t fail")
+				var out bytes.Buffer
+				copied := make(chan struct{})
+				go func() {
+					_, _ = io.Copy(&out, r)
+					close(copied)
+				}()
+				orig := os.Stdout
+				os.Stdout = w
+
+				err = syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+				require.NoError(t, err, "Teardown: kill should return no error")
+				select {
+				case <-time.After(50 * time.Millisecond):
+					exited = false
+				case <-wait:
+					exited = true
+				}
+
+				os.Stdout = orig
+				w.Close()
+				<-copied
+				require.False(t, exited, "Expect to not exit on SIGUSR1")
+				require.Contains(t, out.String(), "goroutine", "Expect stack traces to be printed on SIGUSR1")
 			}
 
 			if !exited {
@@ -23,12 +23,14 @@ import (
 	"github.com/leonelquinteros/gotext"
 	"github.com/ubuntu/adsys/internal/ad/backends"
 	adcommon "github.com/ubuntu/adsys/internal/ad/common"
+	"github.com/ubuntu/adsys/internal/ad/dc"
 	"github.com/ubuntu/adsys/internal/ad/registry"
 	"github.com/ubuntu/adsys/internal/consts"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/policies"
 	"github.com/ubuntu/adsys/internal/policies/entry"
 	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/adsys/internal/telemetry"
 	"github.com/ubuntu/decorate"
 	"golang.org/x/sync/errgroup"
 )
@@ -48,8 +50,25 @@ const (
 	// policyServerPrefix is the GPO prefix containing keys that configure
 	// policy servers for certificate enrollment.
 	policyServersPrefix string = "Software/Policies/Microsoft/Cryptography/PolicyServers/"
+
+	// lastOnlineUpdateFileName is the file, in the object policies cache directory, whose modification time
+	// tracks the last time the GPOs were fetched from the domain controller.
+	lastOnlineUpdateFileName = "last-online-update"
+)
+
+// StaleCacheAction is the behavior to adopt when cached policies are older than the maximum cache age.
+type StaleCacheAction string
+
+const (
+	// StaleCacheFail makes the policy refresh fail.
+	StaleCacheFail StaleCacheAction = "fail"
+	// StaleCacheRevert unloads all policies, restoring managed files to an unmanaged state.
+	StaleCacheRevert StaleCacheAction = "revert"
 )
 
+// ErrStaleCache is returned when the machine is offline and the cached policies are older than the maximum cache age.
+var ErrStaleCache = errors.New(gotext.Get("cached policies are older than the maximum allowed cache age"))
+
 type gpo downloadable
 
 type downloadable struct {
@@ -72,6 +91,8 @@ type AD struct {
 	sysvolCacheDir   string
 	policiesCacheDir string
 	krb5CacheDir     string
+	// machineKrb5CCPath is the ticket cache, copied from the backend one, used to fetch the machine policies.
+	machineKrb5CCPath string
 
 	downloadables map[string]*downloadable
 	sync.RWMutex
@@ -80,6 +101,29 @@ type AD struct {
 	withoutKerberos bool
 	gpoListCmd      []string
 	gpoListTimeout  time.Duration
+
+	// computerAttrs are the attributes of the computer object read at each machine refresh.
+	computerAttrs []string
+
+	maxCacheAge      time.Duration
+	staleCacheAction StaleCacheAction
+
+	ticketExpiry func(string) (string, time.Time, error)
+
+	ticketRenewalMargin     time.Duration
+	ticketRenewalMaxBackoff time.Duration
+	kinitCmd                []string
+
+	smbMinProtocol  string
+	smbMaxProtocol  string
+	setSMBProtocols func(minProtocol, maxProtocol string) error
+
+	dcLocator          *dc.Locator
+	dcBreaker          *dc.Breaker
+	dcFailureThreshold int
+	dcDownCooldown     time.Duration
+
+	telemetry *telemetry.Exporter
 }
 
 type options struct {
@@ -87,9 +131,32 @@ type options struct {
 	runDir    string
 	cacheDir  string
 
+	machineKrb5CCPath string
+
 	withoutKerberos bool
 	gpoListCmd      []string
 	gpoListTimeout  time.Duration
+
+	computerAttributes []string
+
+	maxCacheAge      time.Duration
+	staleCacheAction StaleCacheAction
+
+	ticketExpiry func(string) (string, time.Time, error)
+
+	ticketRenewalMargin     time.Duration
+	ticketRenewalMaxBackoff time.Duration
+	kinitCmd                []string
+
+	smbMinProtocol  string
+	smbMaxProtocol  string
+	setSMBProtocols func(minProtocol, maxProtocol string) error
+
+	dcLocatorOpts      []dc.Option
+	dcFailureThreshold int
+	dcDownCooldown     time.Duration
+
+	otlpEndpoint string
 }
 
 // Option reprents an optional function to change AD behavior.
@@ -111,6 +178,19 @@ func WithRunDir(runDir string) Option {
 	}
 }
 
+// WithMachineKrb5CCName specifies the Kerberos ticket cache, like FILE:/run/adsys/machine.ccache, in which the
+// machine ticket is copied to fetch the machine policies. Only file caches are supported.
+func WithMachineKrb5CCName(name string) Option {
+	return func(o *options) error {
+		p := strings.TrimPrefix(name, "FILE:")
+		if !filepath.IsAbs(p) {
+			return errors.New(gotext.Get("machine Kerberos ticket cache %q must be an absolute path to a file cache", name))
+		}
+		o.machineKrb5CCPath = filepath.Clean(p)
+		return nil
+	}
+}
+
 // WithGpoListTimeout specifies a custom timeout for the adsys-gpolist command.
 func WithGpoListTimeout(timeout time.Duration) Option {
 	return func(o *options) error {
@@ -119,6 +199,68 @@ func WithGpoListTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithMaxCacheAge specifies the maximum age of cached policies used when the machine is offline.
+// A 0 duration means no limit.
+func WithMaxCacheAge(maxAge time.Duration) Option {
+	return func(o *options) error {
+		if maxAge < 0 {
+			return errors.New(gotext.Get("maximum cache age can't be negative: %v", maxAge))
+		}
+		o.maxCacheAge = maxAge
+		return nil
+	}
+}
+
+// WithStaleCacheAction specifies what to do when cached policies are older than the maximum cache age.
+func WithStaleCacheAction(action StaleCacheAction) Option {
+	return func(o *options) error {
+		switch action {
+		case "":
+			return nil
+		case StaleCacheFail, StaleCacheRevert:
+			o.staleCacheAction = action
+			return nil
+		}
+		return errors.New(gotext.Get("unknown stale cache action %q: must be %q or %q", action, StaleCacheFail, StaleCacheRevert))
+	}
+}
+
+// smbProtocolRanks are the lowest and highest dialects, by increasing order, of the SMB protocols accepted by
+// libsmbclient as minimum or maximum protocol.
+var smbProtocolRanks = map[string]struct{ lowest, highest int }{
+	"NT1":     {0, 0},
+	"SMB2_02": {1, 1},
+	"SMB2_10": {2, 2},
+	"SMB2":    {1, 2},
+	"SMB3_00": {3, 3},
+	"SMB3_02": {4, 4},
+	"SMB3_11": {5, 5},
+	"SMB3":    {3, 5},
+}
+
+// WithSMBProtocols specifies the minimum and maximum SMB protocols, like SMB2 or SMB3_11, negotiated to access SYSVOL.
+// An empty value keeps the default: SMB2 as minimum and the libsmbclient default as maximum.
+func WithSMBProtocols(minProtocol, maxProtocol string) Option {
+	return func(o *options) error {
+		minProtocol, maxProtocol = strings.ToUpper(minProtocol), strings.ToUpper(maxProtocol)
+		for _, p := range []string{minProtocol, maxProtocol} {
+			if _, ok := smbProtocolRanks[p]; p != "" && !ok {
+				return errors.New(gotext.Get("unknown SMB protocol %q: must be one of NT1, SMB2, SMB2_02, SMB2_10, SMB3, SMB3_00, SMB3_02 or SMB3_11", p))
+			}
+		}
+		if minProtocol != "" {
+			o.smbMinProtocol = minProtocol
+		}
+		if maxProtocol != "" {
+			o.smbMaxProtocol = maxProtocol
+		}
+		if o.smbMaxProtocol != "" && smbProtocolRanks[o.smbMinProtocol].lowest > smbProtocolRanks[o.smbMaxProtocol].highest {
+			return errors.New(gotext.Get("minimum SMB protocol %s is above maximum SMB protocol %s", o.smbMinProtocol, o.smbMaxProtocol))
+		}
+		return nil
+	}
+}
+
 // AdsysGpoListCode is the embedded script which request
 // Samba to get our GPO list for the given object.
 //
@@ -141,6 +283,17 @@ func New(ctx context.Context, configBackend backends.Backend, hostname string, o
 		gpoListCmd:     []string{"python3", "-c", AdsysGpoListCode},
 		versionID:      versionID,
 		gpoListTimeout: 30 * time.Second, // this is used in tests and set to consts.DefaultGpoListTimeout in production
+
+		staleCacheAction: StaleCacheFail,
+		ticketExpiry:     TicketExpiry,
+
+		ticketRenewalMaxBackoff: defaultTicketRenewalMaxBackoff,
+		kinitCmd:                []string{"kinit"},
+
+		smbMinProtocol:  "SMB2",
+		setSMBProtocols: setSMBProtocols,
+
+		dcDownCooldown: defaultDCDownCooldown,
 	}
 	// applied options
 	for _, o := range opts {
@@ -153,6 +306,13 @@ func New(ctx context.Context, configBackend backends.Backend, hostname string, o
 	if err := os.MkdirAll(filepath.Join(krb5CacheDir, "tracking"), 0700); err != nil {
 		return nil, err
 	}
+	machineKrb5CCPath := args.machineKrb5CCPath
+	if machineKrb5CCPath == "" {
+		machineKrb5CCPath = filepath.Join(krb5CacheDir, hostname)
+	}
+	if err := os.MkdirAll(filepath.Dir(machineKrb5CCPath), 0700); err != nil {
+		return nil, err
+	}
 	sysvolCacheDir := filepath.Join(args.cacheDir, "sysvol")
 	// Create Policies subdirectory under sysvol
 	if err := os.MkdirAll(filepath.Join(sysvolCacheDir, "Policies"), 0700); err != nil {
@@ -170,6 +330,15 @@ func New(ctx context.Context, configBackend backends.Backend, hostname string, o
 	}
 	log.Debugf(ctx, "Backend is SSSD. AD domain: %q, server from configuration: %q", domain, serverFQDN)
 
+	var telemetryExporter *telemetry.Exporter
+	if args.otlpEndpoint != "" {
+		if telemetryExporter, err = telemetry.New(args.otlpEndpoint, hostname); err != nil {
+			return nil, err
+		}
+	}
+
+	dcBreaker := dc.NewBreaker(args.dcFailureThreshold, args.dcDownCooldown)
+
 	return &AD{
 		hostname:         hostname,
 		configBackend:    configBackend,
@@ -178,9 +347,33 @@ func New(ctx context.Context, configBackend backends.Backend, hostname string, o
 		policiesCacheDir: policiesCacheDir,
 		krb5CacheDir:     krb5CacheDir,
 
+		machineKrb5CCPath: machineKrb5CCPath,
+
 		downloadables:  make(map[string]*downloadable),
 		gpoListCmd:     args.gpoListCmd,
 		gpoListTimeout: args.gpoListTimeout,
+
+		computerAttrs: args.computerAttributes,
+
+		maxCacheAge:      args.maxCacheAge,
+		staleCacheAction: args.staleCacheAction,
+
+		ticketExpiry: args.ticketExpiry,
+
+		ticketRenewalMargin:     args.ticketRenewalMargin,
+		ticketRenewalMaxBackoff: args.ticketRenewalMaxBackoff,
+		kinitCmd:                args.kinitCmd,
+
+		smbMinProtocol:  args.smbMinProtocol,
+		smbMaxProtocol:  args.smbMaxProtocol,
+		setSMBProtocols: args.setSMBProtocols,
+
+		dcLocator:          dc.New(append([]dc.Option{dc.WithBreaker(dcBreaker)}, args.dcLocatorOpts...)...),
+		dcBreaker:          dcBreaker,
+		dcFailureThreshold: args.dcFailureThreshold,
+		dcDownCooldown:     args.dcDownCooldown,
+
+		telemetry: telemetryExporter,
 	}, nil
 }
 
@@ -196,15 +389,120 @@ func (ad *AD) GetPolicies(ctx context.Context, objectName string, objectClass Ob
 
 	log.Debugf(ctx, "GetPolicies for %q, type %q", objectName, objectClass)
 
+	krb5CCPath, err := ad.prepareKrb5CC(objectName, objectClass, userKrb5CCName)
+	if err != nil {
+		return pols, err
+	}
+
+	var online bool
+	if online, err = ad.configBackend.IsOnline(); err != nil {
+		return pols, err
+	}
+
+	// If sssd returns that we are offline, returns the cache list of GPOs if present
+	if !online {
+		return ad.getCachedPolicies(ctx, objectName)
+	}
+
+	downloadables, orderedGPOs, err := ad.listGPOs(ctx, objectName, objectClass, krb5CCPath, "")
+	if err != nil {
+		return pols, err
+	}
+
+	ad.Lock()
+	defer ad.Unlock()
+	assetsWereRefresh, err := ad.fetch(ctx, krb5CCPath, downloadables)
+	if err != nil {
+		return pols, err
+	}
+
+	var errg errgroup.Group
+	// Parse policies
+	var gposRules []policies.GPO
+	errg.Go(func() (err error) {
+		gposRules, err = ad.parseGPOs(ctx, orderedGPOs, objectClass)
+		return err
+	})
+
+	// Compress assets
+	var assetsDbPath string
+	errg.Go(func() (err error) {
+		assetsDbPath, err = ad.assetsDB(ctx, assetsWereRefresh)
+		return err
+	})
+
+	if err := errg.Wait(); err != nil {
+		return pols, fmt.Errorf("one or more error while parsing downloaded elements: %w", err)
+	}
+
+	// The computer attributes are cached with the policies, to be used offline.
+	var computerAttrs map[string]string
+	if objectClass == ComputerObject && len(ad.computerAttrs) > 0 {
+		if computerAttrs, err = ad.computerAttributes(ctx, krb5CCPath); err != nil {
+			return pols, err
+		}
+	}
+
+	if pols, err = policies.New(ctx, gposRules, assetsDbPath); err != nil {
+		return pols, err
+	}
+	pols.ComputerAttributes = computerAttrs
+
+	// Only a successful fetch and parse resets the age of the cache.
+	if err := ad.markOnlineUpdate(objectName); err != nil {
+		return policies.Policies{}, errors.Join(err, pols.Close())
+	}
+	return pols, nil
+}
+
+// assetsDB returns the path of the compressed assets of the sysvol cache, or an empty path if there is none.
+// The assets are only compressed again if they were refreshed, otherwise the existing db is attached.
+func (ad *AD) assetsDB(ctx context.Context, assetsWereRefresh bool) (string, error) {
+	assetsSrc := filepath.Join(ad.sysvolCacheDir, "assets")
+	db := filepath.Join(assetsSrc + ".db")
+	if !assetsWereRefresh {
+		if _, err := os.Stat(db); errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+		return db, nil
+	}
+	// check assetsSrc exists
+	_, err := os.Stat(assetsSrc)
+	if errors.Is(err, fs.ErrNotExist) {
+		if err := os.Remove(db); err != nil {
+			return "", err
+		}
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	// Cache it as a single zip file
+	if err := policies.CompressAssets(ctx, assetsSrc); err != nil {
+		return "", err
+	}
+
+	return db, nil
+}
+
+// prepareKrb5CC ensures that an up-to-date copy of the ticket cache of objectName is available and returns its path.
+// userKrb5CCName has no impact for computer object and is ignored.
+func (ad *AD) prepareKrb5CC(objectName string, objectClass ObjectClass, userKrb5CCName string) (krb5CCPath string, err error) {
 	if objectClass == UserObject && !strings.Contains(objectName, "@") {
-		return pols, errors.New(gotext.Get("user name %q should be of the form %s@DOMAIN", objectName, objectName))
+		return "", errors.New(gotext.Get("user name %q should be of the form %s@DOMAIN", objectName, objectName))
 	}
 
 	if objectClass == ComputerObject && objectName != ad.hostname {
-		return pols, errors.New(gotext.Get("requested a type computer of %q which isn't current host %q", objectName, ad.hostname))
+		return "", errors.New(gotext.Get("requested a type computer of %q which isn't current host %q", objectName, ad.hostname))
 	}
 
-	krb5CCPath := filepath.Join(ad.krb5CacheDir, objectName)
+	krb5CCPath = filepath.Join(ad.krb5CacheDir, objectName)
+	// The machine ticket is copied in its own cache, never shared with the users ones.
+	if objectClass == ComputerObject {
+		krb5CCPath = ad.machineKrb5CCPath
+	}
 	krb5CCSymlink := filepath.Join(ad.krb5CacheDir, "tracking", objectName)
 	// Create a ccache symlink on first fetch for future calls (on refresh for instance)
 	if userKrb5CCName != "" || objectClass == ComputerObject {
@@ -213,46 +511,71 @@ func (ad *AD) GetPolicies(ctx context.Context, objectName string, objectClass Ob
 		if objectClass == ComputerObject {
 			src, err = ad.configBackend.HostKrb5CCName()
 			if err != nil {
-				return pols, err
+				return "", err
 			}
 		}
 
 		// Create a symlink to the ccache file
 		if err := ad.ensureKrb5CCSymlink(src, krb5CCSymlink); err != nil {
-			return pols, err
+			return "", err
 		}
 	}
 
 	// Ensure we have an up-to-date copy of the ccache file
 	if err := ad.ensureKrb5CCCopy(krb5CCSymlink, krb5CCPath); err != nil {
-		return pols, err
+		return "", err
 	}
 
-	var online bool
-	if online, err = ad.configBackend.IsOnline(); err != nil {
-		return pols, err
-	}
+	return krb5CCPath, nil
+}
 
-	// If sssd returns that we are offline, returns the cache list of GPOs if present
-	if !online {
-		var cachedPolicies policies.Policies
-		if cachedPolicies, err = policies.NewFromCache(ctx, filepath.Join(ad.policiesCacheDir, objectName)); err != nil {
-			return cachedPolicies, errors.New(gotext.Get("machine is offline and policies cache is unavailable: %v", err))
+// serverFQDN returns the domain controller to fetch the GPOs from. If the backend has no active server, one is
+// discovered from the SRV records of the domain, preferring the controllers reachable over IPv6.
+// As Kerberos service principals are registered for host names, a server given as an IP address is resolved back
+// to its host name when possible.
+// If the server of the backend is marked down after repeated failures, another domain controller is discovered.
+func (ad *AD) serverFQDN(ctx context.Context) (string, error) {
+	server, err := ad.configBackend.ServerFQDN(ctx)
+	if errors.Is(err, backends.ErrNoActiveServer) {
+		log.Debugf(ctx, "No active server from backend, discovering a domain controller for %q", ad.configBackend.Domain())
+		server, err = ad.dcLocator.Discover(ctx, ad.configBackend.Domain())
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", backends.ErrNoActiveServer, err)
 		}
+	}
+	if err != nil {
+		return "", err
+	}
+	server = ad.dcLocator.KerberosHost(ctx, server)
 
-		log.Infof(ctx, "Can't reach AD: machine is offline and %q policies are applied using previous online update", objectName)
-		return cachedPolicies, nil
+	if ok, until := ad.dcBreaker.Allow(server); !ok {
+		log.Warningf(ctx, "Domain controller %q is marked down until %s after repeated failures, discovering another one for %q", server, until.Format(time.DateTime), ad.configBackend.Domain())
+		other, err := ad.dcLocator.Discover(ctx, ad.configBackend.Domain())
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", gotext.Get("domain controller %s is marked down until %s", server, until.Format(time.DateTime)), err)
+		}
+		return ad.dcLocator.KerberosHost(ctx, other), nil
 	}
 
+	return server, nil
+}
+
+// listGPOs returns the URL of each GPO applicable to objectName, with the one of the assets, and the GPOs in
+// order of priority. It uses krb5CCPath to authenticate to AD.
+// If ou is not empty, the GPOs are listed as if objectName was located in this organizational unit.
+func (ad *AD) listGPOs(ctx context.Context, objectName string, objectClass ObjectClass, krb5CCPath, ou string) (downloadables map[string]string, orderedGPOs []gpo, err error) {
 	// We need an AD DC to connect to
-	adServerFQDN, err := ad.configBackend.ServerFQDN(ctx)
+	adServerFQDN, err := ad.serverFQDN(ctx)
 	if err != nil {
-		return policies.Policies{}, errors.New(gotext.Get("can't get current Server FQDN: %v", err))
+		return nil, nil, fmt.Errorf("%s: %w", gotext.Get("can't get current Server FQDN"), err)
 	}
 
-	// Otherwise, try fetching the GPO list from LDAP
+	// Fetch the GPO list from LDAP
 	args := append([]string{}, ad.gpoListCmd...) // Copy gpoListCmd to prevent data race
 	scriptArgs := []string{"--objectclass", string(objectClass), adServerFQDN, objectName}
+	if ou != "" {
+		scriptArgs = append([]string{"--ou", ou}, scriptArgs...)
+	}
 	cmdArgs := append(args, scriptArgs...)
 	cmdCtx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
@@ -267,12 +590,18 @@ func (ad *AD) GetPolicies(ctx context.Context, objectName string, objectClass Ob
 	smbsafe.WaitExec()
 	err = cmd.Run()
 	smbsafe.DoneExec()
+	// Only the timeouts and connection failures count against the domain controller, not a cancelled refresh.
+	switch {
+	case err == nil:
+		ad.dcSucceeded(ctx, adServerFQDN)
+	case ctx.Err() == nil && (cmdCtx.Err() != nil || cmd.ProcessState.ExitCode() == gpoListConnectionFailed):
+		ad.dcFailed(ctx, adServerFQDN)
+	}
 	if err != nil {
-		return pols, errors.New(gotext.Get("failed to retrieve the list of GPO (exited with %d): %v\n%s", cmd.ProcessState.ExitCode(), err, stderr.String()))
+		return nil, nil, errors.New(gotext.Get("failed to retrieve the list of GPO (exited with %d): %v\n%s", cmd.ProcessState.ExitCode(), err, stderr.String()))
 	}
 
-	downloadables := make(map[string]string)
-	var orderedGPOs []gpo
+	downloadables = make(map[string]string)
 	scanner := bufio.NewScanner(&stdout)
 	for scanner.Scan() {
 		t := scanner.Text()
@@ -287,73 +616,82 @@ func (ad *AD) GetPolicies(ctx context.Context, objectName string, objectClass Ob
 		}
 		u, err := url.Parse(gpoURL)
 		if err != nil {
-			return pols, err
+			return nil, nil, err
 		}
 		// Assets are in <root>/DistroID, while GPOs are in <root>/Policies/<gpoName>
 		u.Path = filepath.Join(filepath.Dir(filepath.Dir(u.Path)), consts.DistroID)
 		downloadables["assets"] = u.String()
 	}
 	if err := scanner.Err(); err != nil {
-		return pols, err
+		return nil, nil, err
 	}
 
-	ad.Lock()
-	defer ad.Unlock()
-	assetsWereRefresh, err := ad.fetch(ctx, krb5CCPath, downloadables)
+	return downloadables, orderedGPOs, nil
+}
+
+// getCachedPolicies returns the policies cached for objectName on the last apply.
+// If a maximum cache age is set and the cached policies were fetched from AD before that, the stale
+// cache action is used to either fail or return empty policies, which reverts managed files while keeping the
+// cached policies.
+func (ad *AD) getCachedPolicies(ctx context.Context, objectName string) (pols policies.Policies, err error) {
+	cacheDir := filepath.Join(ad.policiesCacheDir, objectName)
+	if pols, err = policies.NewFromCache(ctx, cacheDir); err != nil {
+		return pols, errors.New(gotext.Get("machine is offline and policies cache is unavailable: %v", err))
+	}
+
+	lastUpdate, err := lastOnlineUpdate(cacheDir)
 	if err != nil {
-		return pols, err
+		return pols, errors.New(gotext.Get("machine is offline and can't get policies cache age: %v", err))
 	}
+	age := time.Since(lastUpdate).Round(time.Second)
 
-	var errg errgroup.Group
-	// Parse policies
-	var gposRules []policies.GPO
-	errg.Go(func() (err error) {
-		gposRules, err = ad.parseGPOs(ctx, orderedGPOs, objectClass)
-		return err
-	})
+	log.Infof(ctx, "Can't reach AD: machine is offline and %q policies are applied using previous online update (cache age: %s)", objectName, age)
 
-	// Compress assets
-	var assetsDbPath string
-	assetsSrc := filepath.Join(ad.sysvolCacheDir, "assets")
-	errg.Go(func() (err error) {
-		// Only compress assets if we have fetched them, otherwise attach optionally
-		// existing db.
-		if !assetsWereRefresh {
-			db := filepath.Join(assetsSrc + ".db")
-			if _, err := os.Stat(db); errors.Is(err, os.ErrNotExist) {
-				return nil
-			} else if err != nil {
-				return err
-			}
-			assetsDbPath = db
-			return nil
-		}
-		// check assetsSrc exists
-		_, err = os.Stat(assetsSrc)
-		if errors.Is(err, fs.ErrNotExist) {
-			if err := os.Remove(filepath.Join(assetsSrc + ".db")); err != nil {
-				return err
-			}
-			return nil
-		} else if err != nil {
-			return err
-		}
+	if ad.maxCacheAge == 0 || age <= ad.maxCacheAge {
+		return pols, nil
+	}
 
-		// Cache it as a single zip file
-		if err := policies.CompressAssets(ctx, assetsSrc); err != nil {
-			return err
+	if ad.staleCacheAction == StaleCacheRevert {
+		log.Warningf(ctx, "Cached policies for %q are %s old, exceeding the maximum cache age of %s: reverting all policies", objectName, age, ad.maxCacheAge)
+		if err := pols.Close(); err != nil {
+			return policies.Policies{}, err
 		}
+		return policies.Policies{KeepCache: true}, nil
+	}
 
-		assetsDbPath = filepath.Join(assetsSrc + ".db")
+	if err := pols.Close(); err != nil {
+		return policies.Policies{}, err
+	}
+	return policies.Policies{}, fmt.Errorf("%w: %q policies are %s old (maximum: %s)", ErrStaleCache, objectName, age, ad.maxCacheAge)
+}
 
-		return nil
-	})
+// markOnlineUpdate records that the policies for objectName were just fetched from AD.
+func (ad *AD) markOnlineUpdate(objectName string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't record online update time for %q", objectName))
 
-	if err := errg.Wait(); err != nil {
-		return pols, fmt.Errorf("one or more error while parsing downloaded elements: %w", err)
+	cacheDir := filepath.Join(ad.policiesCacheDir, objectName)
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return err
 	}
+	return os.WriteFile(filepath.Join(cacheDir, lastOnlineUpdateFileName), nil, 0600)
+}
 
-	return policies.New(ctx, gposRules, assetsDbPath)
+// lastOnlineUpdate returns the last time policies in cacheDir were fetched from AD.
+// Caches created before this was recorded fall back to the modification time of the policies themselves.
+func lastOnlineUpdate(cacheDir string) (time.Time, error) {
+	fi, err := os.Stat(filepath.Join(cacheDir, lastOnlineUpdateFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		fi, err = os.Stat(filepath.Join(cacheDir, policies.PoliciesFileName))
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime(), nil
+}
+
+// MachineKrb5CCPath returns the path of the Kerberos ticket cache used to fetch the machine policies.
+func (ad *AD) MachineKrb5CCPath() string {
+	return ad.machineKrb5CCPath
 }
 
 // ListUsers returns the list of users on the system based on their cached policy information.
@@ -392,6 +730,71 @@ func (ad *AD) ListUsers(ctx context.Context, active bool) (users []string, err e
 	return users, nil
 }
 
+// TicketState is the state of a kerberos ticket.
+type TicketState string
+
+const (
+	// TicketAbsent means that no ticket was found.
+	TicketAbsent TicketState = "absent"
+	// TicketExpired means that the ticket granting ticket is past its end time.
+	TicketExpired TicketState = "expired"
+	// TicketValid means that the ticket granting ticket can still be used.
+	TicketValid TicketState = "valid"
+)
+
+// TicketInfo describes the kerberos ticket of a user or of the machine.
+type TicketInfo struct {
+	State     TicketState
+	Path      string
+	Principal string
+	Expiry    time.Time
+}
+
+// TicketStatus returns the status of the kerberos ticket used to fetch the policies of objectName.
+// User tickets are the ones tracked by previous policy updates, while the machine one is provided by the backend.
+func (ad *AD) TicketStatus(ctx context.Context, objectName string, objectClass ObjectClass) (info TicketInfo, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't get kerberos ticket status for %q", objectName))
+
+	log.Debugf(ctx, "TicketStatus for %q", objectName)
+
+	info.State = TicketAbsent
+
+	if objectClass == ComputerObject {
+		info.Path, err = ad.configBackend.HostKrb5CCName()
+		if err != nil {
+			return info, err
+		}
+	} else {
+		info.Path, err = os.Readlink(filepath.Join(ad.krb5CacheDir, "tracking", objectName))
+		if errors.Is(err, fs.ErrNotExist) {
+			return TicketInfo{State: TicketAbsent}, nil
+		}
+		if err != nil {
+			return info, err
+		}
+	}
+
+	if _, err := os.Stat(info.Path); errors.Is(err, fs.ErrNotExist) {
+		return TicketInfo{State: TicketAbsent}, nil
+	}
+
+	info.Principal, info.Expiry, err = ad.ticketExpiry(info.Path)
+	if errors.Is(err, ErrTicketNotPresent) {
+		log.Debugf(ctx, "No usable ticket in %q: %v", info.Path, err)
+		return TicketInfo{State: TicketAbsent}, nil
+	}
+	if err != nil {
+		return info, err
+	}
+
+	info.State = TicketValid
+	if !info.Expiry.After(time.Now()) {
+		info.State = TicketExpired
+	}
+
+	return info, nil
+}
+
 // ensureKrb5CCSymlink manages user ccname ticket symlinks.
 // It handles concurrent calls, and works by creating a symlink to the
 // actual ticket for tracking purposes.
@@ -440,13 +843,15 @@ func (ad *AD) ensureKrb5CCCopy(krb5CCSymlink, krb5CCCopyName string) error {
 
 	krb5CCSrc, err := os.Readlink(krb5CCSymlink)
 	if err != nil {
-		return errors.New(gotext.Get("failed to read krb5cc symlink: %v", err))
+		return fmt.Errorf("%w: %s", ErrTicketNotPresent, gotext.Get("failed to read krb5cc symlink: %v", err))
 	}
 
 	if copyStat, err := os.Lstat(krb5CCCopyName); err == nil && copyStat.Mode()&os.ModeSymlink == 0 {
 		// We already have a copy of the ticket, let's check if we need to update it
 		srcStat, err := os.Stat(krb5CCSrc)
-		if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrTicketNotPresent, gotext.Get("failed to stat source ticket: %v", err))
+		} else if err != nil {
 			return errors.New(gotext.Get("failed to stat source ticket: %v", err))
 		}
 
@@ -534,7 +939,7 @@ func (ad *AD) parseGPOs(ctx context.Context, gpos []gpo, objectClass ObjectClass
 			// Decode and apply policies in gpo order. First win
 			pols, err := registry.DecodePolicy(f)
 			if err != nil {
-				return errors.New(gotext.Get("%s: %v", f.Name(), err))
+				return fmt.Errorf("%s: %w", f.Name(), err)
 			}
 
 			// filter keys to be overridden
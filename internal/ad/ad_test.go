@@ -4,19 +4,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/termie/go-shutil"
 	"github.com/ubuntu/adsys/internal/ad"
 	"github.com/ubuntu/adsys/internal/ad/backends"
 	"github.com/ubuntu/adsys/internal/ad/backends/mock"
+	"github.com/ubuntu/adsys/internal/ad/dc"
 	"github.com/ubuntu/adsys/internal/policies"
 	"github.com/ubuntu/adsys/internal/policies/entry"
 	"github.com/ubuntu/adsys/internal/testutils"
@@ -33,16 +40,28 @@ func TestNew(t *testing.T) {
 		cacheDirRO             bool
 		runDirRO               bool
 		backendServerFQDNError error
+		machineKrb5CCName      string
+		smbMinProtocol         string
+		smbMaxProtocol         string
 
 		wantErr bool
 	}{
 		"create KRB5 and Sysvol cache directory":                {},
 		"no active server in backend does not fail ad creation": {backendServerFQDNError: backends.ErrNoActiveServer},
+		"create machine ticket cache directory":                 {machineKrb5CCName: "FILE:machine/ccache"},
+		"SMB protocols are case insensitive":                    {smbMinProtocol: "smb3", smbMaxProtocol: "smb3_11"},
+		"SMB2 minimum protocol accepts SMB2_10 maximum":         {smbMinProtocol: "SMB2", smbMaxProtocol: "SMB2_10"},
 
 		"failed to create KRB5 cache directory":      {runDirRO: true, wantErr: true},
 		"failed to create Sysvol cache directory":    {cacheDirRO: true, wantErr: true},
 		"failed to create Policies cache directory":  {sysvolCacheDirExists: true, cacheDirRO: true, wantErr: true},
 		"error on backend ServerFQDN random failure": {backendServerFQDNError: errors.New("Some failure on ServerFQDN"), wantErr: true},
+		"error on relative machine ticket cache":     {machineKrb5CCName: "machine_ccache", wantErr: true},
+		"error on non file machine ticket cache":     {machineKrb5CCName: "KEYRING:persistent:0", wantErr: true},
+		"error on unknown SMB minimum protocol":      {smbMinProtocol: "SMB4", wantErr: true},
+		"error on unknown SMB maximum protocol":      {smbMaxProtocol: "CORE", wantErr: true},
+		"error on maximum below default minimum":     {smbMaxProtocol: "NT1", wantErr: true},
+		"error on maximum below minimum":             {smbMinProtocol: "SMB3", smbMaxProtocol: "SMB2", wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -60,9 +79,20 @@ func TestNew(t *testing.T) {
 				testutils.MakeReadOnly(t, cacheDir)
 			}
 
-			adc, err := ad.New(context.Background(), mock.Backend{ErrServerFQDN: tc.backendServerFQDNError}, hostname,
-				ad.WithRunDir(runDir),
-				ad.WithCacheDir(cacheDir))
+			opts := []ad.Option{ad.WithRunDir(runDir), ad.WithCacheDir(cacheDir)}
+			if tc.machineKrb5CCName != "" {
+				// Relative test paths are made absolute in the run directory, keeping their prefix.
+				name := tc.machineKrb5CCName
+				if p, ok := strings.CutPrefix(name, "FILE:"); ok {
+					name = "FILE:" + filepath.Join(runDir, p)
+				}
+				opts = append(opts, ad.WithMachineKrb5CCName(name))
+			}
+			if tc.smbMinProtocol != "" || tc.smbMaxProtocol != "" {
+				opts = append(opts, ad.WithSMBProtocols(tc.smbMinProtocol, tc.smbMaxProtocol))
+			}
+
+			adc, err := ad.New(context.Background(), mock.Backend{ErrServerFQDN: tc.backendServerFQDNError}, hostname, opts...)
 			if tc.wantErr {
 				require.NotNil(t, err, "AD creation should have failed")
 				return
@@ -74,6 +104,7 @@ func TestNew(t *testing.T) {
 			assert.DirExists(t, adc.Krb5CacheDir(), "Kerberos ticket cache directory doesn't exist")
 			assert.DirExists(t, adc.SysvolCacheDir(), "GPO cache directory doesn't exist")
 			assert.DirExists(t, adc.PoliciesCacheDir(), "policies cache directory doesn't exist")
+			assert.DirExists(t, filepath.Dir(adc.MachineKrb5CCPath()), "machine ticket cache directory doesn't exist")
 		})
 	}
 }
@@ -115,8 +146,9 @@ func TestGetPolicies(t *testing.T) {
 		versionID   string
 		gpoListArgs []string
 
-		turnKrb5CCCacheRO bool
-		existing          map[string]string
+		computerAttributes []string
+		turnKrb5CCCacheRO  bool
+		existing           map[string]string
 
 		want             policies.Policies
 		wantAssetsEquals string
@@ -523,6 +555,33 @@ func TestGetPolicies(t *testing.T) {
 			gpoListArgs: []string{"gpoonly.com", "bob:no-gpt-ini"},
 			wantErr:     true,
 		},
+		"Policy can’t be downloaded, other policies are applied": {
+			gpoListArgs: []string{"gpoonly.com", "bob:standard::bob:no-gpt-ini"},
+			want: policies.Policies{GPOs: []policies.GPO{
+				standardUserGPO("standard"),
+				{ID: "no-gpt-ini", Name: "no-gpt-ini-name", Rules: map[string][]entry.Entry{}},
+			}},
+		},
+		// Computer attributes cases
+		"Computer attributes are read with policies, computer object": {
+			objectName:         hostname,
+			objectClass:        ad.ComputerObject,
+			gpoListArgs:        []string{"gpoonly.com", hostname + ":standard"},
+			computerAttributes: []string{"description", "Location", "unsetAttribute"},
+			want: policies.Policies{GPOs: []policies.GPO{standardComputerGPO("standard")},
+				ComputerAttributes: map[string]string{"description": "Kiosk of the Paris office", "location": "paris"}},
+		},
+		"Computer attributes are not read, user object": {
+			gpoListArgs:        []string{"gpoonly.com", "bob:standard"},
+			computerAttributes: []string{"description"},
+			want:               policies.Policies{GPOs: []policies.GPO{standardUserGPO("standard")}},
+		},
+
+		"Policy can’t be downloaded, cached copy is applied": {
+			gpoListArgs: []string{"gpoonly.com", "bob:no-gpt-ini"},
+			existing:    map[string]string{"Policies/no-gpt-ini": "testdata/AD/SYSVOL/gpoonly.com/Policies/standard"},
+			want:        policies.Policies{GPOs: []policies.GPO{standardUserGPO("no-gpt-ini")}},
+		},
 		"Symlinks can’t be created": {
 			gpoListArgs:       []string{"gpoonly.com", "bob:standard"},
 			turnKrb5CCCacheRO: true,
@@ -536,6 +595,13 @@ func TestGetPolicies(t *testing.T) {
 			gpoListArgs: []string{"gpoonly.com", "bob:empty-value"},
 			wantErr:     true,
 		},
+		"Error on unreadable computer attributes": {
+			objectName:         hostname,
+			objectClass:        ad.ComputerObject,
+			gpoListArgs:        []string{"gpoonly.com", hostname + ":standard"},
+			computerAttributes: []string{"description", "unreadable"},
+			wantErr:            true,
+		},
 	}
 
 	for name, tc := range tests {
@@ -585,7 +651,9 @@ func TestGetPolicies(t *testing.T) {
 			adc, err := ad.New(context.Background(), tc.backend, hostname,
 				ad.WithCacheDir(cachedir), ad.WithRunDir(rundir), ad.WithoutKerberos(),
 				ad.WithGPOListCmd(mockGPOListCmd(t, tc.gpoListArgs...)),
-				ad.WithVersionID(tc.versionID))
+				ad.WithVersionID(tc.versionID),
+				ad.WithDCLocatorOptions(dc.WithResolver(&mockResolver{})),
+				ad.WithComputerAttributes(tc.computerAttributes))
 			require.NoError(t, err, "Setup: cannot create ad object")
 
 			if tc.turnKrb5CCCacheRO {
@@ -598,14 +666,18 @@ func TestGetPolicies(t *testing.T) {
 			}
 
 			entries, err := adc.GetPolicies(context.Background(), tc.objectName, tc.objectClass, krb5CCName)
+			lastOnlineUpdate := filepath.Join(adc.PoliciesCacheDir(), tc.objectName, "last-online-update")
 			if tc.wantErr {
 				require.Error(t, err, "GetPolicies should have errored out")
+				require.NoFileExists(t, lastOnlineUpdate, "GetPolicies should not record an online update when failing")
 				return
 			}
 			require.NoError(t, err, "GetPolicies should return no error")
+			require.FileExists(t, lastOnlineUpdate, "GetPolicies should record the online update")
 
 			// Compare GPOs
 			require.Equal(t, tc.want.GPOs, entries.GPOs, "GetPolicies returns expected GPO entries in correct order")
+			require.Equal(t, tc.want.ComputerAttributes, entries.ComputerAttributes, "GetPolicies returns expected computer attributes")
 
 			// Compare assets
 			uncompressedAssets := t.TempDir()
@@ -629,12 +701,17 @@ func TestGetPoliciesOffline(t *testing.T) {
 	require.NoError(t, err, "Setup: failed to get hostname")
 
 	tests := map[string]struct {
-		domainToCache string
-		backend       mock.Backend
-		gpoListArgs   []string
-
-		wantAssets bool
-		wantErr    bool
+		domainToCache    string
+		backend          mock.Backend
+		gpoListArgs      []string
+		maxCacheAge      time.Duration
+		staleCacheAction ad.StaleCacheAction
+		cacheAge         time.Duration
+
+		wantAssets   bool
+		wantNoGPOs   bool
+		wantErr      bool
+		wantErrStale bool
 	}{
 		"Offline, get from cache, gpo only": {
 			domainToCache: "gpoonly.com",
@@ -671,6 +748,27 @@ func TestGetPoliciesOffline(t *testing.T) {
 			wantAssets:  true,
 		},
 
+		"Offline, cache within maximum age": {
+			domainToCache: "gpoonly.com",
+			backend: mock.Backend{
+				Dom:    "gpoonly.com",
+				Online: false,
+			},
+			maxCacheAge: 72 * time.Hour,
+			cacheAge:    24 * time.Hour,
+		},
+		"Offline, cache over maximum age reverts policies with revert action": {
+			domainToCache: "assetsandgpo.com",
+			backend: mock.Backend{
+				Dom:    "assetsandgpo.com",
+				Online: false,
+			},
+			maxCacheAge:      72 * time.Hour,
+			staleCacheAction: ad.StaleCacheRevert,
+			cacheAge:         96 * time.Hour,
+			wantNoGPOs:       true,
+		},
+
 		"Error on SSSD reports online, but we are actually offline when fetching gpo list, even with a cache": {
 			domainToCache: "assetsandgpo.com",
 			backend: mock.Backend{
@@ -688,6 +786,29 @@ func TestGetPoliciesOffline(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		"Error offline with cache over maximum age": {
+			domainToCache: "gpoonly.com",
+			backend: mock.Backend{
+				Dom:    "gpoonly.com",
+				Online: false,
+			},
+			maxCacheAge:  72 * time.Hour,
+			cacheAge:     96 * time.Hour,
+			wantErr:      true,
+			wantErrStale: true,
+		},
+		"Error offline with cache over maximum age with explicit fail action": {
+			domainToCache: "gpoonly.com",
+			backend: mock.Backend{
+				Dom:    "gpoonly.com",
+				Online: false,
+			},
+			maxCacheAge:      72 * time.Hour,
+			staleCacheAction: ad.StaleCacheFail,
+			cacheAge:         96 * time.Hour,
+			wantErr:          true,
+			wantErrStale:     true,
+		},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -700,7 +821,8 @@ func TestGetPoliciesOffline(t *testing.T) {
 			cachedir, rundir := t.TempDir(), t.TempDir()
 			adc, err := ad.New(context.Background(), tc.backend, hostname,
 				ad.WithCacheDir(cachedir), ad.WithRunDir(rundir), ad.WithoutKerberos(),
-				ad.WithGPOListCmd(mockGPOListCmd(t, tc.gpoListArgs...)))
+				ad.WithGPOListCmd(mockGPOListCmd(t, tc.gpoListArgs...)),
+				ad.WithMaxCacheAge(tc.maxCacheAge), ad.WithStaleCacheAction(tc.staleCacheAction))
 			require.NoError(t, err, "Setup: cannot create ad object")
 
 			objectName := fmt.Sprintf("useroffline@%s", strings.ToUpper(tc.backend.Dom))
@@ -730,15 +852,33 @@ func TestGetPoliciesOffline(t *testing.T) {
 				// Save it and copy to finale destination
 				err = initialPolicies.Save(filepath.Join(adc.PoliciesCacheDir(), objectName))
 				require.NoError(t, err, "Setup: cannot create policy cache file for finale user")
+
+				// Age the cache by moving back its last online update time
+				lastOnlineUpdate := filepath.Join(cachedir, "policies", objectNameForCache, "last-online-update")
+				require.FileExists(t, lastOnlineUpdate, "Setup: online update time should have been recorded")
+				require.NoError(t, shutil.CopyFile(lastOnlineUpdate, filepath.Join(adc.PoliciesCacheDir(), objectName, "last-online-update"), false),
+					"Setup: cannot copy online update time for finale user")
+				updateTime := time.Now().Add(-tc.cacheAge)
+				require.NoError(t, os.Chtimes(filepath.Join(adc.PoliciesCacheDir(), objectName, "last-online-update"), updateTime, updateTime),
+					"Setup: cannot change online update time")
 			}
 
 			entries, err := adc.GetPolicies(context.Background(), objectName, objectClass, krb5CCName)
 			if tc.wantErr {
 				require.NotNil(t, err, "GetPolicies should have errored out")
+				if tc.wantErrStale {
+					require.ErrorIs(t, err, ad.ErrStaleCache, "GetPolicies should have errored out on stale cache")
+				}
 				return
 			}
 			require.NoError(t, err, "GetPolicies should return no error")
 
+			if tc.wantNoGPOs {
+				require.Empty(t, entries.GPOs, "GetPolicies should return no GPO when reverting a stale cache")
+				require.True(t, entries.KeepCache, "GetPolicies should keep the stale cache when reverting it")
+				return
+			}
+
 			// Ensure we only have one policy
 			require.NotEqual(t, 0, len(entries.GPOs), "GetPolicies should return at least one GPO list when not failing")
 
@@ -921,6 +1061,250 @@ func TestGetPoliciesWorkflows(t *testing.T) {
 	}
 }
 
+func TestGetPoliciesWithMachineKrb5CCName(t *testing.T) {
+	t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname")
+
+	cachedir, rundir := t.TempDir(), t.TempDir()
+	machineKrb5CCPath := filepath.Join(t.TempDir(), "adsys", "machine_ccache")
+
+	backend := mock.Backend{
+		Dom:                "assetsandgpo.com",
+		ServURL:            "UNUSED:1636",
+		HostKrb5CCNamePath: filepath.Join(t.TempDir(), "host_ccache"),
+		Online:             true,
+	}
+	require.NoError(t, os.WriteFile(backend.HostKrb5CCNamePath, []byte("KRB5 machine ticket content"), 0600), "Setup: can't create host ticket")
+	userKrb5CCName := setKrb5CC(t, "bob")
+	userKrb5CCStat, err := os.Stat(userKrb5CCName)
+	require.NoError(t, err, "Setup: can't stat user ticket")
+
+	// The gpo list command checks that the machine policies are fetched with the configured ticket cache.
+	gpoListCmd := append([]string{"env", "ADSYS_TESTS_WANT_MACHINE_KRB5CCNAME=" + machineKrb5CCPath},
+		mockGPOListCmd(t, "assetsandgpo.com", fmt.Sprintf("bob:standard::%s:standard", hostname))...)
+	adc, err := ad.New(context.Background(), backend, hostname,
+		ad.WithCacheDir(cachedir), ad.WithRunDir(rundir), ad.WithoutKerberos(),
+		ad.WithMachineKrb5CCName("FILE:"+machineKrb5CCPath),
+		ad.WithGPOListCmd(gpoListCmd))
+	require.NoError(t, err, "Setup: cannot create ad object")
+	require.Equal(t, machineKrb5CCPath, adc.MachineKrb5CCPath(), "MachineKrb5CCPath should return the configured path without its type")
+
+	_, err = adc.GetPolicies(context.Background(), "bob@ASSETSANDGPO.COM", ad.UserObject, userKrb5CCName)
+	require.NoError(t, err, "Setup: GetPolicies for user should return no error")
+	_, err = adc.GetPolicies(context.Background(), hostname, ad.ComputerObject, "")
+	require.NoError(t, err, "GetPolicies should return no error")
+
+	got, err := os.ReadFile(machineKrb5CCPath)
+	require.NoError(t, err, "Configured machine ticket cache should exist")
+	require.Equal(t, "KRB5 machine ticket content", string(got), "Configured machine ticket cache should be a copy of the host ticket")
+	require.NoFileExists(t, filepath.Join(rundir, "krb5cc", hostname), "Default machine ticket cache should not be created")
+
+	// The user ticket, and the daemon copy of it, are untouched.
+	got, err = os.ReadFile(filepath.Join(rundir, "krb5cc", "bob@ASSETSANDGPO.COM"))
+	require.NoError(t, err, "User ticket copy should still exist")
+	require.Equal(t, "KRB5 Ticket file content", string(got), "User ticket copy should not be replaced by the machine ticket")
+	got, err = os.ReadFile(userKrb5CCName)
+	require.NoError(t, err, "User ticket should still exist")
+	require.Equal(t, "KRB5 Ticket file content", string(got), "User ticket content should be untouched")
+	stat, err := os.Stat(userKrb5CCName)
+	require.NoError(t, err, "User ticket should still exist")
+	require.Equal(t, userKrb5CCStat.ModTime(), stat.ModTime(), "User ticket should not be modified")
+}
+
+func TestGetPoliciesWithSMBProtocols(t *testing.T) {
+	t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname")
+
+	tests := map[string]struct {
+		smbMinProtocol string
+		smbMaxProtocol string
+		setterErr      bool
+
+		wantMinProtocol string
+		wantMaxProtocol string
+		wantErr         bool
+	}{
+		"Defaults to SMB2 minimum protocol":  {wantMinProtocol: "SMB2"},
+		"Configured minimum protocol":        {smbMinProtocol: "SMB3", wantMinProtocol: "SMB3"},
+		"Configured maximum protocol":        {smbMaxProtocol: "SMB3_11", wantMinProtocol: "SMB2", wantMaxProtocol: "SMB3_11"},
+		"Configured minimum and maximum":     {smbMinProtocol: "smb3_02", smbMaxProtocol: "SMB3", wantMinProtocol: "SMB3_02", wantMaxProtocol: "SMB3"},
+		"Error on setting the SMB protocols": {setterErr: true, wantMinProtocol: "SMB2", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotMinProtocol, gotMaxProtocol string
+			setter := func(minProtocol, maxProtocol string) error {
+				gotMinProtocol, gotMaxProtocol = minProtocol, maxProtocol
+				if tc.setterErr {
+					return errors.New("setter error")
+				}
+				return nil
+			}
+
+			opts := []ad.Option{
+				ad.WithCacheDir(t.TempDir()), ad.WithRunDir(t.TempDir()), ad.WithoutKerberos(),
+				ad.WithGPOListCmd(mockGPOListCmd(t, "assetsandgpo.com", fmt.Sprintf("%s:standard", hostname))),
+				ad.WithSMBProtocolsSetter(setter),
+			}
+			if tc.smbMinProtocol != "" || tc.smbMaxProtocol != "" {
+				opts = append(opts, ad.WithSMBProtocols(tc.smbMinProtocol, tc.smbMaxProtocol))
+			}
+			backend := mock.Backend{
+				Dom:                "assetsandgpo.com",
+				ServURL:            "UNUSED:1636",
+				HostKrb5CCNamePath: setKrb5CC(t, hostname),
+				Online:             true,
+			}
+			adc, err := ad.New(context.Background(), backend, hostname, opts...)
+			require.NoError(t, err, "Setup: cannot create ad object")
+
+			_, err = adc.GetPolicies(context.Background(), hostname, ad.ComputerObject, "")
+			if tc.wantErr {
+				require.Error(t, err, "GetPolicies should have failed but didn't")
+			} else {
+				require.NoError(t, err, "GetPolicies should return no error")
+			}
+
+			require.Equal(t, tc.wantMinProtocol, gotMinProtocol, "Minimum SMB protocol passed to the SMB client setup is not the expected one")
+			require.Equal(t, tc.wantMaxProtocol, gotMaxProtocol, "Maximum SMB protocol passed to the SMB client setup is not the expected one")
+		})
+	}
+}
+
+func TestGetPoliciesDiscoversIPv6OnlyController(t *testing.T) {
+	t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname")
+
+	// The only domain controller listens on IPv6 only, and is only advertised with AAAA records.
+	lis, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 is not available: %v", err)
+	}
+	defer lis.Close()
+	connected := make(chan struct{}, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+		connected <- struct{}{}
+	}()
+	resolver := &mockResolver{
+		srv: []*net.SRV{{Target: "dc1.gpoonly.com.", Port: uint16(lis.Addr().(*net.TCPAddr).Port)}},
+		ips: map[string][]string{"dc1.gpoonly.com": {"::1"}},
+	}
+
+	backend := mock.Backend{
+		Dom:                "gpoonly.com",
+		HostKrb5CCNamePath: filepath.Join(t.TempDir(), "host_ccache"),
+		Online:             true,
+		ErrServerFQDN:      backends.ErrNoActiveServer,
+	}
+	testutils.CreatePath(t, backend.HostKrb5CCNamePath)
+
+	// The gpo list command checks that it is called with the discovered domain controller.
+	gpoListCmd := append([]string{"env", "ADSYS_TESTS_WANT_SERVER=dc1.gpoonly.com"},
+		mockGPOListCmd(t, "gpoonly.com", "bob:standard")...)
+	adc, err := ad.New(context.Background(), backend, hostname,
+		ad.WithCacheDir(t.TempDir()), ad.WithRunDir(t.TempDir()), ad.WithoutKerberos(),
+		ad.WithGPOListCmd(gpoListCmd),
+		ad.WithDCLocatorOptions(dc.WithResolver(resolver)))
+	require.NoError(t, err, "Setup: cannot create ad object")
+
+	_, err = adc.GetPolicies(context.Background(), "bob@GPOONLY.COM", ad.UserObject, setKrb5CC(t, "bob"))
+	require.NoError(t, err, "GetPolicies should return no error")
+
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetPolicies should have connected to the IPv6 address of the domain controller")
+	}
+}
+
+func TestGetPoliciesMarksDownFailingController(t *testing.T) {
+	t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname")
+
+	// dc2 is the only other controller of the domain, to which the GPOs are fetched from once dc1 is marked down.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Setup: can't listen for dc2")
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := uint16(lis.Addr().(*net.TCPAddr).Port)
+	resolver := &mockResolver{
+		srv: []*net.SRV{{Target: "dc1.gpoonly.com.", Port: port}, {Target: "dc2.gpoonly.com.", Port: port}},
+		ips: map[string][]string{"dc2.gpoonly.com": {"127.0.0.1"}},
+	}
+
+	var mu sync.Mutex
+	var metrics []string
+	collector := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		metrics = append(metrics, string(body))
+	}))
+	defer collector.Close()
+
+	backend := mock.Backend{
+		Dom:                "gpoonly.com",
+		ServURL:            "dc1.gpoonly.com",
+		HostKrb5CCNamePath: filepath.Join(t.TempDir(), "host_ccache"),
+		Online:             true,
+	}
+	testutils.CreatePath(t, backend.HostKrb5CCNamePath)
+
+	gpoListCmd := append([]string{"env", "ADSYS_TESTS_UNREACHABLE_SERVER=dc1.gpoonly.com"},
+		mockGPOListCmd(t, "gpoonly.com", "bob:standard")...)
+	adc, err := ad.New(context.Background(), backend, hostname,
+		ad.WithCacheDir(t.TempDir()), ad.WithRunDir(t.TempDir()), ad.WithoutKerberos(),
+		ad.WithGPOListCmd(gpoListCmd),
+		ad.WithDCCircuitBreaker(2, time.Hour),
+		ad.WithOTLPEndpoint(collector.URL),
+		ad.WithDCLocatorOptions(dc.WithResolver(resolver)))
+	require.NoError(t, err, "Setup: cannot create ad object")
+
+	for range 2 {
+		_, err = adc.GetPolicies(context.Background(), "bob@GPOONLY.COM", ad.UserObject, setKrb5CC(t, "bob"))
+		require.Error(t, err, "GetPolicies should fail while dc1 is not marked down")
+	}
+
+	_, err = adc.GetPolicies(context.Background(), "bob@GPOONLY.COM", ad.UserObject, setKrb5CC(t, "bob"))
+	require.NoError(t, err, "GetPolicies should fetch the GPOs from dc2 once dc1 is marked down")
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, metrics, 3, "The state of the domain controllers should be exported after each connection")
+	for _, m := range metrics {
+		require.Contains(t, m, `"adsys.dc.down"`, "Whether dc1 is marked down should be exported")
+		require.Contains(t, m, `"adsys.dc.consecutive_failures"`, "The consecutive failures of dc1 should be exported")
+		require.Contains(t, m, `"stringValue":"dc1.gpoonly.com"`, "dc1 should be exported")
+	}
+	require.Contains(t, metrics[0], `"asInt":"0"`, "dc1 should not be marked down after its first failure")
+	require.NotContains(t, metrics[0], `"asInt":"2"`, "dc1 should have failed once after its first failure")
+	require.Contains(t, metrics[1], `"asInt":"2"`, "dc1 should have failed twice after its second failure")
+	require.NotContains(t, metrics[1], `"asInt":"0"`, "dc1 should be marked down after its second failure")
+}
+
 func TestGetPoliciesConcurrently(t *testing.T) {
 	t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
 
@@ -1059,6 +1443,309 @@ func TestGetPoliciesConcurrently(t *testing.T) {
 	}
 }
 
+func TestGPOVersionDrift(t *testing.T) {
+	t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname")
+
+	tests := map[string]struct {
+		objectName  string
+		objectClass ad.ObjectClass
+		gpoListArgs []string
+		existing    map[string]string
+		offline     bool
+
+		want    []ad.GPODrift
+		wantErr bool
+	}{
+		"GPO not cached": {
+			gpoListArgs: []string{"gpoonly.com", "bob:standard"},
+			want:        []ad.GPODrift{{Name: "standard-name", ID: "standard", CurrentVersion: 1000}},
+		},
+		"Cached GPO is up to date": {
+			gpoListArgs: []string{"gpoonly.com", "bob:standard"},
+			existing:    map[string]string{"Policies/standard": "testdata/AD/SYSVOL/gpoonly.com/Policies/standard"},
+			want:        []ad.GPODrift{{Name: "standard-name", ID: "standard", Cached: true, CachedVersion: 1000, CurrentVersion: 1000}},
+		},
+		"Cached GPO is older": {
+			gpoListArgs: []string{"gpoonly.com", "bob:standard"},
+			existing:    map[string]string{"Policies/standard": "testdata/AD/SYSVOL/gpoonly.com/Policies/standard-old"},
+			want:        []ad.GPODrift{{Name: "standard-name", ID: "standard", Cached: true, CachedVersion: 100, CurrentVersion: 1000}},
+		},
+		"Multiple GPOs are returned in order": {
+			gpoListArgs: []string{"gpoonly.com", "bob:one-value::bob:standard"},
+			existing:    map[string]string{"Policies/standard": "testdata/AD/SYSVOL/gpoonly.com/Policies/standard-old"},
+			want: []ad.GPODrift{
+				{Name: "one-value-name", ID: "one-value", CurrentVersion: 1000},
+				{Name: "standard-name", ID: "standard", Cached: true, CachedVersion: 100, CurrentVersion: 1000},
+			},
+		},
+		"Machine GPOs": {
+			objectName:  hostname,
+			objectClass: ad.ComputerObject,
+			gpoListArgs: []string{"gpoonly.com", fmt.Sprintf("%s:standard", hostname)},
+			want:        []ad.GPODrift{{Name: "standard-name", ID: "standard", CurrentVersion: 1000}},
+		},
+		"No GPO": {
+			gpoListArgs: []string{"gpoonly.com", "alice:standard"},
+		},
+
+		// Error cases
+		"Error when offline":                         {gpoListArgs: []string{"gpoonly.com", "bob:standard"}, offline: true, wantErr: true},
+		"Error on GPO without GPT.INI on controller": {gpoListArgs: []string{"gpoonly.com", "bob:no-gpt-ini"}, wantErr: true},
+		"Error on GPO list failure":                  {gpoListArgs: []string{"-Exit2-", "bob:standard"}, wantErr: true},
+		"Error on user without domain":               {objectName: "bob", gpoListArgs: []string{"gpoonly.com", "bob:standard"}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+			if tc.objectName == "" {
+				tc.objectName = "bob@GPOONLY.COM"
+			}
+			if tc.objectClass == "" {
+				tc.objectClass = ad.UserObject
+			}
+
+			backend := mock.Backend{
+				Dom:                "gpoonly.com",
+				ServURL:            "myserver.gpoonly.com",
+				HostKrb5CCNamePath: filepath.Join(t.TempDir(), "host_ccache"),
+				Online:             !tc.offline,
+			}
+			testutils.CreatePath(t, backend.HostKrb5CCNamePath)
+
+			var krb5CCName string
+			if tc.objectClass == ad.UserObject {
+				krb5CCName = setKrb5CC(t, "kbr5cc_adsys_tests_bob")
+			}
+
+			adc, err := ad.New(context.Background(), backend, hostname,
+				ad.WithCacheDir(t.TempDir()), ad.WithRunDir(t.TempDir()), ad.WithoutKerberos(),
+				ad.WithGPOListCmd(mockGPOListCmd(t, tc.gpoListArgs...)))
+			require.NoError(t, err, "Setup: cannot create ad object")
+
+			for n, src := range tc.existing {
+				testutils.Copy(t, src, filepath.Join(adc.SysvolCacheDir(), n))
+			}
+
+			got, err := adc.GPOVersionDrift(context.Background(), tc.objectName, tc.objectClass, krb5CCName)
+			if tc.wantErr {
+				require.Error(t, err, "GPOVersionDrift should have errored out")
+				return
+			}
+			require.NoError(t, err, "GPOVersionDrift should return no error")
+			require.Equal(t, tc.want, got, "GPOVersionDrift should return the expected versions in order")
+
+			// The cache is never changed
+			for n, src := range tc.existing {
+				testutils.CompareTreesWithFiltering(t, filepath.Join(adc.SysvolCacheDir(), n), src, false)
+			}
+			cached, err := os.ReadDir(filepath.Join(adc.SysvolCacheDir(), "Policies"))
+			require.NoError(t, err, "Teardown: can't read GPO cache directory")
+			require.Len(t, cached, len(tc.existing), "GPOVersionDrift should not download any GPO")
+		})
+	}
+}
+
+func TestFetchDryRun(t *testing.T) {
+	t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname")
+
+	standardSize := gpoSize(t, "standard")
+	oneValueSize := gpoSize(t, "one-value")
+
+	tests := map[string]struct {
+		objectName  string
+		objectClass ad.ObjectClass
+		gpoListArgs []string
+		existing    map[string]string
+		offline     bool
+
+		want    []ad.GPOFetch
+		wantErr bool
+	}{
+		"GPO not cached is downloaded": {
+			gpoListArgs: []string{"gpoonly.com", "bob:standard"},
+			want:        []ad.GPOFetch{{Name: "standard-name", ID: "standard", Version: 1000, Size: standardSize, Download: true}},
+		},
+		"Cached GPO up to date is not downloaded": {
+			gpoListArgs: []string{"gpoonly.com", "bob:standard"},
+			existing:    map[string]string{"Policies/standard": "testdata/AD/SYSVOL/gpoonly.com/Policies/standard"},
+			want:        []ad.GPOFetch{{Name: "standard-name", ID: "standard", Version: 1000, Size: standardSize}},
+		},
+		"Cached GPO older is downloaded": {
+			gpoListArgs: []string{"gpoonly.com", "bob:standard"},
+			existing:    map[string]string{"Policies/standard": "testdata/AD/SYSVOL/gpoonly.com/Policies/standard-old"},
+			want:        []ad.GPOFetch{{Name: "standard-name", ID: "standard", Version: 1000, Size: standardSize, Download: true}},
+		},
+		"Multiple GPOs are returned in order": {
+			gpoListArgs: []string{"gpoonly.com", "bob:one-value::bob:standard"},
+			existing:    map[string]string{"Policies/standard": "testdata/AD/SYSVOL/gpoonly.com/Policies/standard"},
+			want: []ad.GPOFetch{
+				{Name: "one-value-name", ID: "one-value", Version: 1000, Size: oneValueSize, Download: true},
+				{Name: "standard-name", ID: "standard", Version: 1000, Size: standardSize},
+			},
+		},
+		"Machine GPOs": {
+			objectName:  hostname,
+			objectClass: ad.ComputerObject,
+			gpoListArgs: []string{"gpoonly.com", fmt.Sprintf("%s:standard", hostname)},
+			want:        []ad.GPOFetch{{Name: "standard-name", ID: "standard", Version: 1000, Size: standardSize, Download: true}},
+		},
+		"No GPO": {
+			gpoListArgs: []string{"gpoonly.com", "alice:standard"},
+		},
+
+		// Error cases
+		"Error when offline":                         {gpoListArgs: []string{"gpoonly.com", "bob:standard"}, offline: true, wantErr: true},
+		"Error on GPO without GPT.INI on controller": {gpoListArgs: []string{"gpoonly.com", "bob:no-gpt-ini"}, wantErr: true},
+		"Error on GPO list failure":                  {gpoListArgs: []string{"-Exit2-", "bob:standard"}, wantErr: true},
+		"Error on user without domain":               {objectName: "bob", gpoListArgs: []string{"gpoonly.com", "bob:standard"}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+			if tc.objectName == "" {
+				tc.objectName = "bob@GPOONLY.COM"
+			}
+			if tc.objectClass == "" {
+				tc.objectClass = ad.UserObject
+			}
+
+			backend := mock.Backend{
+				Dom:                "gpoonly.com",
+				ServURL:            "myserver.gpoonly.com",
+				HostKrb5CCNamePath: filepath.Join(t.TempDir(), "host_ccache"),
+				Online:             !tc.offline,
+			}
+			testutils.CreatePath(t, backend.HostKrb5CCNamePath)
+
+			var krb5CCName string
+			if tc.objectClass == ad.UserObject {
+				krb5CCName = setKrb5CC(t, "kbr5cc_adsys_tests_bob")
+			}
+
+			adc, err := ad.New(context.Background(), backend, hostname,
+				ad.WithCacheDir(t.TempDir()), ad.WithRunDir(t.TempDir()), ad.WithoutKerberos(),
+				ad.WithGPOListCmd(mockGPOListCmd(t, tc.gpoListArgs...)))
+			require.NoError(t, err, "Setup: cannot create ad object")
+
+			for n, src := range tc.existing {
+				testutils.Copy(t, src, filepath.Join(adc.SysvolCacheDir(), n))
+			}
+
+			got, err := adc.FetchDryRun(context.Background(), tc.objectName, tc.objectClass, krb5CCName)
+			if tc.wantErr {
+				require.Error(t, err, "FetchDryRun should have errored out")
+				return
+			}
+			require.NoError(t, err, "FetchDryRun should return no error")
+			require.Equal(t, tc.want, got, "FetchDryRun should return the expected GPOs in order")
+
+			// The cache is never changed
+			for n, src := range tc.existing {
+				testutils.CompareTreesWithFiltering(t, filepath.Join(adc.SysvolCacheDir(), n), src, false)
+			}
+			cached, err := os.ReadDir(filepath.Join(adc.SysvolCacheDir(), "Policies"))
+			require.NoError(t, err, "Teardown: can't read GPO cache directory")
+			require.Len(t, cached, len(tc.existing), "FetchDryRun should not download any GPO")
+		})
+	}
+}
+
+func TestSimulatePolicies(t *testing.T) {
+	t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname")
+
+	const ou = "OU=RnD,DC=gpoonly,DC=com"
+
+	tests := map[string]struct {
+		computerName string
+		ou           string
+		gpoListArgs  []string
+		offline      bool
+
+		wantGPOs []string
+		wantErr  bool
+	}{
+		"GPOs of current computer in another OU": {
+			gpoListArgs: []string{"gpoonly.com", fmt.Sprintf("%s:standard::%s/%s:one-value::%s/%s:standard", hostname, hostname, ou, hostname, ou)},
+			wantGPOs:    []string{"one-value-name", "standard-name"},
+		},
+		"GPOs of another computer in another OU": {
+			computerName: "othercomputer",
+			gpoListArgs:  []string{"gpoonly.com", fmt.Sprintf("%s/%s:standard::othercomputer/%s:machine-only", hostname, ou, ou)},
+			wantGPOs:     []string{"machine-only-name"},
+		},
+		"No GPO in OU": {
+			gpoListArgs: []string{"gpoonly.com", fmt.Sprintf("%s:standard", hostname)},
+		},
+
+		// Error cases
+		"Error on missing OU":       {ou: "-", gpoListArgs: []string{"gpoonly.com", fmt.Sprintf("%s:standard", hostname)}, wantErr: true},
+		"Error when offline":        {gpoListArgs: []string{"gpoonly.com", fmt.Sprintf("%s/%s:standard", hostname, ou)}, offline: true, wantErr: true},
+		"Error on GPO list failure": {gpoListArgs: []string{"-Exit2-", fmt.Sprintf("%s/%s:standard", hostname, ou)}, wantErr: true},
+		"Error on GPO without GPT.INI on controller": {
+			gpoListArgs: []string{"gpoonly.com", fmt.Sprintf("%s/%s:no-gpt-ini", hostname, ou)}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+			if tc.computerName == "" {
+				tc.computerName = hostname
+			}
+			switch tc.ou {
+			case "":
+				tc.ou = ou
+			case "-":
+				tc.ou = ""
+			}
+
+			backend := mock.Backend{
+				Dom:                "gpoonly.com",
+				ServURL:            "myserver.gpoonly.com",
+				HostKrb5CCNamePath: filepath.Join(t.TempDir(), "host_ccache"),
+				Online:             !tc.offline,
+			}
+			testutils.CreatePath(t, backend.HostKrb5CCNamePath)
+
+			cacheDir := t.TempDir()
+			adc, err := ad.New(context.Background(), backend, hostname,
+				ad.WithCacheDir(cacheDir), ad.WithRunDir(t.TempDir()), ad.WithoutKerberos(),
+				ad.WithGPOListCmd(mockGPOListCmd(t, tc.gpoListArgs...)))
+			require.NoError(t, err, "Setup: cannot create ad object")
+
+			pols, err := adc.SimulatePolicies(context.Background(), tc.computerName, tc.ou)
+			if tc.wantErr {
+				require.Error(t, err, "SimulatePolicies should have errored out")
+				return
+			}
+			require.NoError(t, err, "SimulatePolicies should return no error")
+			defer pols.Close()
+
+			var got []string
+			for _, g := range pols.GPOs {
+				got = append(got, g.Name)
+			}
+			require.Equal(t, tc.wantGPOs, got, "SimulatePolicies should return the GPOs of the OU in order")
+
+			// The simulation is not recorded as an update of the computer policies.
+			require.NoDirExists(t, filepath.Join(cacheDir, policies.PoliciesCacheBaseName, tc.computerName), "SimulatePolicies should not cache the policies")
+		})
+	}
+}
+
 func TestListUsers(t *testing.T) {
 	t.Parallel()
 
@@ -1202,6 +1889,98 @@ func TestListUsers(t *testing.T) {
 	}
 }
 
+func TestTicketStatus(t *testing.T) {
+	t.Parallel()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname for tests.")
+
+	tests := map[string]struct {
+		isComputer      bool
+		noTicket        bool
+		danglingSymlink bool
+		expiry          time.Duration
+		ticketExpiryErr error
+		errKrb5CCName   bool
+		noMachineTicket bool
+
+		wantState ad.TicketState
+		wantErr   bool
+	}{
+		"Valid user ticket":    {expiry: time.Hour, wantState: ad.TicketValid},
+		"Expired user ticket":  {expiry: -time.Hour, wantState: ad.TicketExpired},
+		"No user ticket":       {noTicket: true, wantState: ad.TicketAbsent},
+		"Dangling user ticket": {danglingSymlink: true, wantState: ad.TicketAbsent},
+		"No TGT in user ticket cache": {
+			ticketExpiryErr: ad.ErrTicketNotPresent, wantState: ad.TicketAbsent},
+
+		"Valid machine ticket":   {isComputer: true, expiry: time.Hour, wantState: ad.TicketValid},
+		"Expired machine ticket": {isComputer: true, expiry: -time.Hour, wantState: ad.TicketExpired},
+		"No machine ticket":      {isComputer: true, noMachineTicket: true, wantState: ad.TicketAbsent},
+
+		// Error cases
+		"Error on machine ticket path lookup failure": {isComputer: true, errKrb5CCName: true, wantErr: true},
+		"Error on ticket cache read failure":          {ticketExpiryErr: errors.New("krb5 error"), wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cachedir, rundir := t.TempDir(), t.TempDir()
+
+			objectName := "bob@GPOONLY.COM"
+			objectClass := ad.UserObject
+			if tc.isComputer {
+				objectName = hostname
+				objectClass = ad.ComputerObject
+			}
+
+			ticketPath := setKrb5CC(t, objectName)
+			if tc.danglingSymlink {
+				require.NoError(t, os.Remove(ticketPath), "Setup: can’t remove krb5cc symlink target")
+			}
+			backend := mock.Backend{Dom: "gpoonly.com", ServURL: "myserver.gpoonly.com", HostKrb5CCNamePath: ticketPath, ErrKrb5CCName: tc.errKrb5CCName}
+			if tc.noMachineTicket {
+				backend.HostKrb5CCNamePath = filepath.Join(t.TempDir(), "non-existent")
+			}
+
+			expiry := time.Now().Add(tc.expiry).Truncate(time.Second)
+			adc, err := ad.New(context.Background(), backend, hostname,
+				ad.WithCacheDir(cachedir), ad.WithRunDir(rundir),
+				ad.WithTicketExpiry(func(path string) (string, time.Time, error) {
+					require.Equal(t, ticketPath, path, "Ticket cache accessor should be called with the tracked ticket path")
+					if tc.ticketExpiryErr != nil {
+						return "", time.Time{}, tc.ticketExpiryErr
+					}
+					return "bob@GPOONLY.COM", expiry, nil
+				}))
+			require.NoError(t, err, "Setup: New should return no error")
+
+			if !tc.isComputer && !tc.noTicket {
+				require.NoError(t, os.Symlink(ticketPath, filepath.Join(adc.Krb5CacheDir(), "tracking", objectName)),
+					"Setup: symlink creation of krb5cc failed")
+			}
+
+			got, err := adc.TicketStatus(context.Background(), objectName, objectClass)
+			if tc.wantErr {
+				require.Error(t, err, "TicketStatus should return an error and didn't")
+				return
+			}
+			require.NoError(t, err, "TicketStatus should return no error")
+
+			require.Equal(t, tc.wantState, got.State, "TicketStatus should return the expected ticket state")
+			if tc.wantState == ad.TicketAbsent {
+				require.Equal(t, ad.TicketInfo{State: ad.TicketAbsent}, got, "TicketStatus should not return any ticket details")
+				return
+			}
+			require.Equal(t, ticketPath, got.Path, "TicketStatus should return the ticket path")
+			require.Equal(t, "bob@GPOONLY.COM", got.Principal, "TicketStatus should return the ticket principal")
+			require.Equal(t, expiry, got.Expiry, "TicketStatus should return the ticket expiry")
+		})
+	}
+}
+
 func TestGetInfo(t *testing.T) {
 	t.Parallel()
 
@@ -1298,6 +2077,25 @@ func TestNormalizeTargetName(t *testing.T) {
 	}
 }
 
+// gpoSize returns the total size of the files of the GPO id in the gpoonly.com SYSVOL.
+func gpoSize(t *testing.T, id string) (size int64) {
+	t.Helper()
+
+	err := filepath.WalkDir(filepath.Join("testdata", "AD", "SYSVOL", "gpoonly.com", "Policies", id), func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	require.NoError(t, err, "Setup: can't compute GPO size")
+	return size
+}
+
 func TestMockGPOList(_ *testing.T) {
 	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
 		return
@@ -1333,9 +2131,45 @@ func TestMockGPOList(_ *testing.T) {
 	// Get Domain
 	domain := args[0]
 
+	// Attributes of the computer object, instead of its GPOs.
+	for i, a := range args[:len(args)-1] {
+		if a != "--attributes" {
+			continue
+		}
+		values := map[string]string{"description": "Kiosk of the Paris office", "location": "paris"}
+		for _, name := range strings.Split(args[i+1], ",") {
+			if name == "unreadable" {
+				fmt.Fprint(os.Stderr, "Insufficient access rights to read attributes")
+				os.Exit(1)
+			}
+			if v, ok := values[strings.ToLower(name)]; ok {
+				fmt.Fprintf(os.Stdout, "%s\t%s\n", name, v)
+			}
+		}
+		return
+	}
+
 	// as in gpolist, we split on the @ if any
 	objectName := args[len(args)-1]
+	if want := os.Getenv("ADSYS_TESTS_WANT_MACHINE_KRB5CCNAME"); want != "" && !strings.Contains(objectName, "@") && krb5File != want {
+		fmt.Fprintf(os.Stderr, "Expecting machine KRB5CCNAME to be %s, got %s", want, krb5File)
+		os.Exit(1)
+	}
+	if want := os.Getenv("ADSYS_TESTS_WANT_SERVER"); want != "" && args[len(args)-2] != want {
+		fmt.Fprintf(os.Stderr, "Expecting domain controller to be %s, got %s", want, args[len(args)-2])
+		os.Exit(1)
+	}
+	if os.Getenv("ADSYS_TESTS_UNREACHABLE_SERVER") == args[len(args)-2] {
+		fmt.Fprintf(os.Stderr, "Can't connect to domain controller %s", args[len(args)-2])
+		os.Exit(2)
+	}
 	objectName = strings.Split(objectName, "@")[0]
+	// GPOs listed as if the object was located in another organizational unit are in the form: "object/ou:GPO".
+	for i, a := range args[:len(args)-1] {
+		if a == "--ou" {
+			objectName = fmt.Sprintf("%s/%s", objectName, args[i+1])
+		}
+	}
 
 	var gpos []string
 
@@ -1353,6 +2187,34 @@ func TestMockGPOList(_ *testing.T) {
 	}
 }
 
+// mockResolver only returns the records it knows about, AAAA ones for IPv6 addresses and A ones for IPv4.
+type mockResolver struct {
+	srv []*net.SRV
+	ips map[string][]string
+}
+
+func (r *mockResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	if len(r.srv) == 0 {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return name, r.srv, nil
+}
+
+func (r *mockResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	var addrs []net.IPAddr
+	for _, ip := range r.ips[host] {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+func (r *mockResolver) LookupAddr(_ context.Context, addr string) ([]string, error) {
+	return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+}
+
 func mockGPOListCmd(t *testing.T, args ...string) []string {
 	t.Helper()
 
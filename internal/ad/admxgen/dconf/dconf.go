@@ -17,6 +17,7 @@ import (
 	"github.com/leonelquinteros/gotext"
 	log "github.com/sirupsen/logrus"
 	"github.com/ubuntu/adsys/internal/ad/admxgen/common"
+	"github.com/ubuntu/adsys/internal/policies/entry"
 	"github.com/ubuntu/decorate"
 	"gopkg.in/ini.v1"
 )
@@ -26,6 +27,8 @@ type Policy struct {
 	ObjectPath string
 	Schema     string
 	Class      string
+	// Strategy is how the array values of multiple GPOs are merged: replace (default), append or union.
+	Strategy string
 }
 
 // TODO:
@@ -155,6 +158,19 @@ func inflateToExpandedPolicies(policies []Policy, release, currentSessions strin
 			"meta": s.Type,
 		}
 
+		switch policy.Strategy {
+		case "", entry.StrategyReplace:
+		case entry.StrategyAppend, entry.StrategyUnion:
+			if s.Type != "as" && s.Type != "ai" {
+				return nil, errors.New(gotext.Get("strategy %q of %s is only supported on arrays", policy.Strategy, policy.ObjectPath))
+			}
+			// Disabled keys are not merged: they override the values of the further GPOs.
+			ep.MetaEnabled["strategy"] = policy.Strategy
+			ep.Note = gotext.Get(`default system value is used for "Not Configured" and enforced if "Disabled". The values of the less specific GPOs are merged with this one.`)
+		default:
+			return nil, errors.New(gotext.Get("unsupported strategy %q of %s, expecting replace, append or union", policy.Strategy, policy.ObjectPath))
+		}
+
 		if m.widgetType == common.WidgetTypeLongDecimal {
 			min := ep.RangeValues.Min
 			if min == "" {
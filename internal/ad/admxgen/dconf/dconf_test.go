@@ -28,18 +28,21 @@ func TestGenerate(t *testing.T) {
 		"Same key relocated twice": {root: "simple"},
 
 		// Different types
-		"One boolean key":                      {root: "simple"},
-		"One decimal key":                      {root: "simple"},
-		"One decimal key with range":           {root: "simple"},
-		"One decimal key with min only":        {root: "simple"},
-		"One decimal key with max only":        {root: "simple"},
-		"Long decimal key":                     {root: "simple"},
-		"Long decimal key with range min lt 0": {root: "simple"},
-		"Long decimal key with range min gt 0": {root: "simple"},
-		"Array of strings":                     {root: "simple"},
-		"Array of integers":                    {root: "simple"},
-		"Double key":                           {root: "simple"},
-		"Double key with range":                {root: "simple"},
+		"One boolean key":                        {root: "simple"},
+		"One decimal key":                        {root: "simple"},
+		"One decimal key with range":             {root: "simple"},
+		"One decimal key with min only":          {root: "simple"},
+		"One decimal key with max only":          {root: "simple"},
+		"Long decimal key":                       {root: "simple"},
+		"Long decimal key with range min lt 0":   {root: "simple"},
+		"Long decimal key with range min gt 0":   {root: "simple"},
+		"Array of strings":                       {root: "simple"},
+		"Array of integers":                      {root: "simple"},
+		"Array of strings with union strategy":   {root: "simple"},
+		"Array of integers with append strategy": {root: "simple"},
+		"Array with replace strategy":            {root: "simple"},
+		"Double key":                             {root: "simple"},
+		"Double key with range":                  {root: "simple"},
 
 		// Override cases
 		"Override without session":                                    {root: "simple", currentSessions: "-"},
@@ -71,12 +74,14 @@ func TestGenerate(t *testing.T) {
 		"Missing XML declaration is successfully parsed":   {root: "missing_xml_declaration"},
 
 		// Error cases
-		"Unsupported key type": {root: "exotic_type", wantErr: true},
-		"Enum does not exist":  {root: "nonexistent_enum", wantErr: true},
-		"Invalid class":        {root: "simple", wantErr: true},
-		"Invalid min":          {root: "invalid_min", wantErr: true},
-		"NaN min":              {root: "nan_min", wantErr: true},
-		"Invalid schema files": {root: "broken_schema", wantErr: true},
+		"Unsupported key type":      {root: "exotic_type", wantErr: true},
+		"Enum does not exist":       {root: "nonexistent_enum", wantErr: true},
+		"Invalid class":             {root: "simple", wantErr: true},
+		"Invalid min":               {root: "invalid_min", wantErr: true},
+		"NaN min":                   {root: "nan_min", wantErr: true},
+		"Invalid schema files":      {root: "broken_schema", wantErr: true},
+		"Strategy on non array key": {root: "simple", wantErr: true},
+		"Unsupported strategy":      {root: "simple", wantErr: true},
 	}
 	for name, tc := range tests {
 		def := strings.ToLower(strings.ReplaceAll(name, " ", "_"))
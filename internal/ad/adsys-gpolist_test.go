@@ -29,6 +29,8 @@ func TestAdsysGPOList(t *testing.T) {
 		url             string
 		accountName     string
 		objectClass     string
+		ou              string
+		attributes      string
 		krb5ccNameState string
 
 		wantErr        bool
@@ -122,6 +124,46 @@ func TestAdsysGPOList(t *testing.T) {
 			objectClass: "computer",
 		},
 
+		// Simulated placement in another OU
+		"Machine GPOs in another OU": {
+			accountName: "hostname1",
+			objectClass: "computer",
+			ou:          "/example/RnD/RnDDep1",
+		},
+		"Machine GPOs in another OU with forced GPOs": {
+			accountName: "hostname1",
+			objectClass: "computer",
+			ou:          "/example/RnD/RnDDep2/SubDep2ForcedPolicy",
+		},
+		"Machine GPOs in another OU blocking inheritance": {
+			accountName: "hostname1",
+			objectClass: "computer",
+			ou:          "/example/RnD/RnDDep2/SubDep2BlockInheritance",
+		},
+		"Machine GPOs in another OU filter user only GPOs": {
+			accountName: "hostname1",
+			objectClass: "computer",
+			ou:          "/example/IT/ITDep2",
+		},
+
+		// Object attributes
+		"Computer attributes": {
+			accountName: "hostname1",
+			objectClass: "computer",
+			attributes:  "description,location,departmentNumber,info,unsetAttribute",
+		},
+		"Computer without requested attributes set": {
+			accountName: "hostname2",
+			objectClass: "computer",
+			attributes:  "description",
+		},
+
+		// Domain controller address cases
+		"IPv6 controller address is bracketed": {
+			url:         "2001:db8::1",
+			accountName: "UserAtRoot@GPOONLY.COM",
+		},
+
 		// Error cases
 		"Error on no network": {
 			url:            "NT_STATUS_NETWORK_UNREACHABLE",
@@ -165,6 +207,20 @@ func TestAdsysGPOList(t *testing.T) {
 			wantReturnCode: 1,
 			wantErr:        true,
 		},
+		"Error on non existent OU": {
+			accountName:    "hostname1",
+			objectClass:    "computer",
+			ou:             "/example/nonexistent",
+			wantReturnCode: 1,
+			wantErr:        true,
+		},
+		"Error on unreadable attributes": {
+			accountName:    "hostnameWithUnreadableAttributes",
+			objectClass:    "computer",
+			attributes:     "description",
+			wantReturnCode: 1,
+			wantErr:        true,
+		},
 		"Error invalid GPO link": {
 			accountName:    "UserInvalidLink@GPOONLY.COM",
 			wantReturnCode: 3,
@@ -225,7 +281,14 @@ func TestAdsysGPOList(t *testing.T) {
 			}
 
 			// #nosec G204: we control the command line name and only change it for tests
-			cmd := exec.Command(adsysGPOListcmd, "--objectclass", tc.objectClass, tc.url, tc.accountName)
+			args := []string{"--objectclass", tc.objectClass, tc.url, tc.accountName}
+			if tc.ou != "" {
+				args = append([]string{"--ou", tc.ou}, args...)
+			}
+			if tc.attributes != "" {
+				args = append([]string{"--attributes", tc.attributes}, args...)
+			}
+			cmd := exec.Command(adsysGPOListcmd, args...)
 			got, err := cmd.CombinedOutput()
 			if tc.wantErr {
 				require.Error(t, err, "adsys-gpostlist should have failed but didn’t")
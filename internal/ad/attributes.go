@@ -0,0 +1,79 @@
+package ad
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+// attributeNameRe matches the valid LDAP attribute names, like description or extensionAttribute1.
+var attributeNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*$`)
+
+// WithComputerAttributes specifies the attributes of the computer object, like description, read from AD at each
+// machine refresh and exposed to the policy managers.
+func WithComputerAttributes(attrs []string) Option {
+	return func(o *options) error {
+		for _, a := range attrs {
+			if !attributeNameRe.MatchString(a) {
+				return errors.New(gotext.Get("invalid computer attribute name %q", a))
+			}
+		}
+		o.computerAttributes = attrs
+		return nil
+	}
+}
+
+// computerAttributes returns the values of the configured attributes of the computer object which are set, keyed
+// by their lowercase name, as LDAP attribute names are case insensitive. Only the first value of multi-valued
+// attributes is returned. It uses krb5CCPath to authenticate to AD.
+func (ad *AD) computerAttributes(ctx context.Context, krb5CCPath string) (attrs map[string]string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't read attributes of computer object"))
+
+	adServerFQDN, err := ad.serverFQDN(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", gotext.Get("can't get current Server FQDN"), err)
+	}
+
+	args := append([]string{}, ad.gpoListCmd...) // Copy gpoListCmd to prevent data race
+	scriptArgs := []string{"--attributes", strings.Join(ad.computerAttrs, ","), "--objectclass", string(ComputerObject), adServerFQDN, ad.hostname}
+	cmdArgs := append(args, scriptArgs...)
+	cmdCtx, cancel := context.WithTimeout(ctx, ad.gpoListTimeout)
+	defer cancel()
+	log.Debugf(ctx, "Getting computer attributes with arguments: %q", strings.Join(scriptArgs, " "))
+	// #nosec G204 - cmdArgs is under our control (python embedded script or mock for tests)
+	cmd := exec.CommandContext(cmdCtx, cmdArgs[0], cmdArgs[1:]...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("KRB5CCNAME=%s", krb5CCPath))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	smbsafe.WaitExec()
+	err = cmd.Run()
+	smbsafe.DoneExec()
+	if err != nil {
+		return nil, errors.New(gotext.Get("failed to retrieve the computer attributes (exited with %d): %v\n%s", cmd.ProcessState.ExitCode(), err, stderr.String()))
+	}
+
+	attrs = make(map[string]string)
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), "\t")
+		if !found {
+			continue
+		}
+		log.Debugf(ctx, "Computer attribute %s is %q", name, value)
+		attrs[strings.ToLower(name)] = value
+	}
+	return attrs, scanner.Err()
+}
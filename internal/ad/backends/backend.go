@@ -17,6 +17,10 @@ type Backend interface {
 	// If the dynamic lookup worked, but there is still no server URL found (for instance, backend
 	// if offline), the error raised is of type ErrorNoActiveServer.
 	ServerFQDN(context.Context) (string, error)
+	// Site returns the Active Directory site of the machine.
+	// It returns first any static configuration and goes dynamic if the backend provides this.
+	// If no site can be found, the error raised is of type ErrNoSite.
+	Site(context.Context) (string, error)
 	// HostKrb5CCName computes and returns the absolute path of the machine krb5 ticket.
 	HostKrb5CCName() (string, error)
 	// DefaultDomainSuffix returns current default domain suffix.
@@ -31,4 +35,8 @@ var (
 	// ErrNoActiveServer is an error receive when there is no active server and no static configuration
 	// This is received in ServerFQDN.
 	ErrNoActiveServer = errors.New(gotext.Get("no active server found"))
+
+	// ErrNoSite is an error receive when the site of the machine is neither configured nor discovered.
+	// This is received in Site.
+	ErrNoSite = errors.New(gotext.Get("no site found"))
 )
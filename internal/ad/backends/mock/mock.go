@@ -4,12 +4,15 @@ package mock
 import (
 	"context"
 	"errors"
+
+	"github.com/ubuntu/adsys/internal/ad/backends"
 )
 
 // Backend is a mock backend where we control some returned value.
 type Backend struct {
 	Dom                string
 	ServURL            string
+	SiteName           string
 	HostKrb5CCNamePath string
 
 	Online        bool
@@ -34,6 +37,15 @@ func (m Backend) ServerFQDN(context.Context) (string, error) {
 	return m.ServURL, nil
 }
 
+// Site returns the Active Directory site of the machine.
+// If no site is set, the error raised is of type ErrNoSite.
+func (m Backend) Site(context.Context) (string, error) {
+	if m.SiteName == "" {
+		return "", backends.ErrNoSite
+	}
+	return m.SiteName, nil
+}
+
 // HostKrb5CCName returns the absolute path of the machine krb5 ticket.
 func (m Backend) HostKrb5CCName() (string, error) {
 	if m.ErrKrb5CCName {
@@ -23,6 +23,7 @@ type SSS struct {
 	domainDbus          dbus.BusObject
 	serverFQDN          string
 	staticServerFQDN    string
+	site                string
 	hostKrb5CCName      string
 	defaultDomainSuffix string
 
@@ -82,6 +83,9 @@ func New(ctx context.Context, c Config, bus *dbus.Conn) (s SSS, err error) {
 		staticServerFQDN = strings.TrimPrefix(staticServerFQDN, "ldap://")
 	}
 
+	// AD site, as sssd does not expose the one it discovered
+	site := domainSection.Key("ad_site").String()
+
 	// local machine sssd krb5 cache
 	hostKrb5CCName := filepath.Join(c.CacheDir, "ccache_"+strings.ToUpper(domain))
 
@@ -90,6 +94,7 @@ func New(ctx context.Context, c Config, bus *dbus.Conn) (s SSS, err error) {
 		domainDbus:          domainDbus,
 		serverFQDN:          staticServerFQDN,
 		staticServerFQDN:    staticServerFQDN,
+		site:                site,
 		hostKrb5CCName:      hostKrb5CCName,
 		defaultDomainSuffix: defaultDomainSuffix,
 
@@ -125,6 +130,16 @@ func (sss SSS) ServerFQDN(ctx context.Context) (serverFQDN string, err error) {
 	return strings.TrimPrefix(serverFQDN, "ldap://"), nil
 }
 
+// Site returns the Active Directory site of the machine.
+// SSSD does not expose the site it discovered, so only the ad_site of sssd.conf is returned. If it is not set,
+// the error raised is of type ErrNoSite.
+func (sss SSS) Site(context.Context) (string, error) {
+	if sss.site == "" {
+		return "", fmt.Errorf("%w: %s", backends.ErrNoSite, gotext.Get("no ad_site configured in sssd.conf for %q", sss.domain))
+	}
+	return sss.site, nil
+}
+
 // HostKrb5CCName returns the absolute path of the machine krb5 ticket.
 func (sss SSS) HostKrb5CCName() (string, error) {
 	return sss.hostKrb5CCName, nil
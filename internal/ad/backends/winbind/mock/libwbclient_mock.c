@@ -35,6 +35,20 @@ wbcErr wbcLookupDomainController(const char *domain, uint32_t flags, struct wbcD
     return WBC_ERR_SUCCESS;
 }
 
+wbcErr wbcLookupDomainControllerEx(const char *domain, struct wbcGuid *guid, const char *site, uint32_t flags,
+                                   struct wbcDomainControllerInfoEx **dc_info) {
+    char *behavior = get_mock_behavior();
+    if (strcmp(behavior, "error_getting_site_name") == 0) {
+        return WBC_ERR_UNKNOWN_FAILURE;
+    }
+
+    struct wbcDomainControllerInfoEx *dc = malloc(sizeof(struct wbcDomainControllerInfoEx));
+    // This is the only field used at the moment
+    dc->client_site_name = "Default-First-Site-Name";
+    *dc_info = dc;
+    return WBC_ERR_SUCCESS;
+}
+
 wbcErr wbcInterfaceDetails(struct wbcInterfaceDetails **details) {
     char *behavior = get_mock_behavior();
     if (strcmp(behavior, "domain_not_found") == 0) {
@@ -35,6 +35,18 @@ char *get_dc_name(char *domain) {
   return strdup(dc_info->dc_name);
 }
 
+char *get_site_name(char *domain) {
+  // Get the site of the machine, as returned by the domain controller
+  wbcErr wbc_status = WBC_ERR_UNKNOWN_FAILURE;
+  struct wbcDomainControllerInfoEx *dc_info = NULL;
+
+  wbc_status = wbcLookupDomainControllerEx(domain, NULL, NULL, WBC_LOOKUP_DC_DS_REQUIRED, &dc_info);
+  if (wbc_status != WBC_ERR_SUCCESS || dc_info->client_site_name == NULL) {
+    return NULL;
+  }
+  return strdup(dc_info->client_site_name);
+}
+
 bool is_online(char *domain) {
   wbcErr wbc_status = WBC_ERR_UNKNOWN_FAILURE;
   struct wbcDomainInfo *info = NULL;
@@ -62,6 +74,7 @@ import (
 	"unsafe"
 
 	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/ad/backends"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/smbsafe"
 	"github.com/ubuntu/decorate"
@@ -70,6 +83,7 @@ import (
 // Winbind is the backend object with domain and DC information.
 type Winbind struct {
 	staticServerFQDN    string
+	staticSite          string
 	domain              string
 	defaultDomainSuffix string
 	kinitCmd            []string
@@ -82,6 +96,7 @@ type Winbind struct {
 type Config struct {
 	ADServer string `mapstructure:"ad_server"` // bypass winbind and use this server
 	ADDomain string `mapstructure:"ad_domain"` // bypass domain name detection and use this domain
+	ADSite   string `mapstructure:"ad_site"`   // bypass site detection and use this site
 }
 
 // Option represents an optional function to change the winbind backend.
@@ -115,6 +130,7 @@ func New(ctx context.Context, c Config, hostname string, opts ...Option) (w Winb
 
 	return Winbind{
 		staticServerFQDN:    c.ADServer,
+		staticSite:          c.ADSite,
 		domain:              c.ADDomain,
 		defaultDomainSuffix: c.ADDomain,
 		kinitCmd:            args.kinitCmd,
@@ -176,6 +192,20 @@ func (w Winbind) ServerFQDN(ctx context.Context) (serverFQDN string, err error)
 	return serverFQDN, nil
 }
 
+// Site returns the Active Directory site of the machine.
+// It returns first any static configuration. If nothing is found, it will fetch
+// the site from winbind. If winbind can't find it, the error raised is of type ErrNoSite.
+func (w Winbind) Site(ctx context.Context) (site string, err error) {
+	defer decorate.OnError(&err, gotext.Get("error while trying to look up AD site on winbind"))
+
+	if w.staticSite != "" {
+		return w.staticSite, nil
+	}
+
+	log.Debugf(ctx, "Triggering autodiscovery of AD site because winbind configuration does not provide an ad_site for %q", w.domain)
+	return siteName(w.domain)
+}
+
 // Config returns a stringified configuration for Winbind backend.
 func (w Winbind) Config() string {
 	return "Current backend is Winbind"
@@ -211,3 +241,14 @@ func dcName(domain string) (string, error) {
 	defer C.free(unsafe.Pointer(dc))
 	return C.GoString(dc), nil
 }
+
+func siteName(domain string) (string, error) {
+	cDomain := C.CString(domain)
+	defer C.free(unsafe.Pointer(cDomain))
+	site := C.get_site_name(cDomain)
+	if site == nil {
+		return "", fmt.Errorf("%w: %s", backends.ErrNoSite, gotext.Get("could not get site name for domain %q", domain))
+	}
+	defer C.free(unsafe.Pointer(site))
+	return C.GoString(site), nil
+}
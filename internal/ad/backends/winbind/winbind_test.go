@@ -30,6 +30,7 @@ func TestWinbind(t *testing.T) {
 		wbclientBehavior string
 		staticADDomain   string
 		staticADServer   string
+		staticADSite     string
 		hostname         string
 
 		wantKinitErr bool
@@ -42,10 +43,12 @@ func TestWinbind(t *testing.T) {
 		"Lookup with overridden ad_domain":                  {staticADDomain: "overridden.com"},
 		"Lookup with overridden ad_server":                  {staticADServer: "controller.overridden.com"},
 		"Lookup with overridden ad_server with LDAP prefix": {staticADServer: "ldap://controller.overridden.com"},
+		"Lookup with overridden ad_site":                    {staticADSite: "Overridden-Site"},
 
 		// Error cases
 		"Error when looking up domain":     {wbclientBehavior: "domain_not_found", wantErr: true},
 		"Error when looking up DC name":    {wbclientBehavior: "error_getting_dc_name"},
+		"Error when looking up site name":  {wbclientBehavior: "error_getting_site_name"},
 		"Error when getting online status": {wbclientBehavior: "error_getting_online_status"},
 		"Error when domain is offline":     {wbclientBehavior: "domain_is_offline"},
 		"Error when requesting krb5cc":     {wantKinitErr: true},
@@ -68,6 +71,9 @@ func TestWinbind(t *testing.T) {
 			if tc.staticADServer != "" {
 				config.ADServer = tc.staticADServer
 			}
+			if tc.staticADSite != "" {
+				config.ADSite = tc.staticADSite
+			}
 
 			kinitCmdOutputFile := filepath.Join(t.TempDir(), "kinit-output")
 			kinitCmd := []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestExecuteKinitCommand", "--", kinitCmdOutputFile}
@@ -0,0 +1,87 @@
+package ad
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/telemetry"
+)
+
+const (
+	// defaultDCDownCooldown is the default time a domain controller is marked down after repeated failures.
+	defaultDCDownCooldown = 5 * time.Minute
+
+	// gpoListConnectionFailed is the exit code of adsys-gpolist when it can't connect to the domain controller.
+	gpoListConnectionFailed = 2
+)
+
+// Metrics of the state of the domain controllers.
+const (
+	dcDownMetric     = "adsys.dc.down"
+	dcFailuresMetric = "adsys.dc.consecutive_failures"
+	attrDCHost       = "adsys.dc.host"
+)
+
+// WithDCCircuitBreaker marks down a domain controller for cooldown after threshold consecutive failed connections
+// to list the GPOs. Until then, the other domain controllers discovered for the domain are used instead.
+// A 0 threshold never marks down any controller, and a 0 cooldown selects the default.
+func WithDCCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(o *options) error {
+		if threshold < 0 {
+			return errors.New(gotext.Get("domain controller failure threshold can't be negative: %d", threshold))
+		}
+		if cooldown < 0 {
+			return errors.New(gotext.Get("domain controller down cooldown can't be negative: %v", cooldown))
+		}
+		o.dcFailureThreshold = threshold
+		if cooldown != 0 {
+			o.dcDownCooldown = cooldown
+		}
+		return nil
+	}
+}
+
+// WithOTLPEndpoint specifies the OTLP/HTTP endpoint, like http://collector:4318, to which the state of the domain
+// controllers is exported.
+func WithOTLPEndpoint(endpoint string) Option {
+	return func(o *options) error {
+		o.otlpEndpoint = endpoint
+		return nil
+	}
+}
+
+// dcFailed records a failed connection to the domain controller host, and exports the new state of the controllers.
+func (ad *AD) dcFailed(ctx context.Context, host string) {
+	if ad.dcBreaker.Failure(host) {
+		log.Warningf(ctx, "Domain controller %q is marked down for %s after %d consecutive failures", host, ad.dcDownCooldown, ad.dcFailureThreshold)
+	}
+	ad.exportDCStates(ctx)
+}
+
+// dcSucceeded records a successful connection to the domain controller host, and exports the new state of the
+// controllers.
+func (ad *AD) dcSucceeded(ctx context.Context, host string) {
+	ad.dcBreaker.Success(host)
+	ad.exportDCStates(ctx)
+}
+
+// exportDCStates exports, for each domain controller which failed at least once, if it is marked down and its
+// number of consecutive failures.
+func (ad *AD) exportDCStates(ctx context.Context) {
+	var gauges []telemetry.Gauge
+	for _, s := range ad.dcBreaker.States() {
+		var down int64
+		if !s.DownUntil.IsZero() {
+			down = 1
+		}
+		attrs := map[string]string{attrDCHost: s.Host}
+		gauges = append(gauges,
+			telemetry.Gauge{Name: dcDownMetric, Description: "Whether the domain controller is marked down after repeated failures.", Unit: "1", Attributes: attrs, Value: down},
+			telemetry.Gauge{Name: dcFailuresMetric, Description: "Consecutive failed connections to the domain controller.", Unit: "1", Attributes: attrs, Value: int64(s.ConsecutiveFailures)},
+		)
+	}
+	ad.telemetry.ExportGauges(ctx, gauges)
+}
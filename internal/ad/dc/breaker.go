@@ -0,0 +1,146 @@
+package dc
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Breaker marks down the domain controllers failing repeatedly, so that they are not connected to again before a
+// cooldown. Once the cooldown is over, the next connection is let through: the controller is marked up again if it
+// succeeds, and down for another cooldown if it fails. It is safe for concurrent use.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// circuit is the state of the connections to a domain controller.
+type circuit struct {
+	failures int
+	// downUntil is the end of the cooldown of a controller marked down, or zero.
+	downUntil time.Time
+}
+
+// State is the breaker state of a domain controller.
+type State struct {
+	Host string
+	// ConsecutiveFailures is the number of failed connections since the last successful one.
+	ConsecutiveFailures int
+	// DownUntil is the end of the cooldown if the controller is marked down, or zero.
+	DownUntil time.Time
+}
+
+type breakerOptions struct {
+	now func() time.Time
+}
+
+// BreakerOption reprents an optional function to change the breaker.
+type BreakerOption func(*breakerOptions)
+
+// NewBreaker returns a breaker marking down a domain controller for cooldown after threshold consecutive failed
+// connections. A 0 threshold never marks down any controller.
+func NewBreaker(threshold int, cooldown time.Duration, opts ...BreakerOption) *Breaker {
+	// defaults
+	args := breakerOptions{
+		now: time.Now,
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       args.now,
+		circuits:  make(map[string]*circuit),
+	}
+}
+
+// Allow returns true if host can be connected to, which is when it is not marked down, or when its cooldown is over.
+// Otherwise, it returns the end of its cooldown.
+func (b *Breaker) Allow(host string) (ok bool, downUntil time.Time) {
+	if b == nil {
+		return true, time.Time{}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[key(host)]
+	if !ok || c.downUntil.IsZero() || !b.now().Before(c.downUntil) {
+		return true, time.Time{}
+	}
+	return false, c.downUntil
+}
+
+// Success records a successful connection to host, which is marked up again.
+func (b *Breaker) Success(host string) {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, ok := b.circuits[key(host)]; ok {
+		*c = circuit{}
+	}
+}
+
+// Failure records a failed connection to host. It returns true if host is now marked down, which happens after
+// threshold consecutive failures, or on the first failure after a cooldown.
+func (b *Breaker) Failure(host string) (down bool) {
+	if b == nil || b.threshold <= 0 {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[key(host)]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key(host)] = c
+	}
+	c.failures++
+	if c.failures < b.threshold {
+		return false
+	}
+	c.downUntil = b.now().Add(b.cooldown)
+	return true
+}
+
+// States returns the state of the domain controllers which failed at least once, sorted by host, so that the
+// controllers marked up again are still reported. A controller whose cooldown is over is not reported as down.
+func (b *Breaker) States() []State {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var states []State
+	now := b.now()
+	for host, c := range b.circuits {
+		s := State{Host: host, ConsecutiveFailures: c.failures}
+		if now.Before(c.downUntil) {
+			s.DownUntil = c.downUntil
+		}
+		states = append(states, s)
+	}
+	slices.SortFunc(states, func(a, b State) int { return strings.Compare(a.Host, b.Host) })
+	return states
+}
+
+// key returns the key of host in the circuits, as the same controller can be named with different cases, ports or
+// with a ldap:// scheme.
+func key(host string) string {
+	return strings.ToLower(Normalize(host))
+}
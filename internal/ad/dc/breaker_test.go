@@ -0,0 +1,91 @@
+package dc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/ad/dc"
+)
+
+func TestBreaker(t *testing.T) {
+	t.Parallel()
+
+	// Each event is a connection result, "fail" or "ok", or "wait" to advance the time by the cooldown.
+	tests := map[string]struct {
+		threshold int
+		events    []string
+
+		wantAllowed  bool
+		wantFailures int
+		wantDown     bool
+		wantNoState  bool
+	}{
+		"Controller without failure is allowed": {threshold: 3, wantAllowed: true, wantNoState: true},
+		"Controller is allowed below threshold": {threshold: 3, events: []string{"fail", "fail"}, wantAllowed: true, wantFailures: 2},
+		"Success resets failures":               {threshold: 3, events: []string{"fail", "fail", "ok", "fail", "fail"}, wantAllowed: true, wantFailures: 2},
+
+		"Threshold consecutive failures trip the breaker": {threshold: 3, events: []string{"fail", "fail", "fail"}, wantFailures: 3, wantDown: true},
+		"Threshold of 1 trips on first failure":           {threshold: 1, events: []string{"fail"}, wantFailures: 1, wantDown: true},
+		"Controller is allowed again after cooldown":      {threshold: 2, events: []string{"fail", "fail", "wait"}, wantAllowed: true, wantFailures: 2},
+		"Success after cooldown resets the breaker":       {threshold: 2, events: []string{"fail", "fail", "wait", "ok"}, wantAllowed: true},
+		"Failure after cooldown trips the breaker again":  {threshold: 2, events: []string{"fail", "fail", "wait", "fail"}, wantFailures: 3, wantDown: true},
+
+		"Breaker with 0 threshold never trips": {events: []string{"fail", "fail", "fail"}, wantAllowed: true, wantNoState: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			b := dc.NewBreaker(tc.threshold, time.Minute, dc.WithTimeNow(func() time.Time { return now }))
+
+			for _, e := range tc.events {
+				switch e {
+				case "fail":
+					b.Failure("dc1.example.com")
+				case "ok":
+					b.Success("dc1.example.com")
+				case "wait":
+					now = now.Add(time.Minute)
+				}
+			}
+			// Another controller is not affected.
+			b.Failure("dc2.example.com")
+
+			allowed, until := b.Allow("dc1.example.com")
+			require.Equal(t, tc.wantAllowed, allowed, "Allow should return if the controller can be connected to")
+			if !tc.wantAllowed {
+				require.Equal(t, now.Add(time.Minute), until, "Allow should return the end of the cooldown")
+			}
+			allowed, _ = b.Allow("ldap://DC1.example.com:389")
+			require.Equal(t, tc.wantAllowed, allowed, "Allow should ignore the case, scheme and port of the controller")
+
+			var got *dc.State
+			for _, s := range b.States() {
+				if s.Host == "dc1.example.com" {
+					got = &s
+				}
+			}
+			if tc.wantNoState {
+				require.Nil(t, got, "States should not report a controller without failure")
+				return
+			}
+			require.NotNil(t, got, "States should report the failing controller")
+			require.Equal(t, tc.wantFailures, got.ConsecutiveFailures, "States should report the consecutive failures")
+			require.Equal(t, tc.wantDown, !got.DownUntil.IsZero(), "States should report if the controller is marked down")
+		})
+	}
+}
+
+func TestNilBreaker(t *testing.T) {
+	t.Parallel()
+
+	var b *dc.Breaker
+	require.False(t, b.Failure("dc1.example.com"), "Failure should not mark down without breaker")
+	b.Success("dc1.example.com")
+	allowed, _ := b.Allow("dc1.example.com")
+	require.True(t, allowed, "Allow should always allow without breaker")
+	require.Empty(t, b.States(), "States should be empty without breaker")
+}
@@ -0,0 +1,199 @@
+// Package dc locates the Active Directory domain controller to connect to, and formats its address to be used
+// in LDAP and SMB URLs and to authenticate with Kerberos.
+//
+// Domain controllers can be reachable over IPv4, IPv6 or both. When discovered from the SRV records of the
+// domain, the IPv6 addresses of each controller are tried first, so that IPv6-only controllers are handled
+// like any other one.
+package dc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// Resolver looks up the DNS records of the domain controllers.
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*net.SRV, err error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupAddr(ctx context.Context, addr string) (names []string, err error)
+}
+
+// Dialer connects to the domain controllers to check they are reachable.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Locator finds the domain controllers of a domain.
+type Locator struct {
+	resolver Resolver
+	dialer   Dialer
+	breaker  *Breaker
+}
+
+type options struct {
+	resolver Resolver
+	dialer   Dialer
+	breaker  *Breaker
+}
+
+// Option reprents an optional function to change the locator.
+type Option func(*options)
+
+// WithResolver specifies a personalized resolver to look up the domain controllers.
+func WithResolver(r Resolver) Option {
+	return func(o *options) {
+		o.resolver = r
+	}
+}
+
+// WithDialer specifies a personalized dialer to connect to the domain controllers.
+func WithDialer(d Dialer) Option {
+	return func(o *options) {
+		o.dialer = d
+	}
+}
+
+// WithBreaker specifies the breaker skipping the domain controllers marked down, and recording the unreachable ones.
+func WithBreaker(b *Breaker) Option {
+	return func(o *options) {
+		o.breaker = b
+	}
+}
+
+// New returns a locator of domain controllers.
+func New(opts ...Option) *Locator {
+	// defaults
+	args := options{
+		resolver: net.DefaultResolver,
+		dialer:   &net.Dialer{Timeout: 5 * time.Second},
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Locator{
+		resolver: args.resolver,
+		dialer:   args.dialer,
+		breaker:  args.breaker,
+	}
+}
+
+// Discover returns the host name of the first reachable domain controller of domain, from its LDAP SRV records.
+// The records are tried in order of priority, and the IPv6 addresses of each controller before its IPv4 ones.
+// The controllers marked down by the breaker are skipped, and the unreachable ones are recorded as failing.
+func (l Locator) Discover(ctx context.Context, domain string) (host string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't discover a domain controller for %q", domain))
+
+	_, records, err := l.resolver.LookupSRV(ctx, "ldap", "tcp", "dc._msdcs."+domain)
+	if err != nil {
+		return "", err
+	}
+	if len(records) == 0 {
+		return "", errors.New(gotext.Get("no domain controller is advertised"))
+	}
+
+	var down []string
+	for _, r := range records {
+		host := strings.TrimSuffix(r.Target, ".")
+		if ok, until := l.breaker.Allow(host); !ok {
+			log.Debugf(ctx, "Skipping domain controller %q, marked down until %s", host, until.Format(time.DateTime))
+			down = append(down, host)
+			continue
+		}
+		ips, err := l.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			log.Debugf(ctx, "Can't resolve domain controller %q: %v", host, err)
+			continue
+		}
+		for _, ip := range preferIPv6(ips) {
+			address := net.JoinHostPort(ip.String(), strconv.Itoa(int(r.Port)))
+			conn, err := l.dialer.DialContext(ctx, "tcp", address)
+			if err != nil {
+				log.Debugf(ctx, "Domain controller %q is not reachable at %s: %v", host, address, err)
+				continue
+			}
+			_ = conn.Close()
+			log.Debugf(ctx, "Discovered domain controller %q, reachable at %s", host, address)
+			return host, nil
+		}
+		if len(ips) > 0 && l.breaker.Failure(host) {
+			log.Warningf(ctx, "Domain controller %q is marked down after repeated connection failures", host)
+		}
+	}
+
+	if len(down) > 0 {
+		return "", errors.New(gotext.Get("no domain controller is reachable, skipped the ones marked down after repeated failures: %s", strings.Join(down, ", ")))
+	}
+	return "", errors.New(gotext.Get("no domain controller is reachable"))
+}
+
+// KerberosHost returns the server to use to authenticate with Kerberos. As service principals are registered for
+// host names, a server given as an IP address is resolved back to the host name of the controller, keeping its
+// port if any. Server is returned unchanged if it is not an IP address or if it can't be resolved.
+func (l Locator) KerberosHost(ctx context.Context, server string) string {
+	host, port := Normalize(server), ""
+	if _, p, err := net.SplitHostPort(strings.TrimPrefix(server, "ldap://")); err == nil {
+		port = p
+	}
+	if _, err := netip.ParseAddr(host); err != nil {
+		return server
+	}
+
+	names, err := l.resolver.LookupAddr(ctx, host)
+	if err != nil || len(names) == 0 {
+		log.Warningf(ctx, "Can't resolve the host name of domain controller %s, Kerberos authentication may fail: %v", host, err)
+		return server
+	}
+	name := strings.TrimSuffix(names[0], ".")
+	if port != "" {
+		return net.JoinHostPort(name, port)
+	}
+	return name
+}
+
+// Normalize returns the host of server, without any ldap:// scheme, port or brackets around IPv6 addresses.
+func Normalize(server string) string {
+	server = strings.TrimPrefix(server, "ldap://")
+	server = strings.TrimSuffix(server, "/")
+	if host, _, err := net.SplitHostPort(server); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(server, "["), "]")
+}
+
+// URLHost returns host as written in an URL, with IPv6 addresses in brackets.
+func URLHost(host string) string {
+	host = Normalize(host)
+	if addr, err := netip.ParseAddr(host); err == nil && addr.Is6() {
+		return fmt.Sprintf("[%s]", host)
+	}
+	return host
+}
+
+// preferIPv6 returns the addresses with the IPv6 ones first, keeping their order otherwise.
+func preferIPv6(ips []net.IPAddr) []net.IPAddr {
+	sorted := slices.Clone(ips)
+	slices.SortStableFunc(sorted, func(a, b net.IPAddr) int {
+		aIs6, bIs6 := a.IP.To4() == nil, b.IP.To4() == nil
+		switch {
+		case aIs6 && !bIs6:
+			return -1
+		case !aIs6 && bIs6:
+			return 1
+		}
+		return 0
+	})
+	return sorted
+}
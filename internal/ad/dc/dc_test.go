@@ -0,0 +1,275 @@
+package dc_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/ad/dc"
+)
+
+func TestDiscover(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		srv       []*net.SRV
+		ips       map[string][]string
+		reachable []string
+		srvErr    bool
+		down      []string
+
+		want      string
+		wantDials []string
+		wantDown  []string
+		wantErr   bool
+	}{
+		"IPv6 only controller": {
+			srv:       []*net.SRV{{Target: "dc1.example.com.", Port: 389}},
+			ips:       map[string][]string{"dc1.example.com": {"2001:db8::1"}},
+			reachable: []string{"[2001:db8::1]:389"},
+			want:      "dc1.example.com",
+			wantDials: []string{"[2001:db8::1]:389"},
+		},
+		"IPv6 addresses are tried first": {
+			srv:       []*net.SRV{{Target: "dc1.example.com.", Port: 389}},
+			ips:       map[string][]string{"dc1.example.com": {"192.0.2.1", "2001:db8::1"}},
+			reachable: []string{"192.0.2.1:389", "[2001:db8::1]:389"},
+			want:      "dc1.example.com",
+			wantDials: []string{"[2001:db8::1]:389"},
+		},
+		"Fallback to IPv4 if IPv6 is unreachable": {
+			srv:       []*net.SRV{{Target: "dc1.example.com.", Port: 389}},
+			ips:       map[string][]string{"dc1.example.com": {"192.0.2.1", "2001:db8::1"}},
+			reachable: []string{"192.0.2.1:389"},
+			want:      "dc1.example.com",
+			wantDials: []string{"[2001:db8::1]:389", "192.0.2.1:389"},
+		},
+		"Next controller is tried if first one is unreachable": {
+			srv:       []*net.SRV{{Target: "dc1.example.com.", Port: 389}, {Target: "dc2.example.com.", Port: 3268}},
+			ips:       map[string][]string{"dc1.example.com": {"2001:db8::1"}, "dc2.example.com": {"2001:db8::2"}},
+			reachable: []string{"[2001:db8::2]:3268"},
+			want:      "dc2.example.com",
+			wantDials: []string{"[2001:db8::1]:389", "[2001:db8::2]:3268"},
+			wantDown:  []string{"dc1.example.com"},
+		},
+		"Next controller is tried if first one can't be resolved": {
+			srv:       []*net.SRV{{Target: "dc1.example.com.", Port: 389}, {Target: "dc2.example.com.", Port: 389}},
+			ips:       map[string][]string{"dc2.example.com": {"2001:db8::2"}},
+			reachable: []string{"[2001:db8::2]:389"},
+			want:      "dc2.example.com",
+			wantDials: []string{"[2001:db8::2]:389"},
+		},
+		"Controller marked down is skipped": {
+			srv:       []*net.SRV{{Target: "dc1.example.com.", Port: 389}, {Target: "dc2.example.com.", Port: 389}},
+			ips:       map[string][]string{"dc1.example.com": {"2001:db8::1"}, "dc2.example.com": {"2001:db8::2"}},
+			reachable: []string{"[2001:db8::1]:389", "[2001:db8::2]:389"},
+			down:      []string{"DC1.example.com"},
+			want:      "dc2.example.com",
+			wantDials: []string{"[2001:db8::2]:389"},
+			wantDown:  []string{"dc1.example.com"},
+		},
+
+		"Error on SRV lookup failing":       {srvErr: true, wantErr: true},
+		"Error on no controller advertised": {srv: []*net.SRV{}, wantErr: true},
+		"Error on no controller reachable": {
+			srv:       []*net.SRV{{Target: "dc1.example.com.", Port: 389}},
+			ips:       map[string][]string{"dc1.example.com": {"2001:db8::1"}},
+			wantDials: []string{"[2001:db8::1]:389"},
+			wantDown:  []string{"dc1.example.com"},
+			wantErr:   true,
+		},
+		"Error on all controllers marked down": {
+			srv:       []*net.SRV{{Target: "dc1.example.com.", Port: 389}},
+			ips:       map[string][]string{"dc1.example.com": {"2001:db8::1"}},
+			reachable: []string{"[2001:db8::1]:389"},
+			down:      []string{"dc1.example.com"},
+			wantDown:  []string{"dc1.example.com"},
+			wantErr:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &mockResolver{srv: tc.srv, ips: tc.ips, srvErr: tc.srvErr}
+			d := &mockDialer{reachable: tc.reachable}
+			b := dc.NewBreaker(1, time.Minute)
+			for _, host := range tc.down {
+				b.Failure(host)
+			}
+			l := dc.New(dc.WithResolver(r), dc.WithDialer(d), dc.WithBreaker(b))
+
+			got, err := l.Discover(context.Background(), "example.com")
+			require.Equal(t, tc.wantDials, d.dials, "Discover should have tried to connect to the expected addresses")
+			var down []string
+			for _, s := range b.States() {
+				down = append(down, s.Host)
+			}
+			require.Equal(t, tc.wantDown, down, "Discover should have marked down the unreachable controllers")
+			if tc.wantErr {
+				require.Error(t, err, "Discover should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "Discover failed but shouldn't have")
+			require.Equal(t, "dc._msdcs.example.com", r.srvName, "Discover should look up the domain controllers SRV records")
+			require.Equal(t, tc.want, got, "Discover should return the expected domain controller")
+		})
+	}
+}
+
+func TestDiscoverConnectsOverIPv6(t *testing.T) {
+	t.Parallel()
+
+	lis, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 is not available: %v", err)
+	}
+	defer lis.Close()
+	accepted := make(chan net.Addr, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- conn.LocalAddr()
+		conn.Close()
+	}()
+
+	r := &mockResolver{
+		srv: []*net.SRV{{Target: "dc1.example.com.", Port: uint16(lis.Addr().(*net.TCPAddr).Port)}},
+		ips: map[string][]string{"dc1.example.com": {"::1"}},
+	}
+	l := dc.New(dc.WithResolver(r))
+
+	got, err := l.Discover(context.Background(), "example.com")
+	require.NoError(t, err, "Discover failed but shouldn't have")
+	require.Equal(t, "dc1.example.com", got, "Discover should return the IPv6 only domain controller")
+
+	addr := <-accepted
+	require.True(t, addr.(*net.TCPAddr).IP.Equal(net.IPv6loopback), "Discover should have connected to the IPv6 address")
+}
+
+func TestKerberosHost(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		server    string
+		names     map[string][]string
+		lookupErr bool
+
+		want string
+	}{
+		"Host name is kept":                         {server: "dc1.example.com", want: "dc1.example.com"},
+		"Host name with port is kept":               {server: "dc1.example.com:389", want: "dc1.example.com:389"},
+		"IPv6 address is resolved":                  {server: "2001:db8::1", names: map[string][]string{"2001:db8::1": {"dc1.example.com."}}, want: "dc1.example.com"},
+		"Bracketed IPv6 address is resolved":        {server: "[2001:db8::1]", names: map[string][]string{"2001:db8::1": {"dc1.example.com."}}, want: "dc1.example.com"},
+		"IPv6 address with port is resolved":        {server: "[2001:db8::1]:389", names: map[string][]string{"2001:db8::1": {"dc1.example.com."}}, want: "dc1.example.com:389"},
+		"IPv6 address with ldap scheme is resolved": {server: "ldap://[2001:db8::1]", names: map[string][]string{"2001:db8::1": {"dc1.example.com."}}, want: "dc1.example.com"},
+		"IPv4 address is resolved":                  {server: "192.0.2.1", names: map[string][]string{"192.0.2.1": {"dc1.example.com."}}, want: "dc1.example.com"},
+
+		"Unresolved IPv6 address is kept":      {server: "[2001:db8::1]", want: "[2001:db8::1]"},
+		"IPv6 address is kept on lookup error": {server: "2001:db8::1", lookupErr: true, want: "2001:db8::1"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			l := dc.New(dc.WithResolver(&mockResolver{names: tc.names, addrErr: tc.lookupErr}))
+
+			got := l.KerberosHost(context.Background(), tc.server)
+			require.Equal(t, tc.want, got, "KerberosHost should return the expected host name")
+		})
+	}
+}
+
+func TestURLHost(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		host string
+
+		want string
+	}{
+		"Host name":                     {host: "dc1.example.com", want: "dc1.example.com"},
+		"Host name with ldap scheme":    {host: "ldap://dc1.example.com", want: "dc1.example.com"},
+		"IPv4 address":                  {host: "192.0.2.1", want: "192.0.2.1"},
+		"IPv6 address":                  {host: "2001:db8::1", want: "[2001:db8::1]"},
+		"Bracketed IPv6 address":        {host: "[2001:db8::1]", want: "[2001:db8::1]"},
+		"IPv6 address with ldap scheme": {host: "ldap://[2001:db8::1]", want: "[2001:db8::1]"},
+		"IPv6 address with port":        {host: "[2001:db8::1]:389", want: "[2001:db8::1]"},
+		"IPv4-mapped IPv6 address":      {host: "::ffff:192.0.2.1", want: "[::ffff:192.0.2.1]"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := dc.URLHost(tc.host)
+			require.Equal(t, tc.want, got, "URLHost should return the expected URL host")
+		})
+	}
+}
+
+type mockResolver struct {
+	srv    []*net.SRV
+	srvErr bool
+	ips    map[string][]string
+	names  map[string][]string
+
+	addrErr bool
+	srvName string
+}
+
+func (r *mockResolver) LookupSRV(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.srvName = name
+	if r.srvErr {
+		return "", nil, errors.New("SRV lookup error")
+	}
+	return "_" + service + "._" + proto + "." + name, r.srv, nil
+}
+
+// LookupIPAddr only returns AAAA records for IPv6 addresses, and A records for IPv4 ones.
+func (r *mockResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r.ips[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	var addrs []net.IPAddr
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+func (r *mockResolver) LookupAddr(_ context.Context, addr string) ([]string, error) {
+	if r.addrErr {
+		return nil, errors.New("PTR lookup error")
+	}
+	names, ok := r.names[addr]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+	}
+	return names, nil
+}
+
+type mockDialer struct {
+	reachable []string
+	dials     []string
+}
+
+func (d *mockDialer) DialContext(_ context.Context, _, address string) (net.Conn, error) {
+	d.dials = append(d.dials, address)
+	for _, r := range d.reachable {
+		if r == address {
+			c1, c2 := net.Pipe()
+			c2.Close()
+			return c1, nil
+		}
+	}
+	return nil, errors.New("connection refused")
+}
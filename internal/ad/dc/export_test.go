@@ -0,0 +1,10 @@
+package dc
+
+import "time"
+
+// WithTimeNow allows to mock the current time, from which the cooldown is computed.
+func WithTimeNow(now func() time.Time) BreakerOption {
+	return func(o *breakerOptions) {
+		o.now = now
+	}
+}
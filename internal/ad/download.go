@@ -59,36 +59,29 @@ Each gpo entry must be a gpo, with a name, url of the form: smb://<server>/SYSVO
 If krb5Ticket is empty, no authentication is done on samba.
 This should not be called concurrently.
 
+A GPO failing to download is retried once. If it still fails, its cached copy, if any, is used. GPOs without
+any cached copy were never applied and are skipped. It only fails if no GPO could be obtained at all.
+
 It returns if the assets were refreshed or not.
 */
 func (ad *AD) fetch(ctx context.Context, krb5Ticket string, downloadables map[string]string) (assetsWereRefreshed bool, err error) {
 	defer decorate.OnError(&err, gotext.Get("can't download all gpos and assets"))
 
-	// protect env variable and map creation
-	ad.fetchMu.Lock()
-	defer ad.fetchMu.Unlock()
-
-	// Set kerberos ticket.
-	const krb5TicketEnv = "KRB5CCNAME"
-	oldKrb5Ticket := os.Getenv(krb5TicketEnv)
-	if err := os.Setenv(krb5TicketEnv, krb5Ticket); err != nil {
-		return false, err
-	}
-	defer func() {
-		if err := os.Setenv(krb5TicketEnv, oldKrb5Ticket); err != nil {
-			log.Errorf(ctx, "Couln't restore initial value for %s: %v", krb5Ticket, err)
-		}
-	}()
-
-	client := libsmbclient.New()
-	defer client.Close()
-	// When testing we cannot use kerberos without a real kerberos server
-	// So we don't use kerberos in this case
-	if !ad.withoutKerberos {
-		client.SetUseKerberos()
-	}
+	err = ad.withSmbClient(ctx, krb5Ticket, func(client *libsmbclient.Client) (err error) {
+		assetsWereRefreshed, err = ad.fetchAll(ctx, client, downloadables)
+		return err
+	})
+	return assetsWereRefreshed, err
+}
 
+// fetchAll downloads all downloadables with client. fetchMu must be held.
+func (ad *AD) fetchAll(ctx context.Context, client *libsmbclient.Client, downloadables map[string]string) (assetsWereRefreshed bool, err error) {
 	var errg errgroup.Group
+	var failedMu sync.Mutex
+	// failedWithCache and failedWithoutCache are the download errors of the GPOs using their cached copy, and
+	// of the skipped ones.
+	var failedWithCache, failedWithoutCache []error
+	var nGPOs int
 	for name, url := range downloadables {
 		g, ok := ad.downloadables[name]
 		if !ok {
@@ -103,55 +96,42 @@ func (ad *AD) fetch(ctx context.Context, krb5Ticket string, downloadables map[st
 			}
 			g = ad.downloadables[name]
 		}
+		if !g.isAssets {
+			nGPOs++
+		}
 		errg.Go(func() (err error) {
 			defer decorate.OnError(&err, gotext.Get("can't download %q", g.name))
 
 			smbsafe.WaitSmb()
 			defer smbsafe.DoneSmb()
 
-			log.Debugf(ctx, "Analyzing %q", g.name)
-
 			dest := filepath.Join(ad.sysvolCacheDir, "Policies", filepath.Base(g.url))
 			if g.isAssets {
 				dest = filepath.Join(ad.sysvolCacheDir, "assets")
 			}
 
-			// Look at GPO version and compare with the one on AD to decide if we redownload or not
-			shouldDownload, err := needsDownload(ctx, client, g, dest)
-			if err != nil {
-				if g.isAssets && errors.Is(err, errNoGPTINI) {
-					log.Info(ctx, "No assets directory with GPT.INI file found on AD, skipping assets download")
-					if _, err := os.Stat(dest); err == nil {
-						// we remove the assets existing directory. We need to repack the db.
-						assetsWereRefreshed = true
-						if err := os.RemoveAll(dest); err != nil {
-							return err
-						}
-					}
-					return nil
-				}
+			err = ad.fetchOne(ctx, client, g, dest, &assetsWereRefreshed)
+			if err == nil || g.isAssets {
 				return err
 			}
 
-			if !shouldDownload {
-				if g.isAssets {
-					log.Info(ctx, gotext.Get("Assets directory is already up to date"))
-				} else {
-					log.Info(ctx, gotext.Get("GPO %q is already up to date", g.name))
-				}
-
+			log.Warningf(ctx, "Downloading %q failed, retrying: %v", g.name, err)
+			if err = ad.fetchOne(ctx, client, g, dest, &assetsWereRefreshed); err == nil {
 				return nil
 			}
-
-			log.Infof(ctx, "Downloading %q", g.name)
-			g.mu.Lock()
-			defer g.mu.Unlock()
-			g.testConcurrent = true
-			if g.isAssets {
-				assetsWereRefreshed = true
+			err = fmt.Errorf("%s: %w", g.name, err)
+
+			failedMu.Lock()
+			defer failedMu.Unlock()
+			// The cached copy is complete, as downloads are only committed once successful.
+			if _, e := findLocalGPTIni(dest); e != nil {
+				log.Warning(ctx, gotext.Get("Can't download GPO %q and there is no cached copy of it, skipping it: %v", g.name, err))
+				failedWithoutCache = append(failedWithoutCache, err)
+				return nil
 			}
-
-			return downloadDir(ctx, client, g.url, dest)
+			log.Warning(ctx, gotext.Get("Can't download GPO %q, using its cached copy: %v", g.name, err))
+			failedWithCache = append(failedWithCache, err)
+			return nil
 		})
 	}
 
@@ -159,9 +139,100 @@ func (ad *AD) fetch(ctx context.Context, krb5Ticket string, downloadables map[st
 		return false, fmt.Errorf("one or more error while fetching GPOs and assets: %w", err)
 	}
 
+	if len(failedWithoutCache) > 0 && len(failedWithoutCache) == nGPOs {
+		return false, fmt.Errorf("no GPO could be obtained: %w", errors.Join(failedWithoutCache...))
+	}
+	if n := len(failedWithCache) + len(failedWithoutCache); n > 0 {
+		log.Warning(ctx, gotext.Get("Partial GPO download failure: %d GPO(s) out of %d couldn't be downloaded, %d of them from their cached copy and %d skipped",
+			n, nGPOs, len(failedWithCache), len(failedWithoutCache)))
+	}
+
 	return assetsWereRefreshed, nil
 }
 
+// withSmbClient runs f with a samba client authenticated with the krb5Ticket ticket cache.
+// The KRB5CCNAME environment variable and the downloadables are protected while f runs.
+func (ad *AD) withSmbClient(ctx context.Context, krb5Ticket string, f func(client *libsmbclient.Client) error) error {
+	// protect env variable and map creation
+	ad.fetchMu.Lock()
+	defer ad.fetchMu.Unlock()
+
+	// Set kerberos ticket.
+	const krb5TicketEnv = "KRB5CCNAME"
+	oldKrb5Ticket := os.Getenv(krb5TicketEnv)
+	if err := os.Setenv(krb5TicketEnv, krb5Ticket); err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.Setenv(krb5TicketEnv, oldKrb5Ticket); err != nil {
+			log.Errorf(ctx, "Couln't restore initial value for %s: %v", krb5Ticket, err)
+		}
+	}()
+
+	// The protocols are global to libsmbclient: set them before creating the client.
+	if err := ad.setSMBProtocols(ad.smbMinProtocol, ad.smbMaxProtocol); err != nil {
+		return err
+	}
+	maxProtocol := ad.smbMaxProtocol
+	if maxProtocol == "" {
+		maxProtocol = gotext.Get("library default")
+	}
+	log.Debugf(ctx, "Accessing SYSVOL with SMB protocols from %s to %s", ad.smbMinProtocol, maxProtocol)
+
+	client := libsmbclient.New()
+	defer client.Close()
+	// When testing we cannot use kerberos without a real kerberos server
+	// So we don't use kerberos in this case
+	if !ad.withoutKerberos {
+		client.SetUseKerberos()
+	}
+
+	return f(client)
+}
+
+// fetchOne downloads the downloadable g to dest if it is outdated. assetsWereRefreshed is set if g are the
+// assets and they were refreshed.
+func (ad *AD) fetchOne(ctx context.Context, client *libsmbclient.Client, g *downloadable, dest string, assetsWereRefreshed *bool) error {
+	log.Debugf(ctx, "Analyzing %q", g.name)
+
+	// Look at GPO version and compare with the one on AD to decide if we redownload or not
+	shouldDownload, err := needsDownload(ctx, client, g, dest)
+	if err != nil {
+		if g.isAssets && errors.Is(err, errNoGPTINI) {
+			log.Info(ctx, "No assets directory with GPT.INI file found on AD, skipping assets download")
+			if _, err := os.Stat(dest); err == nil {
+				// we remove the assets existing directory. We need to repack the db.
+				*assetsWereRefreshed = true
+				if err := os.RemoveAll(dest); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return err
+	}
+
+	if !shouldDownload {
+		if g.isAssets {
+			log.Info(ctx, gotext.Get("Assets directory is already up to date"))
+		} else {
+			log.Info(ctx, gotext.Get("GPO %q is already up to date", g.name))
+		}
+
+		return nil
+	}
+
+	log.Infof(ctx, "Downloading %q", g.name)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.testConcurrent = true
+	if g.isAssets {
+		*assetsWereRefreshed = true
+	}
+
+	return downloadDir(ctx, client, g.url, dest)
+}
+
 var errNoGPTINI = errors.New("no GPT.INI file")
 
 // needsDownload returns if the downloadable should be refreshed.
@@ -172,26 +243,13 @@ func needsDownload(ctx context.Context, client *libsmbclient.Client, g *download
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 
-	var localVersion, remoteVersion int
-	if gptIniPath, err := findLocalGPTIni(localPath); err == nil {
-		if f, err := os.Open(filepath.Clean(gptIniPath)); err == nil {
-			defer decorate.LogFuncOnErrorContext(ctx, f.Close)
-
-			if localVersion, err = getGPOVersion(ctx, f, g.name); err != nil {
-				log.Warningf(ctx, "Invalid local GPT.INI for %s: %v\nDownloading it again…", g.name, err)
-			}
-		}
+	localVersion, err := localGPOVersion(ctx, localPath, g.name)
+	if err != nil {
+		log.Warningf(ctx, "Invalid local GPT.INI for %s: %v\nDownloading it again…", g.name, err)
 	}
 
-	f, err := client.Open(fmt.Sprintf("%s/GPT.INI", g.url), 0, 0)
+	remoteVersion, err := remoteGPOVersion(ctx, client, g.url, g.name)
 	if err != nil {
-		// nolint:errorlint // We cannot have multiple error wrapping directives in a single call
-		return false, fmt.Errorf("%w: %v", errNoGPTINI, err)
-	}
-	defer f.Close()
-	// Read() is on *libsmbclient.File, not libsmbclient.File
-	pf := &f
-	if remoteVersion, err = getGPOVersion(ctx, pf, g.name); err != nil {
 		return false, err
 	}
 
@@ -203,6 +261,35 @@ func needsDownload(ctx context.Context, client *libsmbclient.Client, g *download
 	return true, nil
 }
 
+// localGPOVersion returns the version of the GPT.INI cached in localPath.
+// It returns 0 without any error if there is no cached GPT.INI.
+func localGPOVersion(ctx context.Context, localPath, downloadableName string) (version int, err error) {
+	gptIniPath, err := findLocalGPTIni(localPath)
+	if err != nil {
+		return 0, nil
+	}
+	f, err := os.Open(filepath.Clean(gptIniPath))
+	if err != nil {
+		return 0, nil
+	}
+	defer decorate.LogFuncOnErrorContext(ctx, f.Close)
+
+	return getGPOVersion(ctx, f, downloadableName)
+}
+
+// remoteGPOVersion returns the version of the GPT.INI available at url on AD.
+func remoteGPOVersion(ctx context.Context, client *libsmbclient.Client, url, downloadableName string) (version int, err error) {
+	f, err := client.Open(fmt.Sprintf("%s/GPT.INI", url), 0, 0)
+	if err != nil {
+		// nolint:errorlint // We cannot have multiple error wrapping directives in a single call
+		return 0, fmt.Errorf("%w: %v", errNoGPTINI, err)
+	}
+	defer f.Close()
+	// Read() is on *libsmbclient.File, not libsmbclient.File
+	pf := &f
+	return getGPOVersion(ctx, pf, downloadableName)
+}
+
 func getGPOVersion(ctx context.Context, r io.Reader, downloadableName string) (version int, err error) {
 	defer decorate.OnError(&err, gotext.Get("invalid remote GPT.INI"))
 
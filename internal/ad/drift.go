@@ -0,0 +1,98 @@
+package ad
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/mvo5/libsmbclient-go"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+// GPODrift is the version of a GPO in the cache, compared to its current version on the AD controller.
+type GPODrift struct {
+	Name string
+	ID   string
+
+	// Cached is false if the GPO was never downloaded. CachedVersion is then meaningless.
+	Cached         bool
+	CachedVersion  int
+	CurrentVersion int
+}
+
+// Drifted returns true if the GPO would be downloaded again on next update.
+func (d GPODrift) Drifted() bool {
+	return !d.Cached || d.CachedVersion < d.CurrentVersion
+}
+
+// GPOVersionDrift returns, for each GPO applicable to objectName and in order of priority, its cached version and
+// its current version on the AD controller. Nothing is downloaded nor applied.
+// Versions are compared the same way than when fetching the policies, to decide if a GPO needs to be refreshed.
+// userKrb5CCName has no impact for computer object and is ignored. If empty, we will expect to find one cached
+// ticket <krb5CCDir>/<objectName>.
+func (ad *AD) GPOVersionDrift(ctx context.Context, objectName string, objectClass ObjectClass, userKrb5CCName string) (drifts []GPODrift, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't get GPO version drift for %q", objectName))
+
+	log.Debugf(ctx, "GPOVersionDrift for %q, type %q", objectName, objectClass)
+
+	krb5CCPath, orderedGPOs, err := ad.onlineGPOs(ctx, objectName, objectClass, userKrb5CCName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ad.withSmbClient(ctx, krb5CCPath, func(client *libsmbclient.Client) error {
+		smbsafe.WaitSmb()
+		defer smbsafe.DoneSmb()
+
+		for _, g := range orderedGPOs {
+			d := GPODrift{Name: g.name, ID: filepath.Base(g.url)}
+
+			localPath := filepath.Join(ad.sysvolCacheDir, "Policies", d.ID)
+			if _, err := findLocalGPTIni(localPath); err == nil {
+				d.Cached = true
+				if d.CachedVersion, err = localGPOVersion(ctx, localPath, g.name); err != nil {
+					log.Warningf(ctx, "Invalid local GPT.INI for %s: %v", g.name, err)
+				}
+			}
+
+			if d.CurrentVersion, err = remoteGPOVersion(ctx, client, g.url, g.name); err != nil {
+				return err
+			}
+			log.Debugf(ctx, "Cached version for %q: %d (cached: %t), current version: %d", g.name, d.CachedVersion, d.Cached, d.CurrentVersion)
+
+			drifts = append(drifts, d)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return drifts, nil
+}
+
+// onlineGPOs returns the GPOs applicable to objectName, in order of priority, as listed by the AD controller, with the
+// ticket cache to reach it. It fails if the machine is offline.
+func (ad *AD) onlineGPOs(ctx context.Context, objectName string, objectClass ObjectClass, userKrb5CCName string) (krb5CCPath string, orderedGPOs []gpo, err error) {
+	krb5CCPath, err = ad.prepareKrb5CC(objectName, objectClass, userKrb5CCName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	online, err := ad.configBackend.IsOnline()
+	if err != nil {
+		return "", nil, err
+	}
+	if !online {
+		return "", nil, errors.New(gotext.Get("machine is offline, can't reach the AD controller"))
+	}
+
+	_, orderedGPOs, err = ad.listGPOs(ctx, objectName, objectClass, krb5CCPath, "")
+	if err != nil {
+		return "", nil, err
+	}
+	return krb5CCPath, orderedGPOs, nil
+}
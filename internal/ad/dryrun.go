@@ -0,0 +1,129 @@
+package ad
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/mvo5/libsmbclient-go"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+// GPOFetch is a GPO applicable to an object, as it would be fetched from the AD controller.
+type GPOFetch struct {
+	Name    string
+	ID      string
+	Version int
+	// Size is the total size in bytes of the GPO files on the AD controller.
+	Size int64
+	// Download is true if the GPO is not cached or if its cached version is older.
+	Download bool
+}
+
+// FetchDryRun returns, for each GPO applicable to objectName and in order of priority, its version and size on the
+// AD controller and if it would be downloaded on next update. Only the GPT.INI files are read from the SYSVOL:
+// nothing is downloaded nor applied.
+// userKrb5CCName has no impact for computer object and is ignored. If empty, we will expect to find one cached
+// ticket <krb5CCDir>/<objectName>.
+func (ad *AD) FetchDryRun(ctx context.Context, objectName string, objectClass ObjectClass, userKrb5CCName string) (gpos []GPOFetch, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't list GPOs to fetch for %q", objectName))
+
+	log.Debugf(ctx, "FetchDryRun for %q, type %q", objectName, objectClass)
+
+	krb5CCPath, orderedGPOs, err := ad.onlineGPOs(ctx, objectName, objectClass, userKrb5CCName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ad.withSmbClient(ctx, krb5CCPath, func(client *libsmbclient.Client) error {
+		smbsafe.WaitSmb()
+		defer smbsafe.DoneSmb()
+
+		for _, g := range orderedGPOs {
+			f := GPOFetch{Name: g.name, ID: filepath.Base(g.url)}
+
+			if f.Version, err = remoteGPOVersion(ctx, client, g.url, g.name); err != nil {
+				return err
+			}
+			if f.Size, err = remoteSize(ctx, client, g.url); err != nil {
+				return err
+			}
+
+			// Same comparison than when fetching the policies.
+			d := GPODrift{CurrentVersion: f.Version}
+			localPath := filepath.Join(ad.sysvolCacheDir, "Policies", f.ID)
+			if _, err := findLocalGPTIni(localPath); err == nil {
+				d.Cached = true
+				if d.CachedVersion, err = localGPOVersion(ctx, localPath, g.name); err != nil {
+					log.Warningf(ctx, "Invalid local GPT.INI for %s: %v", g.name, err)
+				}
+			}
+			f.Download = d.Drifted()
+			log.Debugf(ctx, "GPO %q: version %d, %d bytes, download: %t", g.name, f.Version, f.Size, f.Download)
+
+			gpos = append(gpos, f)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return gpos, nil
+}
+
+// remoteSize returns the total size of the files under the directory url on AD, without reading them.
+func remoteSize(ctx context.Context, client *libsmbclient.Client, url string) (size int64, err error) {
+	d, err := client.Opendir(url)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := d.Closedir(); err != nil {
+			log.Info(ctx, "Could not close directory:", err)
+		}
+	}()
+
+	for {
+		dirent, err := d.Readdir()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if dirent.Name == "." || dirent.Name == ".." {
+			continue
+		}
+
+		entityURL := url + "/" + dirent.Name
+		switch dirent.Type {
+		case libsmbclient.SmbcFile:
+			f, err := client.Open(entityURL, 0, 0)
+			if err != nil {
+				return 0, err
+			}
+			n, err := f.Lseek(0, io.SeekEnd)
+			f.Close()
+			if err != nil {
+				return 0, err
+			}
+			size += int64(n)
+		case libsmbclient.SmbcDir:
+			n, err := remoteSize(ctx, client, entityURL)
+			if err != nil {
+				return 0, err
+			}
+			size += n
+		default:
+			return 0, fmt.Errorf("unsupported type %q for entry %s", dirent.Type, dirent.Name)
+		}
+	}
+	return size, nil
+}
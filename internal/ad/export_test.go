@@ -3,6 +3,13 @@ package ad
 var (
 	WithoutKerberos = withoutKerberos
 	WithGPOListCmd  = withGPOListCmd
+	WithTicketStat  = withTicketStat
+
+	ExpandCCacheName = expandCCacheName
+
+	WithSMBProtocolsSetter = withSMBProtocolsSetter
+
+	WithDCLocatorOptions = withDCLocatorOptions
 )
 
 func (ad *AD) SysvolCacheDir() string {
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -245,6 +246,19 @@ func TestFetch(t *testing.T) {
 			gpos: []string{"gpt_ini_version_missing"},
 		},
 
+		// Partial failures
+		"partial failure skips gpo without cached copy": {
+			gpos: []string{"missing_gpt_ini", "gpo2"},
+			want: map[string]string{"Policies/gpo2": "Policies/gpo2"}},
+		"partial failure uses cached copy of gpo": {
+			gpos:     []string{"missing_gpt_ini", "gpo2"},
+			existing: map[string]string{"Policies/missing_gpt_ini": "Policies/gpo1"},
+			want:     map[string]string{"Policies/missing_gpt_ini": "Policies/gpo1", "Policies/gpo2": "Policies/gpo2"}},
+		"failure of all gpos uses their cached copy": {
+			gpos:     []string{"gpo_does_not_exists"},
+			existing: map[string]string{"Policies/gpo_does_not_exists": "Policies/gpo1"},
+			want:     map[string]string{"Policies/gpo_does_not_exists": "Policies/gpo1"}},
+
 		// Errors
 		"Error unexistant remote gpo": {
 			gpos: []string{"gpo_does_not_exists"}, want: nil, wantErr: true},
@@ -252,10 +266,8 @@ func TestFetch(t *testing.T) {
 			gpos: []string{"missing_gpt_ini"}, want: nil, wantErr: true},
 		"Error remote version NaN": {
 			gpos: []string{"gpt_ini_version_NaN"}, want: nil, wantErr: true},
-		"Error keeps downloading other GPOS": {
-			gpos:    []string{"missing_gpt_ini", "gpo2"},
-			want:    map[string]string{"Policies/gpo2": "Policies/gpo2"},
-			wantErr: true},
+		"Error when no gpo could be obtained": {
+			gpos: []string{"missing_gpt_ini", "gpo_does_not_exists"}, want: nil, wantErr: true},
 		/*
 			This is to cover the error case on os.Removall() to clean up the directory. However
 			Marking the assets/ directory or any subelement read only doesn’t help.
@@ -414,7 +426,7 @@ func TestFetchWithUnreadableFile(t *testing.T) {
 		withExistingGPO bool
 	}{
 		"without gpo initially don’t commit new partial GPO": {},
-		"existing gpo is preserved":                          {withExistingGPO: true},
+		"existing gpo is preserved and used":                 {withExistingGPO: true},
 	}
 
 	for name, tc := range tests {
@@ -437,13 +449,16 @@ func TestFetchWithUnreadableFile(t *testing.T) {
 			}
 
 			assetsRefreshed, err := adc.fetch(context.Background(), "", downloadables)
-			require.NotNil(t, err, "fetch should return an error but didn't")
 
 			if !tc.withExistingGPO {
+				require.NotNil(t, err, "fetch should return an error but didn't")
 				require.NoDirExists(t, filepath.Join(adc.sysvolCacheDir, "Policies", "gpo1"), "GPO directory shouldn't be committed on disk")
 				return
 			}
 
+			// The cached copy is used instead
+			require.NoError(t, err, "fetch returned an error but shouldn't")
+
 			// Diff on each gpo dir content
 			expectSelectedPath := filepath.Join("testdata", "AD", "SYSVOL", "fakegpo.com", "Policies", "old_version")
 			testutils.CompareTreesWithFiltering(t, filepath.Join(adc.sysvolCacheDir, "Policies", "gpo1"), expectSelectedPath, false)
@@ -578,6 +593,64 @@ func TestParseGPOConcurrent(t *testing.T) {
 
 const SmbPort = 1445
 
+func TestNextTicketRenewal(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		expiry time.Time
+		margin time.Duration
+
+		wantRenew bool
+		wantWait  time.Duration
+	}{
+		"Wait until the margin before expiry": {expiry: now.Add(10 * time.Hour), margin: time.Hour, wantWait: 9 * time.Hour},
+		"Wait until expiry without margin":    {expiry: now.Add(time.Hour), wantWait: time.Hour},
+
+		"Renew when entering the margin":      {expiry: now.Add(time.Hour), margin: time.Hour, wantRenew: true},
+		"Renew inside the margin":             {expiry: now.Add(10 * time.Minute), margin: time.Hour, wantRenew: true},
+		"Renew expired ticket":                {expiry: now.Add(-time.Minute), margin: time.Hour, wantRenew: true},
+		"Renew when margin exceeds lifetime":  {expiry: now.Add(time.Hour), margin: 2 * time.Hour, wantRenew: true},
+		"Renew when there is no ticket":       {margin: time.Hour, wantRenew: true},
+		"Renew expired ticket without margin": {expiry: now, wantRenew: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			renew, wait := nextTicketRenewal(now, tc.expiry, tc.margin)
+			require.Equal(t, tc.wantRenew, renew, "nextTicketRenewal should return if the ticket needs to be renewed now")
+			require.Equal(t, tc.wantWait, wait, "nextTicketRenewal should return the time to wait before renewing")
+		})
+	}
+}
+
+func TestTicketRenewalBackoff(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		failures   int
+		maxBackoff time.Duration
+
+		want time.Duration
+	}{
+		"First failure waits the minimum backoff":     {failures: 1, maxBackoff: time.Hour, want: 30 * time.Second},
+		"Backoff doubles on each consecutive failure": {failures: 3, maxBackoff: time.Hour, want: 2 * time.Minute},
+		"Backoff is capped to the maximum":            {failures: 10, maxBackoff: 10 * time.Minute, want: 10 * time.Minute},
+		"Maximum below the minimum backoff is used":   {failures: 1, maxBackoff: 10 * time.Second, want: 10 * time.Second},
+		"Many failures don't overflow":                {failures: 1000, maxBackoff: time.Hour, want: time.Hour},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := ticketRenewalBackoff(tc.failures, tc.maxBackoff)
+			require.Equal(t, tc.want, got, "ticketRenewalBackoff should return the expected backoff")
+		})
+	}
+}
+
 func TestMain(m *testing.M) {
 	// Don’t setup samba or sssd for mock helpers
 	if strings.Contains(strings.Join(os.Args, " "), "TestMock") {
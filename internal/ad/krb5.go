@@ -6,12 +6,16 @@ package ad
 
 #include <krb5.h>
 
-char *get_ticket_path() {
+// get_ticket_path returns the name of the default ticket cache. If the krb5 context can't be initialized, NULL is
+// returned and init_ret is set to the krb5 error code.
+char *get_ticket_path(krb5_error_code *init_ret) {
   krb5_error_code ret;
   krb5_context context;
 
+  *init_ret = 0;
   ret = krb5_init_context(&context);
   if (ret) {
+    *init_ret = ret;
     errno = ret;
     return NULL;
   }
@@ -23,39 +27,227 @@ char *get_ticket_path() {
 
   return strdup(cc_name);
 }
+
+// get_error_description returns the human readable description of the krb5 error code, without any context.
+char *get_error_description(krb5_error_code code) {
+  const char *msg = krb5_get_error_message(NULL, code);
+  char *desc = strdup(msg);
+  krb5_free_error_message(NULL, msg);
+  return desc;
+}
+
+// get_ticket_expiry returns the client principal and end time of the ticket
+// granting ticket stored in cc_name. ENOENT is returned when there is no TGT.
+krb5_error_code get_ticket_expiry(const char *cc_name, char **principal, krb5_timestamp *endtime) {
+  krb5_error_code ret;
+  krb5_context context;
+  krb5_ccache ccache;
+  krb5_principal client;
+  krb5_cc_cursor cursor;
+  krb5_creds creds;
+  char *name = NULL;
+
+  *principal = NULL;
+  *endtime = 0;
+
+  ret = krb5_init_context(&context);
+  if (ret) {
+    return ret;
+  }
+
+  ret = krb5_cc_resolve(context, cc_name, &ccache);
+  if (ret) {
+    goto out_context;
+  }
+
+  ret = krb5_cc_get_principal(context, ccache, &client);
+  if (ret) {
+    goto out_ccache;
+  }
+
+  ret = krb5_unparse_name(context, client, &name);
+  if (ret) {
+    goto out_client;
+  }
+
+  ret = krb5_cc_start_seq_get(context, ccache, &cursor);
+  if (ret) {
+    goto out_name;
+  }
+
+  ret = ENOENT;
+  while (krb5_cc_next_cred(context, ccache, &cursor, &creds) == 0) {
+    if (creds.server->length == 2 && creds.server->data[0].length == 6 &&
+        memcmp(creds.server->data[0].data, "krbtgt", 6) == 0) {
+      *endtime = creds.times.endtime;
+      ret = 0;
+    }
+    krb5_free_cred_contents(context, &creds);
+    if (ret == 0) {
+      break;
+    }
+  }
+  krb5_cc_end_seq_get(context, ccache, &cursor);
+
+  if (ret == 0) {
+    *principal = strdup(name);
+  }
+
+out_name:
+  krb5_free_unparsed_name(context, name);
+out_client:
+  krb5_free_principal(context, client);
+out_ccache:
+  krb5_cc_close(context, ccache);
+out_context:
+  krb5_free_context(context);
+  return ret;
+}
 */
 // #cgo pkg-config: krb5
 import "C"
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/user"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/telemetry"
 )
 
 // ErrTicketNotPresent is returned when the ticket cache is not present or not accessible
 var ErrTicketNotPresent = errors.New(gotext.Get("ticket not found or not accessible"))
 
+// Krb5ContextInitError is returned when the krb5 library context can't be initialized, like on an invalid
+// krb5.conf. No Kerberos operation can succeed on the machine until it is fixed.
+type Krb5ContextInitError struct {
+	// Code is the krb5_error_code returned by krb5_init_context.
+	Code int
+	// Description is the human readable message of Code.
+	Description string
+}
+
+func (e *Krb5ContextInitError) Error() string {
+	return gotext.Get("error initializing krb5 context, krb5_error_code %d: %s", e.Code, e.Description)
+}
+
+// krb5ContextInitFailuresMetric is the counter of the failures to initialize the krb5 context.
+const krb5ContextInitFailuresMetric = "adsys.krb5.context_init_failures"
+
+var (
+	// krb5ContextInitFailures counts the failures to initialize the krb5 context.
+	krb5ContextInitFailures atomic.Uint64
+	// processStart is the start of the krb5ContextInitFailures count.
+	processStart = time.Now()
+)
+
+// Krb5ContextInitFailures returns the number of times the krb5 context failed to initialize since the process
+// started.
+func Krb5ContextInitFailures() uint64 {
+	return krb5ContextInitFailures.Load()
+}
+
+// ExportKrb5ContextInitFailures exports the number of times the krb5 context failed to initialize since the process
+// started, so that it can be alerted on.
+func ExportKrb5ContextInitFailures(ctx context.Context, e *telemetry.Exporter) {
+	e.ExportCounters(ctx, []telemetry.Counter{{
+		Name:        krb5ContextInitFailuresMetric,
+		Description: "Failures to initialize the krb5 library context.",
+		Unit:        "1",
+		Start:       processStart,
+		Value:       int64(Krb5ContextInitFailures()),
+	}})
+}
+
+// newKrb5ContextInitError counts the failure to initialize the krb5 context with code.
+func newKrb5ContextInitError(code C.krb5_error_code) error {
+	krb5ContextInitFailures.Add(1)
+
+	cDesc := C.get_error_description(code)
+	defer C.free(unsafe.Pointer(cDesc))
+	return &Krb5ContextInitError{Code: int(code), Description: C.GoString(cDesc)}
+}
+
+const (
+	// defaultTicketStatRetryTimeout is the default time during which the stat of a file based ticket cache is
+	// retried, to ride out the renewal of the ticket.
+	defaultTicketStatRetryTimeout = 300 * time.Millisecond
+	// ticketStatRetryInterval is the time between two stats of the ticket cache.
+	ticketStatRetryInterval = 50 * time.Millisecond
+)
+
+type ticketPathOptions struct {
+	ccacheName       string
+	statRetryTimeout time.Duration
+	stat             func(string) (fs.FileInfo, error)
+}
+
+// TicketPathOption reprents an optional function to change the ticket path lookup.
+type TicketPathOption func(*ticketPathOptions)
+
+// WithStatRetryTimeout specifies for how long the stat of a file based ticket cache is retried when it fails,
+// like during the renewal of the ticket. 0 disables the retry.
+func WithStatRetryTimeout(timeout time.Duration) TicketPathOption {
+	return func(o *ticketPathOptions) {
+		o.statRetryTimeout = timeout
+	}
+}
+
+// WithCCacheName specifies the ticket cache name to use instead of the default one of the krb5 library, like
+// FILE:/tmp/krb5cc_%{uid}. Its %{uid}-style tokens are expanded for the current user. Empty uses the krb5 default.
+func WithCCacheName(name string) TicketPathOption {
+	return func(o *ticketPathOptions) {
+		o.ccacheName = name
+	}
+}
+
 // TicketPath returns the path of the default kerberos ticket cache for the
 // current user.
-// It returns an error if the path is empty or does not exist on the disk.
-func TicketPath() (string, error) {
-	cKrb5cc, err := C.get_ticket_path()
-	defer C.free(unsafe.Pointer(cKrb5cc))
+// It returns an error if the path is empty or does not exist on the disk, and a *Krb5ContextInitError if the krb5
+// context can't be initialized.
+// Other errors accessing a file based cache are retried for a short time.
+func TicketPath(opts ...TicketPathOption) (string, error) {
+	// defaults
+	args := ticketPathOptions{
+		statRetryTimeout: defaultTicketStatRetryTimeout,
+		stat:             os.Stat,
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	krb5cc, err := defaultCCacheName()
+	if args.ccacheName != "" {
+		// The krb5 library isn't used at all, as its configuration may be the reason for the override.
+		krb5cc, err = expandCCacheName(args.ccacheName)
+	}
 	if err != nil {
-		return "", fmt.Errorf(gotext.Get("error initializing krb5 context, krb5_error_code: %d", err))
+		return "", err
 	}
-	krb5cc := C.GoString(cKrb5cc)
 	if krb5cc == "" {
 		return "", errors.New(gotext.Get("path is empty"))
 	}
 
 	krb5ccPath := strings.TrimPrefix(krb5cc, "FILE:")
-	fileInfo, err := os.Stat(krb5ccPath)
+	// Other cache types, like DIR: or KEYRING:, are not files we can wait for.
+	fileBased := strings.HasPrefix(krb5ccPath, "/")
+	deadline := time.Now().Add(args.statRetryTimeout)
+	fileInfo, err := args.stat(krb5ccPath)
+	for err != nil && fileBased && !errors.Is(err, fs.ErrNotExist) && time.Now().Before(deadline) {
+		time.Sleep(min(ticketStatRetryInterval, time.Until(deadline)))
+		fileInfo, err = args.stat(krb5ccPath)
+	}
 	if err != nil {
 		return "", errors.Join(ErrTicketNotPresent, err)
 	}
@@ -65,3 +257,77 @@ func TicketPath() (string, error) {
 
 	return krb5ccPath, nil
 }
+
+// defaultCCacheName returns the name of the default ticket cache of the krb5 library.
+func defaultCCacheName() (string, error) {
+	var initRet C.krb5_error_code
+	cKrb5cc, err := C.get_ticket_path(&initRet)
+	defer C.free(unsafe.Pointer(cKrb5cc))
+	if initRet != 0 {
+		return "", newKrb5ContextInitError(initRet)
+	}
+	if err != nil {
+		return "", fmt.Errorf(gotext.Get("error initializing krb5 context, krb5_error_code: %d", err))
+	}
+	return C.GoString(cKrb5cc), nil
+}
+
+// ccacheTokenRe matches the tokens of a ticket cache name, like %{uid}.
+var ccacheTokenRe = regexp.MustCompile(`%{[^}]*}`)
+
+// expandCCacheName returns the ticket cache name with its tokens expanded for the current user, as the krb5 library
+// does. Only the tokens naming the user, the temporary directory and null are supported.
+func expandCCacheName(name string) (expanded string, err error) {
+	expanded = ccacheTokenRe.ReplaceAllStringFunc(name, func(token string) string {
+		if err != nil {
+			return ""
+		}
+		switch token {
+		case "%{uid}", "%{USERID}":
+			return strconv.Itoa(os.Getuid())
+		case "%{euid}":
+			return strconv.Itoa(os.Geteuid())
+		case "%{username}":
+			var u *user.User
+			if u, err = user.Current(); err != nil {
+				return ""
+			}
+			return u.Username
+		case "%{TEMP}":
+			return strings.TrimSuffix(os.TempDir(), "/")
+		case "%{null}":
+			return ""
+		}
+		err = errors.New(gotext.Get("unsupported token %s", token))
+		return ""
+	})
+	if err == nil && strings.Contains(expanded, "%{") {
+		err = errors.New(gotext.Get("unterminated token"))
+	}
+	if err != nil {
+		return "", fmt.Errorf(gotext.Get("invalid ticket cache name %q: %v", name, err))
+	}
+	return expanded, nil
+}
+
+// TicketExpiry returns the client principal and the end time of the ticket granting ticket stored in the
+// given kerberos ticket cache.
+// It returns ErrTicketNotPresent if the cache can't be read or doesn't contain any ticket granting ticket.
+func TicketExpiry(krb5cc string) (principal string, expiry time.Time, err error) {
+	cKrb5cc := C.CString(krb5cc)
+	defer C.free(unsafe.Pointer(cKrb5cc))
+
+	var cPrincipal *C.char
+	var cEndtime C.krb5_timestamp
+	ret := C.get_ticket_expiry(cKrb5cc, &cPrincipal, &cEndtime)
+	defer C.free(unsafe.Pointer(cPrincipal))
+	if ret == C.ENOENT {
+		return "", time.Time{}, errors.Join(ErrTicketNotPresent, errors.New(gotext.Get("no ticket granting ticket in %q", krb5cc)))
+	}
+	if ret != 0 {
+		return "", time.Time{}, errors.Join(ErrTicketNotPresent, fmt.Errorf(gotext.Get("can't read ticket cache %q, krb5_error_code: %d", krb5cc, ret)))
+	}
+
+	// krb5_timestamp is a signed 32 bits value to be interpreted as unsigned.
+	return C.GoString(cPrincipal), time.Unix(int64(uint32(cEndtime)), 0), nil
+}
@@ -1,14 +1,24 @@
 package ad_test
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/ubuntu/adsys/internal/ad"
+	"github.com/ubuntu/adsys/internal/telemetry"
 	"github.com/ubuntu/adsys/internal/testutils"
 )
 
@@ -25,29 +35,60 @@ func TestTicketPath(t *testing.T) {
 	}
 
 	tests := map[string]struct {
-		krb5Behavior string
-		ccacheIsDir  bool
+		krb5Behavior          string
+		ccacheName            string
+		ccacheFile            string
+		ccacheIsDir           bool
+		transientStatFailures int
+		noStatRetry           bool
+		statRetryTimeout      time.Duration
 
-		wantErr     bool
-		wantErrType error
+		wantErr            bool
+		wantErrType        error
+		wantContextInitErr bool
+		wantStatCalls      int
 	}{
 		"Lookup is successful":                 {krb5Behavior: "return_ccache:FILE:%s"},
 		"Allow ccache without FILE identifier": {krb5Behavior: "return_ccache:%s"},
 
+		// Transient stat failures
+		"Transient stat failure is retried":        {krb5Behavior: "return_ccache:FILE:%s", transientStatFailures: 2, wantStatCalls: 3},
+		"Missing ccache is not retried":            {krb5Behavior: "return_ccache:FILE:%s/non-existent", wantErr: true, wantStatCalls: 1},
+		"Non-file ccache is not retried":           {krb5Behavior: "return_memory_ccache", transientStatFailures: 2, wantErrType: ad.ErrTicketNotPresent, wantStatCalls: 1},
+		"Stat is not retried if retry is disabled": {krb5Behavior: "return_ccache:FILE:%s", transientStatFailures: 1, noStatRetry: true, wantErrType: ad.ErrTicketNotPresent, wantStatCalls: 1},
+		"Error when stat keeps failing":            {krb5Behavior: "return_ccache:FILE:%s", transientStatFailures: 1000, statRetryTimeout: 120 * time.Millisecond, wantErrType: ad.ErrTicketNotPresent},
+
 		"Error when ccache not present on disk": {krb5Behavior: "return_ccache:FILE:%s/non-existent", wantErr: true},
 		"Error when ccache is a directory":      {krb5Behavior: "return_ccache:%s", ccacheIsDir: true, wantErr: true},
-		"Error when initializing context":       {krb5Behavior: "error_initializing_context", wantErr: true},
+		"Error when initializing context":       {krb5Behavior: "error_initializing_context", wantContextInitErr: true},
 		"Error on empty ticket path":            {krb5Behavior: "return_empty_ccache", wantErr: true},
 		"Error on NULL ticket path":             {krb5Behavior: "return_null_ccache", wantErr: true},
 		"Error on non-FILE ccache":              {krb5Behavior: "return_memory_ccache", wantErrType: ad.ErrTicketNotPresent},
+
+		// Ccache name override
+		"Override replaces krb5 default ccache":              {krb5Behavior: "return_ccache:FILE:%s/non-existent", ccacheName: "FILE:%s/krb5cc_12345"},
+		"Override without FILE identifier":                   {krb5Behavior: "return_ccache:FILE:%s/non-existent", ccacheName: "%s/krb5cc_12345"},
+		"Override tokens are expanded":                       {krb5Behavior: "return_ccache:FILE:%s/non-existent", ccacheName: "FILE:%s/krb5cc_%%{uid}", ccacheFile: fmt.Sprintf("krb5cc_%d", os.Getuid())},
+		"Override is used when context can't be initialized": {krb5Behavior: "error_initializing_context", ccacheName: "FILE:%s/krb5cc_12345"},
+		"Empty override keeps krb5 default ccache":           {krb5Behavior: "return_ccache:FILE:%s", ccacheName: ""},
+
+		"Error when override ccache not present on disk": {krb5Behavior: "return_ccache:FILE:%s", ccacheName: "FILE:%s/non-existent", wantErrType: ad.ErrTicketNotPresent},
+		"Error on override with unsupported token":       {krb5Behavior: "return_ccache:FILE:%s", ccacheName: "FILE:%s/krb5cc_%%{LIBDIR}", wantErr: true},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			wantOut := filepath.Join(t.TempDir(), "krb5cc_12345")
+			if tc.ccacheFile == "" {
+				tc.ccacheFile = "krb5cc_12345"
+			}
+			dir := t.TempDir()
+			wantOut := filepath.Join(dir, tc.ccacheFile)
 			if strings.Contains(tc.krb5Behavior, "return_ccache") {
 				tc.krb5Behavior = fmt.Sprintf(tc.krb5Behavior, wantOut)
 			}
+			if tc.ccacheName != "" {
+				tc.ccacheName = fmt.Sprintf(tc.ccacheName, dir)
+			}
 
 			// Set up mock libwbclient behavior
 			t.Setenv("ADSYS_KRB5_BEHAVIOR", tc.krb5Behavior)
@@ -60,7 +101,37 @@ func TestTicketPath(t *testing.T) {
 			}
 			require.NoError(t, err, "Setup: Failed to create path to ticket cache")
 
-			ticketPath, err := ad.TicketPath()
+			var statCalls int
+			opts := []ad.TicketPathOption{ad.WithTicketStat(func(p string) (fs.FileInfo, error) {
+				statCalls++
+				if statCalls <= tc.transientStatFailures {
+					return nil, &fs.PathError{Op: "stat", Path: p, Err: syscall.EIO}
+				}
+				return os.Stat(p)
+			})}
+			if tc.ccacheName != "" {
+				opts = append(opts, ad.WithCCacheName(tc.ccacheName))
+			}
+			if tc.noStatRetry {
+				opts = append(opts, ad.WithStatRetryTimeout(0))
+			} else if tc.statRetryTimeout > 0 {
+				opts = append(opts, ad.WithStatRetryTimeout(tc.statRetryTimeout))
+			}
+
+			initFailures := ad.Krb5ContextInitFailures()
+			ticketPath, err := ad.TicketPath(opts...)
+			if tc.wantContextInitErr {
+				var initErr *ad.Krb5ContextInitError
+				require.ErrorAs(t, err, &initErr, "TicketPath should have returned a krb5 context initialization error")
+				require.NotZero(t, initErr.Code, "Error should have the krb5 error code")
+				require.NotEmpty(t, initErr.Description, "Error should have the description of the krb5 error code")
+				require.Equal(t, initFailures+1, ad.Krb5ContextInitFailures(), "TicketPath should have counted the failure")
+				return
+			}
+			require.Equal(t, initFailures, ad.Krb5ContextInitFailures(), "TicketPath should not have counted any failure")
+			if tc.wantStatCalls > 0 {
+				require.Equal(t, tc.wantStatCalls, statCalls, "TicketPath should have checked the ticket cache the expected number of times")
+			}
 			if tc.wantErr || tc.wantErrType != nil {
 				require.Error(t, err, "TicketPath should have errored out")
 				if tc.wantErrType != nil {
@@ -74,3 +145,64 @@ func TestTicketPath(t *testing.T) {
 		})
 	}
 }
+
+// TestExportKrb5ContextInitFailures is not parallel, as TestTicketPath counts failures.
+func TestExportKrb5ContextInitFailures(t *testing.T) {
+	var metrics []string
+	collector := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		metrics = append(metrics, string(body))
+	}))
+	defer collector.Close()
+	exporter, err := telemetry.New(collector.URL, "myhost")
+	require.NoError(t, err, "Setup: can't create telemetry exporter")
+
+	ad.ExportKrb5ContextInitFailures(context.Background(), exporter)
+
+	require.Len(t, metrics, 1, "The failures should be exported")
+	require.Contains(t, metrics[0], `"adsys.krb5.context_init_failures"`, "The failures should be exported as a counter")
+	require.Contains(t, metrics[0], `"isMonotonic":true`, "The failures should be exported as a counter")
+	require.Contains(t, metrics[0], fmt.Sprintf(`"asInt":"%d"`, ad.Krb5ContextInitFailures()), "The number of failures should be exported")
+}
+
+func TestExpandCCacheName(t *testing.T) {
+	t.Parallel()
+
+	u, err := user.Current()
+	require.NoError(t, err, "Setup: can't get current user")
+	uid, euid := strconv.Itoa(os.Getuid()), strconv.Itoa(os.Geteuid())
+
+	tests := map[string]struct {
+		name string
+
+		want    string
+		wantErr bool
+	}{
+		"Name without token is kept":    {name: "FILE:/tmp/krb5cc", want: "FILE:/tmp/krb5cc"},
+		"Uid token is expanded":         {name: "FILE:/tmp/krb5cc_%{uid}", want: "FILE:/tmp/krb5cc_" + uid},
+		"USERID token is expanded":      {name: "FILE:/tmp/krb5cc_%{USERID}", want: "FILE:/tmp/krb5cc_" + uid},
+		"Euid token is expanded":        {name: "FILE:/tmp/krb5cc_%{euid}", want: "FILE:/tmp/krb5cc_" + euid},
+		"Username token is expanded":    {name: "FILE:/tmp/krb5cc_%{username}", want: "FILE:/tmp/krb5cc_" + u.Username},
+		"TEMP token is expanded":        {name: "FILE:%{TEMP}/krb5cc", want: "FILE:" + strings.TrimSuffix(os.TempDir(), "/") + "/krb5cc"},
+		"Null token is removed":         {name: "FILE:/tmp/krb5cc%{null}", want: "FILE:/tmp/krb5cc"},
+		"Multiple tokens are expanded":  {name: "DIR:/run/user/%{uid}/krb5cc_%{uid}", want: "DIR:/run/user/" + uid + "/krb5cc_" + uid},
+		"Percent without brace is kept": {name: "FILE:/tmp/krb5cc_%uid", want: "FILE:/tmp/krb5cc_%uid"},
+
+		"Error on unsupported token":  {name: "FILE:%{LIBDIR}/krb5cc", wantErr: true},
+		"Error on unterminated token": {name: "FILE:/tmp/krb5cc_%{uid", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ad.ExpandCCacheName(tc.name)
+			if tc.wantErr {
+				require.Error(t, err, "ExpandCCacheName should have errored out")
+				return
+			}
+			require.NoError(t, err, "ExpandCCacheName failed but shouldn't have")
+			require.Equal(t, tc.want, got, "ExpandCCacheName returned an unexpected name")
+		})
+	}
+}
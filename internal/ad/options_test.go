@@ -1,5 +1,12 @@
 package ad
 
+import (
+	"io/fs"
+	"time"
+
+	"github.com/ubuntu/adsys/internal/ad/dc"
+)
+
 func withoutKerberos() Option {
 	return func(o *options) error {
 		o.withoutKerberos = true
@@ -21,3 +28,31 @@ func WithVersionID(versionID string) Option {
 		return nil
 	}
 }
+
+// WithTicketExpiry specifies a personalized kerberos ticket cache accessor.
+func WithTicketExpiry(f func(string) (string, time.Time, error)) Option {
+	return func(o *options) error {
+		o.ticketExpiry = f
+		return nil
+	}
+}
+
+func withDCLocatorOptions(opts ...dc.Option) Option {
+	return func(o *options) error {
+		o.dcLocatorOpts = opts
+		return nil
+	}
+}
+
+func withTicketStat(stat func(string) (fs.FileInfo, error)) TicketPathOption {
+	return func(o *ticketPathOptions) {
+		o.stat = stat
+	}
+}
+
+func withSMBProtocolsSetter(f func(minProtocol, maxProtocol string) error) Option {
+	return func(o *options) error {
+		o.setSMBProtocols = f
+		return nil
+	}
+}
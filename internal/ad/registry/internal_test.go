@@ -248,6 +248,40 @@ func TestReadPolicy(t *testing.T) {
 	}
 }
 
+func TestDecodeMultiSz(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		data []byte
+
+		want    []string
+		wantErr bool
+	}{
+		"Multiple strings":             {data: toUtf16(t, "user1@domain\x00%group@domain\x00"), want: []string{"user1@domain", "%group@domain"}},
+		"Single string":                {data: toUtf16(t, "user1@domain\x00"), want: []string{"user1@domain"}},
+		"Non ASCII strings":            {data: toUtf16(t, "utilisateur@domaine\x00groupe é@domaine\x00"), want: []string{"utilisateur@domaine", "groupe é@domaine"}},
+		"Missing list terminator":      {data: toUtf16(t, "user1@domain\x00user2@domain"), want: []string{"user1@domain", "user2@domain"}},
+		"Empty strings are skipped":    {data: toUtf16(t, "user1@domain\x00\x00user2@domain\x00"), want: []string{"user1@domain", "user2@domain"}},
+		"Empty list":                   {data: toUtf16(t, "\x00"), want: nil},
+		"No data":                      {data: []byte{}, want: nil},
+		"Error on odd number of bytes": {data: []byte("a\x00b"), wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := decodeMultiSz(tc.data)
+			if tc.wantErr {
+				require.Error(t, err, "decodeMultiSz should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "decodeMultiSz should not have failed")
+			require.Equal(t, tc.want, got, "decodeMultiSz returned unexpected values")
+		})
+	}
+}
+
 func policyFilePath(name string) string {
 	return filepath.Join("testdata", strings.ReplaceAll(strings.ReplaceAll(name, ",", "_"), " ", "_")+".pol")
 }
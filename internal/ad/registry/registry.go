@@ -45,14 +45,22 @@ const (
 )
 
 type meta struct {
-	Empty    string
-	Meta     string
-	Strategy string
+	Empty     string
+	Meta      string
+	Strategy  string
+	Condition string
 }
 
+// ErrInvalidPolicy is returned when a policy stream can't be parsed.
+var ErrInvalidPolicy = errors.New(gotext.Get("can't parse policy"))
+
 // DecodePolicy parses a policy stream in registry file format and returns a slice of entries.
 func DecodePolicy(r io.Reader) (entries []entry.Entry, err error) {
-	defer decorate.OnError(&err, gotext.Get("can't parse policy"))
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("%w: %w", ErrInvalidPolicy, err)
+		}
+	}()
 
 	ent, err := readPolicy(r)
 	if err != nil {
@@ -129,7 +137,7 @@ func DecodePolicy(r io.Reader) (entries []entry.Entry, err error) {
 		// if the key is enabled, load value (or replace with default values for empty results)
 		if !disabled {
 			switch t := e.dType; t {
-			case regSz, regMultiSz:
+			case regSz:
 				res, err = decodeUtf16(e.data)
 				if err != nil {
 					return nil, err
@@ -137,9 +145,15 @@ func DecodePolicy(r io.Reader) (entries []entry.Entry, err error) {
 				if res == "" {
 					res = metaValues[e.key].Empty
 				}
-				// lines separators for multi lines textbox are \x00
-				if t == regMultiSz {
-					res = strings.ReplaceAll(res, "\x00", "\n")
+			case regMultiSz:
+				// Lists, like multi lines textboxes, are consumed by the managers as one element per line.
+				values, err := decodeMultiSz(e.data)
+				if err != nil {
+					return nil, err
+				}
+				res = strings.Join(values, "\n")
+				if res == "" {
+					res = metaValues[e.key].Empty
 				}
 			case regDword:
 				var resInt uint32
@@ -154,12 +168,13 @@ func DecodePolicy(r io.Reader) (entries []entry.Entry, err error) {
 		}
 
 		entries = append(entries, entry.Entry{
-			Key:      filepath.Join(e.path, e.key),
-			Value:    res,
-			Disabled: disabled,
-			Meta:     metaValues[e.key].Meta,
-			Strategy: metaValues[e.key].Strategy,
-			Err:      e.err,
+			Key:       filepath.Join(e.path, e.key),
+			Value:     res,
+			Disabled:  disabled,
+			Meta:      metaValues[e.key].Meta,
+			Strategy:  metaValues[e.key].Strategy,
+			Condition: metaValues[e.key].Condition,
+			Err:       e.err,
 		})
 	}
 
@@ -301,6 +316,26 @@ func decodeUtf16(b []byte) (string, error) {
 	return string(utf16.Decode(ints)), nil
 }
 
+// decodeMultiSz returns the strings of a REG_MULTI_SZ value: UTF-16 strings, each terminated by \0, with
+// the list terminated by an additional \0. Empty strings, which can only come from a truncated or padded
+// list, are skipped.
+func decodeMultiSz(b []byte) (values []string, err error) {
+	if len(b)%2 != 0 {
+		return nil, fmt.Errorf("%x is not a valid UTF-16 string list", b)
+	}
+	ints := make([]uint16, len(b)/2)
+	if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, &ints); err != nil {
+		return nil, err
+	}
+	for _, v := range strings.Split(string(utf16.Decode(ints)), "\x00") {
+		if v == "" {
+			continue
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
 // getMetaValues returns meta values (including empty value) for options.
 func getMetaValues(data []byte, keypath string) (metaValues map[string]meta, err error) {
 	defer decorate.OnError(&err, gotext.Get("can't decode meta value for %s: %v", keypath, err))
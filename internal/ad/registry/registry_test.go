@@ -43,6 +43,13 @@ func TestDecodePolicy(t *testing.T) {
 					Value: "B\nA",
 				},
 			}},
+		"one element, multi string list value": {
+			want: []entry.Entry{
+				{
+					Key:   defaultKey,
+					Value: "user1@domain\n%group@domain\ndomain\\user2",
+				},
+			}},
 		"two elements": {
 			want: []entry.Entry{
 				{
@@ -116,6 +123,15 @@ func TestDecodePolicy(t *testing.T) {
 					Strategy: "override",
 				},
 			}},
+		"basic type with condition": {
+			want: []entry.Entry{
+				{
+					Key:       `Software/Policies/Ubuntu/privilege/allow-local-admins/all`,
+					Value:     "",
+					Meta:      "foo",
+					Condition: "chassis==laptop",
+				},
+			}},
 		"basic type is ignored for meta of wrong type": {
 			want: nil},
 
@@ -127,6 +143,13 @@ func TestDecodePolicy(t *testing.T) {
 					Value: "containerDefaultValueForChild",
 				},
 			}},
+		"container with default elements override empty list values": {
+			want: []entry.Entry{
+				{
+					Key:   `Software/Container/Child`,
+					Value: "defaultElem1\ndefaultElem2",
+				},
+			}},
 		"container with default elements are ignored on non empty option values": {
 			want: []entry.Entry{
 				{
@@ -386,6 +409,7 @@ func TestDecodePolicy(t *testing.T) {
 			rules, err := registry.DecodePolicy(f)
 			if tc.wantErr {
 				require.NotNil(t, err, "readPolicy returned no error when expecting one")
+				require.ErrorIs(t, err, registry.ErrInvalidPolicy, "readPolicy should return an invalid policy error")
 			} else {
 				require.NoError(t, err, "readPolicy returned an error when expecting none")
 			}
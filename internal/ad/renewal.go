@@ -0,0 +1,145 @@
+package ad
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// minTicketRenewalBackoff is the time waited after a first failure to renew the machine ticket. It doubles on
+	// each consecutive failure, up to the maximum backoff.
+	minTicketRenewalBackoff = 30 * time.Second
+	// defaultTicketRenewalMaxBackoff is the default maximum time waited between two failed renewals.
+	defaultTicketRenewalMaxBackoff = 10 * time.Minute
+)
+
+// WithTicketRenewal enables the renewal of the machine ticket, from the machine keytab, margin before it expires.
+// maxBackoff is the maximum time waited between two failed renewals. 0 selects the default.
+func WithTicketRenewal(margin, maxBackoff time.Duration) Option {
+	return func(o *options) error {
+		if margin < 0 {
+			return errors.New(gotext.Get("ticket renewal margin can't be negative: %v", margin))
+		}
+		if maxBackoff < 0 {
+			return errors.New(gotext.Get("ticket renewal maximum backoff can't be negative: %v", maxBackoff))
+		}
+		o.ticketRenewalMargin = margin
+		if maxBackoff != 0 {
+			o.ticketRenewalMaxBackoff = maxBackoff
+		}
+		return nil
+	}
+}
+
+// nextTicketRenewal returns if a ticket expiring at expiry needs to be renewed now, margin before it expires, or
+// how long to wait before renewing it. A zero expiry, when there is no ticket, is renewed now.
+func nextTicketRenewal(now, expiry time.Time, margin time.Duration) (renew bool, wait time.Duration) {
+	if expiry.IsZero() {
+		return true, 0
+	}
+	wait = expiry.Add(-margin).Sub(now)
+	if wait <= 0 {
+		return true, 0
+	}
+	return false, wait
+}
+
+// ticketRenewalBackoff returns the time to wait after the given number of consecutive failed renewals.
+func ticketRenewalBackoff(failures int, maxBackoff time.Duration) time.Duration {
+	backoff := minTicketRenewalBackoff
+	for i := 1; i < failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// RenewMachineTicket renews the machine ticket of the daemon from the machine keytab before it expires, until ctx
+// is cancelled. Failed renewals are retried with an exponential backoff. Nothing is done if the renewal is not
+// enabled.
+// Only the daemon machine ticket cache is renewed, never the backend or the users ones.
+func (ad *AD) RenewMachineTicket(ctx context.Context) {
+	if ad.ticketRenewalMargin == 0 {
+		return
+	}
+	log.Debugf(ctx, "Renewing machine ticket in %q %v before it expires", ad.machineKrb5CCPath, ad.ticketRenewalMargin)
+
+	var failures int
+	var renewed bool
+	for {
+		principal, expiry, err := ad.ticketExpiry(ad.machineKrb5CCPath)
+		if err != nil {
+			log.Debugf(ctx, "No usable machine ticket: %v", err)
+			expiry = time.Time{}
+		}
+
+		renew, wait := nextTicketRenewal(time.Now(), expiry, ad.ticketRenewalMargin)
+		switch {
+		case renew && renewed:
+			// The new ticket is already in the margin: don't renew it in a loop.
+			log.Warning(ctx, gotext.Get("Machine ticket lifetime is shorter than the ticket renewal margin %v", ad.ticketRenewalMargin))
+			wait = ad.ticketRenewalMaxBackoff
+			renewed = false
+		case renew:
+			if err := ad.renewMachineTicket(ctx, principal); err != nil {
+				failures++
+				wait = ticketRenewalBackoff(failures, ad.ticketRenewalMaxBackoff)
+				log.Warning(ctx, gotext.Get("Retrying to renew the machine ticket in %v: %v", wait, err))
+				break
+			}
+			failures = 0
+			renewed = true
+			continue
+		default:
+			renewed = false
+			log.Debugf(ctx, "Machine ticket expires at %v, renewing it in %v", expiry, wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// renewMachineTicket gets a new ticket for principal from the machine keytab and replaces the daemon machine ticket
+// cache with it. The principal of the machine account is used if principal is empty.
+func (ad *AD) renewMachineTicket(ctx context.Context, principal string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't renew machine ticket"))
+
+	if principal == "" {
+		hostname, _, _ := strings.Cut(ad.hostname, ".")
+		principal = fmt.Sprintf("%s$@%s", strings.ToUpper(hostname), strings.ToUpper(ad.configBackend.Domain()))
+	}
+	log.Debugf(ctx, "Renewing machine ticket of %s", principal)
+
+	// Get the ticket in a separate cache, so that the policy fetches never read a partial one.
+	newKrb5CCPath := ad.machineKrb5CCPath + ".renew"
+	defer os.Remove(newKrb5CCPath)
+
+	args := append([]string{}, ad.kinitCmd...)
+	args = append(args, "-k", "-c", "FILE:"+newKrb5CCPath, principal)
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return errors.New(gotext.Get("kinit failed: %v\n%s", err, string(out)))
+	}
+
+	// The renewed ticket is newer than the backend one, so it is not replaced by it until the backend renews it too.
+	ad.Lock()
+	defer ad.Unlock()
+	return os.Rename(newKrb5CCPath, ad.machineKrb5CCPath)
+}
@@ -0,0 +1,61 @@
+package ad
+
+import (
+	"context"
+	"errors"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies"
+	"github.com/ubuntu/decorate"
+)
+
+// SimulatePolicies returns the policies computerName would get if it was located in the organizational unit ou,
+// stacked in order of priority as GetPolicies does. The GPOs are downloaded to the sysvol cache, but nothing is
+// applied nor cached for the computer.
+// The AD controller is queried with the machine ticket. computerName must exist in AD, as its groups filter the GPOs.
+func (ad *AD) SimulatePolicies(ctx context.Context, computerName, ou string) (pols policies.Policies, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't simulate policies for %q in %q", computerName, ou))
+
+	log.Debugf(ctx, "SimulatePolicies for %q in %q", computerName, ou)
+
+	if ou == "" {
+		return pols, errors.New(gotext.Get("an organizational unit is required"))
+	}
+
+	krb5CCPath, err := ad.prepareKrb5CC(ad.hostname, ComputerObject, "")
+	if err != nil {
+		return pols, err
+	}
+
+	online, err := ad.configBackend.IsOnline()
+	if err != nil {
+		return pols, err
+	}
+	if !online {
+		return pols, errors.New(gotext.Get("machine is offline, can't reach the AD controller"))
+	}
+
+	downloadables, orderedGPOs, err := ad.listGPOs(ctx, computerName, ComputerObject, krb5CCPath, ou)
+	if err != nil {
+		return pols, err
+	}
+
+	ad.Lock()
+	defer ad.Unlock()
+	assetsWereRefresh, err := ad.fetch(ctx, krb5CCPath, downloadables)
+	if err != nil {
+		return pols, err
+	}
+
+	gposRules, err := ad.parseGPOs(ctx, orderedGPOs, ComputerObject)
+	if err != nil {
+		return pols, err
+	}
+	assetsDbPath, err := ad.assetsDB(ctx, assetsWereRefresh)
+	if err != nil {
+		return pols, err
+	}
+
+	return policies.New(ctx, gposRules, assetsDbPath)
+}
@@ -0,0 +1,37 @@
+package ad
+
+/*
+#cgo pkg-config: smbclient
+#include <stdlib.h>
+#include <unistd.h>
+
+#include <libsmbclient.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+
+	"github.com/leonelquinteros/gotext"
+)
+
+// setSMBProtocols sets the minimum and maximum SMB protocols negotiated by libsmbclient. An empty protocol keeps the
+// library default.
+// The protocols are global to the process: libsmbclient doesn't attach them to any client context.
+func setSMBProtocols(minProtocol, maxProtocol string) error {
+	var cMin, cMax *C.char
+	if minProtocol != "" {
+		cMin = C.CString(minProtocol)
+		defer C.free(unsafe.Pointer(cMin))
+	}
+	if maxProtocol != "" {
+		cMax = C.CString(maxProtocol)
+		defer C.free(unsafe.Pointer(cMax))
+	}
+
+	if C.smbc_setOptionProtocols(nil, cMin, cMax) == 0 {
+		return errors.New(gotext.Get("can't set SMB protocols from %q to %q", minProtocol, maxProtocol))
+	}
+	return nil
+}
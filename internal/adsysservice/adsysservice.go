@@ -17,14 +17,20 @@ import (
 	"github.com/ubuntu/adsys/internal/ad/backends"
 	"github.com/ubuntu/adsys/internal/ad/backends/sss"
 	"github.com/ubuntu/adsys/internal/ad/backends/winbind"
+	"github.com/ubuntu/adsys/internal/ad/registry"
+	"github.com/ubuntu/adsys/internal/adsysservice/applylimiter"
+	"github.com/ubuntu/adsys/internal/adsysservice/refresher"
 	"github.com/ubuntu/adsys/internal/authorizer"
 	"github.com/ubuntu/adsys/internal/consts"
 	"github.com/ubuntu/adsys/internal/daemon"
 	"github.com/ubuntu/adsys/internal/grpc/connectionnotify"
+	"github.com/ubuntu/adsys/internal/grpc/grpcerror"
 	"github.com/ubuntu/adsys/internal/grpc/interceptorschain"
 	"github.com/ubuntu/adsys/internal/grpc/logconnections"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/policies"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
 	"github.com/ubuntu/decorate"
 	"google.golang.org/grpc"
 )
@@ -37,19 +43,27 @@ type Service struct {
 	adc           *ad.AD
 	policyManager *policies.Manager
 
-	authorizer authorizerer
+	authorizer  authorizerer
+	refresher   *refresher.Refresher
+	userApplies *applylimiter.Limiter
+
+	logBufferSize int
 
 	state          state
 	initSystemTime *time.Time
 
 	bus    *dbus.Conn
 	daemon *daemon.Daemon
+
+	// stopTicketRenewal stops the background renewal of the machine ticket.
+	stopTicketRenewal context.CancelFunc
 }
 
 type state struct {
 	cacheDir       string
 	stateDir       string
 	runDir         string
+	rootDir        string
 	dconfDir       string
 	sudoersDir     string
 	policyKitDir   string
@@ -59,20 +73,62 @@ type state struct {
 }
 
 type options struct {
-	cacheDir       string
-	stateDir       string
-	runDir         string
-	dconfDir       string
-	sudoersDir     string
-	policyKitDir   string
-	apparmorDir    string
-	apparmorFsDir  string
-	systemUnitDir  string
-	globalTrustDir string
-	adBackend      string
-	sssConfig      sss.Config
-	winbindConfig  winbind.Config
-	authorizer     authorizerer
+	cacheDir            string
+	stateDir            string
+	runDir              string
+	dconfDir            string
+	dconfProfile        string
+	dconfGroupProfiles  map[string][]string
+	extensionsDir       string
+	rootDir             string
+	sudoersDir          string
+	policyKitDir        string
+	apparmorDir         string
+	apparmorFsDir       string
+	systemUnitDir       string
+	globalTrustDir      string
+	mountSrcAddr        string
+	winePrefixes        map[string]string
+	proxyCheckURL       string
+	proxyNoAutoBypass   bool
+	proxyExclusionsFile string
+	dockerProxyUsers    map[string]string
+	dockerProxyDaemon   bool
+	allowAdminLockout   bool
+	privilegeReportOnly bool
+	sudoersPerGroup     bool
+	sudoersAliases      int
+	sudoersConflicts    bool
+	polkitAdminRules    bool
+	protectedUsers      []string
+	writeCooldown       time.Duration
+	refreshTimeout      time.Duration
+	transactional       bool
+	otlpEndpoint        string
+	tamperHandling      map[string]string
+	unknownKeys         string
+	machineKrb5CCName   string
+	adBackend           string
+	sssConfig           sss.Config
+	winbindConfig       winbind.Config
+	authorizer          authorizerer
+
+	maxCacheAge      time.Duration
+	staleCacheAction string
+
+	smbMinProtocol string
+	smbMaxProtocol string
+
+	computerAttributes []string
+
+	ticketRenewalMargin     time.Duration
+	ticketRenewalMaxBackoff time.Duration
+
+	dcFailureThreshold int
+	dcDownCooldown     time.Duration
+
+	maxConcurrentUserApplies int
+	logBufferSize            int
 }
 type option func(*options) error
 
@@ -112,6 +168,41 @@ func WithDconfDir(p string) func(o *options) error {
 	}
 }
 
+// WithDconfProfile specifies an existing dconf profile, like "user", in which the machine database is layered.
+func WithDconfProfile(p string) func(o *options) error {
+	return func(o *options) error {
+		o.dconfProfile = p
+		return nil
+	}
+}
+
+// WithExtensionsDir specifies a personalized directory for the declarations of the policy types added by third
+// parties.
+func WithExtensionsDir(p string) func(o *options) error {
+	return func(o *options) error {
+		o.extensionsDir = p
+		return nil
+	}
+}
+
+// WithDconfGroupProfiles maps group names to the existing dconf databases, like "kiosk", layered in the profile
+// of their members.
+func WithDconfGroupProfiles(profiles map[string][]string) func(o *options) error {
+	return func(o *options) error {
+		o.dconfGroupProfiles = profiles
+		return nil
+	}
+}
+
+// WithRootDir specifies an alternate root directory prefixing all the paths managed by the policies, for instance
+// when running in a container.
+func WithRootDir(p string) func(o *options) error {
+	return func(o *options) error {
+		o.rootDir = p
+		return nil
+	}
+}
+
 // WithSudoersDir specifies a personalized sudoers directory.
 func WithSudoersDir(p string) func(o *options) error {
 	return func(o *options) error {
@@ -162,6 +253,132 @@ func WithGlobalTrustDir(p string) func(o *options) error {
 	}
 }
 
+// WithMountSourceAddress specifies the local IP address, or network interface, system network mounts are bound to
+// when the share doesn't set its own.
+func WithMountSourceAddress(addr string) func(o *options) error {
+	return func(o *options) error {
+		o.mountSrcAddr = addr
+		return nil
+	}
+}
+
+// WithWineProxyPrefixes specifies, per user, the Wine prefix in which the proxy settings are written.
+func WithWineProxyPrefixes(prefixes map[string]string) func(o *options) error {
+	return func(o *options) error {
+		o.winePrefixes = prefixes
+		return nil
+	}
+}
+
+// WithProxyCheckURL specifies the URL requested through the system proxy once applied, to check its connectivity.
+func WithProxyCheckURL(u string) func(o *options) error {
+	return func(o *options) error {
+		o.proxyCheckURL = u
+		return nil
+	}
+}
+
+// WithProxyNoAutoBypass only excludes the hosts of the proxy policy from the proxy, without automatically adding
+// loopback and link-local addresses and the hostname.
+func WithProxyNoAutoBypass(noAutoBypass bool) func(o *options) error {
+	return func(o *options) error {
+		o.proxyNoAutoBypass = noAutoBypass
+		return nil
+	}
+}
+
+// WithProxyExclusionsFile merges the hosts of the given file, if it exists, with the ones of the proxy policy
+// bypassing the proxy.
+func WithProxyExclusionsFile(p string) func(o *options) error {
+	return func(o *options) error {
+		o.proxyExclusionsFile = p
+		return nil
+	}
+}
+
+// WithDockerProxyUsers specifies, per user, the home directory in which the proxy settings are written to the Docker
+// client configuration.
+func WithDockerProxyUsers(homes map[string]string) func(o *options) error {
+	return func(o *options) error {
+		o.dockerProxyUsers = homes
+		return nil
+	}
+}
+
+// WithDockerProxyDaemon writes the proxy settings to a drop-in of the Docker daemon service.
+func WithDockerProxyDaemon(enabled bool) func(o *options) error {
+	return func(o *options) error {
+		o.dockerProxyDaemon = enabled
+		return nil
+	}
+}
+
+// WithAllowAdminLockout allows the privilege policy to leave the machine without any polkit administrator.
+func WithAllowAdminLockout(allow bool) func(o *options) error {
+	return func(o *options) error {
+		o.allowAdminLockout = allow
+		return nil
+	}
+}
+
+// WithPrivilegeReportOnly only logs the privilege configuration the policy would set, without applying it.
+func WithPrivilegeReportOnly(reportOnly bool) func(o *options) error {
+	return func(o *options) error {
+		o.privilegeReportOnly = reportOnly
+		return nil
+	}
+}
+
+// WithSudoersPerGroup writes the sudoers rules of each client administrators group to its own file.
+func WithSudoersPerGroup(perGroup bool) func(o *options) error {
+	return func(o *options) error {
+		o.sudoersPerGroup = perGroup
+		return nil
+	}
+}
+
+// WithSudoersAliasThreshold specifies the number of client administrators from which their sudoers rules are grouped
+// in an alias. 0 selects the default, and a negative value always writes one rule per administrator.
+func WithSudoersAliasThreshold(threshold int) func(o *options) error {
+	return func(o *options) error {
+		o.sudoersAliases = threshold
+		return nil
+	}
+}
+
+// WithSudoersConflictsCheck warns about the local sudoers rules referencing the users and groups of the privilege policy.
+func WithSudoersConflictsCheck(check bool) func(o *options) error {
+	return func(o *options) error {
+		o.sudoersConflicts = check
+		return nil
+	}
+}
+
+// WithProtectedLocalUsers specifies local users which keep their administrator rights when the privilege policy
+// denies local administrators.
+func WithProtectedLocalUsers(users []string) func(o *options) error {
+	return func(o *options) error {
+		o.protectedUsers = users
+		return nil
+	}
+}
+
+// WithPolkitAdminRules also writes the polkit administrators of the privilege policy to a polkit rules file.
+func WithPolkitAdminRules(enabled bool) func(o *options) error {
+	return func(o *options) error {
+		o.polkitAdminRules = enabled
+		return nil
+	}
+}
+
+// WithMachineKrb5CCName specifies the Kerberos ticket cache used by the daemon to fetch the machine policies.
+func WithMachineKrb5CCName(name string) func(o *options) error {
+	return func(o *options) error {
+		o.machineKrb5CCName = name
+		return nil
+	}
+}
+
 // WithADBackend specifies our specific backend to select.
 func WithADBackend(backend string) func(o *options) error {
 	return func(o *options) error {
@@ -186,6 +403,131 @@ func WithWinbindConfig(c winbind.Config) func(o *options) error {
 	}
 }
 
+// WithUnknownKeys specifies how the policy keys unsupported by this version are handled: ignore, warn or fail.
+func WithUnknownKeys(mode string) func(o *options) error {
+	return func(o *options) error {
+		o.unknownKeys = mode
+		return nil
+	}
+}
+
+// WithWriteCooldown specifies the minimum interval between two rewrites of a privilege file.
+func WithWriteCooldown(cooldown time.Duration) func(o *options) error {
+	return func(o *options) error {
+		o.writeCooldown = cooldown
+		return nil
+	}
+}
+
+// WithRefreshTimeout specifies the maximum duration of applying the policies of an object.
+func WithRefreshTimeout(timeout time.Duration) func(o *options) error {
+	return func(o *options) error {
+		o.refreshTimeout = timeout
+		return nil
+	}
+}
+
+// WithTransactionalRefresh specifies if the changes of all policy managers are rolled back when any of them fails.
+func WithTransactionalRefresh(transactional bool) func(o *options) error {
+	return func(o *options) error {
+		o.transactional = transactional
+		return nil
+	}
+}
+
+// WithOTLPEndpoint specifies the OTLP/HTTP endpoint to which the refreshes spans and metrics are exported.
+func WithOTLPEndpoint(endpoint string) func(o *options) error {
+	return func(o *options) error {
+		o.otlpEndpoint = endpoint
+		return nil
+	}
+}
+
+// WithTamperHandling specifies, per policy type, how the managed files modified locally are handled.
+func WithTamperHandling(modes map[string]string) func(o *options) error {
+	return func(o *options) error {
+		o.tamperHandling = modes
+		return nil
+	}
+}
+
+// WithMaxCacheAge specifies the maximum age of cached policies applied when the machine is offline.
+func WithMaxCacheAge(maxAge time.Duration) func(o *options) error {
+	return func(o *options) error {
+		o.maxCacheAge = maxAge
+		return nil
+	}
+}
+
+// WithStaleCacheAction specifies what to do when offline cached policies exceed the maximum cache age.
+func WithStaleCacheAction(action string) func(o *options) error {
+	return func(o *options) error {
+		o.staleCacheAction = action
+		return nil
+	}
+}
+
+// WithSMBProtocols specifies the minimum and maximum SMB protocols negotiated to access SYSVOL.
+func WithSMBProtocols(minProtocol, maxProtocol string) func(o *options) error {
+	return func(o *options) error {
+		o.smbMinProtocol = minProtocol
+		o.smbMaxProtocol = maxProtocol
+		return nil
+	}
+}
+
+// WithComputerAttributes specifies the attributes of the computer object read from AD at each machine refresh.
+func WithComputerAttributes(attrs []string) func(o *options) error {
+	return func(o *options) error {
+		o.computerAttributes = attrs
+		return nil
+	}
+}
+
+// WithTicketRenewal specifies how long before expiry the machine ticket is renewed in the background, and the
+// maximum time between two failed renewals.
+func WithTicketRenewal(margin, maxBackoff time.Duration) func(o *options) error {
+	return func(o *options) error {
+		o.ticketRenewalMargin = margin
+		o.ticketRenewalMaxBackoff = maxBackoff
+		return nil
+	}
+}
+
+// WithDCCircuitBreaker specifies after how many consecutive failed connections a domain controller is marked down,
+// and for how long.
+func WithDCCircuitBreaker(threshold int, cooldown time.Duration) func(o *options) error {
+	return func(o *options) error {
+		o.dcFailureThreshold = threshold
+		o.dcDownCooldown = cooldown
+		return nil
+	}
+}
+
+// WithMaxConcurrentUserApplies specifies the maximum number of user policies fetched and applied concurrently.
+// 0 selects the default.
+func WithMaxConcurrentUserApplies(n int) func(o *options) error {
+	return func(o *options) error {
+		if n < 0 {
+			return errors.New(gotext.Get("invalid maximum of concurrent user policy applies %d: it can't be negative", n))
+		}
+		o.maxConcurrentUserApplies = n
+		return nil
+	}
+}
+
+// WithLogBufferSize specifies the number of logs buffered for each client, the oldest being dropped when the client
+// can't keep up. 0 selects the default.
+func WithLogBufferSize(n int) func(o *options) error {
+	return func(o *options) error {
+		if n < 0 {
+			return errors.New(gotext.Get("invalid log buffer size %d: it can't be negative", n))
+		}
+		o.logBufferSize = n
+		return nil
+	}
+}
+
 // New returns a new instance of an AD service.
 // If url or domain is empty, we load the missing parameters from sssd.conf, taking first
 // domain in the list if not provided.
@@ -241,6 +583,30 @@ func New(ctx context.Context, opts ...option) (s *Service, err error) {
 		adOptions = append(adOptions, ad.WithRunDir(args.runDir))
 	}
 	adOptions = append(adOptions, ad.WithGpoListTimeout(consts.DefaultGpoListTimeout))
+	if args.maxCacheAge != 0 {
+		adOptions = append(adOptions, ad.WithMaxCacheAge(args.maxCacheAge))
+	}
+	if args.staleCacheAction != "" {
+		adOptions = append(adOptions, ad.WithStaleCacheAction(ad.StaleCacheAction(args.staleCacheAction)))
+	}
+	if args.machineKrb5CCName != "" {
+		adOptions = append(adOptions, ad.WithMachineKrb5CCName(args.machineKrb5CCName))
+	}
+	if args.smbMinProtocol != "" || args.smbMaxProtocol != "" {
+		adOptions = append(adOptions, ad.WithSMBProtocols(args.smbMinProtocol, args.smbMaxProtocol))
+	}
+	if len(args.computerAttributes) > 0 {
+		adOptions = append(adOptions, ad.WithComputerAttributes(args.computerAttributes))
+	}
+	if args.ticketRenewalMargin != 0 || args.ticketRenewalMaxBackoff != 0 {
+		adOptions = append(adOptions, ad.WithTicketRenewal(args.ticketRenewalMargin, args.ticketRenewalMaxBackoff))
+	}
+	if args.dcFailureThreshold != 0 || args.dcDownCooldown != 0 {
+		adOptions = append(adOptions, ad.WithDCCircuitBreaker(args.dcFailureThreshold, args.dcDownCooldown))
+	}
+	if args.otlpEndpoint != "" {
+		adOptions = append(adOptions, ad.WithOTLPEndpoint(args.otlpEndpoint))
+	}
 
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -286,9 +652,21 @@ func New(ctx context.Context, opts ...option) (s *Service, err error) {
 	if args.stateDir != "" {
 		policyOptions = append(policyOptions, policies.WithStateDir(args.stateDir))
 	}
+	if args.rootDir != "" {
+		policyOptions = append(policyOptions, policies.WithRootDir(args.rootDir))
+	}
 	if args.dconfDir != "" {
 		policyOptions = append(policyOptions, policies.WithDconfDir(args.dconfDir))
 	}
+	if args.dconfProfile != "" {
+		policyOptions = append(policyOptions, policies.WithDconfProfile(args.dconfProfile))
+	}
+	if len(args.dconfGroupProfiles) > 0 {
+		policyOptions = append(policyOptions, policies.WithDconfGroupProfiles(args.dconfGroupProfiles))
+	}
+	if args.extensionsDir != "" {
+		policyOptions = append(policyOptions, policies.WithExtensionsDir(args.extensionsDir))
+	}
 	if args.sudoersDir != "" {
 		policyOptions = append(policyOptions, policies.WithSudoersDir(args.sudoersDir))
 	}
@@ -310,22 +688,93 @@ func New(ctx context.Context, opts ...option) (s *Service, err error) {
 	if args.globalTrustDir != "" {
 		policyOptions = append(policyOptions, policies.WithGlobalTrustDir(args.globalTrustDir))
 	}
+	if args.mountSrcAddr != "" {
+		policyOptions = append(policyOptions, policies.WithMountSourceAddress(args.mountSrcAddr))
+	}
+	if len(args.winePrefixes) > 0 {
+		policyOptions = append(policyOptions, policies.WithWineProxyPrefixes(args.winePrefixes))
+	}
+	if args.proxyCheckURL != "" {
+		policyOptions = append(policyOptions, policies.WithProxyCheckURL(args.proxyCheckURL))
+	}
+	if args.proxyNoAutoBypass {
+		policyOptions = append(policyOptions, policies.WithProxyNoAutoBypass(args.proxyNoAutoBypass))
+	}
+	if args.proxyExclusionsFile != "" {
+		policyOptions = append(policyOptions, policies.WithProxyExclusionsFile(args.proxyExclusionsFile))
+	}
+	if len(args.dockerProxyUsers) > 0 {
+		policyOptions = append(policyOptions, policies.WithDockerProxyUsers(args.dockerProxyUsers))
+	}
+	if args.dockerProxyDaemon {
+		policyOptions = append(policyOptions, policies.WithDockerProxyDaemon(args.dockerProxyDaemon))
+	}
+	if args.allowAdminLockout {
+		policyOptions = append(policyOptions, policies.WithAllowAdminLockout(args.allowAdminLockout))
+	}
+	if args.machineKrb5CCName != "" {
+		policyOptions = append(policyOptions, policies.WithMachineKrb5CCPath(adc.MachineKrb5CCPath()))
+	}
+	if args.privilegeReportOnly {
+		policyOptions = append(policyOptions, policies.WithPrivilegeReportOnly(args.privilegeReportOnly))
+	}
+	if args.sudoersPerGroup {
+		policyOptions = append(policyOptions, policies.WithSudoersPerGroup(args.sudoersPerGroup))
+	}
+	if args.sudoersAliases != 0 {
+		policyOptions = append(policyOptions, policies.WithSudoersAliasThreshold(args.sudoersAliases))
+	}
+	if args.sudoersConflicts {
+		policyOptions = append(policyOptions, policies.WithSudoersConflictsCheck(args.sudoersConflicts))
+	}
+	if args.polkitAdminRules {
+		policyOptions = append(policyOptions, policies.WithPolkitAdminRules(args.polkitAdminRules))
+	}
+	if len(args.protectedUsers) > 0 {
+		policyOptions = append(policyOptions, policies.WithProtectedLocalUsers(args.protectedUsers))
+	}
+	if args.writeCooldown != 0 {
+		policyOptions = append(policyOptions, policies.WithWriteCooldown(args.writeCooldown))
+	}
+	if args.refreshTimeout != 0 {
+		policyOptions = append(policyOptions, policies.WithRefreshTimeout(args.refreshTimeout))
+	}
+	if args.transactional {
+		policyOptions = append(policyOptions, policies.WithTransactional(args.transactional))
+	}
+	if args.otlpEndpoint != "" {
+		policyOptions = append(policyOptions, policies.WithOTLPEndpoint(args.otlpEndpoint))
+	}
+	if len(args.tamperHandling) > 0 {
+		modes := make(map[string]tamper.Mode)
+		for rule, mode := range args.tamperHandling {
+			modes[rule] = tamper.Mode(mode)
+		}
+		policyOptions = append(policyOptions, policies.WithTamperHandling(modes))
+	}
+	if args.unknownKeys != "" {
+		policyOptions = append(policyOptions, policies.WithUnknownKeys(ignored.UnknownKeysMode(args.unknownKeys)))
+	}
 	m, err := policies.NewManager(bus, hostname, adBackend, policyOptions...)
 	if err != nil {
 		return nil, err
 	}
+	if err := resumeReplay(ctx, m, cacheDir); err != nil {
+		return nil, err
+	}
 
 	// Init system reference time
 	initSysTime := initSystemTime(bus)
 
-	return &Service{
+	s = &Service{
 		adc:           adc,
 		policyManager: m,
 		authorizer:    args.authorizer,
 		state: state{
-			cacheDir:       args.cacheDir,
+			cacheDir:       cacheDir,
 			stateDir:       args.stateDir,
 			dconfDir:       args.dconfDir,
+			rootDir:        args.rootDir,
 			sudoersDir:     args.sudoersDir,
 			policyKitDir:   args.policyKitDir,
 			runDir:         args.runDir,
@@ -335,7 +784,31 @@ func New(ctx context.Context, opts ...option) (s *Service, err error) {
 		},
 		initSystemTime: initSysTime,
 		bus:            bus,
-	}, nil
+	}
+	maxConcurrentUserApplies := args.maxConcurrentUserApplies
+	if maxConcurrentUserApplies == 0 {
+		maxConcurrentUserApplies = consts.DefaultMaxConcurrentUserApplies
+	}
+	s.userApplies = applylimiter.New(maxConcurrentUserApplies)
+	s.logBufferSize = args.logBufferSize
+	if s.logBufferSize == 0 {
+		s.logBufferSize = consts.DefaultLogBufferSize
+	}
+	s.refresher = refresher.New(func(ctx context.Context) error { return s.updateAllPolicies(ctx, false) })
+
+	renewalCtx, stopTicketRenewal := context.WithCancel(context.Background())
+	s.stopTicketRenewal = stopTicketRenewal
+	go adc.RenewMachineTicket(renewalCtx)
+
+	return s, nil
+}
+
+// errorCodes maps the internal errors to the stable codes attached to the errors sent to the clients.
+var errorCodes = grpcerror.Mapping{
+	ad.ErrTicketNotPresent:     grpcerror.CodeKrbNoTicket,
+	backends.ErrNoActiveServer: grpcerror.CodeDCUnreachable,
+	registry.ErrInvalidPolicy:  grpcerror.CodePolicyParse,
+	ad.ErrStaleCache:           grpcerror.CodeStaleCache,
 }
 
 // RegisterGRPCServer registers our service with the new interceptor chains.
@@ -344,7 +817,8 @@ func (s *Service) RegisterGRPCServer(d *daemon.Daemon) *grpc.Server {
 	s.logger = logrus.StandardLogger()
 	srv := grpc.NewServer(grpc.StreamInterceptor(
 		interceptorschain.StreamServer(
-			log.StreamServerInterceptor(s.logger),
+			grpcerror.StreamServerInterceptor(errorCodes),
+			log.StreamServerInterceptor(s.logger, log.WithBufferSize(s.logBufferSize)),
 			connectionnotify.StreamServerInterceptor(d),
 			logconnections.StreamServerInterceptor(),
 		)), authorizer.WithUnixPeerCreds())
@@ -355,6 +829,7 @@ func (s *Service) RegisterGRPCServer(d *daemon.Daemon) *grpc.Server {
 
 // Quit cleans every ressources than the service was using.
 func (s *Service) Quit(ctx context.Context) {
+	s.stopTicketRenewal()
 	if err := s.bus.Close(); err != nil {
 		log.Warning(ctx, gotext.Get("Can't disconnect system dbus: %v", err))
 	}
@@ -2,6 +2,7 @@ package adsysservice_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -12,10 +13,14 @@ import (
 	"github.com/godbus/dbus/v5/introspect"
 	"github.com/godbus/dbus/v5/prop"
 	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/ad"
+	"github.com/ubuntu/adsys/internal/ad/backends"
 	"github.com/ubuntu/adsys/internal/ad/backends/sss"
 	"github.com/ubuntu/adsys/internal/ad/backends/winbind"
+	"github.com/ubuntu/adsys/internal/ad/registry"
 	"github.com/ubuntu/adsys/internal/adsysservice"
 	"github.com/ubuntu/adsys/internal/consts"
+	"github.com/ubuntu/adsys/internal/grpc/grpcerror"
 	"github.com/ubuntu/adsys/internal/testutils"
 )
 
@@ -123,6 +128,33 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestErrorCodes(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		err error
+
+		wantCode grpcerror.Code
+	}{
+		"Missing ticket":         {err: ad.ErrTicketNotPresent, wantCode: grpcerror.CodeKrbNoTicket},
+		"No active server":       {err: backends.ErrNoActiveServer, wantCode: grpcerror.CodeDCUnreachable},
+		"Invalid policy":         {err: registry.ErrInvalidPolicy, wantCode: grpcerror.CodePolicyParse},
+		"Stale cache":            {err: ad.ErrStaleCache, wantCode: grpcerror.CodeStaleCache},
+		"Wrapped missing ticket": {err: fmt.Errorf("can't get policies: %w", errors.Join(ad.ErrTicketNotPresent, errors.New("no ticket"))), wantCode: grpcerror.CodeKrbNoTicket},
+		"Wrapped invalid policy": {err: fmt.Errorf("can't get policies: Registry.pol: %w", registry.ErrInvalidPolicy), wantCode: grpcerror.CodePolicyParse},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := grpcerror.CodeOf(grpcerror.WithCode(tc.err, adsysservice.ErrorCodes))
+			require.True(t, ok, "Error should have a code attached")
+			require.Equal(t, tc.wantCode, got, "Error should have the expected code attached")
+		})
+	}
+}
+
 func TestMain(m *testing.M) {
 	// export SSSD domain
 	defer testutils.StartLocalSystemBus()()
@@ -0,0 +1,40 @@
+// Package applylimiter caps the number of user policies fetched and applied concurrently, queueing the others.
+package applylimiter
+
+import (
+	"context"
+
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+)
+
+// Limiter lets a maximum number of applies run at a given time. The others wait for one of them to finish.
+type Limiter struct {
+	slots chan struct{}
+}
+
+// New returns a Limiter running at most n applies concurrently. n must be positive.
+func New(n int) *Limiter {
+	return &Limiter{slots: make(chan struct{}, n)}
+}
+
+// Acquire waits for a free slot to apply the policy of target and returns the function releasing it.
+// It returns an error without acquiring any slot if ctx is cancelled while waiting.
+func (l *Limiter) Acquire(ctx context.Context, target string) (release func(), err error) {
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	default:
+	}
+
+	log.Debugf(ctx, "Maximum of %d concurrent user policy applies reached, queuing %q", cap(l.slots), target)
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *Limiter) release() {
+	<-l.slots
+}
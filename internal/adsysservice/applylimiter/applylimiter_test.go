@@ -0,0 +1,106 @@
+package applylimiter_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/adsysservice/applylimiter"
+)
+
+func TestAcquire(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		max     int
+		applies int
+	}{
+		"Applies under the limit all run concurrently": {max: 4, applies: 3},
+		"Applies over the limit are queued":            {max: 4, applies: 20},
+		"Limit of one serializes applies":              {max: 1, applies: 5},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			l := applylimiter.New(tc.max)
+
+			var running, maxRunning, done atomic.Int32
+			var wg sync.WaitGroup
+			for i := range tc.applies {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					release, err := l.Acquire(context.Background(), fmt.Sprintf("user%d", i))
+					require.NoError(t, err, "Acquire should succeed")
+					defer release()
+
+					n := running.Add(1)
+					for {
+						m := maxRunning.Load()
+						if n <= m || maxRunning.CompareAndSwap(m, n) {
+							break
+						}
+					}
+					time.Sleep(10 * time.Millisecond)
+					running.Add(-1)
+					done.Add(1)
+				}()
+			}
+			wg.Wait()
+
+			require.Equal(t, int32(tc.applies), done.Load(), "All applies should have run")
+			require.LessOrEqual(t, maxRunning.Load(), int32(tc.max), "No more than the maximum of applies should run concurrently")
+		})
+	}
+}
+
+func TestAcquireReleasesSlot(t *testing.T) {
+	t.Parallel()
+
+	l := applylimiter.New(1)
+
+	release, err := l.Acquire(context.Background(), "user1")
+	require.NoError(t, err, "Acquire should succeed")
+
+	acquired := make(chan struct{})
+	go func() {
+		release, err := l.Acquire(context.Background(), "user2")
+		require.NoError(t, err, "Acquire should succeed once the slot is released")
+		release()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Second apply should be queued while the first one runs")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Second apply should run once the first one released its slot")
+	}
+}
+
+func TestAcquireCancelledWhileQueued(t *testing.T) {
+	t.Parallel()
+
+	l := applylimiter.New(1)
+
+	release, err := l.Acquire(context.Background(), "user1")
+	require.NoError(t, err, "Acquire should succeed")
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = l.Acquire(ctx, "user2")
+	require.ErrorIs(t, err, context.DeadlineExceeded, "Acquire should fail when the context is cancelled while queued")
+}
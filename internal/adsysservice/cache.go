@@ -0,0 +1,130 @@
+package adsysservice
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys"
+	"github.com/ubuntu/adsys/internal/adsysservice/actions"
+	"github.com/ubuntu/adsys/internal/adsysservice/cachearchive"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// replayDirName is the cache subdirectory holding the imported cache replayed by the daemon.
+	replayDirName = "replay"
+	// replayHostnameFile is the file, at the root of an exported cache, with the name of the machine it comes from.
+	replayHostnameFile = "hostname"
+	// exportChunkSize is the maximum size of the archive chunks streamed to the client.
+	exportChunkSize = 1024 * 1024
+)
+
+// exportedCacheDirs are the cache directories, with the GPOs and the resolved policies, which are exported.
+var exportedCacheDirs = []string{policies.PoliciesCacheBaseName, "sysvol"}
+
+// PolicyExport streams a compressed archive of the daemon GPOs and policies cache.
+func (s *Service) PolicyExport(_ *adsys.Empty, stream adsys.Service_PolicyExportServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while exporting policies cache"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriterSize(exportStreamWriter{stream}, exportChunkSize)
+	if err := cachearchive.Export(stream.Context(), w, s.state.cacheDir, exportedCacheDirs,
+		cachearchive.WithFile(replayHostnameFile, []byte(s.adc.Hostname()))); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// exportStreamWriter sends the written data as archive chunks to the client.
+type exportStreamWriter struct {
+	stream adsys.Service_PolicyExportServer
+}
+
+func (w exportStreamWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		chunk := p[:min(len(p), exportChunkSize)]
+		// Copy the chunk as the buffer is reused once we return.
+		if err := w.stream.Send(&adsys.PolicyExportResponse{Data: append([]byte(nil), chunk...)}); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+// PolicyImport imports an exported policies cache archive and switches the daemon to replay mode.
+// In replay mode, policies are computed from the imported cache and never applied to the system.
+// It can instead leave replay mode.
+func (s *Service) PolicyImport(r *adsys.PolicyImportRequest, stream adsys.Service_PolicyImportServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while importing policies cache"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+
+	replayDir := filepath.Join(s.state.cacheDir, replayDirName)
+	if r.GetExitReplay() {
+		s.policyManager.StopReplay()
+		log.Info(stream.Context(), gotext.Get("Leaving replay mode"))
+		return os.RemoveAll(replayDir)
+	}
+
+	f, err := os.Open(r.GetPath())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Extract next to any previous import, only replacing it once complete.
+	newReplayDir := replayDir + ".new"
+	if err := os.RemoveAll(newReplayDir); err != nil {
+		return err
+	}
+	defer os.RemoveAll(newReplayDir)
+	if err := cachearchive.Import(f, newReplayDir); err != nil {
+		return err
+	}
+	hostname, err := os.ReadFile(filepath.Join(newReplayDir, replayHostnameFile))
+	if err != nil {
+		return errors.New(gotext.Get("%s is not an exported policies cache: %v", r.GetPath(), err))
+	}
+
+	if err := os.RemoveAll(replayDir); err != nil {
+		return err
+	}
+	if err := os.Rename(newReplayDir, replayDir); err != nil {
+		return err
+	}
+
+	s.policyManager.StartReplay(replayDir, strings.TrimSpace(string(hostname)))
+	log.Warning(stream.Context(), gotext.Get("Replaying policies cache of %s: no policy will be applied until leaving replay mode", strings.TrimSpace(string(hostname))))
+
+	return nil
+}
+
+// resumeReplay switches the policy manager to replay mode if a cache was previously imported in cacheDir.
+func resumeReplay(ctx context.Context, m *policies.Manager, cacheDir string) error {
+	replayDir := filepath.Join(cacheDir, replayDirName)
+	hostname, err := os.ReadFile(filepath.Join(replayDir, replayHostnameFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	m.StartReplay(replayDir, strings.TrimSpace(string(hostname)))
+	log.Warning(ctx, gotext.Get("Replaying policies cache of %s: no policy will be applied until leaving replay mode", strings.TrimSpace(string(hostname))))
+	return nil
+}
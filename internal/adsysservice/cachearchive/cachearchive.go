@@ -0,0 +1,211 @@
+// Package cachearchive exports and imports the daemon cache directories as a compressed tarball.
+//
+// Only directories and regular files are archived, with their permissions. Anything else, like symlinks,
+// is skipped on export and rejected on import, as is any entry which would be extracted outside of the
+// destination directory.
+package cachearchive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+type options struct {
+	files map[string][]byte
+}
+
+// Option reprents an optional function to change the exported archive.
+type Option func(*options)
+
+// WithFile adds a file with the given content at the root of the archive.
+func WithFile(name string, content []byte) Option {
+	return func(o *options) {
+		o.files[name] = content
+	}
+}
+
+// Export writes to w a compressed tarball of the given dirs, relative to root.
+// Missing directories are not part of the archive.
+func Export(ctx context.Context, w io.Writer, root string, dirs []string, opts ...Option) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't export cache from %s", root))
+
+	// Set default options
+	args := options{
+		files: make(map[string][]byte),
+	}
+
+	// Apply given options
+	for _, f := range opts {
+		f(&args)
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for name, content := range args.files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0600,
+			Size:     int64(len(content)),
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range dirs {
+		err := filepath.WalkDir(filepath.Join(root, dir), func(path string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && path == filepath.Join(root, dir) {
+				log.Debugf(ctx, "Cache directory %s doesn't exist, skipping it", path)
+				return fs.SkipDir
+			}
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && !d.Type().IsRegular() {
+				log.Warning(ctx, gotext.Get("Skipping %s as it's not a regular file", path))
+				return nil
+			}
+
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			name, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			hdr, err := tar.FileInfoHeader(fi, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = filepath.ToSlash(name)
+			if d.IsDir() {
+				hdr.Name += "/"
+			}
+			// Don't leak the local owners to the machine the cache is imported on.
+			hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// Import extracts the compressed tarball read from r into dest, which is created if needed.
+func Import(r io.Reader, dest string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't import cache to %s", dest))
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	if err := os.MkdirAll(dest, 0700); err != nil {
+		return err
+	}
+
+	// Directories permissions are only set once extracted, deepest first, in case they are not writable.
+	type dirPerm struct {
+		path string
+		perm fs.FileMode
+	}
+	var dirs []dirPerm
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.FromSlash(hdr.Name)
+		if filepath.IsAbs(name) || !filepath.IsLocal(name) {
+			return errors.New(gotext.Get("invalid path %q in archive", hdr.Name))
+		}
+		path := filepath.Join(dest, name)
+		perm := fs.FileMode(hdr.Mode).Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirPerm{path: path, perm: perm})
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return err
+			}
+			if err := extractFile(tr, path, perm); err != nil {
+				return err
+			}
+		default:
+			return errors.New(gotext.Get("unsupported type for %q in archive", strings.TrimSuffix(hdr.Name, "/")))
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].perm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractFile writes the content read from r to path with the given permissions.
+func extractFile(r io.Reader, path string, perm fs.FileMode) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't extract %s", path))
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// #nosec G110 - archives are only imported by privileged users
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	// Enforce permissions, independently of the umask.
+	if err := f.Chmod(perm); err != nil {
+		return err
+	}
+	return f.Close()
+}
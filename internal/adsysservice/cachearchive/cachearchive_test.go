@@ -0,0 +1,151 @@
+package cachearchive_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/adsysservice/cachearchive"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	src := t.TempDir()
+	for path, content := range map[string]string{
+		"policies/hostname/policies":            "gpos: []\n",
+		"policies/hostname/assets.db":           "assets content",
+		"policies/user@example.com/policies":    "gpos:\n- id: '{GPOId}'\n",
+		"sysvol/Policies/{GPOId}/GPT.INI":       "[General]\nVersion=4\n",
+		"sysvol/Policies/{GPOId}/Machine/empty": "",
+		"other/not-exported":                    "not exported",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(src, path)), 0750), "Setup: can't create cache directory")
+		require.NoError(t, os.WriteFile(filepath.Join(src, path), []byte(content), 0600), "Setup: can't create cache file")
+	}
+	require.NoError(t, os.Chmod(filepath.Join(src, "sysvol", "Policies", "{GPOId}", "GPT.INI"), 0644), "Setup: can't change file permissions")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sysvol", "assets", "empty-dir"), 0700), "Setup: can't create empty directory")
+	require.NoError(t, os.Chmod(filepath.Join(src, "sysvol", "Policies"), 0500), "Setup: can't make directory read only")
+	require.NoError(t, os.Symlink("GPT.INI", filepath.Join(src, "sysvol", "assets", "symlink")), "Setup: can't create symlink")
+	t.Cleanup(func() { _ = os.Chmod(filepath.Join(src, "sysvol", "Policies"), 0700) })
+
+	var archive bytes.Buffer
+	err := cachearchive.Export(context.Background(), &archive, src, []string{"policies", "sysvol", "does-not-exist"},
+		cachearchive.WithFile("hostname", []byte("exported-host")))
+	require.NoError(t, err, "Export should not have failed")
+
+	dest := filepath.Join(t.TempDir(), "replay")
+	err = cachearchive.Import(&archive, dest)
+	require.NoError(t, err, "Import should not have failed")
+	t.Cleanup(func() { _ = os.Chmod(filepath.Join(dest, "sysvol", "Policies"), 0700) })
+
+	want := treeContent(t, src, "policies", "sysvol")
+	delete(want, filepath.Join("sysvol", "assets", "symlink"))
+	require.Equal(t, want, treeContent(t, dest, "policies", "sysvol"), "Imported cache should match the exported one")
+	require.NoDirExists(t, filepath.Join(dest, "other"), "Only requested directories should be exported")
+	hostname, err := os.ReadFile(filepath.Join(dest, "hostname"))
+	require.NoError(t, err, "Additional file should have been imported")
+	require.Equal(t, "exported-host", string(hostname), "Additional file should have its content imported")
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		headers   []tar.Header
+		notGzip   bool
+		destIsDir bool
+
+		wantErr bool
+	}{
+		"Import into existing directory":                      {headers: []tar.Header{{Name: "policies/", Typeflag: tar.TypeDir, Mode: 0700}}, destIsDir: true},
+		"Import files without their parent directory entries": {headers: []tar.Header{{Name: "policies/hostname/policies", Typeflag: tar.TypeReg, Mode: 0600}}},
+
+		// Error cases
+		"Error on path outside of destination": {headers: []tar.Header{{Name: "../evil", Typeflag: tar.TypeReg, Mode: 0600}}, wantErr: true},
+		"Error on absolute path":               {headers: []tar.Header{{Name: "/etc/evil", Typeflag: tar.TypeReg, Mode: 0600}}, wantErr: true},
+		"Error on symlink":                     {headers: []tar.Header{{Name: "policies/link", Typeflag: tar.TypeSymlink, Linkname: "/etc/shadow"}}, wantErr: true},
+		"Error on archive not compressed":      {notGzip: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var archive bytes.Buffer
+			if tc.notGzip {
+				archive.WriteString("not a gzip archive")
+			} else {
+				gw := gzip.NewWriter(&archive)
+				tw := tar.NewWriter(gw)
+				for _, hdr := range tc.headers {
+					require.NoError(t, tw.WriteHeader(&hdr), "Setup: can't write archive header")
+				}
+				require.NoError(t, tw.Close(), "Setup: can't close archive")
+				require.NoError(t, gw.Close(), "Setup: can't close archive compression")
+			}
+
+			dest := filepath.Join(t.TempDir(), "replay")
+			if tc.destIsDir {
+				require.NoError(t, os.MkdirAll(dest, 0700), "Setup: can't create destination")
+			}
+
+			err := cachearchive.Import(&archive, dest)
+			if tc.wantErr {
+				require.Error(t, err, "Import should have failed but didn't")
+				require.NoFileExists(t, filepath.Join(filepath.Dir(dest), "evil"), "Import should not have written outside of destination")
+				return
+			}
+			require.NoError(t, err, "Import should not have failed")
+			for _, hdr := range tc.headers {
+				if hdr.Typeflag == tar.TypeDir {
+					require.DirExists(t, filepath.Join(dest, hdr.Name), "Import should have extracted the archive directories")
+					continue
+				}
+				require.FileExists(t, filepath.Join(dest, hdr.Name), "Import should have extracted the archive files")
+			}
+		})
+	}
+}
+
+type fileContent struct {
+	mode    fs.FileMode
+	content string
+}
+
+// treeContent returns the content and mode of all files and directories in dirs, relative to root.
+func treeContent(t *testing.T, root string, dirs ...string) map[string]fileContent {
+	t.Helper()
+
+	r := make(map[string]fileContent)
+	for _, dir := range dirs {
+		err := filepath.WalkDir(filepath.Join(root, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			fi, err := os.Lstat(path)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			var content []byte
+			if fi.Mode().IsRegular() {
+				if content, err = os.ReadFile(path); err != nil {
+					return err
+				}
+			}
+			r[rel] = fileContent{mode: fi.Mode(), content: string(content)}
+			return nil
+		})
+		require.NoError(t, err, "Setup: can't walk tree")
+	}
+	return r
+}
@@ -22,3 +22,6 @@ func (s *Service) SelectedBackend() string {
 
 	return backend
 }
+
+// ErrorCodes is the mapping of internal errors to codes, exported for tests.
+var ErrorCodes = errorCodes
@@ -0,0 +1,55 @@
+package adsysservice
+
+import (
+	"context"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys"
+	"github.com/ubuntu/adsys/internal/ad"
+	"github.com/ubuntu/adsys/internal/adsysservice/actions"
+	"github.com/ubuntu/adsys/internal/authorizer"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// TicketStatus returns the state of the kerberos ticket of the current user, a given user or the machine.
+func (s *Service) TicketStatus(r *adsys.TicketStatusRequest, stream adsys.Service_TicketStatusServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while getting kerberos ticket status"))
+
+	objectClass := ad.UserObject
+	if r.GetIsComputer() {
+		objectClass = ad.ComputerObject
+	}
+
+	target, err := s.adc.NormalizeTargetName(stream.Context(), r.GetTarget(), objectClass)
+	if err != nil {
+		return err
+	}
+
+	// hostname ticket status is allowed to all users
+	if target != s.adc.Hostname() {
+		if err := s.authorizer.IsAllowedFromContext(context.WithValue(stream.Context(), authorizer.OnUserKey, target),
+			actions.ActionPolicyDump); err != nil {
+			return err
+		}
+	}
+
+	info, err := s.adc.TicketStatus(stream.Context(), target, objectClass)
+	if err != nil {
+		return err
+	}
+
+	resp := &adsys.TicketStatusResponse{
+		State:     string(info.State),
+		Path:      info.Path,
+		Principal: info.Principal,
+	}
+	if info.State != ad.TicketAbsent {
+		resp.Expiry = info.Expiry.Unix()
+	}
+	if err := stream.Send(resp); err != nil {
+		log.Warningf(stream.Context(), "couldn't send kerberos ticket status to client: %v", err)
+	}
+
+	return nil
+}
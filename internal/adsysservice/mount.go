@@ -0,0 +1,37 @@
+package adsysservice
+
+import (
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys"
+	"github.com/ubuntu/adsys/internal/authorizer"
+	"github.com/ubuntu/decorate"
+)
+
+// MountStatus returns the status of the system shares set by the mount policy, comparing them to the
+// mounted filesystems.
+func (s *Service) MountStatus(_ *adsys.Empty, stream adsys.Service_MountStatusServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while getting mounts status"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), authorizer.ActionAlwaysAllowed); err != nil {
+		return err
+	}
+
+	status, err := s.policyManager.SystemMountsStatus(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	for _, share := range status {
+		if err := stream.Send(&adsys.MountStatusResponse{
+			Share:  share.Share,
+			Target: share.Target,
+			Type:   share.Type,
+			State:  string(share.State),
+			Error:  share.Error,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,7 +2,9 @@ package adsysservice
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/leonelquinteros/gotext"
 	"github.com/ubuntu/adsys"
@@ -12,6 +14,7 @@ import (
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/policies"
 	"github.com/ubuntu/adsys/internal/policies/certificate"
+	"github.com/ubuntu/adsys/internal/policies/integrity"
 	"github.com/ubuntu/decorate"
 	"golang.org/x/sync/errgroup"
 )
@@ -41,35 +44,87 @@ func (s *Service) UpdatePolicy(r *adsys.UpdatePolicyRequest, stream adsys.Servic
 		return err
 	}
 
-	if r.GetIsComputer() || r.GetAll() {
-		hostname := s.adc.Hostname()
-
-		err = s.updatePolicyFor(stream.Context(), true, hostname, ad.ComputerObject, "", r.GetPurge())
+	ctx := stream.Context()
+	if r.GetTrace() {
+		// Trace bundles contain the policies and managed files of the machine.
+		if err := s.authorizer.IsAllowedFromContext(ctx, actions.ActionServiceManage); err != nil {
+			return err
+		}
+		if ctx, err = s.withTraceBundle(ctx); err != nil {
+			return err
+		}
+	}
 
+	if r.GetIsComputer() || r.GetAll() {
 		if r.GetAll() {
-			users, err := s.adc.ListUsers(stream.Context(), !r.GetPurge())
-			if err != nil {
-				return err
+			// Full refreshes are serialized, and purges can't interleave with them.
+			if r.GetPurge() {
+				return s.refresher.Do(func() error { return s.updateAllPolicies(ctx, true) })
 			}
-			errg := new(errgroup.Group)
-			for _, user := range users {
-				errg.Go(func() (err error) {
-					return s.updatePolicyFor(stream.Context(), false, user, ad.UserObject, "", r.GetPurge())
-				})
-			}
-			if err := errg.Wait(); err != nil {
-				return fmt.Errorf("one or more error for updating all users: %w", err)
+			// Traced refreshes are not coalesced with the other ones, so that the bundle covers a full refresh.
+			if r.GetTrace() {
+				return s.refresher.Do(func() error { return s.updateAllPolicies(ctx, false) })
 			}
+			return s.refresher.Run(ctx)
 		}
-
-		return err
+		return s.updatePolicyFor(ctx, true, s.adc.Hostname(), ad.ComputerObject, "", r.GetPurge())
 	}
 	// Update a single user
-	return s.updatePolicyFor(stream.Context(), r.GetIsComputer(), target, objectClass, r.Krb5Cc, r.GetPurge())
+	return s.updatePolicyFor(ctx, r.GetIsComputer(), target, objectClass, r.Krb5Cc, r.GetPurge())
+}
+
+// RefreshPolicies requests an immediate refresh of the machine and all active users policies in the background.
+// Any request received while a refresh is in flight or queued is coalesced with it.
+func (s *Service) RefreshPolicies(ctx context.Context) {
+	s.refresher.Trigger(ctx)
+}
+
+// updateAllPolicies updates or purges the policy of the machine and then all active users.
+// In replay mode, all users from the imported cache are replayed instead.
+func (s *Service) updateAllPolicies(ctx context.Context, purge bool) error {
+	err := s.updatePolicyFor(ctx, true, s.adc.Hostname(), ad.ComputerObject, "", purge)
+
+	listUsers := func() ([]string, error) { return s.adc.ListUsers(ctx, !purge) }
+	if s.policyManager.IsReplaying() {
+		listUsers = s.policyManager.ReplayedUsers
+	}
+	users, err2 := listUsers()
+	if err2 != nil {
+		return err2
+	}
+	errg := new(errgroup.Group)
+	for _, user := range users {
+		errg.Go(func() (err error) {
+			return s.updatePolicyFor(ctx, false, user, ad.UserObject, "", purge)
+		})
+	}
+	if err := errg.Wait(); err != nil {
+		return fmt.Errorf("one or more error for updating all users: %w", err)
+	}
+
+	return err
 }
 
 // updatePolicyFor updates the policy for a given object.
+// User policies are queued when the maximum of concurrent user applies is reached, while the computer policy
+// is never queued behind them.
 func (s *Service) updatePolicyFor(ctx context.Context, isComputer bool, target string, objectClass ad.ObjectClass, krb5cc string, purge bool) (err error) {
+	if !isComputer {
+		release, err := s.userApplies.Acquire(ctx, target)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	// Only compute policies from the imported cache, without contacting AD nor applying anything.
+	if s.policyManager.IsReplaying() {
+		if purge {
+			return errors.New(gotext.Get("can't purge policies while replaying an imported cache"))
+		}
+		return s.policyManager.ReplayPolicies(ctx, target, isComputer)
+	}
+
 	var pols policies.Policies
 	if !purge {
 		pols, err = s.adc.GetPolicies(ctx, target, objectClass, krb5cc)
@@ -116,6 +171,322 @@ func (s *Service) DumpPolicies(r *adsys.DumpPoliciesRequest, stream adsys.Servic
 	return nil
 }
 
+// EffectivePolicy returns the YAML document of the policy in effect on the machine.
+func (s *Service) EffectivePolicy(_ *adsys.Empty, stream adsys.Service_EffectivePolicyServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while exporting effective policy"))
+
+	// machine policy display is allowed to all users
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), authorizer.ActionAlwaysAllowed); err != nil {
+		return err
+	}
+
+	doc, err := s.policyManager.EffectivePolicy(stream.Context())
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(&adsys.StringResponse{
+		Msg: doc,
+	}); err != nil {
+		log.Warningf(stream.Context(), "couldn't send effective policy to client: %v", err)
+	}
+
+	return nil
+}
+
+// IgnoredEntries returns the policy entries which were ignored during the last refresh of the target, and why.
+func (s *Service) IgnoredEntries(r *adsys.IgnoredEntriesRequest, stream adsys.Service_IgnoredEntriesServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while getting ignored policy entries"))
+
+	objectClass := ad.UserObject
+	if r.GetIsComputer() {
+		objectClass = ad.ComputerObject
+	}
+
+	target, err := s.adc.NormalizeTargetName(stream.Context(), r.GetTarget(), objectClass)
+	if err != nil {
+		return err
+	}
+
+	// hostname ignored entries are available to all users
+	if target != s.adc.Hostname() {
+		if err := s.authorizer.IsAllowedFromContext(context.WithValue(stream.Context(), authorizer.OnUserKey, target),
+			actions.ActionPolicyDump); err != nil {
+			return err
+		}
+	}
+
+	entries, err := s.policyManager.IgnoredEntries(stream.Context(), target, r.GetIsComputer())
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := stream.Send(&adsys.IgnoredEntryResponse{
+			Rule:    e.Rule,
+			Key:     e.Key,
+			Value:   e.Value,
+			Reason:  string(e.Reason),
+			Details: e.Details,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RestartHints returns the policies whose changes during the last refresh of the target need a relogin or reboot
+// to take full effect.
+func (s *Service) RestartHints(r *adsys.RestartHintsRequest, stream adsys.Service_RestartHintsServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while getting restart hints"))
+
+	objectClass := ad.UserObject
+	if r.GetIsComputer() {
+		objectClass = ad.ComputerObject
+	}
+
+	target, err := s.adc.NormalizeTargetName(stream.Context(), r.GetTarget(), objectClass)
+	if err != nil {
+		return err
+	}
+
+	// hostname restart hints are available to all users
+	if target != s.adc.Hostname() {
+		if err := s.authorizer.IsAllowedFromContext(context.WithValue(stream.Context(), authorizer.OnUserKey, target),
+			actions.ActionPolicyDump); err != nil {
+			return err
+		}
+	}
+
+	hints, err := s.policyManager.RestartHints(stream.Context(), target, r.GetIsComputer())
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hints {
+		if err := stream.Send(&adsys.RestartHintResponse{
+			Rule:    h.Rule,
+			Kind:    string(h.Kind),
+			Details: h.Details,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PolicyDrift returns, for each GPO applicable to the target, its cached version and its current version on the
+// AD controller, without applying anything.
+func (s *Service) PolicyDrift(r *adsys.PolicyDriftRequest, stream adsys.Service_PolicyDriftServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while getting GPO version drift"))
+
+	objectClass := ad.UserObject
+	if r.GetIsComputer() {
+		objectClass = ad.ComputerObject
+	}
+
+	target, err := s.adc.NormalizeTargetName(stream.Context(), r.GetTarget(), objectClass)
+	if err != nil {
+		return err
+	}
+
+	// hostname GPO versions are available to all users
+	if target != s.adc.Hostname() {
+		if err := s.authorizer.IsAllowedFromContext(context.WithValue(stream.Context(), authorizer.OnUserKey, target),
+			actions.ActionPolicyDump); err != nil {
+			return err
+		}
+	}
+
+	drifts, err := s.adc.GPOVersionDrift(stream.Context(), target, objectClass, "")
+	if err != nil {
+		return err
+	}
+
+	for _, d := range drifts {
+		if err := stream.Send(&adsys.GPODriftResponse{
+			Name:           d.Name,
+			Id:             d.ID,
+			Cached:         d.Cached,
+			CachedVersion:  int64(d.CachedVersion),
+			CurrentVersion: int64(d.CurrentVersion),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PolicyFetchDryRun returns, for each GPO applicable to the target, its version and size on the AD controller and if
+// it would be downloaded on next update. Nothing is downloaded nor applied.
+func (s *Service) PolicyFetchDryRun(r *adsys.PolicyFetchRequest, stream adsys.Service_PolicyFetchDryRunServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while listing GPOs to fetch"))
+
+	objectClass := ad.UserObject
+	if r.GetIsComputer() {
+		objectClass = ad.ComputerObject
+	}
+
+	target, err := s.adc.NormalizeTargetName(stream.Context(), r.GetTarget(), objectClass)
+	if err != nil {
+		return err
+	}
+
+	// hostname GPOs are available to all users
+	if target != s.adc.Hostname() {
+		if err := s.authorizer.IsAllowedFromContext(context.WithValue(stream.Context(), authorizer.OnUserKey, target),
+			actions.ActionPolicyDump); err != nil {
+			return err
+		}
+	}
+
+	gpos, err := s.adc.FetchDryRun(stream.Context(), target, objectClass, "")
+	if err != nil {
+		return err
+	}
+
+	for _, g := range gpos {
+		if err := stream.Send(&adsys.GPOFetchResponse{
+			Name:     g.Name,
+			Id:       g.ID,
+			Version:  int64(g.Version),
+			Size:     g.Size,
+			Download: g.Download,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SimulatePolicy returns the YAML document of the policy a computer would get once placed in another
+// organizational unit. Nothing is applied.
+func (s *Service) SimulatePolicy(r *adsys.SimulatePolicyRequest, stream adsys.Service_SimulatePolicyServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while simulating policy"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+
+	computer := r.GetComputer()
+	if computer == "" {
+		computer = s.adc.Hostname()
+	}
+	computer, err = s.adc.NormalizeTargetName(stream.Context(), computer, ad.ComputerObject)
+	if err != nil {
+		return err
+	}
+
+	pols, err := s.adc.SimulatePolicies(stream.Context(), computer, r.GetOu())
+	if err != nil {
+		return err
+	}
+	defer pols.Close()
+
+	doc, err := s.policyManager.SimulatePolicy(stream.Context(), pols)
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(&adsys.StringResponse{
+		Msg: doc,
+	}); err != nil {
+		log.Warningf(stream.Context(), "couldn't send simulated policy to client: %v", err)
+	}
+
+	return nil
+}
+
+// VerifyPolicy compares the files managed by the machine policy with the content the policy would write.
+// If requested, the machine policy is applied again when any file drifted.
+func (s *Service) VerifyPolicy(r *adsys.VerifyPolicyRequest, stream adsys.Service_VerifyPolicyServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while verifying managed files"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+	// Fixing the files is a machine policy update.
+	if r.GetFix() {
+		if err := s.authorizer.IsAllowedFromContext(context.WithValue(stream.Context(), authorizer.OnUserKey, "root"),
+			actions.ActionPolicyUpdate); err != nil {
+			return err
+		}
+	}
+
+	files, err := s.policyManager.VerifyPolicies(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	// stillDrifted are the paths of the files which drifted again, or which couldn't be fixed.
+	var stillDrifted map[string]bool
+	if r.GetFix() && slices.ContainsFunc(files, integrity.File.Drifted) {
+		log.Info(stream.Context(), gotext.Get("Some managed files drifted, applying the machine policy again"))
+		if err := s.updatePolicyFor(stream.Context(), true, s.adc.Hostname(), ad.ComputerObject, "", false); err != nil {
+			return err
+		}
+		after, err := s.policyManager.VerifyPolicies(stream.Context())
+		if err != nil {
+			return err
+		}
+		stillDrifted = make(map[string]bool)
+		for _, f := range after {
+			stillDrifted[f.Path] = f.Drifted()
+		}
+	}
+
+	for _, f := range files {
+		if err := stream.Send(&adsys.ManagedFileResponse{
+			Rule:     f.Rule,
+			Path:     f.Path,
+			Exists:   f.GotExists,
+			Expected: f.WantExists,
+			Drifted:  f.Drifted(),
+			Fixed:    f.Drifted() && stillDrifted != nil && !stillDrifted[f.Path],
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PolicyPurge lists the files managed by the policy types no applied policy uses anymore and, if requested, removes
+// them.
+func (s *Service) PolicyPurge(r *adsys.PolicyPurgeRequest, stream adsys.Service_PolicyPurgeServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while purging stale managed files"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+	// Removing the files changes the machine policy.
+	if r.GetApply() {
+		if err := s.authorizer.IsAllowedFromContext(context.WithValue(stream.Context(), authorizer.OnUserKey, "root"),
+			actions.ActionPolicyUpdate); err != nil {
+			return err
+		}
+	}
+
+	files, err := s.policyManager.PurgeStaleFiles(stream.Context(), r.GetTypes(), r.GetApply())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if err := stream.Send(&adsys.StaleFileResponse{
+			Rule:    f.Rule,
+			Path:    f.Path,
+			Removed: f.Removed,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // DumpPoliciesDefinitions dumps requested policy definitions stored in daemon at build time.
 func (s *Service) DumpPoliciesDefinitions(r *adsys.DumpPolicyDefinitionsRequest, stream adsys.Service_DumpPoliciesDefinitionsServer) (err error) {
 	defer decorate.OnError(&err, gotext.Get("error while dumping policy definitions"))
@@ -0,0 +1,85 @@
+package adsysservice
+
+import (
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys"
+	"github.com/ubuntu/adsys/internal/adsysservice/actions"
+	"github.com/ubuntu/decorate"
+)
+
+// PrivilegeFiles returns the current content of the sudoers and polkit files managed by the privilege policy.
+func (s *Service) PrivilegeFiles(_ *adsys.Empty, stream adsys.Service_PrivilegeFilesServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while getting privilege files"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+
+	files, err := s.policyManager.PrivilegeFiles(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if err := stream.Send(&adsys.PrivilegeFileResponse{
+			Path:    f.Path,
+			Content: f.Content,
+			Exists:  f.Exists,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SudoAccess reports whether the privilege policy grants sudo to the requested user, and which rule decides it.
+func (s *Service) SudoAccess(r *adsys.SudoAccessRequest, stream adsys.Service_SudoAccessServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while checking sudo access"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+
+	access, err := s.policyManager.SudoAccess(stream.Context(), r.GetUser())
+	if err != nil {
+		return err
+	}
+
+	return stream.Send(&adsys.SudoAccessResponse{
+		Matched:        access.Matched,
+		Granted:        access.Granted,
+		Path:           access.Path,
+		Line:           int32(access.Line),
+		Rule:           access.Rule,
+		Identity:       access.Identity,
+		PolkitIdentity: access.PolkitIdentity,
+	})
+}
+
+// SystemAdminIdentities returns all the system polkit configuration files setting administrators, and which one is
+// preserved by the privilege policy.
+func (s *Service) SystemAdminIdentities(_ *adsys.Empty, stream adsys.Service_SystemAdminIdentitiesServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while getting system polkit administrators"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+
+	files, err := s.policyManager.SystemAdminIdentities(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if err := stream.Send(&adsys.SystemAdminIdentitiesResponse{
+			Path:      f.Path,
+			Value:     f.Value,
+			Preserved: f.Preserved,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
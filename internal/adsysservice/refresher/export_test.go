@@ -0,0 +1,18 @@
+package refresher
+
+// FollowUpQueued returns true if a follow-up refresh is queued.
+func (r *Refresher) FollowUpQueued() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.followUp != nil
+}
+
+// FollowUpWaiters returns the number of requests waiting for the queued follow-up refresh.
+func (r *Refresher) FollowUpWaiters() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.followUp == nil {
+		return 0
+	}
+	return r.followUp.waiters
+}
@@ -0,0 +1,142 @@
+// Package refresher serializes full policy refreshes, coalescing requests received while a refresh is already in flight.
+package refresher
+
+import (
+	"context"
+	"sync"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+)
+
+// Refresher runs a refresh function, ensuring that only one refresh is executed at a given time.
+// A request received while a refresh is in flight is either merged with it or with a single queued follow-up refresh.
+type Refresher struct {
+	refresh func(context.Context) error
+
+	// lock is held while a refresh or any exclusive operation is executed.
+	lock sync.Mutex
+
+	mu       sync.Mutex
+	inFlight *call
+	followUp *call
+	wg       sync.WaitGroup
+}
+
+// call is a refresh execution, shared by all requests coalesced onto it.
+type call struct {
+	done chan struct{}
+	err  error
+	// waiters is the number of requests waiting for the call.
+	waiters int
+}
+
+// wait returns the result of the call once it completes, or the error of ctx if it is done before.
+func (c *call) wait(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// New returns a Refresher calling refresh on each non coalesced request.
+func New(refresh func(context.Context) error) *Refresher {
+	return &Refresher{refresh: refresh}
+}
+
+// Run requests a refresh and waits for it to complete, or for ctx to be done.
+// If a refresh is already in flight, a single follow-up refresh is queued after it, and any request received
+// meanwhile waits for that follow-up and shares its result. The follow-up is not cancelled with the request which
+// queued it, as the others are still waiting for it.
+func (r *Refresher) Run(ctx context.Context) error {
+	r.mu.Lock()
+	if r.followUp != nil {
+		c := r.followUp
+		c.waiters++
+		r.mu.Unlock()
+		log.Debug(ctx, "A policy refresh is already queued, waiting for it")
+		return c.wait(ctx)
+	}
+	c := &call{done: make(chan struct{}), waiters: 1}
+	if r.inFlight == nil {
+		r.inFlight = c
+		r.mu.Unlock()
+		r.execute(ctx, c)
+		return c.err
+	}
+	log.Debug(ctx, "A policy refresh is in progress, queuing a follow-up refresh")
+	r.followUp = c
+	r.wg.Add(1)
+	r.mu.Unlock()
+
+	go func() {
+		defer r.wg.Done()
+		r.execute(context.WithoutCancel(ctx), c)
+	}()
+	return c.wait(ctx)
+}
+
+// Trigger requests an immediate refresh and returns without waiting for it.
+// It returns false if the request was coalesced with an in-flight or already queued refresh.
+func (r *Refresher) Trigger(ctx context.Context) bool {
+	log.Info(ctx, gotext.Get("Manual policy refresh requested"))
+
+	r.mu.Lock()
+	if r.inFlight != nil || r.followUp != nil {
+		r.mu.Unlock()
+		log.Info(ctx, gotext.Get("A policy refresh is already in progress, coalescing request with it"))
+		return false
+	}
+	c := &call{done: make(chan struct{})}
+	r.inFlight = c
+	r.wg.Add(1)
+	r.mu.Unlock()
+
+	go func() {
+		defer r.wg.Done()
+
+		r.execute(ctx, c)
+		if c.err != nil {
+			log.Warning(ctx, gotext.Get("Manual policy refresh failed: %v", c.err))
+			return
+		}
+		log.Info(ctx, gotext.Get("Manual policy refresh completed successfully"))
+	}()
+
+	return true
+}
+
+// Do runs fn while no refresh is executed, without coalescing it with any other request.
+func (r *Refresher) Do(fn func() error) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return fn()
+}
+
+// Wait blocks until any refresh started by Trigger, or follow-up refresh queued by Run, is done.
+func (r *Refresher) Wait() {
+	r.wg.Wait()
+}
+
+// execute runs the refresh for c once the refresh lock is acquired, and then releases any waiter.
+func (r *Refresher) execute(ctx context.Context, c *call) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.mu.Lock()
+	if r.followUp == c {
+		r.followUp = nil
+	}
+	r.inFlight = c
+	r.mu.Unlock()
+
+	c.err = r.refresh(ctx)
+
+	r.mu.Lock()
+	r.inFlight = nil
+	r.mu.Unlock()
+	close(c.done)
+}
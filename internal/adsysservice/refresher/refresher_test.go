@@ -0,0 +1,231 @@
+package refresher_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/adsysservice/refresher"
+)
+
+func TestTrigger(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		refreshErr error
+	}{
+		"Refresh succeeds":            {},
+		"Refresh failure is reported": {refreshErr: errors.New("refresh error")},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls atomic.Int32
+			r := refresher.New(func(context.Context) error {
+				calls.Add(1)
+				return tc.refreshErr
+			})
+
+			require.True(t, r.Trigger(context.Background()), "Trigger should start a refresh")
+			r.Wait()
+			require.Equal(t, int32(1), calls.Load(), "Refresh should have been called once")
+
+			require.True(t, r.Trigger(context.Background()), "Trigger should start a new refresh once the previous one is done")
+			r.Wait()
+			require.Equal(t, int32(2), calls.Load(), "Refresh should have been called again")
+		})
+	}
+}
+
+func TestTriggerCoalescesRequestsWhileRefreshing(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	r := refresher.New(func(context.Context) error {
+		calls.Add(1)
+		close(started)
+		<-release
+		return nil
+	})
+
+	require.True(t, r.Trigger(context.Background()), "First trigger should start a refresh")
+	<-started
+	for range 10 {
+		require.False(t, r.Trigger(context.Background()), "Trigger during a refresh should be coalesced")
+	}
+	close(release)
+	r.Wait()
+
+	require.Equal(t, int32(1), calls.Load(), "Rapid triggers should only refresh once")
+}
+
+func TestRunQueuesOneFollowUpRefresh(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	r := refresher.New(func(context.Context) error {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		return nil
+	})
+
+	require.True(t, r.Trigger(context.Background()), "First trigger should start a refresh")
+	<-started
+
+	done := make(chan error)
+	go func() { done <- r.Run(context.Background()) }()
+	require.Eventually(t, r.FollowUpQueued, time.Second, 10*time.Millisecond, "Run during a refresh should queue a follow-up")
+	require.False(t, r.Trigger(context.Background()), "Trigger should be coalesced with the queued follow-up")
+
+	close(release)
+	require.NoError(t, <-done, "Run should return the follow-up refresh result")
+	r.Wait()
+
+	require.Equal(t, int32(2), calls.Load(), "Refresh should have been called for the in-flight and the follow-up runs only")
+}
+
+func TestRunSharesQueuedFollowUpResult(t *testing.T) {
+	t.Parallel()
+
+	errFollowUp := errors.New("follow-up error")
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	r := refresher.New(func(context.Context) error {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+			return nil
+		}
+		return errFollowUp
+	})
+
+	require.True(t, r.Trigger(context.Background()), "First trigger should start a refresh")
+	<-started
+
+	errs := make(chan error, 3)
+	go func() { errs <- r.Run(context.Background()) }()
+	require.Eventually(t, r.FollowUpQueued, time.Second, 10*time.Millisecond, "Run during a refresh should queue a follow-up")
+	for range 2 {
+		go func() { errs <- r.Run(context.Background()) }()
+	}
+	require.Eventually(t, func() bool { return r.FollowUpWaiters() == 3 }, time.Second, 10*time.Millisecond,
+		"Runs during a refresh should wait for the queued follow-up")
+
+	close(release)
+	for range 3 {
+		require.ErrorIs(t, <-errs, errFollowUp, "Run should return the follow-up refresh error")
+	}
+	r.Wait()
+
+	require.Equal(t, int32(2), calls.Load(), "Refresh should have been called for the in-flight and the follow-up runs only")
+}
+
+func TestRunWaiterCancellation(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	var followUpErr error
+	started, release := make(chan struct{}), make(chan struct{})
+	r := refresher.New(func(ctx context.Context) error {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+			return nil
+		}
+		followUpErr = ctx.Err()
+		return nil
+	})
+
+	require.True(t, r.Trigger(context.Background()), "First trigger should start a refresh")
+	<-started
+
+	// The request queuing the follow-up and a coalesced one are cancelled, another one keeps waiting.
+	queuingCtx, cancelQueuing := context.WithCancel(context.Background())
+	queuing := make(chan error, 1)
+	go func() { queuing <- r.Run(queuingCtx) }()
+	require.Eventually(t, r.FollowUpQueued, time.Second, 10*time.Millisecond, "Run during a refresh should queue a follow-up")
+	coalescedCtx, cancelCoalesced := context.WithCancel(context.Background())
+	coalesced := make(chan error, 1)
+	go func() { coalesced <- r.Run(coalescedCtx) }()
+	waiting := make(chan error, 1)
+	go func() { waiting <- r.Run(context.Background()) }()
+	require.Eventually(t, func() bool { return r.FollowUpWaiters() == 3 }, time.Second, 10*time.Millisecond,
+		"Runs during a refresh should wait for the queued follow-up")
+
+	cancelQueuing()
+	cancelCoalesced()
+	require.ErrorIs(t, <-queuing, context.Canceled, "Run queuing the follow-up should return once cancelled")
+	require.ErrorIs(t, <-coalesced, context.Canceled, "Coalesced Run should return once cancelled")
+
+	close(release)
+	require.NoError(t, <-waiting, "Run still waiting should return the follow-up refresh result")
+	r.Wait()
+
+	require.NoError(t, followUpErr, "Follow-up refresh should not be cancelled with the request queuing it")
+	require.Equal(t, int32(2), calls.Load(), "Refresh should have been called for the in-flight and the follow-up runs only")
+}
+
+func TestConcurrentRefreshesDontOverlap(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "first"), filepath.Join(dir, "second")}
+
+	var running, overlaps, generation atomic.Int32
+	r := refresher.New(func(context.Context) error {
+		if running.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		defer running.Add(-1)
+
+		// Write a set of managed files, which would be inconsistent if another refresh interleaves.
+		gen := []byte(strconv.Itoa(int(generation.Add(1))))
+		for _, f := range files {
+			if err := os.WriteFile(f, gen, 0600); err != nil {
+				return err
+			}
+			time.Sleep(time.Millisecond)
+		}
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, r.Run(context.Background()), "Run should succeed")
+		}()
+		go func() {
+			defer wg.Done()
+			require.NoError(t, r.Do(func() error {
+				if running.Load() > 0 {
+					overlaps.Add(1)
+				}
+				return nil
+			}), "Do should succeed")
+		}()
+		r.Trigger(context.Background())
+	}
+	wg.Wait()
+	r.Wait()
+
+	require.Zero(t, overlaps.Load(), "Refreshes and exclusive operations should never overlap")
+	first, err := os.ReadFile(files[0])
+	require.NoError(t, err, "Setup: failed to read first managed file")
+	second, err := os.ReadFile(files[1])
+	require.NoError(t, err, "Setup: failed to read second managed file")
+	require.Equal(t, string(first), string(second), "Managed files should be consistent after concurrent refreshes")
+}
@@ -3,6 +3,7 @@ package adsysservice
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 	"slices"
 	"strings"
 	"time"
@@ -81,6 +82,13 @@ func (s *Service) Status(_ *adsys.Empty, stream adsys.Service_StatusServer) (err
 	if state.apparmorDir == "" {
 		state.apparmorDir = consts.DefaultApparmorDir
 	}
+	// The managed paths are relative to the alternate root, if any.
+	if state.rootDir != "" {
+		state.dconfDir = filepath.Join(state.rootDir, state.dconfDir)
+		state.sudoersDir = filepath.Join(state.rootDir, state.sudoersDir)
+		state.policyKitDir = filepath.Join(state.rootDir, state.policyKitDir)
+		state.apparmorDir = filepath.Join(state.rootDir, state.apparmorDir)
+	}
 
 	timeout := gotext.Get("unknown")
 	socket := gotext.Get("unknown")
@@ -0,0 +1,90 @@
+package adsysservice
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys"
+	"github.com/ubuntu/adsys/internal/adsysservice/actions"
+	"github.com/ubuntu/adsys/internal/adsysservice/cachearchive"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// tracesDirName is the cache subdirectory holding the trace bundles of the traced refreshes.
+	tracesDirName = "traces"
+	// maxTraceBundles is the number of most recent trace bundles kept.
+	maxTraceBundles = 5
+)
+
+// withTraceBundle returns a copy of ctx recording in a new trace bundle. Only the most recent bundles are kept.
+func (s *Service) withTraceBundle(ctx context.Context) (context.Context, error) {
+	tracesDir := filepath.Join(s.state.cacheDir, tracesDirName)
+	b, err := trace.New(filepath.Join(tracesDir, time.Now().UTC().Format("20060102T150405.000Z")))
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, gotext.Get("Recording trace bundle in %s", b.Dir()))
+
+	bundles, err := traceBundles(tracesDir)
+	if err != nil {
+		return nil, err
+	}
+	for len(bundles) > maxTraceBundles {
+		if err := os.RemoveAll(filepath.Join(tracesDir, bundles[0])); err != nil {
+			return nil, err
+		}
+		bundles = bundles[1:]
+	}
+
+	return trace.WithBundle(ctx, b), nil
+}
+
+// TraceExport streams a compressed archive of the most recent trace bundle.
+func (s *Service) TraceExport(_ *adsys.Empty, stream adsys.Service_TraceExportServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while exporting trace bundle"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+
+	tracesDir := filepath.Join(s.state.cacheDir, tracesDirName)
+	bundles, err := traceBundles(tracesDir)
+	if err != nil {
+		return err
+	}
+	if len(bundles) == 0 {
+		return errors.New(gotext.Get("no trace bundle was recorded"))
+	}
+
+	w := bufio.NewWriterSize(exportStreamWriter{stream}, exportChunkSize)
+	if err := cachearchive.Export(stream.Context(), w, tracesDir, bundles[len(bundles)-1:]); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// traceBundles returns the names of the trace bundles in tracesDir, from the oldest to the most recent.
+func traceBundles(tracesDir string) ([]string, error) {
+	entries, err := os.ReadDir(tracesDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// Entries are sorted by name, and bundles are named after their UTC creation time.
+	var bundles []string
+	for _, e := range entries {
+		if e.IsDir() {
+			bundles = append(bundles, e.Name())
+		}
+	}
+	return bundles, nil
+}
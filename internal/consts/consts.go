@@ -34,6 +34,9 @@ const (
 	// DefaultShareDir is the default path for adsys share directory.
 	DefaultShareDir = "/usr/share/adsys"
 
+	// DefaultExtensionsDir is the default path for the declarations of the policy types added by third parties.
+	DefaultExtensionsDir = "/etc/adsys/extensions.d"
+
 	// DefaultClientTimeout is the maximum default time in seconds between 2 server activities before the client returns and abort the request.
 	DefaultClientTimeout = 30
 
@@ -43,6 +46,16 @@ const (
 	// DefaultGpoListTimeout is the default time to wait for the GPO list subcommand to finish.
 	DefaultGpoListTimeout = 10 * time.Second
 
+	// DefaultMaxConcurrentUserApplies is the default maximum number of user policies fetched and applied concurrently.
+	DefaultMaxConcurrentUserApplies = 4
+
+	// DefaultLogBufferSize is the default number of logs buffered for each client before the oldest are dropped.
+	DefaultLogBufferSize = 1000
+
+	// DefaultSudoersAliasThreshold is the default number of client administrators from which their sudoers rules are
+	// grouped in an alias.
+	DefaultSudoersAliasThreshold = 10
+
 	// DistroID is the distro ID which can be overridden at build time.
 	DistroID = "Ubuntu"
 )
@@ -59,6 +72,8 @@ const (
 	DefaultDconfDir = "/etc/dconf"
 	// DefaultSudoersDir is the default directory for sudoers configuration.
 	DefaultSudoersDir = "/etc/sudoers.d"
+	// DefaultSudoersConf is the default main sudoers configuration file.
+	DefaultSudoersConf = "/etc/sudoers"
 	// DefaultPolicyKitDir is the default directory for policykit configuration and rules.
 	DefaultPolicyKitDir = "/etc/polkit-1"
 	// DefaultApparmorDir is the default directory for apparmor configuration.
@@ -67,6 +82,34 @@ const (
 	DefaultSystemUnitDir = "/etc/systemd/system"
 	// DefaultGlobalTrustDir is the default directory for the global trust store.
 	DefaultGlobalTrustDir = "/usr/local/share/ca-certificates"
+	// DefaultSysctlDir is the default directory for sysctl configuration.
+	DefaultSysctlDir = "/etc/sysctl.d"
+	// DefaultHostsAccessDir is the default directory for the hosts.allow and hosts.deny files.
+	DefaultHostsAccessDir = "/etc"
+	// DefaultNetworkConnectionsDir is the default directory for NetworkManager system connection profiles.
+	DefaultNetworkConnectionsDir = "/etc/NetworkManager/system-connections"
+	// DefaultEnvironmentDir is the default directory for the environment.d configuration of user sessions.
+	DefaultEnvironmentDir = "/etc/environment.d"
+	// DefaultLimitsDir is the default directory for the pam_limits configuration of user sessions.
+	DefaultLimitsDir = "/etc/security/limits.d"
+	// DefaultBannerDir is the default directory for the issue and issue.net files and the motd.d directory.
+	DefaultBannerDir = "/etc"
+	// DefaultTimeDateDir is the default directory for the localtime link and the timesyncd and chrony configuration.
+	DefaultTimeDateDir = "/etc"
+	// DefaultPamConfigsDir is the default directory for the pam-config profiles.
+	DefaultPamConfigsDir = "/usr/share/pam-configs"
+	// DefaultSecurityDir is the default directory for the access.conf file.
+	DefaultSecurityDir = "/etc/security"
+	// DefaultAuditRulesDir is the default directory for the auditd rules.
+	DefaultAuditRulesDir = "/etc/audit/rules.d"
+	// DefaultGrubDefaultsDir is the default directory for the GRUB configuration fragments.
+	DefaultGrubDefaultsDir = "/etc/default/grub.d"
+	// DefaultNetplanDir is the default directory for the Netplan configuration files.
+	DefaultNetplanDir = "/etc/netplan"
+	// DefaultFontsDir is the default directory for the locally installed fonts.
+	DefaultFontsDir = "/usr/local/share/fonts"
+	// DefaultThemesDir is the default directory for the locally installed themes.
+	DefaultThemesDir = "/usr/local/share/themes"
 )
 
 // SSSD related properties.
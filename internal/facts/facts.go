@@ -0,0 +1,280 @@
+// Package facts collects facts about the machine, like its chassis type, which policy entries, and whole GPOs, can be
+// conditioned on.
+//
+// A condition is a list of comparisons, joined by "&&" and "||" ("&&" binds tighter). Each comparison is
+// either "<fact>==<value>", "<fact>!=<value>", or a boolean fact name, optionally negated with "!".
+// For instance: "chassis==laptop || has-battery".
+//
+// The presence of a package is the boolean fact "package:<name>", like "package:docker.io".
+package facts
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+)
+
+const (
+	// ChassisLaptop is the chassis of portable computers, including convertible and detachable ones.
+	ChassisLaptop = "laptop"
+	// ChassisDesktop is the chassis of desktop and all-in-one computers.
+	ChassisDesktop = "desktop"
+	// ChassisServer is the chassis of servers, including rack mounted and blade ones.
+	ChassisServer = "server"
+	// ChassisTablet is the chassis of tablets and handheld devices.
+	ChassisTablet = "tablet"
+	// ChassisUnknown is used when the chassis type can't be determined.
+	ChassisUnknown = "unknown"
+
+	// NetworkLocationDomain is the network location when the Active Directory controller is reachable.
+	NetworkLocationDomain = "domain"
+	// NetworkLocationExternal is the network location when the Active Directory controller is not reachable.
+	NetworkLocationExternal = "external"
+)
+
+// chassisTypes maps the SMBIOS chassis types to the chassis fact value.
+var chassisTypes = map[int]string{
+	3: ChassisDesktop, 4: ChassisDesktop, 5: ChassisDesktop, 6: ChassisDesktop, 7: ChassisDesktop,
+	13: ChassisDesktop, 15: ChassisDesktop, 16: ChassisDesktop, 24: ChassisDesktop, 35: ChassisDesktop, 36: ChassisDesktop,
+	8: ChassisLaptop, 9: ChassisLaptop, 10: ChassisLaptop, 14: ChassisLaptop, 31: ChassisLaptop, 32: ChassisLaptop,
+	17: ChassisServer, 23: ChassisServer, 25: ChassisServer, 28: ChassisServer, 29: ChassisServer,
+	11: ChassisTablet, 30: ChassisTablet,
+}
+
+// Facts are the machine facts policy entries can be conditioned on.
+type Facts struct {
+	// Chassis is the machine form factor: laptop, desktop, server, tablet or unknown.
+	Chassis string
+	// HasBattery is true if the machine is powered by at least one battery.
+	HasBattery bool
+	// NetworkLocation is domain when the Active Directory controller is reachable, external otherwise.
+	NetworkLocation string
+	// OSVersion is the version of the distribution, like 24.04. It is empty if it can't be determined.
+	OSVersion string
+	// Arch is the architecture of the machine, like amd64.
+	Arch string
+	// Packages are the names of the installed packages, sorted.
+	Packages []string
+}
+
+// Provider collects the machine facts.
+type Provider struct {
+	root     string
+	isOnline func() (bool, error)
+}
+
+type options struct {
+	root string
+}
+
+// Option reprents an optional function to change the facts provider.
+type Option func(*options)
+
+// WithRoot specifies a personalized root directory from which sysfs, os-release and the dpkg database are read.
+func WithRoot(root string) Option {
+	return func(o *options) {
+		o.root = root
+	}
+}
+
+// New returns a facts provider. isOnline reports if the Active Directory controller is reachable.
+func New(isOnline func() (bool, error), opts ...Option) *Provider {
+	// Set default options
+	args := options{
+		root: "/",
+	}
+
+	// Apply given options
+	for _, f := range opts {
+		f(&args)
+	}
+
+	return &Provider{
+		root:     args.root,
+		isOnline: isOnline,
+	}
+}
+
+// Collect returns the current machine facts.
+// Any fact which can't be determined is set to its default value.
+func (p Provider) Collect(ctx context.Context) Facts {
+	f := Facts{
+		Chassis:         p.chassis(ctx),
+		HasBattery:      p.hasBattery(),
+		NetworkLocation: NetworkLocationExternal,
+		OSVersion:       p.osVersion(ctx),
+		Arch:            runtime.GOARCH,
+		Packages:        p.packages(ctx),
+	}
+	if online, err := p.isOnline(); err != nil {
+		log.Debugf(ctx, "Can't determine if Active Directory is reachable: %v", err)
+	} else if online {
+		f.NetworkLocation = NetworkLocationDomain
+	}
+
+	// Don't list all the packages.
+	log.Debugf(ctx, "Collected machine facts: chassis=%s has-battery=%v network-location=%s os-version=%s arch=%s, %d installed packages",
+		f.Chassis, f.HasBattery, f.NetworkLocation, f.OSVersion, f.Arch, len(f.Packages))
+	return f
+}
+
+// osVersion returns the VERSION_ID of os-release.
+func (p Provider) osVersion(ctx context.Context) string {
+	var d []byte
+	var err error
+	for _, path := range []string{filepath.Join("etc", "os-release"), filepath.Join("usr", "lib", "os-release")} {
+		if d, err = os.ReadFile(filepath.Join(p.root, path)); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		log.Debugf(ctx, "Can't read os-release: %v", err)
+		return ""
+	}
+
+	for _, l := range strings.Split(string(d), "\n") {
+		if v, found := strings.CutPrefix(strings.TrimSpace(l), "VERSION_ID="); found {
+			return strings.Trim(v, `"'`)
+		}
+	}
+	return ""
+}
+
+// packages returns the sorted names of the packages installed according to the dpkg status database.
+func (p Provider) packages(ctx context.Context) (names []string) {
+	f, err := os.Open(filepath.Join(p.root, "var", "lib", "dpkg", "status"))
+	if err != nil {
+		log.Debugf(ctx, "Can't read installed packages: %v", err)
+		return nil
+	}
+	defer f.Close()
+
+	// Each package is a paragraph, with its name before its status.
+	var name string
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		l := s.Text()
+		if n, found := strings.CutPrefix(l, "Package:"); found {
+			name = strings.TrimSpace(n)
+			continue
+		}
+		if status, found := strings.CutPrefix(l, "Status:"); found && name != "" && strings.HasSuffix(strings.TrimSpace(status), " installed") {
+			names = append(names, name)
+		}
+	}
+	if err := s.Err(); err != nil {
+		log.Debugf(ctx, "Can't read installed packages: %v", err)
+		return nil
+	}
+
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// chassis returns the chassis fact value from the DMI chassis type.
+func (p Provider) chassis(ctx context.Context) string {
+	d, err := os.ReadFile(filepath.Join(p.root, "sys", "class", "dmi", "id", "chassis_type"))
+	if err != nil {
+		log.Debugf(ctx, "Can't read chassis type: %v", err)
+		return ChassisUnknown
+	}
+	t, err := strconv.Atoi(strings.TrimSpace(string(d)))
+	if err != nil {
+		log.Debugf(ctx, "Invalid chassis type %q: %v", d, err)
+		return ChassisUnknown
+	}
+	if c, ok := chassisTypes[t]; ok {
+		return c
+	}
+	return ChassisUnknown
+}
+
+// hasBattery returns true if any power supply is a battery powering the system, as opposed to peripherals ones.
+func (p Provider) hasBattery() bool {
+	supplies, _ := filepath.Glob(filepath.Join(p.root, "sys", "class", "power_supply", "*"))
+	for _, s := range supplies {
+		if t, err := os.ReadFile(filepath.Join(s, "type")); err != nil || strings.TrimSpace(string(t)) != "Battery" {
+			continue
+		}
+		if scope, err := os.ReadFile(filepath.Join(s, "scope")); err == nil && strings.TrimSpace(string(scope)) == "Device" {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// value returns the value of the fact name, as used in conditions, and whether it exists.
+func (f Facts) value(name string) (string, bool) {
+	if pkg, found := strings.CutPrefix(name, "package:"); found && pkg != "" {
+		_, installed := slices.BinarySearch(f.Packages, pkg)
+		return strconv.FormatBool(installed), true
+	}
+
+	v, ok := map[string]string{
+		"chassis":          f.Chassis,
+		"has-battery":      strconv.FormatBool(f.HasBattery),
+		"network-location": f.NetworkLocation,
+		"os-version":       f.OSVersion,
+		"arch":             f.Arch,
+	}[name]
+	return v, ok
+}
+
+// Eval returns true if the condition is met by the facts.
+// An empty condition is always met.
+func (f Facts) Eval(condition string) (met bool, err error) {
+	if strings.TrimSpace(condition) == "" {
+		return true, nil
+	}
+
+	// Evaluate every comparison, without short-circuiting, so that invalid conditions are always reported.
+	for _, or := range strings.Split(condition, "||") {
+		alternativeMet := true
+		for _, and := range strings.Split(or, "&&") {
+			ok, err := f.evalComparison(strings.TrimSpace(and))
+			if err != nil {
+				return false, errors.New(gotext.Get("invalid condition %q: %v", condition, err))
+			}
+			alternativeMet = alternativeMet && ok
+		}
+		met = met || alternativeMet
+	}
+	return met, nil
+}
+
+// evalComparison evaluates a single comparison against the facts values.
+func (f Facts) evalComparison(comparison string) (bool, error) {
+	name, value, negate := comparison, "true", false
+	if n, v, found := strings.Cut(comparison, "!="); found {
+		name, value, negate = n, v, true
+	} else if n, v, found := strings.Cut(comparison, "=="); found {
+		name, value = n, v
+	} else if n, found := strings.CutPrefix(comparison, "!"); found {
+		name, negate = n, true
+	}
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if name == "" || value == "" {
+		return false, errors.New(gotext.Get("empty fact or value in %q", comparison))
+	}
+
+	v, ok := f.value(name)
+	if !ok {
+		return false, errors.New(gotext.Get("unknown fact %q", name))
+	}
+	if !strings.Contains(comparison, "=") && v != "true" && v != "false" {
+		return false, errors.New(gotext.Get("fact %q is not a boolean", name))
+	}
+
+	return (v == value) != negate, nil
+}
@@ -0,0 +1,117 @@
+package facts_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/facts"
+)
+
+func TestCollect(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		root        string
+		offline     bool
+		onlineError bool
+
+		want facts.Facts
+	}{
+		"Laptop with a battery": {root: "laptop", want: facts.Facts{Chassis: facts.ChassisLaptop, HasBattery: true, NetworkLocation: facts.NetworkLocationDomain}},
+		"Desktop with a peripheral battery": {root: "desktop-with-wireless-mouse",
+			want: facts.Facts{Chassis: facts.ChassisDesktop, NetworkLocation: facts.NetworkLocationDomain}},
+		"Server":                          {root: "server", want: facts.Facts{Chassis: facts.ChassisServer, NetworkLocation: facts.NetworkLocationDomain}},
+		"Offline machine is external":     {root: "laptop", offline: true, want: facts.Facts{Chassis: facts.ChassisLaptop, HasBattery: true, NetworkLocation: facts.NetworkLocationExternal}},
+		"Unknown chassis type":            {root: "unknown-chassis-type", want: facts.Facts{Chassis: facts.ChassisUnknown, NetworkLocation: facts.NetworkLocationDomain}},
+		"Invalid chassis type":            {root: "invalid-chassis-type", want: facts.Facts{Chassis: facts.ChassisUnknown, NetworkLocation: facts.NetworkLocationDomain}},
+		"No sysfs information":            {root: "does-not-exist", want: facts.Facts{Chassis: facts.ChassisUnknown, NetworkLocation: facts.NetworkLocationDomain}},
+		"Online status error is external": {root: "laptop", onlineError: true, want: facts.Facts{Chassis: facts.ChassisLaptop, HasBattery: true, NetworkLocation: facts.NetworkLocationExternal}},
+
+		"OS version and installed packages": {root: "ubuntu", want: facts.Facts{Chassis: facts.ChassisDesktop, NetworkLocation: facts.NetworkLocationDomain,
+			OSVersion: "24.04", Packages: []string{"docker.io", "libc6"}}},
+		"OS version from os-release in /usr/lib": {root: "usr-lib-os-release", want: facts.Facts{Chassis: facts.ChassisUnknown, NetworkLocation: facts.NetworkLocationDomain,
+			OSVersion: "22.04"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			isOnline := func() (bool, error) {
+				if tc.onlineError {
+					return false, errors.New("online status error")
+				}
+				return !tc.offline, nil
+			}
+
+			// The architecture is the one of the running binary.
+			tc.want.Arch = runtime.GOARCH
+
+			p := facts.New(isOnline, facts.WithRoot(filepath.Join("testdata", "sysfs", tc.root)))
+			got := p.Collect(context.Background())
+			require.Equal(t, tc.want, got, "Collect should return the expected facts")
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	t.Parallel()
+
+	laptop := facts.Facts{Chassis: facts.ChassisLaptop, HasBattery: true, NetworkLocation: facts.NetworkLocationExternal,
+		OSVersion: "24.04", Arch: "amd64", Packages: []string{"docker.io", "libc6"}}
+
+	tests := map[string]struct {
+		condition string
+
+		want    bool
+		wantErr bool
+	}{
+		"Empty condition is met": {condition: "", want: true},
+		"Blank condition is met": {condition: "  ", want: true},
+
+		"Equality is met":                {condition: "chassis==laptop", want: true},
+		"Equality is not met":            {condition: "chassis==desktop", want: false},
+		"Inequality is met":              {condition: "chassis!=desktop", want: true},
+		"Inequality is not met":          {condition: "chassis!=laptop", want: false},
+		"Spaces around operands":         {condition: " chassis == laptop ", want: true},
+		"Boolean fact is met":            {condition: "has-battery", want: true},
+		"Negated boolean fact":           {condition: "!has-battery", want: false},
+		"Boolean fact compared to value": {condition: "has-battery==false", want: false},
+		"All comparisons are met":        {condition: "chassis==laptop && network-location==external", want: true},
+		"One comparison is not met":      {condition: "chassis==laptop && network-location==domain", want: false},
+		"One alternative is met":         {condition: "chassis==desktop || has-battery", want: true},
+		"No alternative is met":          {condition: "chassis==desktop || network-location==domain", want: false},
+		"And binds tighter than or":      {condition: "chassis==desktop && has-battery || network-location==external", want: true},
+		"OS version is met":              {condition: "os-version==24.04", want: true},
+		"Architecture is not met":        {condition: "arch==arm64", want: false},
+		"Installed package is met":       {condition: "package:docker.io", want: true},
+		"Missing package is not met":     {condition: "package:podman", want: false},
+		"Negated missing package is met": {condition: "!package:podman && arch==amd64", want: true},
+		"Package compared to value":      {condition: "package:libc6==false", want: false},
+
+		// Error cases
+		"Error on unknown fact":               {condition: "color==blue", wantErr: true},
+		"Error on package without name":       {condition: "package:", wantErr: true},
+		"Error on non boolean fact alone":     {condition: "chassis", wantErr: true},
+		"Error on empty value":                {condition: "chassis==", wantErr: true},
+		"Error on empty fact":                 {condition: "==laptop", wantErr: true},
+		"Error on empty comparison":           {condition: "chassis==laptop &&", wantErr: true},
+		"Error on invalid second alternative": {condition: "chassis==laptop || color==blue", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := laptop.Eval(tc.condition)
+			if tc.wantErr {
+				require.Error(t, err, "Eval should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "Eval should not have failed")
+			require.Equal(t, tc.want, got, "Eval should return the expected result")
+		})
+	}
+}
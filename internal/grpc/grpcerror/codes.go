@@ -0,0 +1,123 @@
+package grpcerror
+
+import (
+	"errors"
+	"slices"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Code is a stable error code attached by the daemon to its errors, so that clients can branch on them.
+type Code string
+
+const (
+	// CodeKrbNoTicket is attached when the Kerberos ticket of the object is missing or not accessible.
+	CodeKrbNoTicket Code = "ERR_KRB_NO_TICKET"
+	// CodeDCUnreachable is attached when no domain controller can be reached.
+	CodeDCUnreachable Code = "ERR_DC_UNREACHABLE"
+	// CodePolicyParse is attached when a policy file of a GPO can't be parsed.
+	CodePolicyParse Code = "ERR_POLICY_PARSE"
+	// CodeStaleCache is attached when the machine is offline and its cached policies are too old.
+	CodeStaleCache Code = "ERR_STALE_CACHE"
+)
+
+// errorDomain is the domain of the error details attached to the GRPC status.
+const errorDomain = "adsys"
+
+// exitCodes are the exit codes of the client for each error code.
+// Other errors exit with 1, and 2 is reserved for usage errors.
+var exitCodes = map[Code]int{
+	CodeKrbNoTicket:   10,
+	CodeDCUnreachable: 11,
+	CodePolicyParse:   12,
+	CodeStaleCache:    13,
+}
+
+// Mapping maps internal errors to the code attached to the errors wrapping them.
+type Mapping map[error]Code
+
+// StreamServerInterceptor attaches the code of mapping to the errors returned by the stream handlers.
+func StreamServerInterceptor(mapping Mapping) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return WithCode(handler(srv, ss), mapping)
+	}
+}
+
+// WithCode returns err as a GRPC status error, with the code of the internal error of mapping it wraps attached
+// as details. If it wraps multiple ones, the lowest code is used, so that the result is always the same.
+// Errors which are already GRPC status errors or don't wrap any internal error of mapping are returned as is.
+func WithCode(err error, mapping Mapping) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var matches []Code
+	for target, c := range mapping {
+		if errors.Is(err, target) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return err
+	}
+	slices.Sort(matches)
+
+	// Unknown is the code of the regular errors returned by handlers, which keeps their formatting in clients.
+	st, detailsErr := status.New(codes.Unknown, err.Error()).WithDetails(&errdetails.ErrorInfo{Reason: string(matches[0]), Domain: errorDomain})
+	if detailsErr != nil {
+		return err
+	}
+	return st.Err()
+}
+
+// CodeOf returns the code attached by the daemon to err, once formatted or not.
+func CodeOf(err error) (Code, bool) {
+	var ce *codedError
+	if errors.As(err, &ce) {
+		return ce.code, true
+	}
+
+	st, ok := status.FromError(err)
+	if !ok || err == nil {
+		return "", false
+	}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetDomain() == errorDomain {
+			return Code(info.GetReason()), true
+		}
+	}
+	return "", false
+}
+
+// ExitCode returns the exit code of the client for err: a distinct one for each known code, or 1 otherwise.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if c, ok := CodeOf(err); ok {
+		if exitCode, ok := exitCodes[c]; ok {
+			return exitCode
+		}
+	}
+	return 1
+}
+
+// codedError is a formatted error keeping the code attached by the daemon.
+type codedError struct {
+	err  error
+	code Code
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
@@ -11,6 +11,7 @@ import (
 
 // Format returns the string formatted of GRPC errors,
 // handling regular issues like timeout, unavailable.
+// The code attached by the daemon, if any, is kept and returned by CodeOf.
 // Non GRPC errors are returned as is.
 func Format(err error, daemonName string) error {
 	if err == nil {
@@ -36,5 +37,8 @@ func Format(err error, daemonName string) error {
 	default:
 		err = errors.New(gotext.Get("Error %s from server: %v", st.Code(), st.Message()))
 	}
+	if c, ok := CodeOf(st.Err()); ok {
+		return &codedError{err: err, code: c}
+	}
 	return err
 }
@@ -2,11 +2,13 @@ package grpcerror_test
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/ubuntu/adsys/internal/grpc/grpcerror"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -63,3 +65,121 @@ func TestFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestWithCode(t *testing.T) {
+	t.Parallel()
+
+	errNoTicket := errors.New("no ticket")
+	errNoServer := errors.New("no server")
+	mapping := grpcerror.Mapping{
+		errNoTicket: grpcerror.CodeKrbNoTicket,
+		errNoServer: grpcerror.CodeDCUnreachable,
+	}
+
+	tests := map[string]struct {
+		err error
+
+		wantCode      grpcerror.Code
+		wantUnchanged bool
+	}{
+		"Mapped error gets its code":                 {err: errNoTicket, wantCode: grpcerror.CodeKrbNoTicket},
+		"Wrapped mapped error gets its code":         {err: fmt.Errorf("can't get policies: %w", errNoServer), wantCode: grpcerror.CodeDCUnreachable},
+		"Multiple mapped errors get the lowest code": {err: errors.Join(errNoTicket, errNoServer), wantCode: grpcerror.CodeDCUnreachable},
+		"Unmapped error is returned as is":           {err: errors.New("foo"), wantUnchanged: true},
+		"GRPC status error is returned as is":        {err: status.Error(codes.Canceled, "foo"), wantUnchanged: true},
+		"Nil returns nil":                            {err: nil, wantUnchanged: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := grpcerror.WithCode(tc.err, mapping)
+			if tc.wantUnchanged {
+				require.Equal(t, tc.err, err, "WithCode should return the error as is")
+				_, ok := grpcerror.CodeOf(err)
+				require.False(t, ok, "CodeOf should not find any code")
+				return
+			}
+
+			st, ok := status.FromError(err)
+			require.True(t, ok, "WithCode should return a GRPC status error")
+			require.Equal(t, codes.Unknown, st.Code(), "GRPC status code should be Unknown")
+			require.Equal(t, tc.err.Error(), st.Message(), "GRPC status message should be the error message")
+
+			got, ok := grpcerror.CodeOf(err)
+			require.True(t, ok, "CodeOf should find the attached code")
+			require.Equal(t, tc.wantCode, got, "CodeOf should return the attached code")
+		})
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	t.Parallel()
+
+	errNoTicket := errors.New("no ticket")
+	interceptor := grpcerror.StreamServerInterceptor(grpcerror.Mapping{errNoTicket: grpcerror.CodeKrbNoTicket})
+
+	err := interceptor(nil, nil, nil, func(interface{}, grpc.ServerStream) error {
+		return fmt.Errorf("can't apply policy: %w", errNoTicket)
+	})
+
+	got, ok := grpcerror.CodeOf(err)
+	require.True(t, ok, "Handler error should have a code attached")
+	require.Equal(t, grpcerror.CodeKrbNoTicket, got, "Handler error should have the mapped code attached")
+
+	err = interceptor(nil, nil, nil, func(interface{}, grpc.ServerStream) error { return nil })
+	require.NoError(t, err, "Handler success should be returned as is")
+}
+
+func TestFormatKeepsCode(t *testing.T) {
+	t.Parallel()
+
+	errNoServer := errors.New("no server")
+	mapping := grpcerror.Mapping{errNoServer: grpcerror.CodeDCUnreachable}
+
+	err := grpcerror.Format(grpcerror.WithCode(fmt.Errorf("can't get policies: %w", errNoServer), mapping), "DaemonName")
+
+	_, grpcError := status.FromError(err)
+	require.False(t, grpcError, "Error is not a GRPC error")
+	require.Equal(t, "Error from server: can't get policies: no server", err.Error(), "Formatted error message should be the one of Unknown errors")
+
+	got, ok := grpcerror.CodeOf(err)
+	require.True(t, ok, "Formatted error should keep its code")
+	require.Equal(t, grpcerror.CodeDCUnreachable, got, "Formatted error should keep its code")
+	require.Equal(t, 11, grpcerror.ExitCode(err), "Exit code should be the one of the code")
+}
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		code grpcerror.Code
+		err  error
+
+		want int
+	}{
+		"Nil error exits with 0":           {err: nil, want: 0},
+		"Error without code exits with 1":  {err: errors.New("foo"), want: 1},
+		"Unknown code exits with 1":        {code: "ERR_UNKNOWN", want: 1},
+		"Canceled status exits with 1":     {err: status.Error(codes.Canceled, "foo"), want: 1},
+		"ERR_KRB_NO_TICKET exits with 10":  {code: grpcerror.CodeKrbNoTicket, want: 10},
+		"ERR_DC_UNREACHABLE exits with 11": {code: grpcerror.CodeDCUnreachable, want: 11},
+		"ERR_POLICY_PARSE exits with 12":   {code: grpcerror.CodePolicyParse, want: 12},
+		"ERR_STALE_CACHE exits with 13":    {code: grpcerror.CodeStaleCache, want: 13},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.err
+			if tc.code != "" {
+				mapped := errors.New("mapped")
+				err = grpcerror.WithCode(mapped, grpcerror.Mapping{mapped: tc.code})
+			}
+
+			require.Equal(t, tc.want, grpcerror.ExitCode(err), "ExitCode should return the expected exit code")
+		})
+	}
+}
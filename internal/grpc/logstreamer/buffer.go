@@ -0,0 +1,170 @@
+package log
+
+import (
+	"sync"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// logBuffer sends the messages of a stream from a dedicated goroutine, so that a slow client never blocks the
+// callers logging to it. When more than size logs are waiting, the oldest ones are dropped and replaced by a
+// single marker counting them. Other messages are never dropped and are sent in order with the logs.
+type logBuffer struct {
+	stream grpc.ServerStream
+	size   int
+
+	localLogger *logrus.Logger
+	idRequest   string
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    []queuedMsg
+	nLogs    int
+	dropped  int
+	closed   bool
+	stopped  bool
+	finished chan struct{}
+
+	// sendMu serializes the messages sent to the stream.
+	sendMu sync.Mutex
+}
+
+type queuedMsg struct {
+	msg interface{}
+	// sent receives the result of sending the message if it's not a log.
+	sent chan error
+}
+
+// newLogBuffer returns a buffer of size logs for stream and starts sending them.
+func newLogBuffer(stream grpc.ServerStream, size int, localLogger *logrus.Logger, idRequest string) *logBuffer {
+	b := &logBuffer{
+		stream:      stream,
+		size:        size,
+		localLogger: localLogger,
+		idRequest:   idRequest,
+		finished:    make(chan struct{}),
+	}
+	b.cond = sync.NewCond(&b.mu)
+
+	go b.run()
+
+	return b
+}
+
+// send queues m to be sent to the stream. Logs are queued without waiting, dropping the oldest queued log if the
+// buffer is full, while other messages wait until they are sent.
+func (b *logBuffer) send(m interface{}) error {
+	l, isLog := m.(*Log)
+	isLog = isLog && l.LogHeader == logIdentifier
+
+	b.mu.Lock()
+	if b.stopped {
+		b.mu.Unlock()
+		return b.sendToStream(m)
+	}
+
+	if !isLog {
+		sent := make(chan error, 1)
+		b.queue = append(b.queue, queuedMsg{msg: m, sent: sent})
+		b.cond.Signal()
+		b.mu.Unlock()
+		return <-sent
+	}
+
+	if b.nLogs >= b.size {
+		b.dropOldestLog()
+	}
+	b.queue = append(b.queue, queuedMsg{msg: m})
+	b.nLogs++
+	b.cond.Signal()
+	b.mu.Unlock()
+	return nil
+}
+
+// dropOldestLog removes the oldest queued log. It must be called with mu held.
+func (b *logBuffer) dropOldestLog() {
+	for i, q := range b.queue {
+		if q.sent != nil {
+			continue
+		}
+		b.queue = append(b.queue[:i], b.queue[i+1:]...)
+		b.nLogs--
+		b.dropped++
+		return
+	}
+}
+
+// run sends the queued messages until the buffer is closed and empty, or the stream is done.
+func (b *logBuffer) run() {
+	defer close(b.finished)
+
+	for {
+		b.mu.Lock()
+		for len(b.queue) == 0 && !b.closed {
+			b.cond.Wait()
+		}
+		if len(b.queue) == 0 || b.stream.Context().Err() != nil {
+			// Nothing can be sent anymore: fail the pending messages, and send the next ones directly.
+			for _, q := range b.queue {
+				if q.sent != nil {
+					q.sent <- b.stream.Context().Err()
+				}
+			}
+			b.queue = nil
+			b.nLogs = 0
+			b.stopped = true
+			b.mu.Unlock()
+			return
+		}
+
+		q := b.queue[0]
+		b.queue = b.queue[1:]
+		var dropped int
+		if q.sent == nil {
+			b.nLogs--
+			// The dropped logs were older than this one.
+			dropped, b.dropped = b.dropped, 0
+		}
+		b.mu.Unlock()
+
+		if dropped > 0 {
+			marker := &Log{
+				LogHeader: logIdentifier,
+				Level:     logrus.WarnLevel.String(),
+				Msg:       gotext.Get("%d messages dropped as the client can't keep up", dropped),
+			}
+			if err := b.sendToStream(marker); err != nil {
+				b.localLogger.Warningf(localLogFormatWithID, b.idRequest, gotext.Get("couldn't send logs to client"))
+			}
+		}
+
+		err := b.sendToStream(q.msg)
+		if q.sent != nil {
+			q.sent <- err
+			continue
+		}
+		if err != nil {
+			b.localLogger.Warningf(localLogFormatWithID, b.idRequest, gotext.Get("couldn't send logs to client"))
+		}
+	}
+}
+
+// close waits for the queued messages to be sent, or the stream to be done. The messages sent after it are sent
+// directly to the stream.
+func (b *logBuffer) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.cond.Signal()
+	b.mu.Unlock()
+
+	<-b.finished
+}
+
+func (b *logBuffer) sendToStream(m interface{}) error {
+	b.sendMu.Lock()
+	defer b.sendMu.Unlock()
+
+	return b.stream.SendMsg(m)
+}
@@ -21,8 +21,20 @@ import (
 const (
 	localLogFormatWithID = "[[%s]] %s"
 	logFormatWithCaller  = "%s %s"
+
+	// objectNameField is the local logs field with the name of the object a policy is applied to.
+	objectNameField = "object"
 )
 
+// objectNameKey is the context key of the name of the object a policy is applied to.
+type objectNameKey struct{}
+
+// WithObjectName returns a copy of ctx tagging any local log with the name of the object a policy is
+// applied to, to tell apart logs of concurrent applies.
+func WithObjectName(ctx context.Context, objectName string) context.Context {
+	return context.WithValue(ctx, objectNameKey{}, objectName)
+}
+
 // Debug logs at the DEBUG level.
 // If the context contains a stream, it will stream there and use associated local logger.
 // Arguments are handled in the manner of fmt.Print; a newline is appended to local log if missing.
@@ -173,12 +185,14 @@ func log(ctx context.Context, level logrus.Level, args ...interface{}) {
 		caller = fmt.Sprintf("%s:%d %s()", f.File, f.Line, funcName)
 	}
 
-	if err := logLocallyMaybeRemote(level, caller, msg, localLogger, idRequest, sendStream); err != nil {
+	objectName, _ := ctx.Value(objectNameKey{}).(string)
+
+	if err := logLocallyMaybeRemote(level, caller, msg, localLogger, idRequest, objectName, sendStream); err != nil {
 		localLogger.Warningf(localLogFormatWithID, idRequest, gotext.Get("couldn't send logs to client"))
 	}
 }
 
-func logLocallyMaybeRemote(level logrus.Level, caller, msg string, localLogger *logrus.Logger, idRequest string, sendStream sendStreamFn) (err error) {
+func logLocallyMaybeRemote(level logrus.Level, caller, msg string, localLogger *logrus.Logger, idRequest, objectName string, sendStream sendStreamFn) (err error) {
 	// decorate depends on logstreamer: we can’t use it here
 	defer func() {
 		if err != nil {
@@ -198,7 +212,11 @@ func logLocallyMaybeRemote(level logrus.Level, caller, msg string, localLogger *
 	if callerForLocal {
 		localMsg = fmt.Sprintf(logFormatWithCaller, caller, localMsg)
 	}
-	localLogger.Log(level, localMsg)
+	if objectName != "" {
+		localLogger.WithField(objectNameField, objectName).Log(level, localMsg)
+	} else {
+		localLogger.Log(level, localMsg)
+	}
 	// Reset value for next call
 	localLogger.SetReportCaller(callerForLocal)
 	localLoggerMu.Unlock()
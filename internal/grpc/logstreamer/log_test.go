@@ -156,6 +156,27 @@ func TestLogWithNoCaller(t *testing.T) {
 	assert.NotContains(t, remoteLogs(), "HASCALLER", "No caller info sent remotely")
 }
 
+func TestLogWithObjectName(t *testing.T) {
+	t.Parallel()
+
+	stream, localLogs, remoteLogs := createLogStream(t, logrus.DebugLevel, false, false, nil)
+
+	log.Warning(log.WithObjectName(stream.Context(), "user@example.com"), "something")
+	log.Info(stream.Context(), "else")
+
+	logs := localLogs()
+	requireLog(t, logs,
+		[]string{"level=warning msg=", "[[123456:", "something", "object=user@example.com"},
+		[]string{"level=info msg=", "else"},
+	)
+	assert.Equal(t, 1, strings.Count(logs, "object="), "Only the log with an object name has the object field")
+	requireLog(t, remoteLogs(),
+		[]string{"level=debug msg=", "Connecting as [[123456:"},
+		[]string{"level=warning msg=", "something"},
+		[]string{"level=info msg=", "else"},
+	)
+}
+
 func TestSetReportCaller(t *testing.T) {
 	tests := map[string]struct {
 		reportCaller bool
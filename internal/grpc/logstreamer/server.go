@@ -23,11 +23,34 @@ type logContext struct {
 	localLogger         *logrus.Logger
 }
 
+type options struct {
+	bufferSize int
+}
+
+// Option reprents an optional function to change the stream server interceptor.
+type Option func(*options)
+
+// WithBufferSize buffers up to size logs for each client, sent in the background so that a slow client never
+// blocks the server. When the client can't keep up, the oldest logs are dropped and the client is told how many.
+// 0 sends the logs directly.
+func WithBufferSize(size int) Option {
+	return func(o *options) {
+		o.bufferSize = size
+	}
+}
+
 // StreamServerInterceptor wraps the server stream to create a new dedicated logger to stream back the logs.
 // It will use serverLogger to log locally the same messages, prefixing by the request ID.
 // It will use ReportCaller value from localLogger to decide if we print the callstack (first frame outside
 // of that package).
-func StreamServerInterceptor(localLogger *logrus.Logger) func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+func StreamServerInterceptor(localLogger *logrus.Logger, opts ...Option) func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	// defaults
+	args := options{}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
 	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 		clientID, withCaller, err := extractMetaFromContext(ss.Context())
 		if err != nil {
@@ -40,6 +63,11 @@ func StreamServerInterceptor(localLogger *logrus.Logger) func(srv interface{}, s
 
 		// create and log request ID
 		idRequest := fmt.Sprintf("%s:%s", clientID, createID())
+		if args.bufferSize > 0 {
+			ssLogs.buffer = newLogBuffer(ss, args.bufferSize, localLogger, idRequest)
+			// Send the remaining logs before the end of the stream.
+			defer ssLogs.buffer.close()
+		}
 		if err := ssLogs.sendLogs(logrus.DebugLevel.String(), "", gotext.Get("Connecting as [[%s]]", idRequest)); err != nil {
 			localLogger.Warningf(localLogFormatWithID, idRequest, gotext.Get("Couldn't send initial connection log to client"))
 		}
@@ -60,12 +88,22 @@ func StreamServerInterceptor(localLogger *logrus.Logger) func(srv interface{}, s
 type serverStreamWithLogs struct {
 	grpc.ServerStream
 	ctx context.Context
+
+	buffer *logBuffer
 }
 
 func (ss serverStreamWithLogs) Context() context.Context {
 	return ss.ctx
 }
 
+// SendMsg sends m through the log buffer, if any.
+func (ss serverStreamWithLogs) SendMsg(m interface{}) error {
+	if ss.buffer == nil {
+		return ss.ServerStream.SendMsg(m)
+	}
+	return ss.buffer.send(m)
+}
+
 // sendLogs sends directly to the stream a Log message with dedicated entries.
 // This will be intercepted by the StreamClientInterceptor for every Log message matching
 // its structure, preventing to hit the client.
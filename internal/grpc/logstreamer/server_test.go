@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -91,6 +94,74 @@ func TestStreamServerInterceptorSendLogsFails(t *testing.T) {
 	assert.Equal(t, 0, len(stream.msgs), "Send to client did not succeed")
 }
 
+func TestStreamServerInterceptorWithSlowClient(t *testing.T) {
+	t.Parallel()
+
+	stream := &slowStream{
+		ctx:     addMetaToContext(context.Background(), false),
+		sending: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+
+	loggingDone := make(chan struct{})
+	handler := func(_ interface{}, s grpc.ServerStream) error {
+		// Wait for the connection log to be blocked in the stream.
+		<-stream.sending
+		for i := range 100 {
+			log.Infof(s.Context(), "message %d", i)
+		}
+		close(loggingDone)
+		return s.SendMsg("response")
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	errCh := make(chan error)
+	go func() {
+		errCh <- log.StreamServerInterceptor(logger, log.WithBufferSize(10))(struct{}{}, stream, nil, handler)
+	}()
+
+	select {
+	case <-loggingDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Logging should not be blocked by a slow client")
+	}
+	close(stream.release)
+	require.NoError(t, <-errCh, "StreamServerInterceptor returned an error when expecting none")
+
+	require.Len(t, stream.msgs, 13, "Only the connection log, the dropped marker, the buffered logs and the response should be sent")
+	msgContains(t, "Connecting as [[123456:", stream.msgs[0], "Send id string to client first")
+	msgContains(t, "90 messages dropped", stream.msgs[1], "Send the number of dropped logs before the buffered ones")
+	for i := range 10 {
+		msgContains(t, fmt.Sprintf("message %d", 90+i), stream.msgs[2+i], "Send the most recent logs in order")
+	}
+	assert.Equal(t, "response", stream.msgs[12], "Send the response after the logs")
+}
+
+// slowStream blocks every message until release is closed, signaling sending on the first one.
+type slowStream struct {
+	grpc.ServerStream
+	ctx context.Context
+
+	sending     chan struct{}
+	sendingOnce sync.Once
+	release     chan struct{}
+
+	msgs []interface{}
+}
+
+func (s *slowStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *slowStream) SendMsg(m interface{}) error {
+	s.sendingOnce.Do(func() { close(s.sending) })
+	<-s.release
+
+	s.msgs = append(s.msgs, m)
+	return nil
+}
+
 func TestStreamServerInterceptorLoggerInvalidMetadata(t *testing.T) {
 	t.Parallel()
 
@@ -14,6 +14,10 @@
 // attempt to apply them. This process is more clearly outlined in the
 // ApplyPolicy function documentation.
 //
+// On the machine, the policy can also set variables, like @{HOMEDIRS}, in the
+// adsys file of the apparmor tunables directory. Profiles including it are
+// recompiled, without relying on the apparmor cache, when those variables change.
+//
 // If any errors occur during the policy apply process, the manager will attempt
 // to restore the initial state of the system before returning an error.
 package apparmor
@@ -38,6 +42,7 @@ import (
 	"github.com/ubuntu/adsys/internal/consts"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/trace"
 	"github.com/ubuntu/adsys/internal/smbsafe"
 	"github.com/ubuntu/decorate"
 )
@@ -57,19 +62,28 @@ func WithApparmorFsDir(path string) Option {
 	}
 }
 
+// WithApparmorTunablesDir specifies a personalized apparmor tunables directory.
+func WithApparmorTunablesDir(path string) Option {
+	return func(o *options) {
+		o.apparmorTunablesDir = path
+	}
+}
+
 // Manager prevents running multiple apparmor update processes in parallel while parsing policy in ApplyPolicy.
 type Manager struct {
-	apparmorDir        string
-	apparmorCacheDir   string
-	apparmorParserCmd  []string
-	loadedPoliciesFile string
+	apparmorDir         string
+	apparmorTunablesDir string
+	apparmorCacheDir    string
+	apparmorParserCmd   []string
+	loadedPoliciesFile  string
 
 	mu sync.Mutex // Prevents multiple instances of apparmor from running concurrenctly
 }
 
 type options struct {
-	apparmorParserCmd []string
-	apparmorFsDir     string
+	apparmorParserCmd   []string
+	apparmorFsDir       string
+	apparmorTunablesDir string
 }
 
 // Option reprents an optional function to change the apparmor manager.
@@ -79,8 +93,9 @@ type Option func(*options)
 func New(apparmorDir string, opts ...Option) *Manager {
 	// defaults
 	args := options{
-		apparmorParserCmd: []string{"apparmor_parser"},
-		apparmorFsDir:     "/sys/kernel/security/apparmor",
+		apparmorParserCmd:   []string{"apparmor_parser"},
+		apparmorFsDir:       "/sys/kernel/security/apparmor",
+		apparmorTunablesDir: filepath.Join(filepath.Dir(apparmorDir), "tunables"),
 	}
 	// applied options
 	for _, o := range opts {
@@ -88,11 +103,12 @@ func New(apparmorDir string, opts ...Option) *Manager {
 	}
 
 	return &Manager{
-		mu:                 sync.Mutex{},
-		apparmorDir:        apparmorDir,
-		apparmorCacheDir:   filepath.Join(consts.DefaultCacheDir, "apparmor"),
-		apparmorParserCmd:  args.apparmorParserCmd,
-		loadedPoliciesFile: filepath.Join(args.apparmorFsDir, "profiles"),
+		mu:                  sync.Mutex{},
+		apparmorDir:         apparmorDir,
+		apparmorTunablesDir: args.apparmorTunablesDir,
+		apparmorCacheDir:    filepath.Join(consts.DefaultCacheDir, "apparmor"),
+		apparmorParserCmd:   args.apparmorParserCmd,
+		loadedPoliciesFile:  filepath.Join(args.apparmorFsDir, "profiles"),
 	}
 }
 
@@ -101,6 +117,7 @@ type AssetsDumper func(ctx context.Context, relSrc, dest string, uid int, gid in
 
 // ApplyPolicy generates an apparmor policy based on a list of entries.
 // Common scenario steps:
+// 0.  On the machine, write /etc/apparmor.d/tunables/adsys with the policy variables
 // 1.  Get the list of loaded apparmor policies
 // 2.  Create /etc/apparmor.d/adsys/<object>.new with new policy
 // 3a. Move /etc/apparmor.d/adsys/<object> to /etc/apparmor.d/adsys/<object>.old
@@ -136,6 +153,20 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 	}
 	m.apparmorParserCmd[0] = absPath
 
+	// Variables are only set on the machine, and must be in place before loading the profiles using them.
+	var tunablesChanged bool
+	if isComputer {
+		var restoreTunables func()
+		if restoreTunables, tunablesChanged, err = m.applyTunables(ctx, entries); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				restoreTunables()
+			}
+		}()
+	}
+
 	// If we have no entries, attempt to unload them and remove the apparmor directory
 	idx := slices.IndexFunc(entries, func(e entry.Entry) bool { return e.Key == fmt.Sprintf("apparmor-%s", objectDir) })
 	if idx == -1 || entries[idx].Disabled {
@@ -150,7 +181,7 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 
 	switch objectDir {
 	case "machine":
-		err = m.applyMachinePolicy(ctx, entries[idx], apparmorPath, assetsDumper)
+		err = m.applyMachinePolicy(ctx, entries[idx], apparmorPath, assetsDumper, tunablesChanged)
 	case "users":
 		err = m.applyUserPolicy(ctx, entries[idx], apparmorPath, objectName, assetsDumper)
 	}
@@ -158,8 +189,9 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 	return err
 }
 
-// applyUserPolicy applies apparmor policies for the machine object.
-func (m *Manager) applyMachinePolicy(ctx context.Context, e entry.Entry, apparmorPath string, assetsDumper AssetsDumper) (err error) {
+// applyMachinePolicy applies apparmor policies for the machine object.
+// If tunablesChanged is true, the profiles are recompiled instead of being loaded from the cache.
+func (m *Manager) applyMachinePolicy(ctx context.Context, e entry.Entry, apparmorPath string, assetsDumper AssetsDumper, tunablesChanged bool) (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't apply machine policy"))
 
 	existingProfiles, err := filesInDir(apparmorPath)
@@ -235,10 +267,15 @@ func (m *Manager) applyMachinePolicy(ctx context.Context, e entry.Entry, apparmo
 	if len(filesToLoad) > 0 && os.Getenv("ADSYS_SKIP_ROOT_CALLS") == "" {
 		// Run apparmor_parser on the files to load, relying on apparmor's caching mechanism
 		apparmorParserCmd := append(m.apparmorParserCmd, []string{"-r", "-W", "-L", m.apparmorCacheDir}...)
+		if tunablesChanged {
+			log.Debug(ctx, gotext.Get("Apparmor tunables changed, recompiling profiles"))
+			apparmorParserCmd = append(apparmorParserCmd, "--skip-read-cache")
+		}
 		apparmorParserCmd = append(apparmorParserCmd, filesToLoad...)
 
 		// #nosec G204 - We are in control of the arguments
 		cmd := exec.CommandContext(ctx, apparmorParserCmd[0], apparmorParserCmd[1:]...)
+		trace.Command(ctx, "apparmor", cmd.Args)
 		cmd.Dir = m.apparmorDir
 		smbsafe.WaitExec()
 		out, err := cmd.CombinedOutput()
@@ -312,6 +349,7 @@ func (m *Manager) applyUserPolicy(ctx context.Context, e entry.Entry, apparmorPa
 
 	// #nosec G204 - We are in control of the arguments
 	cmd := exec.CommandContext(ctx, apparmorParserCmd[0], apparmorParserCmd[1:]...)
+	trace.Command(ctx, "apparmor", cmd.Args)
 	cmd.Dir = m.apparmorDir
 	smbsafe.WaitExec()
 	out, err := cmd.CombinedOutput()
@@ -410,6 +448,7 @@ func (m *Manager) policiesFromFiles(ctx context.Context, profiles []string) (pol
 	var outb, errb bytes.Buffer
 	// #nosec G204 - We are in control of the arguments
 	cmd := exec.CommandContext(ctx, apparmorParserCmd[0], apparmorParserCmd[1:]...)
+	trace.Command(ctx, "apparmor", cmd.Args)
 	cmd.Dir = m.apparmorDir
 	cmd.Stdout = &outb
 	cmd.Stderr = &errb
@@ -447,6 +486,7 @@ func (m *Manager) unloadPolicies(ctx context.Context, policies []string) error {
 	apparmorParserCmd := append(m.apparmorParserCmd, "-R")
 	// #nosec G204 - We are in control of the arguments
 	cmd := exec.CommandContext(ctx, apparmorParserCmd[0], apparmorParserCmd[1:]...)
+	trace.Command(ctx, "apparmor", cmd.Args)
 	cmd.Dir = m.apparmorDir
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -24,6 +24,9 @@ func TestApplyPolicy(t *testing.T) {
 	t.Parallel()
 
 	defaultMachineProfile := []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.foo"}}
+	tunables := entry.Entry{Key: "apparmor-tunables", Value: `# Additional home directories
+@{HOMEDIRS}+=/srv/home/ "/mnt/home dirs/"
+@{DATA_DIRS} = {/data,/srv/data}/ @{HOME}/data/`}
 
 	tests := map[string]struct {
 		entries []entry.Entry
@@ -31,6 +34,7 @@ func TestApplyPolicy(t *testing.T) {
 
 		noParserOutput         bool
 		destsAlreadyExist      map[string]string // key refers to the source path, value to the destination path
+		tunablesAlreadyExist   bool
 		readOnlyApparmorDir    string
 		noApparmorParser       bool
 		existingLoadedPolicies []string
@@ -54,6 +58,15 @@ func TestApplyPolicy(t *testing.T) {
 		"Existing .new directory is removed":       {destsAlreadyExist: map[string]string{"only-machine": "machine.new"}},
 		"Existing .old directory is removed":       {destsAlreadyExist: map[string]string{"only-machine": "machine.old"}},
 
+		// tunables cases
+		"Computer, tunables are written and profiles recompiled":          {entries: append([]entry.Entry{tunables}, defaultMachineProfile...)},
+		"Computer, unchanged tunables don't recompile profiles":           {entries: append([]entry.Entry{tunables}, defaultMachineProfile...), tunablesAlreadyExist: true},
+		"Computer, tunables without profiles":                             {entries: []entry.Entry{tunables}, noParserOutput: true},
+		"Computer, tunables with only comments are removed":               {entries: append([]entry.Entry{{Key: "apparmor-tunables", Value: "# no variable\n\n"}}, defaultMachineProfile...), tunablesAlreadyExist: true},
+		"Computer, disabled tunables are removed and profiles recompiled": {entries: append([]entry.Entry{{Key: "apparmor-tunables", Disabled: true}}, defaultMachineProfile...), tunablesAlreadyExist: true},
+		"Computer, no tunables entry removes existing tunables":           {tunablesAlreadyExist: true},
+		"User, tunables are ignored":                                      {entries: []entry.Entry{tunables}, noParserOutput: true, user: true},
+
 		// shared cases
 		"No profiles, existing rules are removed": {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/foo", "/usr/bin/bar", "/usr/bin/baz"}},
 		"No profiles, apparmor directory absent":  {entries: []entry.Entry{}, noParserOutput: true},
@@ -78,22 +91,29 @@ func TestApplyPolicy(t *testing.T) {
 		"Read-only root directory and no entries": {entries: []entry.Entry{}, readOnlyApparmorDir: ".", noParserOutput: true},
 
 		// error cases
-		"Error on loading profiles failing":                {apparmorParserError: "-r", wantErr: true},
-		"Error on preprocessing new profiles failing":      {apparmorParserError: "-N", wantErr: true},
-		"Error on preprocessing old profiles failing":      {destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/foo"}, apparmorParserError: "-N", wantErr: true},
-		"Error on unloading all profiles failing":          {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/foo", "/usr/bin/bar", "/usr/bin/baz"}, apparmorParserError: "-R", wantErr: true},
-		"Error on unloading old profiles failing":          {destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/bar", "/usr/bin/baz"}, apparmorParserError: "-R", wantErr: true},
-		"Error on save assets dumping failing":             {noParserOutput: true, saveAssetsError: true, wantErr: true},
-		"Error on removing unused assets after dump":       {noParserOutput: true, removeUnusedAssetsError: true, wantErr: true},
-		"Error on profile being a directory":               {entries: []entry.Entry{{Key: "apparmor-machine", Value: "nested/"}}, noParserOutput: true, wantErr: true},
-		"Error on absent profile":                          {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.nonexistent"}}, noParserOutput: true, wantErr: true},
-		"Error on absent loaded policies file":             {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"parseError"}, noParserOutput: true, wantErr: true},
-		"Error on file as a directory":                     {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.foo/notadir"}}, noParserOutput: true, wantErr: true},
-		"Error on read-only root directory with entries":   {readOnlyApparmorDir: ".", noParserOutput: true, wantErr: true},
-		"Error on read-only machine directory":             {destsAlreadyExist: map[string]string{"only-machine": "machine"}, readOnlyApparmorDir: "machine", wantErr: true},
-		"Error on read-only machine directory, no entries": {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, readOnlyApparmorDir: "machine/nested", wantErr: true},
-		"Error on read-only .old directory":                {destsAlreadyExist: map[string]string{"only-machine": "machine.old"}, readOnlyApparmorDir: "machine.old", noParserOutput: true, wantErr: true},
-		"Error on read-only .new directory":                {destsAlreadyExist: map[string]string{"only-machine": "machine.new"}, readOnlyApparmorDir: "machine.new", noParserOutput: true, wantErr: true},
+		"Error on invalid tunable variable name":               {entries: append([]entry.Entry{{Key: "apparmor-tunables", Value: "@{1VAR}=/srv/"}}, defaultMachineProfile...), noParserOutput: true, wantErr: true},
+		"Error on tunable not being an assignment":             {entries: append([]entry.Entry{{Key: "apparmor-tunables", Value: "include <tunables/global>"}}, defaultMachineProfile...), noParserOutput: true, wantErr: true},
+		"Error on tunable without value":                       {entries: append([]entry.Entry{{Key: "apparmor-tunables", Value: "@{VAR}="}}, defaultMachineProfile...), noParserOutput: true, wantErr: true},
+		"Error on tunable value with unbalanced braces":        {entries: append([]entry.Entry{{Key: "apparmor-tunables", Value: "@{VAR}={/srv,/data/"}}, defaultMachineProfile...), noParserOutput: true, wantErr: true},
+		"Error on tunable value ending the rule":               {entries: append([]entry.Entry{{Key: "apparmor-tunables", Value: "@{VAR}=/srv/, /etc/shadow r"}}, defaultMachineProfile...), noParserOutput: true, wantErr: true},
+		"Error on tunable value with unterminated quote":       {entries: append([]entry.Entry{{Key: "apparmor-tunables", Value: `@{VAR}="/srv/my dir/`}}, defaultMachineProfile...), noParserOutput: true, wantErr: true},
+		"Error on loading profiles restores previous tunables": {entries: append([]entry.Entry{{Key: "apparmor-tunables", Value: "@{VAR}=/srv/"}}, defaultMachineProfile...), tunablesAlreadyExist: true, apparmorParserError: "-r", wantErr: true},
+		"Error on loading profiles failing":                    {apparmorParserError: "-r", wantErr: true},
+		"Error on preprocessing new profiles failing":          {apparmorParserError: "-N", wantErr: true},
+		"Error on preprocessing old profiles failing":          {destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/foo"}, apparmorParserError: "-N", wantErr: true},
+		"Error on unloading all profiles failing":              {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/foo", "/usr/bin/bar", "/usr/bin/baz"}, apparmorParserError: "-R", wantErr: true},
+		"Error on unloading old profiles failing":              {destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/bar", "/usr/bin/baz"}, apparmorParserError: "-R", wantErr: true},
+		"Error on save assets dumping failing":                 {noParserOutput: true, saveAssetsError: true, wantErr: true},
+		"Error on removing unused assets after dump":           {noParserOutput: true, removeUnusedAssetsError: true, wantErr: true},
+		"Error on profile being a directory":                   {entries: []entry.Entry{{Key: "apparmor-machine", Value: "nested/"}}, noParserOutput: true, wantErr: true},
+		"Error on absent profile":                              {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.nonexistent"}}, noParserOutput: true, wantErr: true},
+		"Error on absent loaded policies file":                 {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"parseError"}, noParserOutput: true, wantErr: true},
+		"Error on file as a directory":                         {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.foo/notadir"}}, noParserOutput: true, wantErr: true},
+		"Error on read-only root directory with entries":       {readOnlyApparmorDir: ".", noParserOutput: true, wantErr: true},
+		"Error on read-only machine directory":                 {destsAlreadyExist: map[string]string{"only-machine": "machine"}, readOnlyApparmorDir: "machine", wantErr: true},
+		"Error on read-only machine directory, no entries":     {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, readOnlyApparmorDir: "machine/nested", wantErr: true},
+		"Error on read-only .old directory":                    {destsAlreadyExist: map[string]string{"only-machine": "machine.old"}, readOnlyApparmorDir: "machine.old", noParserOutput: true, wantErr: true},
+		"Error on read-only .new directory":                    {destsAlreadyExist: map[string]string{"only-machine": "machine.new"}, readOnlyApparmorDir: "machine.new", noParserOutput: true, wantErr: true},
 	}
 
 	for name, tc := range tests {
@@ -105,6 +125,10 @@ func TestApplyPolicy(t *testing.T) {
 			}
 
 			apparmorDir := t.TempDir()
+			tunablesDir := filepath.Join(t.TempDir(), "tunables")
+			if tc.tunablesAlreadyExist {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "tunables_dir"), tunablesDir)
+			}
 			parserCmdOutputFile := filepath.Join(t.TempDir(), "parser-output")
 			loadedPoliciesFile := mockLoadedPoliciesFile(t, tc.existingLoadedPolicies)
 			if slices.Contains(tc.existingLoadedPolicies, "parseError") {
@@ -136,7 +160,8 @@ func TestApplyPolicy(t *testing.T) {
 
 			m := apparmor.New(apparmorDir,
 				apparmor.WithApparmorParserCmd(apparmorParserCmd),
-				apparmor.WithApparmorFsDir(filepath.Dir(loadedPoliciesFile)))
+				apparmor.WithApparmorFsDir(filepath.Dir(loadedPoliciesFile)),
+				apparmor.WithApparmorTunablesDir(tunablesDir))
 
 			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries, mockAssetsDumper.SaveAssetsTo)
 			if tc.wantErr {
@@ -170,6 +195,7 @@ func TestApplyPolicy(t *testing.T) {
 				require.NoError(t, err, "Setup: can't restore permissions of dumped files")
 			}
 			testutils.CompareTreesWithFiltering(t, apparmorDir, filepath.Join(testutils.GoldenPath(t), "etc", "apparmor.d", "adsys"), testutils.UpdateEnabled())
+			testutils.CompareTreesWithFiltering(t, tunablesDir, filepath.Join(testutils.GoldenPath(t), "etc", "apparmor.d", "tunables"), testutils.UpdateEnabled())
 
 			// Check that apparmor_parser was called with the expected arguments
 			got, err := os.ReadFile(parserCmdOutputFile)
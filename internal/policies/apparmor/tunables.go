@@ -0,0 +1,177 @@
+package apparmor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/decorate"
+)
+
+// tunablesFileName is the name of the file, in the apparmor tunables directory, with the variables set from
+// the policy. Profiles use them with include <tunables/adsys>.
+const tunablesFileName = "adsys"
+
+// tunableAssignment matches a variable assignment, like @{VAR}=value or @{VAR}+=value, with its values.
+var tunableAssignment = regexp.MustCompile(`^@\{([A-Za-z_][A-Za-z0-9_]*)\}\s*(\+?=)\s*(.*)$`)
+
+// applyTunables writes the variables of the apparmor-tunables entry to the tunables file, or removes it if
+// there is none.
+// It returns a function restoring the previous tunables, and if the file content changed.
+func (m *Manager) applyTunables(ctx context.Context, entries []entry.Entry) (restore func(), changed bool, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply apparmor tunables"))
+
+	tunablesPath := filepath.Join(m.apparmorTunablesDir, tunablesFileName)
+	noop := func() {}
+
+	var content string
+	for _, e := range entries {
+		if e.Key != "apparmor-tunables" || e.Disabled {
+			continue
+		}
+		if content, err = parseTunables(e.Value); err != nil {
+			return noop, false, err
+		}
+	}
+
+	oldContent, err := os.ReadFile(tunablesPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return noop, false, err
+	}
+	hadTunables := err == nil
+
+	restore = func() {
+		var err error
+		if hadTunables {
+			err = os.WriteFile(tunablesPath, oldContent, 0600)
+		} else {
+			err = os.Remove(tunablesPath)
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Warning(ctx, gotext.Get("Failed to restore previous apparmor tunables: %v", err))
+		}
+	}
+
+	if content == "" {
+		if !hadTunables {
+			return noop, false, nil
+		}
+		log.Debug(ctx, gotext.Get("Removing apparmor tunables %s", tunablesPath))
+		if err := os.Remove(tunablesPath); err != nil {
+			return noop, false, err
+		}
+		return restore, true, nil
+	}
+
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(m.apparmorTunablesDir, 0755); err != nil {
+		return noop, false, err
+	}
+	if _, changed, err = writeIfChanged(tunablesPath, content); err != nil {
+		return noop, false, err
+	}
+	if !changed {
+		return noop, false, nil
+	}
+	log.Debug(ctx, gotext.Get("Updated apparmor tunables %s", tunablesPath))
+	return restore, true, nil
+}
+
+// parseTunables validates the variable assignments, one per line, of the tunables entry value and returns
+// the content of the tunables file. Empty lines and comments are ignored.
+func parseTunables(value string) (content string, err error) {
+	var assignments []string
+	for _, l := range strings.Split(value, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		m := tunableAssignment.FindStringSubmatch(l)
+		if m == nil {
+			return "", errors.New(gotext.Get("invalid tunable %q: expecting @{VARIABLE}=value", l))
+		}
+		values, err := tunableValues(m[3])
+		if err != nil {
+			return "", errors.New(gotext.Get("invalid tunable %q: %v", l, err))
+		}
+		assignments = append(assignments, fmt.Sprintf("@{%s}%s%s", m[1], m[2], strings.Join(values, " ")))
+	}
+
+	if len(assignments) == 0 {
+		return "", nil
+	}
+
+	return fmt.Sprintf(`# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+
+%s
+`, strings.Join(assignments, "\n")), nil
+}
+
+// tunableValues splits the space separated values of a variable assignment.
+// A value can be double-quoted to contain spaces. Otherwise, braces for alternations must be balanced, and
+// the value can't contain any character ending an apparmor rule or starting a comment.
+func tunableValues(v string) (values []string, err error) {
+	for v = strings.TrimSpace(v); v != ""; v = strings.TrimSpace(v) {
+		var value string
+		if strings.HasPrefix(v, `"`) {
+			end := strings.Index(v[1:], `"`)
+			if end == -1 {
+				return nil, errors.New(gotext.Get("unterminated quoted value"))
+			}
+			value, v = v[:end+2], v[end+2:]
+			if v != "" && v[0] != ' ' && v[0] != '\t' {
+				return nil, errors.New(gotext.Get("missing space after quoted value %s", value))
+			}
+		} else {
+			value, v, _ = strings.Cut(strings.ReplaceAll(v, "\t", " "), " ")
+			if err := checkTunableValue(value); err != nil {
+				return nil, err
+			}
+		}
+		values = append(values, value)
+	}
+
+	if len(values) == 0 {
+		return nil, errors.New(gotext.Get("no value"))
+	}
+	return values, nil
+}
+
+// checkTunableValue returns an error if the unquoted value has unbalanced braces, a comma outside of an
+// alternation, or a quote, comment or null character.
+func checkTunableValue(value string) error {
+	if strings.ContainsAny(value, "\"#\x00") {
+		return errors.New(gotext.Get("invalid character in value %q", value))
+	}
+	depth := 0
+	for _, c := range value {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth <= 0 {
+				return errors.New(gotext.Get("invalid character in value %q", value))
+			}
+		}
+		if depth < 0 {
+			break
+		}
+	}
+	if depth != 0 {
+		return errors.New(gotext.Get("unbalanced braces in value %q", value))
+	}
+	return nil
+}
@@ -0,0 +1,404 @@
+// Package appearance provides a manager to deploy fonts and themes from the SYSVOL.
+//
+// The policy is only supported on computers. It has two entries:
+//   - fonts: the font files of the SYSVOL appearance/fonts/ directory, one per line, installed in a directory
+//     managed by adsys in the local fonts directory.
+//   - themes: the theme directories of the SYSVOL appearance/themes/ directory, one per line, installed in the
+//     local themes directory.
+//
+// Only TrueType, OpenType and WOFF fonts are accepted, with a matching file signature, and themes need an
+// index.theme file. Everything is validated before installing anything.
+//
+// On each refresh, the installed fonts and themes are reconciled with the policy: new and changed ones are
+// installed, and the ones not in the policy anymore are removed. The font cache is refreshed with fc-cache only
+// when the fonts changed. The themes installed by adsys are marked with a file, so that the other themes of the
+// local themes directory are never replaced nor removed.
+package appearance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// assetsDir is the directory of the SYSVOL assets containing the fonts and themes.
+	assetsDir = "appearance/"
+	// fontsDirName is the name of the directory, in the local fonts directory, managed by adsys.
+	fontsDirName = "adsys"
+	// themeMarker is the file marking the themes installed by adsys.
+	themeMarker = ".adsys-managed"
+)
+
+// fontSignatures are the first bytes of the accepted font files, by extension.
+var fontSignatures = map[string][]string{
+	".ttf":   {"\x00\x01\x00\x00", "true"},
+	".otf":   {"OTTO", "\x00\x01\x00\x00"},
+	".ttc":   {"ttcf"},
+	".woff":  {"wOFF"},
+	".woff2": {"wOF2"},
+}
+
+// Manager installs the fonts and themes of the appearance policy.
+type Manager struct {
+	fontsDir     string
+	themesDir    string
+	fontCacheCmd []string
+}
+
+type options struct {
+	fontCacheCmd []string
+}
+
+// Option reprents an optional function to change the appearance manager.
+type Option func(*options)
+
+// WithFontCacheCmd overrides the default command refreshing the font cache.
+func WithFontCacheCmd(cmd []string) Option {
+	return func(o *options) {
+		o.fontCacheCmd = cmd
+	}
+}
+
+// New creates a manager with specific local fonts and themes directories.
+func New(fontsDir, themesDir string, opts ...Option) *Manager {
+	// defaults
+	args := options{
+		fontCacheCmd: []string{"fc-cache", "--force"},
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Manager{
+		fontsDir:     fontsDir,
+		themesDir:    themesDir,
+		fontCacheCmd: args.fontCacheCmd,
+	}
+}
+
+// AssetsDumper is a function which uncompress policies assets to a directory.
+type AssetsDumper func(ctx context.Context, relSrc, dest string, uid int, gid int) (err error)
+
+// ApplyPolicy installs the fonts and themes of the policy and removes the ones not in it anymore.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry, assetsDumper AssetsDumper) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply appearance policy to %s", objectName))
+
+	// Appearance policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	var fonts, themes []string
+	for _, e := range entries {
+		if e.Key != "fonts" && e.Key != "themes" {
+			ignored.RecordUnsupportedKey(ctx, "appearance", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled {
+			continue
+		}
+		names, err := parseNames(e.Key, e.Value)
+		if err != nil {
+			return err
+		}
+		if e.Key == "fonts" {
+			fonts = names
+		} else {
+			themes = names
+		}
+	}
+
+	log.Debugf(ctx, "Applying appearance policy to %s", objectName)
+
+	newFonts := make(map[string][]byte)
+	var themesSrc string
+	if len(fonts) > 0 || len(themes) > 0 {
+		tmpdir, err := os.MkdirTemp("", "adsys-appearance-*")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpdir)
+		assets := filepath.Join(tmpdir, "appearance")
+		if err := assetsDumper(ctx, assetsDir, assets, -1, -1); err != nil {
+			return err
+		}
+
+		// Everything is validated before installing anything.
+		for _, name := range fonts {
+			if newFonts[name], err = readFont(filepath.Join(assets, "fonts", name)); err != nil {
+				return err
+			}
+		}
+		themesSrc = filepath.Join(assets, "themes")
+		if err := m.checkThemes(themesSrc, themes); err != nil {
+			return err
+		}
+	}
+
+	fontsChanged, err := m.syncFonts(ctx, newFonts)
+	if err != nil {
+		return err
+	}
+	if err := m.syncThemes(ctx, themesSrc, themes); err != nil {
+		return err
+	}
+
+	if !fontsChanged {
+		return nil
+	}
+	return m.refreshFontCache(ctx)
+}
+
+// parseNames returns the file or directory names, one per line, of the value of the fonts or themes entry.
+// Empty lines are ignored.
+func parseNames(key, value string) (names []string, err error) {
+	for _, name := range strings.Split(value, "\n") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		// Fonts and themes are installed under their name, so they can't be in subdirectories.
+		if !filepath.IsLocal(name) || strings.Contains(name, "/") || name == themeMarker {
+			return nil, errors.New(gotext.Get("invalid name %q in %s: only names of the SYSVOL appearance/%s/ directory are allowed", name, key, key))
+		}
+		if slices.Contains(names, name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// readFont returns the content of the font file at path, after checking its extension and signature.
+func readFont(path string) ([]byte, error) {
+	name := filepath.Base(path)
+	signatures, ok := fontSignatures[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		return nil, errors.New(gotext.Get("font %q is not a TrueType, OpenType or WOFF font file", name))
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.New(gotext.Get("font %q doesn't exist in SYSVOL appearance/fonts/ directory", name))
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !slices.ContainsFunc(signatures, func(s string) bool { return bytes.HasPrefix(content, []byte(s)) }) {
+		return nil, errors.New(gotext.Get("font %q has not the signature of a %s font file", name, filepath.Ext(name)))
+	}
+	return content, nil
+}
+
+// checkThemes checks that the themes exist in src with an index.theme file, and don't replace a theme not
+// installed by adsys.
+func (m *Manager) checkThemes(src string, themes []string) error {
+	for _, name := range themes {
+		info, err := os.Stat(filepath.Join(src, name, "index.theme"))
+		if errors.Is(err, fs.ErrNotExist) {
+			return errors.New(gotext.Get("theme %q doesn't exist in SYSVOL appearance/themes/ directory or has no index.theme file", name))
+		} else if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return errors.New(gotext.Get("index.theme of theme %q is not a file", name))
+		}
+
+		dest := filepath.Join(m.themesDir, name)
+		if _, err := os.Stat(dest); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dest, themeMarker)); err != nil {
+			return errors.New(gotext.Get("theme %q is already installed in %s and not managed by adsys", name, m.themesDir))
+		}
+	}
+	return nil
+}
+
+// syncFonts replaces the fonts of the managed fonts directory with newFonts, mapped by their name.
+// It returns true if any font was added, changed or removed.
+func (m *Manager) syncFonts(ctx context.Context, newFonts map[string][]byte) (changed bool, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't install fonts"))
+
+	dir := filepath.Join(m.fontsDir, fontsDirName)
+
+	if len(newFonts) == 0 {
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		log.Debug(ctx, gotext.Get("Removing fonts directory %s", dir))
+		return true, os.RemoveAll(dir)
+	}
+
+	// nolint:gosec // G301 fonts are readable by everyone
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, err
+	}
+
+	current, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	for _, f := range current {
+		if _, ok := newFonts[f.Name()]; ok {
+			continue
+		}
+		log.Debug(ctx, gotext.Get("Removing font %s", f.Name()))
+		if err := os.RemoveAll(filepath.Join(dir, f.Name())); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	for name, content := range newFonts {
+		written, err := writeIfChanged(filepath.Join(dir, name), content)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || written
+	}
+
+	return changed, nil
+}
+
+// syncThemes installs the themes from src, replacing the ones which changed, and removes the themes installed by
+// adsys which are not in the policy anymore.
+func (m *Manager) syncThemes(ctx context.Context, src string, themes []string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't install themes"))
+
+	current, err := os.ReadDir(m.themesDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, d := range current {
+		if slices.Contains(themes, d.Name()) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(m.themesDir, d.Name(), themeMarker)); err != nil {
+			continue
+		}
+		log.Debug(ctx, gotext.Get("Removing theme %s", d.Name()))
+		if err := os.RemoveAll(filepath.Join(m.themesDir, d.Name())); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range themes {
+		dest := filepath.Join(m.themesDir, name)
+		newContent, err := readTree(filepath.Join(src, name))
+		if err != nil {
+			return err
+		}
+		newContent[themeMarker] = ""
+		if oldContent, err := readTree(dest); err == nil && maps.Equal(oldContent, newContent) {
+			continue
+		}
+
+		log.Debug(ctx, gotext.Get("Installing theme %s", name))
+		if err := os.RemoveAll(dest + ".new"); err != nil {
+			return err
+		}
+		if err := writeTree(dest+".new", newContent); err != nil {
+			_ = os.RemoveAll(dest + ".new")
+			return err
+		}
+		if err := os.RemoveAll(dest); err != nil {
+			return err
+		}
+		if err := os.Rename(dest+".new", dest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// refreshFontCache rebuilds the font cache so that applications see the installed fonts.
+func (m *Manager) refreshFontCache(ctx context.Context) error {
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, m.fontCacheCmd[0], m.fontCacheCmd[1:]...)
+	trace.Command(ctx, "appearance", cmd.Args)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return errors.New(gotext.Get("failed to refresh font cache: %v\n%s", err, string(out)))
+	}
+	return nil
+}
+
+// readTree returns the content of the regular files of dir, mapped by their path relative to dir.
+func readTree(dir string) (map[string]string, error) {
+	content := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		content[rel] = string(data)
+		return nil
+	})
+	return content, err
+}
+
+// writeTree writes the files of content, mapped by their path relative to dir, readable by everyone.
+func writeTree(dir string, content map[string]string) error {
+	for rel, data := range content {
+		p := filepath.Join(dir, rel)
+		// nolint:gosec // G301 themes are readable by everyone
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		// nolint:gosec // G306 themes are readable by everyone
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			return errors.New(gotext.Get("can't write %s: %v", rel, err))
+		}
+	}
+	return nil
+}
+
+// writeIfChanged will only write to path if content is different from current content.
+func writeIfChanged(path string, content []byte) (done bool, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't save %s", path))
+
+	if oldContent, err := os.ReadFile(path); err == nil && bytes.Equal(oldContent, content) {
+		return false, nil
+	}
+
+	// nolint:gosec // G306 fonts are readable by everyone
+	if err := os.WriteFile(path+".new", content, 0644); err != nil {
+		return false, err
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
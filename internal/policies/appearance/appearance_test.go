@@ -0,0 +1,164 @@
+package appearance_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/appearance"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{
+		{Key: "fonts", Value: "Corporate-Regular.ttf\nCorporate-Bold.otf"},
+		{Key: "themes", Value: "Corporate\nCorporate-Dark"},
+	}
+
+	tests := map[string]struct {
+		entries    []entry.Entry
+		secondCall []entry.Entry
+		user       bool
+
+		existingFiles   bool
+		assetsDumperErr bool
+		fontCacheErr    bool
+
+		wantCacheRefreshes int
+		wantErr            bool
+		wantErrSecondCall  bool
+	}{
+		"Computer, fonts and themes are installed":                  {wantCacheRefreshes: 1},
+		"Computer, only themes don't refresh the font cache":        {entries: []entry.Entry{{Key: "themes", Value: "Corporate"}}},
+		"Computer, duplicated and empty lines are ignored":          {entries: []entry.Entry{{Key: "fonts", Value: "\n Corporate-Icons.woff2 \n\nCorporate-Icons.woff2\n"}}, wantCacheRefreshes: 1},
+		"Computer, existing fonts and themes are reconciled":        {existingFiles: true, wantCacheRefreshes: 1},
+		"Computer, no entries removes managed fonts and themes":     {entries: []entry.Entry{}, existingFiles: true, wantCacheRefreshes: 1},
+		"Computer, disabled entries remove managed fonts and theme": {entries: []entry.Entry{{Key: "fonts", Disabled: true}, {Key: "themes", Disabled: true}}, existingFiles: true, wantCacheRefreshes: 1},
+		"Computer, no entries and no managed fonts":                 {entries: []entry.Entry{}},
+		"Computer, unexpected entry key is ignored":                 {entries: []entry.Entry{{Key: "icons", Value: "Corporate"}}},
+		"User, policy is ignored":                                   {user: true},
+		"User, existing fonts and themes are kept":                  {entries: []entry.Entry{}, existingFiles: true, user: true},
+
+		// Reconciliation
+		"Computer, unchanged fonts don't refresh the font cache": {secondCall: defaultEntries, wantCacheRefreshes: 1},
+		"Computer, added fonts refresh the font cache":           {secondCall: []entry.Entry{{Key: "fonts", Value: "Corporate-Regular.ttf\nCorporate-Bold.otf\nCorporate-Icons.woff2"}}, wantCacheRefreshes: 2},
+		"Computer, removed fonts refresh the font cache":         {secondCall: []entry.Entry{{Key: "fonts", Value: "Corporate-Bold.otf"}, {Key: "themes", Value: "Corporate"}}, wantCacheRefreshes: 2},
+
+		// Error cases
+		"Error on font with unsupported extension":   {entries: []entry.Entry{{Key: "fonts", Value: "readme.txt"}}, wantErr: true},
+		"Error on font without font signature":       {entries: []entry.Entry{{Key: "fonts", Value: "NotAFont.ttf"}}, wantErr: true},
+		"Error on absent font":                       {entries: []entry.Entry{{Key: "fonts", Value: "Absent.ttf"}}, wantErr: true},
+		"Error on font in subdirectory":              {entries: []entry.Entry{{Key: "fonts", Value: "sub/Corporate-Regular.ttf"}}, wantErr: true},
+		"Error on font outside of fonts directory":   {entries: []entry.Entry{{Key: "fonts", Value: "../fonts/Corporate-Regular.ttf"}}, wantErr: true},
+		"Error on theme without index.theme":         {entries: []entry.Entry{{Key: "themes", Value: "NoIndex"}}, wantErr: true},
+		"Error on absent theme":                      {entries: []entry.Entry{{Key: "themes", Value: "Absent"}}, wantErr: true},
+		"Error on theme not managed by adsys":        {entries: []entry.Entry{{Key: "themes", Value: "Yaru"}}, existingFiles: true, wantErr: true},
+		"Error on assets dumping failing":            {assetsDumperErr: true, wantErr: true},
+		"Error on font cache refresh failing":        {fontCacheErr: true, wantCacheRefreshes: 1, wantErr: true},
+		"Error on invalid font keeps current fonts":  {secondCall: []entry.Entry{{Key: "fonts", Value: "NotAFont.ttf"}, {Key: "themes", Value: "Corporate"}}, wantCacheRefreshes: 1, wantErrSecondCall: true},
+		"Error on invalid theme keeps current fonts": {secondCall: []entry.Entry{{Key: "fonts", Value: "Corporate-Regular.ttf"}, {Key: "themes", Value: "NoIndex"}}, wantCacheRefreshes: 1, wantErrSecondCall: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			rootDir := t.TempDir()
+			fontsDir := filepath.Join(rootDir, "usr", "local", "share", "fonts")
+			themesDir := filepath.Join(rootDir, "usr", "local", "share", "themes")
+			if tc.existingFiles {
+				testutils.Copy(t, filepath.Join("testdata", "existing", "fonts"), fontsDir)
+				testutils.Copy(t, filepath.Join("testdata", "existing", "themes"), themesDir)
+			}
+
+			cacheOutputFile := filepath.Join(t.TempDir(), "fc-cache-output")
+			fontCacheCmd := mockFontCacheCmd(t, cacheOutputFile)
+			if tc.fontCacheErr {
+				fontCacheCmd = append(fontCacheCmd, "-Exit1")
+			}
+
+			m := appearance.New(fontsDir, themesDir, appearance.WithFontCacheCmd(fontCacheCmd))
+
+			mockAssetsDumper := testutils.MockAssetsDumper{Err: tc.assetsDumperErr, Path: "appearance/", T: t}
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries, mockAssetsDumper.SaveAssetsTo)
+			if tc.wantErr {
+				// We don't return here as we want to check that the directories are in the expected state even in error cases
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.secondCall != nil {
+				err = m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.secondCall, mockAssetsDumper.SaveAssetsTo)
+				if tc.wantErrSecondCall {
+					require.Error(t, err, "Second ApplyPolicy should have failed but didn't")
+				} else {
+					require.NoError(t, err, "Second ApplyPolicy failed but shouldn't have")
+				}
+			}
+
+			testutils.CompareTreesWithFiltering(t, rootDir, testutils.GoldenPath(t), testutils.UpdateEnabled())
+
+			got, err := os.ReadFile(cacheOutputFile)
+			if tc.wantCacheRefreshes == 0 {
+				require.Error(t, err, "fc-cache should not have been called")
+				return
+			}
+			require.NoError(t, err, "fc-cache should have been called")
+			require.Equal(t, strings.Repeat("--force\n", tc.wantCacheRefreshes), string(got), "fc-cache should have been called %d times", tc.wantCacheRefreshes)
+		})
+	}
+}
+
+func mockFontCacheCmd(t *testing.T, outputFile string) []string {
+	t.Helper()
+
+	return []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockFontCache", "--", outputFile, "--force"}
+}
+
+func TestMockFontCache(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := os.Args
+	for len(args) > 0 {
+		if args[0] != "--" {
+			args = args[1:]
+			continue
+		}
+		args = args[1:]
+		break
+	}
+	// First arg after -- is the output file to write to
+	outputFile, args := args[0], args[1:]
+
+	var wantExit bool
+	if len(args) > 0 && args[len(args)-1] == "-Exit1" {
+		wantExit = true
+		args = args[:len(args)-1]
+	}
+
+	f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	require.NoError(t, err, "Setup: Can't open fc-cache output file")
+	defer f.Close()
+	_, err = f.WriteString(strings.Join(args, " ") + "\n")
+	require.NoError(t, err, "Setup: Can't write fc-cache output file")
+
+	if wantExit {
+		f.Close()
+		// nolint:gocritic // we want to exit with an error without running the deferred calls
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,27 @@
+// Package attributes carries the attributes of the Active Directory computer object of the machine, like its
+// description, to the policy managers, which can substitute them in the policy entries.
+//
+// The attributes are carried by the context given to the policy managers. A context without attributes has none
+// set.
+package attributes
+
+import (
+	"context"
+	"strings"
+)
+
+// attributesKey is the context key of the computer attributes.
+type attributesKey struct{}
+
+// WithComputer returns a copy of ctx carrying the computer attributes attrs, keyed by their lowercase name.
+func WithComputer(ctx context.Context, attrs map[string]string) context.Context {
+	return context.WithValue(ctx, attributesKey{}, attrs)
+}
+
+// Computer returns the value of the computer attribute name of ctx, and whether it is set. Attribute names are case
+// insensitive, as in LDAP.
+func Computer(ctx context.Context, name string) (value string, ok bool) {
+	attrs, _ := ctx.Value(attributesKey{}).(map[string]string)
+	value, ok = attrs[strings.ToLower(name)]
+	return value, ok
+}
@@ -0,0 +1,45 @@
+package attributes_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/attributes"
+)
+
+func TestComputer(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		attrs   map[string]string
+		noAttrs bool
+		name    string
+
+		want   string
+		wantOk bool
+	}{
+		"Attribute is returned":               {name: "description", want: "Kiosk", wantOk: true},
+		"Attribute name is case insensitive":  {name: "Description", want: "Kiosk", wantOk: true},
+		"Attribute set to empty value is set": {attrs: map[string]string{"description": ""}, name: "description", wantOk: true},
+		"Attribute not fetched is not set":    {name: "location"},
+		"Context without attributes has none": {noAttrs: true, name: "description"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if !tc.noAttrs {
+				if tc.attrs == nil {
+					tc.attrs = map[string]string{"description": "Kiosk"}
+				}
+				ctx = attributes.WithComputer(ctx, tc.attrs)
+			}
+
+			got, ok := attributes.Computer(ctx, tc.name)
+			require.Equal(t, tc.wantOk, ok, "Computer should report if the attribute is set")
+			require.Equal(t, tc.want, got, "Computer returned an unexpected value")
+		})
+	}
+}
@@ -0,0 +1,242 @@
+// Package audit provides a manager to apply auditd rules.
+//
+// The policy is only supported on computers. The audit rules of the audit-rules
+// entry, one auditctl rule per line, are written to a file managed by adsys in
+// the audit rules directory, and loaded by regenerating the audit rules with
+// augenrules --load.
+//
+// Only watch (-w, -W) and syscall (-a, -A) rules are accepted: the control
+// rules, like -D or -e, would change the configuration set by the other rules
+// files. Each rule is minimally validated before writing anything.
+//
+// The file is only written, and the rules loaded, when its content changed. If
+// there are no rules to apply, the managed file is removed, and the rules are
+// loaded again if it existed.
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+// rulesFileName is the name of the file, in the audit rules directory, managed by adsys.
+// It is ordered late so that augenrules appends its rules after the ones of the distribution and other packages.
+const rulesFileName = "99-adsys.rules"
+
+var (
+	// permissionsRe matches the permissions of a watch rule.
+	permissionsRe = regexp.MustCompile(`^[rwxa]+$`)
+	// keyRe matches the keys of the rules, used to search the audit logs.
+	keyRe = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+
+	// ruleActions and ruleLists are the actions and lists of the syscall rules.
+	ruleActions = []string{"always", "never"}
+	ruleLists   = []string{"task", "exit", "user", "exclude", "filesystem", "io_uring"}
+)
+
+// Manager applies the audit rules of the audit policy.
+type Manager struct {
+	rulesDir string
+	loadCmd  []string
+}
+
+type options struct {
+	loadCmd []string
+}
+
+// Option reprents an optional function to change the audit manager.
+type Option func(*options)
+
+// WithLoadCmd overrides the default command loading the audit rules.
+func WithLoadCmd(cmd []string) Option {
+	return func(o *options) {
+		o.loadCmd = cmd
+	}
+}
+
+// New creates a manager with a specific audit rules directory.
+func New(rulesDir string, opts ...Option) *Manager {
+	// defaults
+	args := options{
+		loadCmd: []string{"augenrules", "--load"},
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Manager{
+		rulesDir: rulesDir,
+		loadCmd:  args.loadCmd,
+	}
+}
+
+// ApplyPolicy writes the audit rules of the policy to the managed rules file and loads the audit rules if it changed.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply audit policy to %s", objectName))
+
+	// Audit policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	rulesPath := filepath.Join(m.rulesDir, rulesFileName)
+
+	var content string
+	for _, e := range entries {
+		if e.Key != "audit-rules" {
+			ignored.RecordUnsupportedKey(ctx, "audit", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled {
+			continue
+		}
+		if content, err = parseRules(e.Value); err != nil {
+			return err
+		}
+	}
+
+	if content == "" {
+		if _, err := os.Stat(rulesPath); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		log.Debug(ctx, gotext.Get("Removing audit rules %s", rulesPath))
+		if removed, err := tamper.Remove(ctx, "audit", rulesPath); err != nil || !removed {
+			return err
+		}
+		return m.load(ctx)
+	}
+
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(m.rulesDir, 0750); err != nil {
+		return err
+	}
+
+	oldContent, err := os.ReadFile(rulesPath)
+	if err == nil && string(oldContent) == content {
+		log.Debug(ctx, gotext.Get("Audit rules %s are up to date", rulesPath))
+		return nil
+	}
+
+	log.Debugf(ctx, "Applying audit policy to %s", objectName)
+	if written, err := tamper.Write(ctx, "audit", rulesPath, []byte(content), 0600); err != nil || !written {
+		return err
+	}
+
+	return m.load(ctx)
+}
+
+// load regenerates the audit rules from the rules files of the system and loads them.
+func (m *Manager) load(ctx context.Context) error {
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, m.loadCmd[0], m.loadCmd[1:]...)
+	trace.Command(ctx, "audit", cmd.Args)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return errors.New(gotext.Get("failed to load audit rules: %v\n%s", err, string(out)))
+	}
+	return nil
+}
+
+// parseRules validates the audit rules, one per line, of the audit-rules entry value and returns the content of the
+// managed rules file. Empty lines and comments are ignored.
+func parseRules(value string) (content string, err error) {
+	var rules []string
+	for _, l := range strings.Split(value, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		fields := strings.Fields(l)
+		if err := checkRule(fields); err != nil {
+			return "", errors.New(gotext.Get("invalid audit rule %q: %v", l, err))
+		}
+		rules = append(rules, strings.Join(fields, " "))
+	}
+
+	if len(rules) == 0 {
+		return "", nil
+	}
+
+	return fmt.Sprintf(`# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+
+%s
+`, strings.Join(rules, "\n")), nil
+}
+
+// checkRule minimally validates the fields of a watch or syscall audit rule: the rule type, its argument and that
+// its options all have a value.
+func checkRule(fields []string) error {
+	if len(fields) < 2 {
+		return errors.New(gotext.Get("expecting a rule type and its argument"))
+	}
+
+	var allowedOptions []string
+	switch ruleType, arg := fields[0], fields[1]; ruleType {
+	case "-w", "-W":
+		if !filepath.IsAbs(arg) {
+			return errors.New(gotext.Get("watched path %q must be absolute", arg))
+		}
+		allowedOptions = []string{"-p", "-k"}
+	case "-a", "-A":
+		action, list, found := strings.Cut(arg, ",")
+		// auditctl accepts both orders.
+		if slices.Contains(ruleLists, action) {
+			action, list = list, action
+		}
+		if !found || !slices.Contains(ruleActions, action) || !slices.Contains(ruleLists, list) {
+			return errors.New(gotext.Get("expecting action,list with action one of %s and list one of %s",
+				strings.Join(ruleActions, ", "), strings.Join(ruleLists, ", ")))
+		}
+		allowedOptions = []string{"-S", "-F", "-C", "-k"}
+	default:
+		return errors.New(gotext.Get("only watch (-w, -W) and syscall (-a, -A) rules are allowed"))
+	}
+
+	opts := fields[2:]
+	for len(opts) > 0 {
+		opt := opts[0]
+		if !slices.Contains(allowedOptions, opt) {
+			return errors.New(gotext.Get("option %q is not allowed, expecting one of %s", opt, strings.Join(allowedOptions, ", ")))
+		}
+		if len(opts) < 2 {
+			return errors.New(gotext.Get("option %q is missing its value", opt))
+		}
+		v := opts[1]
+		switch opt {
+		case "-p":
+			if !permissionsRe.MatchString(v) {
+				return errors.New(gotext.Get("invalid permissions %q, expecting a combination of r, w, x and a", v))
+			}
+		case "-k":
+			if !keyRe.MatchString(v) {
+				return errors.New(gotext.Get("invalid key %q", v))
+			}
+		}
+		opts = opts[2:]
+	}
+
+	return nil
+}
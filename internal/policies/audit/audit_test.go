@@ -0,0 +1,157 @@
+package audit_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/audit"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{{Key: "audit-rules", Value: "-w /etc/passwd -p wa -k identity\n-a always,exit -F arch=b64 -S sethostname,setdomainname -k system-locale"}}
+
+	tests := map[string]struct {
+		entries []entry.Entry
+		user    bool
+
+		rulesFileAlreadyExists bool
+		readOnlyRulesDir       bool
+		loadCmdError           bool
+
+		wantLoad bool
+		wantErr  bool
+	}{
+		"Computer, rules are written and loaded":                 {wantLoad: true},
+		"Computer, unchanged rules are not loaded again":         {rulesFileAlreadyExists: true},
+		"Computer, changed rules are written and loaded":         {entries: []entry.Entry{{Key: "audit-rules", Value: "-w /etc/sudoers -p wa -k scope"}}, rulesFileAlreadyExists: true, wantLoad: true},
+		"Computer, comments, blank lines and spaces are ignored": {entries: []entry.Entry{{Key: "audit-rules", Value: "# Identity\n\n  -w /etc/passwd   -p wa -k identity \n# Locale\n-a always,exit -F arch=b64 -S sethostname,setdomainname -k system-locale\n"}}, rulesFileAlreadyExists: true},
+		"Computer, all rule types and options are accepted": {entries: []entry.Entry{{Key: "audit-rules", Value: `-w /etc/shadow
+-W /etc/group -k identity
+-a exit,always -S open -F exit=-EACCES -k access
+-A never,exclude -F msgtype=CWD
+-a always,exit -F arch=b64 -C auid!=obj_uid -S execve`}}, wantLoad: true},
+		"Computer, no entries removes existing rules":     {entries: []entry.Entry{}, rulesFileAlreadyExists: true, wantLoad: true},
+		"Computer, disabled entry removes existing rules": {entries: []entry.Entry{{Key: "audit-rules", Disabled: true}}, rulesFileAlreadyExists: true, wantLoad: true},
+		"Computer, only comments removes existing rules":  {entries: []entry.Entry{{Key: "audit-rules", Value: "# nothing\n"}}, rulesFileAlreadyExists: true, wantLoad: true},
+		"Computer, no entries and no existing rules":      {entries: []entry.Entry{}},
+		"Computer, no entries and read-only rules dir":    {entries: []entry.Entry{}, readOnlyRulesDir: true},
+		"Computer, unexpected entry key is ignored":       {entries: []entry.Entry{{Key: "audit-rules-foo", Value: "-w /etc/passwd"}}},
+		"User, policy is ignored":                         {user: true},
+		"User, existing rules are kept":                   {entries: []entry.Entry{}, rulesFileAlreadyExists: true, user: true},
+
+		"Error on control rule":                         {entries: []entry.Entry{{Key: "audit-rules", Value: "-w /etc/passwd\n-D"}}, rulesFileAlreadyExists: true, wantErr: true},
+		"Error on immutable rule":                       {entries: []entry.Entry{{Key: "audit-rules", Value: "-e 2"}}, wantErr: true},
+		"Error on relative watched path":                {entries: []entry.Entry{{Key: "audit-rules", Value: "-w etc/passwd -p wa"}}, wantErr: true},
+		"Error on invalid watch permissions":            {entries: []entry.Entry{{Key: "audit-rules", Value: "-w /etc/passwd -p rwz"}}, wantErr: true},
+		"Error on syscall option in watch rule":         {entries: []entry.Entry{{Key: "audit-rules", Value: "-w /etc/passwd -S open"}}, wantErr: true},
+		"Error on invalid syscall rule action":          {entries: []entry.Entry{{Key: "audit-rules", Value: "-a sometimes,exit -S open"}}, wantErr: true},
+		"Error on invalid syscall rule list":            {entries: []entry.Entry{{Key: "audit-rules", Value: "-a always,entry -S open"}}, wantErr: true},
+		"Error on syscall rule without list":            {entries: []entry.Entry{{Key: "audit-rules", Value: "-a always -S open"}}, wantErr: true},
+		"Error on rule without argument":                {entries: []entry.Entry{{Key: "audit-rules", Value: "-w"}}, wantErr: true},
+		"Error on option without value":                 {entries: []entry.Entry{{Key: "audit-rules", Value: "-w /etc/passwd -k"}}, wantErr: true},
+		"Error on invalid key":                          {entries: []entry.Entry{{Key: "audit-rules", Value: "-w /etc/passwd -k ident/ity"}}, wantErr: true},
+		"Error on read-only rules dir":                  {readOnlyRulesDir: true, wantErr: true},
+		"Error on load command failing":                 {loadCmdError: true, wantLoad: true, wantErr: true},
+		"Error on load command failing on removed rule": {entries: []entry.Entry{}, rulesFileAlreadyExists: true, loadCmdError: true, wantLoad: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			rulesDir := filepath.Join(t.TempDir(), "rules.d")
+			if tc.rulesFileAlreadyExists {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "rules_dir"), rulesDir)
+			}
+			if tc.readOnlyRulesDir {
+				require.NoError(t, os.MkdirAll(rulesDir, 0750), "Setup: can't create rules dir")
+				testutils.MakeReadOnly(t, rulesDir)
+			}
+
+			loadOutputFile := filepath.Join(t.TempDir(), "augenrules-output")
+			loadCmd := mockLoadCmd(t, loadOutputFile)
+			if tc.loadCmdError {
+				loadCmd = append(loadCmd, "-Exit1")
+			}
+
+			m := audit.New(rulesDir, audit.WithLoadCmd(loadCmd))
+
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				// We don't return here as we want to check that the rules dir is in the expected state even in error cases
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.readOnlyRulesDir {
+				// nolint:gosec //false positive, this is a directory
+				require.NoError(t, os.Chmod(rulesDir, 0750), "Setup: can't restore rules dir permissions")
+			}
+			testutils.CompareTreesWithFiltering(t, rulesDir, filepath.Join(testutils.GoldenPath(t), "etc", "audit", "rules.d"), testutils.UpdateEnabled())
+
+			got, err := os.ReadFile(loadOutputFile)
+			if !tc.wantLoad {
+				require.Error(t, err, "augenrules should not have been called")
+				return
+			}
+			require.NoError(t, err, "augenrules should have been called")
+			require.Equal(t, "--load\n", string(got), "augenrules should have been called once to load the rules")
+		})
+	}
+}
+
+func mockLoadCmd(t *testing.T, outputFile string) []string {
+	t.Helper()
+
+	return []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockAugenrules", "--", outputFile, "--load"}
+}
+
+func TestMockAugenrules(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := os.Args
+	for len(args) > 0 {
+		if args[0] != "--" {
+			args = args[1:]
+			continue
+		}
+		args = args[1:]
+		break
+	}
+	// First arg after -- is the output file to write to
+	outputFile, args := args[0], args[1:]
+
+	var wantExit bool
+	if args[len(args)-1] == "-Exit1" {
+		wantExit = true
+		args = args[:len(args)-1]
+	}
+
+	f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	require.NoError(t, err, "Setup: Can't open augenrules output file")
+	defer f.Close()
+	_, err = f.WriteString(strings.Join(args, " ") + "\n")
+	require.NoError(t, err, "Setup: Can't write augenrules output file")
+
+	if wantExit {
+		f.Close()
+		// nolint:gocritic // we want to exit with an error without running the deferred calls
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,245 @@
+// Package banner provides a manager to set the login banners and the message of the day.
+//
+// The policy is only supported on computers. The text of each entry is written
+// to its file:
+//   - issue: /etc/issue, displayed before local console logins.
+//   - issue-net: /etc/issue.net, displayed before remote logins when configured.
+//   - motd: /etc/motd.d/adsys, displayed after logins.
+//
+// {hostname} and {domain} in the text are replaced by the name of the machine and
+// its Active Directory domain. As agetty interprets backslash escapes in
+// /etc/issue, backslashes are escaped in this file so that the text is
+// displayed as is.
+//
+// /etc/issue and /etc/issue.net are owned by the distribution: their original
+// content is saved in the adsys state directory the first time they are
+// written, and restored when the policy doesn't set them anymore. The motd file
+// is owned by adsys and removed when the policy doesn't set it.
+//
+// A file is only written when its content changed.
+package banner
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// origSuffix is the suffix of the saved original content of a distribution file.
+	origSuffix = ".orig"
+	// absentSuffix is the suffix of the marker of a distribution file which didn't exist before adsys wrote it.
+	absentSuffix = ".absent"
+)
+
+// bannerFile is a file written from an entry of the policy.
+type bannerFile struct {
+	// path is the path of the file, relative to the configuration directory.
+	path string
+	// distribution is true if the file is owned by the distribution and needs to be restored.
+	distribution bool
+	// escapeBackslashes is true if the backslashes are interpreted in the file.
+	escapeBackslashes bool
+}
+
+// bannerFiles maps the supported entry keys to the file they are written to.
+var bannerFiles = map[string]bannerFile{
+	"issue":     {path: "issue", distribution: true, escapeBackslashes: true},
+	"issue-net": {path: "issue.net", distribution: true},
+	"motd":      {path: filepath.Join("motd.d", "adsys")},
+}
+
+// Manager writes the banners of the policy.
+type Manager struct {
+	hostname  string
+	domain    string
+	confDir   string
+	backupDir string
+}
+
+type options struct {
+	confDir string
+}
+
+// Option reprents an optional function to change the banner manager.
+type Option func(*options)
+
+// WithConfDir specifies a personalized directory for the issue and issue.net files and the motd.d directory.
+func WithConfDir(p string) Option {
+	return func(o *options) {
+		o.confDir = p
+	}
+}
+
+// New creates a manager substituting the hostname and domain in the banners, and saving the original
+// distribution files in the state directory.
+func New(hostname, domain, stateDir string, opts ...Option) *Manager {
+	// defaults
+	args := options{
+		confDir: "/etc",
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Manager{
+		hostname:  hostname,
+		domain:    domain,
+		confDir:   args.confDir,
+		backupDir: filepath.Join(stateDir, "banner"),
+	}
+}
+
+// ApplyPolicy writes the banners of the policy to their file, and restores or removes the files of the
+// banners which are not set.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply banner policy to %s", objectName))
+
+	// Banner policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	replacer := strings.NewReplacer("{hostname}", m.hostname, "{domain}", m.domain)
+
+	texts := make(map[string]string)
+	for _, e := range entries {
+		if _, ok := bannerFiles[e.Key]; !ok {
+			ignored.RecordUnsupportedKey(ctx, "banner", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled || strings.TrimSpace(e.Value) == "" {
+			continue
+		}
+		texts[e.Key] = replacer.Replace(strings.TrimRight(e.Value, "\n")) + "\n"
+	}
+
+	log.Debugf(ctx, "Applying banner policy to %s", objectName)
+
+	// Apply the files in a stable order, to get reproducible errors
+	for _, key := range []string{"issue", "issue-net", "motd"} {
+		f := bannerFiles[key]
+		text, ok := texts[key]
+		if !ok {
+			if err := m.reset(ctx, f); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.escapeBackslashes {
+			text = strings.ReplaceAll(text, `\`, `\\`)
+		}
+		if err := m.write(ctx, f, text); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write saves the original content of a distribution file if needed, then writes the text to the file if it
+// changed.
+func (m *Manager) write(ctx context.Context, f bannerFile, text string) error {
+	path := filepath.Join(m.confDir, f.path)
+
+	if f.distribution {
+		if err := m.saveOriginal(path, f); err != nil {
+			return err
+		}
+	}
+
+	oldContent, err := os.ReadFile(path)
+	if err == nil && string(oldContent) == text {
+		log.Debug(ctx, gotext.Get("Banner %s is up to date", path))
+		return nil
+	}
+
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	log.Debug(ctx, gotext.Get("Writing banner %s", path))
+	// nolint:gosec // G306 banners are displayed to everyone
+	if err := os.WriteFile(path+".new", []byte(text), 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".new", path)
+}
+
+// saveOriginal saves the content of the distribution file at path, or that it didn't exist, the first time it
+// is managed by adsys.
+func (m *Manager) saveOriginal(path string, f bannerFile) error {
+	backup := filepath.Join(m.backupDir, filepath.Base(f.path))
+	for _, p := range []string{backup + origSuffix, backup + absentSuffix} {
+		if _, err := os.Stat(p); err == nil {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(m.backupDir, 0700); err != nil {
+		return err
+	}
+
+	orig, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return os.WriteFile(backup+absentSuffix, nil, 0600)
+	} else if err != nil {
+		return err
+	}
+	if err := os.WriteFile(backup+origSuffix+".new", orig, 0600); err != nil {
+		return err
+	}
+	return os.Rename(backup+origSuffix+".new", backup+origSuffix)
+}
+
+// reset restores the original content of a distribution file, or removes a file owned by adsys.
+func (m *Manager) reset(ctx context.Context, f bannerFile) error {
+	path := filepath.Join(m.confDir, f.path)
+
+	if !f.distribution {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		log.Debug(ctx, gotext.Get("Removing banner %s", path))
+		return os.Remove(path)
+	}
+
+	backup := filepath.Join(m.backupDir, filepath.Base(f.path))
+
+	// The file didn't exist before adsys wrote it
+	if _, err := os.Stat(backup + absentSuffix); err == nil {
+		log.Debug(ctx, gotext.Get("Removing banner %s", path))
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return os.Remove(backup + absentSuffix)
+	}
+
+	orig, err := os.ReadFile(backup + origSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Not managed by adsys
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	log.Debug(ctx, gotext.Get("Restoring original banner %s", path))
+	// nolint:gosec // G306 banners are displayed to everyone
+	if err := os.WriteFile(path+".new", orig, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		return err
+	}
+	return os.Remove(backup + origSuffix)
+}
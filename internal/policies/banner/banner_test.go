@@ -0,0 +1,97 @@
+package banner_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/banner"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{
+		{Key: "issue", Value: "Authorized use only on {hostname}.\nAll activity is monitored by {domain}.\n"},
+		{Key: "issue-net", Value: "Authorized use only on {hostname}.{domain}.\n"},
+		{Key: "motd", Value: "Welcome to {hostname}, member of {domain}.\nReport incidents to security@{domain}.\n"},
+	}
+
+	tests := map[string]struct {
+		entries []entry.Entry
+		user    bool
+
+		existingFiles string
+		readOnlyDir   string
+
+		wantErr bool
+	}{
+		"Computer, banners are written with hostname and domain substituted": {existingFiles: "distribution"},
+		"Computer, unchanged banners are kept":                               {existingFiles: "managed"},
+		"Computer, changed banners are written and originals are kept": {existingFiles: "managed", entries: []entry.Entry{
+			{Key: "issue", Value: "Private system."},
+			{Key: "issue-net", Value: "Private system."},
+			{Key: "motd", Value: "Welcome."},
+		}},
+		"Computer, only configured banners are written":    {existingFiles: "distribution", entries: []entry.Entry{{Key: "motd", Value: "Welcome to {hostname}."}}},
+		"Computer, backslashes are only escaped in issue":  {existingFiles: "distribution", entries: []entry.Entry{{Key: "issue", Value: `Use DOMAIN\user to log in`}, {Key: "issue-net", Value: `Use DOMAIN\user to log in`}}},
+		"Computer, missing distribution files are created": {},
+		"Computer, no entries restores original banners":   {existingFiles: "managed", entries: []entry.Entry{}},
+		"Computer, disabled entries restore original banners": {existingFiles: "managed", entries: []entry.Entry{
+			{Key: "issue", Disabled: true},
+			{Key: "issue-net", Disabled: true},
+			{Key: "motd", Disabled: true},
+		}},
+		"Computer, empty text restores original banner":             {existingFiles: "managed", entries: []entry.Entry{{Key: "issue", Value: " \n"}, {Key: "issue-net", Value: "Authorized use only on ubuntu.example.com.\n"}}},
+		"Computer, no entries removes banners created by adsys":     {existingFiles: "managed_absent", entries: []entry.Entry{}},
+		"Computer, no entries keeps files not managed by adsys":     {existingFiles: "distribution", entries: []entry.Entry{}},
+		"Computer, unexpected entry key is ignored":                 {existingFiles: "distribution", entries: []entry.Entry{{Key: "banner-foo", Value: "Hello"}}},
+		"User, policy is ignored":                                   {existingFiles: "distribution", user: true},
+		"User, existing banners are kept":                           {existingFiles: "managed", entries: []entry.Entry{}, user: true},
+		"Error on read-only configuration directory":                {existingFiles: "distribution", readOnlyDir: "etc", wantErr: true},
+		"Error on read-only state directory saving original banner": {existingFiles: "distribution", readOnlyDir: filepath.Join("var", "lib", "adsys"), wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			root := filepath.Join(t.TempDir(), "root")
+			if tc.existingFiles != "" {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), tc.existingFiles), root)
+			} else {
+				require.NoError(t, os.MkdirAll(root, 0750), "Setup: can't create root dir")
+			}
+			confDir := filepath.Join(root, "etc")
+			stateDir := filepath.Join(root, "var", "lib", "adsys")
+			if tc.readOnlyDir != "" {
+				require.NoError(t, os.MkdirAll(filepath.Join(root, tc.readOnlyDir), 0750), "Setup: can't create read-only dir")
+				testutils.MakeReadOnly(t, filepath.Join(root, tc.readOnlyDir))
+			}
+
+			m := banner.New("ubuntu", "example.com", stateDir, banner.WithConfDir(confDir))
+
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				// We don't return here as we want to check that the files are in the expected state even in error cases
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.readOnlyDir != "" {
+				// nolint:gosec //false positive, this is a directory
+				require.NoError(t, os.Chmod(filepath.Join(root, tc.readOnlyDir), 0750), "Setup: can't restore read-only dir permissions")
+			}
+			testutils.CompareTreesWithFiltering(t, root, testutils.GoldenPath(t), testutils.UpdateEnabled())
+		})
+	}
+}
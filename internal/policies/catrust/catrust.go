@@ -0,0 +1,225 @@
+// Package catrust provides a manager to add CA certificates to the system trust store.
+//
+// The policy is only supported on computers. The ca-certificates entry is a
+// list of PEM encoded CA certificates. Each certificate is written, named after
+// its SHA-256 fingerprint, to a directory managed by adsys under the global
+// trust store directory, and the system trust store is then regenerated with
+// update-ca-certificates.
+//
+// All certificates are parsed before writing anything: if any of them is
+// invalid, the policy fails and the trust store is left untouched.
+//
+// Certificates which are not part of the policy anymore are removed from the
+// managed directory. The trust store is only regenerated when a certificate
+// was added or removed.
+package catrust
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+// certsDirName is the directory, in the global trust store directory, managed by adsys.
+const certsDirName = "adsys"
+
+// Manager writes the CA certificates of the catrust policy to the system trust store.
+type Manager struct {
+	certsDir  string
+	updateCmd []string
+}
+
+type options struct {
+	updateCmd []string
+}
+
+// Option reprents an optional function to change the catrust manager.
+type Option func(*options)
+
+// WithUpdateCmd overrides the default command regenerating the system trust store.
+func WithUpdateCmd(cmd []string) Option {
+	return func(o *options) {
+		o.updateCmd = cmd
+	}
+}
+
+// New creates a manager with a specific global trust store directory.
+func New(globalTrustDir string, opts ...Option) *Manager {
+	// defaults
+	args := options{
+		updateCmd: []string{"update-ca-certificates", "--fresh"},
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Manager{
+		certsDir:  filepath.Join(globalTrustDir, certsDirName),
+		updateCmd: args.updateCmd,
+	}
+}
+
+// certificate is a CA certificate to write to the managed directory.
+type certificate struct {
+	name    string
+	content []byte
+}
+
+// ApplyPolicy writes the CA certificates of the policy to the managed directory, removes the ones not part of the
+// policy anymore and regenerates the system trust store if any of them changed.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply CA certificates policy to %s", objectName))
+
+	// CA certificates policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	var certs []certificate
+	for _, e := range entries {
+		if e.Key != "ca-certificates" {
+			ignored.RecordUnsupportedKey(ctx, "catrust", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled {
+			continue
+		}
+		if certs, err = parseCertificates(e.Value); err != nil {
+			return err
+		}
+	}
+
+	existing, err := os.ReadDir(m.certsDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if len(certs) == 0 && errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	var changed bool
+	for _, f := range existing {
+		if slices.ContainsFunc(certs, func(c certificate) bool { return c.name == f.Name() }) {
+			continue
+		}
+		log.Debug(ctx, gotext.Get("Removing CA certificate %s", f.Name()))
+		if err := os.Remove(filepath.Join(m.certsDir, f.Name())); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	if len(certs) == 0 {
+		if err := os.Remove(m.certsDir); err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+		return m.update(ctx)
+	}
+
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(m.certsDir, 0755); err != nil {
+		return err
+	}
+
+	for _, c := range certs {
+		path := filepath.Join(m.certsDir, c.name)
+		if oldContent, err := os.ReadFile(path); err == nil && bytes.Equal(oldContent, c.content) {
+			continue
+		}
+		log.Debug(ctx, gotext.Get("Adding CA certificate %s", c.name))
+		// nolint:gosec // G306 certificates in the trust store are world-readable
+		if err := os.WriteFile(path+".new", c.content, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(path+".new", path); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	if !changed {
+		log.Debug(ctx, gotext.Get("CA certificates in %s are up to date", m.certsDir))
+		return nil
+	}
+
+	return m.update(ctx)
+}
+
+// update regenerates the system trust store from the certificates directories.
+func (m *Manager) update(ctx context.Context) error {
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, m.updateCmd[0], m.updateCmd[1:]...)
+	trace.Command(ctx, "catrust", cmd.Args)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return errors.New(gotext.Get("failed to update the system trust store: %v\n%s", err, string(out)))
+	}
+	return nil
+}
+
+// parseCertificates validates the PEM encoded CA certificates of the entry value and returns them, sorted by name
+// and without duplicates.
+func parseCertificates(value string) (certs []certificate, err error) {
+	rest := []byte(value)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, errors.New(gotext.Get("invalid PEM block %q: expecting a CERTIFICATE", block.Type))
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.New(gotext.Get("invalid certificate: %v", err))
+		}
+
+		fingerprint := sha256.Sum256(cert.Raw)
+		name := hex.EncodeToString(fingerprint[:]) + ".crt"
+		if slices.ContainsFunc(certs, func(c certificate) bool { return c.name == name }) {
+			continue
+		}
+		certs = append(certs, certificate{
+			name:    name,
+			content: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}),
+		})
+	}
+
+	if strings.TrimSpace(string(rest)) != "" {
+		return nil, errors.New(gotext.Get("invalid PEM data: %q", firstLine(string(rest))))
+	}
+
+	slices.SortFunc(certs, func(a, b certificate) int { return strings.Compare(a.name, b.name) })
+	return certs, nil
+}
+
+// firstLine returns the first non empty line of s.
+func firstLine(s string) string {
+	l, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(l)
+}
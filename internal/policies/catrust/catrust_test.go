@@ -0,0 +1,165 @@
+package catrust_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/catrust"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		certs   []string
+		entries []entry.Entry
+		user    bool
+
+		certsAlreadyExist bool
+		readOnlyTrustDir  bool
+		updateCmdError    bool
+
+		wantUpdate bool
+		wantErr    bool
+	}{
+		"Computer, certificates are written and trust store updated":       {wantUpdate: true},
+		"Computer, added certificate is written and trust store updated":   {certs: []string{"ca1.pem", "ca2.pem", "ca3.pem"}, certsAlreadyExist: true, wantUpdate: true},
+		"Computer, removed certificate is deleted and trust store updated": {certs: []string{"ca1.pem"}, certsAlreadyExist: true, wantUpdate: true},
+		"Computer, added and removed certificates are reconciled":          {certsAlreadyExist: true, wantUpdate: true},
+		"Computer, unchanged certificates do not update trust store":       {certs: []string{"ca3.pem", "ca1.pem"}, certsAlreadyExist: true},
+		"Computer, duplicated certificates are written once":               {certs: []string{"ca1.pem", "ca1.pem", "ca3.pem"}, certsAlreadyExist: true},
+		"Computer, no entries removes existing certificates":               {entries: []entry.Entry{}, certsAlreadyExist: true, wantUpdate: true},
+		"Computer, disabled entry removes existing certificates":           {entries: []entry.Entry{{Key: "ca-certificates", Disabled: true}}, certsAlreadyExist: true, wantUpdate: true},
+		"Computer, empty value removes existing certificates":              {entries: []entry.Entry{{Key: "ca-certificates", Value: " \n"}}, certsAlreadyExist: true, wantUpdate: true},
+		"Computer, no entries and no existing certificates":                {entries: []entry.Entry{}},
+		"Computer, no entries and read-only trust directory":               {entries: []entry.Entry{}, readOnlyTrustDir: true},
+		"Computer, unexpected entry key is ignored":                        {entries: []entry.Entry{{Key: "ca-certificates-foo", Value: "foo"}}},
+		"User, policy is ignored":                                          {user: true},
+		"User, existing certificates are kept":                             {entries: []entry.Entry{}, certsAlreadyExist: true, user: true},
+
+		"Error on invalid PEM":                          {entries: []entry.Entry{{Key: "ca-certificates", Value: "this is not a certificate"}}, certsAlreadyExist: true, wantErr: true},
+		"Error on invalid PEM after valid certificates": {certs: []string{"ca1.pem", "ca2.pem", "invalid-certificate.pem"}, certsAlreadyExist: true, wantErr: true},
+		"Error on PEM block not being a certificate":    {certs: []string{"ca1.pem", "private-key.pem"}, certsAlreadyExist: true, wantErr: true},
+		"Error on trailing data after certificates":     {entries: []entry.Entry{{Key: "ca-certificates", Value: "-----BEGIN CERTIFICATE-----\ntruncated"}}, wantErr: true},
+		"Error on read-only trust directory":            {readOnlyTrustDir: true, wantErr: true},
+		"Error on read-only trust directory on removal": {entries: []entry.Entry{}, certsAlreadyExist: true, readOnlyTrustDir: true, wantErr: true},
+		"Error on update command failing":               {updateCmdError: true, wantUpdate: true, wantErr: true},
+		"Error on update command failing on removal":    {entries: []entry.Entry{}, certsAlreadyExist: true, updateCmdError: true, wantUpdate: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				if tc.certs == nil {
+					tc.certs = []string{"ca1.pem", "ca2.pem"}
+				}
+				var value string
+				for _, c := range tc.certs {
+					content, err := os.ReadFile(filepath.Join(testutils.TestFamilyPath(t), "certs", c))
+					require.NoError(t, err, "Setup: can't read certificate")
+					value += string(content)
+				}
+				tc.entries = []entry.Entry{{Key: "ca-certificates", Value: value}}
+			}
+
+			trustDir := filepath.Join(t.TempDir(), "ca-certificates")
+			if tc.certsAlreadyExist {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "ca-certificates"), trustDir)
+			} else {
+				require.NoError(t, os.MkdirAll(trustDir, 0750), "Setup: can't create trust dir")
+			}
+			certsDir := filepath.Join(trustDir, "adsys")
+			if tc.readOnlyTrustDir {
+				if tc.certsAlreadyExist {
+					testutils.MakeReadOnly(t, certsDir)
+				}
+				testutils.MakeReadOnly(t, trustDir)
+			}
+
+			updateOutputFile := filepath.Join(t.TempDir(), "update-output")
+			updateCmd := mockUpdateCmd(t, updateOutputFile)
+			if tc.updateCmdError {
+				updateCmd = append(updateCmd, "-Exit1")
+			}
+
+			m := catrust.New(trustDir, catrust.WithUpdateCmd(updateCmd))
+
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				// We don't return here as we want to check that the trust dir is in the expected state even in error cases
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.readOnlyTrustDir {
+				// nolint:gosec //false positive, this is a directory
+				require.NoError(t, os.Chmod(trustDir, 0750), "Setup: can't restore trust dir permissions")
+				if tc.certsAlreadyExist {
+					// nolint:gosec //false positive, this is a directory
+					require.NoError(t, os.Chmod(certsDir, 0750), "Setup: can't restore certificates dir permissions")
+				}
+			}
+			testutils.CompareTreesWithFiltering(t, trustDir, filepath.Join(testutils.GoldenPath(t), "ca-certificates"), testutils.UpdateEnabled())
+
+			got, err := os.ReadFile(updateOutputFile)
+			if !tc.wantUpdate {
+				require.Error(t, err, "update-ca-certificates should not have been called")
+				return
+			}
+			require.NoError(t, err, "update-ca-certificates should have been called")
+			require.Equal(t, "--fresh\n", string(got), "update-ca-certificates should have been called once")
+		})
+	}
+}
+
+func mockUpdateCmd(t *testing.T, outputFile string) []string {
+	t.Helper()
+
+	return []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockUpdateCACertificates", "--", outputFile, "--fresh"}
+}
+
+func TestMockUpdateCACertificates(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := os.Args
+	for len(args) > 0 {
+		if args[0] != "--" {
+			args = args[1:]
+			continue
+		}
+		args = args[1:]
+		break
+	}
+	// First arg after -- is the output file to write to
+	outputFile, args := args[0], args[1:]
+
+	var wantExit bool
+	if args[len(args)-1] == "-Exit1" {
+		wantExit = true
+		args = args[:len(args)-1]
+	}
+
+	f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	require.NoError(t, err, "Setup: Can't open update-ca-certificates output file")
+	defer f.Close()
+	_, err = f.WriteString(strings.Join(args, " ") + "\n")
+	require.NoError(t, err, "Setup: Can't write update-ca-certificates output file")
+
+	if wantExit {
+		f.Close()
+		// nolint:gocritic // we want to exit with an error without running the deferred calls
+		os.Exit(1)
+	}
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/ubuntu/adsys/internal/consts"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/trace"
 	"github.com/ubuntu/adsys/internal/smbsafe"
 	"github.com/ubuntu/decorate"
 )
@@ -44,6 +45,7 @@ type Manager struct {
 	domain          string
 	stateDir        string
 	krb5CacheDir    string
+	krb5CCPath      string
 	vendorPythonDir string
 	globalTrustDir  string
 	certEnrollCmd   []string
@@ -83,6 +85,7 @@ type options struct {
 	runDir            string
 	shareDir          string
 	globalTrustDir    string
+	krb5CCPath        string
 	certAutoenrollCmd []string
 }
 
@@ -117,6 +120,13 @@ func WithGlobalTrustDir(p string) func(*options) {
 	}
 }
 
+// WithKrb5CCPath overrides the machine Kerberos ticket cache used by the autoenrollment script.
+func WithKrb5CCPath(p string) func(*options) {
+	return func(a *options) {
+		a.krb5CCPath = p
+	}
+}
+
 // WithCertAutoenrollCmd overrides the default certificate autoenroll command.
 func WithCertAutoenrollCmd(cmd []string) func(*options) {
 	return func(a *options) {
@@ -143,6 +153,7 @@ func New(domain string, opts ...Option) *Manager {
 		domain:          domain,
 		stateDir:        args.stateDir,
 		krb5CacheDir:    filepath.Join(args.runDir, "krb5cc"),
+		krb5CCPath:      args.krb5CCPath,
 		vendorPythonDir: filepath.Join(args.shareDir, "python"),
 		globalTrustDir:  args.globalTrustDir,
 		certEnrollCmd:   args.certAutoenrollCmd,
@@ -243,8 +254,13 @@ func (m *Manager) runScript(ctx context.Context, action, objectName string, extr
 	log.Debugf(ctx, "Running cert autoenroll script with arguments: %q", strings.Join(scriptArgs, " "))
 	// #nosec G204 - cmdArgs is under our control (python embedded script or mock for tests)
 	cmd := exec.CommandContext(cmdCtx, cmdArgs[0], cmdArgs[1:]...)
+	trace.Command(ctx, "certificate", cmd.Args)
+	krb5CCPath := m.krb5CCPath
+	if krb5CCPath == "" {
+		krb5CCPath = filepath.Join(m.krb5CacheDir, objectName)
+	}
 	cmd.Env = append(os.Environ(),
-		fmt.Sprintf("KRB5CCNAME=%s", filepath.Join(m.krb5CacheDir, objectName)),
+		fmt.Sprintf("KRB5CCNAME=%s", krb5CCPath),
 		fmt.Sprintf("PYTHONPATH=%s:%s", os.Getenv("PYTHONPATH"), m.vendorPythonDir),
 	)
 	smbsafe.WaitExec()
@@ -41,6 +41,7 @@ func TestApplyPolicy(t *testing.T) {
 		autoenrollScriptError bool
 		runScript             bool
 		sambaDirExists        bool
+		machineKrb5CCPath     bool
 
 		wantErr bool
 	}{
@@ -52,6 +53,7 @@ func TestApplyPolicy(t *testing.T) {
 		// Enroll cases
 		"Computer, configured to enroll":                         {entries: []entry.Entry{enrollEntry}, runScript: true},
 		"Computer, configured to enroll, advanced configuration": {entries: append(advancedConfigurationEntries, enrollEntry), runScript: true},
+		"Computer, configured to enroll, machine ticket cache":   {entries: []entry.Entry{enrollEntry}, machineKrb5CCPath: true, runScript: true},
 
 		// Unenroll cases
 		"Computer, configured to unenroll":          {entries: []entry.Entry{{Key: "autoenroll", Value: unenrollValue}}, runScript: true},
@@ -92,13 +94,16 @@ func TestApplyPolicy(t *testing.T) {
 			autoenrollCmdOutputFile := filepath.Join(tmpdir, "autoenroll-output")
 			autoenrollCmd := mockAutoenrollScript(t, autoenrollCmdOutputFile, tc.autoenrollScriptError)
 
-			m := certificate.New(
-				"example.com",
+			opts := []certificate.Option{
 				certificate.WithStateDir(filepath.Join(tmpdir, "statedir")),
 				certificate.WithRunDir(filepath.Join(tmpdir, "rundir")),
 				certificate.WithShareDir(filepath.Join(tmpdir, "sharedir")),
 				certificate.WithCertAutoenrollCmd(autoenrollCmd),
-			)
+			}
+			if tc.machineKrb5CCPath {
+				opts = append(opts, certificate.WithKrb5CCPath(filepath.Join(tmpdir, "machine_ccache")))
+			}
+			m := certificate.New("example.com", opts...)
 
 			err = m.ApplyPolicy(context.Background(), "keypress", !tc.isUser, !tc.isOffline, tc.entries)
 			if tc.wantErr {
@@ -0,0 +1,167 @@
+// Package cooldown limits how often the files managed by adsys are rewritten.
+//
+// When GPO versions flap, for instance during a domain controller replication issue, each refresh rewrites the
+// same files within minutes, which trips file integrity monitoring. The tracker records, in the state directory,
+// when each file was last written and its content. A set of files written within the cooldown is not rewritten as
+// long as the new content of each file is the one adsys last wrote, and is still the one on disk: the refresh still
+// runs, but nothing is replaced.
+//
+// Any change of content, and any file changed by someone else since adsys wrote it, is always written
+// immediately. A 0 cooldown disables the tracker.
+package cooldown
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+	"gopkg.in/yaml.v3"
+)
+
+// StateFileName is the name of the file, in the state directory, recording the last write of each file.
+const StateFileName = "cooldown.yaml"
+
+// write is the last write of a file by adsys.
+type write struct {
+	Time     time.Time `yaml:"time"`
+	Checksum string    `yaml:"checksum"`
+}
+
+// Tracker records the writes of the managed files to suppress the ones happening within the cooldown.
+type Tracker struct {
+	statePath string
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu     sync.Mutex
+	writes map[string]write
+}
+
+type options struct {
+	now func() time.Time
+}
+
+// Option reprents an optional function to change the tracker.
+type Option func(*options)
+
+// New returns a tracker storing its state in stateDir. A 0 cooldown never suppresses any write.
+func New(stateDir string, cooldown time.Duration, opts ...Option) *Tracker {
+	// defaults
+	args := options{
+		now: time.Now,
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Tracker{
+		statePath: filepath.Join(stateDir, StateFileName),
+		cooldown:  cooldown,
+		now:       args.now,
+	}
+}
+
+// Suppressed returns true if all files, mapped by their path to their new content, were written by adsys within
+// the cooldown with that same content, and were not changed since then, so that they should not be rewritten yet.
+// The files are decided together, so that they are never left inconsistent.
+func (t *Tracker) Suppressed(ctx context.Context, files map[string]string) bool {
+	if t.cooldown <= 0 || len(files) == 0 {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.load(); err != nil {
+		log.Warningf(ctx, "Can't load the last writes of the managed files, not applying the cooldown: %v", err)
+		return false
+	}
+
+	var paths []string
+	for path, content := range files {
+		w, ok := t.writes[path]
+		if !ok {
+			return false
+		}
+		since := t.now().Sub(w.Time)
+		if since >= t.cooldown || since < 0 {
+			return false
+		}
+		if checksum([]byte(content)) != w.Checksum {
+			return false
+		}
+		onDisk, err := os.ReadFile(path)
+		if err != nil || checksum(onDisk) != w.Checksum {
+			log.Debugf(ctx, "%s was changed since it was last written, rewriting it despite the cooldown", path)
+			return false
+		}
+		paths = append(paths, path)
+	}
+
+	slices.Sort(paths)
+	log.Infof(ctx, "Not rewriting %s: they were already written with the same content within the %s cooldown", strings.Join(paths, ", "), t.cooldown)
+	return true
+}
+
+// Written records that path was just written with content.
+func (t *Tracker) Written(path string, content []byte) (err error) {
+	if t.cooldown <= 0 {
+		return nil
+	}
+	defer decorate.OnError(&err, gotext.Get("can't record the write of %s", path))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.load(); err != nil {
+		return err
+	}
+	t.writes[path] = write{Time: t.now(), Checksum: checksum(content)}
+
+	d, err := yaml.Marshal(t.writes)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.statePath), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(t.statePath+".new", d, 0600); err != nil {
+		return err
+	}
+	return os.Rename(t.statePath+".new", t.statePath)
+}
+
+// load reads the state file once, as the tracker is the only one writing it.
+func (t *Tracker) load() error {
+	if t.writes != nil {
+		return nil
+	}
+
+	writes := make(map[string]write)
+	d, err := os.ReadFile(t.statePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := yaml.Unmarshal(d, &writes); err != nil {
+		return err
+	}
+	t.writes = writes
+	return nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,172 @@
+package cooldown_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/cooldown"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestSuppressed(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cooldown      time.Duration
+		elapsed       time.Duration
+		notWritten    bool
+		changedOnDisk bool
+		removedOnDisk bool
+		otherFile     bool
+		newContent    string
+		// secondFile is the state of a second file decided with the managed one: "written", "changed" or "new".
+		secondFile string
+
+		want bool
+	}{
+		"Write within the cooldown is suppressed":       {cooldown: time.Hour, elapsed: 10 * time.Minute, want: true},
+		"Write right after the first one is suppressed": {cooldown: time.Hour, want: true},
+		"Write of files all unchanged is suppressed":    {cooldown: time.Hour, elapsed: 10 * time.Minute, secondFile: "written", want: true},
+
+		"Write after the cooldown is not suppressed":              {cooldown: time.Hour, elapsed: time.Hour},
+		"Write of a file never written is not suppressed":         {cooldown: time.Hour, notWritten: true},
+		"Write of another file is not suppressed":                 {cooldown: time.Hour, otherFile: true},
+		"Write of a file changed on disk is not suppressed":       {cooldown: time.Hour, elapsed: 10 * time.Minute, changedOnDisk: true},
+		"Write of a file removed from disk is not suppressed":     {cooldown: time.Hour, elapsed: 10 * time.Minute, removedOnDisk: true},
+		"Write before the recorded one is not suppressed":         {cooldown: time.Hour, elapsed: -10 * time.Minute},
+		"Write of a new content is not suppressed":                {cooldown: time.Hour, elapsed: 10 * time.Minute, newContent: "new content"},
+		"Write of files with one changed is not suppressed":       {cooldown: time.Hour, elapsed: 10 * time.Minute, secondFile: "changed"},
+		"Write of files with one never written is not suppressed": {cooldown: time.Hour, elapsed: 10 * time.Minute, secondFile: "new"},
+		"No cooldown never suppresses writes":                     {elapsed: 10 * time.Minute},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			stateDir := filepath.Join(dir, "state")
+			path := filepath.Join(dir, "managed")
+			content := []byte("managed content")
+			require.NoError(t, os.WriteFile(path, content, 0600), "Setup: can't write managed file")
+
+			now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+			tracker := cooldown.New(stateDir, tc.cooldown, cooldown.WithTimeNow(func() time.Time { return now }))
+			if !tc.notWritten {
+				require.NoError(t, tracker.Written(path, content), "Setup: Written should not fail")
+			}
+
+			files := make(map[string]string)
+			if tc.secondFile != "" {
+				second := filepath.Join(dir, "second")
+				require.NoError(t, os.WriteFile(second, []byte("second content"), 0600), "Setup: can't write second file")
+				if tc.secondFile != "new" {
+					require.NoError(t, tracker.Written(second, []byte("second content")), "Setup: Written should not fail")
+				}
+				files[second] = "second content"
+				if tc.secondFile == "changed" {
+					files[second] = "second new content"
+				}
+			}
+
+			if tc.changedOnDisk {
+				require.NoError(t, os.WriteFile(path, []byte("local edit"), 0600), "Setup: can't change managed file")
+			}
+			if tc.removedOnDisk {
+				require.NoError(t, os.Remove(path), "Setup: can't remove managed file")
+			}
+			if tc.otherFile {
+				path = filepath.Join(dir, "other")
+				require.NoError(t, os.WriteFile(path, content, 0600), "Setup: can't write other file")
+			}
+			now = now.Add(tc.elapsed)
+
+			files[path] = string(content)
+			if tc.newContent != "" {
+				files[path] = tc.newContent
+			}
+
+			got := tracker.Suppressed(context.Background(), files)
+			require.Equal(t, tc.want, got, "Suppressed should return the expected result")
+		})
+	}
+}
+
+func TestSuppressedAcrossRestarts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, "state")
+	path := filepath.Join(dir, "managed")
+	content := []byte("managed content")
+	require.NoError(t, os.WriteFile(path, content, 0600), "Setup: can't write managed file")
+
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	timeNow := cooldown.WithTimeNow(func() time.Time { return now })
+
+	require.NoError(t, cooldown.New(stateDir, time.Hour, timeNow).Written(path, content), "Setup: Written should not fail")
+	now = now.Add(10 * time.Minute)
+
+	// A new tracker, as created when the daemon restarts, loads the last writes from the state directory.
+	require.True(t, cooldown.New(stateDir, time.Hour, timeNow).Suppressed(context.Background(), map[string]string{path: string(content)}),
+		"Write within the cooldown should be suppressed after a restart")
+}
+
+func TestWritten(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cooldown      time.Duration
+		existingState string
+		stateDirFile  bool
+
+		wantNoState bool
+		wantErr     bool
+	}{
+		"Write is recorded":                           {cooldown: time.Hour},
+		"Write is recorded with other recorded files": {cooldown: time.Hour, existingState: "other: {time: 2026-10-15T11:00:00Z, checksum: abc}"},
+		"No cooldown does not record writes":          {wantNoState: true},
+
+		"Error on invalid state":          {cooldown: time.Hour, existingState: "invalid: [", wantErr: true},
+		"Error on state dir being a file": {cooldown: time.Hour, stateDirFile: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			stateDir := t.TempDir()
+			if tc.existingState != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(stateDir, cooldown.StateFileName), []byte(tc.existingState), 0600),
+					"Setup: can't write existing state")
+			}
+			if tc.stateDirFile {
+				stateDir = filepath.Join(stateDir, "file")
+				require.NoError(t, os.WriteFile(stateDir, nil, 0600), "Setup: can't write file")
+			}
+
+			now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+			tracker := cooldown.New(stateDir, tc.cooldown, cooldown.WithTimeNow(func() time.Time { return now }))
+
+			err := tracker.Written("/etc/managed", []byte("managed content"))
+			if tc.wantErr {
+				require.Error(t, err, "Written should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "Written failed but shouldn't have")
+
+			if tc.wantNoState {
+				require.NoFileExists(t, filepath.Join(stateDir, cooldown.StateFileName), "No state should be written")
+				return
+			}
+			got, err := os.ReadFile(filepath.Join(stateDir, cooldown.StateFileName))
+			require.NoError(t, err, "State should be written")
+			want := testutils.LoadWithUpdateFromGolden(t, string(got))
+			require.Equal(t, want, string(got), "Written should record the expected state")
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package cooldown
+
+import "time"
+
+// WithTimeNow allows to mock the current time, from which the cooldown is computed.
+func WithTimeNow(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
@@ -33,9 +33,12 @@ import (
 	"io/fs"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -44,6 +47,7 @@ import (
 	"github.com/ubuntu/adsys/internal/consts"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/trace"
 	"github.com/ubuntu/adsys/internal/smbsafe"
 	"github.com/ubuntu/decorate"
 )
@@ -56,12 +60,68 @@ type Manager struct {
 	// dconfUpdateMu prevents running multiple dconf update processes in parallel.
 	dconfUpdateMu sync.Mutex
 
-	dconfDir string
+	dconfDir      string
+	profile       string
+	groupProfiles map[string][]string
+	userGroups    func(user string) ([]string, error)
+}
+
+type options struct {
+	profile       string
+	groupProfiles map[string][]string
+	userGroups    func(user string) ([]string, error)
+}
+
+// Option reprents an optional function to change the dconf manager.
+type Option func(*options)
+
+// WithProfile specifies an existing dconf profile, like "user", in which the machine database is layered.
+// Other entries of that profile are preserved.
+func WithProfile(name string) Option {
+	return func(o *options) {
+		o.profile = name
+	}
+}
+
+// WithGroupProfiles maps group names to the existing dconf system databases layered in the profile of their members.
+// The databases are stacked above the adsys ones so that the policy locks still apply.
+func WithGroupProfiles(profiles map[string][]string) Option {
+	return func(o *options) {
+		o.groupProfiles = profiles
+	}
+}
+
+// WithUserGroups overrides the default NSS lookup of the groups of a user.
+func WithUserGroups(f func(user string) ([]string, error)) Option {
+	return func(o *options) {
+		o.userGroups = f
+	}
 }
 
 // NewWithDconfDir creates a manager with a specific dconf directory.
-func NewWithDconfDir(dir string) *Manager {
-	return &Manager{dconfDir: dir}
+func NewWithDconfDir(dir string, opts ...Option) *Manager {
+	// defaults
+	args := options{
+		userGroups: lookupUserGroups,
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	// Group names are matched case insensitively, as AD does.
+	groupProfiles := make(map[string][]string)
+	for g, dbs := range args.groupProfiles {
+		g = strings.ToLower(g)
+		groupProfiles[g] = append(groupProfiles[g], dbs...)
+	}
+
+	return &Manager{
+		dconfDir:      dir,
+		profile:       args.profile,
+		groupProfiles: groupProfiles,
+		userGroups:    args.userGroups,
+	}
 }
 
 // ApplyPolicy generates a dconf computer or user policy based on a list of entries.
@@ -101,9 +161,16 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		}
 	}
 
-	// Only clean up user databases/profiles if there are no entries to apply.
+	var groupDBs []string
+	if !isComputer {
+		if groupDBs, err = m.groupDBs(objectName); err != nil {
+			return err
+		}
+	}
+
+	// Only clean up user databases/profiles if there are no entries nor group databases to apply.
 	// We don't clean up the machine database because we don't know if there's any user GPO depending on it.
-	if !isComputer && len(entries) == 0 {
+	if !isComputer && len(entries) == 0 && len(groupDBs) == 0 {
 		if err := os.RemoveAll(dbPath); err != nil {
 			return errors.New(gotext.Get("can't remove user dconf database directory: %v", err))
 		}
@@ -116,13 +183,27 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		return nil
 	}
 
+	// Layer the machine database in the configured shared profile
+	if isComputer && m.profile != "" {
+		if strings.ContainsRune(m.profile, os.PathSeparator) || m.profile == "." || m.profile == ".." {
+			return errors.New(gotext.Get("invalid dconf profile name %q", m.profile))
+		}
+		//nolint:gosec // G301 - Profile must be readable by everyone
+		if err := os.MkdirAll(profilesPath, 0755); err != nil {
+			return err
+		}
+		if err := layerMachineDB(ctx, filepath.Join(profilesPath, m.profile)); err != nil {
+			return err
+		}
+	}
+
 	// Create profiles for users only
 	if !isComputer {
 		//nolint:gosec // G301 - Profile must be readable by everyone
 		if err := os.MkdirAll(profilesPath, 0755); err != nil {
 			return err
 		}
-		if err := writeProfile(ctx, objectName, profilesPath); err != nil {
+		if err := writeProfile(ctx, objectName, profilesPath, groupDBs, m.allGroupDBs()); err != nil {
 			return err
 		}
 	}
@@ -137,8 +218,12 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		if !e.Disabled {
 			section := filepath.Dir(e.Key)
 
-			// normalize common user error cases and check gsettings schema signature match.
-			e.Value = normalizeValue(e.Meta, e.Value)
+			// merge the arrays of multiple GPOs, then normalize common user error cases and check gsettings
+			// schema signature match.
+			if e.Strategy == entry.StrategyAppend || e.Strategy == entry.StrategyUnion {
+				e.Value = mergeArrays(e)
+			}
+			e.Value = normalizeValue(e)
 			if err := checkSignature(e.Meta, e.Value); err != nil {
 				errMsgs = append(errMsgs, gotext.Get("- error on %s: %v", e.Key, err))
 				continue
@@ -204,7 +289,9 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 	smbsafe.WaitExec()
 	m.dconfUpdateMu.Lock()
 	// #nosec G204 - we control the input
-	out, errExec := exec.Command("dconf", "update", filepath.Join(dconfDir, "db")).CombinedOutput()
+	cmd := exec.CommandContext(ctx, "dconf", "update", filepath.Join(dconfDir, "db"))
+	trace.Command(ctx, "dconf", cmd.Args)
+	out, errExec := cmd.CombinedOutput()
 	m.dconfUpdateMu.Unlock()
 	smbsafe.DoneExec()
 	if errExec != nil {
@@ -233,10 +320,72 @@ func writeIfChanged(path string, content string) (done bool, err error) {
 	return true, nil
 }
 
+// groupDBs returns the system databases of the groups user is a member of, in the order of the group names.
+func (m *Manager) groupDBs(user string) (dbs []string, err error) {
+	if len(m.groupProfiles) == 0 {
+		return nil, nil
+	}
+	defer decorate.OnError(&err, gotext.Get("can't select dconf databases from the groups of %s", user))
+
+	groups, err := m.userGroups(user)
+	if err != nil {
+		return nil, err
+	}
+	for i, g := range groups {
+		groups[i] = strings.ToLower(g)
+	}
+	slices.Sort(groups)
+
+	for _, g := range slices.Compact(groups) {
+		for _, db := range m.groupProfiles[g] {
+			if db == "" || strings.ContainsRune(db, os.PathSeparator) || db == "." || db == ".." {
+				return nil, errors.New(gotext.Get("invalid dconf database name %q for group %q", db, g))
+			}
+			if slices.Contains(dbs, db) {
+				continue
+			}
+			dbs = append(dbs, db)
+		}
+	}
+	return dbs, nil
+}
+
+// allGroupDBs returns the system databases of every configured group, so that the ones of groups the user
+// left are removed from its profile.
+func (m *Manager) allGroupDBs() []string {
+	var dbs []string
+	for _, groupDBs := range m.groupProfiles {
+		dbs = append(dbs, groupDBs...)
+	}
+	return dbs
+}
+
+// lookupUserGroups returns the names of the groups of user, resolved through NSS.
+func lookupUserGroups(name string) ([]string, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	gids, err := u.GroupIds()
+	if err != nil {
+		return nil, err
+	}
+	var groups []string
+	for _, gid := range gids {
+		g, err := user.LookupGroupId(gid)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g.Name)
+	}
+	return groups, nil
+}
+
 // writeProfile creates or updates a dconf profile file.
 // The adsys system-db should always be the first system-db in the file to enforce their values
 // (upper system-db in the profile wins).
-func writeProfile(ctx context.Context, user, profilesPath string) (err error) {
+// The groupDBs are layered just above the adsys ones, and any other database of knownGroupDBs is removed.
+func writeProfile(ctx context.Context, user, profilesPath string, groupDBs, knownGroupDBs []string) (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't update user profile %s", profilesPath))
 
 	profilePath := filepath.Join(profilesPath, user)
@@ -245,25 +394,35 @@ func writeProfile(ctx context.Context, user, profilesPath string) (err error) {
 	adsysMachineDB := "system-db:machine"
 	adsysUserDB := fmt.Sprintf("system-db:%s", user)
 
+	var groupLines []string
+	for _, db := range groupDBs {
+		groupLines = append(groupLines, "system-db:"+db)
+	}
+
 	// Read existing content and create file if doesn’t exists
 	content, err := os.ReadFile(profilePath)
 	if err != nil {
 		if !errors.Is(err, fs.ErrNotExist) {
 			return err
 		}
+		out := append(append([]string{"user-db:user"}, groupLines...), adsysUserDB, adsysMachineDB)
 		// #nosec G306. This asset needs to be world-readable.
-		return os.WriteFile(profilePath, []byte(fmt.Sprintf("user-db:user\n%s\n%s", adsysUserDB, adsysMachineDB)), 0644)
+		return os.WriteFile(profilePath, []byte(strings.Join(out, "\n")), 0644)
 	}
 
 	// Read file to insert them at the end, removing duplicates
 	var out []string
 	for _, d := range bytes.Split(bytes.TrimSpace(content), []byte("\n")) {
-		// Add current line if it’s not an adsys one
+		// Add current line if it’s not an adsys or group one
 		if string(d) == adsysMachineDB || string(d) == adsysUserDB {
 			continue
 		}
+		if db, ok := strings.CutPrefix(string(d), "system-db:"); ok && slices.Contains(knownGroupDBs, db) {
+			continue
+		}
 		out = append(out, string(d))
 	}
+	out = append(out, groupLines...)
 	out = append(out, adsysUserDB, adsysMachineDB)
 
 	newContent := []byte(strings.Join(out, "\n"))
@@ -284,6 +443,56 @@ func writeProfile(ctx context.Context, user, profilesPath string) (err error) {
 	return nil
 }
 
+// layerMachineDB inserts the machine system-db in a shared dconf profile file.
+// Any other entry, like databases from other tools, is kept as is. An existing machine database line keeps
+// its position in the stack, otherwise it is appended as the lowest priority database.
+func layerMachineDB(ctx context.Context, profilePath string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't update dconf profile %s", profilePath))
+
+	log.Debugf(ctx, "Layer machine database in profile %s", profilePath)
+
+	adsysMachineDB := "system-db:machine"
+
+	content, err := os.ReadFile(profilePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var lines, out []string
+	if trimmed := strings.TrimSpace(string(content)); trimmed != "" {
+		lines = strings.Split(trimmed, "\n")
+	}
+	var found bool
+	for _, d := range lines {
+		if strings.TrimSpace(d) == adsysMachineDB {
+			// Remove any repetition
+			if found {
+				continue
+			}
+			found = true
+			d = adsysMachineDB
+		}
+		out = append(out, d)
+	}
+	if len(out) == 0 {
+		out = append(out, "user-db:user")
+	}
+	if !found {
+		out = append(out, adsysMachineDB)
+	}
+
+	newContent := strings.Join(out, "\n") + "\n"
+	if string(content) == newContent {
+		return nil
+	}
+
+	//nolint:gosec // G306 - This asset needs to be world-readable.
+	if err := os.WriteFile(profilePath+".adsys.new", []byte(newContent), 0644); err != nil {
+		return err
+	}
+	return os.Rename(profilePath+".adsys.new", profilePath)
+}
+
 // dconfNeedsUpdate will notify if we need to run dconf update for that binary database.
 // For now, it only checks its existence.
 func dconfNeedsUpdate(path string) bool {
@@ -295,14 +504,18 @@ func dconfNeedsUpdate(path string) bool {
 }
 
 // normalizeValue simplify user entry by handling common mistakes on key types.
-func normalizeValue(keyType, value string) string {
-	value = strings.TrimSpace(value)
-	switch keyType {
+func normalizeValue(e entry.Entry) string {
+	value := strings.TrimSpace(e.Value)
+	switch e.Meta {
 	case "s":
 		return quoteValue(value)
 	case "b":
-		return normalizeBoolean(value)
+		return normalizeBoolean(e)
 	case "i":
+		// DWORD values, like 0x2a, are converted to decimal.
+		if i, err := e.IntValue(); err == nil {
+			return strconv.FormatInt(i, 10)
+		}
 		return strings.ReplaceAll(strings.ReplaceAll(value, `"`, ""), "'", "")
 	case "as":
 		return quoteASVariant(value)
@@ -331,20 +544,55 @@ func quoteValue(s string) string {
 // The following is accepted, is case insensitive and spaces are trimmed:
 // y|yes|n|no
 // true|false
-// on|On|ON|off.
-func normalizeBoolean(v string) string {
-	lv := strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(strings.ToLower(v)), `"`, ""), "'", "")
-	switch lv {
-	case "y", "yes", "true", "on":
-		return "true"
-	case "n", "no", "false", "off":
-		return "false"
-	}
-	return v
+// on|On|ON|off
+// DWORD values, like 0 or 1.
+// Other values are returned as is.
+func normalizeBoolean(e entry.Entry) string {
+	b, err := e.BoolValue()
+	if err != nil {
+		return strings.TrimSpace(e.Value)
+	}
+	return strconv.FormatBool(b)
+}
+
+// mergeArrays returns the array of the elements of each line of the value of an array entry, as appended by the
+// append and union strategies from multiple GPOs. The union strategy keeps each element only once.
+// Other entries are returned as is.
+func mergeArrays(e entry.Entry) string {
+	var elements func(string) []string
+	switch e.Meta {
+	case "as":
+		elements = asVariantElements
+	case "ai":
+		elements = aiVariantElements
+	default:
+		return e.Value
+	}
+
+	var merged []string
+	for _, l := range strings.Split(e.Value, "\n") {
+		// Skip empty lines and arrays
+		if strings.Trim(l, " []") == "" {
+			continue
+		}
+		for _, elem := range elements(l) {
+			if e.Strategy == entry.StrategyUnion && slices.Contains(merged, elem) {
+				continue
+			}
+			merged = append(merged, elem)
+		}
+	}
+
+	return fmt.Sprintf("[%s]", strings.Join(merged, ", "))
 }
 
 // quoteASVariant returns a variant array of string properly quoted and separated.
 func quoteASVariant(v string) string {
+	return fmt.Sprintf("[%s]", strings.Join(asVariantElements(v), ", "))
+}
+
+// asVariantElements returns the properly quoted elements of a variant array of string.
+func asVariantElements(v string) []string {
 	v = strings.TrimRight(strings.TrimLeft(v, " ["), " ]")
 
 	// Remove any empty \n elements
@@ -368,7 +616,7 @@ func quoteASVariant(v string) string {
 		for _, e := range t {
 			r = append(r, quoteValue(e))
 		}
-		return fmt.Sprintf("[%s]", strings.Join(r, ", "))
+		return r
 	}
 
 	// Unquoted string
@@ -382,11 +630,16 @@ func quoteASVariant(v string) string {
 		r = append(r, quoteValue(e))
 	}
 
-	return fmt.Sprintf("[%s]", strings.Join(r, ", "))
+	return r
 }
 
 // normalizeAIVariant returns a variant array of int with proper separator.
 func normalizeAIVariant(v string) string {
+	return fmt.Sprintf("[%s]", strings.Join(aiVariantElements(v), ", "))
+}
+
+// aiVariantElements returns the elements of a variant array of int.
+func aiVariantElements(v string) []string {
 	v = strings.TrimRight(strings.TrimLeft(v, " ["), " ]")
 
 	// Remove any empty \n elements
@@ -401,9 +654,9 @@ func normalizeAIVariant(v string) string {
 
 	// normalize separator spaces
 	v = strings.Join(elems, ",")
-	v = strings.ReplaceAll(strings.ReplaceAll(v, " ", ""), ",", ", ")
+	v = strings.ReplaceAll(v, " ", "")
 
-	return fmt.Sprintf("[%s]", v)
+	return strings.Split(v, ",")
 }
 
 // splitOnNonEscaped splits v by sep, only if sep is not escaped.
@@ -2,6 +2,7 @@ package dconf_test
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -20,6 +21,10 @@ func TestApplyPolicy(t *testing.T) {
 		isComputer       bool
 		entries          []entry.Entry
 		existingDconfDir string
+		profile          string
+		groups           []string
+		groupsErr        bool
+		groupProfiles    map[string][]string
 
 		wantErr bool
 	}{
@@ -142,6 +147,23 @@ func TestApplyPolicy(t *testing.T) {
 			{Key: "com/ubuntu/category/key-ai", Value: "1,2\n3\n", Meta: "ai"},
 		}},
 
+		// Arrays merged from multiple GPOs
+		"Appended as of multiple GPOs": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-as", Value: "['firefox.desktop', 'thunderbird.desktop']\n'thunderbird.desktop', 'org.gnome.Nautilus.desktop'", Meta: "as", Strategy: entry.StrategyAppend},
+		}},
+		"Union as of multiple GPOs": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-as", Value: "['firefox.desktop', 'thunderbird.desktop']\n'thunderbird.desktop', 'org.gnome.Nautilus.desktop'\n[]", Meta: "as", Strategy: entry.StrategyUnion},
+		}},
+		"Appended ai of multiple GPOs": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-ai", Value: "[1, 2]\n2,3", Meta: "ai", Strategy: entry.StrategyAppend},
+		}},
+		"Union ai of multiple GPOs": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-ai", Value: "[1, 2]\n2,3", Meta: "ai", Strategy: entry.StrategyUnion},
+		}},
+		"Union of other types is not merged": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "onekey-s", Meta: "s", Strategy: entry.StrategyUnion},
+		}},
+
 		// Profiles tests
 		"Update existing correct profile stays unchanged": {
 			existingDconfDir: "existing-user"},
@@ -158,6 +180,27 @@ func TestApplyPolicy(t *testing.T) {
 		"Update existing profile eliminates adsys DB repetitions": {
 			existingDconfDir: "existing-user-adsysdb-repetitions"},
 
+		// Shared profile tests
+		"Machine db is layered in shared profile preserving other entries": {
+			isComputer: true, profile: "user", existingDconfDir: "machine-with-shared-profile"},
+		"Machine db keeps its position in shared profile": {
+			isComputer: true, profile: "user", existingDconfDir: "machine-with-adsysdb-in-shared-profile"},
+		"Machine db creates missing shared profile": {
+			isComputer: true, profile: "user", existingDconfDir: "-"},
+		"User does not update shared profile": {
+			profile: "user", existingDconfDir: "machine-with-shared-profile"},
+
+		// Group profiles tests
+		"User in group A gets profile A":                       {groups: []string{"ubuntu", "groupa"}},
+		"User in group B gets profile B":                       {groups: []string{"GroupB"}},
+		"User in both groups gets both profiles once":          {groups: []string{"groupb", "groupa"}},
+		"User in no configured group gets the default profile": {groups: []string{"ubuntu"}},
+		"User without policy in group A gets profile A":        {entries: []entry.Entry{}, groups: []string{"groupa"}},
+		"User leaving a group loses its database":              {groups: []string{"groupa"}, existingDconfDir: "existing-user-with-group-db"},
+		"User without policy leaving a group loses its profile": {entries: []entry.Entry{},
+			groups: []string{"ubuntu"}, existingDconfDir: "existing-user-with-group-db"},
+		"Machine ignores group profiles": {isComputer: true, groups: []string{"groupa"}},
+
 		// non adsys content
 		"Do not update other files from db": {entries: []entry.Entry{
 			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-thirdvalue'", Meta: "s"}},
@@ -186,6 +229,9 @@ func TestApplyPolicy(t *testing.T) {
 		"Error on empty meta": {entries: []entry.Entry{
 			{Key: "com/ubuntu/category/key-something", Value: "value", Meta: ""},
 		}, wantErr: true},
+		"Error on invalid shared profile name": {isComputer: true, profile: "../user", wantErr: true},
+		"Error on user groups lookup failure":  {groupsErr: true, wantErr: true},
+		"Error on invalid group database name": {groups: []string{"groupa"}, groupProfiles: map[string][]string{"groupa": {"../profile-a"}}, wantErr: true},
 	}
 
 	for name, tc := range tests {
@@ -210,7 +256,23 @@ func TestApplyPolicy(t *testing.T) {
 					"Setup: can't create initial dconf directory")
 			}
 
-			m := dconf.NewWithDconfDir(dconfDir)
+			var opts []dconf.Option
+			if tc.profile != "" {
+				opts = append(opts, dconf.WithProfile(tc.profile))
+			}
+			if tc.groups != nil || tc.groupsErr {
+				if tc.groupProfiles == nil {
+					tc.groupProfiles = map[string][]string{"GroupA": {"profile-a"}, "groupb": {"profile-b", "common"}, "groupc": {"common"}}
+				}
+				opts = append(opts, dconf.WithGroupProfiles(tc.groupProfiles), dconf.WithUserGroups(func(user string) ([]string, error) {
+					if tc.groupsErr {
+						return nil, errors.New("lookup error")
+					}
+					require.Equal(t, "ubuntu", user, "Setup: groups should be looked up for the user the policy applies to")
+					return tc.groups, nil
+				}))
+			}
+			m := dconf.NewWithDconfDir(dconfDir, opts...)
 			err := m.ApplyPolicy(context.Background(), "ubuntu", tc.isComputer, tc.entries)
 			if tc.wantErr {
 				require.NotNil(t, err, "ApplyPolicy should have failed but didn't")
@@ -218,6 +280,12 @@ func TestApplyPolicy(t *testing.T) {
 			}
 			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
 
+			// Profiles should be stable across multiple applies
+			if tc.profile != "" {
+				err = m.ApplyPolicy(context.Background(), "ubuntu", tc.isComputer, tc.entries)
+				require.NoError(t, err, "Second ApplyPolicy failed but shouldn't have")
+			}
+
 			testutils.CompareTreesWithFiltering(t, dconfDir, testutils.GoldenPath(t), testutils.UpdateEnabled())
 		})
 	}
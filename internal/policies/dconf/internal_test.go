@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/ubuntu/adsys/internal/policies/entry"
 )
 
 func TestNormalize(t *testing.T) {
@@ -42,8 +43,20 @@ func TestNormalize(t *testing.T) {
 		"no transformed to boolean":       {keyType: "b", value: "no", want: "false"},
 		"n transformed to boolean":        {keyType: "b", value: "n", want: "false"},
 		"off transformed to boolean":      {keyType: "b", value: "off", want: "false"},
+		"DWORD 1 transformed to boolean":  {keyType: "b", value: "1", want: "true"},
+		"DWORD 0 transformed to boolean":  {keyType: "b", value: "0", want: "false"},
+		"0x1 transformed to boolean":      {keyType: "b", value: "0x00000001", want: "true"},
+		"dword:0 transformed to boolean":  {keyType: "b", value: "dword:00000000", want: "false"},
 		"non supported is reported as is": {keyType: "b", value: "nonboolean", want: "nonboolean"},
 
+		// int cases
+		"simple int":                 {keyType: "i", value: "42", want: "42"},
+		"negative int":               {keyType: "i", value: "-42", want: "-42"},
+		"quoted int is unquoted":     {keyType: "i", value: "'42'", want: "42"},
+		"hex DWORD converted to int": {keyType: "i", value: "0x2a", want: "42"},
+		"reg DWORD converted to int": {keyType: "i", value: "dword:0000002a", want: "42"},
+		"non int is reported as is":  {keyType: "i", value: "nonint", want: "nonint"},
+
 		// as cases
 		"simple unquoted as":                               {keyType: "as", value: "[aa, bb, cc]", want: "['aa', 'bb', 'cc']"},
 		"simple quoted as":                                 {keyType: "as", value: "['aa', 'bb', 'cc']", want: "['aa', 'bb', 'cc']"},
@@ -107,7 +120,7 @@ func TestNormalize(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			got := normalizeValue(tc.keyType, tc.value)
+			got := normalizeValue(entry.Entry{Meta: tc.keyType, Value: tc.value})
 			assert.Equal(t, tc.want, got, "normalizeValue returned expected value")
 		})
 	}
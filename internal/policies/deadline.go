@@ -0,0 +1,101 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"golang.org/x/sync/errgroup"
+)
+
+// runningManagers tracks the policy managers applying their policy during a refresh.
+type runningManagers struct {
+	mu      sync.Mutex
+	running map[string]int
+	// atCancel are the policy managers which returned after the refresh was cancelled.
+	atCancel map[string]struct{}
+}
+
+type runningManagersKey struct{}
+
+// withRunningManagers returns a context tracking the policy managers applying their policy in r.
+func withRunningManagers(ctx context.Context, r *runningManagers) context.Context {
+	return context.WithValue(ctx, runningManagersKey{}, r)
+}
+
+// startManager marks the manager of rule as running in the tracker of ctx, if any. The returned function marks it
+// as done.
+func startManager(ctx context.Context, rule string) (done func()) {
+	r, ok := ctx.Value(runningManagersKey{}).(*runningManagers)
+	if !ok {
+		return func() {}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running == nil {
+		r.running = make(map[string]int)
+	}
+	r.running[rule]++
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.running[rule]--
+		if r.running[rule] == 0 {
+			delete(r.running, rule)
+		}
+		// The manager was still running when the refresh was cancelled.
+		if ctx.Err() != nil {
+			if r.atCancel == nil {
+				r.atCancel = make(map[string]struct{})
+			}
+			r.atCancel[rule] = struct{}{}
+		}
+	}
+}
+
+// err returns an error listing the policy managers which were running when ctx was cancelled.
+func (r *runningManagers) err(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var rules []string
+	for rule := range r.running {
+		rules = append(rules, rule)
+	}
+	for rule := range r.atCancel {
+		if _, ok := r.running[rule]; !ok {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return errors.New(gotext.Get("policy refresh cancelled: %v", context.Cause(ctx)))
+	}
+	slices.Sort(rules)
+	return errors.New(gotext.Get("policy refresh cancelled while applying %s: %v", strings.Join(rules, ", "), context.Cause(ctx)))
+}
+
+// waitManagers waits for the policy managers of g to return. If ctx is cancelled first, for instance when the refresh
+// deadline is exceeded, the returned error lists the managers which were still running. They are still waited for,
+// so that none writes past the end of the refresh, concurrently with the next one of the object.
+func waitManagers(ctx context.Context, g *errgroup.Group, r *runningManagers) error {
+	done := make(chan error, 1)
+	go func() { done <- g.Wait() }()
+
+	select {
+	case err := <-done:
+		if ctx.Err() != nil {
+			return errors.Join(r.err(ctx), err)
+		}
+		return err
+	case <-ctx.Done():
+		cancelErr := r.err(ctx)
+		log.Warning(ctx, gotext.Get("Waiting for the policy managers to return: %v", cancelErr))
+		return errors.Join(cancelErr, <-done)
+	}
+}
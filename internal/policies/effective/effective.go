@@ -0,0 +1,117 @@
+// Package effective builds a declarative document of the policy applied on the machine, so that it can be
+// archived and compared over time.
+//
+// The document is built from the rules of the last applied policy, once merged between GPOs and filtered
+// by their conditions, and from the privilege configuration written on disk. It only lists the enabled
+// settings. All the sections are always present and their content is sorted, so that two documents only
+// differ when the applied policy does.
+package effective
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is the effective policy of the machine.
+type Policy struct {
+	// Privilege is the privilege configuration of the machine.
+	Privilege Privilege `yaml:"privilege"`
+	// Proxy maps the proxy settings, like "http" or "no-proxy", to their value.
+	Proxy map[string]string `yaml:"proxy"`
+	// Dconf maps the dconf keys of the machine database to their value.
+	Dconf map[string]string `yaml:"dconf"`
+	// Mounts are the system shares mounted on the machine.
+	Mounts []string `yaml:"mounts"`
+}
+
+// Privilege is the privilege configuration of the machine.
+type Privilege struct {
+	// AdminIdentities are the polkit identities of the administrators of the machine.
+	AdminIdentities []string `yaml:"admin-identities"`
+}
+
+// New returns the effective policy from the applied rules, mapped by policy type, and the polkit
+// administrator identities of the machine.
+func New(rules map[string][]entry.Entry, adminIdentities []string) Policy {
+	p := Policy{
+		Privilege: Privilege{AdminIdentities: []string{}},
+		Proxy:     make(map[string]string),
+		Dconf:     make(map[string]string),
+		Mounts:    []string{},
+	}
+
+	for _, id := range adminIdentities {
+		if id = strings.TrimSpace(id); id != "" {
+			p.Privilege.AdminIdentities = append(p.Privilege.AdminIdentities, id)
+		}
+	}
+	slices.Sort(p.Privilege.AdminIdentities)
+	p.Privilege.AdminIdentities = slices.Compact(p.Privilege.AdminIdentities)
+
+	for _, e := range rules["proxy"] {
+		if e.Disabled {
+			continue
+		}
+		p.Proxy[e.Key[strings.LastIndex(e.Key, "/")+1:]] = strings.TrimSpace(e.Value)
+	}
+
+	for _, e := range rules["dconf"] {
+		if e.Disabled {
+			continue
+		}
+		p.Dconf[e.Key] = strings.TrimRight(e.Value, "\n")
+	}
+
+	for _, e := range rules["mount"] {
+		if e.Disabled || e.Key != "system-mounts" {
+			continue
+		}
+		for _, s := range strings.Split(e.Value, "\n") {
+			if s = strings.TrimSpace(s); s != "" {
+				p.Mounts = append(p.Mounts, s)
+			}
+		}
+	}
+	slices.Sort(p.Mounts)
+	p.Mounts = slices.Compact(p.Mounts)
+
+	return p
+}
+
+// Simulation is the policy a computer would get once placed in another organizational unit.
+type Simulation struct {
+	// GPOs are the names of the GPOs the computer would get, in order of priority.
+	GPOs []string `yaml:"gpos"`
+	// Policy is the resulting effective policy.
+	Policy `yaml:",inline"`
+}
+
+// NewSimulation returns the simulated policy from the names of the GPOs, in order of priority, the rules once
+// merged between them, and the polkit administrator identities they would set.
+func NewSimulation(gpos []string, rules map[string][]entry.Entry, adminIdentities []string) Simulation {
+	if gpos == nil {
+		gpos = []string{}
+	}
+	return Simulation{GPOs: gpos, Policy: New(rules, adminIdentities)}
+}
+
+// YAML returns the YAML document of the effective policy.
+func (p Policy) YAML() (string, error) {
+	d, err := yaml.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	return string(d), nil
+}
+
+// YAML returns the YAML document of the simulated policy.
+func (s Simulation) YAML() (string, error) {
+	d, err := yaml.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(d), nil
+}
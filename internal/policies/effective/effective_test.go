@@ -0,0 +1,134 @@
+package effective_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/effective"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/testutils"
+	"gopkg.in/yaml.v3"
+)
+
+func TestYAML(t *testing.T) {
+	t.Parallel()
+
+	representativeRules := map[string][]entry.Entry{
+		"privilege": {{Key: "client-admins", Value: "alice@example.com"}},
+		"proxy": {
+			{Key: "proxy/http", Value: "http://proxy.example.com:3128"},
+			{Key: "proxy/https", Value: "http://proxy.example.com:3128", Disabled: true},
+			{Key: "proxy/no-proxy", Value: "localhost,127.0.0.1,::1\n"},
+		},
+		"dconf": {
+			{Key: "org/gnome/desktop/background/picture-uri", Value: "'file:///usr/share/backgrounds/company.png'", Meta: "s"},
+			{Key: "org/gnome/desktop/interface/clock-format", Disabled: true, Meta: "s"},
+			{Key: "org/gnome/shell/favorite-apps", Value: "['firefox.desktop',\n'org.gnome.Nautilus.desktop']\n", Meta: "as"},
+		},
+		"mount": {
+			{Key: "system-mounts", Value: "smb://example.com/shared\n\n[krb5]nfs://example.com/home\nsmb://example.com/shared\n"},
+			{Key: "user-mounts", Value: "smb://example.com/alice"},
+		},
+		"scripts": {{Key: "startup", Value: "script.sh"}},
+	}
+	representativeIdentities := []string{"unix-user:alice@example.com", "unix-group:sudo", "", "unix-group:admin", "unix-group:sudo"}
+
+	tests := map[string]struct {
+		rules           map[string][]entry.Entry
+		adminIdentities []string
+	}{
+		"Representative policy":        {rules: representativeRules, adminIdentities: representativeIdentities},
+		"Only privilege identities":    {adminIdentities: representativeIdentities},
+		"Disabled entries are skipped": {rules: map[string][]entry.Entry{"proxy": {{Key: "proxy/auto", Disabled: true}}, "mount": {{Key: "system-mounts", Value: "smb://example.com/shared", Disabled: true}}}},
+		"Empty policy":                 {},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := effective.New(tc.rules, tc.adminIdentities).YAML()
+			require.NoError(t, err, "YAML should not fail")
+
+			// All sections are present, with their expected type, whatever the policy is.
+			var schema struct {
+				Privilege struct {
+					AdminIdentities []string `yaml:"admin-identities"`
+				} `yaml:"privilege"`
+				Proxy  map[string]string `yaml:"proxy"`
+				Dconf  map[string]string `yaml:"dconf"`
+				Mounts []string          `yaml:"mounts"`
+			}
+			dec := yaml.NewDecoder(strings.NewReader(got))
+			dec.KnownFields(true)
+			require.NoError(t, dec.Decode(&schema), "YAML document should only contain the expected fields")
+			require.NotNil(t, schema.Privilege.AdminIdentities, "privilege.admin-identities should always be present")
+			require.NotNil(t, schema.Proxy, "proxy should always be present")
+			require.NotNil(t, schema.Dconf, "dconf should always be present")
+			require.NotNil(t, schema.Mounts, "mounts should always be present")
+
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "YAML should return the expected document")
+		})
+	}
+}
+
+func TestYAMLIsStable(t *testing.T) {
+	t.Parallel()
+
+	rules := map[string][]entry.Entry{
+		"proxy": {{Key: "proxy/http", Value: "http://proxy.example.com:3128"}, {Key: "proxy/auto", Value: "http://example.com/proxy.pac"}},
+		"dconf": {{Key: "org/gnome/b", Value: "true"}, {Key: "org/gnome/a", Value: "false"}},
+		"mount": {{Key: "system-mounts", Value: "smb://example.com/b\nsmb://example.com/a"}},
+	}
+	reordered := map[string][]entry.Entry{
+		"mount": {{Key: "system-mounts", Value: "smb://example.com/a\nsmb://example.com/b"}},
+		"dconf": {{Key: "org/gnome/a", Value: "false"}, {Key: "org/gnome/b", Value: "true"}},
+		"proxy": {{Key: "proxy/auto", Value: "http://example.com/proxy.pac"}, {Key: "proxy/http", Value: "http://proxy.example.com:3128"}},
+	}
+
+	want, err := effective.New(rules, []string{"unix-user:bob", "unix-user:alice"}).YAML()
+	require.NoError(t, err, "YAML should not fail")
+	got, err := effective.New(reordered, []string{"unix-user:alice", "unix-user:bob"}).YAML()
+	require.NoError(t, err, "YAML should not fail")
+
+	require.Equal(t, want, got, "YAML document should not depend on the order of the policy")
+}
+
+func TestSimulationYAML(t *testing.T) {
+	t.Parallel()
+
+	rules := map[string][]entry.Entry{
+		"proxy": {{Key: "proxy/http", Value: "http://proxy.example.com:3128"}},
+		"dconf": {{Key: "org/gnome/desktop/interface/clock-format", Value: "'24h'", Meta: "s"}},
+	}
+
+	tests := map[string]struct {
+		gpos []string
+	}{
+		"GPOs are listed in order of priority": {gpos: []string{"RnD Computers", "Default Domain Policy"}},
+		"No GPO":                               {},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := effective.NewSimulation(tc.gpos, rules, []string{"unix-group:sudo"}).YAML()
+			require.NoError(t, err, "YAML should not fail")
+
+			var schema struct {
+				GPOs             []string `yaml:"gpos"`
+				effective.Policy `yaml:",inline"`
+			}
+			dec := yaml.NewDecoder(strings.NewReader(got))
+			dec.KnownFields(true)
+			require.NoError(t, dec.Decode(&schema), "YAML document should only contain the expected fields")
+			require.NotNil(t, schema.GPOs, "gpos should always be present")
+
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "YAML should return the expected document")
+		})
+	}
+}
@@ -2,6 +2,14 @@
 // every information to apply.
 package entry
 
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+)
+
 // Entry represents a key/value based policy (dconf, apparmor, ...) entry.
 type Entry struct {
 	// Key is the relative path to setting. Ex: Software/Ubuntu/User/dconf/wallpaper/path outside of GPO, and then
@@ -13,6 +21,9 @@ type Entry struct {
 	// Strategy are overlay rules for the same keys between multiple GPOs.
 	// Default (empty or unknown value) means "override".
 	Strategy string `yaml:",omitempty"`
+	// Condition is an expression on the machine facts, like "chassis==laptop". The entry is only applied if the
+	// condition is met. Empty means always applied.
+	Condition string `yaml:",omitempty"`
 	// Err is set if there was an error parsing the entry. It is ignored if the
 	// underlying key is not supported by adsys.
 	Err error `yaml:"-"`
@@ -21,9 +32,82 @@ type Entry struct {
 const (
 	// StrategyOverride is the default strategy.
 	StrategyOverride = "override"
+	// StrategyReplace is the same strategy as override.
+	StrategyReplace = "replace"
 	// StrategyAppend is the strategy to append a value to an existing one.
 	// append means from a GPO standpoint that the further GPO value is listed before closest GPO
 	// (and then, enforced GPO in reverse order).
 	StrategyAppend = "append"
+	// StrategyUnion is the strategy to append a value to an existing one, as append, without the lines which are
+	// already listed.
+	StrategyUnion = "union"
 	// This can be extended to support prepend but it is implemented yet as there is no real world cases.
 )
+
+// BoolValue returns the value of the entry as a boolean.
+// DWORD values, in any form accepted by IntValue, are true if they are not 0. true/false, yes/no, y/n and on/off
+// are accepted too, case insensitively.
+func (e Entry) BoolValue() (bool, error) {
+	v := strings.ToLower(trimValue(e.Value))
+	switch v {
+	case "y", "yes", "true", "on":
+		return true, nil
+	case "n", "no", "false", "off":
+		return false, nil
+	}
+
+	i, err := parseInt(v)
+	if err != nil {
+		return false, errors.New(gotext.Get("%q is not a boolean", e.Value))
+	}
+	return i != 0, nil
+}
+
+// IntValue returns the value of the entry as an integer.
+// Decimal values, like 42 or -1, and DWORD hexadecimal values, like 0x2a or dword:0000002a as written in .reg
+// files, are accepted.
+func (e Entry) IntValue() (int64, error) {
+	i, err := parseInt(trimValue(e.Value))
+	if err != nil {
+		return 0, errors.New(gotext.Get("%q is not an integer", e.Value))
+	}
+	return i, nil
+}
+
+// EnumValue returns the name of the value of the entry in values, mapping DWORD values to their names.
+// A value which is already one of the names is returned as is.
+func (e Entry) EnumValue(values map[int64]string) (string, error) {
+	v := trimValue(e.Value)
+	for _, name := range values {
+		if v == name {
+			return name, nil
+		}
+	}
+
+	i, err := parseInt(v)
+	if err != nil {
+		return "", errors.New(gotext.Get("%q is not a valid value", e.Value))
+	}
+	name, ok := values[i]
+	if !ok {
+		return "", errors.New(gotext.Get("%d is not a valid value", i))
+	}
+	return name, nil
+}
+
+// trimValue removes the spaces and quotes around v.
+func trimValue(v string) string {
+	return strings.Trim(strings.TrimSpace(v), `'"`)
+}
+
+// parseInt parses a decimal value, or a DWORD hexadecimal one prefixed with 0x or dword:.
+func parseInt(v string) (int64, error) {
+	lv := strings.ToLower(v)
+	for _, prefix := range []string{"0x", "dword:"} {
+		if hex, ok := strings.CutPrefix(lv, prefix); ok {
+			i, err := strconv.ParseUint(hex, 16, 32)
+			return int64(i), err
+		}
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
@@ -0,0 +1,133 @@
+package entry_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+)
+
+func TestBoolValue(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		value string
+
+		want    bool
+		wantErr bool
+	}{
+		"DWORD 1 is true":                    {value: "1", want: true},
+		"DWORD 0 is false":                   {value: "0", want: false},
+		"Other decimal DWORD is true":        {value: "42", want: true},
+		"Hexadecimal DWORD 1 is true":        {value: "0x00000001", want: true},
+		"Hexadecimal DWORD 0 is false":       {value: "0x0", want: false},
+		"Reg file DWORD 1 is true":           {value: "dword:00000001", want: true},
+		"Reg file DWORD 0 is false":          {value: "dword:00000000", want: false},
+		"true is true":                       {value: "true", want: true},
+		"false is false":                     {value: "false", want: false},
+		"yes is true":                        {value: "yes", want: true},
+		"Boolean is case insensitive":        {value: "ON", want: true},
+		"Spaces and quotes are trimmed":      {value: " '1' ", want: true},
+		"Double quotes are trimmed":          {value: ` "off" `, want: false},
+		"Hexadecimal prefix case is ignored": {value: "0X1", want: true},
+
+		"Error on empty value":                   {value: "", wantErr: true},
+		"Error on non boolean value":             {value: "maybe", wantErr: true},
+		"Error on invalid hexadecimal value":     {value: "0xzz", wantErr: true},
+		"Error on hexadecimal larger than DWORD": {value: "0x100000000", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := entry.Entry{Value: tc.value}.BoolValue()
+			if tc.wantErr {
+				require.Error(t, err, "BoolValue should have returned an error but didn't")
+				return
+			}
+			require.NoError(t, err, "BoolValue should not have returned an error but did")
+			require.Equal(t, tc.want, got, "BoolValue returned an unexpected value")
+		})
+	}
+}
+
+func TestIntValue(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		value string
+
+		want    int64
+		wantErr bool
+	}{
+		"DWORD 0":                       {value: "0", want: 0},
+		"DWORD 1":                       {value: "1", want: 1},
+		"Decimal DWORD":                 {value: "42", want: 42},
+		"Largest decimal DWORD":         {value: "4294967295", want: 4294967295},
+		"Negative decimal":              {value: "-5", want: -5},
+		"Hexadecimal DWORD":             {value: "0x2a", want: 42},
+		"Uppercase hexadecimal DWORD":   {value: "0X2A", want: 42},
+		"Largest hexadecimal DWORD":     {value: "0xffffffff", want: 4294967295},
+		"Reg file DWORD":                {value: "dword:0000002a", want: 42},
+		"Spaces and quotes are trimmed": {value: " '42' ", want: 42},
+
+		"Error on empty value":                   {value: "", wantErr: true},
+		"Error on non integer value":             {value: "forty-two", wantErr: true},
+		"Error on decimal with fraction":         {value: "4.2", wantErr: true},
+		"Error on invalid hexadecimal value":     {value: "0x2g", wantErr: true},
+		"Error on hexadecimal larger than DWORD": {value: "dword:100000000", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := entry.Entry{Value: tc.value}.IntValue()
+			if tc.wantErr {
+				require.Error(t, err, "IntValue should have returned an error but didn't")
+				return
+			}
+			require.NoError(t, err, "IntValue should not have returned an error but did")
+			require.Equal(t, tc.want, got, "IntValue returned an unexpected value")
+		})
+	}
+}
+
+func TestEnumValue(t *testing.T) {
+	t.Parallel()
+
+	values := map[int64]string{0: "none", 1: "wallpaper", 2: "centered"}
+
+	tests := map[string]struct {
+		value string
+
+		want    string
+		wantErr bool
+	}{
+		"DWORD 0 is mapped":           {value: "0", want: "none"},
+		"DWORD 1 is mapped":           {value: "1", want: "wallpaper"},
+		"Hexadecimal DWORD is mapped": {value: "0x2", want: "centered"},
+		"Reg file DWORD is mapped":    {value: "dword:00000002", want: "centered"},
+		"Name is returned as is":      {value: "wallpaper", want: "wallpaper"},
+		"Quoted name is unquoted":     {value: "'centered'", want: "centered"},
+
+		"Error on unknown DWORD": {value: "3", wantErr: true},
+		"Error on unknown name":  {value: "zoom", wantErr: true},
+		"Error on empty value":   {value: "", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := entry.Entry{Value: tc.value}.EnumValue(values)
+			if tc.wantErr {
+				require.Error(t, err, "EnumValue should have returned an error but didn't")
+				return
+			}
+			require.NoError(t, err, "EnumValue should not have returned an error but did")
+			require.Equal(t, tc.want, got, "EnumValue returned an unexpected value")
+		})
+	}
+}
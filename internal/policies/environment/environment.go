@@ -0,0 +1,159 @@
+// Package environment provides a manager to set environment variables in user sessions.
+//
+// The policy is only supported on computers. The environment variables of the
+// environment-variables entry, one "KEY=value" assignment per line, are written
+// to a file managed by adsys in the environment.d configuration directory, which
+// is read by the systemd user manager when the sessions start.
+//
+// Each key is validated before writing anything. Values are written literally,
+// without their surrounding quotes if any: they are quoted and the characters
+// which would be interpreted by environment.d, like $ for variable expansion,
+// are escaped.
+//
+// The file is only written when its content changed. If there are no variables
+// to set, the managed file is removed. Either way, the users have to log in again
+// for the change to take effect, which is reported as a relogin hint.
+package environment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/restart"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+	"github.com/ubuntu/decorate"
+)
+
+// environmentFileName is the name of the file, in the environment.d directory, managed by adsys.
+// It is ordered late so that its variables override the ones set by the distribution and other packages.
+const environmentFileName = "90adsys-env.conf"
+
+// keyRe matches the valid environment variable names.
+var keyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// valueEscaper escapes the characters interpreted by environment.d in double quoted values.
+var valueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`")
+
+// Manager sets the environment variables of the environment policy.
+type Manager struct {
+	environmentDir string
+}
+
+// New creates a manager with a specific environment.d configuration directory.
+func New(environmentDir string) *Manager {
+	return &Manager{
+		environmentDir: environmentDir,
+	}
+}
+
+// ApplyPolicy writes the environment variables of the policy to the managed environment.d file, or removes it
+// if there are none.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply environment policy to %s", objectName))
+
+	// Environment policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	environmentPath := filepath.Join(m.environmentDir, environmentFileName)
+
+	var content string
+	for _, e := range entries {
+		if e.Key != "environment-variables" {
+			ignored.RecordUnsupportedKey(ctx, "environment", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled {
+			continue
+		}
+		if content, err = parseVariables(e.Value); err != nil {
+			return err
+		}
+	}
+
+	if content == "" {
+		log.Debug(ctx, gotext.Get("Removing environment configuration %s", environmentPath))
+		if removed, err := tamper.Remove(ctx, "environment", environmentPath); err != nil || !removed {
+			return err
+		}
+		restart.Require(ctx, restart.Hint{Rule: "environment", Kind: restart.Relogin, Details: gotext.Get("environment variables removed")})
+		return nil
+	}
+
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(m.environmentDir, 0755); err != nil {
+		return err
+	}
+
+	oldContent, err := os.ReadFile(environmentPath)
+	if err == nil && string(oldContent) == content {
+		log.Debug(ctx, gotext.Get("Environment configuration %s is up to date", environmentPath))
+		return nil
+	}
+
+	log.Debugf(ctx, "Applying environment policy to %s", objectName)
+	// The environment.d configuration is world-readable, as the environment of the sessions is.
+	if written, err := tamper.Write(ctx, "environment", environmentPath, []byte(content), 0644); err != nil || !written {
+		return err
+	}
+	restart.Require(ctx, restart.Hint{Rule: "environment", Kind: restart.Relogin, Details: gotext.Get("environment variables changed")})
+	return nil
+}
+
+// parseVariables validates the environment variables, one "KEY=value" per line, of the entry value and returns
+// the content of the managed environment.d file. Empty lines and comments are ignored.
+func parseVariables(value string) (content string, err error) {
+	var keys, vars []string
+	for _, l := range strings.Split(value, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		key, v, found := strings.Cut(l, "=")
+		key, v = strings.TrimSpace(key), strings.TrimSpace(v)
+		if !found {
+			return "", errors.New(gotext.Get("invalid environment variable %q: expecting KEY=value", l))
+		}
+		if !keyRe.MatchString(key) {
+			return "", errors.New(gotext.Get("invalid environment variable name %q: only letters, digits and _ are allowed, and it can't start with a digit", key))
+		}
+		if slices.Contains(keys, key) {
+			return "", errors.New(gotext.Get("environment variable %q is set multiple times", key))
+		}
+		if strings.IndexFunc(v, unicode.IsControl) != -1 {
+			return "", errors.New(gotext.Get("invalid value for environment variable %q: control characters are not allowed", key))
+		}
+
+		// Values can be quoted in the policy, as in a shell
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+
+		keys = append(keys, key)
+		vars = append(vars, fmt.Sprintf(`%s="%s"`, key, valueEscaper.Replace(v)))
+	}
+
+	if len(vars) == 0 {
+		return "", nil
+	}
+
+	return fmt.Sprintf(`# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+
+%s
+`, strings.Join(vars, "\n")), nil
+}
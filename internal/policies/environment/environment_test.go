@@ -0,0 +1,99 @@
+package environment_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/environment"
+	"github.com/ubuntu/adsys/internal/policies/restart"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{{Key: "environment-variables", Value: "JAVA_HOME=/usr/lib/jvm/java-17-openjdk-amd64\nCOMPANY_NAME=Example \"Corp\""}}
+
+	tests := map[string]struct {
+		entries []entry.Entry
+		user    bool
+
+		environmentFileAlreadyExists bool
+		readOnlyEnvironmentDir       bool
+
+		wantRelogin bool
+		wantErr     bool
+	}{
+		"Computer, variables are written":                       {wantRelogin: true},
+		"Computer, unchanged variables are kept":                {environmentFileAlreadyExists: true},
+		"Computer, changed variables are written":               {entries: []entry.Entry{{Key: "environment-variables", Value: "JAVA_HOME=/opt/java"}}, environmentFileAlreadyExists: true, wantRelogin: true},
+		"Computer, comments and blank lines are ignored":        {entries: []entry.Entry{{Key: "environment-variables", Value: "# Java\n\n JAVA_HOME = /usr/lib/jvm/java-17-openjdk-amd64 \nCOMPANY_NAME=Example \"Corp\"\n"}}, environmentFileAlreadyExists: true},
+		"Computer, special characters in values are escaped":    {entries: []entry.Entry{{Key: "environment-variables", Value: "GREETING=Hello $USER, it's `date`\nWIN_PATH=C:\\Program Files\nEMPTY="}}, wantRelogin: true},
+		"Computer, surrounding quotes of values are removed":    {entries: []entry.Entry{{Key: "environment-variables", Value: "DOUBLE=\"/opt/my app\"\nSINGLE='/opt/my app'\nMISMATCHED=\"/opt/my app'"}}, wantRelogin: true},
+		"Computer, no entries removes existing variables":       {entries: []entry.Entry{}, environmentFileAlreadyExists: true, wantRelogin: true},
+		"Computer, disabled entry removes existing variables":   {entries: []entry.Entry{{Key: "environment-variables", Disabled: true}}, environmentFileAlreadyExists: true, wantRelogin: true},
+		"Computer, only comments removes existing variables":    {entries: []entry.Entry{{Key: "environment-variables", Value: "# nothing\n"}}, environmentFileAlreadyExists: true, wantRelogin: true},
+		"Computer, no entries and no existing variables":        {entries: []entry.Entry{}},
+		"Computer, no entries and read-only environment dir":    {entries: []entry.Entry{}, readOnlyEnvironmentDir: true},
+		"Computer, unexpected entry key is ignored":             {entries: []entry.Entry{{Key: "environment-foo", Value: "FOO=bar"}}},
+		"User, policy is ignored":                               {user: true},
+		"User, existing variables are kept":                     {entries: []entry.Entry{}, environmentFileAlreadyExists: true, user: true},
+		"Error on key starting with a digit":                    {entries: []entry.Entry{{Key: "environment-variables", Value: "1JAVA_HOME=/opt/java"}}, environmentFileAlreadyExists: true, wantErr: true},
+		"Error on key with invalid character":                   {entries: []entry.Entry{{Key: "environment-variables", Value: "JAVA-HOME=/opt/java"}}, environmentFileAlreadyExists: true, wantErr: true},
+		"Error on key with space":                               {entries: []entry.Entry{{Key: "environment-variables", Value: "export JAVA_HOME=/opt/java"}}, wantErr: true},
+		"Error on empty key":                                    {entries: []entry.Entry{{Key: "environment-variables", Value: "=/opt/java"}}, wantErr: true},
+		"Error on variable not being an assignment":             {entries: []entry.Entry{{Key: "environment-variables", Value: "JAVA_HOME"}}, wantErr: true},
+		"Error on variable set multiple times":                  {entries: []entry.Entry{{Key: "environment-variables", Value: "JAVA_HOME=/opt/java\nJAVA_HOME=/usr/lib/jvm"}}, wantErr: true},
+		"Error on value with control character":                 {entries: []entry.Entry{{Key: "environment-variables", Value: "JAVA_HOME=/opt/\tjava"}}, wantErr: true},
+		"Error on read-only environment dir":                    {readOnlyEnvironmentDir: true, wantErr: true},
+		"Error on read-only environment dir removing variables": {entries: []entry.Entry{}, environmentFileAlreadyExists: true, readOnlyEnvironmentDir: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			environmentDir := filepath.Join(t.TempDir(), "environment.d")
+			if tc.environmentFileAlreadyExists {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "environment_dir"), environmentDir)
+			}
+			if tc.readOnlyEnvironmentDir {
+				require.NoError(t, os.MkdirAll(environmentDir, 0750), "Setup: can't create environment dir")
+				testutils.MakeReadOnly(t, environmentDir)
+			}
+
+			m := environment.New(environmentDir)
+
+			hints := &restart.Collector{}
+			err := m.ApplyPolicy(restart.WithCollector(context.Background(), hints), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				// We don't return here as we want to check that the environment dir is in the expected state even in error cases
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.readOnlyEnvironmentDir {
+				// nolint:gosec //false positive, this is a directory
+				require.NoError(t, os.Chmod(environmentDir, 0750), "Setup: can't restore environment dir permissions")
+			}
+			testutils.CompareTreesWithFiltering(t, environmentDir, filepath.Join(testutils.GoldenPath(t), "etc", "environment.d"), testutils.UpdateEnabled())
+
+			if !tc.wantRelogin {
+				require.Empty(t, hints.Hints(), "ApplyPolicy should not require a restart")
+				return
+			}
+			require.Len(t, hints.Hints(), 1, "ApplyPolicy should require one restart")
+			require.Equal(t, "environment", hints.Hints()[0].Rule, "ApplyPolicy should require a restart for its rule")
+			require.Equal(t, restart.Relogin, hints.Hints()[0].Kind, "ApplyPolicy should require a relogin")
+		})
+	}
+}
@@ -6,7 +6,6 @@ import (
 
 const (
 	PoliciesAssetsFileName = policiesAssetsFileName
-	PoliciesFileName       = policiesFileName
 )
 
 // WithGDM specifies a personalized gdm manager.
@@ -0,0 +1,358 @@
+// Package extensions provides a manager for the policy types declared by third parties, without code changes.
+//
+// Each YAML file of the extensions directory declares a policy type, the first component of the registry keys of
+// its ADMX under the Ubuntu policies key, and maps each of its policies, matched by their key, to a generic handler:
+//   - file writes the value of the policy to a file of the computer, and removes it when the policy is disabled or
+//     not configured;
+//   - dconf sets a dconf key for the computer or the user, and is applied along the dconf policy.
+//
+// Declarations are strictly validated when they are loaded: any unknown field or handler, invalid path or dconf key,
+// or policy mapped twice rejects the whole extensions directory.
+package extensions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/decorate"
+	"gopkg.in/yaml.v3"
+)
+
+// Handlers applying the policies of the extensions.
+const (
+	handlerFile  = "file"
+	handlerDconf = "dconf"
+)
+
+var (
+	// typeRe matches the policy type names, which are the first component of the registry keys.
+	typeRe = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+	// keyRe matches the policy keys, relative to the policy type.
+	keyRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*(/[a-zA-Z0-9][a-zA-Z0-9_.-]*)*$`)
+	// dconfKeyRe matches the dconf keys, without their leading slash.
+	dconfKeyRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9-]*(/[a-zA-Z0-9][a-zA-Z0-9-]*)+$`)
+	// dconfMetas are the dconf key types the values are normalized for.
+	dconfMetas = []string{"s", "b", "i", "as", "ai"}
+)
+
+// declaration is the content of an extension file.
+type declaration struct {
+	Type     string    `yaml:"type"`
+	Policies []mapping `yaml:"policies"`
+}
+
+// mapping maps a policy of the extension to a handler.
+type mapping struct {
+	Key     string `yaml:"key"`
+	Handler string `yaml:"handler"`
+
+	// file handler
+	Path string `yaml:"path"`
+	Mode string `yaml:"mode"`
+
+	// dconf handler
+	DconfKey string `yaml:"dconf_key"`
+	Meta     string `yaml:"meta"`
+
+	mode fs.FileMode
+}
+
+// Manager applies the policies of the extensions.
+type Manager struct {
+	rootDir    string
+	extensions map[string][]mapping
+}
+
+type options struct {
+	rootDir string
+}
+
+// Option reprents an optional function to change the extensions manager.
+type Option func(*options)
+
+// WithRootDir specifies an alternate root directory under which the files of the extensions are written.
+func WithRootDir(p string) Option {
+	return func(o *options) {
+		o.rootDir = p
+	}
+}
+
+// New loads and validates the extensions declared in the YAML files of dir.
+// A missing directory declares no extension.
+func New(dir string, opts ...Option) (m *Manager, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't load policy extensions from %s", dir))
+
+	// defaults
+	args := options{}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	m = &Manager{
+		rootDir:    args.rootDir,
+		extensions: make(map[string][]mapping),
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	// Glob returns the files in lexical order, so that errors are reported consistently.
+	paths := make(map[string]string)
+	for _, f := range files {
+		d, err := loadDeclaration(f)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := m.extensions[d.Type]; exists {
+			return nil, errors.New(gotext.Get("%s: policy type %q is already declared", f, d.Type))
+		}
+		for _, p := range d.Policies {
+			if p.Handler != handlerFile {
+				continue
+			}
+			if other, exists := paths[p.Path]; exists {
+				return nil, errors.New(gotext.Get("%s: file %s is already written by policy %s", f, p.Path, other))
+			}
+			paths[p.Path] = d.Type + "/" + p.Key
+		}
+		m.extensions[d.Type] = d.Policies
+	}
+
+	return m, nil
+}
+
+// loadDeclaration parses and validates the extension declared in path.
+func loadDeclaration(path string) (d declaration, err error) {
+	defer decorate.OnError(&err, gotext.Get("invalid extension %s", path))
+
+	f, err := os.Open(path)
+	if err != nil {
+		return d, err
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&d); err != nil {
+		if errors.Is(err, io.EOF) {
+			return d, errors.New(gotext.Get("empty declaration"))
+		}
+		return d, err
+	}
+
+	if !typeRe.MatchString(d.Type) {
+		return d, errors.New(gotext.Get("invalid policy type %q: only lowercase letters, digits and dashes are allowed", d.Type))
+	}
+	if len(d.Policies) == 0 {
+		return d, errors.New(gotext.Get("no policy is declared"))
+	}
+
+	var keys []string
+	for i, p := range d.Policies {
+		if !keyRe.MatchString(p.Key) || filepath.Base(p.Key) == "all" {
+			return d, errors.New(gotext.Get("invalid policy key %q", p.Key))
+		}
+		if slices.Contains(keys, p.Key) {
+			return d, errors.New(gotext.Get("policy %q is declared twice", p.Key))
+		}
+		keys = append(keys, p.Key)
+
+		if d.Policies[i], err = validateMapping(p); err != nil {
+			return d, fmt.Errorf("%s: %w", p.Key, err)
+		}
+	}
+
+	return d, nil
+}
+
+// validateMapping checks that the handler of p is known and only has its own valid settings.
+func validateMapping(p mapping) (mapping, error) {
+	switch p.Handler {
+	case handlerFile:
+		if p.DconfKey != "" || p.Meta != "" {
+			return p, errors.New(gotext.Get("dconf_key and meta are only allowed with the dconf handler"))
+		}
+		if !filepath.IsAbs(p.Path) || filepath.Clean(p.Path) != p.Path || p.Path == "/" {
+			return p, errors.New(gotext.Get("invalid path %q: it must be an absolute and clean file path", p.Path))
+		}
+		p.mode = 0644
+		if p.Mode != "" {
+			mode, err := strconv.ParseUint(p.Mode, 8, 32)
+			if err != nil || mode&^0777 != 0 {
+				return p, errors.New(gotext.Get("invalid mode %q: it must be octal permissions, like 0644", p.Mode))
+			}
+			p.mode = fs.FileMode(mode)
+		}
+
+	case handlerDconf:
+		if p.Path != "" || p.Mode != "" {
+			return p, errors.New(gotext.Get("path and mode are only allowed with the file handler"))
+		}
+		if !dconfKeyRe.MatchString(p.DconfKey) {
+			return p, errors.New(gotext.Get("invalid dconf key %q: it must be a path like org/gnome/desktop/background/picture-uri", p.DconfKey))
+		}
+		if !slices.Contains(dconfMetas, p.Meta) {
+			return p, errors.New(gotext.Get("invalid dconf key type %q: supported types are %s", p.Meta, strings.Join(dconfMetas, ", ")))
+		}
+
+	default:
+		return p, errors.New(gotext.Get("unknown handler %q: supported handlers are %s and %s", p.Handler, handlerFile, handlerDconf))
+	}
+
+	return p, nil
+}
+
+// Types returns the sorted policy types declared by the extensions.
+func (m *Manager) Types() []string {
+	var types []string
+	for t := range m.extensions {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Paths returns the files written by the extensions.
+func (m *Manager) Paths() []string {
+	var paths []string
+	for _, t := range m.Types() {
+		for _, p := range m.extensions[t] {
+			if p.Handler == handlerFile {
+				paths = append(paths, filepath.Join(m.rootDir, p.Path))
+			}
+		}
+	}
+	return paths
+}
+
+// MergeWithDconf returns the dconf entries, with the keys set by the extensions policies in rules replacing the ones
+// of the dconf policy, if any.
+func (m *Manager) MergeWithDconf(ctx context.Context, dconfEntries []entry.Entry, rules map[string][]entry.Entry) []entry.Entry {
+	merged := slices.Clone(dconfEntries)
+	for _, t := range m.Types() {
+		for _, p := range m.extensions[t] {
+			if p.Handler != handlerDconf {
+				continue
+			}
+			i := slices.IndexFunc(rules[t], func(e entry.Entry) bool { return e.Key == p.Key })
+			if i == -1 {
+				continue
+			}
+			e := rules[t][i]
+			g := entry.Entry{Key: p.DconfKey, Value: e.Value, Disabled: e.Disabled, Meta: p.Meta}
+
+			j := slices.IndexFunc(merged, func(e entry.Entry) bool { return e.Key == g.Key })
+			if j == -1 {
+				merged = append(merged, g)
+				continue
+			}
+			log.Warning(ctx, gotext.Get("dconf key %s is overridden by the %s policy", g.Key, t))
+			ignored.Record(ctx, ignored.Entry{Rule: "dconf", Key: merged[j].Key, Value: merged[j].Value, Reason: ignored.DuplicateValue,
+				Details: gotext.Get("overridden by the %s policy", t)})
+			merged[j] = g
+		}
+	}
+	return merged
+}
+
+// ApplyPolicy writes the files of the extensions policies configured in rules, and removes the ones of the policies
+// which are not. Entries which are not declared by their extension are ignored.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, rules map[string][]entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply policy extensions to %s", objectName))
+
+	for _, t := range m.Types() {
+		for _, e := range rules[t] {
+			if !slices.ContainsFunc(m.extensions[t], func(p mapping) bool { return p.Key == e.Key }) {
+				ignored.RecordUnsupportedKey(ctx, t, e.Key, e.Value)
+			}
+		}
+	}
+
+	// Files are only written for the computer.
+	if !isComputer {
+		return nil
+	}
+
+	log.Debugf(ctx, "Applying policy extensions to %s", objectName)
+
+	var errs []error
+	for _, t := range m.Types() {
+		for _, p := range m.extensions[t] {
+			if p.Handler != handlerFile {
+				continue
+			}
+			path := filepath.Join(m.rootDir, p.Path)
+
+			i := slices.IndexFunc(rules[t], func(e entry.Entry) bool { return e.Key == p.Key })
+			if i == -1 || rules[t][i].Disabled {
+				if err := removeFile(ctx, path); err != nil {
+					errs = append(errs, err)
+				}
+				continue
+			}
+			content := rules[t][i].Value
+			if !strings.HasSuffix(content, "\n") {
+				content += "\n"
+			}
+			if err := writeFile(ctx, path, content, p.mode); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// writeFile atomically writes content to path with mode, if it changed.
+func writeFile(ctx context.Context, path, content string, mode fs.FileMode) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't write %s", path))
+
+	if old, err := os.ReadFile(path); err == nil && string(old) == content {
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm() == mode {
+			return nil
+		}
+	}
+
+	log.Debugf(ctx, "Writing %s", path)
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".adsys.new", []byte(content), mode); err != nil {
+		return err
+	}
+	// Enforce the declared mode, which is masked by the umask on creation.
+	if err := os.Chmod(path+".adsys.new", mode); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".adsys.new", path); err != nil {
+		return err
+	}
+	return nil
+}
+
+// removeFile removes path, if it exists.
+func removeFile(ctx context.Context, path string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't remove %s", path))
+
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	log.Debugf(ctx, "Removing %s", path)
+	return os.Remove(path)
+}
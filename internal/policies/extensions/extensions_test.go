@@ -0,0 +1,177 @@
+package extensions_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/dconf"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/extensions"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+const acmeExtension = `type: acme-agent
+policies:
+  - key: server/url
+    handler: file
+    path: /etc/acme/agent.conf
+    mode: "0640"
+  - key: server/ca
+    handler: file
+    path: /etc/acme/ca.pem
+  - key: appearance/theme
+    handler: dconf
+    dconf_key: org/acme/agent/theme
+    meta: s
+`
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		declarations []string
+
+		wantTypes []string
+		wantPaths []string
+		wantErr   bool
+	}{
+		"Declared extension is registered": {declarations: []string{acmeExtension},
+			wantTypes: []string{"acme-agent"}, wantPaths: []string{"/etc/acme/agent.conf", "/etc/acme/ca.pem"}},
+		"Multiple extensions are registered": {declarations: []string{acmeExtension, "type: other\npolicies:\n  - key: k\n    handler: dconf\n    dconf_key: org/other/k\n    meta: b\n"},
+			wantTypes: []string{"acme-agent", "other"}, wantPaths: []string{"/etc/acme/agent.conf", "/etc/acme/ca.pem"}},
+		"No declaration registers no extension": {},
+
+		"Error on unknown handler":                {declarations: []string{"type: acme\npolicies:\n  - key: k\n    handler: script\n    path: /usr/bin/acme\n"}, wantErr: true},
+		"Error on missing handler":                {declarations: []string{"type: acme\npolicies:\n  - key: k\n    path: /etc/acme.conf\n"}, wantErr: true},
+		"Error on unknown field":                  {declarations: []string{"type: acme\npolicies:\n  - key: k\n    handler: file\n    path: /etc/acme.conf\n    owner: root\n"}, wantErr: true},
+		"Error on field of another handler":       {declarations: []string{"type: acme\npolicies:\n  - key: k\n    handler: file\n    path: /etc/acme.conf\n    meta: s\n"}, wantErr: true},
+		"Error on invalid YAML":                   {declarations: []string{"type: [acme"}, wantErr: true},
+		"Error on empty declaration":              {declarations: []string{""}, wantErr: true},
+		"Error on invalid type":                   {declarations: []string{"type: Acme/Agent\npolicies:\n  - key: k\n    handler: dconf\n    dconf_key: org/acme/k\n    meta: s\n"}, wantErr: true},
+		"Error on no policy":                      {declarations: []string{"type: acme\n"}, wantErr: true},
+		"Error on invalid key":                    {declarations: []string{"type: acme\npolicies:\n  - key: ../k\n    handler: dconf\n    dconf_key: org/acme/k\n    meta: s\n"}, wantErr: true},
+		"Error on reserved all key":               {declarations: []string{"type: acme\npolicies:\n  - key: k/all\n    handler: dconf\n    dconf_key: org/acme/k\n    meta: s\n"}, wantErr: true},
+		"Error on policy declared twice":          {declarations: []string{"type: acme\npolicies:\n  - key: k\n    handler: file\n    path: /etc/a\n  - key: k\n    handler: file\n    path: /etc/b\n"}, wantErr: true},
+		"Error on relative path":                  {declarations: []string{"type: acme\npolicies:\n  - key: k\n    handler: file\n    path: etc/acme.conf\n"}, wantErr: true},
+		"Error on unclean path":                   {declarations: []string{"type: acme\npolicies:\n  - key: k\n    handler: file\n    path: /etc/../acme.conf\n"}, wantErr: true},
+		"Error on invalid mode":                   {declarations: []string{"type: acme\npolicies:\n  - key: k\n    handler: file\n    path: /etc/acme.conf\n    mode: \"4755\"\n"}, wantErr: true},
+		"Error on invalid dconf key":              {declarations: []string{"type: acme\npolicies:\n  - key: k\n    handler: dconf\n    dconf_key: /org/acme/k\n    meta: s\n"}, wantErr: true},
+		"Error on unsupported dconf type":         {declarations: []string{"type: acme\npolicies:\n  - key: k\n    handler: dconf\n    dconf_key: org/acme/k\n    meta: aai\n"}, wantErr: true},
+		"Error on type declared twice":            {declarations: []string{acmeExtension, acmeExtension}, wantErr: true},
+		"Error on file written by two extensions": {declarations: []string{acmeExtension, "type: other\npolicies:\n  - key: k\n    handler: file\n    path: /etc/acme/ca.pem\n"}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for i, d := range tc.declarations {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("%02d.yaml", i)), []byte(d), 0600), "Setup: can't write declaration")
+			}
+
+			m, err := extensions.New(dir)
+			if tc.wantErr {
+				require.Error(t, err, "New should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "New failed but shouldn't have")
+
+			require.Equal(t, tc.wantTypes, m.Types(), "Types should return the declared policy types")
+			require.Equal(t, tc.wantPaths, m.Paths(), "Paths should return the files written by the extensions")
+		})
+	}
+}
+
+func TestNewWithMissingDir(t *testing.T) {
+	t.Parallel()
+
+	m, err := extensions.New(filepath.Join(t.TempDir(), "doesnotexist"))
+	require.NoError(t, err, "New should not fail on a missing directory")
+	require.Empty(t, m.Types(), "No extension should be registered")
+}
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		entries        []entry.Entry
+		dconfEntries   []entry.Entry
+		existingFiles  bool
+		user           bool
+		readOnlyEtcDir bool
+
+		wantErr bool
+	}{
+		"Computer, files and dconf keys are applied": {},
+		"Computer, existing files are updated": {entries: []entry.Entry{
+			{Key: "server/url", Value: "https://acme2.example.com"},
+			{Key: "server/ca", Value: "-----BEGIN CERTIFICATE-----\nMIIB2\n-----END CERTIFICATE-----\n"},
+		}, existingFiles: true},
+		"Computer, disabled policy removes its file": {entries: []entry.Entry{
+			{Key: "server/url", Disabled: true},
+			{Key: "server/ca", Value: "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"},
+		}, existingFiles: true},
+		"Computer, no entries removes all files":   {entries: []entry.Entry{}, existingFiles: true},
+		"Computer, undeclared policy is ignored":   {entries: []entry.Entry{{Key: "server/port", Value: "8443"}}},
+		"Computer, dconf policy key is overridden": {dconfEntries: []entry.Entry{{Key: "org/acme/agent/theme", Value: "'light'", Meta: "s"}}},
+		"User, only dconf keys are applied":        {user: true},
+
+		"Error on read-only destination": {readOnlyEtcDir: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = []entry.Entry{
+					{Key: "server/url", Value: "https://acme.example.com"},
+					{Key: "server/ca", Value: "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"},
+					{Key: "appearance/theme", Value: "'dark'"},
+				}
+			}
+
+			extensionsDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(extensionsDir, "acme.yaml"), []byte(acmeExtension), 0600), "Setup: can't write declaration")
+
+			root := filepath.Join(t.TempDir(), "root")
+			if tc.existingFiles {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "existing"), root)
+			}
+			require.NoError(t, os.MkdirAll(root, 0750), "Setup: can't create root dir")
+			if tc.readOnlyEtcDir {
+				require.NoError(t, os.MkdirAll(filepath.Join(root, "etc"), 0750), "Setup: can't create etc dir")
+				testutils.MakeReadOnly(t, filepath.Join(root, "etc"))
+			}
+
+			m, err := extensions.New(extensionsDir, extensions.WithRootDir(root))
+			require.NoError(t, err, "Setup: New failed")
+
+			rules := map[string][]entry.Entry{"acme-agent": tc.entries}
+
+			err = m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, rules)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+
+			// The dconf keys of the extension are applied with the dconf policy.
+			dconfEntries := m.MergeWithDconf(context.Background(), tc.dconfEntries, rules)
+			if !tc.user {
+				d := dconf.NewWithDconfDir(filepath.Join(root, "etc", "dconf"))
+				require.NoError(t, d.ApplyPolicy(context.Background(), "ubuntu", true, dconfEntries), "Setup: dconf ApplyPolicy failed")
+			} else {
+				require.Equal(t, []entry.Entry{{Key: "org/acme/agent/theme", Value: "'dark'", Meta: "s"}}, dconfEntries,
+					"MergeWithDconf should convert the dconf policies of the user")
+			}
+
+			testutils.CompareTreesWithFiltering(t, root, testutils.GoldenPath(t), testutils.UpdateEnabled())
+		})
+	}
+}
@@ -0,0 +1,237 @@
+// Package firewall provides a manager to apply host firewall rules with ufw.
+//
+// The policy is only supported on computers. The ufw-rules entry lists ufw
+// rules, one per line, in the syntax of the ufw command line, like
+// "allow 22/tcp" or "deny from 10.0.0.0/8 to any port 3306".
+//
+// Every rule added by adsys is tagged with a comment containing the rule of the
+// policy. On each refresh, the rules are reconciled against the numbered output
+// of ufw status: rules of the policy which are not set yet are added, and
+// adsys-tagged rules which are not in the policy anymore are deleted. Rules
+// which were not created by adsys are never modified.
+//
+// ufw only lists its rules when it is active. If the firewall is inactive, the
+// policy is not applied and a warning is logged.
+package firewall
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+// commentPrefix tags the comment of the rules added by adsys.
+const commentPrefix = "adsys: "
+
+// supportedActions are the actions a rule of the policy can start with.
+var supportedActions = []string{"allow", "deny", "reject", "limit"}
+
+// statusRuleRe matches a rule tagged by adsys in the output of ufw status numbered, capturing its number and
+// the rule of the policy it was added for.
+var statusRuleRe = regexp.MustCompile(`^\[\s*(\d+)\].*#\s*` + regexp.QuoteMeta(commentPrefix) + `(.+)$`)
+
+// Backend is the interface to manage the ufw rules.
+type Backend interface {
+	// Status returns the output of ufw status numbered.
+	Status(ctx context.Context) (string, error)
+	// Run runs ufw with the given arguments.
+	Run(ctx context.Context, args ...string) error
+}
+
+// ufw runs the ufw command.
+type ufw struct{}
+
+// Status returns the output of ufw status numbered.
+func (ufw) Status(ctx context.Context) (string, error) {
+	return runUfw(ctx, "status", "numbered")
+}
+
+// Run runs ufw with the given arguments.
+func (ufw) Run(ctx context.Context, args ...string) error {
+	_, err := runUfw(ctx, args...)
+	return err
+}
+
+func runUfw(ctx context.Context, args ...string) (string, error) {
+	// #nosec G204 - The rules are validated before being passed as arguments, without any shell
+	cmd := exec.CommandContext(ctx, "ufw", args...)
+	trace.Command(ctx, "firewall", cmd.Args)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return "", errors.Join(err, errors.New(gotext.Get("ufw %s failed: %s", strings.Join(args, " "), string(out))))
+	}
+	return string(out), nil
+}
+
+// Manager applies the rules of the firewall policy.
+type Manager struct {
+	backend Backend
+}
+
+type options struct {
+	backend Backend
+}
+
+// Option reprents an optional function to change the firewall manager.
+type Option func(*options)
+
+// WithBackend specifies a personalized backend to manage the ufw rules.
+func WithBackend(b Backend) Option {
+	return func(o *options) {
+		o.backend = b
+	}
+}
+
+// New creates a manager applying the rules with ufw.
+func New(opts ...Option) *Manager {
+	// defaults
+	args := options{
+		backend: ufw{},
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Manager{
+		backend: args.backend,
+	}
+}
+
+// ApplyPolicy adds the rules of the policy which are not set yet and deletes the rules added by adsys which
+// are not in the policy anymore.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply firewall policy to %s", objectName))
+
+	// Firewall policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	var rules []string
+	for _, e := range entries {
+		if e.Key != "ufw-rules" {
+			ignored.RecordUnsupportedKey(ctx, "firewall", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled {
+			continue
+		}
+		if rules, err = parseRules(e.Value); err != nil {
+			return err
+		}
+	}
+
+	status, err := m.backend.Status(ctx)
+	if err != nil {
+		// Nothing to clean up if ufw is not installed
+		if len(rules) == 0 && errors.Is(err, exec.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if strings.Contains(status, "Status: inactive") {
+		if len(rules) > 0 {
+			log.Warning(ctx, gotext.Get("The firewall is inactive: rules of the firewall policy are not applied"))
+		}
+		return nil
+	}
+
+	current, err := parseStatus(status)
+	if err != nil {
+		return err
+	}
+
+	// Delete obsolete rules first, from the last one so that the numbers of the previous ones don't change.
+	var obsolete []int
+	for n, rule := range current {
+		if !slices.Contains(rules, rule) {
+			obsolete = append(obsolete, n)
+		}
+	}
+	slices.Sort(obsolete)
+	slices.Reverse(obsolete)
+	for _, n := range obsolete {
+		log.Debugf(ctx, "Deleting firewall rule %d: %q", n, current[n])
+		if err := m.backend.Run(ctx, "--force", "delete", strconv.Itoa(n)); err != nil {
+			return err
+		}
+	}
+
+	set := make(map[string]bool)
+	for _, rule := range current {
+		set[rule] = true
+	}
+	for _, rule := range rules {
+		if set[rule] {
+			continue
+		}
+		log.Debugf(ctx, "Adding firewall rule %q", rule)
+		if err := m.backend.Run(ctx, append(strings.Fields(rule), "comment", commentPrefix+rule)...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseRules validates the ufw rules, one per line, of the entry value and returns them with normalized
+// spacing. Empty lines, comments and duplicated rules are ignored.
+func parseRules(value string) (rules []string, err error) {
+	for _, l := range strings.Split(value, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		fields := strings.Fields(l)
+		if !slices.Contains(supportedActions, fields[0]) {
+			return nil, errors.New(gotext.Get("invalid firewall rule %q: expecting it to start with one of %s", l, strings.Join(supportedActions, ", ")))
+		}
+		if len(fields) < 2 {
+			return nil, errors.New(gotext.Get("invalid firewall rule %q: missing port, service or address", l))
+		}
+		if slices.Contains(fields, "comment") || strings.ContainsAny(l, `#'"`) {
+			return nil, errors.New(gotext.Get("invalid firewall rule %q: comments are reserved to adsys", l))
+		}
+
+		rule := strings.Join(fields, " ")
+		if slices.Contains(rules, rule) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseStatus returns the rules tagged by adsys, indexed by number, in the output of ufw status numbered.
+// As ufw adds a rule for IPv4 and IPv6, the same policy rule can be listed multiple times.
+func parseStatus(status string) (rules map[int]string, err error) {
+	rules = make(map[int]string)
+	for _, l := range strings.Split(status, "\n") {
+		matches := statusRuleRe.FindStringSubmatch(strings.TrimSpace(l))
+		if matches == nil {
+			continue
+		}
+		n, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, errors.New(gotext.Get("invalid rule number in ufw status line %q: %v", l, err))
+		}
+		rules[n] = strings.TrimSpace(matches[2])
+	}
+	return rules, nil
+}
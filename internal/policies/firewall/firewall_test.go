@@ -0,0 +1,143 @@
+package firewall_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/firewall"
+)
+
+const (
+	statusNoRules = `Status: active
+`
+
+	statusWithRules = `Status: active
+
+     To                         Action      From
+     --                         ------      ----
+[ 1] 22/tcp                     ALLOW IN    Anywhere                   # adsys: allow 22/tcp
+[ 2] 80/tcp                     ALLOW IN    Anywhere
+[ 3] 3306                       DENY IN     10.0.0.0/8                 # adsys: deny from 10.0.0.0/8 to any port 3306
+[ 4] 8080                       ALLOW IN    Anywhere                   # local: allow 8080
+[ 5] 22/tcp (v6)                ALLOW IN    Anywhere (v6)              # adsys: allow 22/tcp
+[ 6] 80/tcp (v6)                ALLOW IN    Anywhere (v6)
+`
+
+	statusInactive = `Status: inactive
+`
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{{Key: "ufw-rules", Value: "allow 22/tcp\ndeny from 10.0.0.0/8 to any port 3306"}}
+
+	tests := map[string]struct {
+		entries []entry.Entry
+		user    bool
+
+		status       string
+		statusErr    error
+		runErrOnCall int
+
+		wantCalls []string
+		wantErr   bool
+	}{
+		"Computer, rules are added": {
+			status: statusNoRules,
+			wantCalls: []string{
+				"allow 22/tcp comment adsys: allow 22/tcp",
+				"deny from 10.0.0.0/8 to any port 3306 comment adsys: deny from 10.0.0.0/8 to any port 3306",
+			}},
+		"Computer, existing rules are not added again": {status: statusWithRules},
+		"Computer, new rule is added":                  {entries: []entry.Entry{{Key: "ufw-rules", Value: "allow 22/tcp\ndeny from 10.0.0.0/8 to any port 3306\nlimit ssh"}}, status: statusWithRules, wantCalls: []string{"limit ssh comment adsys: limit ssh"}},
+		"Computer, rules not in policy are deleted from the last one": {
+			entries:   []entry.Entry{{Key: "ufw-rules", Value: "deny from 10.0.0.0/8 to any port 3306"}},
+			status:    statusWithRules,
+			wantCalls: []string{"--force delete 5", "--force delete 1"}},
+		"Computer, rules are deleted before new ones are added": {
+			entries:   []entry.Entry{{Key: "ufw-rules", Value: "allow 22/tcp\nallow 443/tcp"}},
+			status:    statusWithRules,
+			wantCalls: []string{"--force delete 3", "allow 443/tcp comment adsys: allow 443/tcp"}},
+		"Computer, spacing, comments and duplicated rules are normalized": {
+			entries: []entry.Entry{{Key: "ufw-rules", Value: "# Remote access\n\n  allow   22/tcp \ndeny from 10.0.0.0/8   to any port 3306\nallow 22/tcp\n"}},
+			status:  statusWithRules},
+		"Computer, no entries deletes all adsys rules":     {entries: []entry.Entry{}, status: statusWithRules, wantCalls: []string{"--force delete 5", "--force delete 3", "--force delete 1"}},
+		"Computer, disabled entry deletes all adsys rules": {entries: []entry.Entry{{Key: "ufw-rules", Disabled: true}}, status: statusWithRules, wantCalls: []string{"--force delete 5", "--force delete 3", "--force delete 1"}},
+		"Computer, no entries and no adsys rules":          {entries: []entry.Entry{}, status: statusNoRules},
+		"Computer, no entries and ufw not installed":       {entries: []entry.Entry{}, statusErr: exec.ErrNotFound},
+		"Computer, inactive firewall is left untouched":    {status: statusInactive},
+		"Computer, unexpected entry key is ignored":        {entries: []entry.Entry{{Key: "ufw-foo", Value: "allow 80"}}, status: statusWithRules, wantCalls: []string{"--force delete 5", "--force delete 3", "--force delete 1"}},
+		"User, policy is ignored":                          {user: true, status: statusNoRules},
+		"User, existing rules are kept":                    {entries: []entry.Entry{}, user: true, status: statusWithRules},
+		"Error on rule with unsupported action":            {entries: []entry.Entry{{Key: "ufw-rules", Value: "allow 22/tcp\ndelete 1"}}, status: statusWithRules, wantErr: true},
+		"Error on rule without port, service or address":   {entries: []entry.Entry{{Key: "ufw-rules", Value: "allow"}}, status: statusWithRules, wantErr: true},
+		"Error on rule with comment":                       {entries: []entry.Entry{{Key: "ufw-rules", Value: "allow 80 comment web"}}, status: statusWithRules, wantErr: true},
+		"Error on rule with quote":                         {entries: []entry.Entry{{Key: "ufw-rules", Value: "allow 80 'web'"}}, status: statusWithRules, wantErr: true},
+		"Error on ufw not installed with rules":            {statusErr: exec.ErrNotFound, wantErr: true},
+		"Error on ufw status failing":                      {entries: []entry.Entry{}, statusErr: errors.New("ufw status error"), wantErr: true},
+		"Error on deleting rule failing":                   {entries: []entry.Entry{}, status: statusWithRules, runErrOnCall: 2, wantCalls: []string{"--force delete 5", "--force delete 3"}, wantErr: true},
+		"Error on adding rule failing, previous ones are kept": {
+			status:       statusNoRules,
+			runErrOnCall: 2,
+			wantCalls: []string{
+				"allow 22/tcp comment adsys: allow 22/tcp",
+				"deny from 10.0.0.0/8 to any port 3306 comment adsys: deny from 10.0.0.0/8 to any port 3306",
+			},
+			wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			backend := &mockBackend{status: tc.status, statusErr: tc.statusErr, runErrOnCall: tc.runErrOnCall}
+			m := firewall.New(firewall.WithBackend(backend))
+
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			require.Equal(t, tc.wantCalls, backend.calls, "ufw should have been called with the expected arguments")
+		})
+	}
+}
+
+// mockBackend is a mock for the ufw backend, recording the arguments of each ufw call.
+type mockBackend struct {
+	status    string
+	statusErr error
+
+	// runErrOnCall is the number, starting at 1, of the call to fail. 0 means no failure.
+	runErrOnCall int
+
+	calls []string
+}
+
+func (b *mockBackend) Status(context.Context) (string, error) {
+	if b.statusErr != nil {
+		return "", fmt.Errorf("ufw status numbered failed: %w", b.statusErr)
+	}
+	return b.status, nil
+}
+
+func (b *mockBackend) Run(_ context.Context, args ...string) error {
+	b.calls = append(b.calls, strings.Join(args, " "))
+	if len(b.calls) == b.runErrOnCall {
+		return errors.New("ufw error")
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Package gnomeextensions converts the GNOME Shell extensions policy to dconf entries.
+//
+// The policy is supported on users and computers. It supports the following entries, each listing extension
+// UUIDs, like dash-to-dock@micxgx.gmail.com, one per line:
+//   - enabled-extensions: the extensions to enable.
+//   - disabled-extensions: the extensions to disable.
+//
+// They are converted to the org.gnome.shell enabled-extensions and disabled-extensions keys, as arrays of strings,
+// and applied and locked by the dconf manager with the rest of the dconf policy. They replace any dconf entry of
+// the policy for the same keys. A disabled entry locks the key to its default value.
+//
+// The UUIDs are checked before being converted. An extension can't be both enabled and disabled.
+package gnomeextensions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/decorate"
+)
+
+// dconfKeys are the org.gnome.shell dconf keys set by each entry of the policy.
+var dconfKeys = map[string]string{
+	"enabled-extensions":  "org/gnome/shell/enabled-extensions",
+	"disabled-extensions": "org/gnome/shell/disabled-extensions",
+}
+
+// uuidRe matches the characters allowed in an extension UUID.
+var uuidRe = regexp.MustCompile(`^[a-zA-Z0-9][-a-zA-Z0-9_.@]*$`)
+
+// MergeWithDconf returns the dconf entries, with the keys set by the GNOME Shell extensions entries replacing
+// the ones of the dconf policy, if any.
+func MergeWithDconf(ctx context.Context, dconfEntries, entries []entry.Entry) (merged []entry.Entry, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply GNOME Shell extensions policy"))
+
+	generated, err := toDconf(ctx, entries)
+	if err != nil {
+		return nil, err
+	}
+	if len(generated) == 0 {
+		return dconfEntries, nil
+	}
+
+	merged = slices.Clone(dconfEntries)
+	for _, g := range generated {
+		i := slices.IndexFunc(merged, func(e entry.Entry) bool { return e.Key == g.Key })
+		if i == -1 {
+			merged = append(merged, g)
+			continue
+		}
+		log.Warning(ctx, gotext.Get("dconf key %s is overridden by the GNOME Shell extensions policy", g.Key))
+		ignored.Record(ctx, ignored.Entry{Rule: "dconf", Key: merged[i].Key, Value: merged[i].Value, Reason: ignored.DuplicateValue,
+			Details: gotext.Get("overridden by the GNOME Shell extensions policy")})
+		merged[i] = g
+	}
+
+	return merged, nil
+}
+
+// toDconf converts the GNOME Shell extensions entries to the dconf entries setting the org.gnome.shell keys
+// as arrays of strings.
+func toDconf(ctx context.Context, entries []entry.Entry) (dconfEntries []entry.Entry, err error) {
+	lists := make(map[string][]string)
+	for _, e := range entries {
+		key, ok := dconfKeys[e.Key]
+		if !ok {
+			ignored.RecordUnsupportedKey(ctx, "gnomeextensions", e.Key, e.Value)
+			continue
+		}
+
+		if e.Disabled {
+			dconfEntries = append(dconfEntries, entry.Entry{Key: key, Disabled: true, Meta: "as"})
+			continue
+		}
+
+		uuids, err := parseUUIDs(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		if len(uuids) == 0 {
+			log.Warning(ctx, gotext.Get("No extension listed in GNOME Shell extensions entry %s, skipping it", e.Key))
+			ignored.Record(ctx, ignored.Entry{Rule: "gnomeextensions", Key: e.Key, Value: e.Value, Reason: ignored.InvalidValue,
+				Details: gotext.Get("no extension listed")})
+			continue
+		}
+		lists[e.Key] = uuids
+
+		var quoted []string
+		for _, u := range uuids {
+			quoted = append(quoted, fmt.Sprintf("'%s'", u))
+		}
+		dconfEntries = append(dconfEntries, entry.Entry{Key: key, Value: fmt.Sprintf("[%s]", strings.Join(quoted, ", ")), Meta: "as"})
+	}
+
+	for _, u := range lists["enabled-extensions"] {
+		if slices.Contains(lists["disabled-extensions"], u) {
+			return nil, errors.New(gotext.Get("extension %q can't be both enabled and disabled", u))
+		}
+	}
+
+	return dconfEntries, nil
+}
+
+// parseUUIDs returns the extension UUIDs of the entry value, one per line, without duplicates.
+// Empty lines and comments are ignored.
+func parseUUIDs(value string) (uuids []string, err error) {
+	for _, l := range strings.Split(value, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		if !uuidRe.MatchString(l) {
+			return nil, errors.New(gotext.Get("invalid extension UUID %q", l))
+		}
+		if slices.Contains(uuids, l) {
+			continue
+		}
+		uuids = append(uuids, l)
+	}
+	return uuids, nil
+}
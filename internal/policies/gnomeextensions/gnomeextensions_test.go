@@ -0,0 +1,131 @@
+package gnomeextensions_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/dconf"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/gnomeextensions"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestMergeWithDconf(t *testing.T) {
+	t.Parallel()
+
+	favoriteApps := entry.Entry{Key: "org/gnome/shell/favorite-apps", Value: "['firefox.desktop']", Meta: "as"}
+
+	tests := map[string]struct {
+		dconfEntries []entry.Entry
+		entries      []entry.Entry
+
+		want    []entry.Entry
+		wantErr bool
+	}{
+		"Enabled extensions": {
+			entries: []entry.Entry{{Key: "enabled-extensions", Value: "dash-to-dock@micxgx.gmail.com\nubuntu-appindicators@ubuntu.com"}},
+			want: []entry.Entry{
+				{Key: "org/gnome/shell/enabled-extensions", Value: "['dash-to-dock@micxgx.gmail.com', 'ubuntu-appindicators@ubuntu.com']", Meta: "as"},
+			},
+		},
+		"Disabled extensions": {
+			entries: []entry.Entry{{Key: "disabled-extensions", Value: "ding@rastersoft.com"}},
+			want: []entry.Entry{
+				{Key: "org/gnome/shell/disabled-extensions", Value: "['ding@rastersoft.com']", Meta: "as"},
+			},
+		},
+		"Enabled and disabled extensions": {
+			entries: []entry.Entry{
+				{Key: "disabled-extensions", Value: "ding@rastersoft.com"},
+				{Key: "enabled-extensions", Value: "dash-to-dock@micxgx.gmail.com"},
+			},
+			want: []entry.Entry{
+				{Key: "org/gnome/shell/disabled-extensions", Value: "['ding@rastersoft.com']", Meta: "as"},
+				{Key: "org/gnome/shell/enabled-extensions", Value: "['dash-to-dock@micxgx.gmail.com']", Meta: "as"},
+			},
+		},
+		"Comments, blank lines and duplicates are ignored": {
+			entries: []entry.Entry{{Key: "enabled-extensions", Value: "# Dock\n\n  dash-to-dock@micxgx.gmail.com  \ndash-to-dock@micxgx.gmail.com\n"}},
+			want: []entry.Entry{
+				{Key: "org/gnome/shell/enabled-extensions", Value: "['dash-to-dock@micxgx.gmail.com']", Meta: "as"},
+			},
+		},
+		"Disabled entry locks the key to its default value": {
+			entries: []entry.Entry{{Key: "enabled-extensions", Disabled: true}},
+			want:    []entry.Entry{{Key: "org/gnome/shell/enabled-extensions", Disabled: true, Meta: "as"}},
+		},
+		"Other dconf entries are kept": {
+			dconfEntries: []entry.Entry{favoriteApps},
+			entries:      []entry.Entry{{Key: "enabled-extensions", Value: "dash-to-dock@micxgx.gmail.com"}},
+			want: []entry.Entry{
+				favoriteApps,
+				{Key: "org/gnome/shell/enabled-extensions", Value: "['dash-to-dock@micxgx.gmail.com']", Meta: "as"},
+			},
+		},
+		"Dconf entry for the same key is replaced": {
+			dconfEntries: []entry.Entry{
+				{Key: "org/gnome/shell/enabled-extensions", Value: "dash-to-dock@micxgx.gmail.com", Meta: "as"},
+				favoriteApps,
+			},
+			entries: []entry.Entry{{Key: "enabled-extensions", Value: "ubuntu-appindicators@ubuntu.com"}},
+			want: []entry.Entry{
+				{Key: "org/gnome/shell/enabled-extensions", Value: "['ubuntu-appindicators@ubuntu.com']", Meta: "as"},
+				favoriteApps,
+			},
+		},
+		"Empty list is skipped": {
+			dconfEntries: []entry.Entry{favoriteApps},
+			entries:      []entry.Entry{{Key: "enabled-extensions", Value: "# None\n"}},
+			want:         []entry.Entry{favoriteApps},
+		},
+		"Unsupported key is skipped": {
+			dconfEntries: []entry.Entry{favoriteApps},
+			entries:      []entry.Entry{{Key: "allowed", Value: "dash-to-dock@micxgx.gmail.com"}},
+			want:         []entry.Entry{favoriteApps},
+		},
+		"No entries": {
+			dconfEntries: []entry.Entry{favoriteApps},
+			want:         []entry.Entry{favoriteApps},
+		},
+
+		"Error on invalid UUID":                        {entries: []entry.Entry{{Key: "enabled-extensions", Value: "dash-to-dock@micxgx.gmail.com', 'evil"}}, wantErr: true},
+		"Error on UUID with spaces":                    {entries: []entry.Entry{{Key: "disabled-extensions", Value: "dash to dock"}}, wantErr: true},
+		"Error on extension both enabled and disabled": {entries: []entry.Entry{{Key: "enabled-extensions", Value: "ding@rastersoft.com"}, {Key: "disabled-extensions", Value: "ding@rastersoft.com"}}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := gnomeextensions.MergeWithDconf(context.Background(), tc.dconfEntries, tc.entries)
+			if tc.wantErr {
+				require.Error(t, err, "MergeWithDconf should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "MergeWithDconf failed but shouldn't have")
+			require.Equal(t, tc.want, got, "MergeWithDconf should return the expected dconf entries")
+		})
+	}
+}
+
+func TestMergeWithDconfIsAppliedByDconf(t *testing.T) {
+	t.Parallel()
+
+	entries := []entry.Entry{
+		{Key: "enabled-extensions", Value: "dash-to-dock@micxgx.gmail.com\nubuntu-appindicators@ubuntu.com"},
+		{Key: "disabled-extensions", Value: "ding@rastersoft.com"},
+	}
+	dconfEntries := []entry.Entry{{Key: "org/gnome/shell/favorite-apps", Value: "['firefox.desktop']", Meta: "as"}}
+
+	merged, err := gnomeextensions.MergeWithDconf(context.Background(), dconfEntries, entries)
+	require.NoError(t, err, "MergeWithDconf failed but shouldn't have")
+
+	dconfDir := t.TempDir()
+	m := dconf.NewWithDconfDir(dconfDir)
+	err = m.ApplyPolicy(context.Background(), "ubuntu", true, merged)
+	require.NoError(t, err, "dconf ApplyPolicy failed but shouldn't have")
+
+	testutils.CompareTreesWithFiltering(t, filepath.Join(dconfDir, "db", "machine.d"), filepath.Join(testutils.GoldenPath(t), "machine.d"), testutils.UpdateEnabled())
+}
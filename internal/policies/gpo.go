@@ -58,7 +58,7 @@ func (g GPO) Format(w io.Writer, withRules, withOverridden bool, alreadyProcesse
 			}
 
 			// Do not add non overridable key to the alreadyProcessedRules override detection map.
-			if r.Strategy == "append" {
+			if r.Strategy == entry.StrategyAppend || r.Strategy == entry.StrategyUnion {
 				continue
 			}
 			alreadyProcessedRules[k] = struct{}{}
@@ -0,0 +1,186 @@
+// Package grub provides a manager to set kernel command line parameters through the GRUB configuration.
+//
+// The policy is only supported on computers. The parameters of the kernel-cmdline entry, separated by spaces or new
+// lines, are appended to GRUB_CMDLINE_LINUX_DEFAULT in a configuration fragment managed by adsys in the GRUB
+// defaults directory. The fragment extends the value set by /etc/default/grub and the other fragments instead of
+// replacing it. The GRUB configuration is then regenerated with update-grub, and the parameters are used on next
+// boot.
+//
+// Each parameter is validated before writing anything, as the fragment is sourced as a shell script.
+//
+// The fragment is only written, and the GRUB configuration regenerated, when its content changed. If there are no
+// parameters to apply, the managed fragment is removed, and the configuration regenerated if it existed.
+package grub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/restart"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+// cfgFileName is the name of the fragment, in the GRUB defaults directory, managed by adsys.
+// It is ordered late so that it is sourced after the fragments of the distribution and other packages.
+const cfgFileName = "99-adsys.cfg"
+
+// parameterRe matches a kernel parameter, with an optional value. Quotes and shell special characters are not
+// allowed as the fragment is sourced as a shell script.
+var parameterRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+(=[A-Za-z0-9_.,:/@+=-]*)?$`)
+
+// Manager applies the kernel command line parameters of the grub policy.
+type Manager struct {
+	grubDir   string
+	updateCmd []string
+}
+
+type options struct {
+	updateCmd []string
+}
+
+// Option reprents an optional function to change the grub manager.
+type Option func(*options)
+
+// WithUpdateCmd overrides the default command regenerating the GRUB configuration.
+func WithUpdateCmd(cmd []string) Option {
+	return func(o *options) {
+		o.updateCmd = cmd
+	}
+}
+
+// New creates a manager with a specific GRUB defaults directory.
+func New(grubDir string, opts ...Option) *Manager {
+	// defaults
+	args := options{
+		updateCmd: []string{"update-grub"},
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Manager{
+		grubDir:   grubDir,
+		updateCmd: args.updateCmd,
+	}
+}
+
+// ApplyPolicy writes the kernel command line parameters of the policy to the managed GRUB fragment and regenerates
+// the GRUB configuration if it changed.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply grub policy to %s", objectName))
+
+	// GRUB policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	cfgPath := filepath.Join(m.grubDir, cfgFileName)
+
+	var content string
+	for _, e := range entries {
+		if e.Key != "kernel-cmdline" {
+			ignored.RecordUnsupportedKey(ctx, "grub", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled {
+			continue
+		}
+		if content, err = parseParameters(e.Value); err != nil {
+			return err
+		}
+	}
+
+	if content == "" {
+		if _, err := os.Stat(cfgPath); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		log.Debug(ctx, gotext.Get("Removing GRUB configuration %s", cfgPath))
+		if removed, err := tamper.Remove(ctx, "grub", cfgPath); err != nil || !removed {
+			return err
+		}
+		if err := m.update(ctx); err != nil {
+			return err
+		}
+		restart.Require(ctx, restart.Hint{Rule: "grub", Kind: restart.Reboot, Details: gotext.Get("kernel parameters removed")})
+		return nil
+	}
+
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(m.grubDir, 0755); err != nil {
+		return err
+	}
+
+	oldContent, err := os.ReadFile(cfgPath)
+	if err == nil && string(oldContent) == content {
+		log.Debug(ctx, gotext.Get("GRUB configuration %s is up to date", cfgPath))
+		return nil
+	}
+
+	log.Debugf(ctx, "Applying grub policy to %s", objectName)
+	// The fragment is world-readable, as it is sourced by update-grub like the other ones of the directory.
+	if written, err := tamper.Write(ctx, "grub", cfgPath, []byte(content), 0644); err != nil || !written {
+		return err
+	}
+
+	if err := m.update(ctx); err != nil {
+		return err
+	}
+	restart.Require(ctx, restart.Hint{Rule: "grub", Kind: restart.Reboot, Details: gotext.Get("kernel parameters changed")})
+	return nil
+}
+
+// update regenerates the GRUB configuration from its defaults.
+func (m *Manager) update(ctx context.Context) error {
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, m.updateCmd[0], m.updateCmd[1:]...)
+	trace.Command(ctx, "grub", cmd.Args)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return errors.New(gotext.Get("failed to update GRUB configuration: %v\n%s", err, string(out)))
+	}
+	return nil
+}
+
+// parseParameters validates the kernel parameters, separated by spaces or new lines, of the kernel-cmdline entry
+// value and returns the content of the managed fragment. Duplicated parameters are only kept once.
+func parseParameters(value string) (content string, err error) {
+	var params []string
+	for _, p := range strings.Fields(value) {
+		if !parameterRe.MatchString(p) {
+			return "", errors.New(gotext.Get("invalid kernel parameter %q", p))
+		}
+		if slices.Contains(params, p) {
+			continue
+		}
+		params = append(params, p)
+	}
+
+	if len(params) == 0 {
+		return "", nil
+	}
+
+	return fmt.Sprintf(`# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+
+GRUB_CMDLINE_LINUX_DEFAULT="${GRUB_CMDLINE_LINUX_DEFAULT} %s"
+`, strings.Join(params, " ")), nil
+}
@@ -0,0 +1,158 @@
+package grub_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/grub"
+	"github.com/ubuntu/adsys/internal/policies/restart"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{{Key: "kernel-cmdline", Value: "mitigations=auto,nosmt audit=1"}}
+
+	tests := map[string]struct {
+		entries []entry.Entry
+		user    bool
+
+		cfgFileAlreadyExists bool
+		readOnlyGrubDir      bool
+		updateCmdError       bool
+
+		wantUpdate bool
+		wantErr    bool
+	}{
+		"Computer, parameters are written and configuration updated": {wantUpdate: true},
+		"Computer, unchanged parameters don't update configuration":  {cfgFileAlreadyExists: true},
+		"Computer, changed parameters are written and updated":       {entries: []entry.Entry{{Key: "kernel-cmdline", Value: "mitigations=off"}}, cfgFileAlreadyExists: true, wantUpdate: true},
+		"Computer, parameters on multiple lines with spaces":         {entries: []entry.Entry{{Key: "kernel-cmdline", Value: "  mitigations=auto,nosmt\n\naudit=1 \n"}}, cfgFileAlreadyExists: true},
+		"Computer, duplicated parameters are only written once":      {entries: []entry.Entry{{Key: "kernel-cmdline", Value: "quiet splash\nquiet audit=1 splash"}}, wantUpdate: true},
+		"Computer, parameters with and without values are accepted":  {entries: []entry.Entry{{Key: "kernel-cmdline", Value: "nosplash console=ttyS0,115200n8 root=/dev/disk/by-label/root rd.luks.uuid=luks-1234 ipv6.disable=1 intel_iommu="}}, wantUpdate: true},
+		"Computer, no entries removes existing parameters":           {entries: []entry.Entry{}, cfgFileAlreadyExists: true, wantUpdate: true},
+		"Computer, disabled entry removes existing parameters":       {entries: []entry.Entry{{Key: "kernel-cmdline", Disabled: true}}, cfgFileAlreadyExists: true, wantUpdate: true},
+		"Computer, only spaces removes existing parameters":          {entries: []entry.Entry{{Key: "kernel-cmdline", Value: " \n "}}, cfgFileAlreadyExists: true, wantUpdate: true},
+		"Computer, no entries and no existing parameters":            {entries: []entry.Entry{}},
+		"Computer, no entries and read-only grub dir":                {entries: []entry.Entry{}, readOnlyGrubDir: true},
+		"Computer, unexpected entry key is ignored":                  {entries: []entry.Entry{{Key: "kernel-cmdline-foo", Value: "quiet"}}},
+		"User, policy is ignored":                                    {user: true},
+		"User, existing parameters are kept":                         {entries: []entry.Entry{}, cfgFileAlreadyExists: true, user: true},
+
+		"Error on parameter with quotes":                 {entries: []entry.Entry{{Key: "kernel-cmdline", Value: `quiet" init="/bin/sh`}}, cfgFileAlreadyExists: true, wantErr: true},
+		"Error on parameter with shell expansion":        {entries: []entry.Entry{{Key: "kernel-cmdline", Value: "quiet init=$(reboot)"}}, wantErr: true},
+		"Error on parameter with shell separator":        {entries: []entry.Entry{{Key: "kernel-cmdline", Value: "quiet;reboot"}}, wantErr: true},
+		"Error on parameter without name":                {entries: []entry.Entry{{Key: "kernel-cmdline", Value: "=1"}}, wantErr: true},
+		"Error on read-only grub dir":                    {readOnlyGrubDir: true, wantErr: true},
+		"Error on update command failing":                {updateCmdError: true, wantUpdate: true, wantErr: true},
+		"Error on update command failing on removed cfg": {entries: []entry.Entry{}, cfgFileAlreadyExists: true, updateCmdError: true, wantUpdate: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			grubDir := filepath.Join(t.TempDir(), "grub.d")
+			if tc.cfgFileAlreadyExists {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "grub_dir"), grubDir)
+			}
+			if tc.readOnlyGrubDir {
+				require.NoError(t, os.MkdirAll(grubDir, 0750), "Setup: can't create grub dir")
+				testutils.MakeReadOnly(t, grubDir)
+			}
+
+			updateOutputFile := filepath.Join(t.TempDir(), "update-grub-output")
+			updateCmd := mockUpdateCmd(t, updateOutputFile)
+			if tc.updateCmdError {
+				updateCmd = append(updateCmd, "-Exit1")
+			}
+
+			m := grub.New(grubDir, grub.WithUpdateCmd(updateCmd))
+
+			hints := &restart.Collector{}
+			err := m.ApplyPolicy(restart.WithCollector(context.Background(), hints), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				// We don't return here as we want to check that the grub dir is in the expected state even in error cases
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.readOnlyGrubDir {
+				// nolint:gosec //false positive, this is a directory
+				require.NoError(t, os.Chmod(grubDir, 0750), "Setup: can't restore grub dir permissions")
+			}
+			testutils.CompareTreesWithFiltering(t, grubDir, filepath.Join(testutils.GoldenPath(t), "etc", "default", "grub.d"), testutils.UpdateEnabled())
+
+			got, err := os.ReadFile(updateOutputFile)
+			if !tc.wantUpdate {
+				require.Error(t, err, "update-grub should not have been called")
+				require.Empty(t, hints.Hints(), "ApplyPolicy should not require a restart")
+				return
+			}
+			require.NoError(t, err, "update-grub should have been called")
+			require.Equal(t, "\n", string(got), "update-grub should have been called once")
+
+			if tc.wantErr {
+				require.Empty(t, hints.Hints(), "ApplyPolicy should not require a restart when the configuration is not updated")
+				return
+			}
+			require.Len(t, hints.Hints(), 1, "ApplyPolicy should require one restart")
+			require.Equal(t, "grub", hints.Hints()[0].Rule, "ApplyPolicy should require a restart for its rule")
+			require.Equal(t, restart.Reboot, hints.Hints()[0].Kind, "ApplyPolicy should require a reboot")
+		})
+	}
+}
+
+func mockUpdateCmd(t *testing.T, outputFile string) []string {
+	t.Helper()
+
+	return []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockUpdateGrub", "--", outputFile}
+}
+
+func TestMockUpdateGrub(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := os.Args
+	for len(args) > 0 {
+		if args[0] != "--" {
+			args = args[1:]
+			continue
+		}
+		args = args[1:]
+		break
+	}
+	// First arg after -- is the output file to write to
+	outputFile, args := args[0], args[1:]
+
+	var wantExit bool
+	if len(args) > 0 && args[len(args)-1] == "-Exit1" {
+		wantExit = true
+		args = args[:len(args)-1]
+	}
+
+	f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	require.NoError(t, err, "Setup: Can't open update-grub output file")
+	defer f.Close()
+	_, err = f.WriteString(strings.Join(args, " ") + "\n")
+	require.NoError(t, err, "Setup: Can't write update-grub output file")
+
+	if wantExit {
+		f.Close()
+		// nolint:gocritic // we want to exit with an error without running the deferred calls
+		os.Exit(1)
+	}
+}
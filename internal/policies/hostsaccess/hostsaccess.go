@@ -0,0 +1,201 @@
+// Package hostsaccess provides a manager to apply TCP wrappers access control rules.
+//
+// The policy is only supported on computers. The rules of the hosts-allow and
+// hosts-deny entries are written in a block delimited by adsys markers in
+// /etc/hosts.allow and /etc/hosts.deny respectively.
+//
+// Local rules, outside of the markers, are preserved. The managed block is
+// appended to the file the first time, and replaced in place afterwards. As the
+// first matching rule is used, local rules set before the block take
+// precedence over the ones of the policy.
+//
+// The syntax of each rule is checked before writing anything. A file is only
+// written when its content changed. If there are no rules for a file, only its
+// managed block is removed.
+package hostsaccess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// beginMarker starts the block of rules managed by adsys.
+	beginMarker = "# BEGIN adsys managed rules"
+	// endMarker ends the block of rules managed by adsys.
+	endMarker = "# END adsys managed rules"
+)
+
+// accessFiles maps the supported entry keys to the file, in the configuration directory, they are written to.
+var accessFiles = map[string]string{
+	"hosts-allow": "hosts.allow",
+	"hosts-deny":  "hosts.deny",
+}
+
+// Manager applies the TCP wrappers access control rules of the policy.
+type Manager struct {
+	etcDir string
+}
+
+// New creates a manager with a specific directory for the hosts.allow and hosts.deny files.
+func New(etcDir string) *Manager {
+	return &Manager{
+		etcDir: etcDir,
+	}
+}
+
+// ApplyPolicy writes the access control rules of the policy in the managed block of hosts.allow and hosts.deny.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply hosts access policy to %s", objectName))
+
+	// Hosts access policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	// Check all rules before writing any file.
+	blocks := make(map[string][]string)
+	for _, e := range entries {
+		if _, ok := accessFiles[e.Key]; !ok {
+			ignored.RecordUnsupportedKey(ctx, "hostsaccess", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled {
+			continue
+		}
+		if blocks[e.Key], err = parseRules(e.Value); err != nil {
+			return err
+		}
+	}
+
+	log.Debugf(ctx, "Applying hosts access policy to %s", objectName)
+	for _, key := range []string{"hosts-allow", "hosts-deny"} {
+		if err := m.updateFile(ctx, filepath.Join(m.etcDir, accessFiles[key]), blocks[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateFile replaces the managed block of the file at path with the rules, or removes it if there is none.
+// Content outside the managed block is preserved.
+func (m *Manager) updateFile(ctx context.Context, path string, rules []string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't update %s", path))
+
+	var mode fs.FileMode = 0644
+	oldContent, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err != nil && len(rules) == 0 {
+		return nil
+	}
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	before, after, found, err := splitManagedBlock(string(oldContent))
+	if err != nil {
+		return err
+	}
+
+	var block string
+	if len(rules) > 0 {
+		block = fmt.Sprintf(`%s
+# Do not edit this block manually.
+# Any changes will be overwritten.
+%s
+%s
+`, beginMarker, strings.Join(rules, "\n"), endMarker)
+	}
+
+	switch {
+	case found && block == "" && after == "":
+		// Drop the blank line separating the removed block from the local rules.
+		before = strings.TrimSuffix(before, "\n")
+		if !strings.HasSuffix(before, "\n") && before != "" {
+			before += "\n"
+		}
+	case !found && block != "" && before != "":
+		// Append the block to the file the first time, separated from the local rules.
+		if !strings.HasSuffix(before, "\n") {
+			before += "\n"
+		}
+		before += "\n"
+	}
+	content := before + block + after
+
+	if content == string(oldContent) {
+		return nil
+	}
+
+	log.Debug(ctx, gotext.Get("Updating managed rules of %s", path))
+	if err := os.WriteFile(path+".new", []byte(content), mode); err != nil {
+		return err
+	}
+	return os.Rename(path+".new", path)
+}
+
+// splitManagedBlock returns the content before and after the managed block, and if it was found. The content is
+// returned unchanged in before if there is no managed block.
+func splitManagedBlock(content string) (before, after string, found bool, err error) {
+	lines := strings.SplitAfter(content, "\n")
+
+	begin, end := -1, -1
+	for i, l := range lines {
+		switch strings.TrimSpace(l) {
+		case beginMarker:
+			if begin != -1 {
+				return "", "", false, errors.New(gotext.Get("multiple adsys managed blocks"))
+			}
+			begin = i
+		case endMarker:
+			if begin == -1 || end != -1 {
+				return "", "", false, errors.New(gotext.Get("unexpected end of adsys managed block"))
+			}
+			end = i
+		}
+	}
+
+	if begin == -1 {
+		return content, "", false, nil
+	}
+	if end == -1 {
+		return "", "", false, errors.New(gotext.Get("unterminated adsys managed block"))
+	}
+
+	return strings.Join(lines[:begin], ""), strings.Join(lines[end+1:], ""), true, nil
+}
+
+// parseRules minimally validates the access control rules, one per line, of the entry value, in the
+// "daemon_list : client_list [ : shell_command ]" format. Empty lines and comments are ignored.
+func parseRules(value string) (rules []string, err error) {
+	for _, l := range strings.Split(value, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		if strings.HasSuffix(l, `\`) {
+			return nil, errors.New(gotext.Get("invalid rule %q: line continuations are not supported", l))
+		}
+		fields := strings.SplitN(l, ":", 3)
+		if len(fields) < 2 || strings.TrimSpace(fields[0]) == "" || strings.TrimSpace(fields[1]) == "" {
+			return nil, errors.New(gotext.Get("invalid rule %q: expecting daemon_list : client_list [ : shell_command ]", l))
+		}
+		rules = append(rules, l)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,91 @@
+package hostsaccess_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/hostsaccess"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{
+		{Key: "hosts-allow", Value: "sshd: 10.0.0.0/255.0.0.0\nsshd, vsftpd : .example.com EXCEPT badhost.example.com"},
+		{Key: "hosts-deny", Value: "ALL: ALL : spawn /bin/echo %c >> /var/log/denied"},
+	}
+
+	tests := map[string]struct {
+		entries  []entry.Entry
+		user     bool
+		existing string
+
+		readOnlyEtcDir bool
+
+		wantErr bool
+	}{
+		"Computer, rules are written in new files":              {},
+		"Computer, rules are appended after local rules":        {existing: "local_rules"},
+		"Computer, managed block is updated":                    {existing: "managed_block"},
+		"Computer, managed block is updated in place":           {existing: "managed_block_in_middle"},
+		"Computer, unchanged managed block is kept":             {existing: "managed_block", entries: []entry.Entry{{Key: "hosts-allow", Value: "sshd: 10.0.0.0/255.0.0.0"}, {Key: "hosts-deny", Value: "ALL: ALL"}}, readOnlyEtcDir: true},
+		"Computer, comments and blank lines are ignored":        {existing: "local_rules", entries: []entry.Entry{{Key: "hosts-allow", Value: "# Internal network\n\n  sshd: 10.0.0.0/255.0.0.0  \n"}}},
+		"Computer, only one file has rules":                     {existing: "managed_block", entries: []entry.Entry{{Key: "hosts-allow", Value: "sshd: 10.0.0.0/255.0.0.0"}}},
+		"Computer, no entries removes managed blocks":           {existing: "managed_block", entries: []entry.Entry{}},
+		"Computer, no entries removes managed block in place":   {existing: "managed_block_in_middle", entries: []entry.Entry{}},
+		"Computer, no entries keeps file with only local rules": {existing: "local_rules", entries: []entry.Entry{}},
+		"Computer, no entries empties file with only block":     {existing: "only_managed_block", entries: []entry.Entry{}},
+		"Computer, no entries and no files":                     {entries: []entry.Entry{}},
+		"Computer, disabled entries remove managed blocks":      {existing: "managed_block", entries: []entry.Entry{{Key: "hosts-allow", Disabled: true}, {Key: "hosts-deny", Disabled: true}}},
+		"Computer, unsupported key is ignored":                  {existing: "local_rules", entries: []entry.Entry{{Key: "hosts-foo", Value: "sshd: ALL"}}},
+		"User, policy is ignored":                               {existing: "local_rules", user: true},
+
+		"Error on rule without client list":         {existing: "managed_block", entries: []entry.Entry{{Key: "hosts-allow", Value: "sshd:"}}, wantErr: true},
+		"Error on rule without daemon list":         {existing: "managed_block", entries: []entry.Entry{{Key: "hosts-deny", Value: ": ALL"}}, wantErr: true},
+		"Error on rule without separator":           {existing: "managed_block", entries: []entry.Entry{{Key: "hosts-deny", Value: "ALL"}}, wantErr: true},
+		"Error on rule with line continuation":      {existing: "managed_block", entries: []entry.Entry{{Key: "hosts-allow", Value: "sshd: 10.0.0.0/255.0.0.0 \\\n  192.168.1."}}, wantErr: true},
+		"Error on invalid rule doesn't write files": {existing: "managed_block", entries: []entry.Entry{{Key: "hosts-allow", Value: "sshd: ALL"}, {Key: "hosts-deny", Value: "ALL"}}, wantErr: true},
+		"Error on unterminated managed block":       {existing: "unterminated_block", wantErr: true},
+		"Error on read-only etc directory":          {existing: "local_rules", readOnlyEtcDir: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			etcDir := filepath.Join(t.TempDir(), "etc")
+			if tc.existing != "" {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), tc.existing), etcDir)
+			} else {
+				require.NoError(t, os.MkdirAll(etcDir, 0750), "Setup: can't create etc dir")
+			}
+			if tc.readOnlyEtcDir {
+				testutils.MakeReadOnly(t, etcDir)
+			}
+
+			m := hostsaccess.New(etcDir)
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				// We don't return here as we want to check that the files are in the expected state even in error cases
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.readOnlyEtcDir {
+				// nolint:gosec //false positive, this is a directory
+				require.NoError(t, os.Chmod(etcDir, 0750), "Setup: can't restore etc dir permissions")
+			}
+			testutils.CompareTreesWithFiltering(t, etcDir, filepath.Join(testutils.GoldenPath(t), "etc"), testutils.UpdateEnabled())
+		})
+	}
+}
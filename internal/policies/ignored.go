@@ -0,0 +1,67 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/decorate"
+	"gopkg.in/yaml.v3"
+)
+
+// IgnoredEntriesFileName is the file, in the policies cache of an object, listing the entries ignored during its
+// last policy refresh.
+const IgnoredEntriesFileName = "ignored"
+
+// saveIgnoredEntries writes the ignored entries in the policies cache directory p. The file is removed if there
+// is none.
+func saveIgnoredEntries(p string, entries []ignored.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't save ignored policy entries to %s", p))
+
+	path := filepath.Join(p, IgnoredEntriesFileName)
+	if len(entries) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	d, err := yaml.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, d, 0600)
+}
+
+// IgnoredEntries returns the entries which were ignored during the last policy refresh of objectName, and why.
+func (m *Manager) IgnoredEntries(ctx context.Context, objectName string, isMachine bool) (entries []ignored.Entry, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to get ignored policy entries for %q (machine: %v)", objectName, isMachine))
+
+	log.Infof(ctx, "Get ignored policy entries for %q (machine: %t)", objectName, isMachine)
+
+	cacheDir, hostname := m.cachedPoliciesDir()
+	if isMachine {
+		objectName = hostname
+	}
+	p := filepath.Join(cacheDir, m.cachedObjectName(objectName))
+
+	if _, err := os.Stat(p); err != nil {
+		return nil, errors.New(gotext.Get("policies were not applied for %q: %v", objectName, err))
+	}
+
+	d, err := os.ReadFile(filepath.Join(p, IgnoredEntriesFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(d, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
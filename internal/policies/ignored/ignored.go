@@ -0,0 +1,141 @@
+// Package ignored collects the policy entries which are skipped or altered while applying policies, with the
+// reason why, so that they can be reported to administrators.
+//
+// The collector is carried by the context given to the policy managers. Recording an entry on a context
+// without collector is a no-op.
+//
+// The entries with a key a policy manager doesn't handle, for instance set for a newer version of adsys, are logged
+// according to the unknown keys mode of the collector. In fail mode, the policy refresh fails once all the policies
+// are applied.
+package ignored
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+)
+
+// Reason is the code of the reason why an entry was ignored.
+type Reason string
+
+const (
+	// UnsupportedKey is an entry with a key the policy manager doesn't handle.
+	UnsupportedKey Reason = "unsupported-key"
+	// InvalidValue is an entry, or an element of its value, which can't be applied.
+	InvalidValue Reason = "invalid-value"
+	// DuplicateValue is an element of an entry value which is already set by another one.
+	DuplicateValue Reason = "duplicate-value"
+	// NormalizedIdentity is a user or group name which was changed to be applied.
+	NormalizedIdentity Reason = "normalized-identity"
+	// ConditionNotMet is an entry whose condition doesn't match the machine facts.
+	ConditionNotMet Reason = "condition-not-met"
+	// GPOFilteredOut is the scope filter of a GPO which doesn't match the machine facts. None of its entries is applied.
+	GPOFilteredOut Reason = "gpo-filtered-out"
+)
+
+// UnknownKeysMode is how the entries with a key unsupported by the policy managers are handled.
+type UnknownKeysMode string
+
+const (
+	// UnknownKeysIgnore records the entries and only logs them at debug level.
+	UnknownKeysIgnore UnknownKeysMode = "ignore"
+	// UnknownKeysWarn records the entries and logs them as warnings. This is the default.
+	UnknownKeysWarn UnknownKeysMode = "warn"
+	// UnknownKeysFail records the entries, logs them as warnings and fails the policy refresh.
+	UnknownKeysFail UnknownKeysMode = "fail"
+)
+
+// Entry is a policy entry, or an element of its value, which was ignored.
+type Entry struct {
+	// Rule is the policy type of the entry, like "privilege".
+	Rule string
+	// Key is the key of the entry in its rule.
+	Key string
+	// Value is the ignored element, or the whole value of the entry.
+	Value string `yaml:",omitempty"`
+	// Reason is the code of the reason why the entry was ignored.
+	Reason Reason
+	// Details is a human readable explanation of the reason.
+	Details string `yaml:",omitempty"`
+}
+
+// Collector gathers the ignored entries recorded during a policy refresh. It is safe for concurrent use.
+type Collector struct {
+	// UnknownKeys is how the entries with an unsupported key are handled. It defaults to UnknownKeysWarn.
+	UnknownKeys UnknownKeysMode
+
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// collectorKey is the context key of the collector.
+type collectorKey struct{}
+
+// WithCollector returns a copy of ctx recording any ignored entry in c.
+func WithCollector(ctx context.Context, c *Collector) context.Context {
+	return context.WithValue(ctx, collectorKey{}, c)
+}
+
+// Record adds the ignored entries to the collector of ctx, if any.
+func Record(ctx context.Context, entries ...Entry) {
+	c, ok := ctx.Value(collectorKey{}).(*Collector)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, entries...)
+}
+
+// RecordUnsupportedKey adds the entry, with a key the policy manager of rule doesn't handle, to the collector of
+// ctx, if any. It is logged as a warning, or only at debug level if unknown keys are ignored.
+func RecordUnsupportedKey(ctx context.Context, rule, key, value string) {
+	msg := gotext.Get("Encountered unsupported key '%s' while parsing %s entries, skipping it", key, rule)
+	if c, ok := ctx.Value(collectorKey{}).(*Collector); ok && c.UnknownKeys == UnknownKeysIgnore {
+		log.Debug(ctx, msg)
+	} else {
+		log.Warning(ctx, msg)
+	}
+
+	Record(ctx, Entry{Rule: rule, Key: key, Value: value, Reason: UnsupportedKey})
+}
+
+// UnknownKeysError returns an error listing the entries with an unsupported key if the collector is in fail mode.
+func (c *Collector) UnknownKeysError() error {
+	if c.UnknownKeys != UnknownKeysFail {
+		return nil
+	}
+
+	var keys []string
+	for _, e := range c.Entries() {
+		if e.Reason != UnsupportedKey {
+			continue
+		}
+		keys = append(keys, fmt.Sprintf("%s/%s", e.Rule, e.Key))
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return errors.New(gotext.Get("policies contain keys unsupported by this version of adsys: %s", strings.Join(slices.Compact(keys), ", ")))
+}
+
+// Entries returns the recorded entries, sorted by rule, key and value, without duplicates.
+func (c *Collector) Entries() []Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := slices.Clone(c.entries)
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return cmp.Or(cmp.Compare(a.Rule, b.Rule), cmp.Compare(a.Key, b.Key), cmp.Compare(a.Value, b.Value),
+			cmp.Compare(a.Reason, b.Reason), cmp.Compare(a.Details, b.Details))
+	})
+	return slices.Compact(entries)
+}
@@ -0,0 +1,125 @@
+package ignored_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+)
+
+func TestCollector(t *testing.T) {
+	t.Parallel()
+
+	unsupported := ignored.Entry{Rule: "proxy", Key: "proxy/foo", Value: "bar", Reason: ignored.UnsupportedKey}
+	normalized := ignored.Entry{Rule: "privilege", Key: "client-admins", Value: `DOMAIN\alice`, Reason: ignored.NormalizedIdentity, Details: `changed to "alice@DOMAIN"`}
+	duplicate := ignored.Entry{Rule: "mount", Key: "system-mounts", Value: "smb://example.com/share", Reason: ignored.DuplicateValue}
+	otherNormalized := ignored.Entry{Rule: "privilege", Key: "client-admins", Value: "bob:", Reason: ignored.NormalizedIdentity, Details: `changed to "bob"`}
+
+	tests := map[string]struct {
+		recorded [][]ignored.Entry
+
+		want []ignored.Entry
+	}{
+		"No entry recorded":                         {},
+		"Entries are sorted by rule, key and value": {recorded: [][]ignored.Entry{{unsupported, otherNormalized}, {duplicate, normalized}}, want: []ignored.Entry{duplicate, normalized, otherNormalized, unsupported}},
+		"Duplicated entries are only returned once": {recorded: [][]ignored.Entry{{unsupported, normalized}, {unsupported}, {normalized}}, want: []ignored.Entry{normalized, unsupported}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &ignored.Collector{}
+			ctx := ignored.WithCollector(context.Background(), c)
+
+			// Managers record concurrently
+			var wg sync.WaitGroup
+			for _, entries := range tc.recorded {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ignored.Record(ctx, entries...)
+				}()
+			}
+			wg.Wait()
+
+			got := c.Entries()
+			if tc.want == nil {
+				require.Empty(t, got, "Entries should return no ignored entry")
+				return
+			}
+			require.Equal(t, tc.want, got, "Entries should return the recorded entries")
+		})
+	}
+}
+
+func TestRecordWithoutCollector(t *testing.T) {
+	t.Parallel()
+
+	require.NotPanics(t, func() {
+		ignored.Record(context.Background(), ignored.Entry{Rule: "proxy", Key: "proxy/foo", Reason: ignored.UnsupportedKey})
+	}, "Record should be a no-op without collector")
+}
+
+func TestUnknownKeys(t *testing.T) {
+	// We capture the global logs, so the test can't be parallel.
+
+	tests := map[string]struct {
+		mode         ignored.UnknownKeysMode
+		noUnknownKey bool
+
+		wantWarning bool
+		wantErr     bool
+	}{
+		"Default mode warns":                          {wantWarning: true},
+		"Warn mode warns":                             {mode: ignored.UnknownKeysWarn, wantWarning: true},
+		"Ignore mode does not warn":                   {mode: ignored.UnknownKeysIgnore},
+		"Fail mode warns and fails":                   {mode: ignored.UnknownKeysFail, wantWarning: true, wantErr: true},
+		"Fail mode does not fail without unknown key": {mode: ignored.UnknownKeysFail, noUnknownKey: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &ignored.Collector{UnknownKeys: tc.mode}
+			ctx := ignored.WithCollector(context.Background(), c)
+
+			// capture log output (set to stderr, but captured when loading logrus)
+			r, w, err := os.Pipe()
+			require.NoError(t, err, "Setup: pipe shouldn’t fail")
+			orig := logrus.StandardLogger().Out
+			logrus.StandardLogger().SetOutput(w)
+
+			ignored.Record(ctx, ignored.Entry{Rule: "mount", Key: "system-mounts", Value: "smb://example.com/share", Reason: ignored.DuplicateValue})
+			want := []ignored.Entry{{Rule: "mount", Key: "system-mounts", Value: "smb://example.com/share", Reason: ignored.DuplicateValue}}
+			if !tc.noUnknownKey {
+				ignored.RecordUnsupportedKey(ctx, "proxy", "proxy/foo", "bar")
+				want = append(want, ignored.Entry{Rule: "proxy", Key: "proxy/foo", Value: "bar", Reason: ignored.UnsupportedKey})
+			}
+
+			logrus.StandardLogger().SetOutput(orig)
+			w.Close()
+			var out bytes.Buffer
+			_, err = io.Copy(&out, r)
+			require.NoError(t, err, "Setup: Couldn't copy logs to buffer")
+
+			require.Equal(t, want, c.Entries(), "Unsupported keys should be recorded in all modes")
+
+			if tc.wantWarning {
+				require.Contains(t, out.String(), "Encountered unsupported key 'proxy/foo'", "Unsupported key should have been logged")
+			} else {
+				require.NotContains(t, out.String(), "Encountered unsupported key", "Unsupported key should not have been logged")
+			}
+
+			err = c.UnknownKeysError()
+			if !tc.wantErr {
+				require.NoError(t, err, "UnknownKeysError should return no error")
+				return
+			}
+			require.ErrorContains(t, err, "proxy/proxy/foo", "UnknownKeysError should list the unsupported keys")
+		})
+	}
+}
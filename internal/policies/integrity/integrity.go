@@ -0,0 +1,50 @@
+// Package integrity compares the files managed by adsys with the content they would have if the applied policy
+// was written again, to detect local edits or other tools clobbering them.
+package integrity
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// File is a file managed by adsys, with its content on disk and the one the applied policy would write.
+type File struct {
+	// Rule is the policy type managing the file, like "privilege".
+	Rule string
+	Path string
+
+	// Want is the content the policy would write. WantExists is false when the policy would remove the file.
+	Want       string
+	WantExists bool
+	// Got is the content on disk. GotExists is false when the file is not on disk.
+	Got       string
+	GotExists bool
+}
+
+// Drifted returns true if the file on disk differs from what the policy would write.
+func (f File) Drifted() bool {
+	if f.WantExists != f.GotExists {
+		return true
+	}
+	return f.WantExists && f.Want != f.Got
+}
+
+// Read returns the file of rule at path, with its content on disk, compared to want.
+// The policy would remove the file if want is nil.
+func Read(rule, path string, want *string) (File, error) {
+	f := File{Rule: rule, Path: path}
+	if want != nil {
+		f.Want, f.WantExists = *want, true
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	} else if err != nil {
+		return File{}, err
+	}
+	f.Got, f.GotExists = string(content), true
+
+	return f, nil
+}
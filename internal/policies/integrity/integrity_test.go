@@ -0,0 +1,65 @@
+package integrity_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/integrity"
+)
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		content *string
+		want    *string
+
+		wantDrifted bool
+		wantErr     bool
+	}{
+		"File matches the policy":       {content: ptr("content\n"), want: ptr("content\n")},
+		"File is absent as expected":    {},
+		"Empty file matches the policy": {content: ptr(""), want: ptr("")},
+
+		"File content differs":                {content: ptr("edited\n"), want: ptr("content\n"), wantDrifted: true},
+		"File is missing":                     {want: ptr("content\n"), wantDrifted: true},
+		"File should have been removed":       {content: ptr("content\n"), wantDrifted: true},
+		"Empty file should have been removed": {content: ptr(""), wantDrifted: true},
+
+		"Error on unreadable file": {content: ptr("content\n"), want: ptr("content\n"), wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "managed")
+			if tc.content != nil {
+				require.NoError(t, os.WriteFile(path, []byte(*tc.content), 0600), "Setup: can't write managed file")
+			}
+			if tc.wantErr {
+				require.NoError(t, os.Remove(path), "Setup: can't remove managed file")
+				require.NoError(t, os.Mkdir(path, 0750), "Setup: can't replace managed file with a directory")
+			}
+
+			f, err := integrity.Read("privilege", path, tc.want)
+			if tc.wantErr {
+				require.Error(t, err, "Read should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "Read failed but shouldn't have")
+
+			require.Equal(t, "privilege", f.Rule, "Read should set the rule of the file")
+			require.Equal(t, path, f.Path, "Read should set the path of the file")
+			require.Equal(t, tc.content != nil, f.GotExists, "Read should report if the file exists")
+			require.Equal(t, tc.want != nil, f.WantExists, "Read should report if the file is expected")
+			require.Equal(t, tc.wantDrifted, f.Drifted(), "Drifted should report if the file differs from the policy")
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
@@ -0,0 +1,327 @@
+// Package jobs is the policy manager for scheduled jobs.
+//
+// Scheduled jobs are scripts from the SYSVOL scripts/ subdirectory which are run
+// on a recurring schedule on the machine, e.g. for a daily cleanup.
+//
+// Each job is set up as a pair of systemd units generated by ADSys: a timer,
+// triggered on the schedule of the job, and a oneshot service running its
+// script. The output of the scripts is logged in the system journal, with the
+// name of the job unit as identifier.
+//
+// On each refresh, the units are reconciled with the policy: timers of new or
+// updated jobs are enabled and started, and the units of jobs which are not in
+// the policy anymore are stopped and removed.
+// If a timer fails to start, it will be logged but the policy will still be applied.
+package jobs
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// unitPrefix is the prefix of all units generated for scheduled jobs.
+	unitPrefix = "adsys-job-"
+	// scriptsDir is the directory, in the jobs directory, where the scripts are dumped.
+	scriptsDir = "scripts"
+)
+
+//go:embed adsys-job-template.service
+var serviceTemplate string
+
+//go:embed adsys-job-template.timer
+var timerTemplate string
+
+// jobName matches the valid names of scheduled jobs, which are used in the unit names.
+var jobName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Manager holds information needed for handling the scheduled jobs policies.
+type Manager struct {
+	jobsDir       string
+	systemUnitDir string
+	systemdCaller systemdCaller
+}
+
+type systemdCaller interface {
+	StartUnit(context.Context, string) error
+	StopUnit(context.Context, string) error
+	EnableUnit(context.Context, string) error
+	DisableUnit(context.Context, string) error
+	DaemonReload(context.Context) error
+}
+
+// New creates a Manager to handle scheduled jobs policies.
+// The scripts of the jobs are stored in the jobs/ subdirectory of stateDir, as the timers persist across reboots.
+func New(stateDir string, systemUnitDir string, systemdCaller systemdCaller) (m *Manager, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to create new scheduled jobs manager"))
+
+	//nolint:gosec // G301 - /etc/systemd/system permissions are 0755, so we should keep the same pattern.
+	if err := os.MkdirAll(systemUnitDir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Manager{
+		jobsDir:       filepath.Join(stateDir, "jobs"),
+		systemUnitDir: systemUnitDir,
+		systemdCaller: systemdCaller,
+	}, nil
+}
+
+// AssetsDumper is a function which uncompress policies assets to a directory.
+type AssetsDumper func(ctx context.Context, relSrc, dest string, uid int, gid int) (err error)
+
+// job is a scheduled job of the policy.
+type job struct {
+	name     string
+	schedule string
+	script   string
+}
+
+// ApplyPolicy reconciles the scheduled jobs units with the list of entries.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry, assetsDumper AssetsDumper) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply scheduled jobs policy to %s", objectName))
+
+	// Scheduled jobs are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	log.Debugf(ctx, "Applying scheduled jobs policy to %s", objectName)
+
+	var jobs []job
+	for _, e := range entries {
+		if e.Key != "scheduled-jobs" {
+			ignored.RecordUnsupportedKey(ctx, "jobs", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled {
+			continue
+		}
+		if jobs, err = parseJobs(e.Value); err != nil {
+			return err
+		}
+	}
+
+	newUnits := make(map[string]string)
+	if len(jobs) > 0 {
+		if err := m.dumpScripts(ctx, jobs, assetsDumper); err != nil {
+			return err
+		}
+		for _, j := range jobs {
+			script := filepath.Join(m.jobsDir, scriptsDir, j.script)
+			newUnits[unitPrefix+j.name+".service"] = fmt.Sprintf(serviceTemplate, j.name, script, j.name)
+			newUnits[unitPrefix+j.name+".timer"] = fmt.Sprintf(timerTemplate, j.name, j.schedule)
+		}
+	}
+
+	// Withdrawn jobs are removed first, so that their scripts are not run anymore.
+	var withdrawn []string
+	for _, name := range m.currentJobUnits() {
+		if _, ok := newUnits[name]; !ok {
+			withdrawn = append(withdrawn, name)
+		}
+	}
+	if err := m.removeUnits(ctx, withdrawn); err != nil {
+		return err
+	}
+
+	var timersToStart []string
+	for name, content := range newUnits {
+		written, err := writeIfChanged(filepath.Join(m.systemUnitDir, name), content)
+		if err != nil {
+			return err
+		}
+		if !written {
+			continue
+		}
+		// The timer is restarted if either of the job units changed.
+		timer := strings.TrimSuffix(strings.TrimSuffix(name, ".service"), ".timer") + ".timer"
+		if !slices.Contains(timersToStart, timer) {
+			timersToStart = append(timersToStart, timer)
+		}
+	}
+	slices.Sort(timersToStart)
+
+	if len(jobs) == 0 {
+		if err := os.RemoveAll(m.jobsDir); err != nil {
+			return err
+		}
+	}
+
+	if len(withdrawn) == 0 && len(timersToStart) == 0 {
+		return nil
+	}
+
+	if err := m.systemdCaller.DaemonReload(ctx); err != nil {
+		return err
+	}
+
+	for _, timer := range timersToStart {
+		if err := m.systemdCaller.EnableUnit(ctx, timer); err != nil {
+			return err
+		}
+		if err := m.systemdCaller.StartUnit(ctx, timer); err != nil {
+			log.Warning(ctx, gotext.Get("failed to start unit %q: %v", timer, err))
+		}
+	}
+
+	return nil
+}
+
+// dumpScripts replaces the scripts of the jobs directory with the ones of the policy assets, and checks that all
+// jobs scripts exist.
+func (m *Manager) dumpScripts(ctx context.Context, jobs []job, assetsDumper AssetsDumper) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't save scheduled jobs scripts"))
+
+	if err := os.MkdirAll(m.jobsDir, 0700); err != nil {
+		return err
+	}
+
+	// Dump assets aside first, to not alter the scripts of the current jobs on failure.
+	newScriptsPath := filepath.Join(m.jobsDir, scriptsDir+".new")
+	if err := os.RemoveAll(newScriptsPath); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(newScriptsPath)
+		}
+	}()
+	if err := assetsDumper(ctx, "scripts/", newScriptsPath, -1, -1); err != nil {
+		return err
+	}
+
+	for _, j := range jobs {
+		p := filepath.Join(newScriptsPath, j.script)
+		info, err := os.Stat(p)
+		if errors.Is(err, os.ErrNotExist) {
+			return errors.New(gotext.Get("script %q of job %q doesn't exist in SYSVOL scripts/ subdirectory", j.script, j.name))
+		} else if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return errors.New(gotext.Get("script %q of job %q is a directory and not a file to execute", j.script, j.name))
+		}
+		// nolint:gosec // G302 - scripts need rx permissions
+		if err := os.Chmod(p, 0550); err != nil {
+			return err
+		}
+	}
+
+	scriptsPath := filepath.Join(m.jobsDir, scriptsDir)
+	if err := os.RemoveAll(scriptsPath); err != nil {
+		return err
+	}
+	return os.Rename(newScriptsPath, scriptsPath)
+}
+
+// parseJobs parses the scheduled jobs, one per line, of the entry value, in the "name;schedule;script" format.
+// The schedule is a systemd calendar event expression and the script is relative to the SYSVOL scripts/
+// subdirectory. Empty lines and comments are ignored.
+func parseJobs(value string) (jobs []job, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to parse scheduled jobs"))
+
+	for _, l := range strings.Split(value, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		fields := strings.Split(l, ";")
+		if len(fields) != 3 {
+			return nil, errors.New(gotext.Get("job %q is badly formatted: expecting name;schedule;script", l))
+		}
+		j := job{
+			name:     strings.TrimSpace(fields[0]),
+			schedule: strings.TrimSpace(fields[1]),
+			script:   strings.TrimSpace(fields[2]),
+		}
+
+		if !jobName.MatchString(j.name) {
+			return nil, errors.New(gotext.Get("job %q has an invalid name: only letters, digits, - and _ are allowed", l))
+		}
+		if slices.ContainsFunc(jobs, func(o job) bool { return o.name == j.name }) {
+			return nil, errors.New(gotext.Get("job %q is defined multiple times", j.name))
+		}
+		if j.schedule == "" {
+			return nil, errors.New(gotext.Get("job %q has no schedule", j.name))
+		}
+		if !filepath.IsLocal(j.script) {
+			return nil, errors.New(gotext.Get("job %q has an invalid script path %q", j.name, j.script))
+		}
+		// The script path is quoted in the service unit.
+		if strings.ContainsAny(j.script, "\"\\%$") {
+			return nil, errors.New(gotext.Get("job %q has an invalid character in script path %q", j.name, j.script))
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, nil
+}
+
+// removeUnits stops the timers and removes the units of withdrawn jobs.
+func (m *Manager) removeUnits(ctx context.Context, units []string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to clean up the scheduled jobs units"))
+
+	for _, unit := range units {
+		if strings.HasSuffix(unit, ".timer") {
+			// Tries to stop the timer before disabling and removing it.
+			if err := m.systemdCaller.StopUnit(ctx, unit); err != nil {
+				log.Warning(ctx, gotext.Get("Failed to stop unit %q: %v", unit, err))
+			}
+			if err := m.systemdCaller.DisableUnit(ctx, unit); err != nil {
+				return err
+			}
+		}
+
+		if err := os.Remove(filepath.Join(m.systemUnitDir, unit)); err != nil {
+			return errors.New(gotext.Get("could not remove file %q: %v", unit, err))
+		}
+	}
+
+	return nil
+}
+
+// currentJobUnits returns the names of the scheduled jobs units in the unit directory, timers first.
+func (m *Manager) currentJobUnits() []string {
+	var units []string
+	for _, ext := range []string{".timer", ".service"} {
+		paths, _ := filepath.Glob(filepath.Join(m.systemUnitDir, unitPrefix+"*"+ext))
+		for _, path := range paths {
+			units = append(units, filepath.Base(path))
+		}
+	}
+	return units
+}
+
+// writeIfChanged will only write to path if content is different from current content.
+func writeIfChanged(path string, content string) (done bool, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't save %s", path))
+
+	if oldContent, err := os.ReadFile(path); err == nil && string(oldContent) == content {
+		return false, nil
+	}
+
+	//nolint:gosec // G306 - This asset needs to be world-readable.
+	if err := os.WriteFile(path+".new", []byte(content), 0644); err != nil {
+		return false, err
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,174 @@
+package jobs_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/jobs"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	const defaultJobs = "cleanup;daily;cleanup.sh\nreport;Mon *-*-* 02:00:00;nested/report.sh"
+
+	tests := map[string]struct {
+		entries    []entry.Entry
+		secondCall []entry.Entry
+		user       bool
+
+		assetsDumperErr bool
+		failOn          string
+
+		wantErr           bool
+		wantErrSecondCall bool
+	}{
+		"Computer, jobs units are generated and timers started": {},
+		"Computer, comments and blank lines are ignored":        {entries: []entry.Entry{{Key: "scheduled-jobs", Value: "# Daily cleanup\n\n  cleanup ; daily ; cleanup.sh  \n"}}},
+		"Computer, script path with spaces":                     {entries: []entry.Entry{{Key: "scheduled-jobs", Value: "backup;weekly;backup with spaces.sh"}}},
+		"Computer, unsupported key is ignored":                  {entries: []entry.Entry{{Key: "scheduled-foo", Value: "cleanup;daily;cleanup.sh"}}},
+		"Computer, no entries":                                  {entries: []entry.Entry{}},
+		"Computer, disabled entry":                              {entries: []entry.Entry{{Key: "scheduled-jobs", Value: "cleanup;daily;cleanup.sh", Disabled: true}}},
+		"Computer, only warn when starting timers fails":        {failOn: "start"},
+		"User, policy is ignored":                               {user: true},
+
+		// Reconciliation
+		"Computer, unchanged jobs are not reloaded":                      {secondCall: []entry.Entry{{Key: "scheduled-jobs", Value: defaultJobs}}},
+		"Computer, removed job units are withdrawn":                      {secondCall: []entry.Entry{{Key: "scheduled-jobs", Value: "cleanup;daily;cleanup.sh"}}},
+		"Computer, updated schedule restarts its timer":                  {secondCall: []entry.Entry{{Key: "scheduled-jobs", Value: "cleanup;hourly;cleanup.sh\nreport;Mon *-*-* 02:00:00;nested/report.sh"}}},
+		"Computer, updated script restarts its timer":                    {secondCall: []entry.Entry{{Key: "scheduled-jobs", Value: "cleanup;daily;nested/report.sh\nreport;Mon *-*-* 02:00:00;nested/report.sh"}}},
+		"Computer, new job is added":                                     {entries: []entry.Entry{{Key: "scheduled-jobs", Value: "cleanup;daily;cleanup.sh"}}, secondCall: []entry.Entry{{Key: "scheduled-jobs", Value: defaultJobs}}},
+		"Computer, no entries removes all jobs":                          {secondCall: []entry.Entry{}},
+		"Computer, disabled entry removes all jobs":                      {secondCall: []entry.Entry{{Key: "scheduled-jobs", Value: defaultJobs, Disabled: true}}},
+		"Computer, only warn when stopping timers of removed jobs fails": {failOn: "stop", secondCall: []entry.Entry{}},
+
+		// Error cases
+		"Error on job without schedule":                          {entries: []entry.Entry{{Key: "scheduled-jobs", Value: "cleanup;;cleanup.sh"}}, wantErr: true},
+		"Error on job with missing field":                        {entries: []entry.Entry{{Key: "scheduled-jobs", Value: "cleanup;cleanup.sh"}}, wantErr: true},
+		"Error on job with invalid name":                         {entries: []entry.Entry{{Key: "scheduled-jobs", Value: "clean up;daily;cleanup.sh"}}, wantErr: true},
+		"Error on duplicated job name":                           {entries: []entry.Entry{{Key: "scheduled-jobs", Value: "cleanup;daily;cleanup.sh\ncleanup;hourly;cleanup.sh"}}, wantErr: true},
+		"Error on absolute script path":                          {entries: []entry.Entry{{Key: "scheduled-jobs", Value: "cleanup;daily;/bin/true"}}, wantErr: true},
+		"Error on script path outside of scripts directory":      {entries: []entry.Entry{{Key: "scheduled-jobs", Value: "cleanup;daily;../cleanup.sh"}}, wantErr: true},
+		"Error on script path with quote":                        {entries: []entry.Entry{{Key: "scheduled-jobs", Value: `cleanup;daily;clean"up.sh`}}, wantErr: true},
+		"Error on absent script":                                 {entries: []entry.Entry{{Key: "scheduled-jobs", Value: "cleanup;daily;absent.sh"}}, wantErr: true},
+		"Error on script being a directory":                      {entries: []entry.Entry{{Key: "scheduled-jobs", Value: "cleanup;daily;adir"}}, wantErr: true},
+		"Error on assets dumping failing":                        {assetsDumperErr: true, wantErr: true},
+		"Error on daemon reload failing":                         {failOn: "daemon-reload", wantErr: true},
+		"Error on enabling timers failing":                       {failOn: "enable", wantErr: true},
+		"Error on disabling timers of removed jobs failing":      {failOn: "disable", secondCall: []entry.Entry{}, wantErrSecondCall: true},
+		"Error on invalid job keeps current jobs on second call": {secondCall: []entry.Entry{{Key: "scheduled-jobs", Value: "cleanup;daily;absent.sh"}}, wantErrSecondCall: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = []entry.Entry{{Key: "scheduled-jobs", Value: defaultJobs}}
+			}
+
+			rootDir := t.TempDir()
+			stateDir := filepath.Join(rootDir, "var", "lib", "adsys")
+			systemUnitDir := filepath.Join(rootDir, "etc", "systemd", "system")
+
+			systemdCaller := &mockSystemdCaller{}
+			// Only fail on the call where the step is exercised.
+			if tc.secondCall == nil {
+				systemdCaller.failOn = tc.failOn
+			}
+
+			m, err := jobs.New(stateDir, systemUnitDir, systemdCaller)
+			require.NoError(t, err, "Setup: Failed to create manager for the tests.")
+
+			mockAssetsDumper := testutils.MockAssetsDumper{Err: tc.assetsDumperErr, Path: "scripts/", T: t}
+			err = m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries, mockAssetsDumper.SaveAssetsTo)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.secondCall != nil {
+				systemdCaller.record("--- second call")
+				systemdCaller.failOn = tc.failOn
+				err = m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.secondCall, mockAssetsDumper.SaveAssetsTo)
+				if tc.wantErrSecondCall {
+					require.Error(t, err, "Second ApplyPolicy should have failed but didn't")
+				} else {
+					require.NoError(t, err, "Second ApplyPolicy failed but shouldn't have")
+				}
+			}
+
+			normalizeUnits(t, systemUnitDir, rootDir)
+			testutils.CompareTreesWithFiltering(t, rootDir, testutils.GoldenPath(t), testutils.UpdateEnabled())
+
+			got := strings.Join(systemdCaller.calls, "\n") + "\n"
+			want := testutils.LoadWithUpdateFromGolden(t, got, testutils.WithGoldenPath(testutils.GoldenPath(t)+"-systemd_calls"))
+			require.Equal(t, want, got, "Systemd calls don't match")
+		})
+	}
+}
+
+// normalizeUnits removes the temporary root directory from the script paths of the generated service units.
+func normalizeUnits(t *testing.T, systemUnitDir, rootDir string) {
+	t.Helper()
+
+	services, err := filepath.Glob(filepath.Join(systemUnitDir, "*.service"))
+	require.NoError(t, err, "Setup: can't list service units")
+	for _, p := range services {
+		content, err := os.ReadFile(p)
+		require.NoError(t, err, "Setup: can't read service unit")
+		content = []byte(strings.ReplaceAll(string(content), rootDir, ""))
+		// nolint:gosec // G306 - This asset needs to be world-readable.
+		require.NoError(t, os.WriteFile(p, content, 0644), "Setup: can't normalize service unit")
+	}
+}
+
+type mockSystemdCaller struct {
+	failOn string
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (s *mockSystemdCaller) record(call string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+}
+
+func (s *mockSystemdCaller) call(step, unit string) error {
+	s.record(strings.TrimSpace(fmt.Sprintf("%s %s", step, unit)))
+	if s.failOn == step {
+		return fmt.Errorf("failed to %s %s", step, unit)
+	}
+	return nil
+}
+
+func (s *mockSystemdCaller) StartUnit(_ context.Context, unit string) error {
+	return s.call("start", unit)
+}
+
+func (s *mockSystemdCaller) StopUnit(_ context.Context, unit string) error {
+	return s.call("stop", unit)
+}
+
+func (s *mockSystemdCaller) EnableUnit(_ context.Context, unit string) error {
+	return s.call("enable", unit)
+}
+
+func (s *mockSystemdCaller) DisableUnit(_ context.Context, unit string) error {
+	return s.call("disable", unit)
+}
+
+func (s *mockSystemdCaller) DaemonReload(_ context.Context) error {
+	return s.call("daemon-reload", "")
+}
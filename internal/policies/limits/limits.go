@@ -0,0 +1,180 @@
+// Package limits provides a manager to set the resource limits of the user sessions.
+//
+// The policy is only supported on computers. The limit rules of the resource-limits
+// entry, one "<domain> <type> <item> <value>" rule per line as in limits.conf(5),
+// are written to a file managed by adsys in the limits.d configuration directory,
+// which is read by pam_limits when the sessions start.
+//
+// Each field of each rule is validated against the values supported by pam_limits
+// before writing anything.
+//
+// The file is only written when its content changed. If there are no rules to set,
+// the managed file is removed. Either way, the users have to log in again for the
+// change to take effect, which is reported as a relogin hint.
+package limits
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/restart"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+	"github.com/ubuntu/decorate"
+)
+
+// limitsFileName is the name of the file, in the limits.d directory, managed by adsys.
+// It is ordered late so that its rules override the ones set by the distribution and other packages.
+const limitsFileName = "99-adsys.conf"
+
+var (
+	// domainRe matches the valid domains: a user, a @group, a %group for maxlogins, a wildcard, or a uid or
+	// gid range.
+	domainRe = regexp.MustCompile(`^(\*|%|[%@]?[A-Za-z0-9_][A-Za-z0-9_.@\\-]*\$?|@?[0-9]*:[0-9]*)$`)
+
+	// types are the supported limit types.
+	types = []string{"soft", "hard", "-"}
+
+	// items are the supported limit items.
+	items = []string{"core", "data", "fsize", "memlock", "nofile", "rss", "stack", "cpu", "nproc", "as",
+		"maxlogins", "maxsyslogins", "nonewprivs", "priority", "locks", "sigpending", "msgqueue", "nice", "rtprio"}
+
+	// signedItems are the items whose value can be negative.
+	signedItems = []string{"priority", "nice"}
+)
+
+// Manager sets the resource limits of the limits policy.
+type Manager struct {
+	limitsDir string
+}
+
+// New creates a manager with a specific limits.d configuration directory.
+func New(limitsDir string) *Manager {
+	return &Manager{
+		limitsDir: limitsDir,
+	}
+}
+
+// ApplyPolicy writes the limit rules of the policy to the managed limits.d file, or removes it if there are
+// none.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply limits policy to %s", objectName))
+
+	// Limits policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	limitsPath := filepath.Join(m.limitsDir, limitsFileName)
+
+	var content string
+	for _, e := range entries {
+		if e.Key != "resource-limits" {
+			ignored.RecordUnsupportedKey(ctx, "limits", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled {
+			continue
+		}
+		if content, err = parseRules(e.Value); err != nil {
+			return err
+		}
+	}
+
+	if content == "" {
+		log.Debug(ctx, gotext.Get("Removing limits configuration %s", limitsPath))
+		if removed, err := tamper.Remove(ctx, "limits", limitsPath); err != nil || !removed {
+			return err
+		}
+		restart.Require(ctx, restart.Hint{Rule: "limits", Kind: restart.Relogin, Details: gotext.Get("resource limits removed")})
+		return nil
+	}
+
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(m.limitsDir, 0755); err != nil {
+		return err
+	}
+
+	oldContent, err := os.ReadFile(limitsPath)
+	if err == nil && string(oldContent) == content {
+		log.Debug(ctx, gotext.Get("Limits configuration %s is up to date", limitsPath))
+		return nil
+	}
+
+	log.Debugf(ctx, "Applying limits policy to %s", objectName)
+	// The limits.d configuration is world-readable, as in the distribution.
+	if written, err := tamper.Write(ctx, "limits", limitsPath, []byte(content), 0644); err != nil || !written {
+		return err
+	}
+	restart.Require(ctx, restart.Hint{Rule: "limits", Kind: restart.Relogin, Details: gotext.Get("resource limits changed")})
+	return nil
+}
+
+// parseRules validates the limit rules, one "<domain> <type> <item> <value>" per line, of the entry value and
+// returns the content of the managed limits.d file. Empty lines and comments are ignored.
+func parseRules(value string) (content string, err error) {
+	var rules []string
+	for _, l := range strings.Split(value, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		fields := strings.Fields(l)
+		if len(fields) != 4 {
+			return "", errors.New(gotext.Get("invalid limit rule %q: expecting <domain> <type> <item> <value>", l))
+		}
+		domain, typ, item, v := fields[0], fields[1], fields[2], fields[3]
+
+		if !domainRe.MatchString(domain) {
+			return "", errors.New(gotext.Get("invalid domain %q in limit rule %q: expecting a user, @group, %%group, * or an id range", domain, l))
+		}
+		if !slices.Contains(types, typ) {
+			return "", errors.New(gotext.Get("invalid type %q in limit rule %q: expecting one of %s", typ, l, strings.Join(types, ", ")))
+		}
+		if !slices.Contains(items, item) {
+			return "", errors.New(gotext.Get("invalid item %q in limit rule %q: expecting one of %s", item, l, strings.Join(items, ", ")))
+		}
+		if err := checkValue(item, v); err != nil {
+			return "", errors.New(gotext.Get("invalid value %q in limit rule %q: %v", v, l, err))
+		}
+
+		rules = append(rules, strings.Join(fields, "\t"))
+	}
+
+	if len(rules) == 0 {
+		return "", nil
+	}
+
+	return fmt.Sprintf(`# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+
+%s
+`, strings.Join(rules, "\n")), nil
+}
+
+// checkValue returns an error if v is not a valid value for item.
+func checkValue(item, v string) error {
+	if v == "unlimited" || v == "infinity" || v == "-1" {
+		return nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return errors.New(gotext.Get("expecting a number, unlimited or infinity"))
+	}
+	if n < 0 && !slices.Contains(signedItems, item) {
+		return errors.New(gotext.Get("only %s can be negative", strings.Join(signedItems, " and ")))
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package limits_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/limits"
+	"github.com/ubuntu/adsys/internal/policies/restart"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{{Key: "resource-limits", Value: "* soft nofile 4096\n@developers hard nproc 2048"}}
+
+	tests := map[string]struct {
+		entries []entry.Entry
+		user    bool
+
+		limitsFileAlreadyExists bool
+		readOnlyLimitsDir       bool
+
+		wantRelogin bool
+		wantErr     bool
+	}{
+		"Computer, rules are written":                    {wantRelogin: true},
+		"Computer, unchanged rules are kept":             {limitsFileAlreadyExists: true},
+		"Computer, changed rules are written":            {entries: []entry.Entry{{Key: "resource-limits", Value: "* hard nofile 8192"}}, limitsFileAlreadyExists: true, wantRelogin: true},
+		"Computer, comments and blank lines are ignored": {entries: []entry.Entry{{Key: "resource-limits", Value: "# Files\n\n  *   soft  nofile   4096 \n@developers hard nproc 2048\n"}}, limitsFileAlreadyExists: true},
+		"Computer, all domain forms are supported": {entries: []entry.Entry{{Key: "resource-limits", Value: "bob@example.com soft nofile 4096\n" +
+			"@developers@example.com hard nproc 100\n%admins - maxlogins 2\n% - maxsyslogins 50\n1000: soft core 0\n@2000:3000 hard as unlimited\n:999 - memlock infinity"}}, wantRelogin: true},
+		"Computer, negative values are allowed for nice and priority": {entries: []entry.Entry{{Key: "resource-limits", Value: "@audio - nice -10\n@audio - priority -5\n* soft stack -1"}}, wantRelogin: true},
+		"Computer, no entries removes existing rules":                 {entries: []entry.Entry{}, limitsFileAlreadyExists: true, wantRelogin: true},
+		"Computer, disabled entry removes existing rules":             {entries: []entry.Entry{{Key: "resource-limits", Disabled: true}}, limitsFileAlreadyExists: true, wantRelogin: true},
+		"Computer, only comments removes existing rules":              {entries: []entry.Entry{{Key: "resource-limits", Value: "# nothing\n"}}, limitsFileAlreadyExists: true, wantRelogin: true},
+		"Computer, no entries and no existing rules":                  {entries: []entry.Entry{}},
+		"Computer, no entries and read-only limits dir":               {entries: []entry.Entry{}, readOnlyLimitsDir: true},
+		"Computer, unexpected entry key is ignored":                   {entries: []entry.Entry{{Key: "limits-foo", Value: "* soft nofile 4096"}}},
+		"User, policy is ignored":                                     {user: true},
+		"User, existing rules are kept":                               {entries: []entry.Entry{}, limitsFileAlreadyExists: true, user: true},
+
+		"Error on invalid item":                        {entries: []entry.Entry{{Key: "resource-limits", Value: "* soft nofile 4096\n* soft openfiles 4096"}}, limitsFileAlreadyExists: true, wantErr: true},
+		"Error on invalid type":                        {entries: []entry.Entry{{Key: "resource-limits", Value: "* both nofile 4096"}}, limitsFileAlreadyExists: true, wantErr: true},
+		"Error on invalid domain":                      {entries: []entry.Entry{{Key: "resource-limits", Value: "bob:alice soft nofile 4096"}}, wantErr: true},
+		"Error on value not being a number":            {entries: []entry.Entry{{Key: "resource-limits", Value: "* soft nofile many"}}, wantErr: true},
+		"Error on negative value for unsigned item":    {entries: []entry.Entry{{Key: "resource-limits", Value: "* soft nofile -10"}}, wantErr: true},
+		"Error on rule with missing field":             {entries: []entry.Entry{{Key: "resource-limits", Value: "* soft nofile"}}, wantErr: true},
+		"Error on rule with extra field":               {entries: []entry.Entry{{Key: "resource-limits", Value: "* soft nofile 4096 # files"}}, wantErr: true},
+		"Error on read-only limits dir":                {readOnlyLimitsDir: true, wantErr: true},
+		"Error on read-only limits dir removing rules": {entries: []entry.Entry{}, limitsFileAlreadyExists: true, readOnlyLimitsDir: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			limitsDir := filepath.Join(t.TempDir(), "limits.d")
+			if tc.limitsFileAlreadyExists {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "limits_dir"), limitsDir)
+			}
+			if tc.readOnlyLimitsDir {
+				require.NoError(t, os.MkdirAll(limitsDir, 0750), "Setup: can't create limits dir")
+				testutils.MakeReadOnly(t, limitsDir)
+			}
+
+			m := limits.New(limitsDir)
+
+			hints := &restart.Collector{}
+			err := m.ApplyPolicy(restart.WithCollector(context.Background(), hints), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				// We don't return here as we want to check that the limits dir is in the expected state even in error cases
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.readOnlyLimitsDir {
+				// nolint:gosec //false positive, this is a directory
+				require.NoError(t, os.Chmod(limitsDir, 0750), "Setup: can't restore limits dir permissions")
+			}
+			testutils.CompareTreesWithFiltering(t, limitsDir, filepath.Join(testutils.GoldenPath(t), "etc", "security", "limits.d"), testutils.UpdateEnabled())
+
+			if !tc.wantRelogin {
+				require.Empty(t, hints.Hints(), "ApplyPolicy should not require a restart")
+				return
+			}
+			require.Len(t, hints.Hints(), 1, "ApplyPolicy should require one restart")
+			require.Equal(t, "limits", hints.Hints()[0].Rule, "ApplyPolicy should require a restart for its rule")
+			require.Equal(t, restart.Relogin, hints.Hints()[0].Kind, "ApplyPolicy should require a relogin")
+		})
+	}
+}
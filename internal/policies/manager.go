@@ -24,38 +24,80 @@
 package policies
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/leonelquinteros/gotext"
 	"github.com/ubuntu/adsys/internal/ad/backends"
 	"github.com/ubuntu/adsys/internal/consts"
+	"github.com/ubuntu/adsys/internal/facts"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/policies/apparmor"
+	"github.com/ubuntu/adsys/internal/policies/appearance"
+	"github.com/ubuntu/adsys/internal/policies/attributes"
+	"github.com/ubuntu/adsys/internal/policies/audit"
+	"github.com/ubuntu/adsys/internal/policies/banner"
+	"github.com/ubuntu/adsys/internal/policies/catrust"
 	"github.com/ubuntu/adsys/internal/policies/certificate"
+	"github.com/ubuntu/adsys/internal/policies/cooldown"
 	"github.com/ubuntu/adsys/internal/policies/dconf"
+	"github.com/ubuntu/adsys/internal/policies/effective"
 	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/environment"
+	"github.com/ubuntu/adsys/internal/policies/extensions"
+	"github.com/ubuntu/adsys/internal/policies/firewall"
 	"github.com/ubuntu/adsys/internal/policies/gdm"
+	"github.com/ubuntu/adsys/internal/policies/gnomeextensions"
+	"github.com/ubuntu/adsys/internal/policies/grub"
+	"github.com/ubuntu/adsys/internal/policies/hostsaccess"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/integrity"
+	"github.com/ubuntu/adsys/internal/policies/jobs"
+	"github.com/ubuntu/adsys/internal/policies/limits"
 	"github.com/ubuntu/adsys/internal/policies/mount"
+	"github.com/ubuntu/adsys/internal/policies/netplan"
+	"github.com/ubuntu/adsys/internal/policies/network"
+	"github.com/ubuntu/adsys/internal/policies/pam"
 	"github.com/ubuntu/adsys/internal/policies/privilege"
 	"github.com/ubuntu/adsys/internal/policies/proxy"
+	"github.com/ubuntu/adsys/internal/policies/restart"
 	"github.com/ubuntu/adsys/internal/policies/scripts"
+	"github.com/ubuntu/adsys/internal/policies/secrets"
+	"github.com/ubuntu/adsys/internal/policies/snaps"
+	"github.com/ubuntu/adsys/internal/policies/sysctl"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+	"github.com/ubuntu/adsys/internal/policies/timedate"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/adsys/internal/policies/units"
 	"github.com/ubuntu/adsys/internal/systemd"
+	"github.com/ubuntu/adsys/internal/telemetry"
 	"github.com/ubuntu/decorate"
 	"golang.org/x/sync/errgroup"
 )
 
 // ProOnlyRules are the rules that are only available for Pro subscribers. They
 // will be filtered otherwise.
-var ProOnlyRules = []string{"privilege", "scripts", "mount", "apparmor", "proxy", "certificate"}
+var ProOnlyRules = []string{"privilege", "scripts", "mount", "apparmor", "proxy", "certificate", "catrust", "sysctl", "hostsaccess", "jobs", "network", "firewall", "environment", "banner", "limits", "timedate", "pam", "audit", "grub", "appearance", "snaps", "units", "netplan"}
+
+// supportedRules are all the rules handled by a policy manager.
+var supportedRules = append([]string{"dconf", "gnomeextensions", "gdm"}, ProOnlyRules...)
+
+// tamperRules are the rules whose policy manager checks if its managed files were modified locally before replacing
+// them. The proxy policy only checks the Docker daemon drop-in: ubuntu-proxy-manager writes the system proxy
+// settings, and the other files are merged with the local settings of the users.
+var tamperRules = []string{"audit", "environment", "grub", "limits", "netplan", "privilege", "proxy", "sysctl", "units"}
 
 // Manager handles all managers for various policy handlers.
 type Manager struct {
@@ -72,6 +114,60 @@ type Manager struct {
 	apparmor    *apparmor.Manager
 	proxy       *proxy.Manager
 	certificate *certificate.Manager
+	catrust     *catrust.Manager
+	sysctl      *sysctl.Manager
+	hostsAccess *hostsaccess.Manager
+	jobs        *jobs.Manager
+	network     *network.Manager
+	firewall    *firewall.Manager
+	environment *environment.Manager
+	banner      *banner.Manager
+	limits      *limits.Manager
+	timedate    *timedate.Manager
+	pam         *pam.Manager
+	audit       *audit.Manager
+	grub        *grub.Manager
+	appearance  *appearance.Manager
+	snaps       *snaps.Manager
+	units       *units.Manager
+	netplan     *netplan.Manager
+
+	// extensions applies the policy types declared by third parties.
+	extensions *extensions.Manager
+
+	facts factsCollector
+
+	// managedDirs are checked before applying any policy.
+	managedDirs []managedDir
+
+	// purgeablePaths are the glob patterns of the files only owned by each policy manager, which can be purged once
+	// the policy is not used anymore.
+	purgeablePaths map[string][]string
+	systemUnitDir  string
+	systemdCaller  systemdCaller
+
+	// tracedPaths are the glob patterns of the files managed by each policy manager, snapshotted in trace bundles and
+	// backed up by transactional refreshes.
+	tracedPaths map[string][]string
+
+	// transactional rolls back the changes of all policy managers when any of them fails.
+	transactional bool
+	// transactionMu serializes transactional refreshes, as objects share managed files.
+	transactionMu *sync.Mutex
+
+	unknownKeys ignored.UnknownKeysMode
+
+	// refreshTimeout is the deadline of applying the policies of an object. 0 means no deadline.
+	refreshTimeout time.Duration
+
+	// telemetry exports the refreshes spans and metrics. It is nil when no endpoint is configured.
+	telemetry *telemetry.Exporter
+
+	// tamper handles the managed files modified locally. It is nil when no policy type handles them.
+	tamper *tamper.Guard
+
+	// replay is set when the manager only replays an imported cache.
+	replay atomic.Pointer[replayState]
 
 	subscriptionDbus dbus.BusObject
 
@@ -92,24 +188,84 @@ type systemdCaller interface {
 	DaemonReload(context.Context) error
 }
 
+// factsCollector is the interface to collect the machine facts policy entries can be conditioned on.
+type factsCollector interface {
+	Collect(context.Context) facts.Facts
+}
+
 type options struct {
-	cacheDir       string
-	stateDir       string
-	dconfDir       string
-	sudoersDir     string
-	policyKitDir   string
-	runDir         string
-	shareDir       string
-	apparmorDir    string
-	apparmorFsDir  string
-	systemUnitDir  string
-	globalTrustDir string
-	proxyApplier   proxy.Caller
-	systemdCaller  systemdCaller
-	gdm            *gdm.Manager
+	cacheDir            string
+	stateDir            string
+	dconfDir            string
+	dconfProfile        string
+	dconfGroupProfiles  map[string][]string
+	rootDir             string
+	sudoersDir          string
+	policyKitDir        string
+	runDir              string
+	shareDir            string
+	extensionsDir       string
+	apparmorDir         string
+	apparmorFsDir       string
+	systemUnitDir       string
+	globalTrustDir      string
+	sysctlDir           string
+	hostsAccessDir      string
+	networkConnsDir     string
+	environmentDir      string
+	bannerDir           string
+	limitsDir           string
+	timeDateDir         string
+	pamConfigsDir       string
+	securityDir         string
+	auditRulesDir       string
+	grubDefaultsDir     string
+	netplanDir          string
+	fontsDir            string
+	themesDir           string
+	mountSrcAddr        string
+	winePrefixes        map[string]string
+	proxyCheckURL       string
+	proxyNoAutoBypass   bool
+	proxyExclusionsFile string
+	dockerProxyUsers    map[string]string
+	dockerProxyDaemon   bool
+	allowAdminLockout   bool
+	privilegeReportOnly bool
+	sudoersPerGroup     bool
+	sudoersAliases      int
+	sudoersConflicts    bool
+	polkitAdminRules    bool
+	protectedUsers      []string
+	writeCooldown       time.Duration
+	refreshTimeout      time.Duration
+	transactional       bool
+	otlpEndpoint        string
+	tamperHandling      map[string]tamper.Mode
+	unknownKeys         ignored.UnknownKeysMode
+	machineKrb5CCPath   string
+	proxyApplier        proxy.Caller
+	secretResolver      secrets.Resolver
+	firewallBackend     firewall.Backend
+	systemdCaller       systemdCaller
+	factsCollector      factsCollector
+	gdm                 *gdm.Manager
 
 	apparmorParserCmd []string
 	certAutoenrollCmd []string
+	caTrustUpdateCmd  []string
+	sysctlCmd         []string
+	nmcliCmd          []string
+	timedatectlCmd    []string
+	timeSyncRestart   []string
+	pamAuthUpdateCmd  []string
+	auditLoadCmd      []string
+	grubUpdateCmd     []string
+	netplanGenCmd     []string
+	netplanApplyCmd   []string
+	fontCacheCmd      []string
+	snapCmd           []string
+	dockerRestartCmd  []string
 }
 
 // Option reprents an optional function to change Policies behavior.
@@ -131,6 +287,15 @@ func WithStateDir(p string) Option {
 	}
 }
 
+// WithRootDir specifies an alternate root directory, like the one of a container, prefixing all the paths managed by
+// the policy managers. The adsys cache, state, run and share directories are not prefixed.
+func WithRootDir(p string) Option {
+	return func(o *options) error {
+		o.rootDir = p
+		return nil
+	}
+}
+
 // WithDconfDir specifies a personalized dconf directory.
 func WithDconfDir(p string) Option {
 	return func(o *options) error {
@@ -139,6 +304,22 @@ func WithDconfDir(p string) Option {
 	}
 }
 
+// WithDconfProfile specifies an existing dconf profile in which the machine database is layered.
+func WithDconfProfile(p string) Option {
+	return func(o *options) error {
+		o.dconfProfile = p
+		return nil
+	}
+}
+
+// WithDconfGroupProfiles maps group names to the existing dconf databases layered in the profile of their members.
+func WithDconfGroupProfiles(profiles map[string][]string) Option {
+	return func(o *options) error {
+		o.dconfGroupProfiles = profiles
+		return nil
+	}
+}
+
 // WithSudoersDir specifies a personalized sudoers directory.
 func WithSudoersDir(p string) Option {
 	return func(o *options) error {
@@ -171,6 +352,15 @@ func WithShareDir(p string) Option {
 	}
 }
 
+// WithExtensionsDir specifies a personalized directory for the declarations of the policy types added by third
+// parties.
+func WithExtensionsDir(p string) Option {
+	return func(o *options) error {
+		o.extensionsDir = p
+		return nil
+	}
+}
+
 // WithApparmorDir specifies a personalized apparmor directory.
 func WithApparmorDir(p string) Option {
 	return func(o *options) error {
@@ -187,36 +377,465 @@ func WithApparmorParserCmd(p []string) Option {
 	}
 }
 
-// WithApparmorFsDir specifies a personalized directory for the apparmor
-// security filesystem.
-func WithApparmorFsDir(p string) Option {
+// WithApparmorFsDir specifies a personalized directory for the apparmor
+// security filesystem.
+func WithApparmorFsDir(p string) Option {
+	return func(o *options) error {
+		o.apparmorFsDir = p
+		return nil
+	}
+}
+
+// WithSysctlDir specifies a personalized sysctl configuration directory.
+func WithSysctlDir(p string) Option {
+	return func(o *options) error {
+		o.sysctlDir = p
+		return nil
+	}
+}
+
+// WithSysctlCmd overrides the default command reloading the sysctl configuration.
+func WithSysctlCmd(cmd []string) Option {
+	return func(o *options) error {
+		o.sysctlCmd = cmd
+		return nil
+	}
+}
+
+// WithHostsAccessDir specifies a personalized directory for the hosts.allow and hosts.deny files.
+func WithHostsAccessDir(p string) Option {
+	return func(o *options) error {
+		o.hostsAccessDir = p
+		return nil
+	}
+}
+
+// WithEnvironmentDir specifies a personalized environment.d configuration directory.
+func WithEnvironmentDir(p string) Option {
+	return func(o *options) error {
+		o.environmentDir = p
+		return nil
+	}
+}
+
+// WithBannerDir specifies a personalized directory for the issue and issue.net files and the motd.d directory.
+func WithBannerDir(p string) Option {
+	return func(o *options) error {
+		o.bannerDir = p
+		return nil
+	}
+}
+
+// WithLimitsDir specifies a personalized limits.d configuration directory.
+func WithLimitsDir(p string) Option {
+	return func(o *options) error {
+		o.limitsDir = p
+		return nil
+	}
+}
+
+// WithTimeDateDir specifies a personalized directory for the localtime link and the timesyncd and chrony configuration.
+func WithTimeDateDir(p string) Option {
+	return func(o *options) error {
+		o.timeDateDir = p
+		return nil
+	}
+}
+
+// WithTimedatectlCmd overrides the default command setting the timezone.
+func WithTimedatectlCmd(cmd []string) Option {
+	return func(o *options) error {
+		o.timedatectlCmd = cmd
+		return nil
+	}
+}
+
+// WithTimeSyncRestartCmd overrides the default command restarting the time synchronization services.
+func WithTimeSyncRestartCmd(cmd []string) Option {
+	return func(o *options) error {
+		o.timeSyncRestart = cmd
+		return nil
+	}
+}
+
+// WithPamConfigsDir specifies a personalized directory for the pam-config profiles.
+func WithPamConfigsDir(p string) Option {
+	return func(o *options) error {
+		o.pamConfigsDir = p
+		return nil
+	}
+}
+
+// WithSecurityDir specifies a personalized directory for the access.conf file.
+func WithSecurityDir(p string) Option {
+	return func(o *options) error {
+		o.securityDir = p
+		return nil
+	}
+}
+
+// WithPamAuthUpdateCmd overrides the default command updating the PAM configuration.
+func WithPamAuthUpdateCmd(cmd []string) Option {
+	return func(o *options) error {
+		o.pamAuthUpdateCmd = cmd
+		return nil
+	}
+}
+
+// WithAuditRulesDir specifies a personalized directory for the auditd rules.
+func WithAuditRulesDir(p string) Option {
+	return func(o *options) error {
+		o.auditRulesDir = p
+		return nil
+	}
+}
+
+// WithAuditLoadCmd overrides the default command loading the auditd rules.
+func WithAuditLoadCmd(cmd []string) Option {
+	return func(o *options) error {
+		o.auditLoadCmd = cmd
+		return nil
+	}
+}
+
+// WithGrubDefaultsDir specifies a personalized directory for the GRUB configuration fragments.
+func WithGrubDefaultsDir(p string) Option {
+	return func(o *options) error {
+		o.grubDefaultsDir = p
+		return nil
+	}
+}
+
+// WithGrubUpdateCmd overrides the default command regenerating the GRUB configuration.
+func WithGrubUpdateCmd(cmd []string) Option {
+	return func(o *options) error {
+		o.grubUpdateCmd = cmd
+		return nil
+	}
+}
+
+// WithNetplanDir specifies a personalized directory for the Netplan configuration files.
+func WithNetplanDir(p string) Option {
+	return func(o *options) error {
+		o.netplanDir = p
+		return nil
+	}
+}
+
+// WithNetplanGenerateCmd overrides the default command validating the Netplan configuration.
+func WithNetplanGenerateCmd(cmd []string) Option {
+	return func(o *options) error {
+		o.netplanGenCmd = cmd
+		return nil
+	}
+}
+
+// WithNetplanApplyCmd overrides the default command applying the Netplan configuration.
+func WithNetplanApplyCmd(cmd []string) Option {
+	return func(o *options) error {
+		o.netplanApplyCmd = cmd
+		return nil
+	}
+}
+
+// WithFontsDir specifies a personalized directory for the locally installed fonts.
+func WithFontsDir(p string) Option {
+	return func(o *options) error {
+		o.fontsDir = p
+		return nil
+	}
+}
+
+// WithThemesDir specifies a personalized directory for the locally installed themes.
+func WithThemesDir(p string) Option {
+	return func(o *options) error {
+		o.themesDir = p
+		return nil
+	}
+}
+
+// WithFontCacheCmd overrides the default command refreshing the font cache.
+func WithFontCacheCmd(cmd []string) Option {
+	return func(o *options) error {
+		o.fontCacheCmd = cmd
+		return nil
+	}
+}
+
+// WithDockerRestartCmd overrides the default command used to restart the Docker daemon when its proxy settings changed.
+func WithDockerRestartCmd(cmd []string) Option {
+	return func(o *options) error {
+		o.dockerRestartCmd = cmd
+		return nil
+	}
+}
+
+// WithSnapCmd overrides the default command used to list, install and remove snaps.
+func WithSnapCmd(cmd []string) Option {
+	return func(o *options) error {
+		o.snapCmd = cmd
+		return nil
+	}
+}
+
+// WithNetworkConnectionsDir specifies a personalized directory for NetworkManager system connection profiles.
+func WithNetworkConnectionsDir(p string) Option {
+	return func(o *options) error {
+		o.networkConnsDir = p
+		return nil
+	}
+}
+
+// WithNmcliCmd overrides the default command reloading the NetworkManager connections.
+func WithNmcliCmd(cmd []string) Option {
+	return func(o *options) error {
+		o.nmcliCmd = cmd
+		return nil
+	}
+}
+
+// WithSystemUnitDir specifies a personalized unit directory for adsys mount units.
+func WithSystemUnitDir(p string) Option {
+	return func(o *options) error {
+		o.systemUnitDir = p
+		return nil
+	}
+}
+
+// WithMountSourceAddress specifies the local IP address, or network interface, system network mounts are bound to
+// when the share doesn't set its own.
+func WithMountSourceAddress(addr string) Option {
+	return func(o *options) error {
+		o.mountSrcAddr = addr
+		return nil
+	}
+}
+
+// WithWineProxyPrefixes specifies, per user, the Wine prefix in which the proxy settings are written.
+func WithWineProxyPrefixes(prefixes map[string]string) Option {
+	return func(o *options) error {
+		o.winePrefixes = prefixes
+		return nil
+	}
+}
+
+// WithProxyCheckURL specifies the URL requested through the system proxy once applied, to check its connectivity.
+func WithProxyCheckURL(u string) Option {
+	return func(o *options) error {
+		o.proxyCheckURL = u
+		return nil
+	}
+}
+
+// WithProxyNoAutoBypass only excludes the hosts of the proxy policy from the proxy, without automatically adding
+// loopback and link-local addresses and the hostname.
+func WithProxyNoAutoBypass(noAutoBypass bool) Option {
+	return func(o *options) error {
+		o.proxyNoAutoBypass = noAutoBypass
+		return nil
+	}
+}
+
+// WithDockerProxyUsers specifies, per user, the home directory in which the proxy settings are written to the Docker
+// client configuration.
+func WithDockerProxyUsers(homes map[string]string) Option {
+	return func(o *options) error {
+		o.dockerProxyUsers = homes
+		return nil
+	}
+}
+
+// WithDockerProxyDaemon writes the proxy settings to a drop-in of the Docker daemon service.
+func WithDockerProxyDaemon(enabled bool) Option {
+	return func(o *options) error {
+		o.dockerProxyDaemon = enabled
+		return nil
+	}
+}
+
+// WithProxyExclusionsFile merges the hosts of the given file, if it exists, with the ones of the proxy policy
+// bypassing the proxy.
+func WithProxyExclusionsFile(p string) Option {
+	return func(o *options) error {
+		o.proxyExclusionsFile = p
+		return nil
+	}
+}
+
+// WithAllowAdminLockout allows the privilege policy to leave the machine without any polkit administrator.
+func WithAllowAdminLockout(allow bool) Option {
+	return func(o *options) error {
+		o.allowAdminLockout = allow
+		return nil
+	}
+}
+
+// WithPrivilegeReportOnly only logs the privilege configuration the policy would set, without applying it.
+func WithPrivilegeReportOnly(reportOnly bool) Option {
+	return func(o *options) error {
+		o.privilegeReportOnly = reportOnly
+		return nil
+	}
+}
+
+// WithSudoersPerGroup writes the sudoers rules of each client administrators group to its own file.
+func WithSudoersPerGroup(perGroup bool) Option {
+	return func(o *options) error {
+		o.sudoersPerGroup = perGroup
+		return nil
+	}
+}
+
+// WithSudoersAliasThreshold groups the sudoers rules of the client administrators in an alias once they are at least
+// threshold. 0 or less always writes one rule per administrator.
+func WithSudoersAliasThreshold(threshold int) Option {
+	return func(o *options) error {
+		o.sudoersAliases = threshold
+		return nil
+	}
+}
+
+// WithSudoersConflictsCheck warns about the local sudoers rules referencing the users and groups of the privilege policy.
+func WithSudoersConflictsCheck(check bool) Option {
+	return func(o *options) error {
+		o.sudoersConflicts = check
+		return nil
+	}
+}
+
+// WithProtectedLocalUsers specifies local users which keep their administrator rights when the privilege policy
+// denies local administrators.
+func WithProtectedLocalUsers(users []string) Option {
+	return func(o *options) error {
+		o.protectedUsers = users
+		return nil
+	}
+}
+
+// WithPolkitAdminRules also writes the polkit administrators of the privilege policy to a polkit rules file.
+func WithPolkitAdminRules(enabled bool) Option {
+	return func(o *options) error {
+		o.polkitAdminRules = enabled
+		return nil
+	}
+}
+
+// WithUnknownKeys specifies how the policy keys unsupported by the policy managers are handled.
+func WithUnknownKeys(mode ignored.UnknownKeysMode) Option {
+	return func(o *options) error {
+		switch mode {
+		case "":
+			return nil
+		case ignored.UnknownKeysIgnore, ignored.UnknownKeysWarn, ignored.UnknownKeysFail:
+			o.unknownKeys = mode
+			return nil
+		}
+		return errors.New(gotext.Get("unknown mode %q for unsupported policy keys: must be %q, %q or %q", mode,
+			ignored.UnknownKeysIgnore, ignored.UnknownKeysWarn, ignored.UnknownKeysFail))
+	}
+}
+
+// WithWriteCooldown specifies the minimum interval between two rewrites of the privilege files with the same content,
+// unless they were changed in between. 0 disables the cooldown.
+func WithWriteCooldown(cooldown time.Duration) Option {
+	return func(o *options) error {
+		if cooldown < 0 {
+			return errors.New(gotext.Get("write cooldown can't be negative: %v", cooldown))
+		}
+		o.writeCooldown = cooldown
+		return nil
+	}
+}
+
+// WithRefreshTimeout specifies the maximum duration of applying the policies of an object. The policy managers still
+// running when it's exceeded are cancelled and the refresh fails. 0 means no deadline.
+func WithRefreshTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		if timeout < 0 {
+			return errors.New(gotext.Get("refresh timeout can't be negative: %v", timeout))
+		}
+		o.refreshTimeout = timeout
+		return nil
+	}
+}
+
+// WithTransactional specifies if the changes of all policy managers are rolled back when any of them fails, instead
+// of keeping the policies applied by the others.
+func WithTransactional(transactional bool) Option {
+	return func(o *options) error {
+		o.transactional = transactional
+		return nil
+	}
+}
+
+// WithOTLPEndpoint specifies the OTLP/HTTP endpoint, like http://collector:4318, to which the spans and durations of
+// the refreshes and of each policy manager, and the grants of the privilege policy, are exported.
+func WithOTLPEndpoint(endpoint string) Option {
+	return func(o *options) error {
+		o.otlpEndpoint = endpoint
+		return nil
+	}
+}
+
+// WithTamperHandling specifies, per policy type, how the managed files modified locally since adsys last wrote them
+// are handled. Policy types without mode overwrite them.
+func WithTamperHandling(modes map[string]tamper.Mode) Option {
+	return func(o *options) error {
+		for rule := range modes {
+			if !slices.Contains(tamperRules, rule) {
+				return errors.New(gotext.Get("%s policy doesn't support handling modified files: must be one of %s", rule, strings.Join(tamperRules, ", ")))
+			}
+		}
+		o.tamperHandling = modes
+		return nil
+	}
+}
+
+// WithMachineKrb5CCPath specifies the machine Kerberos ticket cache used by the policy managers.
+func WithMachineKrb5CCPath(p string) Option {
+	return func(o *options) error {
+		o.machineKrb5CCPath = p
+		return nil
+	}
+}
+
+// WithGlobalTrustDir specifies a personalized global trust directory for use
+// with the certificate manager.
+func WithGlobalTrustDir(p string) Option {
+	return func(o *options) error {
+		o.globalTrustDir = p
+		return nil
+	}
+}
+
+// WithProxyApplier specifies a personalized proxy applier for the proxy policy manager.
+func WithProxyApplier(p proxy.Caller) Option {
 	return func(o *options) error {
-		o.apparmorFsDir = p
+		o.proxyApplier = p
 		return nil
 	}
 }
 
-// WithSystemUnitDir specifies a personalized unit directory for adsys mount units.
-func WithSystemUnitDir(p string) Option {
+// WithSecretResolver specifies the resolver of the secret references embedded in the proxy policy.
+func WithSecretResolver(r secrets.Resolver) Option {
 	return func(o *options) error {
-		o.systemUnitDir = p
+		o.secretResolver = r
 		return nil
 	}
 }
 
-// WithGlobalTrustDir specifies a personalized global trust directory for use
-// with the certificate manager.
-func WithGlobalTrustDir(p string) Option {
+// WithFirewallBackend specifies a personalized backend to manage the ufw rules of the firewall policy.
+func WithFirewallBackend(b firewall.Backend) Option {
 	return func(o *options) error {
-		o.globalTrustDir = p
+		o.firewallBackend = b
 		return nil
 	}
 }
 
-// WithProxyApplier specifies a personalized proxy applier for the proxy policy manager.
-func WithProxyApplier(p proxy.Caller) Option {
+// WithFactsCollector specifies a personalized collector of the machine facts entries conditions are evaluated against.
+func WithFactsCollector(c factsCollector) Option {
 	return func(o *options) error {
-		o.proxyApplier = p
+		o.factsCollector = c
 		return nil
 	}
 }
@@ -237,6 +856,14 @@ func WithCertAutoenrollCmd(cmd []string) Option {
 	}
 }
 
+// WithCATrustUpdateCmd overrides the default command regenerating the system trust store.
+func WithCATrustUpdateCmd(cmd []string) Option {
+	return func(o *options) error {
+		o.caTrustUpdateCmd = cmd
+		return nil
+	}
+}
+
 // NewManager returns a new manager with all default policy handlers.
 func NewManager(bus *dbus.Conn, hostname string, backend backends.Backend, opts ...Option) (m *Manager, err error) {
 	defer decorate.OnError(&err, gotext.Get("can't create a new policy handlers manager"))
@@ -248,15 +875,31 @@ func NewManager(bus *dbus.Conn, hostname string, backend backends.Backend, opts
 
 	// defaults
 	args := options{
-		cacheDir:       consts.DefaultCacheDir,
-		stateDir:       consts.DefaultStateDir,
-		runDir:         consts.DefaultRunDir,
-		shareDir:       consts.DefaultShareDir,
-		apparmorDir:    consts.DefaultApparmorDir,
-		systemUnitDir:  consts.DefaultSystemUnitDir,
-		globalTrustDir: consts.DefaultGlobalTrustDir,
-		systemdCaller:  defaultSystemdCaller,
-		gdm:            nil,
+		cacheDir:        consts.DefaultCacheDir,
+		stateDir:        consts.DefaultStateDir,
+		runDir:          consts.DefaultRunDir,
+		shareDir:        consts.DefaultShareDir,
+		extensionsDir:   consts.DefaultExtensionsDir,
+		apparmorDir:     consts.DefaultApparmorDir,
+		systemUnitDir:   consts.DefaultSystemUnitDir,
+		globalTrustDir:  consts.DefaultGlobalTrustDir,
+		sysctlDir:       consts.DefaultSysctlDir,
+		hostsAccessDir:  consts.DefaultHostsAccessDir,
+		networkConnsDir: consts.DefaultNetworkConnectionsDir,
+		environmentDir:  consts.DefaultEnvironmentDir,
+		bannerDir:       consts.DefaultBannerDir,
+		limitsDir:       consts.DefaultLimitsDir,
+		timeDateDir:     consts.DefaultTimeDateDir,
+		pamConfigsDir:   consts.DefaultPamConfigsDir,
+		securityDir:     consts.DefaultSecurityDir,
+		auditRulesDir:   consts.DefaultAuditRulesDir,
+		grubDefaultsDir: consts.DefaultGrubDefaultsDir,
+		netplanDir:      consts.DefaultNetplanDir,
+		fontsDir:        consts.DefaultFontsDir,
+		themesDir:       consts.DefaultThemesDir,
+		sudoersAliases:  consts.DefaultSudoersAliasThreshold,
+		systemdCaller:   defaultSystemdCaller,
+		gdm:             nil,
 	}
 	// applied options (including dconf manager used by gdm)
 	for _, o := range opts {
@@ -264,14 +907,46 @@ func NewManager(bus *dbus.Conn, hostname string, backend backends.Backend, opts
 			return nil, err
 		}
 	}
+	if args.rootDir != "" {
+		args.reroot()
+	}
 	// dconf manager
-	dconfManager := &dconf.Manager{}
-	if args.dconfDir != "" {
-		dconfManager = dconf.NewWithDconfDir(args.dconfDir)
+	var dconfOptions []dconf.Option
+	if args.dconfProfile != "" {
+		dconfOptions = append(dconfOptions, dconf.WithProfile(args.dconfProfile))
+	}
+	if len(args.dconfGroupProfiles) > 0 {
+		dconfOptions = append(dconfOptions, dconf.WithGroupProfiles(args.dconfGroupProfiles))
+	}
+	dconfManager := dconf.NewWithDconfDir(args.dconfDir, dconfOptions...)
+
+	// telemetry exporter
+	var telemetryExporter *telemetry.Exporter
+	if args.otlpEndpoint != "" {
+		if telemetryExporter, err = telemetry.New(args.otlpEndpoint, hostname); err != nil {
+			return nil, err
+		}
 	}
 
 	// privilege manager
-	privilegeManager := privilege.NewWithDirs(args.sudoersDir, args.policyKitDir)
+	// Client administrators can be restricted to some hosts, matched on the hostname or FQDN.
+	hostnames := []string{hostname}
+	if domain := backend.Domain(); domain != "" && !strings.Contains(hostname, ".") {
+		hostnames = append(hostnames, fmt.Sprintf("%s.%s", hostname, domain))
+	}
+	privilegeManager := privilege.NewWithDirs(args.sudoersDir, args.policyKitDir,
+		privilege.WithHostnames(hostnames...),
+		privilege.WithAllowAdminLockout(args.allowAdminLockout),
+		privilege.WithReportOnly(args.privilegeReportOnly),
+		privilege.WithSudoersPerGroup(args.sudoersPerGroup),
+		privilege.WithSudoersAliasThreshold(args.sudoersAliases),
+		privilege.WithSudoersConflictsCheck(args.sudoersConflicts),
+		privilege.WithPolkitAdminRules(args.polkitAdminRules),
+		privilege.WithProtectedUsers(args.protectedUsers...),
+		privilege.WithSystemUnitDir(args.systemUnitDir),
+		privilege.WithSystemdCaller(args.systemdCaller),
+		privilege.WithCooldown(cooldown.New(args.stateDir, args.writeCooldown)),
+		privilege.WithTelemetry(telemetryExporter))
 
 	// scripts manager
 	scriptsManager, err := scripts.New(args.runDir, args.systemdCaller)
@@ -280,7 +955,11 @@ func NewManager(bus *dbus.Conn, hostname string, backend backends.Backend, opts
 	}
 
 	// mount manager
-	mountManager, err := mount.New(args.runDir, args.systemUnitDir, args.systemdCaller)
+	mountOptions := []mount.Option{mount.WithDomain(backend.Domain()), mount.WithSiteLookup(backend.Site)}
+	if args.mountSrcAddr != "" {
+		mountOptions = append(mountOptions, mount.WithSourceAddress(args.mountSrcAddr))
+	}
+	mountManager, err := mount.New(args.runDir, args.systemUnitDir, args.systemdCaller, mountOptions...)
 	if err != nil {
 		return nil, err
 	}
@@ -296,10 +975,34 @@ func NewManager(bus *dbus.Conn, hostname string, backend backends.Backend, opts
 	apparmorManager := apparmor.New(args.apparmorDir, apparmorOptions...)
 
 	// proxy manager
-	var proxyOptions []proxy.Option
+	proxyOptions := []proxy.Option{proxy.WithRunDir(args.runDir)}
 	if args.proxyApplier != nil {
 		proxyOptions = append(proxyOptions, proxy.WithProxyApplier(args.proxyApplier))
 	}
+	if len(args.winePrefixes) > 0 {
+		proxyOptions = append(proxyOptions, proxy.WithWinePrefixes(args.winePrefixes))
+	}
+	if args.proxyCheckURL != "" {
+		proxyOptions = append(proxyOptions, proxy.WithConnectivityCheckURL(args.proxyCheckURL))
+	}
+	if args.proxyNoAutoBypass {
+		proxyOptions = append(proxyOptions, proxy.WithoutAutoBypass())
+	}
+	if args.proxyExclusionsFile != "" {
+		proxyOptions = append(proxyOptions, proxy.WithExclusionsFile(args.proxyExclusionsFile))
+	}
+	if args.secretResolver != nil {
+		proxyOptions = append(proxyOptions, proxy.WithSecretResolver(args.secretResolver))
+	}
+	if len(args.dockerProxyUsers) > 0 {
+		proxyOptions = append(proxyOptions, proxy.WithDockerUsers(args.dockerProxyUsers))
+	}
+	if args.dockerProxyDaemon {
+		proxyOptions = append(proxyOptions, proxy.WithDockerDaemon(filepath.Join(args.systemUnitDir, "docker.service.d"), args.systemdCaller))
+	}
+	if args.dockerRestartCmd != nil {
+		proxyOptions = append(proxyOptions, proxy.WithDockerRestartCmd(args.dockerRestartCmd))
+	}
 	proxyManager := proxy.New(bus, proxyOptions...)
 
 	// certificate manager
@@ -308,12 +1011,141 @@ func NewManager(bus *dbus.Conn, hostname string, backend backends.Backend, opts
 		certificate.WithRunDir(args.runDir),
 		certificate.WithShareDir(args.shareDir),
 		certificate.WithGlobalTrustDir(args.globalTrustDir),
+		certificate.WithKrb5CCPath(args.machineKrb5CCPath),
 	}
 	if args.certAutoenrollCmd != nil {
 		certificateOpts = append(certificateOpts, certificate.WithCertAutoenrollCmd(args.certAutoenrollCmd))
 	}
 	certificateManager := certificate.New(backend.Domain(), certificateOpts...)
 
+	// CA certificates trust manager
+	var catrustOptions []catrust.Option
+	if args.caTrustUpdateCmd != nil {
+		catrustOptions = append(catrustOptions, catrust.WithUpdateCmd(args.caTrustUpdateCmd))
+	}
+	catrustManager := catrust.New(args.globalTrustDir, catrustOptions...)
+
+	// sysctl manager
+	var sysctlOptions []sysctl.Option
+	if args.sysctlCmd != nil {
+		sysctlOptions = append(sysctlOptions, sysctl.WithSysctlCmd(args.sysctlCmd))
+	}
+	sysctlManager := sysctl.New(args.sysctlDir, sysctlOptions...)
+
+	// hosts access manager
+	hostsAccessManager := hostsaccess.New(args.hostsAccessDir)
+
+	// scheduled jobs manager
+	jobsManager, err := jobs.New(args.stateDir, args.systemUnitDir, args.systemdCaller)
+	if err != nil {
+		return nil, err
+	}
+
+	// network connections manager
+	var networkOptions []network.Option
+	if args.nmcliCmd != nil {
+		networkOptions = append(networkOptions, network.WithNmcliCmd(args.nmcliCmd))
+	}
+	networkManager := network.New(args.networkConnsDir, networkOptions...)
+
+	// firewall manager
+	var firewallOptions []firewall.Option
+	if args.firewallBackend != nil {
+		firewallOptions = append(firewallOptions, firewall.WithBackend(args.firewallBackend))
+	}
+	firewallManager := firewall.New(firewallOptions...)
+
+	// environment manager
+	environmentManager := environment.New(args.environmentDir)
+
+	// banner manager
+	bannerManager := banner.New(hostname, backend.Domain(), args.stateDir, banner.WithConfDir(args.bannerDir))
+
+	// limits manager
+	limitsManager := limits.New(args.limitsDir)
+
+	// timedate manager
+	timedateOptions := []timedate.Option{timedate.WithConfDir(args.timeDateDir)}
+	if args.timedatectlCmd != nil {
+		timedateOptions = append(timedateOptions, timedate.WithTimedatectlCmd(args.timedatectlCmd))
+	}
+	if args.timeSyncRestart != nil {
+		timedateOptions = append(timedateOptions, timedate.WithRestartCmd(args.timeSyncRestart))
+	}
+	timedateManager := timedate.New(timedateOptions...)
+
+	// pam manager
+	pamOptions := []pam.Option{pam.WithPamConfigsDir(args.pamConfigsDir), pam.WithSecurityDir(args.securityDir)}
+	if args.pamAuthUpdateCmd != nil {
+		pamOptions = append(pamOptions, pam.WithPamAuthUpdateCmd(args.pamAuthUpdateCmd))
+	}
+	pamManager := pam.New(pamOptions...)
+
+	// audit manager
+	var auditOptions []audit.Option
+	if args.auditLoadCmd != nil {
+		auditOptions = append(auditOptions, audit.WithLoadCmd(args.auditLoadCmd))
+	}
+	auditManager := audit.New(args.auditRulesDir, auditOptions...)
+
+	// grub manager
+	var grubOptions []grub.Option
+	if args.grubUpdateCmd != nil {
+		grubOptions = append(grubOptions, grub.WithUpdateCmd(args.grubUpdateCmd))
+	}
+	grubManager := grub.New(args.grubDefaultsDir, grubOptions...)
+
+	// appearance manager
+	var appearanceOptions []appearance.Option
+	if args.fontCacheCmd != nil {
+		appearanceOptions = append(appearanceOptions, appearance.WithFontCacheCmd(args.fontCacheCmd))
+	}
+	appearanceManager := appearance.New(args.fontsDir, args.themesDir, appearanceOptions...)
+
+	// snaps manager
+	var snapsOptions []snaps.Option
+	if args.snapCmd != nil {
+		snapsOptions = append(snapsOptions, snaps.WithSnapCmd(args.snapCmd))
+	}
+	snapsManager := snaps.New(args.stateDir, snapsOptions...)
+
+	// systemd unit overrides manager
+	unitsManager := units.New(args.systemUnitDir, args.systemdCaller)
+
+	// netplan manager
+	var netplanOptions []netplan.Option
+	if args.netplanGenCmd != nil {
+		netplanOptions = append(netplanOptions, netplan.WithGenerateCmd(args.netplanGenCmd))
+	}
+	if args.netplanApplyCmd != nil {
+		netplanOptions = append(netplanOptions, netplan.WithApplyCmd(args.netplanApplyCmd))
+	}
+	netplanManager := netplan.New(args.netplanDir, netplanOptions...)
+
+	// extensions manager
+	extensionsManager, err := extensions.New(args.extensionsDir, extensions.WithRootDir(args.rootDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range extensionsManager.Types() {
+		if slices.Contains(supportedRules, t) {
+			return nil, errors.New(gotext.Get("policy extension type %q is already handled by adsys", t))
+		}
+	}
+
+	// modified files guard
+	var tamperGuard *tamper.Guard
+	if len(args.tamperHandling) > 0 {
+		if tamperGuard, err = tamper.New(args.stateDir, args.tamperHandling); err != nil {
+			return nil, err
+		}
+	}
+
+	// facts collector
+	if args.factsCollector == nil {
+		args.factsCollector = facts.New(backend.IsOnline)
+	}
+
 	// inject applied dconf mangager if we need to build a gdm manager
 	if args.gdm == nil {
 		if args.gdm, err = gdm.New(gdm.WithDconf(dconfManager)); err != nil {
@@ -340,8 +1172,56 @@ func NewManager(bus *dbus.Conn, hostname string, backend backends.Backend, opts
 		apparmor:         apparmorManager,
 		proxy:            proxyManager,
 		certificate:      certificateManager,
+		catrust:          catrustManager,
+		sysctl:           sysctlManager,
+		hostsAccess:      hostsAccessManager,
+		jobs:             jobsManager,
+		network:          networkManager,
+		firewall:         firewallManager,
+		environment:      environmentManager,
+		banner:           bannerManager,
+		limits:           limitsManager,
+		timedate:         timedateManager,
+		pam:              pamManager,
+		audit:            auditManager,
+		grub:             grubManager,
+		appearance:       appearanceManager,
+		snaps:            snapsManager,
+		units:            unitsManager,
+		netplan:          netplanManager,
 		gdm:              args.gdm,
 
+		extensions: extensionsManager,
+
+		facts: args.factsCollector,
+
+		unknownKeys: args.unknownKeys,
+
+		refreshTimeout: args.refreshTimeout,
+
+		telemetry: telemetryExporter,
+
+		tamper: tamperGuard,
+
+		managedDirs: []managedDir{
+			{path: cmp.Or(args.dconfDir, consts.DefaultDconfDir), policy: "dconf"},
+			// The sudoers directory is detected from the sudoers configuration when not set, and is the default one
+			// on most systems.
+			{path: cmp.Or(args.sudoersDir, consts.DefaultSudoersDir), policy: "privilege", computerOnly: true},
+			{path: cmp.Or(args.policyKitDir, consts.DefaultPolicyKitDir), policy: "privilege", computerOnly: true},
+			{path: args.environmentDir, policy: "environment", computerOnly: true},
+			{path: args.systemUnitDir, policy: "mount", computerOnly: true},
+		},
+
+		tracedPaths: tracedPaths(args, extensionsManager),
+
+		purgeablePaths: purgeablePaths(args),
+		systemUnitDir:  args.systemUnitDir,
+		systemdCaller:  args.systemdCaller,
+
+		transactional: args.transactional,
+		transactionMu: &sync.Mutex{},
+
 		subscriptionDbus: subscriptionDbus,
 
 		muMu:     &sync.Mutex{},
@@ -354,6 +1234,15 @@ func NewManager(bus *dbus.Conn, hostname string, backend backends.Backend, opts
 func (m *Manager) ApplyPolicies(ctx context.Context, objectName string, isComputer bool, pols *Policies) (err error) {
 	defer decorate.OnError(&err, gotext.Get("failed to apply policy to %q", objectName))
 
+	// Tag all logs of this apply, including from the policy managers, with the object name.
+	ctx = log.WithObjectName(ctx, objectName)
+	ctx = trace.WithObject(ctx, objectName)
+
+	// Never write to the system while replaying an imported cache.
+	if m.IsReplaying() {
+		return errors.New(gotext.Get("the daemon is in replay mode"))
+	}
+
 	// We have a lock per objectName to prevent multiple instances of ApplyPolicies for the same object.
 	m.muMu.Lock()
 	if _, ok := m.objectMu[objectName]; !ok {
@@ -363,18 +1252,92 @@ func (m *Manager) ApplyPolicies(ctx context.Context, objectName string, isComput
 	defer m.objectMu[objectName].Unlock()
 	m.muMu.Unlock()
 
-	rules := pols.GetUniqueRules()
+	// The policy managers spans nest under the refresh one, which exports them when it ends.
+	ctx, span := m.telemetry.StartRefresh(ctx, objectName, isComputer)
+	defer func() { span.End(ctx, err) }()
+
+	// The deadline starts once we hold the lock, so that waiting for another refresh of the object is not counted.
+	// The policy managers running when it's exceeded are reported in the error.
+	running := &runningManagers{}
+	ctx = withRunningManagers(ctx, running)
+	if m.refreshTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, m.refreshTimeout,
+			errors.New(gotext.Get("refresh timeout of %v exceeded", m.refreshTimeout)))
+		defer cancel()
+	}
+
+	// Collect the entries skipped by the policy managers, to report them after the refresh.
+	collector := &ignored.Collector{UnknownKeys: m.unknownKeys}
+	ctx = ignored.WithCollector(ctx, collector)
+	// Collect the hints of the policy managers whose changes need a relogin or reboot to take full effect.
+	hints := &restart.Collector{}
+	ctx = restart.WithCollector(ctx, hints)
+	// The policy managers writing whole files check if they were modified locally before replacing them.
+	if m.tamper != nil {
+		ctx = tamper.WithGuard(ctx, m.tamper)
+	}
+	// The attributes of the computer object can be substituted by the policy managers.
+	ctx = attributes.WithComputer(ctx, m.computerAttributes(ctx, isComputer, pols))
+
+	// Machine facts are collected once per refresh, to skip entries whose condition is not met.
+	applicable, err := pols.applicableTo(ctx, m.facts.Collect(ctx))
+	if err != nil {
+		return err
+	}
+	rules := applicable.GetUniqueRules()
+	// Policies of a newer version of adsys can contain rules no policy manager handles.
+	extensionTypes := m.extensions.Types()
+	for rule, entries := range rules {
+		if slices.Contains(supportedRules, rule) || slices.Contains(extensionTypes, rule) {
+			continue
+		}
+		for _, e := range entries {
+			ignored.RecordUnsupportedKey(ctx, rule, e.Key, e.Value)
+		}
+	}
 	action := gotext.Get("Applying")
 	if len(rules) == 0 {
 		action = gotext.Get("Unloading")
 	}
 	log.Info(ctx, gotext.Get("%s policies for %s (machine: %v)", action, objectName, isComputer))
 
+	if err := m.checkManagedDirs(isComputer); err != nil {
+		return err
+	}
+
+	// In transactional mode, the managed files are backed up to restore them all if any policy manager fails.
+	var tx *transaction
+	if m.transactional {
+		m.transactionMu.Lock()
+		defer m.transactionMu.Unlock()
+		if tx, err = newTransaction(m.tracedPaths); err != nil {
+			return err
+		}
+	}
+	rollback := func(err error) error {
+		if tx == nil {
+			return err
+		}
+		log.Warning(ctx, gotext.Get("Rolling back the policies applied for %s: %v", objectName, err))
+		// The refresh context can be exceeded, but restoring the files must not be interrupted.
+		return errors.Join(err, tx.rollback(context.WithoutCancel(ctx)))
+	}
+
 	var g errgroup.Group
 	// Applying dconf policies take a while to complete, so it's better to start applying them before
 	// querying dbus for the Pro subscription state, as it does not rely on that.
 	g.Go(func() error {
-		return m.dconf.ApplyPolicy(ctx, objectName, isComputer, rules["dconf"])
+		// The GNOME Shell extensions policy is applied as dconf keys.
+		dconfEntries, err := gnomeextensions.MergeWithDconf(ctx, rules["dconf"], rules["gnomeextensions"])
+		if err != nil {
+			return err
+		}
+		// Extensions can set dconf keys too.
+		dconfEntries = m.extensions.MergeWithDconf(ctx, dconfEntries, rules)
+		return m.traced(ctx, "dconf", dconfEntries, func() error {
+			return m.dconf.ApplyPolicy(ctx, objectName, isComputer, dconfEntries)
+		})
 	})
 	if !m.GetSubscriptionState(ctx) {
 		if filteredRules := filterRules(ctx, rules); len(filteredRules) > 0 {
@@ -383,38 +1346,299 @@ func (m *Manager) ApplyPolicies(ctx context.Context, objectName string, isComput
 	}
 
 	g.Go(func() error {
-		return m.privilege.ApplyPolicy(ctx, objectName, isComputer, rules["privilege"])
+		return m.traced(ctx, "privilege", rules["privilege"], func() error {
+			return m.privilege.ApplyPolicy(ctx, objectName, isComputer, rules["privilege"], pols.SaveAssetsTo)
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "scripts", rules["scripts"], func() error {
+			return m.scripts.ApplyPolicy(ctx, objectName, isComputer, rules["scripts"], pols.SaveAssetsTo)
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "mount", rules["mount"], func() error {
+			return m.mount.ApplyPolicy(ctx, objectName, isComputer, rules["mount"])
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "apparmor", rules["apparmor"], func() error {
+			return m.apparmor.ApplyPolicy(ctx, objectName, isComputer, rules["apparmor"], pols.SaveAssetsTo)
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "proxy", rules["proxy"], func() error {
+			return m.proxy.ApplyPolicy(ctx, objectName, isComputer, rules["proxy"])
+		})
 	})
 	g.Go(func() error {
-		return m.scripts.ApplyPolicy(ctx, objectName, isComputer, rules["scripts"], pols.SaveAssetsTo)
+		return m.traced(ctx, "catrust", rules["catrust"], func() error {
+			return m.catrust.ApplyPolicy(ctx, objectName, isComputer, rules["catrust"])
+		})
 	})
 	g.Go(func() error {
-		return m.mount.ApplyPolicy(ctx, objectName, isComputer, rules["mount"])
+		return m.traced(ctx, "sysctl", rules["sysctl"], func() error {
+			return m.sysctl.ApplyPolicy(ctx, objectName, isComputer, rules["sysctl"])
+		})
 	})
 	g.Go(func() error {
-		return m.apparmor.ApplyPolicy(ctx, objectName, isComputer, rules["apparmor"], pols.SaveAssetsTo)
+		return m.traced(ctx, "hostsaccess", rules["hostsaccess"], func() error {
+			return m.hostsAccess.ApplyPolicy(ctx, objectName, isComputer, rules["hostsaccess"])
+		})
 	})
 	g.Go(func() error {
-		return m.proxy.ApplyPolicy(ctx, objectName, isComputer, rules["proxy"])
+		return m.traced(ctx, "jobs", rules["jobs"], func() error {
+			return m.jobs.ApplyPolicy(ctx, objectName, isComputer, rules["jobs"], pols.SaveAssetsTo)
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "network", rules["network"], func() error {
+			return m.network.ApplyPolicy(ctx, objectName, isComputer, rules["network"])
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "firewall", rules["firewall"], func() error {
+			return m.firewall.ApplyPolicy(ctx, objectName, isComputer, rules["firewall"])
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "environment", rules["environment"], func() error {
+			return m.environment.ApplyPolicy(ctx, objectName, isComputer, rules["environment"])
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "banner", rules["banner"], func() error {
+			return m.banner.ApplyPolicy(ctx, objectName, isComputer, rules["banner"])
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "limits", rules["limits"], func() error {
+			return m.limits.ApplyPolicy(ctx, objectName, isComputer, rules["limits"])
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "timedate", rules["timedate"], func() error {
+			return m.timedate.ApplyPolicy(ctx, objectName, isComputer, rules["timedate"])
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "pam", rules["pam"], func() error {
+			return m.pam.ApplyPolicy(ctx, objectName, isComputer, rules["pam"])
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "audit", rules["audit"], func() error {
+			return m.audit.ApplyPolicy(ctx, objectName, isComputer, rules["audit"])
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "grub", rules["grub"], func() error {
+			return m.grub.ApplyPolicy(ctx, objectName, isComputer, rules["grub"])
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "appearance", rules["appearance"], func() error {
+			return m.appearance.ApplyPolicy(ctx, objectName, isComputer, rules["appearance"], pols.SaveAssetsTo)
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "snaps", rules["snaps"], func() error {
+			return m.snaps.ApplyPolicy(ctx, objectName, isComputer, rules["snaps"])
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "units", rules["units"], func() error {
+			return m.units.ApplyPolicy(ctx, objectName, isComputer, rules["units"])
+		})
+	})
+	g.Go(func() error {
+		return m.traced(ctx, "netplan", rules["netplan"], func() error {
+			return m.netplan.ApplyPolicy(ctx, objectName, isComputer, rules["netplan"])
+		})
+	})
+	g.Go(func() error {
+		var extensionsEntries []entry.Entry
+		for _, t := range extensionTypes {
+			extensionsEntries = append(extensionsEntries, rules[t]...)
+		}
+		return m.traced(ctx, "extensions", extensionsEntries, func() error {
+			return m.extensions.ApplyPolicy(ctx, objectName, isComputer, rules)
+		})
 	})
 	g.Go(func() error {
 		// Ignore error as we don't want to fail because of online status this late in the process
 		isOnline, _ := m.backend.IsOnline()
-		return m.certificate.ApplyPolicy(ctx, objectName, isComputer, isOnline, rules["certificate"])
+		return m.traced(ctx, "certificate", rules["certificate"], func() error {
+			return m.certificate.ApplyPolicy(ctx, objectName, isComputer, isOnline, rules["certificate"])
+		})
 	})
-	if err := g.Wait(); err != nil {
-		return err
+	if err := waitManagers(ctx, &g, running); err != nil {
+		return rollback(err)
 	}
 
 	if isComputer {
 		// Apply GDM policy only now as we need dconf machine database to be ready first
-		if err := m.gdm.ApplyPolicy(ctx, rules["gdm"]); err != nil {
-			return err
+		if err := m.traced(ctx, "gdm", rules["gdm"], func() error {
+			return m.gdm.ApplyPolicy(ctx, rules["gdm"])
+		}); err != nil {
+			return rollback(err)
 		}
 	}
 
 	// Write cache Policies
-	return pols.Save(filepath.Join(m.policiesCacheDir, objectName))
+	p := filepath.Join(m.policiesCacheDir, objectName)
+	if !pols.KeepCache {
+		if err := pols.Save(p); err != nil {
+			return err
+		}
+	}
+	if err := saveIgnoredEntries(p, collector.Entries()); err != nil {
+		return err
+	}
+	if err := saveRestartHints(p, hints.Hints()); err != nil {
+		return err
+	}
+
+	// All policies are applied, but the refresh fails if some keys are not supported.
+	return collector.UnknownKeysError()
+}
+
+// computerAttributes returns the attributes of the computer object read with the machine policies, or, for users,
+// the ones cached at the last machine refresh.
+func (m *Manager) computerAttributes(ctx context.Context, isComputer bool, pols *Policies) map[string]string {
+	if isComputer {
+		return pols.ComputerAttributes
+	}
+
+	machine, err := NewFromCache(ctx, filepath.Join(m.policiesCacheDir, m.hostname))
+	if err != nil {
+		log.Debugf(ctx, "No computer attribute available, as the machine policies cache can't be read: %v", err)
+		return nil
+	}
+	defer machine.Close()
+	return machine.ComputerAttributes
+}
+
+// traced calls apply for the manager of rule and, if ctx records in a trace bundle, records its entries, the changes
+// of its managed files, and its error.
+func (m *Manager) traced(ctx context.Context, rule string, entries []entry.Entry, apply func() error) (err error) {
+	done := startManager(ctx, rule)
+	defer done()
+
+	if span := telemetry.StartApply(ctx, rule); span != nil {
+		before := managedFilesState(m.tracedPaths[rule])
+		defer func() {
+			span.SetChanged(!maps.Equal(before, managedFilesState(m.tracedPaths[rule])))
+			span.End(ctx, err)
+		}()
+	}
+
+	if !trace.Enabled(ctx) {
+		return apply()
+	}
+
+	trace.Entries(ctx, rule, entries)
+	before := trace.Snapshot(ctx, m.tracedPaths[rule]...)
+	err = apply()
+	trace.Changes(ctx, rule, before, trace.Snapshot(ctx, m.tracedPaths[rule]...))
+	trace.Error(ctx, rule, err)
+	return err
+}
+
+// managedFilesState returns the modification time, size and permissions of the files matching the glob patterns,
+// and of the ones in the matching directories, to detect whether a policy manager changed them.
+func managedFilesState(patterns []string) map[string]string {
+	state := make(map[string]string)
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, root := range matches {
+			_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return nil
+				}
+				info, err := d.Info()
+				if err != nil || info.IsDir() {
+					return nil
+				}
+				state[path] = fmt.Sprintf("%d %d %v", info.ModTime().UnixNano(), info.Size(), info.Mode())
+				return nil
+			})
+		}
+	}
+	return state
+}
+
+// reroot prefixes all the managed paths with the root directory. The directories whose default is decided by their
+// manager are set to their usual default first.
+func (o *options) reroot() {
+	if o.dconfDir == "" {
+		o.dconfDir = consts.DefaultDconfDir
+	}
+	if o.sudoersDir == "" {
+		o.sudoersDir = consts.DefaultSudoersDir
+	}
+	if o.policyKitDir == "" {
+		o.policyKitDir = consts.DefaultPolicyKitDir
+	}
+
+	for _, p := range []*string{
+		&o.dconfDir, &o.sudoersDir, &o.policyKitDir, &o.apparmorDir, &o.systemUnitDir, &o.globalTrustDir,
+		&o.sysctlDir, &o.hostsAccessDir, &o.networkConnsDir, &o.environmentDir, &o.bannerDir, &o.limitsDir,
+		&o.timeDateDir, &o.pamConfigsDir, &o.securityDir, &o.auditRulesDir, &o.grubDefaultsDir, &o.fontsDir,
+		&o.themesDir, &o.netplanDir,
+	} {
+		*p = filepath.Join(o.rootDir, *p)
+	}
+}
+
+// tracedPaths returns the glob patterns of the files managed by each policy manager.
+func tracedPaths(args options, extensionsManager *extensions.Manager) map[string][]string {
+	var proxyPaths []string
+	for _, prefix := range args.winePrefixes {
+		proxyPaths = append(proxyPaths, filepath.Join(prefix, "user.reg"))
+	}
+	for _, home := range args.dockerProxyUsers {
+		proxyPaths = append(proxyPaths, filepath.Join(home, ".docker", "config.json"))
+	}
+	if args.dockerProxyDaemon {
+		proxyPaths = append(proxyPaths, filepath.Join(args.systemUnitDir, "docker.service.d", "http-proxy.conf"))
+	}
+
+	return map[string][]string{
+		"dconf": {cmp.Or(args.dconfDir, consts.DefaultDconfDir)},
+		"privilege": {
+			cmp.Or(args.sudoersDir, consts.DefaultSudoersDir),
+			cmp.Or(args.policyKitDir, consts.DefaultPolicyKitDir),
+			filepath.Join(args.systemUnitDir, "adsys-privilege-expiry.*"),
+		},
+		"mount":       {filepath.Join(args.systemUnitDir, "adsys-*.mount")},
+		"jobs":        {filepath.Join(args.systemUnitDir, "adsys-job-*")},
+		"apparmor":    {args.apparmorDir},
+		"proxy":       proxyPaths,
+		"catrust":     {args.globalTrustDir},
+		"sysctl":      {args.sysctlDir},
+		"hostsaccess": {filepath.Join(args.hostsAccessDir, "hosts.allow"), filepath.Join(args.hostsAccessDir, "hosts.deny")},
+		"network":     {args.networkConnsDir},
+		"environment": {args.environmentDir},
+		"banner": {
+			filepath.Join(args.bannerDir, "issue"),
+			filepath.Join(args.bannerDir, "issue.net"),
+			filepath.Join(args.bannerDir, "motd.d", "adsys"),
+		},
+		"limits": {args.limitsDir},
+		"timedate": {
+			filepath.Join(args.timeDateDir, "systemd", "timesyncd.conf.d", "adsys.conf"),
+			filepath.Join(args.timeDateDir, "chrony", "sources.d", "adsys.sources"),
+		},
+		"pam":   {args.pamConfigsDir, filepath.Join(args.securityDir, "access.conf")},
+		"audit": {args.auditRulesDir},
+		"grub":  {args.grubDefaultsDir},
+		// Fonts are binary files, their changes are not diffed.
+		"appearance": {filepath.Join(args.themesDir, "*")},
+		"snaps":      {filepath.Join(args.stateDir, "snaps", "managed")},
+		"units":      {filepath.Join(args.systemUnitDir, "*.d", "99-adsys.conf")},
+		"netplan":    {filepath.Join(args.netplanDir, "99-adsys.yaml")},
+		"extensions": extensionsManager.Paths(),
+	}
 }
 
 // DumpPolicies displays the currently applied policies and rules (since last update) for objectName.
@@ -424,14 +1648,17 @@ func (m *Manager) DumpPolicies(ctx context.Context, objectName string, computerO
 
 	log.Infof(ctx, "Dumping policies for %s", objectName)
 
+	cacheDir, hostname := m.cachedPoliciesDir()
+	objectName = m.cachedObjectName(objectName)
+
 	var out strings.Builder
 
 	var alreadyProcessedRules map[string]struct{}
 	if !computerOnly {
 		fmt.Fprintln(&out, gotext.Get("Policies from machine configuration:"))
-		policiesHost, err := NewFromCache(ctx, filepath.Join(m.policiesCacheDir, m.hostname))
+		policiesHost, err := NewFromCache(ctx, filepath.Join(cacheDir, hostname))
 		if err != nil {
-			return "", errors.New(gotext.Get("no policy applied for %q: %v", m.hostname, err))
+			return "", errors.New(gotext.Get("no policy applied for %q: %v", hostname, err))
 		}
 		for _, g := range policiesHost.GPOs {
 			alreadyProcessedRules = g.Format(&out, withRules, withOverridden, alreadyProcessedRules)
@@ -440,7 +1667,7 @@ func (m *Manager) DumpPolicies(ctx context.Context, objectName string, computerO
 	}
 
 	// Load target policies
-	policiesTarget, err := NewFromCache(ctx, filepath.Join(m.policiesCacheDir, objectName))
+	policiesTarget, err := NewFromCache(ctx, filepath.Join(cacheDir, objectName))
 	if err != nil {
 		log.Info(ctx, gotext.Get("User %q not found on cache.", objectName))
 		return "", errors.New(gotext.Get("no policy applied for %q: %v", objectName, err))
@@ -458,11 +1685,12 @@ func (m *Manager) LastUpdateFor(ctx context.Context, objectName string, isMachin
 
 	log.Infof(ctx, "Get policies last update time %q (machine: %t)", objectName, isMachine)
 
+	cacheDir, hostname := m.cachedPoliciesDir()
 	if isMachine {
-		objectName = m.hostname
+		objectName = hostname
 	}
 
-	info, err := os.Stat(filepath.Join(m.policiesCacheDir, objectName))
+	info, err := os.Stat(filepath.Join(cacheDir, m.cachedObjectName(objectName)))
 	if err != nil {
 		return time.Time{}, errors.New(gotext.Get("policies were not applied for %q: %v", objectName, err))
 	}
@@ -501,6 +1729,122 @@ func (m *Manager) GetSubscriptionState(ctx context.Context) (subscriptionEnabled
 	return true
 }
 
+// SystemMountsStatus returns the status of the system shares set by the mount policy.
+func (m *Manager) SystemMountsStatus(ctx context.Context) ([]mount.ShareStatus, error) {
+	return m.mount.SystemMountsStatus(ctx)
+}
+
+// PrivilegeFiles returns the sudoers and polkit files managed by the privilege policy, as written on disk.
+func (m *Manager) PrivilegeFiles(ctx context.Context) ([]privilege.ManagedFile, error) {
+	return m.privilege.ManagedFiles(ctx)
+}
+
+// SudoAccess reports whether the sudoers files written by the privilege policy grant sudo to user, and which rule
+// decides it.
+func (m *Manager) SudoAccess(ctx context.Context, user string) (privilege.SudoAccess, error) {
+	return m.privilege.SudoAccess(ctx, user)
+}
+
+// SystemAdminIdentities returns the system polkit configuration files setting administrators, and which one is
+// preserved by the privilege policy.
+func (m *Manager) SystemAdminIdentities(ctx context.Context) ([]privilege.SystemAdminIdentities, error) {
+	return m.privilege.SystemAdminIdentities(ctx)
+}
+
+// EffectivePolicy returns the YAML document of the policy in effect on the machine: the rules of the last
+// policy applied to it, as filtered when applying them, and the privilege configuration written on disk.
+func (m *Manager) EffectivePolicy(ctx context.Context) (doc string, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to export effective policy"))
+
+	cacheDir, hostname := m.cachedPoliciesDir()
+	pols, err := NewFromCache(ctx, filepath.Join(cacheDir, hostname))
+	if err != nil {
+		return "", errors.New(gotext.Get("no policy applied for %q: %v", hostname, err))
+	}
+	defer pols.Close()
+
+	applicable, err := pols.applicableTo(ctx, m.facts.Collect(ctx))
+	if err != nil {
+		return "", err
+	}
+	rules := applicable.GetUniqueRules()
+	if !m.GetSubscriptionState(ctx) {
+		filterRules(ctx, rules)
+	}
+
+	adminIdentities, err := m.privilege.AdminIdentities(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return effective.New(rules, adminIdentities).YAML()
+}
+
+// SimulatePolicy returns the YAML document of the policy the machine would get from pols, the policies computed for
+// another placement in AD: the GPOs, in order of priority, and the resulting effective policy. The rules are
+// filtered as when applying them, with the facts of this machine. Nothing is applied nor cached.
+func (m *Manager) SimulatePolicy(ctx context.Context, pols Policies) (doc string, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to simulate policy"))
+
+	applicable, err := pols.applicableTo(ctx, m.facts.Collect(ctx))
+	if err != nil {
+		return "", err
+	}
+	rules := applicable.GetUniqueRules()
+	if !m.GetSubscriptionState(ctx) {
+		filterRules(ctx, rules)
+	}
+
+	adminIdentities, err := m.privilege.SimulateAdminIdentities(ctx, rules["privilege"], pols.SaveAssetsTo)
+	if err != nil {
+		return "", err
+	}
+
+	var gpos []string
+	for _, g := range applicable.GPOs {
+		gpos = append(gpos, g.Name)
+	}
+
+	return effective.NewSimulation(gpos, rules, adminIdentities).YAML()
+}
+
+// VerifyPolicies compares the files managed by the privilege and proxy policies with the content they would have if
+// the policy last applied to the machine was written again. Nothing is changed on the system.
+func (m *Manager) VerifyPolicies(ctx context.Context) (files []integrity.File, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to verify managed files"))
+
+	// The imported cache doesn't match what was applied on the system.
+	if m.IsReplaying() {
+		return nil, errors.New(gotext.Get("the daemon is in replay mode"))
+	}
+
+	pols, err := NewFromCache(ctx, filepath.Join(m.policiesCacheDir, m.hostname))
+	if err != nil {
+		return nil, errors.New(gotext.Get("no policy applied for %q: %v", m.hostname, err))
+	}
+	defer pols.Close()
+
+	applicable, err := pols.applicableTo(ctx, m.facts.Collect(ctx))
+	if err != nil {
+		return nil, err
+	}
+	rules := applicable.GetUniqueRules()
+	if !m.GetSubscriptionState(ctx) {
+		filterRules(ctx, rules)
+	}
+
+	privilegeFiles, err := m.privilege.Verify(ctx, rules["privilege"], pols.SaveAssetsTo)
+	if err != nil {
+		return nil, err
+	}
+	proxyFiles, err := m.proxy.Verify(ctx, rules["proxy"])
+	if err != nil {
+		return nil, err
+	}
+
+	return append(privilegeFiles, proxyFiles...), nil
+}
+
 // filterRules allows to filter any rules that are not eligible for the current device,
 // and returns the sorted list of filtered rules.
 func filterRules(ctx context.Context, rules map[string][]entry.Entry) []string {
@@ -83,6 +83,37 @@ protocol://domain.com/mountpath
 `,
 	},
 
+	"entry with mount options": {Value: `
+smb://domain.com/mountpath;uid=1000,gid=1000,file_mode=0644,dir_mode=0755,vers=3.0
+[krb5]smb://krb_domain.com/mount/krb_path; ro, nosuid
+nfs://otherdomain.com/mount/path;nfsvers=4.2,hard,timeo=600
+`,
+	},
+
+	"entry with same values with and without mount options": {Value: `
+smb://domain.com/options_first;vers=3.0
+smb://domain.com/options_first
+smb://domain.com/no_options_first
+smb://domain.com/no_options_first;vers=3.0
+`,
+	},
+
+	"entry with source addresses": {Value: `
+smb://domain.com/interface;srcaddr=eth1
+smb://domain.com/address;vers=3.0,srcaddr=198.51.100.20
+smb://domain.com/default
+nfs://otherdomain.com/nfs/path
+`,
+	},
+	"entry with force unmount option": {Value: `
+smb://domain.com/forced;force-unmount
+nfs://otherdomain.com/forced/with/options;nfsvers=4.2,force-unmount,hard
+smb://domain.com/not_forced
+`,
+	},
+
+	"entry with not allowed mount option": {Value: "smb://domain.com/mountpath;uid=1000,suid"},
+
 	"errored entry": {Value: "protocol://domain.com/mountpath", Err: fmt.Errorf("some error")},
 
 	"entry with badly formatted value": {Value: "protocol//domain.com/mountpath"},
@@ -1,6 +1,7 @@
 package mount
 
 import (
+	"context"
 	"os/user"
 )
 
@@ -11,9 +12,37 @@ func WithUserLookup(f func(string) (*user.User, error)) Option {
 	}
 }
 
+// WithProcMounts defines a custom mounts file, in the /proc/mounts format, for tests.
+func WithProcMounts(p string) Option {
+	return func(o *options) {
+		o.procMounts = p
+	}
+}
+
 // SetSystemdCaller allows to override the systemdCaller of the Manager for the tests.
 // This is used instead of a option function because we need to control the
 // behavior of the mock in multiple occasions during tests.
 func (m *Manager) SetSystemdCaller(systemdCaller systemdCaller) {
 	m.systemdCaller = systemdCaller
 }
+
+// WithProcDir defines a custom directory, in the /proc format, to look up the processes using a busy mount for tests.
+func WithProcDir(p string) Option {
+	return func(o *options) {
+		o.procDir = p
+	}
+}
+
+// WithLazyUnmount defines a custom function to lazily unmount the busy shares for tests.
+func WithLazyUnmount(f func(context.Context, string) error) Option {
+	return func(o *options) {
+		o.lazyUnmount = f
+	}
+}
+
+// WithUserNotifier defines a custom function to notify the users of the unmount of a busy share for tests.
+func WithUserNotifier(f func(context.Context, string, string) error) Option {
+	return func(o *options) {
+		o.notifyUser = f
+	}
+}
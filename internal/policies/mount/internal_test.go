@@ -2,6 +2,8 @@ package mount
 
 import (
 	"context"
+	"errors"
+	"net"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -10,9 +12,13 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/attributes"
 	"github.com/ubuntu/adsys/internal/testutils"
 )
 
+// noExpand is a variables expander leaving the values unchanged.
+func noExpand(v string) (string, error) { return v, nil }
+
 func TestParseEntryValues(t *testing.T) {
 	t.Parallel()
 
@@ -31,18 +37,21 @@ func TestParseEntryValues(t *testing.T) {
 		"Parse values trimming sequential linebreaks": {entry: "entry with multiple linebreaks"},
 
 		// Special cases.
-		"Parse values from entry with kerberos auth tags": {entry: "entry with kerberos auth tags"},
-		"Returns empty slice if the entry is empty":       {entry: "entry with no value"},
+		"Parse values from entry with kerberos auth tags":                            {entry: "entry with kerberos auth tags"},
+		"Parse values from entry with mount options":                                 {entry: "entry with mount options"},
+		"Parse values prioritizing the first value found, despite the mount options": {entry: "entry with same values with and without mount options"},
+		"Returns empty slice if the entry is empty":                                  {entry: "entry with no value"},
 
 		// Error cases
-		"Error when parsing entry with badly formatted values": {entry: "entry with badly formatted value", wantErr: true},
+		"Error when parsing entry with badly formatted values":   {entry: "entry with badly formatted value", wantErr: true},
+		"Error when parsing entry with not allowed mount option": {entry: "entry with not allowed mount option", wantErr: true},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			got, err := parseEntryValues(context.Background(), EntriesForTests[tc.entry])
+			got, err := parseEntryValues(context.Background(), EntriesForTests[tc.entry], noExpand)
 			if tc.wantErr {
 				require.Error(t, err, "Expected an error but got none.")
 				return
@@ -56,6 +65,109 @@ func TestParseEntryValues(t *testing.T) {
 	}
 }
 
+func TestVariablesExpander(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		value       string
+		username    string
+		domain      string
+		site        string
+		siteErr     bool
+		noSiteSetup bool
+		noAttrs     bool
+
+		want    string
+		wantErr bool
+	}{
+		"Value without variables is unchanged": {value: "smb://server.example.com/home", want: "smb://server.example.com/home"},
+		"Site is replaced":                     {value: `smb://server.example.com/home/%site%`, want: "smb://server.example.com/home/Paris"},
+		"Domain is replaced":                   {value: `smb://%domain%/home`, want: "smb://example.com/home"},
+		"Username is replaced":                 {value: `smb://server.example.com/home/%username%`, username: "alice", want: "smb://server.example.com/home/alice"},
+		"Username is replaced without domain":  {value: `smb://server.example.com/home/%username%`, username: "alice@example.com", want: "smb://server.example.com/home/alice"},
+		"Variables are case insensitive":       {value: `smb://%DOMAIN%/%Site%/%UserName%`, username: "alice", want: "smb://example.com/Paris/alice"},
+		"Variable is replaced multiple times":  {value: `smb://%site%.%domain%/%site%`, want: "smb://Paris.example.com/Paris"},
+		"Variable in mount options is replaced": {value: `smb://server.example.com/home;uid=%username%`, username: "alice",
+			want: "smb://server.example.com/home;uid=alice"},
+		"Unknown variable is unchanged": {value: `smb://server.example.com/%other%`, want: "smb://server.example.com/%other%"},
+
+		// Computer attributes
+		"Computer attribute is replaced": {value: `smb://server.example.com/kiosks/%computer:location%`, want: "smb://server.example.com/kiosks/paris"},
+		"Computer attribute is replaced in user mounts": {value: `smb://server.example.com/%computer:location%/%username%`, username: "alice",
+			want: "smb://server.example.com/paris/alice"},
+		"Computer attribute name is case insensitive": {value: `smb://server.example.com/%Computer:departmentNumber%`, want: "smb://server.example.com/42"},
+		"Computer attribute with other variables":     {value: `smb://%domain%/%site%/%computer:location%`, want: "smb://example.com/Paris/paris"},
+		"Invalid computer attribute name is unchanged": {value: `smb://server.example.com/%computer:loc_ation%`,
+			want: "smb://server.example.com/%computer:loc_ation%"},
+
+		// Error cases
+		"Error on site which can't be looked up":                 {value: `smb://server.example.com/%site%`, siteErr: true, wantErr: true},
+		"Error on empty site":                                    {value: `smb://server.example.com/%site%`, site: "-", wantErr: true},
+		"Error on site without site lookup":                      {value: `smb://server.example.com/%site%`, noSiteSetup: true, wantErr: true},
+		"Error on empty domain":                                  {value: `smb://%domain%/home`, domain: "-", wantErr: true},
+		"Error on username for system mounts":                    {value: `smb://server.example.com/home/%username%`, wantErr: true},
+		"Error on computer attribute not read":                   {value: `smb://server.example.com/%computer:description%`, wantErr: true},
+		"Error on empty computer attribute":                      {value: `smb://server.example.com/%computer:info%`, wantErr: true},
+		"Error on computer attribute without any attribute read": {value: `smb://server.example.com/%computer:location%`, noAttrs: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			domain, site := "example.com", "Paris"
+			if tc.domain == "-" {
+				domain = ""
+			}
+			if tc.site == "-" {
+				site = ""
+			}
+			opts := []Option{WithDomain(domain)}
+			if !tc.noSiteSetup {
+				opts = append(opts, WithSiteLookup(func(context.Context) (string, error) {
+					if tc.siteErr {
+						return "", errors.New("site lookup error")
+					}
+					return site, nil
+				}))
+			}
+			m, err := New(t.TempDir(), t.TempDir(), nil, opts...)
+			require.NoError(t, err, "Setup: New should not return an error")
+
+			ctx := context.Background()
+			if !tc.noAttrs {
+				ctx = attributes.WithComputer(ctx, map[string]string{"location": "paris", "departmentnumber": "42", "info": ""})
+			}
+
+			got, err := m.variablesExpander(ctx, tc.username)(tc.value)
+			if tc.wantErr {
+				require.Error(t, err, "Expander should have returned an error but didn't")
+				return
+			}
+			require.NoError(t, err, "Expander should not have returned an error but did")
+			require.Equal(t, tc.want, got, "Expander returned an unexpected value")
+		})
+	}
+}
+
+func TestVariablesExpanderLooksUpSiteOnce(t *testing.T) {
+	t.Parallel()
+
+	var lookups int
+	m, err := New(t.TempDir(), t.TempDir(), nil, WithSiteLookup(func(context.Context) (string, error) {
+		lookups++
+		return "Paris", nil
+	}))
+	require.NoError(t, err, "Setup: New should not return an error")
+
+	expand := m.variablesExpander(context.Background(), "")
+	for _, v := range []string{"smb://server/%site%", "nfs://server/%site%", "smb://server/other"} {
+		_, err := expand(v)
+		require.NoError(t, err, "Expander should not have returned an error but did")
+	}
+	require.Equal(t, 1, lookups, "Site should be looked up only once")
+}
+
 func TestWriteFileWithUIDGID(t *testing.T) {
 	t.Parallel()
 
@@ -132,21 +244,48 @@ func TestCreateUnits(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		entry string
+		entry         string
+		sourceAddress string
+
+		wantErr bool
 	}{
 		"Write single unit":      {entry: "entry with one value"},
 		"Write multiple units":   {entry: "entry with multiple values"},
 		"Write krb5 tagged unit": {entry: "entry with kerberos auth tag"},
+
+		"Write cifs units bound to source address":                       {entry: "entry with multiple values", sourceAddress: "192.0.2.10"},
+		"Write units with mount options":                                 {entry: "entry with mount options"},
+		"Write units merging mount options":                              {entry: "entry with mount options", sourceAddress: "192.0.2.10"},
+		"Write units with force unmount option":                          {entry: "entry with force unmount option"},
+		"Write units with share source addresses":                        {entry: "entry with source addresses"},
+		"Write units with share source addresses overriding default one": {entry: "entry with source addresses", sourceAddress: "192.0.2.10"},
+
+		"Error when source address can't be resolved": {entry: "entry with multiple values", sourceAddress: "unknown0", wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			parsedValues, err := parseEntryValues(context.Background(), EntriesForTests[tc.entry])
+			parsedValues, err := parseEntryValues(context.Background(), EntriesForTests[tc.entry], noExpand)
 			require.NoError(t, err, "Setup: failed to parse entries for TestCreateUnits.")
 
 			unitPath := t.TempDir()
-			units := createUnits(parsedValues)
+			// Interfaces are resolved to a fixed address, while IP addresses are kept.
+			resolveSource := func(source string) (string, error) {
+				if source == "unknown0" {
+					return "", errors.New("unknown interface")
+				}
+				if net.ParseIP(source) == nil {
+					return "203.0.113.5", nil
+				}
+				return source, nil
+			}
+			units, err := createUnits(parsedValues, tc.sourceAddress, resolveSource)
+			if tc.wantErr {
+				require.Error(t, err, "createUnits should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "createUnits failed but shouldn't have")
 
 			for name, content := range units {
 				err := os.WriteFile(filepath.Join(unitPath, name), []byte(content), 0600)
@@ -157,3 +296,145 @@ func TestCreateUnits(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveSourceAddress(t *testing.T) {
+	t.Parallel()
+
+	cidr := func(s string) net.Addr {
+		ip, ipNet, err := net.ParseCIDR(s)
+		require.NoError(t, err, "Setup: invalid CIDR")
+		ipNet.IP = ip
+		return ipNet
+	}
+	interfaces := map[string][]net.Addr{
+		"eth0":      {cidr("fe80::1/64"), cidr("2001:db8::10/64"), cidr("192.0.2.10/24"), cidr("192.0.2.11/24")},
+		"ipv6only0": {cidr("fe80::1/64"), cidr("2001:db8::10/64"), cidr("2001:db8::11/64")},
+		"noaddr0":   {cidr("fe80::1/64")},
+	}
+
+	tests := map[string]struct {
+		source string
+
+		want    string
+		wantErr bool
+	}{
+		"IPv4 address is kept":                         {source: "198.51.100.20", want: "198.51.100.20"},
+		"IPv6 address is kept":                         {source: "2001:db8::20", want: "2001:db8::20"},
+		"Interface is resolved to its first IPv4":      {source: "eth0", want: "192.0.2.10"},
+		"IPv6 only interface is resolved to its first": {source: "ipv6only0", want: "2001:db8::10"},
+
+		"Error on unknown interface":                {source: "unknown0", wantErr: true},
+		"Error on interface without global address": {source: "noaddr0", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := Manager{interfaceAddrs: func(name string) ([]net.Addr, error) {
+				addrs, ok := interfaces[name]
+				if !ok {
+					return nil, errors.New("no such network interface")
+				}
+				return addrs, nil
+			}}
+
+			got, err := m.resolveSourceAddress(tc.source)
+			if tc.wantErr {
+				require.Error(t, err, "resolveSourceAddress should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "resolveSourceAddress failed but shouldn't have")
+			require.Equal(t, tc.want, got, "resolveSourceAddress should return the expected address")
+		})
+	}
+}
+
+func TestParseMountOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		mountType string
+		options   string
+
+		want    []string
+		wantErr bool
+	}{
+		// Allowed options.
+		"Allowed cifs options":              {mountType: "cifs", options: "uid=1000,gid=1000,file_mode=0644,dir_mode=0755,vers=3.0", want: []string{"uid=1000", "gid=1000", "file_mode=0644", "dir_mode=0755", "vers=3.0"}},
+		"Allowed cifs options with names":   {mountType: "cifs", options: "uid=alice@domain.com,gid=domain_users", want: []string{"uid=alice@domain.com", "gid=domain_users"}},
+		"Allowed cifs source address":       {mountType: "cifs", options: "srcaddr=2001:db8::10", want: []string{"srcaddr=2001:db8::10"}},
+		"Allowed cifs source interface":     {mountType: "cifs", options: "srcaddr=enp0s31f6.100", want: []string{"srcaddr=enp0s31f6.100"}},
+		"Allowed cifs flags":                {mountType: "cifs", options: "ro,nosuid,nodev,noexec", want: []string{"ro", "nosuid", "nodev", "noexec"}},
+		"Allowed cifs option with enum":     {mountType: "cifs", options: "cache=none", want: []string{"cache=none"}},
+		"Allowed nfs options":               {mountType: "nfs", options: "nfsvers=4.2,hard,timeo=600,proto=tcp", want: []string{"nfsvers=4.2", "hard", "timeo=600", "proto=tcp"}},
+		"Spaces around options are trimmed": {mountType: "cifs", options: " ro , vers=3.0 ", want: []string{"ro", "vers=3.0"}},
+		"Single option":                     {mountType: "nfs", options: "ro", want: []string{"ro"}},
+		"Force unmount option":              {mountType: "cifs", options: "force-unmount,ro", want: []string{"force-unmount", "ro"}},
+
+		// Rejected options.
+		"Error on unknown option":                            {mountType: "cifs", options: "vers=3.0,unknown", wantErr: true},
+		"Error on option granting privileges":                {mountType: "cifs", options: "suid", wantErr: true},
+		"Error on option with credentials":                   {mountType: "cifs", options: "username=alice,password=secret", wantErr: true},
+		"Error on credentials file option":                   {mountType: "cifs", options: "credentials=/root/creds", wantErr: true},
+		"Error on option overriding kerberos authentication": {mountType: "cifs", options: "sec=none", wantErr: true},
+		"Error on invalid source address":                    {mountType: "cifs", options: "srcaddr=192.0.2.10/24", wantErr: true},
+		"Error on source address for nfs mounts":             {mountType: "nfs", options: "srcaddr=192.0.2.10", wantErr: true},
+		"Error on option from another mount type":            {mountType: "nfs", options: "file_mode=0644", wantErr: true},
+		"Error on unsupported mount type":                    {mountType: "fuse", options: "ro", wantErr: true},
+
+		// Malformed options.
+		"Error on empty options":                {mountType: "cifs", options: "", wantErr: true},
+		"Error on empty option":                 {mountType: "cifs", options: "ro,,vers=3.0", wantErr: true},
+		"Error on option without name":          {mountType: "cifs", options: "=1000", wantErr: true},
+		"Error on flag with value":              {mountType: "cifs", options: "ro=yes", wantErr: true},
+		"Error on force unmount with value":     {mountType: "cifs", options: "force-unmount=yes", wantErr: true},
+		"Error on option without value":         {mountType: "cifs", options: "uid", wantErr: true},
+		"Error on option with empty value":      {mountType: "cifs", options: "uid=", wantErr: true},
+		"Error on invalid numeric value":        {mountType: "nfs", options: "timeo=ten", wantErr: true},
+		"Error on invalid mode value":           {mountType: "cifs", options: "file_mode=0999", wantErr: true},
+		"Error on invalid version value":        {mountType: "cifs", options: "vers=3.x", wantErr: true},
+		"Error on invalid enum value":           {mountType: "cifs", options: "cache=always", wantErr: true},
+		"Error on value with systemd specifier": {mountType: "cifs", options: "uid=%u", wantErr: true},
+		"Error on value with spaces":            {mountType: "cifs", options: "uid=alice smith", wantErr: true},
+		"Error on option set multiple times":    {mountType: "cifs", options: "uid=1000,uid=1001", wantErr: true},
+		"Error on spaces around option equal":   {mountType: "cifs", options: "uid = 1000", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseMountOptions(tc.mountType, tc.options)
+			if tc.wantErr {
+				require.Error(t, err, "parseMountOptions should have returned an error but didn't")
+				return
+			}
+			require.NoError(t, err, "parseMountOptions should not have returned an error but did")
+			require.Equal(t, tc.want, got, "parseMountOptions returned unexpected options")
+		})
+	}
+}
+
+func TestUnescapeMountField(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		field string
+
+		want string
+	}{
+		"Field without escape is unchanged":   {field: "/adsys/cifs/domain.com/share", want: "/adsys/cifs/domain.com/share"},
+		"Escaped space is decoded":            {field: `/media/with\040space`, want: "/media/with space"},
+		"Multiple escapes are decoded":        {field: `/a\011b\134c`, want: "/a\tb\\c"},
+		"Invalid escape is kept":              {field: `/a\9xyz`, want: `/a\9xyz`},
+		"Truncated escape at the end is kept": {field: `/a\04`, want: `/a\04`},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := unescapeMountField(tc.field)
+			require.Equal(t, tc.want, got, "unescapeMountField should return the expected value")
+		})
+	}
+}
@@ -11,6 +11,9 @@
 // entries values and gvfs to mount the requested shared drives.
 // Should an error occur during this step, it will be logged (in the system journal for the system
 // or in the adsys-user-mounts.service for the user).
+//
+// As the user mounts are only mounted when the session starts, replacing or removing previously applied ones
+// is reported as a relogin hint.
 package mount
 
 import (
@@ -18,39 +21,97 @@ import (
 	_ "embed"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/user"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/coreos/go-systemd/v22/unit"
 	"github.com/leonelquinteros/gotext"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/attributes"
 	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/restart"
 	"github.com/ubuntu/decorate"
 )
 
 type options struct {
-	userLookup    func(string) (*user.User, error)
-	systemUnitDir string
+	userLookup     func(string) (*user.User, error)
+	systemUnitDir  string
+	sourceAddress  string
+	procMounts     string
+	domain         string
+	siteLookup     func(context.Context) (string, error)
+	procDir        string
+	lazyUnmount    func(context.Context, string) error
+	notifyUser     func(context.Context, string, string) error
+	interfaceAddrs func(string) ([]net.Addr, error)
 }
 
 // Option represents an optional function that is able to alter a default behavior used in mount.
 type Option func(*options)
 
+// WithSourceAddress binds the system CIFS mounts to the given local IP address or to the address of the given
+// network interface (mount option srcaddr), unless the share sets its own. It is used on multi-homed machines to
+// force the traffic through a given interface.
+func WithSourceAddress(addr string) Option {
+	return func(o *options) {
+		o.sourceAddress = addr
+	}
+}
+
+// WithDomain sets the Active Directory domain which the %domain% variable of the mount entries is replaced by.
+func WithDomain(domain string) Option {
+	return func(o *options) {
+		o.domain = domain
+	}
+}
+
+// WithSiteLookup sets the function returning the Active Directory site of the machine, which the %site% variable
+// of the mount entries is replaced by.
+func WithSiteLookup(f func(context.Context) (string, error)) Option {
+	return func(o *options) {
+		o.siteLookup = f
+	}
+}
+
 //go:embed adsys-mount-template.mount
 var systemdUnitTemplate string
 
 const krbTag string = "[krb5]"
 const defaultMountTimeoutSec int = 30
 
+// forceUnmountKey marks the mount units of the shares with the force-unmount option. Keys prefixed with X- are
+// ignored by systemd.
+const forceUnmountKey = "X-ADSysForceUnmount"
+
+// variablesRe matches the variables which can be used in the mount entries. They are case insensitive, as on Windows.
+var variablesRe = regexp.MustCompile(`(?i)%(site|domain|username|computer:[a-z][a-z0-9-]*)%`)
+
 // Manager holds information needed for handling the mount policies.
 type Manager struct {
 	runDir        string
 	systemUnitDir string
 	systemdCaller systemdCaller
+	sourceAddress string
+	procMounts    string
+	domain        string
+	siteLookup    func(context.Context) (string, error)
+	procDir       string
+	lazyUnmount   func(context.Context, string) error
+	notifyUser    func(context.Context, string, string) error
+	// interfaceAddrs returns the addresses of a network interface, to bind the mounts to.
+	interfaceAddrs func(string) ([]net.Addr, error)
+
+	// startErrors are the errors of the last start of the system mount units which failed.
+	startErrors   map[string]error
+	startErrorsMu sync.Mutex
 
 	userLookup func(string) (*user.User, error)
 }
@@ -70,12 +131,28 @@ func New(runDir string, systemUnitDir string, systemdCaller systemdCaller, opts
 	o := options{
 		userLookup:    user.Lookup,
 		systemUnitDir: systemUnitDir,
+		procMounts:    "/proc/mounts",
+		procDir:       "/proc",
+		lazyUnmount:   lazyUnmount,
+		notifyUser:    notifyUser,
+		interfaceAddrs: func(name string) ([]net.Addr, error) {
+			iface, err := net.InterfaceByName(name)
+			if err != nil {
+				return nil, err
+			}
+			return iface.Addrs()
+		},
 	}
 
 	for _, opt := range opts {
 		opt(&o)
 	}
 
+	// The interface is only resolved when applying the policy, as it can be brought up later.
+	if o.sourceAddress != "" && !sourceValue(o.sourceAddress) {
+		return nil, errors.New(gotext.Get("invalid mount source address %q: must be an IP address or a network interface name", o.sourceAddress))
+	}
+
 	// Multiple users will be in users/ subdirectory. Create the main one.
 	//nolint:gosec // G301 - multiple users will be in users/ subdirectory, we want all of them to be able to access its own subdirectory.
 	if err := os.MkdirAll(filepath.Join(runDir, "users"), 0750); err != nil {
@@ -92,9 +169,19 @@ func New(runDir string, systemUnitDir string, systemdCaller systemdCaller, opts
 	}
 
 	return &Manager{
-		runDir:        runDir,
-		systemUnitDir: systemUnitDir,
-		systemdCaller: systemdCaller,
+		runDir:         runDir,
+		systemUnitDir:  systemUnitDir,
+		systemdCaller:  systemdCaller,
+		sourceAddress:  o.sourceAddress,
+		procMounts:     o.procMounts,
+		domain:         o.domain,
+		siteLookup:     o.siteLookup,
+		procDir:        o.procDir,
+		lazyUnmount:    o.lazyUnmount,
+		notifyUser:     o.notifyUser,
+		interfaceAddrs: o.interfaceAddrs,
+
+		startErrors: make(map[string]error),
 
 		userLookup: o.userLookup,
 	}, nil
@@ -160,10 +247,15 @@ func (m *Manager) applyUserMountsPolicy(ctx context.Context, username string, en
 		return errors.New(gotext.Get("can't create user directory %q for %q: %v", objectPath, username, err))
 	}
 
-	parsedValues, err := parseEntryValues(ctx, entry)
+	parsedValues, err := parseEntryValues(ctx, entry, m.variablesExpander(ctx, username))
 	if err != nil {
 		return err
 	}
+	for _, v := range parsedValues {
+		if strings.Contains(v, optionsSeparator) {
+			return errors.New(gotext.Get("mount options are only supported for system mounts: %q", v))
+		}
+	}
 
 	s := strings.Join(parsedValues, "\n")
 	if s == "" {
@@ -173,9 +265,14 @@ func (m *Manager) applyUserMountsPolicy(ctx context.Context, username string, en
 		return nil
 	}
 
+	// The mounts are only read when the user session starts: replacing the previous ones needs a relogin.
+	oldContent, readErr := os.ReadFile(mountsPath)
 	if err = writeFileWithUIDGID(mountsPath, uid, gid, s); err != nil {
 		return err
 	}
+	if readErr == nil && string(oldContent) != s+"\n" {
+		restart.Require(ctx, restart.Hint{Rule: "mount", Kind: restart.Relogin, Details: gotext.Get("user mounts changed")})
+	}
 
 	return nil
 }
@@ -185,11 +282,14 @@ func (m *Manager) applySystemMountsPolicy(ctx context.Context, machineName strin
 
 	log.Debug(ctx, gotext.Get("Applying mount policy to machine %q", machineName))
 
-	parsedValues, err := parseEntryValues(ctx, entry)
+	parsedValues, err := parseEntryValues(ctx, entry, m.variablesExpander(ctx, ""))
+	if err != nil {
+		return err
+	}
+	newUnits, err := createUnits(parsedValues, m.sourceAddress, m.resolveSourceAddress)
 	if err != nil {
 		return err
 	}
-	newUnits := createUnits(parsedValues)
 
 	// Marks shares to write as new units and removes from map units that shouldn't change
 	needsReload := false
@@ -236,9 +336,11 @@ func (m *Manager) applySystemMountsPolicy(ctx context.Context, machineName strin
 		if err := m.systemdCaller.EnableUnit(ctx, name); err != nil {
 			return err
 		}
-		if err := m.systemdCaller.StartUnit(ctx, name); err != nil {
+		err := m.systemdCaller.StartUnit(ctx, name)
+		if err != nil {
 			log.Warning(ctx, gotext.Get("failed to start unit %q: %v", name, err))
 		}
+		m.setStartError(name, err)
 	}
 
 	return nil
@@ -250,14 +352,32 @@ type mountInfo struct {
 	sharedPath string
 	protocol   string
 	options    []string
+	// forceUnmount is set if the share should be lazily unmounted when it is busy on removal.
+	forceUnmount bool
 }
 
 // createUnits formats the adsys-.mount template with the specified paths.
-func createUnits(mountPaths []string) map[string]string {
+// CIFS mounts are bound to the local address resolved from the source set on the share, or from sourceAddress
+// if not empty.
+func createUnits(mountPaths []string, sourceAddress string, resolveSource func(string) (string, error)) (map[string]string, error) {
 	units := make(map[string]string)
 
 	for _, mp := range mountPaths {
 		mi := parseMountPath(mp)
+		if mi.protocol == "cifs" {
+			source := sourceAddress
+			if i := slices.IndexFunc(mi.options, func(o string) bool { return strings.HasPrefix(o, "srcaddr=") }); i != -1 {
+				source = strings.TrimPrefix(mi.options[i], "srcaddr=")
+				mi.options = slices.Delete(mi.options, i, i+1)
+			}
+			if source != "" {
+				addr, err := resolveSource(source)
+				if err != nil {
+					return nil, err
+				}
+				mi.options = append(mi.options, fmt.Sprintf("srcaddr=%s", addr))
+			}
+		}
 
 		what := whatStringFromInfo(mi)
 		where := filepath.Join("/", "adsys", mi.protocol, mi.hostname, mi.sharedPath)
@@ -267,6 +387,11 @@ func createUnits(mountPaths []string) map[string]string {
 			opts = strings.Join(mi.options, ",")
 		}
 
+		var extra string
+		if mi.forceUnmount {
+			extra = forceUnmountKey + "=true\n"
+		}
+
 		content := fmt.Sprintf(systemdUnitTemplate,
 			mp,                     // Description
 			what,                   // What
@@ -274,20 +399,55 @@ func createUnits(mountPaths []string) map[string]string {
 			mi.protocol,            // Type
 			opts,                   // Options
 			defaultMountTimeoutSec, // TimeoutSec
+			extra,                  // Additional keys
 		)
 
 		n := fmt.Sprintf("%s.mount", unit.UnitNameEscape(where[1:]))
 		units[n] = content
 	}
 
-	return units
+	return units, nil
 }
 
-// parseMountPath takes a mount path <protocol>://<hostname>/<shared_path> and parses it
-// into the richer type mountInfo.
+// resolveSourceAddress returns the local IP address of source, which is either an IP address or the name of a
+// network interface. The first IPv4 address of the interface is preferred over its IPv6 ones.
+func (m *Manager) resolveSourceAddress(source string) (string, error) {
+	if net.ParseIP(source) != nil {
+		return source, nil
+	}
+
+	addrs, err := m.interfaceAddrs(source)
+	if err != nil {
+		return "", errors.New(gotext.Get("can't get the address of the source interface %q: %v", source, err))
+	}
+	var ipv6 string
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ip := ipNet.IP.To4(); ip != nil {
+			return ip.String(), nil
+		}
+		if ipv6 == "" {
+			ipv6 = ipNet.IP.String()
+		}
+	}
+	if ipv6 == "" {
+		return "", errors.New(gotext.Get("source interface %q has no IP address", source))
+	}
+	return ipv6, nil
+}
+
+// parseMountPath takes a mount path <protocol>://<hostname>/<shared_path>, optionally followed by its
+// mount options, and parses it into the richer type mountInfo.
+// The mount options are expected to be already validated.
 func parseMountPath(path string) mountInfo {
 	var info mountInfo
 
+	// path = [krb5]protocol://hostname/shared_path;options
+	path, options, _ := strings.Cut(path, optionsSeparator)
+
 	// path = [krb5]protocol://hostname/shared_path
 	krb5 := strings.HasPrefix(path, krbTag)
 	if krb5 {
@@ -298,16 +458,17 @@ func parseMountPath(path string) mountInfo {
 
 	// path = protocol://hostname/shared_path
 	protocol, path, _ := strings.Cut(path, ":")
-
-	// Some aliases for common mounts protocols, as they need to be converted to a type
-	// recognized by systemd and the mount command.
-	switch protocol {
-	case "smb":
-		info.protocol = "cifs"
-	case "ftp":
-		info.protocol = "fuse"
-	default:
-		info.protocol = protocol
+	info.protocol = mountType(protocol)
+
+	if options != "" {
+		opts, _ := parseMountOptions(info.protocol, options)
+		for _, o := range opts {
+			if o == forceUnmountOption {
+				info.forceUnmount = true
+				continue
+			}
+			info.options = append(info.options, o)
+		}
 	}
 
 	// path = //hostname/shared_path
@@ -319,6 +480,19 @@ func parseMountPath(path string) mountInfo {
 	return info
 }
 
+// mountType returns the mount type of a protocol. Some aliases for common mounts protocols need to be
+// converted to a type recognized by systemd and the mount command.
+func mountType(protocol string) string {
+	switch protocol {
+	case "smb":
+		return "cifs"
+	case "ftp":
+		return "fuse"
+	default:
+		return protocol
+	}
+}
+
 // whatStringFromInfo creates the What value of a systemd mount unit from the
 // specified info as some protocols have quite different What values.
 // If the protocol is not recognized, the What string will be that of a partition
@@ -345,8 +519,65 @@ func whatStringFromInfo(mi mountInfo) string {
 	return what
 }
 
-// parseEntryValues parses the entry value, trimming whitespaces and removing duplicates.
-func parseEntryValues(ctx context.Context, e entry.Entry) (p []string, err error) {
+// variablesExpander returns a function replacing the %site%, %domain%, %username% and %computer:<attribute>%
+// variables of a mount entry value. %username% is the name of the user without its domain and is empty for the system
+// mounts. %computer:<attribute>% is an attribute of the computer object, read from AD at the machine refresh. The
+// site is looked up once, when first used.
+// Any variable which can't be resolved errors out, so that the entry is never mounted from a wrong location.
+func (m *Manager) variablesExpander(ctx context.Context, username string) func(string) (string, error) {
+	username, _, _ = strings.Cut(username, "@")
+	values := map[string]string{"domain": m.domain, "username": username}
+	resolveErrs := map[string]error{
+		"domain":   errors.New(gotext.Get("the domain is unknown")),
+		"username": errors.New(gotext.Get("it is only available in user mounts")),
+	}
+	siteLooked := false
+
+	return func(value string) (string, error) {
+		var errs []error
+		expanded := variablesRe.ReplaceAllStringFunc(value, func(variable string) string {
+			name := strings.ToLower(strings.Trim(variable, "%"))
+			if attr, isAttr := strings.CutPrefix(name, "computer:"); isAttr {
+				if v, ok := attributes.Computer(ctx, attr); ok && v != "" {
+					return v
+				}
+				errs = append(errs, errors.New(gotext.Get("can't resolve %s in %q: the attribute %s of the computer object is not read from AD or not set", variable, value, attr)))
+				return variable
+			}
+			if name == "site" && !siteLooked {
+				siteLooked = true
+				values["site"], resolveErrs["site"] = m.lookupSite(ctx)
+			}
+			if values[name] == "" {
+				errs = append(errs, errors.New(gotext.Get("can't resolve %s in %q: %v", variable, value, resolveErrs[name])))
+				return variable
+			}
+			return values[name]
+		})
+		if len(errs) > 0 {
+			return "", errors.Join(errs...)
+		}
+		return expanded, nil
+	}
+}
+
+// lookupSite returns the Active Directory site of the machine.
+func (m *Manager) lookupSite(ctx context.Context) (string, error) {
+	if m.siteLookup == nil {
+		return "", errors.New(gotext.Get("the site is unknown"))
+	}
+	site, err := m.siteLookup(ctx)
+	if err != nil {
+		return "", err
+	}
+	if site == "" {
+		return "", errors.New(gotext.Get("the site is unknown"))
+	}
+	return site, nil
+}
+
+// parseEntryValues parses the entry value, trimming whitespaces, expanding the variables and removing duplicates.
+func parseEntryValues(ctx context.Context, e entry.Entry, expand func(string) (string, error)) (p []string, err error) {
 	defer decorate.OnError(&err, gotext.Get("failed to parse entry values"))
 
 	if e.Err != nil {
@@ -360,13 +591,21 @@ func parseEntryValues(ctx context.Context, e entry.Entry) (p []string, err error
 			continue
 		}
 
-		// Compares "normal" and prefixed values the same way, since the unit name will be the same.
-		tmp := strings.TrimPrefix(v, krbTag)
+		v, err = expand(v)
+		if err != nil {
+			return nil, err
+		}
+
+		// Compares "normal" and prefixed values, with or without options, the same way, since the unit name
+		// will be the same.
+		tmp, _, _ := strings.Cut(strings.TrimPrefix(v, krbTag), optionsSeparator)
 		if prev, ok := seen[tmp]; ok {
 			if prev == v {
 				log.Debug(ctx, gotext.Get("Value %q is duplicated.", v))
 			} else {
 				log.Warning(ctx, gotext.Get("The location %q was already set up to be mounted with different options or authentication. The first provided value %q will be used instead.", v, prev))
+				ignored.Record(ctx, ignored.Entry{Rule: "mount", Key: e.Key, Value: v, Reason: ignored.DuplicateValue,
+					Details: gotext.Get("location already set up by %q", prev)})
 			}
 			continue
 		}
@@ -382,16 +621,27 @@ func parseEntryValues(ctx context.Context, e entry.Entry) (p []string, err error
 	return p, nil
 }
 
-// checkValue checks if the entry value respects the defined formatting directive: <protocol>://<hostname-or-ip>/<shared-path>.
+// checkValue checks if the entry value respects the defined formatting directive:
+// <protocol>://<hostname-or-ip>/<shared-path>, optionally followed by ;<mount-options>.
 func checkValue(value string) error {
 	// Removes the kerberos auth tag, if it exists
 	tmp := strings.TrimPrefix(value, krbTag)
 
+	// Separates the mount options, if any
+	tmp, options, hasOptions := strings.Cut(tmp, optionsSeparator)
+
 	// Value left: protocol://<hostname-or-ip>/<shared-path>
-	if _, hostnameAndPath, found := strings.Cut(tmp, ":"); !found || !strings.HasPrefix(hostnameAndPath, "//") {
+	protocol, hostnameAndPath, found := strings.Cut(tmp, ":")
+	if !found || !strings.HasPrefix(hostnameAndPath, "//") {
 		return errors.New(gotext.Get("entry %q is badly formatted", value))
 	}
 
+	if hasOptions {
+		if _, err := parseMountOptions(mountType(protocol), options); err != nil {
+			return errors.New(gotext.Get("entry %q is badly formatted: %v", value, err))
+		}
+	}
+
 	return nil
 }
 
@@ -493,9 +743,12 @@ func (m *Manager) cleanupMountsFile(ctx context.Context, uid string) (err error)
 
 	// Since the function might be called even if there is not a mounts file, we
 	// must ignore the ErrNotExist returned by os.Remove.
-	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+	if err := os.Remove(p); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
 		return err
 	}
+	restart.Require(ctx, restart.Hint{Rule: "mount", Kind: restart.Relogin, Details: gotext.Get("user mounts removed")})
 	return nil
 }
 
@@ -506,7 +759,13 @@ func (m *Manager) cleanupMountUnits(ctx context.Context, units []string) (err er
 	for _, unit := range units {
 		// Tries to stop the unit before disabling and removing it.
 		if err := m.systemdCaller.StopUnit(ctx, unit); err != nil {
-			log.Warning(ctx, gotext.Get("Failed to stop unit %q: %v", unit, err))
+			target, force := forceUnmountTarget(filepath.Join(m.systemUnitDir, unit))
+			if !force {
+				log.Warning(ctx, gotext.Get("Failed to stop unit %q: %v", unit, err))
+			} else {
+				log.Warning(ctx, gotext.Get("Failed to stop unit %q, forcing the unmount of %q: %v", unit, target, err))
+				m.forceUnmount(ctx, target)
+			}
 		}
 
 		// Disables the unit before removing it.
@@ -517,6 +776,7 @@ func (m *Manager) cleanupMountUnits(ctx context.Context, units []string) (err er
 		if err := os.Remove(filepath.Join(m.systemUnitDir, unit)); err != nil {
 			return errors.New(gotext.Get("could not remove file %q: %v", unit, err))
 		}
+		m.setStartError(unit, nil)
 	}
 
 	return nil
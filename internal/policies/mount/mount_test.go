@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/ubuntu/adsys/internal/policies/entry"
 	"github.com/ubuntu/adsys/internal/policies/mount"
+	"github.com/ubuntu/adsys/internal/policies/restart"
 	"github.com/ubuntu/adsys/internal/testutils"
 )
 
@@ -20,13 +21,18 @@ func TestNew(t *testing.T) {
 	tests := map[string]struct {
 		readOnlyRunDir     bool
 		readOnlySystemdDir bool
+		sourceAddress      string
 
 		wantErr bool
 	}{
-		"Creates manager successfully": {},
+		"Creates manager successfully":             {},
+		"Creates manager with IPv4 source address": {sourceAddress: "192.0.2.10"},
+		"Creates manager with IPv6 source address": {sourceAddress: "2001:db8::10"},
+		"Creates manager with source interface":    {sourceAddress: "eth0"},
 
 		"Error when runDir has invalid permissions":        {readOnlyRunDir: true, wantErr: true},
 		"Error when systemUnitDir has invalid permissions": {readOnlySystemdDir: true, wantErr: true},
+		"Error when source address is invalid":             {sourceAddress: "192.0.2.10/24", wantErr: true},
 	}
 
 	for name, tc := range tests {
@@ -48,7 +54,7 @@ func TestNew(t *testing.T) {
 				testutils.MakeReadOnly(t, systemdDir)
 			}
 
-			_, err := mount.New(runDir, filepath.Join(systemdDir, "system"), &mockSystemdCaller{})
+			_, err := mount.New(runDir, filepath.Join(systemdDir, "system"), &mockSystemdCaller{}, mount.WithSourceAddress(tc.sourceAddress))
 			if tc.wantErr {
 				require.Error(t, err, "Expected an error when creating manager but got none.")
 				return
@@ -85,6 +91,7 @@ func TestApplyPolicy(t *testing.T) {
 		secondMockSystemdCaller     mockSystemdCaller
 		pathAlreadyExistsSecondCall bool
 
+		wantRelogin       bool
 		wantErr           bool
 		wantErrSecondCall bool
 	}{
@@ -108,10 +115,10 @@ func TestApplyPolicy(t *testing.T) {
 		"User, creates only dirs if there are no entries":                {entries: []string{"no entries"}},
 
 		// Policy refresh.
-		"User, mount file is removed on refreshing policy with no entries":                    {secondCall: []string{"no entries"}},
-		"User, mount file is removed on refreshing policy with an empty entry":                {secondCall: []string{"entry with no value"}},
-		"User, mount file is removed on refreshing policy with a disabled entry":              {secondCall: []string{"entry with one value"}, isDisabledSecondCall: true},
-		"User, mount file is updated on refreshing policy with an entry with multiple values": {secondCall: []string{"entry with multiple values"}},
+		"User, mount file is removed on refreshing policy with no entries":                    {secondCall: []string{"no entries"}, wantRelogin: true},
+		"User, mount file is removed on refreshing policy with an empty entry":                {secondCall: []string{"entry with no value"}, wantRelogin: true},
+		"User, mount file is removed on refreshing policy with a disabled entry":              {secondCall: []string{"entry with one value"}, isDisabledSecondCall: true, wantRelogin: true},
+		"User, mount file is updated on refreshing policy with an entry with multiple values": {secondCall: []string{"entry with multiple values"}, wantRelogin: true},
 
 		/**************************** SYSTEM ***************************/
 		// Success cases.
@@ -158,10 +165,12 @@ func TestApplyPolicy(t *testing.T) {
 		"Error when cleaning up user policy with no entries and path already exists as a directory":  {entries: []string{"no entries"}, pathAlreadyExists: true, wantErr: true},
 		"Error when cleaning up user policy with empty entry and path already exists as a directory": {entries: []string{"entry with no value"}, pathAlreadyExists: true, wantErr: true},
 		"Error when applying policy with entry containing badly formatted value":                     {entries: []string{"entry with badly formatted value"}, wantErr: true},
+		"Error when applying policy with entry containing mount options":                             {entries: []string{"entry with mount options"}, wantErr: true},
 
 		/**************************** SYSTEM ***************************/
 		// Error cases.
 		"Error when creating units with bad entry values":                        {entries: []string{"entry with badly formatted value"}, isComputer: true, wantErr: true},
+		"Error when creating units with not allowed mount option":                {entries: []string{"entry with not allowed mount option"}, isComputer: true, wantErr: true},
 		"Error when daemon-reload fails":                                         {firstMockSystemdCaller: mockSystemdCaller{failOn: daemonReload}, isComputer: true, wantErr: true},
 		"Error when disabling units for clean up fails":                          {secondCall: []string{"entry with multiple values"}, isComputer: true, secondMockSystemdCaller: mockSystemdCaller{failOn: disable}, wantErrSecondCall: true},
 		"Error when enabling new units fails":                                    {isComputer: true, firstMockSystemdCaller: mockSystemdCaller{failOn: enable}, wantErr: true},
@@ -233,7 +242,10 @@ func TestApplyPolicy(t *testing.T) {
 			m, err := mount.New(runDir, systemUnitDir, &tc.firstMockSystemdCaller, opts...)
 			require.NoError(t, err, "Setup: Failed to create manager for the tests.")
 
-			err = m.ApplyPolicy(context.Background(), tc.objectName, tc.isComputer, entries)
+			hints := &restart.Collector{}
+			ctx := restart.WithCollector(context.Background(), hints)
+
+			err = m.ApplyPolicy(ctx, tc.objectName, tc.isComputer, entries)
 			if tc.wantErr {
 				require.Error(t, err, "ApplyPolicy should have returned an error but did not")
 				return
@@ -261,7 +273,7 @@ func TestApplyPolicy(t *testing.T) {
 					testutils.CreatePath(t, filepath.Join(p, "not_empty"))
 				}
 
-				err = m.ApplyPolicy(context.Background(), tc.objectName, tc.isComputer, secondEntries)
+				err = m.ApplyPolicy(ctx, tc.objectName, tc.isComputer, secondEntries)
 				if tc.wantErrSecondCall {
 					require.Error(t, err, "Second call should have returned an error but didn't")
 				} else {
@@ -273,6 +285,169 @@ func TestApplyPolicy(t *testing.T) {
 				makeIndependentOfCurrentUID(t, runDir, u.Uid)
 			}
 			testutils.CompareTreesWithFiltering(t, rootDir, testutils.GoldenPath(t), testutils.UpdateEnabled())
+
+			if !tc.wantRelogin {
+				require.Empty(t, hints.Hints(), "ApplyPolicy should not require a restart")
+				return
+			}
+			require.Len(t, hints.Hints(), 1, "ApplyPolicy should require one restart")
+			require.Equal(t, "mount", hints.Hints()[0].Rule, "ApplyPolicy should require a restart for the mount rule")
+			require.Equal(t, restart.Relogin, hints.Hints()[0].Kind, "ApplyPolicy should require a relogin")
+		})
+	}
+}
+
+func TestSystemMountsStatus(t *testing.T) {
+	t.Parallel()
+
+	const (
+		cifsTarget     = "/adsys/cifs/otherdomain.com/mount/path"
+		nfsTarget      = "/adsys/nfs/yetanotherdomain.com/mount_path/mount/path"
+		protocolTarget = "/adsys/protocol/domain.com/mountpath2"
+	)
+
+	tests := map[string]struct {
+		entry      string
+		procMounts string
+		startFails bool
+
+		want    map[string]mount.ShareState
+		wantErr bool
+	}{
+		"All shares are mounted": {procMounts: "all_mounted", want: map[string]mount.ShareState{
+			cifsTarget: mount.ShareMounted, nfsTarget: mount.ShareMounted, protocolTarget: mount.ShareMounted}},
+		"No share is mounted": {procMounts: "no_mounts", want: map[string]mount.ShareState{
+			cifsTarget: mount.ShareUnmounted, nfsTarget: mount.ShareUnmounted, protocolTarget: mount.ShareUnmounted}},
+		"Shares are matched on target and type": {procMounts: "some_mounted", want: map[string]mount.ShareState{
+			cifsTarget: mount.ShareMounted, nfsTarget: mount.ShareUnmounted, protocolTarget: mount.ShareUnmounted}},
+		"Shares failing to start are failed unless mounted": {procMounts: "some_mounted", startFails: true, want: map[string]mount.ShareState{
+			cifsTarget: mount.ShareMounted, nfsTarget: mount.ShareFailed, protocolTarget: mount.ShareFailed}},
+		"No share without policy": {entry: "entry with no value", procMounts: "all_mounted", want: map[string]mount.ShareState{}},
+
+		"Error when mounts file does not exist": {procMounts: "does_not_exist", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rootDir := t.TempDir()
+			if tc.entry == "" {
+				tc.entry = "entry with multiple values"
+			}
+			e := mount.EntriesForTests[tc.entry]
+			e.Key = "system-mounts"
+
+			// StartUnit fails with the zero value of the mock.
+			systemdCaller := mockSystemdCaller{failOn: stop}
+			if tc.startFails {
+				systemdCaller = mockSystemdCaller{failOn: start}
+			}
+			m, err := mount.New(filepath.Join(rootDir, "run", "adsys"), filepath.Join(rootDir, "etc", "systemd", "system"), &systemdCaller,
+				mount.WithProcMounts(filepath.Join("testdata", "TestSystemMountsStatus", tc.procMounts)))
+			require.NoError(t, err, "Setup: Failed to create manager for the tests.")
+			err = m.ApplyPolicy(context.Background(), "ubuntu", true, []entry.Entry{e})
+			require.NoError(t, err, "Setup: ApplyPolicy should not have returned an error but did")
+
+			status, err := m.SystemMountsStatus(context.Background())
+			if tc.wantErr {
+				require.Error(t, err, "SystemMountsStatus should have returned an error but did not")
+				return
+			}
+			require.NoError(t, err, "SystemMountsStatus should not have returned an error but did")
+
+			got := make(map[string]mount.ShareState)
+			for _, s := range status {
+				got[s.Target] = s.State
+				if s.State == mount.ShareFailed {
+					require.NotEmpty(t, s.Error, "Failed share should have an error")
+				} else {
+					require.Empty(t, s.Error, "Share which is not failed should not have an error")
+				}
+			}
+			require.Equal(t, tc.want, got, "SystemMountsStatus should return the expected share states")
+		})
+	}
+}
+
+func TestForceUnmountBusyShares(t *testing.T) {
+	t.Parallel()
+
+	const target = "/adsys/cifs/domain.com/share"
+
+	tests := map[string]struct {
+		value     string
+		stopFails bool
+		lazyFails bool
+		notifyErr bool
+
+		wantLazyUnmount bool
+		wantNotified    []string
+	}{
+		"Busy share with force-unmount is lazily unmounted and its users notified": {value: "smb://domain.com/share;force-unmount", stopFails: true,
+			wantLazyUnmount: true, wantNotified: []string{"1000", "1001"}},
+		"Busy share with force-unmount and other options is lazily unmounted": {value: "smb://domain.com/share;ro,force-unmount,vers=3.0", stopFails: true,
+			wantLazyUnmount: true, wantNotified: []string{"1000", "1001"}},
+		"Failing notifications are only logged": {value: "smb://domain.com/share;force-unmount", stopFails: true, notifyErr: true,
+			wantLazyUnmount: true, wantNotified: []string{"1000", "1001"}},
+
+		"Busy share without force-unmount is not lazily unmounted":     {value: "smb://domain.com/share", stopFails: true},
+		"Share with force-unmount which stops is not lazily unmounted": {value: "smb://domain.com/share;force-unmount"},
+		"Users are not notified if the lazy unmount fails":             {value: "smb://domain.com/share;force-unmount", stopFails: true, lazyFails: true, wantLazyUnmount: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rootDir := t.TempDir()
+			procDir := filepath.Join(rootDir, "proc")
+			// Processes of user 1000 and 1001 use the share, while the one of user 1002 does not.
+			for pid, p := range map[string]struct{ uid, link, path string }{
+				"100": {uid: "1000", link: "cwd", path: target},
+				"101": {uid: "1000", link: "fd/3", path: target + "/document.odt"},
+				"200": {uid: "1001", link: "fd/10", path: target + "/dir/file"},
+				"300": {uid: "1002", link: "cwd", path: target + "-other"},
+			} {
+				require.NoError(t, os.MkdirAll(filepath.Join(procDir, pid, "fd"), 0700), "Setup: can't create process directory")
+				require.NoError(t, os.WriteFile(filepath.Join(procDir, pid, "status"), []byte("Name:\tbash\nUid:\t"+p.uid+"\t"+p.uid+"\t"+p.uid+"\t"+p.uid+"\n"), 0600),
+					"Setup: can't write process status")
+				require.NoError(t, os.Symlink(p.path, filepath.Join(procDir, pid, p.link)), "Setup: can't create process link")
+			}
+
+			var gotLazyUnmount bool
+			var gotNotified []string
+			// StartUnit fails with the zero value of the mock, which is only logged.
+			m, err := mount.New(filepath.Join(rootDir, "run", "adsys"), filepath.Join(rootDir, "etc", "systemd", "system"), &mockSystemdCaller{},
+				mount.WithProcDir(procDir),
+				mount.WithLazyUnmount(func(_ context.Context, p string) error {
+					require.Equal(t, target, p, "Lazy unmount should be called on the share mount point")
+					gotLazyUnmount = true
+					if tc.lazyFails {
+						return errors.New("lazy unmount error")
+					}
+					return nil
+				}),
+				mount.WithUserNotifier(func(_ context.Context, uid, msg string) error {
+					require.Contains(t, msg, target, "Notification should mention the share mount point")
+					gotNotified = append(gotNotified, uid)
+					if tc.notifyErr {
+						return errors.New("notification error")
+					}
+					return nil
+				}))
+			require.NoError(t, err, "Setup: Failed to create manager for the tests.")
+
+			err = m.ApplyPolicy(context.Background(), "ubuntu", true, []entry.Entry{{Key: "system-mounts", Value: tc.value}})
+			require.NoError(t, err, "Setup: ApplyPolicy should not have returned an error but did")
+
+			if tc.stopFails {
+				m.SetSystemdCaller(&mockSystemdCaller{failOn: stop})
+			}
+			// Removing the share from the policy stops its mount unit.
+			err = m.ApplyPolicy(context.Background(), "ubuntu", true, nil)
+			require.NoError(t, err, "ApplyPolicy should not have returned an error but did")
+
+			require.Equal(t, tc.wantLazyUnmount, gotLazyUnmount, "Lazy unmount should only be done for busy shares with force-unmount")
+			require.Equal(t, tc.wantNotified, gotNotified, "Users using the share should be notified once")
 		})
 	}
 }
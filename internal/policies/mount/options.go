@@ -0,0 +1,130 @@
+package mount
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/decorate"
+)
+
+// optionsSeparator separates a system mount location from its additional mount options:
+// <protocol>://<hostname-or-ip>/<shared-path>;<option1>,<option2>=<value>.
+const optionsSeparator = ";"
+
+// forceUnmountOption is not a mount option but makes adsys lazily unmount a share which is busy when it is
+// removed from the policy.
+const forceUnmountOption = "force-unmount"
+
+var (
+	numericValue = regexp.MustCompile(`^[0-9]+$`).MatchString
+	modeValue    = regexp.MustCompile(`^0?[0-7]{3,4}$`).MatchString
+	versionValue = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`).MatchString
+	// nameValue matches user, group and charset names. It notably excludes % which is expanded by systemd.
+	nameValue = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]*$`).MatchString
+	// interfaceValue matches network interface names.
+	interfaceValue = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,14}$`).MatchString
+)
+
+// sourceValue matches the source of the cifs mounts: a local IP address or the name of a network interface.
+func sourceValue(v string) bool {
+	return net.ParseIP(v) != nil || interfaceValue(v)
+}
+
+// oneOf returns a validator accepting any of the given values.
+func oneOf(values ...string) func(string) bool {
+	return func(v string) bool {
+		return slices.Contains(values, v)
+	}
+}
+
+// Options granting privileges (suid, dev…), embedding credentials or overriding the ones set by adsys
+// (sec) are not part of the allowed options below. srcaddr accepts a network interface name, resolved by adsys.
+var (
+	// allowedMountFlags are, per mount type, the options without value which can be set from the policy.
+	allowedMountFlags = map[string][]string{
+		"cifs": {"ro", "rw", "nosuid", "nodev", "noexec", "nofail", "forceuid", "forcegid", "hard", "soft",
+			"noperm", "nobrl", "nounix", "serverino", "noserverino", "mfsymlinks", "nocase", "seal"},
+		"nfs": {"ro", "rw", "nosuid", "nodev", "noexec", "nofail", "hard", "soft", "ac", "noac", "nolock"},
+	}
+
+	// allowedMountOptions are, per mount type, the options with a value which can be set from the policy,
+	// with the validator of their value.
+	allowedMountOptions = map[string]map[string]func(string) bool{
+		"cifs": {
+			"uid":           nameValue,
+			"gid":           nameValue,
+			"file_mode":     modeValue,
+			"dir_mode":      modeValue,
+			"vers":          versionValue,
+			"cache":         oneOf("strict", "none", "loose", "singleclient"),
+			"actimeo":       numericValue,
+			"rsize":         numericValue,
+			"wsize":         numericValue,
+			"echo_interval": numericValue,
+			"iocharset":     nameValue,
+			"srcaddr":       sourceValue,
+		},
+		"nfs": {
+			"vers":        versionValue,
+			"nfsvers":     versionValue,
+			"proto":       oneOf("tcp", "udp", "rdma", "tcp6", "udp6", "rdma6"),
+			"port":        numericValue,
+			"timeo":       numericValue,
+			"retrans":     numericValue,
+			"rsize":       numericValue,
+			"wsize":       numericValue,
+			"actimeo":     numericValue,
+			"acregmin":    numericValue,
+			"acregmax":    numericValue,
+			"acdirmin":    numericValue,
+			"acdirmax":    numericValue,
+			"nconnect":    numericValue,
+			"lookupcache": oneOf("all", "none", "positive", "pos"),
+		},
+	}
+)
+
+// parseMountOptions validates the comma separated mount options set in the policy for a mount of type
+// mountType and returns them.
+func parseMountOptions(mountType, options string) (opts []string, err error) {
+	defer decorate.OnError(&err, gotext.Get("invalid mount options %q", options))
+
+	flags, ok := allowedMountFlags[mountType]
+	if !ok {
+		return nil, errors.New(gotext.Get("mount options are not supported for %s mounts", mountType))
+	}
+	valueOptions := allowedMountOptions[mountType]
+
+	seen := make(map[string]struct{})
+	for _, o := range strings.Split(options, ",") {
+		o = strings.TrimSpace(o)
+		name, value, hasValue := strings.Cut(o, "=")
+		if name == "" {
+			return nil, errors.New(gotext.Get("empty mount option"))
+		}
+		if _, ok := seen[name]; ok {
+			return nil, errors.New(gotext.Get("mount option %q is set multiple times", name))
+		}
+		seen[name] = struct{}{}
+
+		if slices.Contains(flags, name) || name == forceUnmountOption {
+			if hasValue {
+				return nil, errors.New(gotext.Get("mount option %q doesn't accept any value", name))
+			}
+		} else if isValid, ok := valueOptions[name]; ok {
+			if !isValid(value) {
+				return nil, errors.New(gotext.Get("invalid value %q for mount option %q", value, name))
+			}
+		} else {
+			return nil, errors.New(gotext.Get("mount option %q is not allowed for %s mounts", name, mountType))
+		}
+
+		opts = append(opts, o)
+	}
+
+	return opts, nil
+}
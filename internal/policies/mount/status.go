@@ -0,0 +1,179 @@
+package mount
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/go-systemd/v22/unit"
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// ShareState is the state of a system share set by the mount policy.
+type ShareState string
+
+const (
+	// ShareMounted is the state of a share mounted on its target.
+	ShareMounted ShareState = "mounted"
+	// ShareUnmounted is the state of a share which is not mounted, without any known error.
+	ShareUnmounted ShareState = "unmounted"
+	// ShareFailed is the state of a share which is not mounted as its unit failed to start.
+	ShareFailed ShareState = "failed"
+)
+
+// ShareStatus is the status of a system share set by the mount policy.
+type ShareStatus struct {
+	Share  string
+	Target string
+	Type   string
+	State  ShareState
+	// Error is the last error starting the share mount unit, if it failed.
+	Error string
+}
+
+// procMount is a mounted filesystem, as listed in /proc/mounts.
+type procMount struct {
+	target string
+	fstype string
+}
+
+// SystemMountsStatus compares the system shares of the current policy, that is the mount units generated by
+// adsys, to the mounted filesystems and returns their status, sorted by target.
+// Shares are matched to the mounted filesystems on their target path and type.
+// User shares are not listed as they are mounted by gvfs in the user session.
+func (m *Manager) SystemMountsStatus(ctx context.Context) (status []ShareStatus, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't get system mounts status"))
+
+	mounts, err := readProcMounts(m.procMounts)
+	if err != nil {
+		return nil, err
+	}
+
+	m.startErrorsMu.Lock()
+	defer m.startErrorsMu.Unlock()
+
+	for name := range m.currentSystemMountUnits() {
+		s, err := parseMountUnit(filepath.Join(m.systemUnitDir, name))
+		if err != nil {
+			log.Warning(ctx, gotext.Get("Ignoring mount unit %q: %v", name, err))
+			continue
+		}
+
+		s.State = ShareUnmounted
+		for _, pm := range mounts {
+			if pm.target == s.Target && sameMountType(s.Type, pm.fstype) {
+				s.State = ShareMounted
+				break
+			}
+		}
+		if startErr, ok := m.startErrors[name]; ok && s.State != ShareMounted {
+			s.State = ShareFailed
+			s.Error = startErr.Error()
+		}
+
+		status = append(status, s)
+	}
+
+	sort.Slice(status, func(i, j int) bool { return status[i].Target < status[j].Target })
+	return status, nil
+}
+
+// setStartError records the error of the last start of a mount unit, or clears it if err is nil.
+func (m *Manager) setStartError(name string, err error) {
+	m.startErrorsMu.Lock()
+	defer m.startErrorsMu.Unlock()
+
+	if err == nil {
+		delete(m.startErrors, name)
+		return
+	}
+	m.startErrors[name] = err
+}
+
+// parseMountUnit returns the share, target and type of a mount unit generated by adsys.
+func parseMountUnit(path string) (s ShareStatus, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return s, err
+	}
+	defer f.Close()
+
+	opts, err := unit.DeserializeOptions(f)
+	if err != nil {
+		return s, err
+	}
+	for _, o := range opts {
+		if o.Section != "Mount" {
+			continue
+		}
+		switch o.Name {
+		case "What":
+			s.Share = o.Value
+		case "Where":
+			s.Target = o.Value
+		case "Type":
+			s.Type = o.Value
+		}
+	}
+
+	if s.Target == "" {
+		return s, errors.New(gotext.Get("no mount target"))
+	}
+	return s, nil
+}
+
+// readProcMounts returns the filesystems listed in the mounts file at path, using the /proc/mounts format.
+func readProcMounts(path string) (mounts []procMount, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// source target fstype options dump pass
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		mounts = append(mounts, procMount{target: unescapeMountField(fields[1]), fstype: fields[2]})
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountField decodes the octal escapes, like \040 for a space, of a /proc/mounts field.
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+4 <= len(field) {
+			if c, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
+
+// sameMountType returns true if a filesystem mounted with fstype matches a mount unit of type unitType.
+// The kernel reports NFSv4 mounts as nfs4 and fuse mounts with their subtype.
+func sameMountType(unitType, fstype string) bool {
+	if unitType == fstype || strings.HasPrefix(fstype, unitType+".") {
+		return true
+	}
+	return unitType == "nfs" && fstype == "nfs4"
+}
@@ -0,0 +1,168 @@
+package mount
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/go-systemd/v22/unit"
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// forceUnmount lazily unmounts target, which could not be stopped as it is busy, and notifies the users having
+// processes using it. Errors are only logged, as when the mount unit can't be stopped without forcing it.
+func (m *Manager) forceUnmount(ctx context.Context, target string) {
+	// Processes are looked up before unmounting, as their paths are not under target anymore once it is detached.
+	uids := usersOfMount(m.procDir, target)
+
+	if err := m.lazyUnmount(ctx, target); err != nil {
+		log.Warning(ctx, gotext.Get("Failed to lazily unmount %q: %v", target, err))
+		return
+	}
+	log.Infof(ctx, "Lazily unmounted busy share %q", target)
+
+	msg := gotext.Get("The network share %s was removed by your administrator and is no longer available. Save any open files from it elsewhere.", target)
+	for _, uid := range uids {
+		if err := m.notifyUser(ctx, uid, msg); err != nil {
+			log.Warning(ctx, gotext.Get("Failed to notify user %s of the unmount of %q: %v", uid, target, err))
+		}
+	}
+}
+
+// forceUnmountTarget returns the mount point of the mount unit at path and whether it was generated for a share
+// with the force-unmount option.
+func forceUnmountTarget(path string) (target string, force bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	opts, err := unit.DeserializeOptions(f)
+	if err != nil {
+		return "", false
+	}
+	for _, o := range opts {
+		if o.Section != "Mount" {
+			continue
+		}
+		switch o.Name {
+		case "Where":
+			target = o.Value
+		case forceUnmountKey:
+			force = o.Value == "true"
+		}
+	}
+
+	return target, force && target != ""
+}
+
+// usersOfMount returns the sorted uids of the processes, listed in procDir, whose working directory, root
+// directory or any open file is under target.
+func usersOfMount(procDir, target string) (uids []string) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil
+	}
+
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		pidDir := filepath.Join(procDir, e.Name())
+		if !processUsesMount(pidDir, target) {
+			continue
+		}
+		uid, err := processUID(pidDir)
+		if err != nil || slices.Contains(uids, uid) {
+			continue
+		}
+		uids = append(uids, uid)
+	}
+
+	slices.Sort(uids)
+	return uids
+}
+
+// processUsesMount returns true if the process at pidDir has its working directory, root directory or any open
+// file under target.
+func processUsesMount(pidDir, target string) bool {
+	links, _ := filepath.Glob(filepath.Join(pidDir, "fd", "*"))
+	links = append(links, filepath.Join(pidDir, "cwd"), filepath.Join(pidDir, "root"))
+
+	for _, l := range links {
+		p, err := os.Readlink(l)
+		if err != nil {
+			continue
+		}
+		if p == target || strings.HasPrefix(p, target+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// processUID returns the real uid of the process at pidDir.
+func processUID(pidDir string) (uid string, err error) {
+	f, err := os.Open(filepath.Join(pidDir, "status"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Uid: real effective saved filesystem
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "Uid:" {
+			return fields[1], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New(gotext.Get("no uid found for process %s", filepath.Base(pidDir)))
+}
+
+// lazyUnmount detaches target from the file system, even if it is busy. It is cleaned up once not used anymore.
+func lazyUnmount(ctx context.Context, target string) error {
+	// #nosec G204 - target is a mount point generated by adsys.
+	if out, err := exec.CommandContext(ctx, "umount", "--lazy", target).CombinedOutput(); err != nil {
+		return errors.New(gotext.Get("%v: %s", err, out))
+	}
+	return nil
+}
+
+// notifyUser sends a desktop notification with msg to the graphical session of the user with the given uid.
+// Nothing is sent if the user has no running session.
+func notifyUser(ctx context.Context, uid, msg string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't send notification"))
+
+	bus := filepath.Join("/run/user", uid, "bus")
+	if _, err := os.Stat(bus); err != nil {
+		log.Debugf(ctx, "No session bus for user %s, not notifying it", uid)
+		return nil
+	}
+	u, err := user.LookupId(uid)
+	if err != nil {
+		return err
+	}
+
+	// #nosec G204 - the user name is resolved from the uid and the message is generated by adsys.
+	cmd := exec.CommandContext(ctx, "runuser", "-u", u.Username, "--",
+		"notify-send", "--app-name=ADSys", "--urgency=critical", gotext.Get("Network share removed"), msg)
+	cmd.Env = append(os.Environ(), "DBUS_SESSION_BUS_ADDRESS=unix:path="+bus)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.New(gotext.Get("%v: %s", err, out))
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+// Package netplan provides a manager to apply a Netplan network configuration, like static addresses, from policy.
+//
+// The policy is only supported on computers. The netplan entry is a Netplan YAML document, with a top-level network
+// mapping, written to a configuration file managed by adsys in the Netplan directory. As it is ordered last, its
+// settings are merged over the ones of the other configuration files by Netplan.
+//
+// The configuration is only written when its content changed. It is then validated with netplan generate before
+// being applied with netplan apply. If the validation fails, the previous managed file is restored, or removed if
+// there was none, so that an invalid configuration is never applied. If there is no configuration to apply, the
+// managed file is removed, and the remaining configuration applied if it existed.
+package netplan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+	"gopkg.in/yaml.v3"
+)
+
+// cfgFileName is the name of the configuration file, in the Netplan directory, managed by adsys.
+// It is ordered late so that its settings are merged over the ones of the installer and cloud-init files.
+const cfgFileName = "99-adsys.yaml"
+
+// Manager applies the Netplan configuration of the netplan policy.
+type Manager struct {
+	netplanDir  string
+	generateCmd []string
+	applyCmd    []string
+}
+
+type options struct {
+	generateCmd []string
+	applyCmd    []string
+}
+
+// Option reprents an optional function to change the netplan manager.
+type Option func(*options)
+
+// WithGenerateCmd overrides the default command validating the Netplan configuration.
+func WithGenerateCmd(cmd []string) Option {
+	return func(o *options) {
+		o.generateCmd = cmd
+	}
+}
+
+// WithApplyCmd overrides the default command applying the Netplan configuration.
+func WithApplyCmd(cmd []string) Option {
+	return func(o *options) {
+		o.applyCmd = cmd
+	}
+}
+
+// New creates a manager with a specific Netplan directory.
+func New(netplanDir string, opts ...Option) *Manager {
+	// defaults
+	args := options{
+		generateCmd: []string{"netplan", "generate"},
+		applyCmd:    []string{"netplan", "apply"},
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Manager{
+		netplanDir:  netplanDir,
+		generateCmd: args.generateCmd,
+		applyCmd:    args.applyCmd,
+	}
+}
+
+// ApplyPolicy writes the Netplan configuration of the policy to the managed file, and validates and applies it if
+// it changed.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply netplan policy to %s", objectName))
+
+	// Netplan policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	cfgPath := filepath.Join(m.netplanDir, cfgFileName)
+
+	var content string
+	for _, e := range entries {
+		if e.Key != "netplan" {
+			ignored.RecordUnsupportedKey(ctx, "netplan", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled {
+			continue
+		}
+		if content, err = generateConfig(e.Value); err != nil {
+			return err
+		}
+	}
+
+	oldContent, err := os.ReadFile(cfgPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	hadConfig := err == nil
+
+	if content == "" {
+		if !hadConfig {
+			return nil
+		}
+		log.Debug(ctx, gotext.Get("Removing Netplan configuration %s", cfgPath))
+		if removed, err := tamper.Remove(ctx, "netplan", cfgPath); err != nil || !removed {
+			return err
+		}
+		return m.run(ctx, m.applyCmd)
+	}
+
+	if hadConfig && string(oldContent) == content {
+		log.Debug(ctx, gotext.Get("Netplan configuration %s is up to date", cfgPath))
+		return nil
+	}
+
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(m.netplanDir, 0755); err != nil {
+		return err
+	}
+
+	log.Debugf(ctx, "Applying netplan policy to %s", objectName)
+	// Netplan warns about configuration files readable by others, as they can contain secrets like Wi-Fi passwords.
+	if written, err := tamper.Write(ctx, "netplan", cfgPath, []byte(content), 0600); err != nil || !written {
+		return err
+	}
+
+	if err := m.run(ctx, m.generateCmd); err != nil {
+		if rollbackErr := rollback(ctx, cfgPath, oldContent, hadConfig); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return errors.New(gotext.Get("invalid Netplan configuration, restored the previous one: %v", err))
+	}
+
+	return m.run(ctx, m.applyCmd)
+}
+
+// run runs the netplan command cmdArgs.
+func (m *Manager) run(ctx context.Context, cmdArgs []string) error {
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...)
+	trace.Command(ctx, "netplan", cmd.Args)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return errors.New(gotext.Get("failed to run %q: %v\n%s", strings.Join(cmdArgs, " "), err, string(out)))
+	}
+	return nil
+}
+
+// rollback restores the previous content of the managed file, or removes it if there was none.
+func rollback(ctx context.Context, cfgPath string, oldContent []byte, hadConfig bool) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't restore the previous Netplan configuration"))
+
+	if !hadConfig {
+		_, err := tamper.Remove(ctx, "netplan", cfgPath)
+		return err
+	}
+	_, err = tamper.Write(ctx, "netplan", cfgPath, oldContent, 0600)
+	return err
+}
+
+// generateConfig validates the Netplan YAML document of the netplan entry value and returns the content of the
+// managed file, or an empty content if the value is empty.
+// The document is normalized, keeping the order of the keys and the comments.
+func generateConfig(value string) (content string, err error) {
+	defer decorate.OnError(&err, gotext.Get("invalid Netplan configuration"))
+
+	if strings.TrimSpace(value) == "" {
+		return "", nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); err != nil {
+		return "", err
+	}
+	if len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return "", errors.New(gotext.Get("the configuration must be a YAML mapping"))
+	}
+	root := doc.Content[0]
+	var hasNetwork bool
+	for i := 0; i < len(root.Content); i += 2 {
+		key := root.Content[i].Value
+		if key != "network" {
+			return "", errors.New(gotext.Get("unexpected top-level key %q: only network is allowed", key))
+		}
+		if root.Content[i+1].Kind != yaml.MappingNode {
+			return "", errors.New(gotext.Get("network must be a YAML mapping"))
+		}
+		hasNetwork = true
+	}
+	if !hasNetwork {
+		return "", errors.New(gotext.Get("missing top-level network key"))
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+
+`)
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
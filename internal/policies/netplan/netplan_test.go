@@ -0,0 +1,156 @@
+package netplan_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/netplan"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+const defaultConfig = `network:
+  version: 2
+  ethernets:
+    eth0:
+      dhcp4: false
+      addresses: [192.0.2.10/24]
+      routes:
+        - to: default
+          via: 192.0.2.1
+      nameservers:
+        addresses: [192.0.2.53]
+`
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{{Key: "netplan", Value: defaultConfig}}
+
+	tests := map[string]struct {
+		entries []entry.Entry
+		user    bool
+
+		cfgFileAlreadyExists bool
+		readOnlyNetplanDir   bool
+		generateCmdError     bool
+		applyCmdError        bool
+
+		wantCalls []string
+		wantErr   bool
+	}{
+		"Computer, configuration is written, validated and applied": {wantCalls: []string{"generate", "apply"}},
+		"Computer, unchanged configuration is not applied again":    {cfgFileAlreadyExists: true},
+		"Computer, changed configuration is written and applied":    {entries: []entry.Entry{{Key: "netplan", Value: "network:\n  version: 2\n  ethernets:\n    eth0:\n      dhcp4: true\n"}}, cfgFileAlreadyExists: true, wantCalls: []string{"generate", "apply"}},
+		"Computer, configuration is normalized with its comments":   {entries: []entry.Entry{{Key: "netplan", Value: "# Office network\nnetwork:\n    version: 2\n    ethernets:\n        eth0: {dhcp4: true} # DHCP\n"}}, wantCalls: []string{"generate", "apply"}},
+		"Computer, no entries removes existing configuration":       {entries: []entry.Entry{}, cfgFileAlreadyExists: true, wantCalls: []string{"apply"}},
+		"Computer, disabled entry removes existing configuration":   {entries: []entry.Entry{{Key: "netplan", Disabled: true}}, cfgFileAlreadyExists: true, wantCalls: []string{"apply"}},
+		"Computer, only spaces removes existing configuration":      {entries: []entry.Entry{{Key: "netplan", Value: " \n "}}, cfgFileAlreadyExists: true, wantCalls: []string{"apply"}},
+		"Computer, no entries and no existing configuration":        {entries: []entry.Entry{}},
+		"Computer, no entries and read-only netplan dir":            {entries: []entry.Entry{}, readOnlyNetplanDir: true},
+		"Computer, unexpected entry key is ignored":                 {entries: []entry.Entry{{Key: "netplan-foo", Value: defaultConfig}}},
+		"User, policy is ignored":                                   {user: true},
+		"User, existing configuration is kept":                      {entries: []entry.Entry{}, cfgFileAlreadyExists: true, user: true},
+
+		"Error on invalid YAML":                                    {entries: []entry.Entry{{Key: "netplan", Value: "network: [version: 2"}}, cfgFileAlreadyExists: true, wantErr: true},
+		"Error on configuration not being a mapping":               {entries: []entry.Entry{{Key: "netplan", Value: "- network"}}, wantErr: true},
+		"Error on missing network key":                             {entries: []entry.Entry{{Key: "netplan", Value: "# only a comment\n{}"}}, wantErr: true},
+		"Error on unexpected top-level key":                        {entries: []entry.Entry{{Key: "netplan", Value: defaultConfig + "wifis: {}\n"}}, wantErr: true},
+		"Error on network not being a mapping":                     {entries: []entry.Entry{{Key: "netplan", Value: "network: 2"}}, wantErr: true},
+		"Error on read-only netplan dir":                           {readOnlyNetplanDir: true, wantErr: true},
+		"Error on validation failure restores previous file":       {entries: []entry.Entry{{Key: "netplan", Value: "network:\n  version: 3\n"}}, cfgFileAlreadyExists: true, generateCmdError: true, wantCalls: []string{"generate"}, wantErr: true},
+		"Error on validation failure removes new file":             {generateCmdError: true, wantCalls: []string{"generate"}, wantErr: true},
+		"Error on apply failure keeps the validated configuration": {applyCmdError: true, wantCalls: []string{"generate", "apply"}, wantErr: true},
+		"Error on apply failure on removed configuration":          {entries: []entry.Entry{}, cfgFileAlreadyExists: true, applyCmdError: true, wantCalls: []string{"apply"}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			netplanDir := filepath.Join(t.TempDir(), "netplan")
+			if tc.cfgFileAlreadyExists {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "netplan_dir"), netplanDir)
+			}
+			if tc.readOnlyNetplanDir {
+				require.NoError(t, os.MkdirAll(netplanDir, 0750), "Setup: can't create netplan dir")
+				testutils.MakeReadOnly(t, netplanDir)
+			}
+
+			outputFile := filepath.Join(t.TempDir(), "netplan-output")
+			m := netplan.New(netplanDir,
+				netplan.WithGenerateCmd(mockNetplanCmd(t, outputFile, "generate", tc.generateCmdError)),
+				netplan.WithApplyCmd(mockNetplanCmd(t, outputFile, "apply", tc.applyCmdError)))
+
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				// We don't return here as we want to check that the netplan dir is in the expected state even in error cases
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.readOnlyNetplanDir {
+				// nolint:gosec //false positive, this is a directory
+				require.NoError(t, os.Chmod(netplanDir, 0750), "Setup: can't restore netplan dir permissions")
+			}
+			testutils.CompareTreesWithFiltering(t, netplanDir, filepath.Join(testutils.GoldenPath(t), "etc", "netplan"), testutils.UpdateEnabled())
+
+			var calls []string
+			got, err := os.ReadFile(outputFile)
+			if err == nil {
+				calls = strings.Fields(string(got))
+			}
+			require.Equal(t, tc.wantCalls, calls, "ApplyPolicy should have run the expected netplan commands")
+		})
+	}
+}
+
+func mockNetplanCmd(t *testing.T, outputFile, name string, fail bool) []string {
+	t.Helper()
+
+	cmd := []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockNetplan", "--", outputFile, name}
+	if fail {
+		cmd = append(cmd, "-Exit1")
+	}
+	return cmd
+}
+
+func TestMockNetplan(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := os.Args
+	for len(args) > 0 {
+		if args[0] != "--" {
+			args = args[1:]
+			continue
+		}
+		args = args[1:]
+		break
+	}
+	// First arg after -- is the output file to write to, then the netplan command name
+	outputFile, name, args := args[0], args[1], args[2:]
+
+	f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	require.NoError(t, err, "Setup: Can't open netplan output file")
+	defer f.Close()
+	_, err = f.WriteString(name + "\n")
+	require.NoError(t, err, "Setup: Can't write netplan output file")
+
+	if len(args) > 0 && args[0] == "-Exit1" {
+		f.Close()
+		// nolint:gocritic // we want to exit with an error without running the deferred calls
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,320 @@
+// Package network provides a manager to apply NetworkManager connection profiles.
+//
+// The policy is only supported on computers. The network-connections entry
+// contains one or more connection profiles in NetworkManager keyfile format,
+// each of them starting with its [connection] section. Every profile must have
+// an id and a type. A stable uuid, derived from the id, is added to the
+// profiles which don't define one.
+//
+// Each profile is written to adsys-<id>.nmconnection in the NetworkManager
+// system connections directory. As profiles may contain secrets, like Wi-Fi or
+// VPN passwords, the files are only readable by root.
+//
+// On each refresh, the managed profiles are reconciled with the policy:
+// profiles of the policy are written when their content changed, and managed
+// profiles which are not in the policy anymore are removed. Profiles not
+// managed by adsys are never modified. NetworkManager connections are only
+// reloaded when a managed profile changed.
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// profilePrefix and profileSuffix delimit the name of the connection profiles managed by adsys.
+	profilePrefix = "adsys-"
+	profileSuffix = ".nmconnection"
+
+	// connectionSection is the section starting each connection profile.
+	connectionSection = "[connection]"
+)
+
+// uuidNamespace is the namespace of the uuids generated for the profiles without one.
+var uuidNamespace = uuid.MustParse("4f1c3a5e-8b4d-4d8a-9a57-7c1f2a0d6e21")
+
+// Manager applies the connection profiles of the network policy.
+type Manager struct {
+	connectionsDir string
+	nmcliCmd       []string
+}
+
+type options struct {
+	nmcliCmd []string
+}
+
+// Option reprents an optional function to change the network manager.
+type Option func(*options)
+
+// WithNmcliCmd overrides the default command reloading the NetworkManager connections.
+func WithNmcliCmd(cmd []string) Option {
+	return func(o *options) {
+		o.nmcliCmd = cmd
+	}
+}
+
+// New creates a manager with a specific NetworkManager system connections directory.
+func New(connectionsDir string, opts ...Option) *Manager {
+	// defaults
+	args := options{
+		nmcliCmd: []string{"nmcli", "connection", "reload"},
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Manager{
+		connectionsDir: connectionsDir,
+		nmcliCmd:       args.nmcliCmd,
+	}
+}
+
+// ApplyPolicy writes the connection profiles of the policy to the system connections directory, removes the
+// managed profiles which are not in the policy anymore and reloads NetworkManager connections if any changed.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply network policy to %s", objectName))
+
+	// Network policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	profiles := make(map[string]string)
+	for _, e := range entries {
+		if e.Key != "network-connections" {
+			ignored.RecordUnsupportedKey(ctx, "network", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled {
+			continue
+		}
+		if profiles, err = parseProfiles(e.Value); err != nil {
+			return err
+		}
+	}
+
+	existing, err := filepath.Glob(filepath.Join(m.connectionsDir, profilePrefix+"*"+profileSuffix))
+	if err != nil {
+		return err
+	}
+
+	if len(profiles) == 0 && len(existing) == 0 {
+		return nil
+	}
+
+	log.Debugf(ctx, "Applying network policy to %s", objectName)
+
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(m.connectionsDir, 0755); err != nil {
+		return err
+	}
+
+	var changed bool
+	// Write profiles in a stable order, to get reproducible errors
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		written, err := writeProfile(filepath.Join(m.connectionsDir, name), profiles[name])
+		if err != nil {
+			return err
+		}
+		if written {
+			log.Debug(ctx, gotext.Get("Connection profile %s updated", name))
+			changed = true
+		}
+	}
+
+	for _, p := range existing {
+		if _, ok := profiles[filepath.Base(p)]; ok {
+			continue
+		}
+		log.Debug(ctx, gotext.Get("Removing connection profile %s", p))
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	if !changed {
+		log.Debug(ctx, gotext.Get("NetworkManager connection profiles are up to date"))
+		return nil
+	}
+
+	return m.reload(ctx)
+}
+
+// writeProfile writes the content of the connection profile to path, only readable by root, if it changed.
+// It returns true if the profile was written.
+func writeProfile(path, content string) (written bool, err error) {
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() == 0600 {
+		oldContent, err := os.ReadFile(path)
+		if err == nil && string(oldContent) == content {
+			return false, nil
+		}
+	}
+
+	// Secrets are stored in the profile: make sure it's never readable by others, even temporarily.
+	if err := os.Remove(path + ".new"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	if err := os.WriteFile(path+".new", []byte(content), 0600); err != nil {
+		return false, err
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// reload makes NetworkManager reload its connection profiles from disk.
+func (m *Manager) reload(ctx context.Context) error {
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, m.nmcliCmd[0], m.nmcliCmd[1:]...)
+	trace.Command(ctx, "network", cmd.Args)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return errors.New(gotext.Get("failed to reload NetworkManager connections: %v\n%s", err, string(out)))
+	}
+	return nil
+}
+
+// parseProfiles splits the connection profiles of the entry value, each starting with its [connection]
+// section, and returns the content of their keyfile indexed by file name.
+// Empty lines and comments outside of the profiles are ignored.
+func parseProfiles(value string) (profiles map[string]string, err error) {
+	profiles = make(map[string]string)
+
+	var current []string
+	flush := func() error {
+		if current == nil {
+			return nil
+		}
+		name, content, err := profileKeyfile(current)
+		if err != nil {
+			return err
+		}
+		if _, exists := profiles[name]; exists {
+			return errors.New(gotext.Get("multiple connection profiles are written to %s", name))
+		}
+		profiles[name] = content
+		return nil
+	}
+
+	for _, l := range strings.Split(value, "\n") {
+		l = strings.TrimSpace(l)
+		if l == connectionSection {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			current = []string{l}
+			continue
+		}
+		if current == nil {
+			if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, ";") {
+				continue
+			}
+			return nil, errors.New(gotext.Get("invalid line %q: connection profiles must start with a %s section", l, connectionSection))
+		}
+		current = append(current, l)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// profileKeyfile validates the lines of a connection profile and returns the name and content of its keyfile.
+// A uuid derived from the profile id is added if the profile doesn't define one.
+func profileKeyfile(lines []string) (name, content string, err error) {
+	var id, connType string
+	var hasUUID bool
+
+	section := ""
+	for _, l := range lines {
+		if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, ";") {
+			continue
+		}
+		if strings.HasPrefix(l, "[") && strings.HasSuffix(l, "]") {
+			section = l
+			continue
+		}
+		key, v, found := strings.Cut(l, "=")
+		key, v = strings.TrimSpace(key), strings.TrimSpace(v)
+		if !found || key == "" {
+			return "", "", errors.New(gotext.Get("invalid connection profile line %q: expecting key=value", l))
+		}
+		if section != connectionSection {
+			continue
+		}
+		switch key {
+		case "id":
+			id = v
+		case "type":
+			connType = v
+		case "uuid":
+			if err := uuid.Validate(v); err != nil {
+				return "", "", errors.New(gotext.Get("invalid uuid %q in connection profile: %v", v, err))
+			}
+			hasUUID = true
+		}
+	}
+
+	if id == "" {
+		return "", "", errors.New(gotext.Get("connection profile without id"))
+	}
+	if connType == "" {
+		return "", "", errors.New(gotext.Get("connection profile %q without type", id))
+	}
+
+	// Drop trailing blank lines, separating the profiles in the policy
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if !hasUUID {
+		lines = slices.Insert(lines, 1, fmt.Sprintf("uuid=%s", uuid.NewSHA1(uuidNamespace, []byte(id))))
+	}
+
+	return profileFileName(id), fmt.Sprintf(`# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+
+%s
+`, strings.Join(lines, "\n")), nil
+}
+
+// profileFileName returns the name of the keyfile of the connection id. Characters which are not safe in a
+// file name are replaced by underscores.
+func profileFileName(id string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, id)
+	return profilePrefix + name + profileSuffix
+}
@@ -0,0 +1,202 @@
+package network_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/network"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+const (
+	wifiProfile = `[connection]
+id=Office Wi-Fi
+type=wifi
+
+[wifi]
+ssid=Office
+
+[wifi-security]
+key-mgmt=wpa-eap
+
+[802-1x]
+eap=peap;
+identity=corp\wifi
+password=s3cr3t
+phase2-auth=mschapv2`
+
+	vpnProfile = `[connection]
+id=Corporate VPN
+uuid=0b5c2a45-6a0d-4d6f-9b6b-3b7a8f1e2d4c
+type=vpn
+autoconnect=false
+
+[vpn]
+service-type=org.freedesktop.NetworkManager.openvpn
+remote=vpn.example.com`
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{{Key: "network-connections", Value: wifiProfile + "\n\n" + vpnProfile}}
+
+	tests := map[string]struct {
+		entries []entry.Entry
+		user    bool
+
+		existingProfiles string
+		wrongPermissions bool
+		readOnlyConnsDir bool
+		nmcliCmdError    bool
+		noConnectionsDir bool
+
+		wantReload bool
+		wantErr    bool
+	}{
+		"Computer, profiles are written and connections reloaded":         {wantReload: true},
+		"Computer, unchanged profiles are not reloaded":                   {existingProfiles: "up_to_date"},
+		"Computer, profiles with wrong permissions are written again":     {existingProfiles: "up_to_date", wrongPermissions: true, wantReload: true},
+		"Computer, changed profiles are written and connections reloaded": {entries: []entry.Entry{{Key: "network-connections", Value: strings.ReplaceAll(wifiProfile, "s3cr3t", "n3w")}}, existingProfiles: "up_to_date", wantReload: true},
+		"Computer, managed profiles not in policy are removed":            {existingProfiles: "stale_and_local", wantReload: true},
+		"Computer, comments and blank lines before profiles are ignored":  {entries: []entry.Entry{{Key: "network-connections", Value: "# Office network\n\n" + wifiProfile + "\n" + vpnProfile + "\n\n"}}, existingProfiles: "up_to_date"},
+		"Computer, unsafe characters in id are replaced in file name":     {entries: []entry.Entry{{Key: "network-connections", Value: "[connection]\nid=../VPN: paris\ntype=vpn"}}, wantReload: true},
+		"Computer, no entries removes managed profiles":                   {entries: []entry.Entry{}, existingProfiles: "stale_and_local", wantReload: true},
+		"Computer, disabled entry removes managed profiles":               {entries: []entry.Entry{{Key: "network-connections", Disabled: true}}, existingProfiles: "stale_and_local", wantReload: true},
+		"Computer, no entries and no managed profiles":                    {entries: []entry.Entry{}, noConnectionsDir: true},
+		"Computer, no entries and read-only connections directory":        {entries: []entry.Entry{}, readOnlyConnsDir: true},
+		"Computer, unexpected entry key is ignored":                       {entries: []entry.Entry{{Key: "network-foo", Value: wifiProfile}}, noConnectionsDir: true},
+		"User, policy is ignored":                                         {user: true, noConnectionsDir: true},
+		"User, existing profiles are kept":                                {entries: []entry.Entry{}, existingProfiles: "stale_and_local", user: true},
+
+		"Error on profile without id":                        {entries: []entry.Entry{{Key: "network-connections", Value: "[connection]\ntype=wifi"}}, existingProfiles: "up_to_date", wantErr: true},
+		"Error on profile without type":                      {entries: []entry.Entry{{Key: "network-connections", Value: "[connection]\nid=Office"}}, existingProfiles: "up_to_date", wantErr: true},
+		"Error on profile with invalid uuid":                 {entries: []entry.Entry{{Key: "network-connections", Value: "[connection]\nid=Office\nuuid=nope\ntype=wifi"}}, wantErr: true},
+		"Error on profile not starting with connection":      {entries: []entry.Entry{{Key: "network-connections", Value: "[wifi]\nssid=Office\n" + wifiProfile}}, wantErr: true},
+		"Error on profile line not being an assignment":      {entries: []entry.Entry{{Key: "network-connections", Value: wifiProfile + "\nautoconnect"}}, wantErr: true},
+		"Error on profiles with the same id":                 {entries: []entry.Entry{{Key: "network-connections", Value: wifiProfile + "\n" + wifiProfile}}, wantErr: true},
+		"Error on profiles written to the same file":         {entries: []entry.Entry{{Key: "network-connections", Value: "[connection]\nid=VPN paris\ntype=vpn\n[connection]\nid=VPN_paris\ntype=vpn"}}, wantErr: true},
+		"Error on read-only connections directory":           {readOnlyConnsDir: true, wantErr: true},
+		"Error on nmcli failing":                             {nmcliCmdError: true, wantReload: true, wantErr: true},
+		"Error on nmcli failing on removed managed profiles": {entries: []entry.Entry{}, existingProfiles: "stale_and_local", nmcliCmdError: true, wantReload: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			connsDir := filepath.Join(t.TempDir(), "system-connections")
+			if tc.existingProfiles != "" {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), tc.existingProfiles), connsDir)
+				// Git doesn't keep the permissions of the profiles
+				files, err := filepath.Glob(filepath.Join(connsDir, "*"))
+				require.NoError(t, err, "Setup: can't list existing profiles")
+				perm := os.FileMode(0600)
+				if tc.wrongPermissions {
+					perm = 0644
+				}
+				for _, f := range files {
+					require.NoError(t, os.Chmod(f, perm), "Setup: can't set permissions of existing profile")
+				}
+			} else if !tc.noConnectionsDir {
+				require.NoError(t, os.MkdirAll(connsDir, 0750), "Setup: can't create connections dir")
+			}
+			if tc.readOnlyConnsDir {
+				testutils.MakeReadOnly(t, connsDir)
+			}
+
+			nmcliOutputFile := filepath.Join(t.TempDir(), "nmcli-output")
+			nmcliCmd := mockNmcliCmd(t, nmcliOutputFile)
+			if tc.nmcliCmdError {
+				nmcliCmd = append(nmcliCmd, "-Exit1")
+			}
+
+			m := network.New(connsDir, network.WithNmcliCmd(nmcliCmd))
+
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				// We don't return here as we want to check that the connections dir is in the expected state even in error cases
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.readOnlyConnsDir {
+				// nolint:gosec //false positive, this is a directory
+				require.NoError(t, os.Chmod(connsDir, 0750), "Setup: can't restore connections dir permissions")
+			}
+			testutils.CompareTreesWithFiltering(t, connsDir, filepath.Join(testutils.GoldenPath(t), "etc", "NetworkManager", "system-connections"), testutils.UpdateEnabled())
+
+			// Managed profiles contain secrets and must only be readable by root
+			if !tc.user {
+				profiles, err := filepath.Glob(filepath.Join(connsDir, "adsys-*.nmconnection"))
+				require.NoError(t, err, "Teardown: can't list managed profiles")
+				for _, p := range profiles {
+					info, err := os.Stat(p)
+					require.NoError(t, err, "Teardown: can't stat managed profile")
+					require.Equal(t, os.FileMode(0600), info.Mode().Perm(), "Managed profile %s should only be readable by its owner", p)
+				}
+			}
+
+			got, err := os.ReadFile(nmcliOutputFile)
+			if !tc.wantReload {
+				require.Error(t, err, "nmcli should not have been called")
+				return
+			}
+			require.NoError(t, err, "nmcli should have been called")
+			require.Equal(t, "connection reload\n", string(got), "nmcli should have been called once to reload the connections")
+		})
+	}
+}
+
+func mockNmcliCmd(t *testing.T, outputFile string) []string {
+	t.Helper()
+
+	return []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockNmcli", "--", outputFile, "connection", "reload"}
+}
+
+func TestMockNmcli(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := os.Args
+	for len(args) > 0 {
+		if args[0] != "--" {
+			args = args[1:]
+			continue
+		}
+		args = args[1:]
+		break
+	}
+	// First arg after -- is the output file to write to
+	outputFile, args := args[0], args[1:]
+
+	var wantExit bool
+	if args[len(args)-1] == "-Exit1" {
+		wantExit = true
+		args = args[:len(args)-1]
+	}
+
+	f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	require.NoError(t, err, "Setup: Can't open nmcli output file")
+	defer f.Close()
+	_, err = f.WriteString(strings.Join(args, " ") + "\n")
+	require.NoError(t, err, "Setup: Can't write nmcli output file")
+
+	if wantExit {
+		f.Close()
+		// nolint:gocritic // we want to exit with an error without running the deferred calls
+		os.Exit(1)
+	}
+}
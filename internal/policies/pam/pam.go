@@ -0,0 +1,359 @@
+// Package pam provides a manager to enable PAM modules and set the login access rules of the machine.
+//
+// The policy is only supported on computers. It supports the following entries:
+//   - mkhomedir: creates the home directory of users on their first login with pam_mkhomedir.
+//   - access-rules: the login access rules, in the access.conf format, enforced with pam_access.
+//
+// Modules are enabled by writing pam-config profiles managed by adsys, prefixed with adsys-, and
+// running pam-auth-update, so that the PAM configuration of other packages and of the local
+// administrator is preserved. The profiles are removed with pam-auth-update when the modules are
+// not enabled anymore.
+//
+// The access rules are written in a block delimited by adsys markers in /etc/security/access.conf.
+// Local rules, outside of the markers, are preserved. The managed block is appended to the file the
+// first time, and replaced in place afterwards. As the first matching rule is used, local rules set
+// before the block take precedence over the ones of the policy.
+//
+// The syntax of each rule is checked before changing anything.
+package pam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// beginMarker starts the block of rules managed by adsys.
+	beginMarker = "# BEGIN adsys managed rules"
+	// endMarker ends the block of rules managed by adsys.
+	endMarker = "# END adsys managed rules"
+
+	// mkhomedirProfile is the name of the pam-config profile enabling pam_mkhomedir.
+	mkhomedirProfile = "adsys-mkhomedir"
+	// accessProfile is the name of the pam-config profile enabling pam_access.
+	accessProfile = "adsys-access"
+)
+
+// profiles are the content of the pam-config profiles managed by adsys.
+var profiles = map[string]string{
+	mkhomedirProfile: `Name: Create home directory on first login (managed by adsys)
+Default: yes
+Priority: 0
+Session-Type: Additional
+Session-Interactive-Only: yes
+Session:
+	optional	pam_mkhomedir.so
+`,
+	accessProfile: `Name: Login access control with access.conf (managed by adsys)
+Default: yes
+Priority: 0
+Account-Type: Additional
+Account:
+	required	pam_access.so
+`,
+}
+
+// Manager enables the PAM modules and writes the login access rules of the pam policy.
+type Manager struct {
+	pamConfigsDir    string
+	securityDir      string
+	pamAuthUpdateCmd []string
+}
+
+type options struct {
+	pamConfigsDir    string
+	securityDir      string
+	pamAuthUpdateCmd []string
+}
+
+// Option reprents an optional function to change the pam manager.
+type Option func(*options)
+
+// WithPamConfigsDir specifies a personalized directory for the pam-config profiles.
+func WithPamConfigsDir(p string) Option {
+	return func(o *options) {
+		o.pamConfigsDir = p
+	}
+}
+
+// WithSecurityDir specifies a personalized directory for the access.conf file.
+func WithSecurityDir(p string) Option {
+	return func(o *options) {
+		o.securityDir = p
+	}
+}
+
+// WithPamAuthUpdateCmd overrides the default command updating the PAM configuration from the profiles.
+func WithPamAuthUpdateCmd(cmd []string) Option {
+	return func(o *options) {
+		o.pamAuthUpdateCmd = cmd
+	}
+}
+
+// New creates a manager enabling PAM modules and setting the login access rules.
+func New(opts ...Option) *Manager {
+	// defaults
+	args := options{
+		pamConfigsDir:    "/usr/share/pam-configs",
+		securityDir:      "/etc/security",
+		pamAuthUpdateCmd: []string{"pam-auth-update", "--package"},
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Manager{
+		pamConfigsDir:    args.pamConfigsDir,
+		securityDir:      args.securityDir,
+		pamAuthUpdateCmd: args.pamAuthUpdateCmd,
+	}
+}
+
+// ApplyPolicy enables the PAM modules of the policy and writes its access rules in the managed block of
+// access.conf. The modules which are not enabled anymore are disabled.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply pam policy to %s", objectName))
+
+	// PAM policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	// Check all rules before changing anything.
+	var mkhomedir bool
+	var rules []string
+	for _, e := range entries {
+		switch e.Key {
+		case "mkhomedir":
+			mkhomedir = !e.Disabled
+		case "access-rules":
+			if e.Disabled {
+				continue
+			}
+			if rules, err = parseRules(e.Value); err != nil {
+				return err
+			}
+		default:
+			ignored.RecordUnsupportedKey(ctx, "pam", e.Key, e.Value)
+		}
+	}
+
+	log.Debugf(ctx, "Applying pam policy to %s", objectName)
+
+	wanted := map[string]bool{mkhomedirProfile: mkhomedir, accessProfile: len(rules) > 0}
+
+	// Disable the modules first, so that pam_access is never enabled without the rules of the policy.
+	var toRemove []string
+	for _, name := range []string{mkhomedirProfile, accessProfile} {
+		if wanted[name] {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(m.pamConfigsDir, name)); err == nil {
+			toRemove = append(toRemove, name)
+		}
+	}
+	if len(toRemove) > 0 {
+		if err := m.pamAuthUpdate(ctx, append([]string{"--remove"}, toRemove...)...); err != nil {
+			return err
+		}
+		for _, name := range toRemove {
+			log.Debug(ctx, gotext.Get("Removing pam-config profile %s", name))
+			if err := os.Remove(filepath.Join(m.pamConfigsDir, name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := m.updateAccessConf(ctx, rules); err != nil {
+		return err
+	}
+
+	var changed bool
+	for _, name := range []string{mkhomedirProfile, accessProfile} {
+		if !wanted[name] {
+			continue
+		}
+		written, err := m.writeProfile(ctx, name)
+		if err != nil {
+			return err
+		}
+		changed = changed || written
+	}
+	if changed {
+		return m.pamAuthUpdate(ctx)
+	}
+
+	return nil
+}
+
+// writeProfile writes the pam-config profile name. It returns true if the profile changed.
+func (m *Manager) writeProfile(ctx context.Context, name string) (changed bool, err error) {
+	p := filepath.Join(m.pamConfigsDir, name)
+	content := profiles[name]
+
+	oldContent, err := os.ReadFile(p)
+	if err == nil && string(oldContent) == content {
+		return false, nil
+	}
+
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(m.pamConfigsDir, 0755); err != nil {
+		return false, err
+	}
+	log.Debug(ctx, gotext.Get("Writing pam-config profile %s", name))
+	// nolint:gosec // G306 match distribution permission
+	if err := os.WriteFile(p+".new", []byte(content), 0644); err != nil {
+		return false, err
+	}
+	return true, os.Rename(p+".new", p)
+}
+
+// pamAuthUpdate updates the PAM configuration from the pam-config profiles, with the additional args.
+func (m *Manager) pamAuthUpdate(ctx context.Context, args ...string) error {
+	args = append(slices.Clone(m.pamAuthUpdateCmd[1:]), args...)
+	log.Debug(ctx, gotext.Get("Updating PAM configuration: %s %s", m.pamAuthUpdateCmd[0], strings.Join(args, " ")))
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, m.pamAuthUpdateCmd[0], args...)
+	trace.Command(ctx, "pam", cmd.Args)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return errors.New(gotext.Get("failed to update PAM configuration: %v\n%s", err, string(out)))
+	}
+	return nil
+}
+
+// updateAccessConf replaces the managed block of access.conf with the rules, or removes it if there is none.
+// Content outside the managed block is preserved.
+func (m *Manager) updateAccessConf(ctx context.Context, rules []string) (err error) {
+	path := filepath.Join(m.securityDir, "access.conf")
+	defer decorate.OnError(&err, gotext.Get("can't update %s", path))
+
+	var mode fs.FileMode = 0644
+	oldContent, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err != nil && len(rules) == 0 {
+		return nil
+	}
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	before, after, found, err := splitManagedBlock(string(oldContent))
+	if err != nil {
+		return err
+	}
+
+	var block string
+	if len(rules) > 0 {
+		block = fmt.Sprintf(`%s
+# Do not edit this block manually.
+# Any changes will be overwritten.
+%s
+%s
+`, beginMarker, strings.Join(rules, "\n"), endMarker)
+	}
+
+	switch {
+	case found && block == "" && after == "":
+		// Drop the blank line separating the removed block from the local rules.
+		before = strings.TrimSuffix(before, "\n")
+		if !strings.HasSuffix(before, "\n") && before != "" {
+			before += "\n"
+		}
+	case !found && block != "" && before != "":
+		// Append the block to the file the first time, separated from the local rules.
+		if !strings.HasSuffix(before, "\n") {
+			before += "\n"
+		}
+		before += "\n"
+	}
+	content := before + block + after
+
+	if content == string(oldContent) {
+		return nil
+	}
+
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(m.securityDir, 0755); err != nil {
+		return err
+	}
+	log.Debug(ctx, gotext.Get("Updating managed rules of %s", path))
+	if err := os.WriteFile(path+".new", []byte(content), mode); err != nil {
+		return err
+	}
+	return os.Rename(path+".new", path)
+}
+
+// splitManagedBlock returns the content before and after the managed block, and if it was found. The content is
+// returned unchanged in before if there is no managed block.
+func splitManagedBlock(content string) (before, after string, found bool, err error) {
+	lines := strings.SplitAfter(content, "\n")
+
+	begin, end := -1, -1
+	for i, l := range lines {
+		switch strings.TrimSpace(l) {
+		case beginMarker:
+			if begin != -1 {
+				return "", "", false, errors.New(gotext.Get("multiple adsys managed blocks"))
+			}
+			begin = i
+		case endMarker:
+			if begin == -1 || end != -1 {
+				return "", "", false, errors.New(gotext.Get("unexpected end of adsys managed block"))
+			}
+			end = i
+		}
+	}
+
+	if begin == -1 {
+		return content, "", false, nil
+	}
+	if end == -1 {
+		return "", "", false, errors.New(gotext.Get("unterminated adsys managed block"))
+	}
+
+	return strings.Join(lines[:begin], ""), strings.Join(lines[end+1:], ""), true, nil
+}
+
+// parseRules minimally validates the login access rules, one per line, of the entry value, in the
+// "permission : users/groups : origins" format, where permission is + or -. Empty lines and comments are ignored.
+func parseRules(value string) (rules []string, err error) {
+	for _, l := range strings.Split(value, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		// Origins can contain colons, like IPv6 addresses.
+		fields := strings.SplitN(l, ":", 3)
+		if len(fields) < 3 || strings.TrimSpace(fields[1]) == "" || strings.TrimSpace(fields[2]) == "" {
+			return nil, errors.New(gotext.Get("invalid rule %q: expecting permission : users/groups : origins", l))
+		}
+		if p := strings.TrimSpace(fields[0]); p != "+" && p != "-" {
+			return nil, errors.New(gotext.Get("invalid rule %q: permission must be + or -", l))
+		}
+		rules = append(rules, l)
+	}
+
+	return rules, nil
+}
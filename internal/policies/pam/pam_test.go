@@ -0,0 +1,152 @@
+package pam_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/pam"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{
+		{Key: "mkhomedir"},
+		{Key: "access-rules", Value: "+:(admins@example.com):ALL\n-:ALL EXCEPT root:ALL"},
+	}
+
+	tests := map[string]struct {
+		entries  []entry.Entry
+		user     bool
+		existing string
+
+		pamAuthUpdateFail bool
+
+		wantCalls []string
+		wantErr   bool
+	}{
+		"Computer, modules are enabled and rules are written":     {wantCalls: []string{"--package"}},
+		"Computer, mkhomedir is enabled":                          {entries: []entry.Entry{{Key: "mkhomedir"}}, wantCalls: []string{"--package"}},
+		"Computer, mkhomedir is enabled with local configuration": {existing: "local_config", entries: []entry.Entry{{Key: "mkhomedir"}}, wantCalls: []string{"--package"}},
+		"Computer, rules are appended after local rules":          {existing: "local_config", wantCalls: []string{"--package"}},
+		"Computer, managed block is updated in place":             {existing: "managed_config", entries: []entry.Entry{{Key: "mkhomedir"}, {Key: "access-rules", Value: "+:(operators@example.com):LOCAL"}}},
+		"Computer, unchanged configuration is kept":               {existing: "managed_config"},
+		"Computer, comments and blank lines in rules are ignored": {existing: "local_config", entries: []entry.Entry{{Key: "access-rules", Value: "# Admins\n\n  +:(admins@example.com):ALL  \n"}}, wantCalls: []string{"--package"}},
+		"Computer, rules can have IPv6 origins":                   {entries: []entry.Entry{{Key: "access-rules", Value: "+:ALL:2001:db8::/32"}}, wantCalls: []string{"--package"}},
+		"Computer, disabled mkhomedir is disabled":                {existing: "managed_config", entries: []entry.Entry{{Key: "mkhomedir", Disabled: true}, defaultEntries[1]}, wantCalls: []string{"--package --remove adsys-mkhomedir"}},
+		"Computer, disabled rules disable pam_access":             {existing: "managed_config", entries: []entry.Entry{{Key: "mkhomedir"}, {Key: "access-rules", Disabled: true}}, wantCalls: []string{"--package --remove adsys-access"}},
+		"Computer, empty rules disable pam_access":                {existing: "managed_config", entries: []entry.Entry{{Key: "mkhomedir"}, {Key: "access-rules", Value: "# No rules"}}, wantCalls: []string{"--package --remove adsys-access"}},
+		"Computer, no entries disables modules and removes rules": {existing: "managed_config", entries: []entry.Entry{}, wantCalls: []string{"--package --remove adsys-mkhomedir adsys-access"}},
+		"Computer, no entries keeps local configuration":          {existing: "local_config", entries: []entry.Entry{}},
+		"Computer, no entries and no configuration":               {entries: []entry.Entry{}},
+		"Computer, disabling a module keeps the other one":        {existing: "managed_config", entries: []entry.Entry{{Key: "mkhomedir", Disabled: true}, {Key: "access-rules", Value: "-:ALL EXCEPT root:ALL"}}, wantCalls: []string{"--package --remove adsys-mkhomedir"}},
+		"Computer, unsupported key is ignored":                    {existing: "local_config", entries: []entry.Entry{{Key: "pam-foo", Value: "bar"}}},
+		"User, policy is ignored":                                 {existing: "local_config", user: true},
+		"User, existing configuration is kept":                    {existing: "managed_config", entries: []entry.Entry{}, user: true},
+
+		"Error on rule without origins":              {existing: "managed_config", entries: []entry.Entry{{Key: "access-rules", Value: "+:root"}}, wantErr: true},
+		"Error on rule without users":                {existing: "managed_config", entries: []entry.Entry{{Key: "access-rules", Value: "+: :ALL"}}, wantErr: true},
+		"Error on rule with invalid permission":      {existing: "managed_config", entries: []entry.Entry{{Key: "access-rules", Value: "allow:root:ALL"}}, wantErr: true},
+		"Error on invalid rule doesn't change PAM":   {existing: "managed_config", entries: []entry.Entry{{Key: "access-rules", Value: "+:root:ALL\n-:ALL"}}, wantErr: true},
+		"Error on unterminated managed block":        {existing: "unterminated_block", wantErr: true},
+		"Error on pam-auth-update failing":           {pamAuthUpdateFail: true, wantCalls: []string{"--package"}, wantErr: true},
+		"Error on pam-auth-update failing to remove": {existing: "managed_config", entries: []entry.Entry{}, pamAuthUpdateFail: true, wantCalls: []string{"--package --remove adsys-mkhomedir adsys-access"}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			rootDir := filepath.Join(t.TempDir(), "root")
+			if tc.existing != "" {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), tc.existing), rootDir)
+			} else {
+				require.NoError(t, os.MkdirAll(rootDir, 0750), "Setup: can't create root dir")
+			}
+
+			outputFile := filepath.Join(t.TempDir(), "pam-auth-update-output")
+			pamAuthUpdateCmd := mockPamAuthUpdateCmd(t, outputFile)
+			if tc.pamAuthUpdateFail {
+				pamAuthUpdateCmd = append(pamAuthUpdateCmd, "-Exit1")
+			}
+
+			m := pam.New(
+				pam.WithPamConfigsDir(filepath.Join(rootDir, "usr", "share", "pam-configs")),
+				pam.WithSecurityDir(filepath.Join(rootDir, "etc", "security")),
+				pam.WithPamAuthUpdateCmd(pamAuthUpdateCmd))
+
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				// We don't return here as we want to check that the configuration is in the expected state even in error cases
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			testutils.CompareTreesWithFiltering(t, rootDir, filepath.Join(testutils.GoldenPath(t), "root"), testutils.UpdateEnabled())
+
+			got, err := os.ReadFile(outputFile)
+			if len(tc.wantCalls) == 0 {
+				require.Error(t, err, "pam-auth-update should not have been called")
+				return
+			}
+			require.NoError(t, err, "pam-auth-update should have been called")
+			require.Equal(t, strings.Join(tc.wantCalls, "\n")+"\n", string(got), "pam-auth-update should have been called with the expected arguments")
+		})
+	}
+}
+
+func mockPamAuthUpdateCmd(t *testing.T, outputFile string) []string {
+	t.Helper()
+
+	return []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockPamAuthUpdate", "--", outputFile, "--package"}
+}
+
+func TestMockPamAuthUpdate(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := os.Args
+	for len(args) > 0 {
+		if args[0] != "--" {
+			args = args[1:]
+			continue
+		}
+		args = args[1:]
+		break
+	}
+	// First arg after -- is the output file to write to
+	outputFile, args := args[0], args[1:]
+
+	// Arguments are appended by the manager after the failure flag
+	var wantExit bool
+	if i := slices.Index(args, "-Exit1"); i != -1 {
+		wantExit = true
+		args = slices.Delete(args, i, i+1)
+	}
+
+	f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	require.NoError(t, err, "Setup: Can't open command output file")
+	defer f.Close()
+	_, err = f.WriteString(strings.Join(args, " ") + "\n")
+	require.NoError(t, err, "Setup: Can't write command output file")
+
+	if wantExit {
+		f.Close()
+		// nolint:gocritic // we want to exit with an error without running the deferred calls
+		os.Exit(1)
+	}
+}
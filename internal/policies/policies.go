@@ -12,12 +12,15 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
 	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/facts"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
 	"github.com/ubuntu/decorate"
 	"golang.org/x/exp/mmap"
 	"gopkg.in/yaml.v3"
@@ -25,9 +28,13 @@ import (
 
 const (
 	// PoliciesCacheBaseName is the base directory where we want to cache policies.
-	PoliciesCacheBaseName  = "policies"
-	policiesFileName       = "policies"
+	PoliciesCacheBaseName = "policies"
+	// PoliciesFileName is the file name of the serialized policies in an object cache directory.
+	PoliciesFileName       = "policies"
 	policiesAssetsFileName = "assets.db"
+
+	// filterRule is the policy type of the GPO scope filters. They are never applied by a policy manager.
+	filterRule = "filter"
 )
 
 type assetsFromMMAP struct {
@@ -38,8 +45,14 @@ type assetsFromMMAP struct {
 
 // Policies is the list of GPOs applied to a particular object, with the global data cache.
 type Policies struct {
-	GPOs   []GPO
-	assets *assetsFromMMAP `yaml:"-"`
+	GPOs []GPO
+	// ComputerAttributes are the attributes of the computer object read from AD, keyed by their lowercase name.
+	// They are only set for the machine.
+	ComputerAttributes map[string]string `yaml:",omitempty"`
+	// KeepCache prevents the policies from replacing the cached ones once applied, like when unloading a stale
+	// cache which must stay available for later offline refreshes and inspection.
+	KeepCache bool            `yaml:"-"`
+	assets    *assetsFromMMAP `yaml:"-"`
 }
 
 // New returns new policies with GPOs and assets loaded from DB.
@@ -70,7 +83,7 @@ func NewFromCache(ctx context.Context, p string) (pols Policies, err error) {
 
 	log.Debugf(ctx, "Loading policies from cache using %s", p)
 
-	d, err := os.ReadFile(filepath.Join(p, policiesFileName))
+	d, err := os.ReadFile(filepath.Join(p, PoliciesFileName))
 	if err != nil {
 		return pols, err
 	}
@@ -130,7 +143,7 @@ func (pols *Policies) Save(p string) (err error) {
 	if err != nil {
 		return err
 	}
-	if err := os.WriteFile(filepath.Join(p, policiesFileName), d, 0600); err != nil {
+	if err := os.WriteFile(filepath.Join(p, PoliciesFileName), d, 0600); err != nil {
 		return err
 	}
 
@@ -362,6 +375,83 @@ func CompressAssets(ctx context.Context, p string) (err error) {
 	return err
 }
 
+// applicableTo returns a copy of the policies without the GPOs whose scope filter is not met by the machine facts, and
+// without the entries whose condition is not met. Filtered GPOs and entries don't override the same keys from further
+// GPOs.
+func (pols Policies) applicableTo(ctx context.Context, f facts.Facts) (applicable Policies, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't evaluate policy conditions"))
+
+	applicable = pols
+	applicable.GPOs = make([]GPO, 0, len(pols.GPOs))
+	for _, g := range pols.GPOs {
+		filter, met, err := g.filterMet(f)
+		if err != nil {
+			return Policies{}, err
+		}
+		if !met {
+			log.Info(ctx, gotext.Get("Skipping GPO %s as its scope filter %q is not met", g.Name, filter.Value))
+			ignored.Record(ctx, ignored.Entry{Rule: filterRule, Key: filter.Key, Value: filter.Value, Reason: ignored.GPOFilteredOut,
+				Details: gotext.Get("GPO %s is skipped", g.Name)})
+			continue
+		}
+
+		rules := make(map[string][]entry.Entry, len(g.Rules))
+		for t, entries := range g.Rules {
+			// Scope filters only apply to their GPO.
+			if t == filterRule {
+				continue
+			}
+			for _, e := range entries {
+				met, err := f.Eval(e.Condition)
+				if err != nil {
+					return Policies{}, err
+				}
+				if !met {
+					log.Debugf(ctx, "Skipping %s entry %s from %s as condition %q is not met", t, e.Key, g.Name, e.Condition)
+					ignored.Record(ctx, ignored.Entry{Rule: t, Key: e.Key, Value: e.Value, Reason: ignored.ConditionNotMet,
+						Details: gotext.Get("condition %q of %s is not met", e.Condition, g.Name)})
+					continue
+				}
+				rules[t] = append(rules[t], e)
+			}
+		}
+		g.Rules = rules
+		applicable.GPOs = append(applicable.GPOs, g)
+	}
+
+	return applicable, nil
+}
+
+// filterMet returns true if all the enabled scope filters of the GPO are met by the facts. Otherwise, it returns the
+// first filter which is not met.
+func (g GPO) filterMet(f facts.Facts) (filter entry.Entry, met bool, err error) {
+	for _, e := range g.Rules[filterRule] {
+		if e.Disabled {
+			continue
+		}
+		met, err := f.Eval(e.Value)
+		if err != nil {
+			return e, false, errors.New(gotext.Get("invalid scope filter of GPO %s: %v", g.Name, err))
+		}
+		if !met {
+			return e, false, nil
+		}
+	}
+	return entry.Entry{}, true, nil
+}
+
+// unionLines returns the lines of further followed by the lines of closest which are not in further.
+func unionLines(further, closest string) string {
+	lines := strings.Split(further, "\n")
+	for _, l := range strings.Split(closest, "\n") {
+		if slices.ContainsFunc(lines, func(o string) bool { return strings.TrimSpace(o) == strings.TrimSpace(l) }) {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return strings.Join(lines, "\n")
+}
+
 // GetUniqueRules return order rules, with one entry per key for a given type.
 // Returned file is a map of type to its entries.
 func (pols Policies) GetUniqueRules() map[string][]entry.Entry {
@@ -378,7 +468,7 @@ func (pols Policies) GetUniqueRules() map[string][]entry.Entry {
 			}
 			for _, e := range entries {
 				switch e.Strategy {
-				case entry.StrategyAppend:
+				case entry.StrategyAppend, entry.StrategyUnion:
 					// We skip disabled keys as we only append enabled one.
 					if e.Disabled {
 						continue
@@ -387,13 +477,19 @@ func (pols Policies) GetUniqueRules() map[string][]entry.Entry {
 					// If there is an existing value, prepend new value to it. We are analyzing GPOs in reverse order (closest first).
 					if _, exists := seen[t+e.Key]; exists {
 						keyAlreadySeen = true
+						closest := dedup[t][e.Key]
 						// We have seen a closest key which is an override. We don’t append furthest append values.
-						if dedup[t][e.Key].Strategy != entry.StrategyAppend {
+						if closest.Strategy != entry.StrategyAppend && closest.Strategy != entry.StrategyUnion {
 							continue
 						}
-						e.Value = e.Value + "\n" + dedup[t][e.Key].Value
-						// Keep closest meta value.
-						e.Meta = dedup[t][e.Key].Meta
+						if closest.Strategy == entry.StrategyUnion {
+							e.Value = unionLines(e.Value, closest.Value)
+						} else {
+							e.Value = e.Value + "\n" + closest.Value
+						}
+						// Keep closest meta value and strategy.
+						e.Meta = closest.Meta
+						e.Strategy = closest.Strategy
 					}
 					dedup[t][e.Key] = e
 					if keyAlreadySeen {
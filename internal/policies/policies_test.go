@@ -836,6 +836,56 @@ func TestGetUniqueRules(t *testing.T) {
 				},
 			}},
 
+		// union cases
+		"Union policy entry, multiple GPOs, lines already listed are skipped": {
+			gpos: []policies.GPO{
+				{ID: "closest", Name: "closest-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Value: "'b.desktop'\n'c.desktop'", Strategy: entry.StrategyUnion},
+					}}},
+				{ID: "furthest", Name: "furthest-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Value: "'a.desktop'\n 'b.desktop'", Strategy: entry.StrategyUnion},
+					}}},
+			},
+			want: map[string][]entry.Entry{
+				"domain": {
+					{Key: "A", Value: "'a.desktop'\n 'b.desktop'\n'c.desktop'", Strategy: entry.StrategyUnion},
+				},
+			}},
+		"Union policy entry, multiple GPOs, disabled key is ignored": {
+			gpos: []policies.GPO{
+				{ID: "closest", Name: "closest-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Value: "closest value", Strategy: entry.StrategyUnion},
+					}}},
+				{ID: "furthest", Name: "furthest-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Value: "furthest value", Strategy: entry.StrategyUnion, Disabled: true},
+					}}},
+			},
+			want: map[string][]entry.Entry{
+				"domain": {
+					{Key: "A", Value: "closest value", Strategy: entry.StrategyUnion},
+				},
+			}},
+		"Mix union and append on GPOs, closest strategy wins": {
+			gpos: []policies.GPO{
+				{ID: "closest", Name: "closest-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Value: "same value", Strategy: entry.StrategyUnion},
+					}}},
+				{ID: "furthest", Name: "furthest-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Value: "same value", Strategy: entry.StrategyAppend},
+					}}},
+			},
+			want: map[string][]entry.Entry{
+				"domain": {
+					{Key: "A", Value: "same value", Strategy: entry.StrategyUnion},
+				},
+			}},
+
 		// Mix append and override: closest win
 		"Mix meta on GPOs, furthest policy entry is append, closest is override": {
 			gpos: []policies.GPO{
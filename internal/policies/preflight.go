@@ -0,0 +1,60 @@
+package policies
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	"golang.org/x/sys/unix"
+)
+
+// managedDir is a directory written by a policy manager, checked before applying any policy.
+type managedDir struct {
+	path string
+	// policy is the policy type writing to the directory.
+	policy string
+	// computerOnly is set if the directory is only written when applying computer policies.
+	computerOnly bool
+}
+
+// checkManagedDirs checks that the managed directories of the object type can be written to, before applying any
+// policy, so that some policy managers don't succeed while others fail.
+// The returned error lists all directories which can't be written to.
+func (m *Manager) checkManagedDirs(isComputer bool) error {
+	var inaccessible []string
+	for _, d := range m.managedDirs {
+		if d.computerOnly && !isComputer {
+			continue
+		}
+		if err := checkWritable(d.path); err != nil {
+			inaccessible = append(inaccessible, fmt.Sprintf("%s (%s): %v", d.path, d.policy, err))
+		}
+	}
+	if len(inaccessible) == 0 {
+		return nil
+	}
+
+	return errors.New(gotext.Get("no policy was applied as some managed directories can't be written to:\n%s", strings.Join(inaccessible, "\n")))
+}
+
+// checkWritable checks that dir, or its closest existing parent if dir doesn't exist yet, can be written to.
+// Nothing is written to the system.
+func checkWritable(dir string) error {
+	for {
+		_, err := os.Stat(dir)
+		if err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if !errors.Is(err, fs.ErrNotExist) || parent == dir {
+			return err
+		}
+		dir = parent
+	}
+
+	return unix.Access(dir, unix.W_OK)
+}
@@ -0,0 +1,224 @@
+package privilege
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/decorate"
+)
+
+// SudoAccess is whether the privilege policy grants sudo to a user, and the rule deciding it.
+type SudoAccess struct {
+	// Matched is false when no rule of the adsys sudoers files applies to the user: the local sudo configuration
+	// decides alone.
+	Matched bool
+	// Granted is true when the last adsys rule applying to the user grants commands, as the last matching sudoers
+	// rule wins.
+	Granted bool
+	// Path and Line locate the deciding rule.
+	Path string
+	Line int
+	// Rule is the deciding rule, on one line.
+	Rule string
+	// Identity is the user, group or alias of the rule the user matched.
+	Identity string
+	// PolkitIdentity is the polkit administrator identity in effect matching the user, if any.
+	PolkitIdentity string
+}
+
+// SudoAccess reports whether the sudoers files written by adsys grant sudo to name, and which rule decides it.
+// The groups of the user are resolved through NSS, and sudo itself is never run. Netgroups are not resolved.
+func (m *Manager) SudoAccess(ctx context.Context, name string) (access SudoAccess, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't check sudo access of %s", name))
+
+	groups, err := m.userGroups(name)
+	if err != nil {
+		return access, err
+	}
+
+	// sudo reads the files of the include directory in lexical order: the main adsys file is read before the group
+	// files and the snippets.
+	sudoersConf, _, _, _ := m.managedPaths(ctx)
+	others, err := installedSudoersFiles(filepath.Dir(sudoersConf), filepath.Base(sudoersConf)+"-")
+	if err != nil {
+		return access, err
+	}
+	slices.Sort(others)
+
+	for _, p := range append([]string{sudoersConf}, others...) {
+		if err := lastSudoersMatch(p, name, groups, &access); err != nil {
+			return access, err
+		}
+	}
+
+	ids, err := m.AdminIdentities(ctx)
+	if err != nil {
+		return access, err
+	}
+	for _, id := range ids {
+		if u, ok := strings.CutPrefix(id, "unix-user:"); ok && strings.EqualFold(u, name) {
+			access.PolkitIdentity = id
+			break
+		}
+		if g, ok := strings.CutPrefix(id, "unix-group:"); ok && containsFold(groups, g) {
+			access.PolkitIdentity = id
+			break
+		}
+	}
+
+	return access, nil
+}
+
+// lastSudoersMatch updates access with the rules of the sudoers file p applying to the user name, member of groups.
+// A missing file has no rule.
+func lastSudoersMatch(p, name string, groups []string, access *SudoAccess) error {
+	content, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	aliases := make(map[string][]string)
+	var rule string
+	var ruleLine int
+	for i, l := range strings.Split(string(content), "\n") {
+		if rule == "" {
+			ruleLine = i + 1
+		}
+		// Rules can span multiple lines, ending with a backslash.
+		if cont, ok := strings.CutSuffix(strings.TrimRight(l, " \t"), `\`); ok {
+			rule += cont + " "
+			continue
+		}
+		rule = strings.Join(strings.Fields(rule+l), " ")
+		if def, ok := strings.CutPrefix(rule, "User_Alias "); ok {
+			aliasName, members, _ := strings.Cut(def, "=")
+			aliases[strings.TrimSpace(aliasName)] = splitUsersList(members)
+			rule = ""
+			continue
+		}
+		users, spec := splitRule(rule)
+		if users == "" {
+			rule = ""
+			continue
+		}
+		if id := matchUsersList(splitUsersList(users), name, groups, aliases); id != "" {
+			// The commands follow the run as list, if any.
+			_, cmds, _ := strings.Cut(spec, "=")
+			if _, after, found := strings.Cut(cmds, ")"); found {
+				cmds = after
+			}
+			*access = SudoAccess{
+				Matched:  true,
+				Granted:  !strings.HasPrefix(strings.TrimSpace(cmds), "!"),
+				Path:     p,
+				Line:     ruleLine,
+				Rule:     rule,
+				Identity: id,
+			}
+		}
+		rule = ""
+	}
+
+	return nil
+}
+
+// splitRule returns the users list of a sudoers rule, ending with the first blank outside of quotes, and the rest of
+// the rule. Comments, defaults and includes are not rules and have no users list.
+func splitRule(rule string) (users, spec string) {
+	if rule == "" || strings.HasPrefix(rule, "#") || strings.HasPrefix(rule, "@") || strings.HasPrefix(rule, "Defaults") {
+		return "", ""
+	}
+	var quoted bool
+	for i, c := range rule {
+		if c == '"' {
+			quoted = !quoted
+		}
+		if !quoted && (c == ' ' || c == '\t') {
+			return rule[:i], rule[i+1:]
+		}
+	}
+	return "", ""
+}
+
+// splitUsersList returns the items of a comma separated sudoers users list.
+func splitUsersList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// matchUsersList returns the item of the users list matching the user name, member of groups, or an empty string if
+// none does. As sudo, the last matching item wins, and a negated one excludes the user.
+func matchUsersList(items []string, name string, groups []string, aliases map[string][]string) (id string) {
+	for _, item := range items {
+		negated := strings.HasPrefix(item, "!")
+		u := strings.Trim(strings.TrimPrefix(item, "!"), `"`)
+
+		var matched bool
+		switch {
+		case u == "ALL":
+			matched = true
+		case strings.HasPrefix(u, "%"):
+			matched = containsFold(groups, strings.TrimPrefix(strings.TrimPrefix(u, "%"), ":"))
+		case strings.HasPrefix(u, "+"), strings.HasPrefix(u, "#"):
+			// Netgroups and user ids are not resolved.
+		default:
+			if members, isAlias := aliases[u]; isAlias {
+				if member := matchUsersList(members, name, groups, aliases); member != "" {
+					matched = true
+					u = fmt.Sprintf("%s (%s)", u, member)
+				}
+				break
+			}
+			matched = strings.EqualFold(u, name)
+		}
+		if !matched {
+			continue
+		}
+		id = u
+		if negated {
+			id = ""
+		}
+	}
+	return id
+}
+
+// containsFold returns true if s contains v, ignoring the case.
+func containsFold(s []string, v string) bool {
+	return slices.ContainsFunc(s, func(e string) bool { return strings.EqualFold(e, v) })
+}
+
+// lookupUserGroups returns the names of the groups of the user name, resolved through NSS.
+func lookupUserGroups(name string) ([]string, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	gids, err := u.GroupIds()
+	if err != nil {
+		return nil, err
+	}
+	var groups []string
+	for _, gid := range gids {
+		g, err := user.LookupGroupId(gid)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g.Name)
+	}
+	return groups, nil
+}
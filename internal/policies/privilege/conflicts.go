@@ -0,0 +1,114 @@
+package privilege
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+)
+
+// sudoersConflict is a rule of a local sudoers file referencing an identity managed by adsys.
+type sudoersConflict struct {
+	file     string
+	line     int
+	identity string
+}
+
+// warnSudoersConflicts logs a warning for each rule of the local sudoers files referencing one of the identities
+// managed by adsys. Those files are never modified.
+func warnSudoersConflicts(ctx context.Context, sudoersDir string, identities []string) {
+	conflicts, err := sudoersConflicts(sudoersDir, identities)
+	if err != nil {
+		log.Warningf(ctx, "Can't check local sudoers files for conflicts: %v", err)
+		return
+	}
+	for _, c := range conflicts {
+		log.Warning(ctx, gotext.Get("Local sudoers rule in %s, line %d, also applies to %s, managed by adsys: the effective privileges may differ from the policy",
+			c.file, c.line, c.identity))
+	}
+}
+
+// sudoersConflicts returns the rules of the files of sudoersDir, not managed by adsys, whose users list references
+// one of identities. As sudo, files with a "." in their name or ending with "~" are ignored.
+func sudoersConflicts(sudoersDir string, identities []string) (conflicts []sudoersConflict, err error) {
+	if len(identities) == 0 {
+		return nil, nil
+	}
+
+	files, err := os.ReadDir(sudoersDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || strings.HasPrefix(name, adsysBaseConfName) || strings.Contains(name, ".") || strings.HasSuffix(name, "~") {
+			continue
+		}
+		p := filepath.Join(sudoersDir, name)
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		var rule string
+		var ruleLine int
+		for i, l := range strings.Split(string(content), "\n") {
+			if rule == "" {
+				ruleLine = i + 1
+			}
+			// Rules can span multiple lines, ending with a backslash.
+			if cont, ok := strings.CutSuffix(strings.TrimRight(l, " \t"), `\`); ok {
+				rule += cont + " "
+				continue
+			}
+			rule += l
+			if id := ruleIdentity(rule, identities); id != "" {
+				conflicts = append(conflicts, sudoersConflict{file: p, line: ruleLine, identity: id})
+			}
+			rule = ""
+		}
+	}
+
+	return conflicts, nil
+}
+
+// ruleIdentity returns the first of identities referenced in the users list of a sudoers rule, or an empty string
+// if there is none. Comments, defaults and aliases are not rules.
+func ruleIdentity(rule string, identities []string) string {
+	rule = strings.TrimSpace(rule)
+	if rule == "" || strings.HasPrefix(rule, "#") || strings.HasPrefix(rule, "@") || strings.HasPrefix(rule, "Defaults") {
+		return ""
+	}
+	// The users list ends with the first blank outside of quotes.
+	users := rule
+	var quoted bool
+	for i, c := range rule {
+		if c == '"' {
+			quoted = !quoted
+		}
+		if !quoted && (c == ' ' || c == '\t') {
+			users = rule[:i]
+			break
+		}
+	}
+	if strings.HasSuffix(users, "_Alias") {
+		return ""
+	}
+
+	for _, u := range strings.Split(users, ",") {
+		u = strings.Trim(strings.TrimPrefix(strings.TrimSpace(u), "!"), `"`)
+		if i := slices.IndexFunc(identities, func(id string) bool { return strings.EqualFold(id, u) }); i >= 0 {
+			return identities[i]
+		}
+	}
+	return ""
+}
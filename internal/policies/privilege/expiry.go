@@ -0,0 +1,267 @@
+package privilege
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// ttlTag prefixes a client administrator only granted privileges for a given duration.
+	ttlTag = "[ttl="
+	// expiryMarker starts the comments annotating temporary grants in the sudoers and polkit files.
+	expiryMarker = "# adsys-expires: "
+	// expiryUnitName is the name of the systemd timer and service removing expired grants.
+	expiryUnitName = "adsys-privilege-expiry"
+	// adsysdPath is the path of the daemon executable removing expired grants.
+	adsysdPath = "/sbin/adsysd"
+)
+
+// polkitExpiryRe matches the expiry times of the temporary administrators in the polkit admin rules.
+var polkitExpiryRe = regexp.MustCompile(`Date\.parse\("[^"]*"\)`)
+
+const expiryTimerTemplate = `# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+[Unit]
+Description=Remove expired ADSys privilege grants
+
+[Timer]
+%sPersistent=true
+AccuracySec=1s
+
+[Install]
+WantedBy=timers.target
+`
+
+const expiryServiceTemplate = `# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+[Unit]
+Description=Remove expired ADSys privilege grants
+
+[Service]
+Type=oneshot
+ExecStart=%s expire-privileges %s %s
+`
+
+// expiryComment returns the comment annotating a temporary grant expiring at expiry.
+// In the sudoers file, it annotates the rule on the next line. In the polkit file, it annotates polkitID.
+func expiryComment(expiry time.Time, polkitID string) string {
+	if polkitID == "" {
+		return fmt.Sprintf("%s%s\n", expiryMarker, expiry.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s%s %s\n", expiryMarker, expiry.Format(time.RFC3339), polkitID)
+}
+
+// withoutExpiryComments returns content without the annotations of the temporary grants. The expiry times of the
+// polkit admin rules are blanked too.
+func withoutExpiryComments(content string) string {
+	lines := strings.SplitAfter(content, "\n")
+	lines = slices.DeleteFunc(lines, func(l string) bool { return strings.HasPrefix(l, expiryMarker) })
+	return polkitExpiryRe.ReplaceAllString(strings.Join(lines, ""), `Date.parse("")`)
+}
+
+// removeIdentities returns the polkit identities list, separated by ;, without the ones in ids.
+func removeIdentities[T any](identities string, ids map[string]T) string {
+	var kept []string
+	for _, id := range strings.Split(identities, ";") {
+		if _, ok := ids[id]; ok || id == "" {
+			continue
+		}
+		kept = append(kept, id)
+	}
+	return strings.Join(kept, ";")
+}
+
+// scheduleGrantsExpiry installs a systemd timer removing the temporary grants from sudoersConf and
+// policyKitConf at their expiry times, replacing any previous schedule.
+// If there is no expiry, any existing timer is removed.
+func (m *Manager) scheduleGrantsExpiry(ctx context.Context, expiries []time.Time, sudoersConf, policyKitConf string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't schedule privilege grants expiry"))
+
+	timer := filepath.Join(m.systemUnitDir, expiryUnitName+".timer")
+	service := filepath.Join(m.systemUnitDir, expiryUnitName+".service")
+
+	if len(expiries) == 0 {
+		if _, err := os.Stat(timer); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if m.systemdCaller == nil {
+			return errors.New(gotext.Get("no systemd caller to remove the expiry timer"))
+		}
+		if err := m.systemdCaller.StopUnit(ctx, expiryUnitName+".timer"); err != nil {
+			log.Warning(ctx, gotext.Get("Failed to stop unit %q: %v", expiryUnitName+".timer", err))
+		}
+		if err := m.systemdCaller.DisableUnit(ctx, expiryUnitName+".timer"); err != nil {
+			return err
+		}
+		for _, p := range []string{timer, service} {
+			if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+		return m.systemdCaller.DaemonReload(ctx)
+	}
+
+	if m.systemdCaller == nil {
+		return errors.New(gotext.Get("no systemd caller to schedule the expiry timer"))
+	}
+
+	slices.SortFunc(expiries, func(a, b time.Time) int { return a.Compare(b) })
+	expiries = slices.Compact(expiries)
+	var onCalendar string
+	for _, e := range expiries {
+		onCalendar += fmt.Sprintf("OnCalendar=%s\n", e.UTC().Format("2006-01-02 15:04:05 UTC"))
+	}
+
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(m.systemUnitDir, 0755); err != nil {
+		return err
+	}
+	var changed bool
+	for p, content := range map[string]string{
+		timer:   fmt.Sprintf(expiryTimerTemplate, onCalendar),
+		service: fmt.Sprintf(expiryServiceTemplate, adsysdPath, sudoersConf, policyKitConf),
+	} {
+		if old, err := os.ReadFile(p); err == nil && string(old) == content {
+			continue
+		}
+		// #nosec G306 - systemd units are world-readable.
+		if err := os.WriteFile(p+".new", []byte(content), 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(p+".new", p); err != nil {
+			return err
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	log.Debugf(ctx, "Scheduling privilege grants expiry at %v", expiries)
+	if err := m.systemdCaller.DaemonReload(ctx); err != nil {
+		return err
+	}
+	if err := m.systemdCaller.EnableUnit(ctx, expiryUnitName+".timer"); err != nil {
+		return err
+	}
+	// Restart the timer so that it is rescheduled with the new expiry times.
+	if err := m.systemdCaller.StopUnit(ctx, expiryUnitName+".timer"); err != nil {
+		log.Warning(ctx, gotext.Get("Failed to stop unit %q: %v", expiryUnitName+".timer", err))
+	}
+	return m.systemdCaller.StartUnit(ctx, expiryUnitName+".timer")
+}
+
+// RemoveExpiredGrants removes the expired temporary administrators from sudoersConf, the sudoers group files next to
+// it, and policyKitConf. The files modified locally are handled by the tamper guard of ctx, if any.
+func RemoveExpiredGrants(ctx context.Context, sudoersConf, policyKitConf string) error {
+	return removeExpiredGrants(ctx, sudoersConf, policyKitConf, time.Now())
+}
+
+// removeExpiredGrants removes the temporary administrators expired at now from sudoersConf, the sudoers group files
+// next to it, and policyKitConf. Missing files are ignored.
+func removeExpiredGrants(ctx context.Context, sudoersConf, policyKitConf string, now time.Time) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't remove expired privilege grants"))
+
+	groupFiles, err := installedSudoersFiles(filepath.Dir(sudoersConf), sudoersGroupPrefix)
+	if err != nil {
+		return err
+	}
+
+	// Sudoers: remove the rules following an expired annotation.
+	for _, p := range append([]string{sudoersConf}, groupFiles...) {
+		if err := rewriteFile(ctx, p, func(lines []string) []string {
+			var kept []string
+			for i := 0; i < len(lines); i++ {
+				if expiry, _, ok := parseExpiryComment(ctx, lines[i]); ok {
+					if !now.Before(expiry) {
+						log.Infof(ctx, "Removing expired sudoers rule %q", strings.TrimSpace(lines[min(i+1, len(lines)-1)]))
+						i++
+						continue
+					}
+				}
+				kept = append(kept, lines[i])
+			}
+			return kept
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Polkit: remove the expired identities from the administrators list.
+	return rewriteFile(ctx, policyKitConf, func(lines []string) []string {
+		expired := make(map[string]struct{})
+		var kept []string
+		for _, l := range lines {
+			if expiry, id, ok := parseExpiryComment(ctx, l); ok && !now.Before(expiry) {
+				log.Infof(ctx, "Removing expired polkit administrator %q", id)
+				expired[id] = struct{}{}
+				continue
+			}
+			kept = append(kept, l)
+		}
+		for i, l := range kept {
+			if ids, found := strings.CutPrefix(l, "AdminIdentities="); found {
+				kept[i] = "AdminIdentities=" + removeIdentities(ids, expired)
+			}
+		}
+		return kept
+	})
+}
+
+// parseExpiryComment returns the expiry time, and the polkit identity if any, of an expiry annotation.
+// ok is false if line is not a valid annotation.
+func parseExpiryComment(ctx context.Context, line string) (expiry time.Time, polkitID string, ok bool) {
+	v, found := strings.CutPrefix(line, expiryMarker)
+	if !found {
+		return time.Time{}, "", false
+	}
+	t, polkitID, _ := strings.Cut(v, " ")
+	expiry, err := time.Parse(time.RFC3339, t)
+	if err != nil {
+		log.Warningf(ctx, "Ignoring invalid expiry annotation %q: %v", line, err)
+		return time.Time{}, "", false
+	}
+	return expiry, polkitID, true
+}
+
+// rewriteFile atomically replaces the content of path with the lines returned by transform, keeping its
+// permissions, unless it was modified locally and is preserved. It does nothing if path doesn't exist or is
+// unchanged.
+func rewriteFile(ctx context.Context, path string, transform func([]string) []string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't update %s", path))
+
+	fi, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	newContent := strings.Join(transform(lines), "\n") + "\n"
+	if newContent == string(content) {
+		return nil
+	}
+
+	_, err = tamper.Write(ctx, "privilege", path, []byte(newContent), fi.Mode().Perm())
+	return err
+}
@@ -0,0 +1,48 @@
+package privilege
+
+import (
+	"os/user"
+	"time"
+)
+
+// WithTimeNow allows to mock the current time, from which temporary grants expire.
+func WithTimeNow(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// WithUserLookup allows to mock system user lookup.
+func WithUserLookup(userLookup func(string) (*user.User, error)) Option {
+	return func(o *options) {
+		o.userLookup = userLookup
+	}
+}
+
+// WithGroupLookup allows to mock system group lookup.
+func WithGroupLookup(groupLookup func(string) (*user.Group, error)) Option {
+	return func(o *options) {
+		o.groupLookup = groupLookup
+	}
+}
+
+// WithUserGroups allows to mock the lookup of the groups of a user.
+func WithUserGroups(userGroups func(string) ([]string, error)) Option {
+	return func(o *options) {
+		o.userGroups = userGroups
+	}
+}
+
+// WithVisudoCmd allows to mock the command validating the sudoers snippets. The snippet path is appended to it.
+func WithVisudoCmd(cmd []string) Option {
+	return func(o *options) {
+		o.visudoCmd = cmd
+	}
+}
+
+// WithProbeDir allows to mock the check that a file can be created in the sudoers directory.
+func WithProbeDir(probe func(string) error) Option {
+	return func(o *options) {
+		o.probeDir = probe
+	}
+}
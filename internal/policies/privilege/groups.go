@@ -0,0 +1,55 @@
+package privilege
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/decorate"
+)
+
+// sudoersGroupPrefix prefixes the name of the sudoers files granting privileges to a client administrators group,
+// when writing one file per group. It is ordered after the main sudoers file and before the sudoers snippets.
+const sudoersGroupPrefix = adsysBaseConfName + "-group-"
+
+// sudoersGroupFileName returns the name of the sudoers file granting privileges to group. Groups only differing
+// by the characters sudo doesn't accept in file names share the same file.
+func sudoersGroupFileName(group string) string {
+	return sudoersGroupPrefix + sudoersSnippetNameRe.ReplaceAllString(group, "_")
+}
+
+// checkSudoersGroupFiles validates the content of the sudoers group files, mapped by their name, with visudo.
+func (m *Manager) checkSudoersGroupFiles(ctx context.Context, files map[string]string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't validate sudoers group files"))
+
+	if len(files) == 0 {
+		return nil
+	}
+
+	tmpdir, err := os.MkdirTemp("", "adsys-sudoers-groups-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		p := filepath.Join(tmpdir, name)
+		if err := os.WriteFile(p, []byte(files[name]), 0600); err != nil {
+			return err
+		}
+		if err := m.checkSudoers(ctx, p); err != nil {
+			return errors.New(gotext.Get("invalid sudoers file %s: %v", name, err))
+		}
+	}
+
+	return nil
+}
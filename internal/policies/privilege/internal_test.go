@@ -2,10 +2,19 @@ package privilege
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/termie/go-shutil"
+	"github.com/ubuntu/adsys/internal/consts"
+	"github.com/ubuntu/adsys/internal/testutils"
 )
 
 func TestSplitAndNormalizeUsersAndGroups(t *testing.T) {
@@ -56,7 +65,7 @@ func TestSplitAndNormalizeUsersAndGroups(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			got := splitAndNormalizeUsersAndGroups(context.Background(), tc.input)
+			got := splitAndNormalizeUsersAndGroups(context.Background(), "client-admins", tc.input)
 			assert.Equal(t, tc.want, got, "splitAndNormalizeUsersAndGroups returned expected value")
 		})
 	}
@@ -102,3 +111,253 @@ func TestGetSystemPolkitAdminIdentities(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitClientAdmins(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input string
+
+		want []clientAdmin
+	}{
+		"Simple one value":                        {input: "user@domain", want: []clientAdmin{{name: "user@domain"}}},
+		"Value with runas user":                   {input: "user@domain=(svcacct)", want: []clientAdmin{{name: "user@domain", runAs: "svcacct"}}},
+		"Value with runas user and group":         {input: "%group@domain=(svcacct:svcgroup)", want: []clientAdmin{{name: "%group@domain", runAs: "svcacct:svcgroup"}}},
+		"Spaces around runas are trimmed":         {input: "user@domain =( svcacct ) ", want: []clientAdmin{{name: "user@domain", runAs: "svcacct"}}},
+		"Multiple values with and without":        {input: "user1@domain=(svcacct),user2@domain\nuser3@domain=(:svcgroup)", want: []clientAdmin{{name: "user1@domain", runAs: "svcacct"}, {name: "user2@domain"}, {name: "user3@domain", runAs: ":svcgroup"}}},
+		"Invalid characters are still normalized": {input: `domain\user=(svcacct)`, want: []clientAdmin{{name: "user@domain", runAs: "svcacct"}}},
+		"Unterminated runas is part of the name":  {input: "user@domain=(svcacct", want: []clientAdmin{{name: "user@domain(svcacct"}}},
+		"Empty runas means no restriction":        {input: "user@domain=()", want: []clientAdmin{{name: "user@domain"}}},
+		"Empty values are stripped":               {input: ",=(svcacct),", want: nil},
+
+		// time to live
+		"Value with time to live":                       {input: "[ttl=4h]user@domain", want: []clientAdmin{{name: "user@domain", ttl: "4h"}}},
+		"Value with time to live and runas":             {input: "[ttl=4h]user@domain=(svcacct)", want: []clientAdmin{{name: "user@domain", runAs: "svcacct", ttl: "4h"}}},
+		"Spaces around time to live are trimmed":        {input: " [ttl= 30m ] %group@domain", want: []clientAdmin{{name: "%group@domain", ttl: "30m"}}},
+		"Time to live only applies to its value":        {input: "[ttl=4h]user1@domain,user2@domain", want: []clientAdmin{{name: "user1@domain", ttl: "4h"}, {name: "user2@domain"}}},
+		"Unterminated time to live is part of the name": {input: "[ttl=4huser@domain", want: []clientAdmin{{name: "ttl4huser@domain"}}},
+
+		// host filter
+		"Value with host filter":                       {input: "[host=web-*]user@domain", want: []clientAdmin{{name: "user@domain", host: "web-*"}}},
+		"Value with host filter and time to live":      {input: "[host=web-*][ttl=4h]user@domain", want: []clientAdmin{{name: "user@domain", ttl: "4h", host: "web-*"}}},
+		"Value with time to live and host filter":      {input: "[ttl=4h] [host=web-*] %group@domain=(svcacct)", want: []clientAdmin{{name: "%group@domain", runAs: "svcacct", ttl: "4h", host: "web-*"}}},
+		"Host filter only applies to its value":        {input: "[host=web-*]user1@domain,user2@domain", want: []clientAdmin{{name: "user1@domain", host: "web-*"}, {name: "user2@domain"}}},
+		"Unterminated host filter is part of the name": {input: "[host=webuser@domain", want: []clientAdmin{{name: "hostwebuser@domain"}}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := splitClientAdmins(context.Background(), tc.input)
+			assert.Equal(t, tc.want, got, "splitClientAdmins returned expected value")
+		})
+	}
+}
+
+func TestDetectSudoersDir(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		sudoersConf string
+
+		want string
+	}{
+		"Detect #includedir directive":       {sudoersConf: "includedir-hash", want: "/etc/custom-sudoers.d"},
+		"Detect @includedir directive":       {sudoersConf: "includedir-at", want: "/etc/custom-sudoers.d"},
+		"Last directive takes precedence":    {sudoersConf: "multiple-includedirs", want: "/etc/last-sudoers.d"},
+		"Relative directory to sudoers file": {sudoersConf: "relative-includedir", want: "testdata/sudoers/relative-includedir/sudoers.d"},
+		"Quoted directory":                   {sudoersConf: "quoted-includedir", want: "/etc/custom sudoers.d"},
+
+		// Fallbacks
+		"Default directory without directive":        {sudoersConf: "no-includedir", want: consts.DefaultSudoersDir},
+		"Default directory if sudoers doesn't exist": {sudoersConf: "doesnotexist", want: consts.DefaultSudoersDir},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := detectSudoersDir(context.Background(), filepath.Join("testdata", "sudoers", tc.sudoersConf, "sudoers"))
+			assert.Equal(t, tc.want, got, "detectSudoersDir returned expected value")
+		})
+	}
+}
+
+func TestPolkitRulesFilesOrder(t *testing.T) {
+	t.Parallel()
+
+	// polkit reads the rules files in the lexical order of their names, whatever their directory.
+	files := []string{"50-default.rules", "49-other-tool.rules", polkitAdminRulesName, "10-other-tool.rules", polkitRulesName}
+	slices.Sort(files)
+
+	require.Equal(t, []string{"10-adsys-privilege-enforcement.rules", "10-other-tool.rules", "20-adsys-privilege-admins.rules", "49-other-tool.rules", "50-default.rules"},
+		files, "adsys rules should be read before the distribution default ones, and the admin ones after other tools ordered before them")
+}
+
+func TestSudoersConflicts(t *testing.T) {
+	t.Parallel()
+
+	managed := []string{"%admin", "%sudo", "alice@domain.com", "%group@domain.com", "user name@domain.com", "+netgroup"}
+	dir := filepath.Join("testdata", "sudoers-conflicts", "sudoers.d")
+
+	tests := map[string]struct {
+		sudoersDir string
+		identities []string
+
+		want []sudoersConflict
+	}{
+		"Detect conflicting local rules": {sudoersDir: dir, identities: managed, want: []sudoersConflict{
+			{file: filepath.Join(dir, "local-admins"), line: 5, identity: "%sudo"},
+			{file: filepath.Join(dir, "local-admins"), line: 6, identity: "alice@domain.com"},
+			{file: filepath.Join(dir, "local-deny"), line: 1, identity: "%group@domain.com"},
+			{file: filepath.Join(dir, "local-deny"), line: 3, identity: "user name@domain.com"},
+			{file: filepath.Join(dir, "local-deny"), line: 4, identity: "+netgroup"},
+		}},
+		"No conflict with other identities": {sudoersDir: dir, identities: []string{"bob@domain.com"}},
+		"No managed identities":             {sudoersDir: dir},
+		"Missing sudoers directory":         {sudoersDir: filepath.Join("testdata", "doesnotexist"), identities: managed},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := sudoersConflicts(tc.sudoersDir, tc.identities)
+			require.NoError(t, err, "sudoersConflicts failed but shouldn't have")
+			assert.Equal(t, tc.want, got, "sudoersConflicts returned unexpected conflicts")
+		})
+	}
+}
+
+func TestRemoveExpiredGrants(t *testing.T) {
+	t.Parallel()
+
+	// Grants in testdata expire at 10:30 and 14:00.
+	tests := map[string]struct {
+		now          time.Time
+		missingFiles bool
+	}{
+		"Keep grants not expired yet":    {now: time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)},
+		"Remove grants expired":          {now: time.Date(2024, time.March, 1, 10, 30, 0, 0, time.UTC)},
+		"Remove all grants once expired": {now: time.Date(2024, time.March, 1, 15, 0, 0, 0, time.UTC)},
+		"Expiry is compared in UTC":      {now: time.Date(2024, time.March, 1, 11, 0, 0, 0, time.FixedZone("UTC+1", 3600))},
+		"Missing files are ignored":      {now: time.Date(2024, time.March, 1, 15, 0, 0, 0, time.UTC), missingFiles: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dest := t.TempDir()
+			if !tc.missingFiles {
+				require.NoError(t, shutil.CopyTree(filepath.Join("testdata", "expiring-grants"), filepath.Join(dest, "etc"), &shutil.CopyTreeOptions{Symlinks: true, CopyFunction: shutil.Copy}),
+					"Setup: can't create initial files")
+			}
+
+			err := removeExpiredGrants(context.Background(), filepath.Join(dest, "etc", "sudoers"), filepath.Join(dest, "etc", "polkit.conf"), tc.now)
+			require.NoError(t, err, "removeExpiredGrants failed but shouldn't have")
+
+			if tc.missingFiles {
+				_, err := os.Stat(filepath.Join(dest, "etc"))
+				require.ErrorIs(t, err, os.ErrNotExist, "removeExpiredGrants should not have created any file")
+				return
+			}
+			testutils.CompareTreesWithFiltering(t, filepath.Join(dest, "etc"), testutils.GoldenPath(t), testutils.UpdateEnabled())
+		})
+	}
+}
+
+func TestCachedClientAdmins(t *testing.T) {
+	t.Parallel()
+
+	m := NewWithDirs("", "")
+
+	got := m.cachedClientAdmins(context.Background(), "user1@domain,%group@domain=(svcacct)")
+	want := []clientAdmin{{name: "user1@domain"}, {name: "%group@domain", runAs: "svcacct"}}
+	require.Equal(t, want, got, "cachedClientAdmins should return the split value")
+
+	got = m.cachedClientAdmins(context.Background(), "user1@domain,%group@domain=(svcacct)")
+	require.Equal(t, want, got, "cachedClientAdmins should return the same value for identical input")
+
+	got = m.cachedClientAdmins(context.Background(), "[ttl=1h]user2@domain")
+	require.Equal(t, []clientAdmin{{name: "user2@domain", ttl: "1h"}}, got, "cachedClientAdmins should split again a changed input")
+
+	got = m.cachedClientAdmins(context.Background(), "")
+	require.Empty(t, got, "cachedClientAdmins should not return a cached value for an empty input")
+}
+
+func BenchmarkCachedClientAdmins(b *testing.B) {
+	var admins []string
+	for i := range 2000 {
+		admins = append(admins, fmt.Sprintf("user%d@domain.com", i), fmt.Sprintf("%%group%d@domain.com=(svcacct)", i))
+	}
+	value := strings.Join(admins, "\n")
+
+	b.Run("Without cache", func(b *testing.B) {
+		for range b.N {
+			splitClientAdmins(context.Background(), value)
+		}
+	})
+
+	b.Run("With cache on identical applies", func(b *testing.B) {
+		m := NewWithDirs("", "")
+		for range b.N {
+			m.cachedClientAdmins(context.Background(), value)
+		}
+	})
+}
+
+func TestApplyPolicyLock(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cancelled    bool
+		timeout      time.Duration
+		releaseAfter time.Duration
+
+		wantErr bool
+	}{
+		"Apply once the lock is released": {timeout: 5 * time.Second, releaseAfter: 50 * time.Millisecond},
+
+		"Error on cancelled context while the lock is held": {cancelled: true, wantErr: true},
+		"Error on deadline while the lock is held":          {timeout: 50 * time.Millisecond, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempEtc := t.TempDir()
+			m := NewWithDirs(filepath.Join(tempEtc, "sudoers.d"), filepath.Join(tempEtc, "polkit-1"))
+
+			// Hold the lock as another apply would
+			m.privilegeMu <- struct{}{}
+			if tc.releaseAfter > 0 {
+				go func() {
+					time.Sleep(tc.releaseAfter)
+					m.unlock()
+				}()
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+			if tc.cancelled {
+				cancel()
+			}
+
+			err := m.ApplyPolicy(ctx, "ubuntu", true, nil, nil)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+				require.Len(t, m.privilegeMu, 1, "Lock should still be held by the other apply")
+				return
+			}
+			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			require.Empty(t, m.privilegeMu, "Lock should have been released after applying")
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package privilege
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	"github.com/ubuntu/adsys/internal/telemetry"
+)
+
+// Metrics of the grants applied by the privilege policy.
+const (
+	clientAdminsMetric   = "adsys.privilege.client_admins"
+	localAdminsMetric    = "adsys.privilege.local_admins_allowed"
+	attrPrivilegeChanged = "adsys.policy.changed"
+)
+
+// exportGrants exports the number of client administrators granted by the applied policy r, and whether the local
+// administrators keep their rights. changed is true if the apply changed the privilege files.
+func (m *Manager) exportGrants(ctx context.Context, r rendered, changed bool) {
+	// The same administrator can be granted several times, for instance to run as different users.
+	admins := slices.Clone(r.clientAdmins)
+	slices.Sort(admins)
+	admins = slices.Compact(admins)
+
+	var allowLocalAdmins int64
+	if r.allowLocalAdmins {
+		allowLocalAdmins = 1
+	}
+
+	attrs := map[string]string{attrPrivilegeChanged: strconv.FormatBool(changed)}
+	m.telemetry.ExportGauges(ctx, []telemetry.Gauge{
+		{Name: clientAdminsMetric, Description: "Client administrators granted by the privilege policy.", Unit: "1", Attributes: attrs, Value: int64(len(admins))},
+		{Name: localAdminsMetric, Description: "Whether the local administrators keep their rights.", Unit: "1", Attributes: attrs, Value: allowLocalAdmins},
+	})
+}
@@ -6,13 +6,38 @@
 //   - /etc/sudoers.d/99-adsys-privilege-enforcement
 //   - /etc/polkit-1/localauthority.conf.d/99-adsys-privilege-enforcement
 //
+// Specific polkit actions can also be granted to, or denied for, users and groups without making them
+// administrators, with rules written to /etc/polkit-1/rules.d/10-adsys-privilege-enforcement.rules.
+// Named action groups, like printer or network administrators, can be granted all the actions of some namespaces,
+// like org.cups.*, in the same file.
+// Optionally, the polkit administrators can also be written, as an admin rule, to
+// /etc/polkit-1/rules.d/20-adsys-privilege-admins.rules for the polkit versions only reading JavaScript rules.
+//
+// Vetted sudoers snippets of the SYSVOL can also be referenced by the policy. They are validated with visudo
+// and installed verbatim next to the sudoers file, as 99-adsys-privilege-enforcement-snippet-<path>.
+//
+// Large sets of client administrators running as the same user and group can be grouped in a sudoers User_Alias,
+// defined with one administrator per line, and referenced by a single rule, so that the lines stay short.
+//
+// Optionally, the client administrators groups can be written to one sudoers file per group, named
+// 99-adsys-privilege-enforcement-group-<group>, instead of the main sudoers file. Each of them is validated with
+// visudo, and the files of the groups removed from the policy are deleted.
+//
+// Some local users, like a break-glass account, can be protected: when the policy denies local administrators, they
+// are explicitly allowed after the deny rules, and kept as polkit administrators.
+//
+// The other files of the sudoers directory can also be checked for rules referencing the users and groups managed by
+// the policy, as they may grant what the policy denies, or the opposite. A warning is logged for each of them, but
+// they are never modified.
+//
 // This is an all or nothing type of policy and, therefore, requires a lot of attention during setup.
 // If the policy is setup improperly, users could end up with too much (or too little) privilege,
 // which could compromise the safety and/or usability of the machine until the policy gets updated.
 // If the policy is set without any value (or it's disabled) the files are removed and the default
 // privilege configuration is restored.
 // Should the manager fail to create the files with the requested values, it will return an error and
-// authentication will be prevented.
+// authentication will be prevented. If the sudoers directory is immutable or read-only, no file is changed, so that
+// the sudo and polkit configurations stay consistent.
 package privilege
 
 import (
@@ -21,14 +46,27 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"os/user"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/leonelquinteros/gotext"
 	"github.com/ubuntu/adsys/internal/consts"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/cooldown"
 	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/integrity"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+	"github.com/ubuntu/adsys/internal/telemetry"
 	"github.com/ubuntu/decorate"
 	"gopkg.in/ini.v1"
 )
@@ -50,22 +88,279 @@ import (
 
 const adsysBaseConfName = "99-adsys-privilege-enforcement"
 
+// sudoersAdminsAlias is the name of the sudoers User_Alias of the client administrators. The next aliases, for other
+// users and groups to run as, are suffixed with their number.
+const sudoersAdminsAlias = "ADSYS_CLIENT_ADMINS"
+
+// polkitRulesName is the name of the polkit rules file. polkit stops at the first rule returning a result, in
+// the lexical order of the files, so it is ordered early to take precedence over the distribution rules.
+const polkitRulesName = "10-adsys-privilege-enforcement.rules"
+
+// polkitAdminRulesName is the name of the optional polkit rules file setting the administrators. Only the first
+// admin rule returning identities is used, so it is ordered before the distribution default (50-default.rules),
+// while the admin rules of other tools ordered before it still take precedence.
+const polkitAdminRulesName = "20-adsys-privilege-admins.rules"
+
+// polkitActionIDRe matches the valid polkit action ids, in reverse domain name notation.
+var polkitActionIDRe = regexp.MustCompile(`^[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+$`)
+
+// polkitResults maps the results allowed in polkit rules to their polkit value.
+var polkitResults = map[string]string{
+	"yes":        "polkit.Result.YES",
+	"auth_admin": "polkit.Result.AUTH_ADMIN",
+	"no":         "polkit.Result.NO",
+}
+
+// polkitActionPrefixRe matches the valid action namespaces of polkit action groups, like org.cups.*. They have at
+// least 2 components, so that a group can't be granted all the actions of a top-level domain.
+var polkitActionPrefixRe = regexp.MustCompile(`^[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+\.\*$`)
+
+// polkitActionGroupNameRe matches the valid names of polkit action groups.
+var polkitActionGroupNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// polkitActionGroupResults maps the results allowed for polkit action groups to their polkit value. Members of an
+// action group are never denied anything, and only have to authenticate as themselves at most.
+var polkitActionGroupResults = map[string]string{
+	"yes":       "polkit.Result.YES",
+	"auth_self": "polkit.Result.AUTH_SELF",
+}
+
 // Manager prevents running multiple privilege update process in parallel while parsing policy in ApplyPolicy.
 type Manager struct {
 	sudoersDir   string
+	sudoersConf  string
 	policyKitDir string
+
+	allowAdminLockout bool
+	reportOnly        bool
+	sudoersPerGroup   bool
+	checkConflicts    bool
+	polkitAdminRules  bool
+	// sudoersAliasThreshold is the number of client administrators from which they are grouped in an alias.
+	sudoersAliasThreshold int
+
+	systemUnitDir string
+	systemdCaller systemdCaller
+
+	// hostnames are the names of the machine matched by the host filters of client administrators.
+	hostnames []string
+	// protectedUsers are the local users keeping their administrator rights when local administrators are denied.
+	protectedUsers []string
+
+	userLookup  func(string) (*user.User, error)
+	groupLookup func(string) (*user.Group, error)
+	userGroups  func(string) ([]string, error)
+	now         func() time.Time
+	visudoCmd   []string
+	// probeDir checks that a file can be created in a directory.
+	probeDir func(string) error
+
+	clientAdminsCache clientAdminsCache
+
+	// cooldown suppresses the rewrites of the privilege files happening too soon after the previous ones.
+	cooldown *cooldown.Tracker
+	// telemetry exports the grants applied by the policy.
+	telemetry *telemetry.Exporter
+
+	// privilegeMu prevents concurrent applies of the policy. It is a channel of size 1 instead of a mutex so
+	// that waiting for it can be cancelled.
+	privilegeMu chan struct{}
+}
+
+// clientAdminsCache holds the client administrators split from the last client-admins entry value, so that
+// the value, identical on most refreshes, is only normalized again once it changed.
+type clientAdminsCache struct {
+	mu     sync.Mutex
+	value  *string
+	admins []clientAdmin
+	// ignored are the names changed or dropped while normalizing value, reported again on each refresh.
+	ignored []ignored.Entry
+}
+
+// systemdCaller is the interface to interact with systemd.
+type systemdCaller interface {
+	StartUnit(context.Context, string) error
+	StopUnit(context.Context, string) error
+	EnableUnit(context.Context, string) error
+	DisableUnit(context.Context, string) error
+	DaemonReload(context.Context) error
+}
+
+type options struct {
+	sudoersConf       string
+	allowAdminLockout bool
+	reportOnly        bool
+	sudoersPerGroup   bool
+	checkConflicts    bool
+	polkitAdminRules  bool
+	aliasThreshold    int
+	systemUnitDir     string
+	systemdCaller     systemdCaller
+	hostnames         []string
+	protectedUsers    []string
+	userLookup        func(string) (*user.User, error)
+	groupLookup       func(string) (*user.Group, error)
+	userGroups        func(string) ([]string, error)
+	now               func() time.Time
+	visudoCmd         []string
+	probeDir          func(string) error
+	cooldown          *cooldown.Tracker
+	telemetry         *telemetry.Exporter
+}
+
+// Option reprents an optional function to change the privilege manager.
+type Option func(*options)
+
+// WithAllowAdminLockout allows writing a polkit configuration without any administrator.
+func WithAllowAdminLockout(allow bool) Option {
+	return func(o *options) {
+		o.allowAdminLockout = allow
+	}
+}
+
+// WithReportOnly only logs, on each apply, the privilege configuration the policy would set, without
+// changing any sudo or polkit file.
+func WithReportOnly(reportOnly bool) Option {
+	return func(o *options) {
+		o.reportOnly = reportOnly
+	}
+}
+
+// WithSudoersPerGroup writes the sudoers rules of each client administrators group to its own file, instead of
+// the main sudoers file.
+func WithSudoersPerGroup(perGroup bool) Option {
+	return func(o *options) {
+		o.sudoersPerGroup = perGroup
+	}
+}
+
+// WithSudoersConflictsCheck warns, on each apply, about the rules of the other files of the sudoers directory
+// referencing the users and groups managed by the policy. Those files are never modified.
+func WithSudoersConflictsCheck(check bool) Option {
+	return func(o *options) {
+		o.checkConflicts = check
+	}
+}
+
+// WithPolkitAdminRules also writes the polkit administrators to a rules file, for the polkit versions reading
+// rules.d instead of localauthority.conf.d. The rules file only sets the administrators and leaves the result of
+// the actions to the other rules.
+func WithPolkitAdminRules(enabled bool) Option {
+	return func(o *options) {
+		o.polkitAdminRules = enabled
+	}
+}
+
+// WithSudoersAliasThreshold groups the client administrators running as the same user and group in a sudoers
+// User_Alias once they are at least threshold. 0 or less always writes one rule per administrator.
+func WithSudoersAliasThreshold(threshold int) Option {
+	return func(o *options) {
+		o.aliasThreshold = threshold
+	}
+}
+
+// WithSystemUnitDir specifies a personalized directory for the systemd units removing expired grants.
+func WithSystemUnitDir(p string) Option {
+	return func(o *options) {
+		o.systemUnitDir = p
+	}
+}
+
+// WithSystemdCaller specifies the systemd caller used to schedule the removal of expired grants.
+func WithSystemdCaller(c systemdCaller) Option {
+	return func(o *options) {
+		o.systemdCaller = c
+	}
+}
+
+// WithHostnames specifies the names of the machine, like its hostname and FQDN, matched by the host filters of
+// client administrators. It defaults to the system hostname.
+func WithHostnames(names ...string) Option {
+	return func(o *options) {
+		o.hostnames = names
+	}
+}
+
+// WithProtectedUsers specifies local users, like a break-glass account, which keep their sudo and polkit
+// administrator rights when the policy denies local administrators.
+func WithProtectedUsers(users ...string) Option {
+	return func(o *options) {
+		o.protectedUsers = users
+	}
+}
+
+// WithCooldown specifies the tracker suppressing the rewrites of the privilege files within its cooldown.
+func WithCooldown(t *cooldown.Tracker) Option {
+	return func(o *options) {
+		o.cooldown = t
+	}
+}
+
+// WithTelemetry specifies the exporter of the metrics of the grants applied by the policy.
+func WithTelemetry(e *telemetry.Exporter) Option {
+	return func(o *options) {
+		o.telemetry = e
+	}
 }
 
 // NewWithDirs creates a manager with a specific root directory.
-func NewWithDirs(sudoersDir, policyKitDir string) *Manager {
+// If sudoersDir is empty, the include directory of the main sudoers configuration is used.
+func NewWithDirs(sudoersDir, policyKitDir string, opts ...Option) *Manager {
+	// defaults
+	args := options{
+		sudoersConf:   consts.DefaultSudoersConf,
+		systemUnitDir: consts.DefaultSystemUnitDir,
+		userLookup:    user.Lookup,
+		groupLookup:   user.LookupGroup,
+		userGroups:    lookupUserGroups,
+		now:           time.Now,
+		visudoCmd:     []string{"visudo", "-c", "-q", "-f"},
+		probeDir:      probeWritable,
+		cooldown:      cooldown.New("", 0),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		args.hostnames = []string{hostname}
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
 	return &Manager{
 		sudoersDir:   sudoersDir,
+		sudoersConf:  args.sudoersConf,
 		policyKitDir: policyKitDir,
+
+		allowAdminLockout: args.allowAdminLockout,
+		reportOnly:        args.reportOnly,
+		sudoersPerGroup:   args.sudoersPerGroup,
+		checkConflicts:    args.checkConflicts,
+		polkitAdminRules:  args.polkitAdminRules,
+
+		sudoersAliasThreshold: args.aliasThreshold,
+
+		systemUnitDir: args.systemUnitDir,
+		systemdCaller: args.systemdCaller,
+
+		hostnames:      args.hostnames,
+		protectedUsers: args.protectedUsers,
+
+		userLookup:  args.userLookup,
+		groupLookup: args.groupLookup,
+		userGroups:  args.userGroups,
+		now:         args.now,
+		visudoCmd:   args.visudoCmd,
+		probeDir:    args.probeDir,
+		cooldown:    args.cooldown,
+		telemetry:   args.telemetry,
+
+		privilegeMu: make(chan struct{}, 1),
 	}
 }
 
 // ApplyPolicy generates a privilege policy based on a list of entries.
-func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+// The sudoers snippets referenced by the policy are fetched with assetsDumper.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry, assetsDumper AssetsDumper) (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't apply privilege policy to %s", objectName))
 
 	// We only have privilege escalation on computers.
@@ -73,58 +368,168 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		return nil
 	}
 
-	sudoersDir := m.sudoersDir
-	if sudoersDir == "" {
-		sudoersDir = consts.DefaultSudoersDir
-	}
-	policyKitDir := m.policyKitDir
-	if policyKitDir == "" {
-		policyKitDir = consts.DefaultPolicyKitDir
+	if err := m.lock(ctx); err != nil {
+		return err
 	}
-	sudoersConf := filepath.Join(sudoersDir, adsysBaseConfName)
-	policyKitConf := filepath.Join(policyKitDir, "localauthority.conf.d", adsysBaseConfName+".conf")
+	defer m.unlock()
+
+	sudoersConf, policyKitConf, policyKitRules, policyKitAdminRules := m.managedPaths(ctx)
 
 	log.Debugf(ctx, "Applying privilege policy to %s", objectName)
 
 	// We don’t create empty files if there is no entries. Still remove any previous version.
 	if len(entries) == 0 {
-		if err := os.Remove(sudoersConf); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		if m.reportOnly {
+			reportPrivilegeFile(ctx, sudoersConf, nil)
+			reportPrivilegeFile(ctx, policyKitConf, nil)
+			reportPrivilegeFile(ctx, policyKitRules, nil)
+			reportPrivilegeFile(ctx, policyKitAdminRules, nil)
+			reportSudoersFiles(ctx, filepath.Dir(sudoersConf), sudoersGroupPrefix, nil)
+			reportSudoersFiles(ctx, filepath.Dir(sudoersConf), sudoersSnippetPrefix, nil)
+			return nil
+		}
+		// Only fail on a read-only sudoers directory if there is anything to remove from it.
+		installed, err := installedSudoersFiles(filepath.Dir(sudoersConf), adsysBaseConfName)
+		if err != nil {
 			return err
+		} else if len(installed) > 0 {
+			if err := m.checkSudoersDirWritable(filepath.Dir(sudoersConf)); err != nil {
+				return err
+			}
+		}
+		changed := len(installed) > 0
+		for _, p := range []string{sudoersConf, policyKitConf, policyKitRules, policyKitAdminRules} {
+			removed, err := tamper.Remove(ctx, "privilege", p)
+			if err != nil {
+				return err
+			}
+			changed = changed || removed
 		}
-		if err := os.Remove(policyKitConf); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		if err := m.installSudoersFiles(ctx, filepath.Dir(sudoersConf), sudoersGroupPrefix, nil); err != nil {
 			return err
 		}
+		if err := m.installSudoersFiles(ctx, filepath.Dir(sudoersConf), sudoersSnippetPrefix, nil); err != nil {
+			return err
+		}
+		if err := m.scheduleGrantsExpiry(ctx, nil, sudoersConf, policyKitConf); err != nil {
+			return err
+		}
+		m.exportGrants(ctx, rendered{allowLocalAdmins: true}, changed)
 		return nil
 	}
 
-	// Create our temp files and parent directories
-	// nolint:gosec // G301 match distribution permission
-	if err := os.MkdirAll(filepath.Dir(sudoersConf), 0755); err != nil {
+	r, err := m.render(ctx, entries, assetsDumper)
+	if err != nil {
 		return err
 	}
-	// nolint:gosec // G302 match distribution permission
-	sudoersF, err := os.OpenFile(sudoersConf+".new", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0440)
+
+	if m.checkConflicts {
+		warnSudoersConflicts(ctx, filepath.Dir(sudoersConf), r.sudoersIdentities)
+	}
+
+	if m.reportOnly {
+		reportPrivilegeFile(ctx, sudoersConf, &r.sudoers)
+		reportPrivilegeFile(ctx, policyKitConf, &r.policyKitConf)
+		if r.polkitRules == "" {
+			reportPrivilegeFile(ctx, policyKitRules, nil)
+		} else {
+			reportPrivilegeFile(ctx, policyKitRules, &r.polkitRules)
+		}
+		if r.polkitAdminRules == "" {
+			reportPrivilegeFile(ctx, policyKitAdminRules, nil)
+		} else {
+			reportPrivilegeFile(ctx, policyKitAdminRules, &r.polkitAdminRules)
+		}
+		reportSudoersFiles(ctx, filepath.Dir(sudoersConf), sudoersGroupPrefix, r.sudoersGroups)
+		reportSudoersFiles(ctx, filepath.Dir(sudoersConf), sudoersSnippetPrefix, r.sudoersSnippets)
+		if len(r.expiries) > 0 {
+			log.Infof(ctx, "Report-only privilege mode: temporary administrators would expire at %v", r.expiries)
+		}
+		return nil
+	}
+
+	// Don't change the polkit files either if the sudoers ones can't be written, to keep both consistent.
+	if err := m.checkSudoersDirWritable(filepath.Dir(sudoersConf)); err != nil {
+		return err
+	}
+
+	files, removing, err := privilegeFiles(filepath.Dir(sudoersConf), sudoersConf, policyKitConf, policyKitRules, policyKitAdminRules, r)
 	if err != nil {
 		return err
 	}
-	defer sudoersF.Close()
-	// nolint:gosec // G301 match distribution permission
-	if err := os.MkdirAll(filepath.Dir(policyKitConf), 0755); err != nil {
+	changed := removing || !unchangedOnDisk(files)
+
+	// Within the cooldown, the files are only left untouched together, if none of them changes or is removed.
+	if !removing && m.cooldown.Suppressed(ctx, files) {
+		if err := m.scheduleGrantsExpiry(ctx, r.expiries, sudoersConf, policyKitConf); err != nil {
+			return err
+		}
+		m.exportGrants(ctx, r, false)
+		return nil
+	}
+
+	if err := m.writeFile(ctx, sudoersConf, r.sudoers, 0440); err != nil {
 		return err
 	}
-	// nolint:gosec // G302 match distribution permission
-	policyKitConfF, err := os.OpenFile(policyKitConf+".new", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
+	if err := m.writeFile(ctx, policyKitConf, r.policyKitConf, 0644); err != nil {
 		return err
 	}
-	defer policyKitConfF.Close()
+	if err := m.writePolkitRules(ctx, policyKitRules, r.polkitRules); err != nil {
+		return err
+	}
+	if err := m.writePolkitRules(ctx, policyKitAdminRules, r.polkitAdminRules); err != nil {
+		return err
+	}
+	if err := m.installSudoersFiles(ctx, filepath.Dir(sudoersConf), sudoersGroupPrefix, r.sudoersGroups); err != nil {
+		return err
+	}
+	if err := m.installSudoersFiles(ctx, filepath.Dir(sudoersConf), sudoersSnippetPrefix, r.sudoersSnippets); err != nil {
+		return err
+	}
+
+	if err := m.scheduleGrantsExpiry(ctx, r.expiries, sudoersConf, policyKitConf); err != nil {
+		return err
+	}
+	m.exportGrants(ctx, r, changed)
+	return nil
+}
+
+// rendered is the content of the privilege files generated from the entries of the policy.
+type rendered struct {
+	sudoers         string
+	policyKitConf   string
+	polkitRules     string
+	sudoersSnippets map[string]string
+	// sudoersGroups are the sudoers files of the client administrators groups, mapped by their name, when writing
+	// one file per group.
+	sudoersGroups map[string]string
+	// sudoersIdentities are the users, groups and netgroups the sudoers rules apply to.
+	sudoersIdentities []string
+	// expiries are the expiry times of the temporary grants.
+	expiries []time.Time
+	// polkitAdminRules is only set when the administrators are also written to a polkit rules file.
+	polkitAdminRules string
+	// clientAdmins are the client administrators granted by the policy.
+	clientAdmins []string
+	// allowLocalAdmins is true if the local administrators keep their rights.
+	allowLocalAdmins bool
+}
+
+// render generates the content of the sudoers and polkit files for a non empty list of entries, without
+// changing anything on disk. The sudoers snippets referenced by the policy are fetched with assetsDumper.
+func (m *Manager) render(ctx context.Context, entries []entry.Entry, assetsDumper AssetsDumper) (r rendered, err error) {
+	policyKitDir := m.policyKitDir
+	if policyKitDir == "" {
+		policyKitDir = consts.DefaultPolicyKitDir
+	}
 
 	systemPolkitAdmins, err := getSystemPolkitAdminIdentities(ctx, policyKitDir)
 	if err != nil {
-		return err
+		return r, err
 	}
 
-	// Parse our rules and write to temp files
+	// Parse our rules and generate the content of our files
+	var sudoers strings.Builder
 	var headerWritten bool
 	header := `# This file is managed by adsys.
 # Do not edit this file manually.
@@ -134,6 +539,10 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 
 	allowLocalAdmins := true
 	var polkitAdditionalUsersGroups []string
+	// Rules for specific actions are written before the action groups ones, as polkit stops at the first result.
+	var polkitActionRules, polkitActionGroupRules []string
+	// polkitExpiries are the expiry times of the temporary polkit administrators.
+	polkitExpiries := make(map[string]time.Time)
 
 	for _, entry := range entries {
 		var contentSudo string
@@ -145,75 +554,912 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		switch entry.Key {
 		case "allow-local-admins":
 			allowLocalAdmins = !entry.Disabled
+			// The policy has no value, but a DWORD one set by a registry preference can disallow local admins.
+			if allowLocalAdmins && strings.TrimSpace(entry.Value) != "" {
+				if allowLocalAdmins, err = entry.BoolValue(); err != nil {
+					return r, errors.New(gotext.Get("invalid value for allow-local-admins: %v", err))
+				}
+			}
 			if allowLocalAdmins {
 				continue
 			}
 			contentSudo += "%admin	ALL=(ALL) !ALL\n"
 			contentSudo += "%sudo	ALL=(ALL:ALL) !ALL\n"
+			r.sudoersIdentities = append(r.sudoersIdentities, "%admin", "%sudo")
+			// Protected users are allowed after the deny rules, as the last matching sudoers rule wins.
+			for _, u := range m.validProtectedUsers(ctx) {
+				contentSudo += fmt.Sprintf("\"%s\"	ALL=(ALL:ALL) ALL\n", u)
+				r.sudoersIdentities = append(r.sudoersIdentities, u)
+			}
 		case "client-admins":
 			if entry.Disabled {
 				continue
 			}
 
 			var polkitElem []string
-			for _, e := range splitAndNormalizeUsersAndGroups(ctx, entry.Value) {
-				contentSudo += fmt.Sprintf("\"%s\"	ALL=(ALL:ALL) ALL\n", e)
+			var adminRules []sudoersRule
+			granted := len(r.clientAdmins)
+			for _, admin := range m.cachedClientAdmins(ctx, entry.Value) {
+				if admin.host != "" {
+					matched, err := m.matchesHost(admin.host)
+					if err != nil {
+						return r, errors.New(gotext.Get("invalid host filter %q for %s", admin.host, admin.name))
+					}
+					if !matched {
+						log.Debugf(ctx, "Skipping client administrator %s as host filter %q doesn't match %s", admin.name, admin.host, strings.Join(m.hostnames, ", "))
+						continue
+					}
+				}
+
+				runAs := "ALL:ALL"
+				if admin.runAs != "" {
+					if runAs, err = m.resolveRunAs(admin.runAs); err != nil {
+						return r, err
+					}
+				}
+				e := admin.name
 				polkitID := fmt.Sprintf("unix-user:%s", e)
-				if strings.HasPrefix(e, "%") {
-					polkitID = fmt.Sprintf("unix-group:%s", strings.TrimPrefix(e, "%"))
+				sudoersID := fmt.Sprintf("\"%s\"", e)
+				var groupFile string
+				if group, isGroup := strings.CutPrefix(e, "%"); isGroup {
+					polkitID = fmt.Sprintf("unix-group:%s", group)
+					if m.sudoersPerGroup {
+						groupFile = sudoersGroupFileName(group)
+					}
+				}
+				// Netgroups are only recognized by sudo when not quoted. Their names are validated beforehand.
+				if netgroup, isNetgroup := strings.CutPrefix(e, "+"); isNetgroup {
+					polkitID = fmt.Sprintf("unix-netgroup:%s", netgroup)
+					sudoersID = e
+				}
+				var rule string
+				// Temporary grants expire relatively to the last time they were applied.
+				if admin.ttl != "" {
+					ttl, err := time.ParseDuration(admin.ttl)
+					if err != nil || ttl <= 0 {
+						return r, errors.New(gotext.Get("invalid time to live %q for %s", admin.ttl, e))
+					}
+					expiry := m.now().Add(ttl).UTC().Truncate(time.Second)
+					rule += expiryComment(expiry, "")
+					if admin.runAs == "" {
+						polkitExpiries[polkitID] = expiry
+					}
+					r.expiries = append(r.expiries, expiry)
+				}
+				rule += fmt.Sprintf("%s	ALL=(%s) ALL\n", sudoersID, runAs)
+				// A polkit administrator can act as root: the entries restricted to run as another user or group
+				// are only granted their sudo rule.
+				if admin.runAs == "" {
+					polkitElem = append(polkitElem, polkitID)
+				}
+				r.sudoersIdentities = append(r.sudoersIdentities, e)
+				r.clientAdmins = append(r.clientAdmins, e)
+
+				if groupFile == "" {
+					adminRules = append(adminRules, sudoersRule{id: sudoersID, runAs: runAs, rule: rule, temporary: admin.ttl != ""})
+					continue
+				}
+				if r.sudoersGroups == nil {
+					r.sudoersGroups = make(map[string]string)
 				}
-				polkitElem = append(polkitElem, polkitID)
+				if _, ok := r.sudoersGroups[groupFile]; !ok {
+					r.sudoersGroups[groupFile] = header
+				}
+				r.sudoersGroups[groupFile] += rule
 			}
-			if len(polkitElem) < 1 {
+			contentSudo += m.clientAdminsRules(adminRules)
+			if len(r.clientAdmins) == granted {
 				continue
 			}
 			polkitAdditionalUsersGroups = polkitElem
+		case "polkit-rules":
+			if !entry.Disabled {
+				if polkitActionRules, err = parsePolkitRules(ctx, entry.Value); err != nil {
+					return r, err
+				}
+			}
+			// Polkit rules are written to their own file
+			continue
+		case "polkit-action-groups":
+			if !entry.Disabled {
+				if polkitActionGroupRules, err = parsePolkitActionGroups(ctx, entry.Value); err != nil {
+					return r, err
+				}
+			}
+			// Polkit action groups are written with the polkit rules
+			continue
+		case "sudoers-snippets":
+			if !entry.Disabled {
+				if r.sudoersSnippets, err = m.fetchSudoersSnippets(ctx, entry.Value, assetsDumper); err != nil {
+					return r, err
+				}
+			}
+			// Sudoers snippets are installed as their own files
+			continue
 		}
 
-		// Write to our files
-		if _, err := sudoersF.WriteString(contentSudo + "\n"); err != nil {
-			return err
-		}
+		sudoers.WriteString(contentSudo + "\n")
 		headerWritten = true
 	}
+	r.sudoers = sudoers.String()
+	r.polkitRules = polkitRulesContent(append(polkitActionRules, polkitActionGroupRules...))
+
+	if err := m.checkSudoersGroupFiles(ctx, r.sudoersGroups); err != nil {
+		return r, err
+	}
+
 	// PolicyKitConf files depends on multiple keys, so we need to write it at the end
 	if !allowLocalAdmins || polkitAdditionalUsersGroups != nil {
-		users := strings.Join(polkitAdditionalUsersGroups, ";")
+		// Identities are sorted so that equivalent policies always generate the same file.
+		polkitAdditionalUsersGroups = sortedIdentities(polkitAdditionalUsersGroups)
+		identities := polkitAdditionalUsersGroups
 		// We need to set system local admin here as we override the key from the previous file
 		// otherwise, they will be disabled.
 		if allowLocalAdmins {
-			if systemPolkitAdmins != "" {
-				systemPolkitAdmins += ";"
+			identities = append(strings.Split(systemPolkitAdmins, ";"), identities...)
+		} else {
+			for _, u := range m.validProtectedUsers(ctx) {
+				identities = append(identities, fmt.Sprintf("unix-user:%s", u))
 			}
-			users = systemPolkitAdmins + users
 		}
+		users := strings.Join(sortedIdentities(identities), ";")
 
-		if _, err := policyKitConfF.WriteString(fmt.Sprintf("%s[Configuration]\nAdminIdentities=%s", header, users) + "\n"); err != nil {
-			return err
+		// Don't leave the machine without any polkit administrator, unless explicitly requested.
+		// When local administrators are denied, temporary ones don't count as they will expire.
+		if users == "" || (!allowLocalAdmins && removeIdentities(users, polkitExpiries) == "") {
+			if !m.allowAdminLockout {
+				msg := gotext.Get("refusing to configure polkit without any administrator: local administrators are denied and no valid client administrator is set")
+				log.Error(ctx, msg)
+				return r, errors.New(msg)
+			}
+			log.Warning(ctx, gotext.Get("No polkit administrator is configured, as admin lockout is allowed"))
+		}
+
+		var expiryComments string
+		for _, id := range polkitAdditionalUsersGroups {
+			if expiry, ok := polkitExpiries[id]; ok {
+				expiryComments += expiryComment(expiry, id)
+			}
+		}
+		r.policyKitConf = fmt.Sprintf("%s%s[Configuration]\nAdminIdentities=%s", header, expiryComments, users) + "\n"
+
+		if m.polkitAdminRules {
+			r.polkitAdminRules = polkitAdminRulesContent(sortedIdentities(identities), polkitExpiries)
+		}
+	}
+	r.allowLocalAdmins = allowLocalAdmins
+
+	return r, nil
+}
+
+// sudoersRule is the sudoers rule of a client administrator.
+type sudoersRule struct {
+	id    string
+	runAs string
+	// rule is the sudoers line of the administrator, preceded by its expiry comment for temporary grants.
+	rule string
+	// temporary grants are never aliased, so that their line can be removed once expired.
+	temporary bool
+}
+
+// clientAdminsRules returns the sudoers rules of the client administrators, in order.
+// Once at least sudoersAliasThreshold administrators, without time to live, run as the same user and group, they are
+// grouped in a User_Alias, with one administrator per line, referenced by a single rule written in place of the first
+// of them.
+func (m *Manager) clientAdminsRules(rules []sudoersRule) string {
+	counts := make(map[string]int)
+	for _, r := range rules {
+		if !r.temporary {
+			counts[r.runAs]++
+		}
+	}
+
+	var out strings.Builder
+	aliased := make(map[string]bool)
+	for _, r := range rules {
+		if r.temporary || m.sudoersAliasThreshold <= 0 || counts[r.runAs] < m.sudoersAliasThreshold {
+			out.WriteString(r.rule)
+			continue
+		}
+		if aliased[r.runAs] {
+			continue
+		}
+
+		name := sudoersAdminsAlias
+		if len(aliased) > 0 {
+			name = fmt.Sprintf("%s_%d", sudoersAdminsAlias, len(aliased)+1)
+		}
+		aliased[r.runAs] = true
+
+		var ids []string
+		for _, other := range rules {
+			if !other.temporary && other.runAs == r.runAs {
+				ids = append(ids, other.id)
+			}
+		}
+		out.WriteString(fmt.Sprintf("User_Alias %s = \\\n\t%s\n", name, strings.Join(ids, ", \\\n\t")))
+		out.WriteString(fmt.Sprintf("%s\tALL=(%s) ALL\n", name, r.runAs))
+	}
+	return out.String()
+}
+
+// lock waits for the other applies of the policy to end. It returns an error if ctx is done before.
+func (m *Manager) lock(ctx context.Context) error {
+	select {
+	case m.privilegeMu <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return errors.New(gotext.Get("another privilege policy is still being applied: %v", ctx.Err()))
+	}
+}
+
+// unlock releases the lock taken by lock.
+func (m *Manager) unlock() {
+	<-m.privilegeMu
+}
+
+// managedPaths returns the paths of the sudoers, polkit configuration and polkit rules files managed by adsys.
+func (m *Manager) managedPaths(ctx context.Context) (sudoersConf, policyKitConf, policyKitRules, policyKitAdminRules string) {
+	sudoersDir := m.sudoersDir
+	if sudoersDir == "" {
+		sudoersDir = detectSudoersDir(ctx, m.sudoersConf)
+	}
+	policyKitDir := m.policyKitDir
+	if policyKitDir == "" {
+		policyKitDir = consts.DefaultPolicyKitDir
+	}
+
+	return filepath.Join(sudoersDir, adsysBaseConfName),
+		filepath.Join(policyKitDir, "localauthority.conf.d", adsysBaseConfName+".conf"),
+		filepath.Join(policyKitDir, "rules.d", polkitRulesName),
+		filepath.Join(policyKitDir, "rules.d", polkitAdminRulesName)
+}
+
+// ManagedFile is a sudoers or polkit file managed by adsys, with its current content on disk.
+type ManagedFile struct {
+	Path    string
+	Content string
+	// Exists is false when the file is not on disk, as the policy doesn't need it.
+	Exists bool
+}
+
+// ManagedFiles returns the sudoers and polkit files managed by adsys, as currently written on disk.
+func (m *Manager) ManagedFiles(ctx context.Context) (files []ManagedFile, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't read privilege files"))
+
+	sudoersConf, policyKitConf, policyKitRules, policyKitAdminRules := m.managedPaths(ctx)
+	paths := []string{sudoersConf, policyKitConf, policyKitRules}
+	if m.polkitAdminRules {
+		paths = append(paths, policyKitAdminRules)
+	}
+	for _, p := range paths {
+		content, err := os.ReadFile(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			files = append(files, ManagedFile{Path: p})
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		files = append(files, ManagedFile{Path: p, Content: string(content), Exists: true})
+	}
+
+	return files, nil
+}
+
+// Verify compares the sudoers and polkit files managed by adsys, including the sudoers group files and snippets, with
+// the content the policy entries would write. Nothing is changed on disk.
+// As temporary grants expire relatively to the last time they were applied, their expiry annotations are not
+// compared.
+func (m *Manager) Verify(ctx context.Context, entries []entry.Entry, assetsDumper AssetsDumper) (files []integrity.File, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't verify privilege files"))
+
+	sudoersConf, policyKitConf, policyKitRules, policyKitAdminRules := m.managedPaths(ctx)
+	want := make(map[string]*string)
+	var groups, snippets map[string]string
+	if len(entries) > 0 {
+		r, err := m.render(ctx, entries, assetsDumper)
+		if err != nil {
+			return nil, err
+		}
+		want[sudoersConf], want[policyKitConf] = &r.sudoers, &r.policyKitConf
+		if r.polkitRules != "" {
+			want[policyKitRules] = &r.polkitRules
+		}
+		if r.polkitAdminRules != "" {
+			want[policyKitAdminRules] = &r.polkitAdminRules
+		}
+		groups, snippets = r.sudoersGroups, r.sudoersSnippets
+	}
+
+	paths := []string{sudoersConf, policyKitConf, policyKitRules}
+	if m.polkitAdminRules {
+		paths = append(paths, policyKitAdminRules)
+	}
+	for _, f := range []struct {
+		prefix string
+		files  map[string]string
+	}{{sudoersGroupPrefix, groups}, {sudoersSnippetPrefix, snippets}} {
+		installed, err := installedSudoersFiles(filepath.Dir(sudoersConf), f.prefix)
+		if err != nil {
+			return nil, err
+		}
+		for name, content := range f.files {
+			p := filepath.Join(filepath.Dir(sudoersConf), name)
+			want[p] = &content
+			if !slices.Contains(installed, p) {
+				installed = append(installed, p)
+			}
+		}
+		slices.Sort(installed)
+		paths = append(paths, installed...)
+	}
+
+	for _, p := range paths {
+		f, err := integrity.Read("privilege", p, want[p])
+		if err != nil {
+			return nil, err
+		}
+		f.Want, f.Got = withoutExpiryComments(f.Want), withoutExpiryComments(f.Got)
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+// AdminIdentities returns the polkit administrator identities in effect on the machine: the ones written by
+// adsys if any, or the ones of the system configuration otherwise.
+func (m *Manager) AdminIdentities(ctx context.Context) (ids []string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't read polkit administrators"))
+
+	_, policyKitConf, _, _ := m.managedPaths(ctx)
+
+	var adminIdentities string
+	if _, err := os.Stat(policyKitConf); errors.Is(err, fs.ErrNotExist) {
+		if adminIdentities, err = getSystemPolkitAdminIdentities(ctx, filepath.Dir(filepath.Dir(policyKitConf))); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else {
+		cfg, err := ini.LoadSources(ini.LoadOptions{IgnoreInlineComment: true}, policyKitConf)
+		if err != nil {
+			return nil, err
+		}
+		adminIdentities = cfg.Section("Configuration").Key("AdminIdentities").String()
+	}
+
+	return sortedIdentities(strings.Split(adminIdentities, ";")), nil
+}
+
+// SimulateAdminIdentities returns the polkit administrator identities the policy entries would set, or the ones of
+// the system configuration if they don't set any. Nothing is changed on disk.
+// The sudoers snippets referenced by the policy are fetched with assetsDumper.
+func (m *Manager) SimulateAdminIdentities(ctx context.Context, entries []entry.Entry, assetsDumper AssetsDumper) (ids []string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't simulate polkit administrators"))
+
+	if len(entries) > 0 {
+		r, err := m.render(ctx, entries, assetsDumper)
+		if err != nil {
+			return nil, err
+		}
+		if r.policyKitConf != "" {
+			cfg, err := ini.LoadSources(ini.LoadOptions{IgnoreInlineComment: true}, []byte(r.policyKitConf))
+			if err != nil {
+				return nil, err
+			}
+			return sortedIdentities(strings.Split(cfg.Section("Configuration").Key("AdminIdentities").String(), ";")), nil
+		}
+	}
+
+	policyKitDir := m.policyKitDir
+	if policyKitDir == "" {
+		policyKitDir = consts.DefaultPolicyKitDir
+	}
+	adminIdentities, err := getSystemPolkitAdminIdentities(ctx, policyKitDir)
+	if err != nil {
+		return nil, err
+	}
+	return sortedIdentities(strings.Split(adminIdentities, ";")), nil
+}
+
+// localUserRe matches the local user names which can be protected.
+var localUserRe = regexp.MustCompile(`^[a-zA-Z0-9_][-a-zA-Z0-9_.]*\$?$`)
+
+// validProtectedUsers returns the protected users, sorted and without duplicates. Invalid user names are skipped with
+// a warning.
+func (m *Manager) validProtectedUsers(ctx context.Context) (users []string) {
+	for _, u := range m.protectedUsers {
+		u = strings.TrimSpace(u)
+		if !localUserRe.MatchString(u) {
+			log.Warning(ctx, gotext.Get("Ignoring invalid protected user name %q", u))
+			continue
+		}
+		users = append(users, u)
+	}
+	slices.Sort(users)
+	return slices.Compact(users)
+}
+
+// sortedIdentities returns the non empty polkit identities of ids, sorted and without duplicates.
+func sortedIdentities(ids []string) []string {
+	var r []string
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" {
+			r = append(r, id)
+		}
+	}
+	slices.Sort(r)
+	return slices.Compact(r)
+}
+
+// writePolkitRules writes the polkit rules content to path. If there is no rule, the file is removed.
+func (m *Manager) writePolkitRules(ctx context.Context, path, content string) error {
+	if content == "" {
+		_, err := tamper.Remove(ctx, "privilege", path)
+		return err
+	}
+
+	return m.writeFile(ctx, path, content, 0644)
+}
+
+// privilegeFiles returns the content of the rendered privilege files, mapped by their path, with the sudoers ones
+// installed in sudoersDir. removing is true if any previously installed file is not part of them anymore.
+func privilegeFiles(sudoersDir, sudoersConf, policyKitConf, policyKitRules, policyKitAdminRules string, r rendered) (files map[string]string, removing bool, err error) {
+	files = map[string]string{
+		sudoersConf:   r.sudoers,
+		policyKitConf: r.policyKitConf,
+	}
+	if r.polkitRules != "" {
+		files[policyKitRules] = r.polkitRules
+	}
+	if r.polkitAdminRules != "" {
+		files[policyKitAdminRules] = r.polkitAdminRules
+	}
+	for name, content := range r.sudoersGroups {
+		files[filepath.Join(sudoersDir, name)] = content
+	}
+	for name, content := range r.sudoersSnippets {
+		files[filepath.Join(sudoersDir, name)] = content
+	}
+
+	installed := []string{policyKitRules, policyKitAdminRules}
+	for _, prefix := range []string{sudoersGroupPrefix, sudoersSnippetPrefix} {
+		matches, err := installedSudoersFiles(sudoersDir, prefix)
+		if err != nil {
+			return nil, false, err
+		}
+		installed = append(installed, matches...)
+	}
+	for _, p := range installed {
+		if _, ok := files[p]; ok {
+			continue
+		}
+		if _, err := os.Stat(p); err == nil {
+			removing = true
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, false, err
+		}
+	}
+
+	return files, removing, nil
+}
+
+// installedSudoersFiles returns the files starting with prefix installed in sudoersDir. The backups of the files
+// modified locally are not part of them: sudo doesn't read them either, as their name contains a dot.
+func installedSudoersFiles(sudoersDir, prefix string) (files []string, err error) {
+	matches, err := filepath.Glob(filepath.Join(sudoersDir, prefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range matches {
+		if strings.HasSuffix(p, tamper.BackupSuffix) {
+			continue
+		}
+		files = append(files, p)
+	}
+	return files, nil
+}
+
+// unchangedOnDisk returns true if all files, mapped by their path, already have their content.
+func unchangedOnDisk(files map[string]string) bool {
+	for p, content := range files {
+		got, err := os.ReadFile(p)
+		if err != nil || string(got) != content {
+			return false
 		}
 	}
+	return true
+}
 
-	// Move temp files to their final destination
-	if err := os.Rename(sudoersConf+".new", sudoersConf); err != nil {
+// writeFile atomically writes content to path, unless it was modified locally and is preserved, and records the
+// write for the cooldown.
+func (m *Manager) writeFile(ctx context.Context, path, content string, perm fs.FileMode) error {
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
-	if err := os.Rename(policyKitConf+".new", policyKitConf); err != nil {
+	if written, err := tamper.Write(ctx, "privilege", path, []byte(content), perm); err != nil || !written {
 		return err
 	}
 
+	if err := m.cooldown.Written(path, []byte(content)); err != nil {
+		log.Warningf(ctx, "Cooldown won't apply to the next rewrite of %s: %v", path, err)
+	}
 	return nil
 }
 
+// checkSudoersDirWritable returns an actionable error if no file can be created in the sudoers directory, as it is
+// immutable or on a read-only mount, instead of the bare error of the first failing write.
+func (m *Manager) checkSudoersDirWritable(dir string) error {
+	err := m.probeDir(dir)
+	if errors.Is(err, syscall.EROFS) || errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES) {
+		return fmt.Errorf("%s: %w", gotext.Get("sudoers directory %s is read-only or immutable, no privilege file was changed", dir), err)
+	}
+	return err
+}
+
+// probeWritable checks that a file can be created in dir, creating it if needed. The probe file starts with a "."
+// so that sudo never reads it.
+func probeWritable(dir string) error {
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".adsys-probe-*")
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(f.Name())
+}
+
+// parsePolkitRules returns the polkit rules for the rules in v, one per line, of the form:
+//
+//	<action id> <yes|auth_admin|no> <subject>[,<subject>...]
+//
+// Subjects are users or %groups, normalized as client administrators. Empty lines and comments are ignored.
+func parsePolkitRules(ctx context.Context, v string) (rules []string, err error) {
+	for _, l := range strings.Split(v, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		fields := strings.Fields(l)
+		if len(fields) < 3 {
+			return nil, errors.New(gotext.Get("invalid polkit rule %q: expecting an action id, a result and subjects", l))
+		}
+		actionID, result := fields[0], fields[1]
+		if !polkitActionIDRe.MatchString(actionID) {
+			return nil, errors.New(gotext.Get("invalid polkit action id %q", actionID))
+		}
+		polkitResult, ok := polkitResults[result]
+		if !ok {
+			return nil, errors.New(gotext.Get("invalid result %q for polkit action %s: only yes, auth_admin and no are supported", result, actionID))
+		}
+
+		conditions := polkitSubjectConditions(ctx, "polkit-rules", strings.Join(fields[2:], " "))
+		if len(conditions) == 0 {
+			return nil, errors.New(gotext.Get("no valid subject for polkit action %s", actionID))
+		}
+
+		rules = append(rules, fmt.Sprintf(`polkit.addRule(function(action, subject) {
+    if (action.id == %s &&
+        (%s)) {
+        return %s;
+    }
+});
+`, strconv.Quote(actionID), strings.Join(conditions, " || "), polkitResult))
+	}
+
+	return rules, nil
+}
+
+// parsePolkitActionGroups returns the polkit rules for the action groups in v, one per line, of the form:
+//
+//	<name> <namespace>[,<namespace>...] <yes|auth_self> <subject>[,<subject>...]
+//
+// Namespaces are action id prefixes, like org.cups.*, granted to the subjects. Subjects are users or %groups,
+// normalized as client administrators. Empty lines and comments are ignored.
+func parsePolkitActionGroups(ctx context.Context, v string) (rules []string, err error) {
+	names := make(map[string]bool)
+	for _, l := range strings.Split(v, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		fields := strings.Fields(l)
+		if len(fields) < 4 {
+			return nil, errors.New(gotext.Get("invalid polkit action group %q: expecting a name, action namespaces, a result and subjects", l))
+		}
+		name, result := fields[0], fields[2]
+		if !polkitActionGroupNameRe.MatchString(name) {
+			return nil, errors.New(gotext.Get("invalid polkit action group name %q: only letters, digits, - and _ are allowed", name))
+		}
+		if names[name] {
+			return nil, errors.New(gotext.Get("polkit action group %s is defined multiple times", name))
+		}
+		names[name] = true
+
+		var matches []string
+		for _, prefix := range strings.Split(fields[1], ",") {
+			if !polkitActionPrefixRe.MatchString(prefix) {
+				return nil, errors.New(gotext.Get("invalid action namespace %q for polkit action group %s: expecting an action id prefix like org.cups.*", prefix, name))
+			}
+			// Polkit JavaScript engines don't all support startsWith.
+			matches = append(matches, fmt.Sprintf("action.id.indexOf(%s) == 0", strconv.Quote(strings.TrimSuffix(prefix, "*"))))
+		}
+		polkitResult, ok := polkitActionGroupResults[result]
+		if !ok {
+			return nil, errors.New(gotext.Get("invalid result %q for polkit action group %s: only yes and auth_self are supported", result, name))
+		}
+
+		conditions := polkitSubjectConditions(ctx, "polkit-action-groups", strings.Join(fields[3:], " "))
+		if len(conditions) == 0 {
+			return nil, errors.New(gotext.Get("no valid subject for polkit action group %s", name))
+		}
+
+		rules = append(rules, fmt.Sprintf(`// Action group %s
+polkit.addRule(function(action, subject) {
+    if ((%s) &&
+        (%s)) {
+        return %s;
+    }
+});
+`, name, strings.Join(matches, " || "), strings.Join(conditions, " || "), polkitResult))
+	}
+
+	return rules, nil
+}
+
+// polkitSubjectConditions returns the polkit conditions matching the users, %groups and +netgroups listed in v.
+func polkitSubjectConditions(ctx context.Context, key, v string) (conditions []string) {
+	for _, s := range splitAndNormalizeUsersAndGroups(ctx, key, v) {
+		if group, isGroup := strings.CutPrefix(s, "%"); isGroup {
+			conditions = append(conditions, fmt.Sprintf("subject.isInGroup(%s)", strconv.Quote(group)))
+			continue
+		}
+		if netgroup, isNetgroup := strings.CutPrefix(s, "+"); isNetgroup {
+			conditions = append(conditions, fmt.Sprintf("subject.isInNetGroup(%s)", strconv.Quote(netgroup)))
+			continue
+		}
+		conditions = append(conditions, fmt.Sprintf("subject.user == %s", strconv.Quote(s)))
+	}
+	return conditions
+}
+
+// polkitRulesContent returns the content of the polkit rules file for rules. If there is no rule, the content is
+// empty.
+func polkitRulesContent(rules []string) string {
+	if len(rules) == 0 {
+		return ""
+	}
+
+	return `// This file is managed by adsys.
+// Do not edit this file manually.
+// Any changes will be overwritten.
+
+` + strings.Join(rules, "\n")
+}
+
+// polkitAdminRulesContent returns the content of the polkit rules file setting ids as the administrators.
+// Temporary administrators, with an expiry in expiries, are only returned by the rule until they expire.
+// The file only adds an admin rule, and never a rule returning a result for an action, so that the authorizations
+// of the actions are still decided by the other rules.
+func polkitAdminRulesContent(ids []string, expiries map[string]time.Time) string {
+	var admins, temporaries []string
+	for _, id := range ids {
+		if expiry, ok := expiries[id]; ok {
+			temporaries = append(temporaries, fmt.Sprintf(`    if (now < Date.parse(%s)) {
+        admins.push(%s);
+    }
+`, strconv.Quote(expiry.Format(time.RFC3339)), strconv.Quote(id)))
+			continue
+		}
+		admins = append(admins, strconv.Quote(id))
+	}
+
+	var now string
+	if len(temporaries) > 0 {
+		now = "    var now = Date.now();\n"
+	}
+
+	return fmt.Sprintf(`// This file is managed by adsys.
+// Do not edit this file manually.
+// Any changes will be overwritten.
+
+polkit.addAdminRule(function(action, subject) {
+%s    var admins = [%s];
+%s    return admins;
+});
+`, now, strings.Join(admins, ", "), strings.Join(temporaries, ""))
+}
+
+// reportPrivilegeFile logs, in report-only mode, how path would be changed to content, or removed if content
+// is nil. Read errors are only logged as the file is never written.
+func reportPrivilegeFile(ctx context.Context, path string, content *string) {
+	old, err := os.ReadFile(path)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Warning(ctx, gotext.Get("Report-only privilege mode: can't read current %s: %v", path, err))
+	}
+
+	switch {
+	case content == nil && !exists:
+		log.Infof(ctx, "Report-only privilege mode: %s is absent, as expected", path)
+	case content == nil:
+		log.Info(ctx, gotext.Get("Report-only privilege mode: %s would be removed", path))
+	case exists && string(old) == *content:
+		log.Infof(ctx, "Report-only privilege mode: %s is up to date", path)
+	default:
+		log.Info(ctx, gotext.Get("Report-only privilege mode: %s would be set to:\n%s", path, *content))
+	}
+}
+
+// hostTag prefixes a client administrator only granted privileges on the hosts matching a glob.
+const hostTag = "[host="
+
+// clientAdmin is a user or group granted administrator privileges, optionally restricted to run commands
+// as a given target, for a given time to live or on some hosts only.
+type clientAdmin struct {
+	name  string
+	runAs string
+	ttl   string
+	host  string
+}
+
+// cachedClientAdmins returns the client administrators of v, only splitting and normalizing it if it differs
+// from the previously applied value. The returned slice must not be modified.
+func (m *Manager) cachedClientAdmins(ctx context.Context, v string) []clientAdmin {
+	m.clientAdminsCache.mu.Lock()
+	defer m.clientAdminsCache.mu.Unlock()
+
+	if c := &m.clientAdminsCache; c.value != nil && *c.value == v {
+		log.Debug(ctx, "Client administrators are unchanged, using cached value")
+		ignored.Record(ctx, c.ignored...)
+		return c.admins
+	}
+
+	collector := &ignored.Collector{}
+	admins := splitClientAdmins(ignored.WithCollector(ctx, collector), v)
+	m.clientAdminsCache.value = &v
+	m.clientAdminsCache.admins = admins
+	m.clientAdminsCache.ignored = collector.Entries()
+	ignored.Record(ctx, m.clientAdminsCache.ignored...)
+	return admins
+}
+
+// splitClientAdmins splits client administrators on lines and ,.
+// Each element can be suffixed with =(user), =(user:group) or =(:group) to restrict the users and group
+// that the administrator can run commands as, and prefixed, in any order, with [ttl=duration] to only grant
+// privileges temporarily and [host=glob] to only grant them on matching hosts.
+func splitClientAdmins(ctx context.Context, v string) (admins []clientAdmin) {
+	for _, e := range strings.Split(strings.ReplaceAll(v, "\n", ","), ",") {
+		var ttl, host string
+		for {
+			var found bool
+			if ttl == "" {
+				if ttl, e, found = cutTag(e, ttlTag); found {
+					continue
+				}
+			}
+			if host == "" {
+				if host, e, found = cutTag(e, hostTag); found {
+					continue
+				}
+			}
+			break
+		}
+
+		var runAs string
+		if i := strings.Index(e, "=("); i != -1 && strings.HasSuffix(strings.TrimSpace(e), ")") {
+			runAs = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(e[i+2:]), ")"))
+			e = strings.TrimSpace(e[:i])
+		}
+
+		names := splitAndNormalizeUsersAndGroups(ctx, "client-admins", e)
+		if len(names) == 0 {
+			continue
+		}
+		admins = append(admins, clientAdmin{name: names[0], runAs: runAs, ttl: ttl, host: host})
+	}
+
+	return admins
+}
+
+// cutTag returns the value of the tag prefixing e, like [ttl=4h], and e without it.
+// found is false, and e returned as is, if e doesn't start with a terminated tag.
+func cutTag(e, tag string) (value, rest string, found bool) {
+	rest, found = strings.CutPrefix(strings.TrimSpace(e), tag)
+	if !found {
+		return "", e, false
+	}
+	i := strings.Index(rest, "]")
+	if i == -1 {
+		return "", e, false
+	}
+	return strings.TrimSpace(rest[:i]), strings.TrimSpace(rest[i+1:]), true
+}
+
+// matchesHost returns true if the glob pattern matches one of the names of the machine, case insensitively.
+func (m *Manager) matchesHost(pattern string) (bool, error) {
+	pattern = strings.ToLower(pattern)
+	// Check the pattern even without any name to match.
+	if _, err := path.Match(pattern, ""); err != nil {
+		return false, err
+	}
+	for _, name := range m.hostnames {
+		if matched, _ := path.Match(pattern, strings.ToLower(name)); matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// resolveRunAs ensures the user and group of a runas specification exist and returns it in sudoers format.
+func (m *Manager) resolveRunAs(runAs string) (spec string, err error) {
+	defer decorate.OnError(&err, gotext.Get("invalid runas specification %q", runAs))
+
+	runAsUser, runAsGroup, _ := strings.Cut(runAs, ":")
+	runAsUser, runAsGroup = strings.TrimSpace(runAsUser), strings.TrimSpace(runAsGroup)
+	if runAsUser == "" && runAsGroup == "" {
+		return "", errors.New(gotext.Get("no user or group to run as"))
+	}
+
+	if runAsUser != "" {
+		if _, err := m.userLookup(runAsUser); err != nil {
+			return "", err
+		}
+		spec = quoteSudoersName(runAsUser)
+	}
+	if runAsGroup != "" {
+		if _, err := m.groupLookup(runAsGroup); err != nil {
+			return "", err
+		}
+		spec += ":" + quoteSudoersName(runAsGroup)
+	}
+
+	return spec, nil
+}
+
+// quoteSudoersName quotes name if it contains characters which have a special meaning in sudoers.
+func quoteSudoersName(name string) string {
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && !strings.ContainsRune("_-.", c) {
+			return fmt.Sprintf("%q", name)
+		}
+	}
+	return name
+}
+
+// netgroupRe matches the netgroup names which can be written unquoted in sudoers.
+var netgroupRe = regexp.MustCompile(`^[a-zA-Z0-9_][-a-zA-Z0-9_.]*$`)
+
 // splitAndNormalizeUsersAndGroups allow splitting on lines and ,.
-// We remove any invalid characters and empty elements.
-// All will have the form of user@domain.
-func splitAndNormalizeUsersAndGroups(ctx context.Context, v string) []string {
+// We remove any invalid characters and empty elements, reporting them as ignored for the entry key.
+// All will have the form of user@domain, except netgroups prefixed with +, which are kept as is.
+func splitAndNormalizeUsersAndGroups(ctx context.Context, key, v string) []string {
 	var elems []string
 	elems = append(elems, strings.Split(v, "\n")...)
 	v = strings.Join(elems, ",")
 	elems = nil
 	for _, e := range strings.Split(v, ",") {
 		initialValue := e
+		// NIS netgroups are not directory names: they are kept as is, only if valid.
+		if netgroup, isNetgroup := strings.CutPrefix(strings.TrimSpace(e), "+"); isNetgroup {
+			if !netgroupRe.MatchString(netgroup) {
+				log.Warningf(ctx, "Ignoring invalid netgroup %q", strings.TrimSpace(initialValue))
+				ignored.Record(ctx, ignored.Entry{Rule: "privilege", Key: key, Value: strings.TrimSpace(initialValue), Reason: ignored.InvalidValue,
+					Details: gotext.Get("invalid netgroup name")})
+				continue
+			}
+			elems = append(elems, "+"+netgroup)
+			continue
+		}
+
 		// Invalid chars in Windows user names: '/[]:|<>+=;,?*%"
 		isgroup := strings.HasPrefix(e, "%")
 		for _, c := range []string{"/", "[", "]", ":", "|", "<", ">", "=", ";", "?", "*", "%"} {
@@ -232,32 +1478,119 @@ func splitAndNormalizeUsersAndGroups(ctx context.Context, v string) []string {
 
 		e = strings.TrimSpace(e)
 		if e == "" {
+			if strings.TrimSpace(initialValue) != "" {
+				ignored.Record(ctx, ignored.Entry{Rule: "privilege", Key: key, Value: strings.TrimSpace(initialValue), Reason: ignored.InvalidValue,
+					Details: gotext.Get("only made of invalid characters")})
+			}
 			continue
 		}
 		if e != initialValue {
 			log.Warningf(ctx, "Changed user or group %q to %q: Invalid characters or domain\\user format", initialValue, e)
 		}
+		// Surrounding spaces are not worth reporting.
+		if e != strings.TrimSpace(initialValue) {
+			ignored.Record(ctx, ignored.Entry{Rule: "privilege", Key: key, Value: strings.TrimSpace(initialValue), Reason: ignored.NormalizedIdentity,
+				Details: gotext.Get("changed to %q", e)})
+		}
 		elems = append(elems, e)
 	}
 
 	return elems
 }
 
+// detectSudoersDir returns the directory included by the sudoers configuration file sudoersConf, with a
+// #includedir or @includedir directive. If there are multiple ones, the last one is returned as its rules are
+// evaluated last and thus take precedence.
+// It falls back to the default sudoers directory if there is none or the file can't be read.
+func detectSudoersDir(ctx context.Context, sudoersConf string) string {
+	d, err := os.ReadFile(sudoersConf)
+	if err != nil {
+		log.Debugf(ctx, "Can't read %s, using default sudoers directory: %v", sudoersConf, err)
+		return consts.DefaultSudoersDir
+	}
+
+	var dir string
+	for _, l := range strings.Split(string(d), "\n") {
+		fields := strings.Fields(l)
+		if len(fields) < 2 || (fields[0] != "#includedir" && fields[0] != "@includedir") {
+			continue
+		}
+		p := strings.Trim(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(l), fields[0])), `"`)
+		// Relative paths are relative to the including file.
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(sudoersConf), p)
+		}
+		dir = p
+	}
+
+	if dir == "" {
+		log.Debugf(ctx, "No include directory in %s, using default sudoers directory", sudoersConf)
+		return consts.DefaultSudoersDir
+	}
+	log.Debugf(ctx, "Using sudoers directory %s included from %s", dir, sudoersConf)
+	return dir
+}
+
+// SystemAdminIdentities is the AdminIdentities value set by a system polkit configuration file.
+type SystemAdminIdentities struct {
+	Path  string
+	Value string
+	// Preserved is set on the file whose value adsys preserves as the system polkit administrators.
+	Preserved bool
+}
+
+// SystemAdminIdentities returns all the system polkit configuration files setting AdminIdentities, in the order
+// polkit reads them, and which one is preserved by adsys. This is diagnostic only.
+func (m *Manager) SystemAdminIdentities(ctx context.Context) (files []SystemAdminIdentities, err error) {
+	policyKitDir := m.policyKitDir
+	if policyKitDir == "" {
+		policyKitDir = consts.DefaultPolicyKitDir
+	}
+
+	files, _, err = readSystemPolkitAdminIdentities(ctx, policyKitDir)
+	return files, err
+}
+
 // getSystemPolkitAdminIdentities returns the list of configured system polkit admins as a string.
 // It lists /etc/polkit-1/localauthority.conf.d and take the highest file in ascii order to match
 // from the [configuration] section AdminIdentities value.
+// As the lower files are ignored, all the files setting AdminIdentities are logged when there are several of them.
 func getSystemPolkitAdminIdentities(ctx context.Context, policyKitDir string) (adminIdentities string, err error) {
+	files, adminIdentities, err := readSystemPolkitAdminIdentities(ctx, policyKitDir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(files) > 1 {
+		var report strings.Builder
+		for _, f := range files {
+			preserved := ""
+			if f.Preserved {
+				preserved = gotext.Get(" (preserved)")
+			}
+			report.WriteString(fmt.Sprintf("\n  %s: %s%s", f.Path, f.Value, preserved))
+		}
+		log.Warning(ctx, gotext.Get("Several system polkit configuration files set AdminIdentities, only the highest one is used:%s", report.String()))
+	}
+
+	return adminIdentities, nil
+}
+
+// readSystemPolkitAdminIdentities returns the system polkit configuration files setting AdminIdentities, and the
+// value of the highest file in ascii order, ignoring the file managed by adsys.
+func readSystemPolkitAdminIdentities(ctx context.Context, policyKitDir string) (files []SystemAdminIdentities, adminIdentities string, err error) {
 	defer decorate.OnError(&err, gotext.Get("can't get existing system polkit administrators in %s", policyKitDir))
 
 	polkitConfFiles, err := filepath.Glob(filepath.Join(policyKitDir, "localauthority.conf.d", "*.conf"))
 	if err != nil {
-		return "", err
+		return nil, "", err
 	}
 	sort.Strings(polkitConfFiles)
+	var last string
 	for _, p := range polkitConfFiles {
 		fi, err := os.Stat(p)
 		if err != nil {
-			return "", err
+			return nil, "", err
 		}
 		if fi.IsDir() {
 			log.Warning(ctx, gotext.Get("%s is a directory. Ignoring.", p))
@@ -271,11 +1604,22 @@ func getSystemPolkitAdminIdentities(ctx context.Context, policyKitDir string) (a
 
 		cfg, err := ini.LoadSources(ini.LoadOptions{IgnoreInlineComment: true}, p)
 		if err != nil {
-			return "", err
+			return nil, "", err
 		}
 
+		last = p
+		// Key creates the key when missing, so it must be checked before.
+		isSet := cfg.Section("Configuration").HasKey("AdminIdentities")
 		adminIdentities = cfg.Section("Configuration").Key("AdminIdentities").String()
+		if isSet {
+			files = append(files, SystemAdminIdentities{Path: p, Value: adminIdentities})
+		}
 	}
 
-	return adminIdentities, nil
+	// Only the highest file is used, even if it doesn't set any administrator.
+	if len(files) > 0 && files[len(files)-1].Path == last {
+		files[len(files)-1].Preserved = true
+	}
+
+	return files, adminIdentities, nil
 }
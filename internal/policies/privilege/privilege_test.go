@@ -2,14 +2,33 @@ package privilege_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/user"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/termie/go-shutil"
+	"github.com/ubuntu/adsys/internal/policies/cooldown"
 	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/integrity"
 	"github.com/ubuntu/adsys/internal/policies/privilege"
+	"github.com/ubuntu/adsys/internal/policies/restart"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+	"github.com/ubuntu/adsys/internal/telemetry"
 	"github.com/ubuntu/adsys/internal/testutils"
 )
 
@@ -23,22 +42,98 @@ func TestApplyPolicy(t *testing.T) {
 		entries            []entry.Entry
 		existingSudoersDir string
 		existingPolkitDir  string
+		existingUnitDir    string
 		makeReadOnly       string
 		destIsDir          string
+		allowAdminLockout  bool
+		sudoersPerGroup    bool
+		aliasThreshold     int
+		checkConflicts     bool
+		polkitAdminRules   bool
+		protectedUsers     []string
+		systemdFailing     bool
+		assetsDumperErr    bool
 
 		wantErr bool
 	}{
 		// local admin cases
-		"Disallow local admins":                            {entries: []entry.Entry{{Key: "allow-local-admins", Disabled: true}}},
+		"Disallow local admins":                            {entries: []entry.Entry{{Key: "allow-local-admins", Disabled: true}}, allowAdminLockout: true},
 		"Allow local admins with no other rules is a noop": {entries: []entry.Entry{{Key: "allow-local-admins", Disabled: false}}},
+		"Allow local admins with DWORD 1 is a noop":        {entries: []entry.Entry{{Key: "allow-local-admins", Value: "1"}}},
+		"Disallow local admins with DWORD 0": {entries: []entry.Entry{{Key: "allow-local-admins", Value: "0"}},
+			allowAdminLockout: true},
+		"Disallow local admins with hexadecimal DWORD 0": {entries: []entry.Entry{{Key: "allow-local-admins", Value: "0x00000000"}},
+			allowAdminLockout: true},
+
+		// admin lockout
+		"Error on disallowing local admins without client admins": {entries: defaultLocalAdminDisabledRule, wantErr: true},
+		"Error on disallowing local admins with only invalid client admins": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: ",;,:"}}, wantErr: true},
+		"Error on disallowing local admins with previous local admin conf and no client admins": {
+			existingPolkitDir: "existing-previous-local-admins-one",
+			entries:           defaultLocalAdminDisabledRule, wantErr: true},
+		"Allow lockout without any admin": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: ",;,:"}}, allowAdminLockout: true},
+
+		// protected local users
+		"Disallow local admins keeps protected users": {entries: defaultLocalAdminDisabledRule,
+			protectedUsers: []string{"rescue", "breakglass", "rescue"}},
+		"Disallow local admins and set client admins keeps protected users": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: "alice@domain.com,%group@domain.com"}}, protectedUsers: []string{"breakglass"}},
+		"Disallow local admins keeps protected users in polkit admin rules": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: "alice@domain.com"}}, protectedUsers: []string{"breakglass"}, polkitAdminRules: true},
+		"Invalid protected users are ignored": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: "alice@domain.com"}}, protectedUsers: []string{"breakglass", "", "evil user", "\"quoted\"", "%sudo"}},
+		"Protected users are not set when local admins are allowed": {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com"}},
+			protectedUsers: []string{"breakglass"}},
+		"Error on disallowing local admins with only invalid protected users": {entries: defaultLocalAdminDisabledRule,
+			protectedUsers: []string{"evil user"}, wantErr: true},
 
 		// client admins from AD
-		"Set client user admins":                       {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com"}}},
-		"Set client multiple users admins":             {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,domain\\bob,carole cosmic@otherdomain.com"}}},
-		"Set client group admins":                      {entries: []entry.Entry{{Key: "client-admins", Value: "%group@domain.com"}}},
-		"Set client mixed with users and group admins": {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,%group@domain.com"}}},
-		"Empty client AD admins":                       {entries: []entry.Entry{{Key: "client-admins", Value: ""}}},
-		"No client AD admins":                          {entries: []entry.Entry{{Key: "client-admins", Disabled: true}}},
+		"Set client user admins":                                 {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com"}}},
+		"Set client multiple users admins":                       {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,domain\\bob,carole cosmic@otherdomain.com"}}},
+		"Set client group admins":                                {entries: []entry.Entry{{Key: "client-admins", Value: "%group@domain.com"}}},
+		"Set client mixed with users and group admins":           {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,%group@domain.com"}}},
+		"Set client netgroup admins":                             {entries: []entry.Entry{{Key: "client-admins", Value: "+sysadmins"}}},
+		"Set client mixed with users, group and netgroup admins": {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,%group@domain.com,+sysadmins"}}},
+		"Set client netgroup admins with runas and time to live": {entries: []entry.Entry{{Key: "client-admins", Value: "[ttl=4h]+oncall=(svcacct),+sysadmins"}}},
+		"Invalid client netgroup admins are ignored":             {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,+sys admins,+,+\"evil\""}}},
+		"Empty client AD admins":                                 {entries: []entry.Entry{{Key: "client-admins", Value: ""}}},
+		"No client AD admins":                                    {entries: []entry.Entry{{Key: "client-admins", Disabled: true}}},
+
+		// client admins with runas restrictions
+		"Set client user admins with runas user":              {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com=(svcacct)"}}},
+		"Set client group admins with runas user and group":   {entries: []entry.Entry{{Key: "client-admins", Value: "%group@domain.com=(svcacct:svcgroup)"}}},
+		"Set client user admins with runas group only":        {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com=(:svcgroup)"}}},
+		"Set client user admins with AD runas user is quoted": {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com=(svc@domain.com)"}}},
+		"Set client admins mixed with and without runas":      {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com=(svcacct),domain\\bob\n%group@domain.com"}}},
+		"Client admins with runas are not polkit admins with local admins disabled": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: "alice@domain.com=(svcacct),domain\\bob\n[ttl=4h]%group@domain.com=(:svcgroup)"}}},
+		"Error on only client admins with runas with local admins disabled": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: "alice@domain.com=(svcacct)"}}, wantErr: true},
+
+		// client admins with time to live
+		"Set client admins with time to live":           {entries: []entry.Entry{{Key: "client-admins", Value: "[ttl=4h]alice@domain.com,domain\\bob\n[ttl=30m]%group@domain.com=(svcacct)"}}},
+		"Set client admins with same time to live":      {entries: []entry.Entry{{Key: "client-admins", Value: "[ttl=4h]alice@domain.com,[ttl=240m]%group@domain.com"}}},
+		"Set client admins matching hostname":           {entries: []entry.Entry{{Key: "client-admins", Value: "[host=web-*]alice@domain.com,[host=db-*]bob@domain.com\n[host=WEB-01.DOMAIN.COM][ttl=4h]%group@domain.com"}}},
+		"Set no client admins on non matching hostname": {entries: []entry.Entry{{Key: "client-admins", Value: "[host=db-*]alice@domain.com\n[host=web-01.other.com]%group@domain.com"}}},
+		"Replace expiry timer when client admins have time to live": {
+			existingUnitDir: "existing-expiry-timer",
+			entries:         []entry.Entry{{Key: "client-admins", Value: "[ttl=1h]alice@domain.com"}}},
+		"Remove expiry timer when no client admins have time to live": {
+			existingUnitDir: "existing-expiry-timer",
+			entries:         []entry.Entry{{Key: "client-admins", Value: "alice@domain.com"}}},
+		"Remove expiry timer when there are no rules": {existingUnitDir: "existing-expiry-timer"},
+		"Disallow local admins with only client admins with time to live and lockout override": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: "[ttl=4h]alice@domain.com"}}, allowAdminLockout: true},
 
 		// Mixed rules
 		"Disallow local admins and set client admins": {entries: []entry.Entry{
@@ -68,23 +163,144 @@ func TestApplyPolicy(t *testing.T) {
 				{Key: "allow-local-admins", Disabled: false},
 				{Key: "client-admins", Value: "alice@domain.com"}}},
 
+		// polkit rules
+		"Set polkit rules":                {entries: []entry.Entry{{Key: "polkit-rules", Value: "org.freedesktop.systemd1.manage-units yes %sysadmins@domain.com, alice@domain.com\n# Reboot\n\norg.freedesktop.login1.reboot auth_admin domain\\bob\norg.freedesktop.NetworkManager.settings.modify.system no %guests@domain.com"}}},
+		"Set polkit rules with netgroups": {entries: []entry.Entry{{Key: "polkit-rules", Value: "org.freedesktop.systemd1.manage-units yes %sysadmins@domain.com, +sysadmins"}}},
+		"Set polkit rules with client admins": {entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "polkit-rules", Value: "org.freedesktop.systemd1.manage-units yes %sysadmins@domain.com"}}},
+		"Overwrite existing polkit rules": {existingPolkitDir: "existing-files", entries: []entry.Entry{{Key: "polkit-rules", Value: "org.freedesktop.login1.reboot no bob@domain.com"}}},
+		"Disabled polkit rules removes existing rules": {existingPolkitDir: "existing-files", entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "polkit-rules", Disabled: true}}},
+
+		// polkit action groups
+		"Set polkit action groups for printer admins":             {entries: []entry.Entry{{Key: "polkit-action-groups", Value: "printer-admins org.cups.* auth_self %printadmins@domain.com"}}},
+		"Set polkit action groups for network admins":             {entries: []entry.Entry{{Key: "polkit-action-groups", Value: "network-admins org.freedesktop.NetworkManager.*,org.freedesktop.network1.* yes %netadmins@domain.com, alice@domain.com, +netops"}}},
+		"Set polkit action groups for printer and network admins": {entries: []entry.Entry{{Key: "polkit-action-groups", Value: "# Printers\nprinter-admins org.cups.* auth_self %printadmins@domain.com\n\nnetwork-admins org.freedesktop.NetworkManager.* yes %netadmins@domain.com"}}},
+		"Set polkit action groups after polkit rules": {entries: []entry.Entry{
+			{Key: "polkit-rules", Value: "org.freedesktop.NetworkManager.settings.modify.system no %guests@domain.com"},
+			{Key: "polkit-action-groups", Value: "network-admins org.freedesktop.NetworkManager.* yes %netadmins@domain.com"}}},
+		"Disabled polkit action groups removes existing rules": {existingPolkitDir: "existing-files", entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "polkit-action-groups", Disabled: true}}},
+
+		// sudoers snippets
+		"Install sudoers snippets": {entries: []entry.Entry{{Key: "sudoers-snippets", Value: "developers\n# Operations team\n\nteams/ops.sudoers"}}},
+		"Install sudoers snippets with client admins": {entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "sudoers-snippets", Value: "developers"}}},
+		"Remove sudoers snippets not referenced anymore": {existingSudoersDir: "existing-sudoers-snippets", entries: []entry.Entry{{Key: "sudoers-snippets", Value: "teams/ops.sudoers"}}},
+		"Disabled sudoers snippets removes existing snippets": {existingSudoersDir: "existing-sudoers-snippets", entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "sudoers-snippets", Disabled: true}}},
+		"No rules removes existing sudoers snippets": {existingSudoersDir: "existing-sudoers-snippets"},
+
+		// one sudoers file per group
+		"Set client group admins in one file per group": {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,%group@domain.com,%ops team@domain.com,+sysadmins"}},
+			sudoersPerGroup: true},
+		"Set client group admins with runas and time to live in one file per group": {entries: []entry.Entry{{Key: "client-admins", Value: "[ttl=4h]%group@domain.com=(svcacct),%ops@domain.com"}},
+			sudoersPerGroup: true},
+		"Groups with the same file name share it in one file per group": {entries: []entry.Entry{{Key: "client-admins", Value: "%ops.team@domain.com,%ops_team@domain.com"}},
+			sudoersPerGroup: true},
+		"Disallow local admins and set client group admins in one file per group": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: "%group@domain.com"}}, sudoersPerGroup: true},
+		"Remove group files of groups not in the policy anymore": {existingSudoersDir: "existing-sudoers-groups", entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,%group@domain.com"}},
+			sudoersPerGroup: true},
+		"Remove group files when not writing one file per group": {existingSudoersDir: "existing-sudoers-groups", entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,%group@domain.com"}}},
+		"No rules removes existing group files":                  {existingSudoersDir: "existing-sudoers-groups", sudoersPerGroup: true},
+
+		// sudoers aliases of client admins
+		"Client admins below alias threshold keep one rule each": {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,%group@domain.com"}},
+			aliasThreshold: 3},
+		"Client admins reaching alias threshold are grouped in an alias": {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,%group@domain.com,+sysadmins"}},
+			aliasThreshold: 3},
+		"Client admins are grouped in one alias per runas": {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com=(svcacct),carole@domain.com,domain\\bob=(svcacct)\n%group@domain.com,dave@domain.com=(:svcgroup)"}},
+			aliasThreshold: 2},
+		"Client admins with time to live are not grouped in an alias": {entries: []entry.Entry{{Key: "client-admins", Value: "[ttl=4h]alice@domain.com,domain\\bob,carole@domain.com"}},
+			aliasThreshold: 2},
+		"Client group admins in one file per group are not grouped in an alias": {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,domain\\bob,%group@domain.com,%ops@domain.com"}},
+			aliasThreshold: 2, sudoersPerGroup: true},
+
 		// Overwrite existing files
 		"No rules and no existing history means no files": {},
-		"Overwrite existing sudoers file":                 {existingSudoersDir: "existing-files", entries: defaultLocalAdminDisabledRule},
-		"Overwrite existing polkit file":                  {existingPolkitDir: "existing-files", entries: defaultLocalAdminDisabledRule},
+		"Overwrite existing sudoers file":                 {existingSudoersDir: "existing-files", entries: defaultLocalAdminDisabledRule, allowAdminLockout: true},
+		"Overwrite existing polkit file":                  {existingPolkitDir: "existing-files", entries: defaultLocalAdminDisabledRule, allowAdminLockout: true},
 		"No rules still overwrite those files":            {existingSudoersDir: "existing-files", existingPolkitDir: "existing-files"},
-		"Don't overwrite other existing files":            {existingSudoersDir: "existing-other-files", existingPolkitDir: "existing-other-files", entries: defaultLocalAdminDisabledRule},
+		"Don't overwrite other existing files":            {existingSudoersDir: "existing-other-files", existingPolkitDir: "existing-other-files", entries: defaultLocalAdminDisabledRule, allowAdminLockout: true},
 
 		// Not a computer, don’t do anything (even not create new files)
 		"Not a computer": {notComputer: true, existingSudoersDir: "existing-other-files", existingPolkitDir: "existing-other-files"},
 
 		// Error cases
-		"Error on writing to sudoers file":                          {makeReadOnly: "sudoers.d/", existingSudoersDir: "existing-files", existingPolkitDir: "existing-files", entries: defaultLocalAdminDisabledRule, wantErr: true},
-		"Error on writing to polkit subdirectory creation":          {makeReadOnly: "polkit-1/", existingSudoersDir: "existing-files", existingPolkitDir: "only-base-polkit-dir", entries: defaultLocalAdminDisabledRule, wantErr: true},
-		"Error on writing to polkit conf file":                      {makeReadOnly: "polkit-1/localauthority.conf.d", existingSudoersDir: "existing-files", existingPolkitDir: "existing-files", entries: defaultLocalAdminDisabledRule, wantErr: true},
-		"Error on creating sudoers and polkit base directory":       {makeReadOnly: ".", entries: defaultLocalAdminDisabledRule, wantErr: true},
-		"Error if can’t rename to destination for sudoers file":     {destIsDir: "sudoers.d/99-adsys-privilege-enforcement", entries: defaultLocalAdminDisabledRule, wantErr: true},
-		"Error if can’t rename to destination for polkit conf file": {destIsDir: "polkit-1/localauthority.conf.d/99-adsys-privilege-enforcement.conf", entries: defaultLocalAdminDisabledRule, wantErr: true},
+		"Error on writing to sudoers file":                             {makeReadOnly: "sudoers.d/", existingSudoersDir: "existing-files", existingPolkitDir: "existing-files", entries: defaultLocalAdminDisabledRule, allowAdminLockout: true, wantErr: true},
+		"Error on writing to polkit subdirectory creation":             {makeReadOnly: "polkit-1/", existingSudoersDir: "existing-files", existingPolkitDir: "only-base-polkit-dir", entries: defaultLocalAdminDisabledRule, allowAdminLockout: true, wantErr: true},
+		"Error on writing to polkit conf file":                         {makeReadOnly: "polkit-1/localauthority.conf.d", existingSudoersDir: "existing-files", existingPolkitDir: "existing-files", entries: defaultLocalAdminDisabledRule, allowAdminLockout: true, wantErr: true},
+		"Error on creating sudoers and polkit base directory":          {makeReadOnly: ".", entries: defaultLocalAdminDisabledRule, allowAdminLockout: true, wantErr: true},
+		"Error if can’t rename to destination for sudoers file":        {destIsDir: "sudoers.d/99-adsys-privilege-enforcement", entries: defaultLocalAdminDisabledRule, allowAdminLockout: true, wantErr: true},
+		"Error if can’t rename to destination for polkit conf file":    {destIsDir: "polkit-1/localauthority.conf.d/99-adsys-privilege-enforcement.conf", entries: defaultLocalAdminDisabledRule, allowAdminLockout: true, wantErr: true},
+		"Error if can’t rename to destination for polkit rules file":   {destIsDir: "polkit-1/rules.d/10-adsys-privilege-enforcement.rules", entries: []entry.Entry{{Key: "polkit-rules", Value: "org.freedesktop.login1.reboot yes alice@domain.com"}}, wantErr: true},
+		"Error on polkit rule with invalid action id":                  {entries: []entry.Entry{{Key: "polkit-rules", Value: "org.freedesktop.login1.* yes alice@domain.com"}}, wantErr: true},
+		"Error on polkit rule with action id not in reverse domain":    {entries: []entry.Entry{{Key: "polkit-rules", Value: "reboot yes alice@domain.com"}}, wantErr: true},
+		"Error on polkit rule with invalid result":                     {entries: []entry.Entry{{Key: "polkit-rules", Value: "org.freedesktop.login1.reboot auth_self alice@domain.com"}}, wantErr: true},
+		"Error on polkit rule without subject":                         {entries: []entry.Entry{{Key: "polkit-rules", Value: "org.freedesktop.login1.reboot yes"}}, wantErr: true},
+		"Error on polkit rule with only invalid subjects":              {entries: []entry.Entry{{Key: "polkit-rules", Value: "org.freedesktop.login1.reboot yes ;, :"}}, wantErr: true},
+		"Error on polkit action group with namespace without wildcard": {entries: []entry.Entry{{Key: "polkit-action-groups", Value: "printer-admins org.cups auth_self alice@domain.com"}}, wantErr: true},
+		"Error on polkit action group with top-level namespace":        {entries: []entry.Entry{{Key: "polkit-action-groups", Value: "printer-admins org.* auth_self alice@domain.com"}}, wantErr: true},
+		"Error on polkit action group with wildcard inside namespace":  {entries: []entry.Entry{{Key: "polkit-action-groups", Value: "printer-admins org.*.cups.* auth_self alice@domain.com"}}, wantErr: true},
+		"Error on polkit action group with invalid namespace in list":  {entries: []entry.Entry{{Key: "polkit-action-groups", Value: "printer-admins org.cups.*,cups auth_self alice@domain.com"}}, wantErr: true},
+		"Error on polkit action group with invalid result":             {entries: []entry.Entry{{Key: "polkit-action-groups", Value: "printer-admins org.cups.* auth_admin alice@domain.com"}}, wantErr: true},
+		"Error on polkit action group with invalid name":               {entries: []entry.Entry{{Key: "polkit-action-groups", Value: "printer/admins org.cups.* yes alice@domain.com"}}, wantErr: true},
+		"Error on polkit action group defined multiple times":          {entries: []entry.Entry{{Key: "polkit-action-groups", Value: "printer-admins org.cups.* yes alice@domain.com\nprinter-admins org.cups.* yes bob@domain.com"}}, wantErr: true},
+		"Error on polkit action group without subject":                 {entries: []entry.Entry{{Key: "polkit-action-groups", Value: "printer-admins org.cups.* yes"}}, wantErr: true},
+		"Error on polkit action group with only invalid subjects":      {entries: []entry.Entry{{Key: "polkit-action-groups", Value: "printer-admins org.cups.* yes ;, :"}}, wantErr: true},
+		"Error on invalid allow local admins value":                    {entries: []entry.Entry{{Key: "allow-local-admins", Value: "maybe"}}, wantErr: true},
+		"Error on runas user not resolving":                            {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com=(unknown)"}}, wantErr: true},
+		"Error on runas group not resolving":                           {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com=(svcacct:unknown)"}}, wantErr: true},
+		"Error on runas without user nor group":                        {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com=(:)"}}, wantErr: true},
+		"Error on invalid time to live":                                {entries: []entry.Entry{{Key: "client-admins", Value: "[ttl=soon]alice@domain.com"}}, wantErr: true},
+		"Error on negative time to live":                               {entries: []entry.Entry{{Key: "client-admins", Value: "[ttl=-1h]alice@domain.com"}}, wantErr: true},
+		"Error on invalid host filter":                                 {entries: []entry.Entry{{Key: "client-admins", Value: "[host=web-[]alice@domain.com"}}, wantErr: true},
+		"Error on scheduling expiry timer":                             {entries: []entry.Entry{{Key: "client-admins", Value: "[ttl=4h]alice@domain.com"}}, systemdFailing: true, wantErr: true},
+		"Error on removing expiry timer":                               {existingUnitDir: "existing-expiry-timer", systemdFailing: true, wantErr: true},
+		"Error on invalid sudoers snippet":                             {entries: []entry.Entry{{Key: "sudoers-snippets", Value: "developers\ninvalid.sudoers"}}, wantErr: true},
+		"Error on missing sudoers snippet":                             {entries: []entry.Entry{{Key: "sudoers-snippets", Value: "doesnotexist"}}, wantErr: true},
+		"Error on sudoers snippet path escaping the SYSVOL":            {entries: []entry.Entry{{Key: "sudoers-snippets", Value: "../scripts/script.sh"}}, wantErr: true},
+		"Error on absolute sudoers snippet path":                       {entries: []entry.Entry{{Key: "sudoers-snippets", Value: "/etc/sudoers"}}, wantErr: true},
+		"Error on sudoers snippets installed with the same name":       {entries: []entry.Entry{{Key: "sudoers-snippets", Value: "teams/ops.sudoers\nteams_ops_sudoers"}}, wantErr: true},
+		// polkit admin rules
+		"Set polkit admin rules with local admins": {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,%group@domain.com"}},
+			polkitAdminRules: true},
+		"Set polkit admin rules without local admins": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: "alice@domain.com,%group@domain.com,+sysadmins"}}, polkitAdminRules: true},
+		"Set polkit admin rules with time to live": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: "[ttl=4h]alice@domain.com,%group@domain.com"}}, polkitAdminRules: true},
+		"Set polkit admin rules next to polkit rules": {entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "polkit-rules", Value: "org.freedesktop.login1.reboot yes %sysadmins@domain.com"}}, polkitAdminRules: true},
+		"Overwrite existing polkit admin rules": {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com"}},
+			existingPolkitDir: "existing-polkit-admin-rules", polkitAdminRules: true},
+		"Allow local admins with no other rules doesn't set polkit admin rules": {entries: []entry.Entry{{Key: "allow-local-admins", Disabled: false}},
+			polkitAdminRules: true},
+		"Disabled polkit admin rules removes existing ones": {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com"}},
+			existingPolkitDir: "existing-polkit-admin-rules"},
+		"No rules removes existing polkit admin rules": {existingPolkitDir: "existing-polkit-admin-rules", polkitAdminRules: true},
+		"Error if can’t rename to destination for polkit admin rules file": {destIsDir: "polkit-1/rules.d/20-adsys-privilege-admins.rules",
+			entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com"}}, polkitAdminRules: true, wantErr: true},
+
+		// conflicting local sudoers rules
+		"Conflicting local sudoers files are kept": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: "alice@domain.com,%group@domain.com"}}, existingSudoersDir: "sudoers-conflicts", checkConflicts: true},
+
+		"Error on fetching sudoers snippets":  {entries: []entry.Entry{{Key: "sudoers-snippets", Value: "developers"}}, assetsDumperErr: true, wantErr: true},
+		"Error on invalid sudoers group file": {entries: []entry.Entry{{Key: "client-admins", Value: "%syntax error@domain.com"}}, sudoersPerGroup: true, wantErr: true},
+		"Error on disallowing local admins with only client admins with time to live": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: "[ttl=4h]alice@domain.com"}}, wantErr: true},
 	}
 
 	for name, tc := range tests {
@@ -94,6 +310,7 @@ func TestApplyPolicy(t *testing.T) {
 			tempEtc := t.TempDir()
 			sudoersDir := filepath.Join(tempEtc, "sudoers.d")
 			policyKitDir := filepath.Join(tempEtc, "polkit-1")
+			systemUnitDir := filepath.Join(tempEtc, "systemd", "system")
 
 			if tc.existingSudoersDir != "" {
 				require.NoError(t,
@@ -109,6 +326,13 @@ func TestApplyPolicy(t *testing.T) {
 						&shutil.CopyTreeOptions{Symlinks: true, CopyFunction: shutil.Copy}),
 					"Setup: can't create initial polkit directory")
 			}
+			if tc.existingUnitDir != "" {
+				require.NoError(t,
+					shutil.CopyTree(
+						filepath.Join("testdata", tc.existingUnitDir, "systemd"), filepath.Join(tempEtc, "systemd"),
+						&shutil.CopyTreeOptions{Symlinks: true, CopyFunction: shutil.Copy}),
+					"Setup: can't create initial systemd unit directory")
+			}
 			// make read only destination to not be able to overwrite or write into it
 			if tc.makeReadOnly != "" {
 				testutils.MakeReadOnly(t, filepath.Join(tempEtc, tc.makeReadOnly))
@@ -119,15 +343,935 @@ func TestApplyPolicy(t *testing.T) {
 				require.NoError(t, os.MkdirAll(filepath.Join(tempEtc, tc.destIsDir), 0750), "Setup: can't create fake unwritable file")
 			}
 
-			m := privilege.NewWithDirs(sudoersDir, policyKitDir)
-			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.notComputer, tc.entries)
+			m := privilege.NewWithDirs(sudoersDir, policyKitDir,
+				privilege.WithUserLookup(mockUserLookup), privilege.WithGroupLookup(mockGroupLookup),
+				privilege.WithAllowAdminLockout(tc.allowAdminLockout),
+				privilege.WithSudoersPerGroup(tc.sudoersPerGroup),
+				privilege.WithSudoersAliasThreshold(tc.aliasThreshold),
+				privilege.WithSudoersConflictsCheck(tc.checkConflicts),
+				privilege.WithPolkitAdminRules(tc.polkitAdminRules),
+				privilege.WithProtectedUsers(tc.protectedUsers...),
+				privilege.WithSystemUnitDir(systemUnitDir),
+				privilege.WithSystemdCaller(mockSystemdCaller{failing: tc.systemdFailing}),
+				privilege.WithHostnames("web-01", "web-01.domain.com"),
+				privilege.WithTimeNow(mockNow),
+				privilege.WithVisudoCmd(mockVisudoCmd()))
+			mockAssetsDumper := testutils.MockAssetsDumper{T: t, Err: tc.assetsDumperErr, Path: "sudoers/"}
+			hints := &restart.Collector{}
+			err := m.ApplyPolicy(restart.WithCollector(context.Background(), hints), "ubuntu", !tc.notComputer, tc.entries, mockAssetsDumper.SaveAssetsTo)
 			if tc.wantErr {
 				require.NotNil(t, err, "ApplyPolicy should have failed but didn't")
 				return
 			}
 			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			// Privileges are checked by sudo and polkit on each request.
+			require.Empty(t, hints.Hints(), "ApplyPolicy should not require a restart")
 
+			normalizeExpiryService(t, tempEtc)
 			testutils.CompareTreesWithFiltering(t, tempEtc, testutils.GoldenPath(t), testutils.UpdateEnabled())
 		})
 	}
 }
+
+func TestApplyPolicyResetsGrantsExpiry(t *testing.T) {
+	t.Parallel()
+
+	entries := []entry.Entry{{Key: "client-admins", Value: "[ttl=4h]alice@domain.com,bob@domain.com"}}
+
+	tempEtc := t.TempDir()
+	now := mockNow()
+	m := privilege.NewWithDirs(filepath.Join(tempEtc, "sudoers.d"), filepath.Join(tempEtc, "polkit-1"),
+		privilege.WithSystemUnitDir(filepath.Join(tempEtc, "systemd", "system")),
+		privilege.WithSystemdCaller(mockSystemdCaller{}),
+		privilege.WithTimeNow(func() time.Time { return now }))
+
+	err := m.ApplyPolicy(context.Background(), "ubuntu", true, entries, nil)
+	require.NoError(t, err, "Setup: first ApplyPolicy failed but shouldn't have")
+
+	// A refresh re-including the temporary admin an hour later postpones its expiry.
+	now = now.Add(time.Hour)
+	err = m.ApplyPolicy(context.Background(), "ubuntu", true, entries, nil)
+	require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+
+	normalizeExpiryService(t, tempEtc)
+	testutils.CompareTreesWithFiltering(t, tempEtc, testutils.GoldenPath(t), testutils.UpdateEnabled())
+}
+
+func TestApplyPolicyWithCooldown(t *testing.T) {
+	t.Parallel()
+
+	alice := entry.Entry{Key: "client-admins", Value: "alice@domain.com"}
+	bob := entry.Entry{Key: "client-admins", Value: "bob@domain.com"}
+	polkitRules := entry.Entry{Key: "polkit-rules", Value: "org.freedesktop.systemd1.manage-units yes %sysadmins@domain.com"}
+
+	tests := map[string]struct {
+		cooldown     time.Duration
+		firstEntries []entry.Entry
+		tamper       bool
+
+		secondEntries []entry.Entry
+
+		wantRewritten bool
+	}{
+		"Same policy within the cooldown is not rewritten":                    {cooldown: time.Hour},
+		"Same policy with several files within the cooldown is not rewritten": {cooldown: time.Hour, firstEntries: []entry.Entry{alice, polkitRules}, secondEntries: []entry.Entry{alice, polkitRules}},
+
+		"Same policy without cooldown is rewritten":       {wantRewritten: true},
+		"Changed policy within the cooldown is rewritten": {cooldown: time.Hour, secondEntries: []entry.Entry{bob}, wantRewritten: true},
+		"Removed file within the cooldown is removed":     {cooldown: time.Hour, firstEntries: []entry.Entry{alice, polkitRules}, wantRewritten: true},
+		"Tampered file is rewritten within the cooldown":  {cooldown: time.Hour, tamper: true, wantRewritten: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.firstEntries == nil {
+				tc.firstEntries = []entry.Entry{alice}
+			}
+			if tc.secondEntries == nil {
+				tc.secondEntries = []entry.Entry{alice}
+			}
+
+			tempEtc := t.TempDir()
+			sudoersConf := filepath.Join(tempEtc, "sudoers.d", "99-adsys-privilege-enforcement")
+			polkitRulesPath := filepath.Join(tempEtc, "polkit-1", "rules.d", "10-adsys-privilege-enforcement.rules")
+			m := privilege.NewWithDirs(filepath.Join(tempEtc, "sudoers.d"), filepath.Join(tempEtc, "polkit-1"),
+				privilege.WithUserLookup(mockUserLookup), privilege.WithGroupLookup(mockGroupLookup),
+				privilege.WithCooldown(cooldown.New(t.TempDir(), tc.cooldown)))
+
+			err := m.ApplyPolicy(context.Background(), "ubuntu", true, tc.firstEntries, nil)
+			require.NoError(t, err, "Setup: first ApplyPolicy failed but shouldn't have")
+			if tc.tamper {
+				// The sudoers file is read-only: replace it as an editor would.
+				tampered := sudoersConf + ".tampered"
+				require.NoError(t, os.WriteFile(tampered, []byte("\"eve@domain.com\"	ALL=(ALL:ALL) ALL\n"), 0600), "Setup: can't write tampered sudoers file")
+				require.NoError(t, os.Rename(tampered, sudoersConf), "Setup: can't tamper sudoers file")
+			}
+			before, err := os.Stat(sudoersConf)
+			require.NoError(t, err, "Setup: can't stat sudoers file")
+
+			// GPO versions flapping apply the policy again right after the first one.
+			err = m.ApplyPolicy(context.Background(), "ubuntu", true, tc.secondEntries, nil)
+			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+
+			after, err := os.Stat(sudoersConf)
+			require.NoError(t, err, "Sudoers file should exist")
+			if !tc.wantRewritten {
+				require.True(t, os.SameFile(before, after), "Sudoers file should not be rewritten within the cooldown")
+				return
+			}
+			require.False(t, os.SameFile(before, after), "Sudoers file should be rewritten")
+
+			got, err := os.ReadFile(sudoersConf)
+			require.NoError(t, err, "Sudoers file should be readable")
+			require.NotContains(t, string(got), "eve@domain.com", "Sudoers file should be rewritten with the policy")
+			for _, e := range tc.secondEntries {
+				if e.Key == "client-admins" {
+					require.Contains(t, string(got), e.Value, "Sudoers file should be rewritten with the policy")
+				}
+			}
+			if !slices.Contains(tc.secondEntries, polkitRules) {
+				require.NoFileExists(t, polkitRulesPath, "Polkit rules file should be removed")
+			}
+		})
+	}
+}
+
+func TestApplyPolicyWithLocallyModifiedFiles(t *testing.T) {
+	t.Parallel()
+
+	alice := entry.Entry{Key: "client-admins", Value: "alice@domain.com"}
+	bob := entry.Entry{Key: "client-admins", Value: "bob@domain.com"}
+	localEdit := "\"eve@domain.com\"	ALL=(ALL:ALL) ALL\n"
+
+	tests := map[string]struct {
+		mode          tamper.Mode
+		secondEntries []entry.Entry
+
+		wantLocalEdit bool
+		wantRemoved   bool
+		wantBackup    bool
+	}{
+		"Modified file is overwritten by default":                  {},
+		"Modified file is kept in preserve mode":                   {mode: tamper.Preserve, wantLocalEdit: true},
+		"Modified file is backed up in backup-then-overwrite mode": {mode: tamper.BackupThenOverwrite, wantBackup: true},
+		"Modified file is removed with the policy by default":      {secondEntries: []entry.Entry{}, wantRemoved: true},
+		"Modified file is kept on policy removal in preserve mode": {mode: tamper.Preserve, secondEntries: []entry.Entry{}, wantLocalEdit: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.secondEntries == nil {
+				tc.secondEntries = []entry.Entry{bob}
+			}
+
+			tempEtc := t.TempDir()
+			sudoersConf := filepath.Join(tempEtc, "sudoers.d", "99-adsys-privilege-enforcement")
+			modes := map[string]tamper.Mode{"sysctl": tamper.Preserve}
+			if tc.mode != "" {
+				modes["privilege"] = tc.mode
+			}
+			g, err := tamper.New(t.TempDir(), modes)
+			require.NoError(t, err, "Setup: can't create tamper guard")
+			ctx := tamper.WithGuard(context.Background(), g)
+			m := privilege.NewWithDirs(filepath.Join(tempEtc, "sudoers.d"), filepath.Join(tempEtc, "polkit-1"),
+				privilege.WithUserLookup(mockUserLookup), privilege.WithGroupLookup(mockGroupLookup))
+
+			err = m.ApplyPolicy(ctx, "ubuntu", true, []entry.Entry{alice}, nil)
+			require.NoError(t, err, "Setup: first ApplyPolicy failed but shouldn't have")
+			// The sudoers file is read-only: replace it as an editor would.
+			tampered := sudoersConf + ".tampered"
+			require.NoError(t, os.WriteFile(tampered, []byte(localEdit), 0600), "Setup: can't write modified sudoers file")
+			require.NoError(t, os.Rename(tampered, sudoersConf), "Setup: can't modify sudoers file")
+
+			err = m.ApplyPolicy(ctx, "ubuntu", true, tc.secondEntries, nil)
+			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+
+			if tc.wantRemoved {
+				require.NoFileExists(t, sudoersConf, "Sudoers file should be removed")
+			} else {
+				got, err := os.ReadFile(sudoersConf)
+				require.NoError(t, err, "Sudoers file should be readable")
+				if tc.wantLocalEdit {
+					require.Equal(t, localEdit, string(got), "Sudoers file should keep the local changes")
+				} else {
+					require.Contains(t, string(got), "bob@domain.com", "Sudoers file should be rewritten with the policy")
+				}
+			}
+
+			if !tc.wantBackup {
+				require.NoFileExists(t, sudoersConf+tamper.BackupSuffix, "No backup should be created")
+				return
+			}
+			got, err := os.ReadFile(sudoersConf + tamper.BackupSuffix)
+			require.NoError(t, err, "Backup should be created")
+			require.Equal(t, localEdit, string(got), "Backup should have the local changes")
+
+			// The backup is not an installed sudoers file to clean up.
+			err = m.ApplyPolicy(ctx, "ubuntu", true, tc.secondEntries, nil)
+			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			require.FileExists(t, sudoersConf+tamper.BackupSuffix, "Backup should be kept")
+		})
+	}
+}
+
+func TestApplyPolicyExportsGrants(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var metrics []string
+	collector := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		metrics = append(metrics, string(body))
+	}))
+	defer collector.Close()
+	exporter, err := telemetry.New(collector.URL, "myhost")
+	require.NoError(t, err, "Setup: can't create telemetry exporter")
+
+	tempEtc := t.TempDir()
+	m := privilege.NewWithDirs(filepath.Join(tempEtc, "sudoers.d"), filepath.Join(tempEtc, "polkit-1"),
+		privilege.WithUserLookup(mockUserLookup), privilege.WithGroupLookup(mockGroupLookup),
+		privilege.WithTelemetry(exporter))
+
+	entries := []entry.Entry{
+		{Key: "allow-local-admins", Disabled: true},
+		{Key: "client-admins", Value: "alice@domain.com, %group@domain.com"},
+	}
+	// The policy is applied, applied again unchanged, then removed.
+	for _, e := range [][]entry.Entry{entries, entries, {}} {
+		require.NoError(t, m.ApplyPolicy(context.Background(), "ubuntu", true, e, nil), "ApplyPolicy failed but shouldn't have")
+	}
+	// Users have no privilege policy.
+	require.NoError(t, m.ApplyPolicy(context.Background(), "bob", false, entries, nil), "ApplyPolicy failed but shouldn't have")
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, metrics, 3, "The grants should be exported after each apply of the machine policy")
+
+	// Each apply exports the value of the gauges, and whether it changed the privilege files.
+	want := []map[string]string{
+		{"adsys.privilege.client_admins": "2", "adsys.privilege.local_admins_allowed": "0", "adsys.policy.changed": "true"},
+		{"adsys.privilege.client_admins": "2", "adsys.privilege.local_admins_allowed": "0", "adsys.policy.changed": "false"},
+		{"adsys.privilege.client_admins": "0", "adsys.privilege.local_admins_allowed": "1", "adsys.policy.changed": "true"},
+	}
+	for i, m := range metrics {
+		var req struct {
+			ResourceMetrics []struct {
+				ScopeMetrics []struct {
+					Metrics []struct {
+						Name  string
+						Gauge struct {
+							DataPoints []struct {
+								AsInt      string
+								Attributes []struct {
+									Key   string
+									Value struct{ StringValue string }
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+		require.NoError(t, json.Unmarshal([]byte(m), &req), "Exported metrics should be valid JSON")
+		got := make(map[string]string)
+		for _, metric := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+			require.Len(t, metric.Gauge.DataPoints, 1, "Each gauge should have one data point")
+			got[metric.Name] = metric.Gauge.DataPoints[0].AsInt
+			for _, a := range metric.Gauge.DataPoints[0].Attributes {
+				got[a.Key] = a.Value.StringValue
+			}
+		}
+		require.Equal(t, want[i], got, "Apply %d should export the expected grants", i)
+	}
+}
+
+func TestApplyPolicyKeepsSudoersSnippetsOnValidationFailure(t *testing.T) {
+	t.Parallel()
+
+	tempEtc := t.TempDir()
+	sudoersDir := filepath.Join(tempEtc, "sudoers.d")
+	testutils.Copy(t, filepath.Join("testdata", "existing-sudoers-snippets", "sudoers.d"), sudoersDir)
+
+	m := privilege.NewWithDirs(sudoersDir, filepath.Join(tempEtc, "polkit-1"), privilege.WithVisudoCmd(mockVisudoCmd()))
+	mockAssetsDumper := testutils.MockAssetsDumper{T: t, Path: "sudoers/"}
+	err := m.ApplyPolicy(context.Background(), "ubuntu", true, []entry.Entry{{Key: "sudoers-snippets", Value: "teams/ops.sudoers\ninvalid.sudoers"}}, mockAssetsDumper.SaveAssetsTo)
+	require.Error(t, err, "ApplyPolicy should have failed but didn't")
+
+	// The installed snippets are unchanged, including the ones which would have been removed.
+	for _, name := range []string{"99-adsys-privilege-enforcement-snippet-developers", "99-adsys-privilege-enforcement-snippet-teams_ops_sudoers"} {
+		want, err := os.ReadFile(filepath.Join("testdata", "existing-sudoers-snippets", "sudoers.d", name))
+		require.NoError(t, err, "Setup: can't read initial sudoers snippet")
+		got, err := os.ReadFile(filepath.Join(sudoersDir, name))
+		require.NoError(t, err, "Installed sudoers snippet should not have been removed")
+		require.Equal(t, string(want), string(got), "Installed sudoers snippet should not have been changed")
+	}
+}
+
+func TestApplyPolicySudoersDirNotWritable(t *testing.T) {
+	t.Parallel()
+
+	entries := []entry.Entry{{Key: "allow-local-admins", Disabled: true}, {Key: "client-admins", Value: "alice@domain.com"}}
+
+	tests := map[string]struct {
+		probeErr   error
+		noEntries  bool
+		noExisting bool
+
+		wantErr         bool
+		wantReadOnlyErr bool
+	}{
+		"Error on read-only mount":                         {probeErr: syscall.EROFS, wantErr: true, wantReadOnlyErr: true},
+		"Error on immutable directory":                     {probeErr: syscall.EPERM, wantErr: true, wantReadOnlyErr: true},
+		"Error on permission denied":                       {probeErr: syscall.EACCES, wantErr: true, wantReadOnlyErr: true},
+		"Error on removing files from read-only directory": {probeErr: syscall.EROFS, noEntries: true, wantErr: true, wantReadOnlyErr: true},
+		"Error on other failures is returned as is":        {probeErr: syscall.EIO, wantErr: true},
+		"No entries and no existing files is a noop":       {probeErr: syscall.EROFS, noEntries: true, noExisting: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempEtc := filepath.Join(t.TempDir(), "etc")
+			sudoersDir := filepath.Join(tempEtc, "sudoers.d")
+			if tc.noExisting {
+				require.NoError(t, os.MkdirAll(tempEtc, 0750), "Setup: can't create etc directory")
+			} else {
+				testutils.Copy(t, filepath.Join("testdata", "existing-files"), tempEtc)
+			}
+			initial := filepath.Join(t.TempDir(), "initial")
+			testutils.Copy(t, tempEtc, initial)
+
+			m := privilege.NewWithDirs(sudoersDir, filepath.Join(tempEtc, "polkit-1"),
+				privilege.WithUserLookup(mockUserLookup), privilege.WithGroupLookup(mockGroupLookup),
+				privilege.WithSystemUnitDir(filepath.Join(tempEtc, "systemd", "system")),
+				privilege.WithSystemdCaller(mockSystemdCaller{}),
+				privilege.WithProbeDir(func(dir string) error {
+					return &fs.PathError{Op: "open", Path: filepath.Join(dir, ".adsys-probe"), Err: tc.probeErr}
+				}))
+
+			e := entries
+			if tc.noEntries {
+				e = nil
+			}
+			err := m.ApplyPolicy(context.Background(), "ubuntu", true, e, nil)
+			if !tc.wantErr {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			} else {
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+				require.ErrorIs(t, err, tc.probeErr, "ApplyPolicy should return the underlying error")
+				require.Equal(t, tc.wantReadOnlyErr, strings.Contains(err.Error(), "read-only or immutable"),
+					"ApplyPolicy should only return an actionable error on read-only or immutable directory")
+			}
+
+			// Neither the sudoers nor the polkit files are changed.
+			testutils.CompareTreesWithFiltering(t, tempEtc, initial, false)
+		})
+	}
+}
+
+func TestApplyPolicyRecordsIgnoredEntries(t *testing.T) {
+	t.Parallel()
+
+	entries := []entry.Entry{
+		{Key: "allow-local-admins", Disabled: true},
+		{Key: "client-admins", Value: `alice@domain.com,DOMAIN\bob, ::? ,carole|@domain.com, dave@domain.com, +sys|admins`},
+	}
+	want := []ignored.Entry{
+		{Rule: "privilege", Key: "client-admins", Value: "+sys|admins", Reason: ignored.InvalidValue, Details: "invalid netgroup name"},
+		{Rule: "privilege", Key: "client-admins", Value: "::?", Reason: ignored.InvalidValue, Details: "only made of invalid characters"},
+		{Rule: "privilege", Key: "client-admins", Value: `DOMAIN\bob`, Reason: ignored.NormalizedIdentity, Details: `changed to "bob@DOMAIN"`},
+		{Rule: "privilege", Key: "client-admins", Value: "carole|@domain.com", Reason: ignored.NormalizedIdentity, Details: `changed to "carole@domain.com"`},
+	}
+
+	tempEtc := t.TempDir()
+	m := privilege.NewWithDirs(filepath.Join(tempEtc, "sudoers.d"), filepath.Join(tempEtc, "polkit-1"),
+		privilege.WithSystemUnitDir(filepath.Join(tempEtc, "systemd", "system")),
+		privilege.WithSystemdCaller(mockSystemdCaller{}))
+
+	// Normalized names are reported on each refresh, even if the value is unchanged.
+	for i := 0; i < 2; i++ {
+		collector := &ignored.Collector{}
+		err := m.ApplyPolicy(ignored.WithCollector(context.Background(), collector), "ubuntu", true, entries, nil)
+		require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+		require.Equal(t, want, collector.Entries(), "ApplyPolicy should have recorded the normalized and dropped names")
+	}
+}
+
+func TestApplyPolicyPolkitIdentitiesAreStable(t *testing.T) {
+	t.Parallel()
+
+	values := []string{
+		"alice@domain.com,%group@domain.com,domain\\bob",
+		"domain\\bob\n%group@domain.com,alice@domain.com",
+		"%group@domain.com,alice@domain.com,domain\\bob,alice@domain.com",
+	}
+
+	var want string
+	for i, v := range values {
+		tempEtc := t.TempDir()
+		policyKitDir := filepath.Join(tempEtc, "polkit-1")
+		testutils.Copy(t, filepath.Join("testdata", "existing-previous-local-admins-multi", "polkit-1"), policyKitDir)
+
+		m := privilege.NewWithDirs(filepath.Join(tempEtc, "sudoers.d"), policyKitDir,
+			privilege.WithSystemUnitDir(filepath.Join(tempEtc, "systemd", "system")),
+			privilege.WithSystemdCaller(mockSystemdCaller{}))
+		err := m.ApplyPolicy(context.Background(), "ubuntu", true, []entry.Entry{{Key: "client-admins", Value: v}}, nil)
+		require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+
+		got, err := os.ReadFile(filepath.Join(policyKitDir, "localauthority.conf.d", "99-adsys-privilege-enforcement.conf"))
+		require.NoError(t, err, "polkit configuration should have been written")
+		if i == 0 {
+			want = string(got)
+			require.Contains(t, want, "AdminIdentities=unix-group:group@domain.com;unix-user:alice@domain.com;unix-user:bob@domain;",
+				"polkit identities should be sorted")
+			continue
+		}
+		require.Equal(t, want, string(got), "polkit configuration should not depend on the order of the client administrators")
+	}
+}
+
+func TestApplyPolicyReportOnly(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		entries       []entry.Entry
+		existingFiles []string
+	}{
+		"No existing files":                     {entries: []entry.Entry{{Key: "allow-local-admins", Disabled: true}, {Key: "client-admins", Value: "alice@domain.com"}}},
+		"Existing files are kept":               {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,%group@domain.com"}}, existingFiles: []string{"existing-files"}},
+		"Existing files are kept on no entries": {existingFiles: []string{"existing-files"}},
+		"Expiry timer is not scheduled":         {entries: []entry.Entry{{Key: "client-admins", Value: "[ttl=4h]alice@domain.com"}}},
+		"Expiry timer is not removed":           {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com"}}, existingFiles: []string{"existing-files", "existing-expiry-timer"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempEtc := t.TempDir()
+			for _, dir := range tc.existingFiles {
+				subdirs, err := os.ReadDir(filepath.Join("testdata", dir))
+				require.NoError(t, err, "Setup: can't read initial files")
+				for _, d := range subdirs {
+					require.NoError(t,
+						shutil.CopyTree(
+							filepath.Join("testdata", dir, d.Name()), filepath.Join(tempEtc, d.Name()),
+							&shutil.CopyTreeOptions{Symlinks: true, CopyFunction: shutil.Copy}),
+						"Setup: can't create initial files")
+				}
+			}
+			initial := filepath.Join(t.TempDir(), "initial")
+			require.NoError(t, shutil.CopyTree(tempEtc, initial, &shutil.CopyTreeOptions{Symlinks: true, CopyFunction: shutil.Copy}),
+				"Setup: can't save initial files")
+
+			m := privilege.NewWithDirs(filepath.Join(tempEtc, "sudoers.d"), filepath.Join(tempEtc, "polkit-1"),
+				privilege.WithUserLookup(mockUserLookup), privilege.WithGroupLookup(mockGroupLookup),
+				privilege.WithReportOnly(true),
+				privilege.WithSystemUnitDir(filepath.Join(tempEtc, "systemd", "system")),
+				// Any systemd call would fail.
+				privilege.WithSystemdCaller(mockSystemdCaller{failing: true}),
+				privilege.WithTimeNow(mockNow))
+
+			// Every refresh only reports the changes.
+			for i := 0; i < 3; i++ {
+				err := m.ApplyPolicy(context.Background(), "ubuntu", true, tc.entries, nil)
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			testutils.CompareTreesWithFiltering(t, tempEtc, initial, false)
+		})
+	}
+}
+
+func TestManagedFiles(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		existingSudoersDir string
+		existingPolkitDir  string
+		sudoersIsDir       bool
+
+		wantErr bool
+	}{
+		"Return content of existing files":     {existingSudoersDir: "existing-files", existingPolkitDir: "existing-files"},
+		"Return only sudoers file":             {existingSudoersDir: "existing-files"},
+		"Missing files are returned as absent": {},
+		"Other files are not returned":         {existingSudoersDir: "existing-other-files", existingPolkitDir: "existing-other-files"},
+
+		"Error on unreadable file": {sudoersIsDir: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempEtc := t.TempDir()
+			sudoersDir := filepath.Join(tempEtc, "sudoers.d")
+			policyKitDir := filepath.Join(tempEtc, "polkit-1")
+			if tc.existingSudoersDir != "" {
+				testutils.Copy(t, filepath.Join("testdata", tc.existingSudoersDir, "sudoers.d"), sudoersDir)
+			}
+			if tc.existingPolkitDir != "" {
+				testutils.Copy(t, filepath.Join("testdata", tc.existingPolkitDir, "polkit-1"), policyKitDir)
+			}
+			if tc.sudoersIsDir {
+				require.NoError(t, os.MkdirAll(filepath.Join(sudoersDir, "99-adsys-privilege-enforcement"), 0750), "Setup: can't create sudoers file as a directory")
+			}
+
+			m := privilege.NewWithDirs(sudoersDir, policyKitDir)
+			got, err := m.ManagedFiles(context.Background())
+			if tc.wantErr {
+				require.Error(t, err, "ManagedFiles should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "ManagedFiles failed but shouldn't have")
+
+			// Make the paths independent of the temporary directory.
+			for i := range got {
+				got[i].Path = strings.TrimPrefix(got[i].Path, tempEtc+"/")
+			}
+			want := testutils.LoadWithUpdateFromGoldenYAML(t, got)
+			require.Equal(t, want, got, "ManagedFiles should return the expected files")
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{
+		{Key: "client-admins", Value: "alice@domain.com,[ttl=4h]bob@domain.com"},
+		{Key: "polkit-rules", Value: "org.freedesktop.login1.reboot yes alice@domain.com"},
+		{Key: "sudoers-snippets", Value: "developers"},
+	}
+
+	tests := map[string]struct {
+		entries          []entry.Entry
+		tamper           map[string]*string
+		polkitAdminRules bool
+
+		wantDrifted []string
+		wantErr     bool
+	}{
+		"Files written by the policy are not drifted":                              {},
+		"Temporary grants applied earlier are not drifted":                         {entries: []entry.Entry{{Key: "client-admins", Value: "[ttl=4h]alice@domain.com"}}},
+		"No policy and no file":                                                    {entries: []entry.Entry{}},
+		"Polkit admin rules with temporary grants applied earlier are not drifted": {polkitAdminRules: true},
+
+		"Tampered sudoers file is detected": {
+			tamper:      map[string]*string{"sudoers.d/99-adsys-privilege-enforcement": ptr("\"eve@domain.com\"	ALL=(ALL:ALL) ALL\n")},
+			wantDrifted: []string{"sudoers.d/99-adsys-privilege-enforcement"}},
+		"Removed polkit rules are detected": {
+			tamper:      map[string]*string{"polkit-1/rules.d/10-adsys-privilege-enforcement.rules": nil},
+			wantDrifted: []string{"polkit-1/rules.d/10-adsys-privilege-enforcement.rules"}},
+		"Tampered polkit admin rules are detected": {
+			polkitAdminRules: true,
+			tamper:           map[string]*string{"polkit-1/rules.d/20-adsys-privilege-admins.rules": ptr("polkit.addAdminRule(function(action, subject) {\n    return [\"unix-user:eve\"];\n});\n")},
+			wantDrifted:      []string{"polkit-1/rules.d/20-adsys-privilege-admins.rules"}},
+		"Tampered sudoers snippet is detected": {
+			tamper:      map[string]*string{"sudoers.d/99-adsys-privilege-enforcement-snippet-developers": ptr("%developers ALL=(ALL:ALL) NOPASSWD: ALL\n")},
+			wantDrifted: []string{"sudoers.d/99-adsys-privilege-enforcement-snippet-developers"}},
+		"Unknown sudoers snippet is detected": {
+			tamper:      map[string]*string{"sudoers.d/99-adsys-privilege-enforcement-snippet-other": ptr("eve ALL=(ALL:ALL) ALL\n")},
+			wantDrifted: []string{"sudoers.d/99-adsys-privilege-enforcement-snippet-other"}},
+		"Files left without policy are detected": {
+			entries:     []entry.Entry{},
+			tamper:      map[string]*string{"polkit-1/localauthority.conf.d/99-adsys-privilege-enforcement.conf": ptr("[Configuration]\nAdminIdentities=unix-user:eve\n")},
+			wantDrifted: []string{"polkit-1/localauthority.conf.d/99-adsys-privilege-enforcement.conf"}},
+
+		"Error on invalid policy": {entries: []entry.Entry{{Key: "polkit-rules", Value: "reboot yes alice@domain.com"}}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			tempEtc := t.TempDir()
+			// Temporary grants are applied and verified at different times.
+			now := mockNow()
+			m := privilege.NewWithDirs(filepath.Join(tempEtc, "sudoers.d"), filepath.Join(tempEtc, "polkit-1"),
+				privilege.WithUserLookup(mockUserLookup), privilege.WithGroupLookup(mockGroupLookup),
+				privilege.WithSystemUnitDir(filepath.Join(tempEtc, "systemd", "system")),
+				privilege.WithSystemdCaller(mockSystemdCaller{}),
+				privilege.WithPolkitAdminRules(tc.polkitAdminRules),
+				privilege.WithTimeNow(func() time.Time { now = now.Add(time.Hour); return now }),
+				privilege.WithVisudoCmd(mockVisudoCmd()))
+			mockAssetsDumper := testutils.MockAssetsDumper{T: t, Path: "sudoers/"}
+
+			if !tc.wantErr {
+				err := m.ApplyPolicy(context.Background(), "ubuntu", true, tc.entries, mockAssetsDumper.SaveAssetsTo)
+				require.NoError(t, err, "Setup: ApplyPolicy failed but shouldn't have")
+			}
+			for p, content := range tc.tamper {
+				p = filepath.Join(tempEtc, p)
+				if content == nil {
+					require.NoError(t, os.Remove(p), "Setup: can't remove managed file")
+					continue
+				}
+				require.NoError(t, os.MkdirAll(filepath.Dir(p), 0750), "Setup: can't create managed file directory")
+				// Managed files can be read-only: replace them as an editor would.
+				require.NoError(t, os.WriteFile(p+".tampered", []byte(*content), 0600), "Setup: can't write tampered file")
+				require.NoError(t, os.Rename(p+".tampered", p), "Setup: can't tamper managed file")
+			}
+
+			files, err := m.Verify(context.Background(), tc.entries, mockAssetsDumper.SaveAssetsTo)
+			if tc.wantErr {
+				require.Error(t, err, "Verify should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "Verify failed but shouldn't have")
+			require.Equal(t, tc.wantDrifted, driftedPaths(files, tempEtc), "Verify should report the expected drifted files")
+
+			// Applying the policy again fixes the drifted files.
+			err = m.ApplyPolicy(context.Background(), "ubuntu", true, tc.entries, mockAssetsDumper.SaveAssetsTo)
+			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			files, err = m.Verify(context.Background(), tc.entries, mockAssetsDumper.SaveAssetsTo)
+			require.NoError(t, err, "Verify failed but shouldn't have")
+			require.Empty(t, driftedPaths(files, tempEtc), "Verify should not report any drifted file once the policy is applied again")
+		})
+	}
+}
+
+// driftedPaths returns the paths, relative to root, of the drifted files.
+func driftedPaths(files []integrity.File, root string) (paths []string) {
+	for _, f := range files {
+		if f.Drifted() {
+			paths = append(paths, strings.TrimPrefix(f.Path, root+"/"))
+		}
+	}
+	return paths
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestAdminIdentities(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		existingPolkitDir string
+		polkitConfIsDir   bool
+
+		want    []string
+		wantErr bool
+	}{
+		"Return identities written by adsys":                   {existingPolkitDir: "existing-previous-local-admins-with-adsys-file", want: []string{"unix-user:shouldbeignored"}},
+		"Return system identities without adsys configuration": {existingPolkitDir: "existing-previous-local-admins-multi", want: []string{"unix-user:local50admin1", "unix-user:local50admin2"}},
+		"No identities without polkit configuration":           {want: nil},
+		"Directories in system configuration are ignored":      {existingPolkitDir: "incorrect-policikit-conf-is-dir", want: nil},
+
+		"Error on adsys configuration being a directory": {polkitConfIsDir: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			policyKitDir := filepath.Join(t.TempDir(), "polkit-1")
+			if tc.existingPolkitDir != "" {
+				testutils.Copy(t, filepath.Join("testdata", tc.existingPolkitDir, "polkit-1"), policyKitDir)
+			}
+			if tc.polkitConfIsDir {
+				require.NoError(t, os.MkdirAll(filepath.Join(policyKitDir, "localauthority.conf.d", "99-adsys-privilege-enforcement.conf"), 0750),
+					"Setup: can't create polkit configuration as a directory")
+			}
+
+			m := privilege.NewWithDirs(t.TempDir(), policyKitDir)
+			got, err := m.AdminIdentities(context.Background())
+			if tc.wantErr {
+				require.Error(t, err, "AdminIdentities should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "AdminIdentities failed but shouldn't have")
+			require.Equal(t, tc.want, got, "AdminIdentities should return the expected identities")
+		})
+	}
+}
+
+func TestSystemAdminIdentities(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		existingPolkitDir string
+
+		want []privilege.SystemAdminIdentities
+	}{
+		"Report the single system file": {existingPolkitDir: "existing-previous-local-admins-one", want: []privilege.SystemAdminIdentities{
+			{Path: "50-local-admins.conf", Value: "unix-user:local50admin1;unix-user:local50admin2", Preserved: true},
+		}},
+		"Report all system files, highest one is preserved": {existingPolkitDir: "existing-previous-local-admins-multi", want: []privilege.SystemAdminIdentities{
+			{Path: "40-local-admins.conf", Value: "unix-user:local40admin1;unix-user:local40admin2"},
+			{Path: "50-local-admins.conf", Value: "unix-user:local50admin1;unix-user:local50admin2", Preserved: true},
+		}},
+		"Report only files setting identities and ignore adsys file": {existingPolkitDir: "existing-duplicated-system-admins", want: []privilege.SystemAdminIdentities{
+			{Path: "10-tool-a.conf", Value: "unix-group:toola"},
+			{Path: "50-tool-b.conf", Value: "unix-user:toolb1;unix-user:toolb2", Preserved: true},
+		}},
+		"No file is preserved if highest one sets no identities": {existingPolkitDir: "existing-system-admins-highest-unset", want: []privilege.SystemAdminIdentities{
+			{Path: "10-tool-a.conf", Value: "unix-group:toola"},
+			{Path: "50-tool-b.conf", Value: "unix-user:toolb"},
+		}},
+		"No file without system identities":        {existingPolkitDir: "existing-other-files"},
+		"No file without polkit configuration":     {},
+		"Directories in configuration are ignored": {existingPolkitDir: "incorrect-policikit-conf-is-dir"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			policyKitDir := filepath.Join(t.TempDir(), "polkit-1")
+			if tc.existingPolkitDir != "" {
+				testutils.Copy(t, filepath.Join("testdata", tc.existingPolkitDir, "polkit-1"), policyKitDir)
+			}
+			for i := range tc.want {
+				tc.want[i].Path = filepath.Join(policyKitDir, "localauthority.conf.d", tc.want[i].Path)
+			}
+
+			m := privilege.NewWithDirs(t.TempDir(), policyKitDir)
+			got, err := m.SystemAdminIdentities(context.Background())
+			require.NoError(t, err, "SystemAdminIdentities failed but shouldn't have")
+			require.Equal(t, tc.want, got, "SystemAdminIdentities should report all the files setting identities")
+		})
+	}
+}
+
+func TestSimulateAdminIdentities(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		entries           []entry.Entry
+		existingPolkitDir string
+
+		want    []string
+		wantErr bool
+	}{
+		"Return client administrators": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: "bob@example.com,%mygroup@example.com"}},
+			want: []string{"unix-group:mygroup@example.com", "unix-user:bob@example.com"}},
+		"Return system identities without privilege policy": {existingPolkitDir: "existing-previous-local-admins-multi", want: []string{"unix-user:local50admin1", "unix-user:local50admin2"}},
+		"Return system identities when policy doesn't change administrators": {entries: []entry.Entry{{Key: "allow-local-admins", Value: "1"}},
+			existingPolkitDir: "existing-previous-local-admins-multi", want: []string{"unix-user:local50admin1", "unix-user:local50admin2"}},
+		"Configuration written by adsys is ignored":  {existingPolkitDir: "existing-previous-local-admins-with-adsys-file", want: []string{"unix-user:local50admin1", "unix-user:local50admin2"}},
+		"No identities without polkit configuration": {want: nil},
+
+		"Error on invalid allow local admins value": {entries: []entry.Entry{{Key: "allow-local-admins", Value: "notabool"}}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			policyKitDir := filepath.Join(t.TempDir(), "polkit-1")
+			if tc.existingPolkitDir != "" {
+				testutils.Copy(t, filepath.Join("testdata", tc.existingPolkitDir, "polkit-1"), policyKitDir)
+			}
+
+			m := privilege.NewWithDirs(t.TempDir(), policyKitDir)
+			got, err := m.SimulateAdminIdentities(context.Background(), tc.entries, nil)
+			if tc.wantErr {
+				require.Error(t, err, "SimulateAdminIdentities should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "SimulateAdminIdentities failed but shouldn't have")
+			require.Equal(t, tc.want, got, "SimulateAdminIdentities should return the expected identities")
+
+			_, err = os.Stat(filepath.Join(policyKitDir, "localauthority.conf.d", "99-adsys-privilege-enforcement.conf"))
+			if tc.existingPolkitDir != "existing-previous-local-admins-with-adsys-file" {
+				require.ErrorIs(t, err, fs.ErrNotExist, "SimulateAdminIdentities should not write any polkit configuration")
+			}
+		})
+	}
+}
+
+// normalizeExpiryService replaces the temporary directory in the generated expiry service, if any, so that
+// it can be compared to the golden files.
+func normalizeExpiryService(t *testing.T, tempEtc string) {
+	t.Helper()
+
+	p := filepath.Join(tempEtc, "systemd", "system", "adsys-privilege-expiry.service")
+	content, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	require.NoError(t, err, "Teardown: can't read expiry service")
+	content = []byte(strings.ReplaceAll(string(content), tempEtc, "/etc"))
+	require.NoError(t, os.WriteFile(p, content, 0644), "Teardown: can't write expiry service")
+}
+
+// mockNow returns a fixed time from which temporary grants expire.
+func mockNow() time.Time {
+	return time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)
+}
+
+type mockSystemdCaller struct {
+	testutils.MockSystemdCaller
+
+	failing bool
+}
+
+func (s mockSystemdCaller) DaemonReload(_ context.Context) error {
+	if s.failing {
+		return errors.New("failed to reload daemon")
+	}
+	return nil
+}
+
+func (s mockSystemdCaller) DisableUnit(_ context.Context, _ string) error {
+	if s.failing {
+		return errors.New("failed to disable unit")
+	}
+	return nil
+}
+
+func TestSudoAccess(t *testing.T) {
+	t.Parallel()
+
+	adsysSudoers := filepath.Join("sudoers.d", "99-adsys-privilege-enforcement")
+	groupSudoers := filepath.Join("sudoers.d", "99-adsys-privilege-enforcement-group-devs")
+
+	tests := map[string]struct {
+		user      string
+		groups    []string
+		noFiles   bool
+		groupsErr bool
+
+		want    privilege.SudoAccess
+		wantErr bool
+	}{
+		"Directly listed user is granted": {user: "alice", want: privilege.SudoAccess{Matched: true, Granted: true,
+			Path: adsysSudoers, Line: 9, Rule: `"alice" ALL=(ALL:ALL) ALL`, Identity: "alice", PolkitIdentity: "unix-user:alice"}},
+		"User name is matched ignoring case": {user: "Alice", want: privilege.SudoAccess{Matched: true, Granted: true,
+			Path: adsysSudoers, Line: 9, Rule: `"alice" ALL=(ALL:ALL) ALL`, Identity: "alice", PolkitIdentity: "unix-user:alice"}},
+		"Group member is granted": {user: "bob", groups: []string{"domain users@example.com", "admins@example.com"}, want: privilege.SudoAccess{Matched: true, Granted: true,
+			Path: adsysSudoers, Line: 10, Rule: `"%admins@example.com" ALL=(ALL:ALL) ALL`, Identity: "%admins@example.com", PolkitIdentity: "unix-group:admins@example.com"}},
+		"Member of a group with its own file is granted": {user: "dave", groups: []string{"devs"}, want: privilege.SudoAccess{Matched: true, Granted: true,
+			Path: groupSudoers, Line: 5, Rule: `"%devs" ALL=(ALL:ALL) ALL`, Identity: "%devs"}},
+		"Alias member is granted": {user: "carol", want: privilege.SudoAccess{Matched: true, Granted: true,
+			Path: adsysSudoers, Line: 14, Rule: "ADSYS_CLIENT_ADMINS ALL=(root) ALL", Identity: "ADSYS_CLIENT_ADMINS (carol)"}},
+		"Member of an alias group is granted": {user: "frank", groups: []string{"helpdesk"}, want: privilege.SudoAccess{Matched: true, Granted: true,
+			Path: adsysSudoers, Line: 14, Rule: "ADSYS_CLIENT_ADMINS ALL=(root) ALL", Identity: "ADSYS_CLIENT_ADMINS (%helpdesk)"}},
+		"Local administrator is denied": {user: "eve", groups: []string{"sudo"}, want: privilege.SudoAccess{Matched: true,
+			Path: adsysSudoers, Line: 6, Rule: "%sudo ALL=(ALL:ALL) !ALL", Identity: "%sudo"}},
+		"Protected user is granted after the deny rules": {user: "breakglass", groups: []string{"sudo"}, want: privilege.SudoAccess{Matched: true, Granted: true,
+			Path: adsysSudoers, Line: 7, Rule: `"breakglass" ALL=(ALL:ALL) ALL`, Identity: "breakglass", PolkitIdentity: "unix-user:breakglass"}},
+		"Last matching rule wins": {user: "alice", groups: []string{"admin", "devs"}, want: privilege.SudoAccess{Matched: true, Granted: true,
+			Path: groupSudoers, Line: 5, Rule: `"%devs" ALL=(ALL:ALL) ALL`, Identity: "%devs", PolkitIdentity: "unix-user:alice"}},
+		"User without adsys rule is not matched":    {user: "mallory", groups: []string{"users"}},
+		"Rules of local files are not considered":   {user: "eve"},
+		"User is not matched without adsys sudoers": {user: "alice", noFiles: true},
+
+		"Error on groups lookup failure": {user: "alice", groupsErr: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := filepath.Join(t.TempDir(), "etc")
+			if !tc.noFiles {
+				testutils.Copy(t, filepath.Join("testdata", "sudo-access"), dir)
+			}
+
+			m := privilege.NewWithDirs(filepath.Join(dir, "sudoers.d"), filepath.Join(dir, "polkit-1"),
+				privilege.WithUserGroups(func(name string) ([]string, error) {
+					if tc.groupsErr {
+						return nil, errors.New("lookup error")
+					}
+					require.Equal(t, tc.user, name, "Setup: groups should be looked up for the requested user")
+					return tc.groups, nil
+				}))
+			got, err := m.SudoAccess(context.Background(), tc.user)
+			if tc.wantErr {
+				require.Error(t, err, "SudoAccess should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "SudoAccess failed but shouldn't have")
+
+			if tc.want.Path != "" {
+				tc.want.Path = filepath.Join(dir, tc.want.Path)
+			}
+			require.Equal(t, tc.want, got, "SudoAccess should return the expected access")
+		})
+	}
+}
+
+func mockUserLookup(name string) (*user.User, error) {
+	if name != "svcacct" && name != "svc@domain.com" {
+		return nil, user.UnknownUserError(name)
+	}
+	return &user.User{Username: name}, nil
+}
+
+func mockGroupLookup(name string) (*user.Group, error) {
+	if name != "svcgroup" {
+		return nil, user.UnknownGroupError(name)
+	}
+	return &user.Group{Name: name}, nil
+}
+
+// mockVisudoCmd returns a visudo command rejecting the sudoers files containing "syntax error".
+func mockVisudoCmd() []string {
+	return []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockVisudo", "--"}
+}
+
+func TestMockVisudo(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	// Last arg is the sudoers file to validate
+	p := os.Args[len(os.Args)-1]
+	d, err := os.ReadFile(p)
+	require.NoError(t, err, "Setup: Can't read sudoers file to validate")
+
+	if strings.Contains(string(d), "syntax error") {
+		fmt.Fprintf(os.Stderr, "%s:1:1: syntax error\n", p)
+		// nolint:gocritic // we want to exit with an error without running the deferred calls
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,149 @@
+package privilege
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// sudoersAssetsDir is the directory of the SYSVOL assets containing the sudoers snippets.
+	sudoersAssetsDir = "sudoers/"
+	// sudoersSnippetPrefix prefixes the name of the installed sudoers snippets. It is ordered after the main
+	// sudoers file, so that snippets are evaluated last.
+	sudoersSnippetPrefix = adsysBaseConfName + "-snippet-"
+)
+
+// sudoersSnippetNameRe matches the characters replaced in the name of the installed snippets, as sudo ignores
+// the files with a dot in their name in its include directories.
+var sudoersSnippetNameRe = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// AssetsDumper is a function which uncompress policies assets to a directory.
+type AssetsDumper func(ctx context.Context, relSrc, dest string, uid int, gid int) (err error)
+
+// fetchSudoersSnippets returns the content of the sudoers snippets of v, one path relative to the sudoers
+// directory of the SYSVOL per line, mapped by their installed name. Each snippet is validated with visudo.
+// Empty lines and comments are ignored.
+func (m *Manager) fetchSudoersSnippets(ctx context.Context, v string, assetsDumper AssetsDumper) (snippets map[string]string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't fetch sudoers snippets"))
+
+	// paths maps the installed names to the snippet paths, to detect collisions.
+	paths := make(map[string]string)
+	for _, p := range strings.Split(v, "\n") {
+		p = strings.TrimSpace(p)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+		if !filepath.IsLocal(p) {
+			return nil, errors.New(gotext.Get("invalid sudoers snippet path %q: it must be relative to the sudoers directory of the SYSVOL", p))
+		}
+		p = filepath.Clean(p)
+
+		name := sudoersSnippetPrefix + sudoersSnippetNameRe.ReplaceAllString(p, "_")
+		if other, ok := paths[name]; ok && other != p {
+			return nil, errors.New(gotext.Get("sudoers snippets %q and %q would both be installed as %s", other, p, name))
+		}
+		paths[name] = p
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	tmpdir, err := os.MkdirTemp("", "adsys-sudoers-snippets-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpdir)
+	assetsDir := filepath.Join(tmpdir, "sudoers")
+	if err := assetsDumper(ctx, sudoersAssetsDir, assetsDir, -1, -1); err != nil {
+		return nil, err
+	}
+
+	snippets = make(map[string]string)
+	for name, p := range paths {
+		src := filepath.Join(assetsDir, p)
+		content, err := os.ReadFile(src)
+		if err != nil {
+			return nil, errors.New(gotext.Get("can't read sudoers snippet %q: %v", p, err))
+		}
+		if err := m.checkSudoers(ctx, src); err != nil {
+			return nil, errors.New(gotext.Get("invalid sudoers snippet %q: %v", p, err))
+		}
+		snippets[name] = string(content)
+	}
+
+	return snippets, nil
+}
+
+// checkSudoers validates the sudoers file at path with visudo.
+func (m *Manager) checkSudoers(ctx context.Context, path string) error {
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, m.visudoCmd[0], append(m.visudoCmd[1:], path)...)
+	trace.Command(ctx, "privilege", cmd.Args)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, string(out))
+	}
+	return nil
+}
+
+// installSudoersFiles writes the files, mapped by their name, to sudoersDir and removes the previously installed
+// ones starting with prefix which are not part of them anymore.
+func (m *Manager) installSudoersFiles(ctx context.Context, sudoersDir, prefix string, files map[string]string) error {
+	installed, err := installedSudoersFiles(sudoersDir, prefix)
+	if err != nil {
+		return err
+	}
+	for _, p := range installed {
+		if _, ok := files[filepath.Base(p)]; ok {
+			continue
+		}
+		log.Debug(ctx, gotext.Get("Removing sudoers file %s", p))
+		if _, err := tamper.Remove(ctx, "privilege", p); err != nil {
+			return err
+		}
+	}
+
+	if len(files) == 0 {
+		return nil
+	}
+
+	for name, content := range files {
+		if err := m.writeFile(ctx, filepath.Join(sudoersDir, name), content, 0440); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reportSudoersFiles logs, in report-only mode, how the files starting with prefix installed in sudoersDir would
+// be changed to files.
+func reportSudoersFiles(ctx context.Context, sudoersDir, prefix string, files map[string]string) {
+	installed, err := installedSudoersFiles(sudoersDir, prefix)
+	if err != nil {
+		log.Warning(ctx, gotext.Get("Report-only privilege mode: can't list current sudoers files: %v", err))
+	}
+	for _, p := range installed {
+		if _, ok := files[filepath.Base(p)]; !ok {
+			reportPrivilegeFile(ctx, p, nil)
+		}
+	}
+	for name, content := range files {
+		reportPrivilegeFile(ctx, filepath.Join(sudoersDir, name), &content)
+	}
+}
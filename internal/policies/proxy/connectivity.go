@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+)
+
+// connectivityCheckTimeout is the maximum time to reach the test URL through the applied proxy.
+const connectivityCheckTimeout = 10 * time.Second
+
+// newProxiedHTTPClient returns an HTTP client sending all its requests through proxyURL.
+func newProxiedHTTPClient(proxyURL *url.URL) *http.Client {
+	return &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+}
+
+// checkConnectivity requests the test URL through the proxy just applied for its scheme, falling back to
+// the http proxy. A failure is only logged as a warning, so that an administrator can spot a misconfigured
+// proxy, without failing the policy which is already applied.
+func (m *Manager) checkConnectivity(ctx context.Context, settings map[string]string) {
+	if m.connectivityCheckURL == "" {
+		return
+	}
+
+	target, err := url.Parse(m.connectivityCheckURL)
+	if err != nil || target.Host == "" {
+		log.Warning(ctx, gotext.Get("Not checking proxy connectivity: invalid test URL %q", m.connectivityCheckURL))
+		return
+	}
+
+	proxy := settings[target.Scheme]
+	if proxy == "" {
+		proxy = settings["http"]
+	}
+	if proxy == "" {
+		log.Debugf(ctx, "Not checking proxy connectivity: no proxy set for %s", m.connectivityCheckURL)
+		return
+	}
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		log.Warning(ctx, gotext.Get("Not checking proxy connectivity: invalid proxy URL: %v", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, connectivityCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target.String(), nil)
+	if err != nil {
+		log.Warning(ctx, gotext.Get("Not checking proxy connectivity: %v", err))
+		return
+	}
+
+	log.Debugf(ctx, "Checking connectivity to %s through proxy %s", target, proxyURL.Redacted())
+	resp, err := m.newHTTPClient(proxyURL).Do(req)
+	if err != nil {
+		log.Warning(ctx, gotext.Get("Could not reach %s through proxy %s, the proxy settings may be wrong: %v", target, proxyURL.Redacted(), err))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		log.Warning(ctx, gotext.Get("Could not reach %s through proxy %s, the proxy settings may be wrong: %s", target, proxyURL.Redacted(), resp.Status))
+		return
+	}
+	log.Debugf(ctx, "Reached %s through proxy %s", target, proxyURL.Redacted())
+}
@@ -0,0 +1,233 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+// dockerDropInFileName is the name of the drop-in, in the Docker service drop-in directory, setting the proxy
+// environment of the Docker daemon.
+const dockerDropInFileName = "http-proxy.conf"
+
+// systemdCaller is the interface to reload the systemd units once the Docker daemon drop-in changed.
+type systemdCaller interface {
+	DaemonReload(context.Context) error
+}
+
+// applyDockerProxy writes the proxy settings in the Docker client configuration of each opted-in user and in the
+// Docker daemon drop-in, if enabled. Nothing is done if Docker is not installed.
+// Failures for the users are only logged, as for Wine prefixes, while a failure for the daemon is returned.
+func (m *Manager) applyDockerProxy(ctx context.Context, settings map[string]string, noProxy string) error {
+	if len(m.dockerUsers) == 0 && m.dockerDropInDir == "" {
+		return nil
+	}
+	if _, err := m.lookPath("docker"); err != nil {
+		log.Debugf(ctx, "Docker is not installed, not applying proxy settings to Docker: %v", err)
+		return nil
+	}
+
+	proxies := dockerClientProxies(settings, noProxy)
+	for user, home := range m.dockerUsers {
+		log.Debugf(ctx, "Applying proxy settings to Docker configuration of %s in %s", user, home)
+		if err := updateDockerConfig(home, proxies); err != nil {
+			log.Warning(ctx, gotext.Get("Could not apply proxy settings to Docker configuration of %s: %v", user, err))
+		}
+	}
+
+	if m.dockerDropInDir == "" {
+		return nil
+	}
+	return m.applyDockerDaemonProxy(ctx, settings, noProxy)
+}
+
+// dockerClientProxies returns the default proxies of the Docker client configuration, which are set in the
+// containers, for the proxy settings. Docker doesn't support automatic proxy configuration URLs.
+func dockerClientProxies(settings map[string]string, noProxy string) map[string]string {
+	proxies := make(map[string]string)
+	for key, name := range map[string]string{"http": "httpProxy", "https": "httpsProxy", "ftp": "ftpProxy", "socks": "allProxy"} {
+		if v := strings.TrimSpace(settings[key]); v != "" {
+			proxies[name] = v
+		}
+	}
+	if len(proxies) > 0 && noProxy != "" {
+		proxies["noProxy"] = noProxy
+	}
+	return proxies
+}
+
+// updateDockerConfig sets the default proxies of the Docker client configuration of the user with the home directory
+// home, or removes them if there is none. Other settings are left untouched. The configuration directory is created,
+// owned by the owner of the home directory, if needed. As the home directory belongs to the user, neither it, the
+// configuration directory nor the configuration file can be a symlink, and the file is kept owned by the owner of
+// the configuration directory.
+func updateDockerConfig(home string, proxies map[string]string) (err error) {
+	path := filepath.Join(home, ".docker", "config.json")
+	defer decorate.OnError(&err, gotext.Get("can't update Docker configuration %s", path))
+
+	h, err := openUserDir(home)
+	if err != nil {
+		return err
+	}
+	defer h.close()
+
+	cfg := make(map[string]any)
+	var content []byte
+	perm := os.FileMode(0600)
+	d, err := h.openDir(".docker")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if d != nil {
+		defer d.close()
+		content, perm, err = d.readFile("config.json")
+		if errors.Is(err, fs.ErrNotExist) {
+			perm = 0600
+		} else if err != nil {
+			return err
+		} else if err := json.Unmarshal(content, &cfg); err != nil {
+			return err
+		}
+	}
+
+	allProxies, _ := cfg["proxies"].(map[string]any)
+	if len(proxies) == 0 {
+		if _, ok := allProxies["default"]; !ok {
+			return nil
+		}
+		delete(allProxies, "default")
+		if len(allProxies) == 0 {
+			delete(cfg, "proxies")
+		}
+	} else {
+		if allProxies == nil {
+			allProxies = make(map[string]any)
+			cfg["proxies"] = allProxies
+		}
+		allProxies["default"] = proxies
+	}
+
+	newContent, err := json.MarshalIndent(cfg, "", "\t")
+	if err != nil {
+		return err
+	}
+	newContent = append(newContent, '\n')
+	if bytes.Equal(content, newContent) {
+		return nil
+	}
+
+	if d == nil {
+		if d, err = h.mkdir(".docker", 0700); err != nil {
+			return err
+		}
+		defer d.close()
+	}
+	return d.writeFile("config.json", newContent, perm)
+}
+
+// applyDockerDaemonProxy writes the HTTP and HTTPS proxy settings to the Docker daemon drop-in, or removes it if
+// there is none. A drop-in modified locally is handled like the other managed files. When the drop-in changed, the
+// systemd units are reloaded and the Docker daemon restarted, if running.
+func (m *Manager) applyDockerDaemonProxy(ctx context.Context, settings map[string]string, noProxy string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply proxy settings to Docker daemon"))
+
+	p := filepath.Join(m.dockerDropInDir, dockerDropInFileName)
+	content := dockerDropIn(settings, noProxy)
+
+	oldContent, err := os.ReadFile(p)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	exists := err == nil
+
+	switch {
+	case content == "" && !exists:
+		return nil
+	case content == string(oldContent):
+		log.Debugf(ctx, "Docker daemon proxy drop-in %s is up to date", p)
+		return nil
+	case content == "":
+		log.Debugf(ctx, "Removing Docker daemon proxy drop-in %s", p)
+		if removed, err := tamper.Remove(ctx, "proxy", p); err != nil || !removed {
+			return err
+		}
+	default:
+		log.Debugf(ctx, "Writing Docker daemon proxy drop-in %s", p)
+		// nolint:gosec // G301 match systemd permission
+		if err := os.MkdirAll(m.dockerDropInDir, 0755); err != nil {
+			return err
+		}
+		// The proxy URLs can embed credentials.
+		if written, err := tamper.Write(ctx, "proxy", p, []byte(content), 0600); err != nil || !written {
+			return err
+		}
+	}
+
+	if err := m.systemdCaller.DaemonReload(ctx); err != nil {
+		return err
+	}
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, m.dockerRestartCmd[0], m.dockerRestartCmd[1:]...)
+	trace.Command(ctx, "proxy", cmd.Args)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return errors.New(gotext.Get("failed to restart Docker daemon: %v\n%s", err, string(out)))
+	}
+	return nil
+}
+
+// dockerDropIn returns the content of the Docker daemon drop-in for the proxy settings. It is empty if neither the
+// HTTP nor the HTTPS proxy is set, as they are the only ones used by the daemon.
+func dockerDropIn(settings map[string]string, noProxy string) string {
+	var env []string
+	for key, name := range map[string]string{"http": "HTTP_PROXY", "https": "HTTPS_PROXY"} {
+		if v := strings.TrimSpace(settings[key]); v != "" {
+			env = append(env, fmt.Sprintf("%s=%s", name, v))
+		}
+	}
+	if len(env) == 0 {
+		return ""
+	}
+	// Map iteration is random, keep HTTP_PROXY first.
+	if len(env) == 2 && strings.HasPrefix(env[0], "HTTPS_PROXY=") {
+		env[0], env[1] = env[1], env[0]
+	}
+	if noProxy != "" {
+		env = append(env, fmt.Sprintf("NO_PROXY=%s", noProxy))
+	}
+
+	var out strings.Builder
+	out.WriteString(`# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+
+[Service]
+`)
+	for _, e := range env {
+		out.WriteString(fmt.Sprintf("Environment=\"%s\"\n", escapeSystemdValue(e)))
+	}
+	return out.String()
+}
+
+// escapeSystemdValue escapes a value to be used in a double-quoted systemd unit setting.
+func escapeSystemdValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return strings.ReplaceAll(s, "%", "%%")
+}
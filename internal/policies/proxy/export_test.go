@@ -1,3 +1,22 @@
 package proxy
 
+import (
+	"net/http"
+	"net/url"
+)
+
 const ErrDBusServiceUnknownName = errDBusServiceUnknownName
+
+// WithHTTPClient overrides the function returning the HTTP client used to check the connectivity through the proxy.
+func WithHTTPClient(f func(proxyURL *url.URL) *http.Client) Option {
+	return func(o *options) {
+		o.newHTTPClient = f
+	}
+}
+
+// WithLookPath overrides the function looking for the Docker executable, to detect whether Docker is installed.
+func WithLookPath(f func(file string) (string, error)) Option {
+	return func(o *options) {
+		o.lookPath = f
+	}
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	nmDbusRegisteredName = "org.freedesktop.NetworkManager"
+	nmDbusObjectPath     = "/org/freedesktop/NetworkManager"
+	nmDbusInterface      = "org.freedesktop.NetworkManager"
+)
+
+// NetworkState is the interface to get the networks the machine is currently connected to.
+type NetworkState interface {
+	// ActiveConnections returns the names of the active connections and the SSIDs of their Wi-Fi networks.
+	ActiveConnections(ctx context.Context) ([]string, error)
+}
+
+// networkManager gets the network state from NetworkManager via D-Bus.
+type networkManager struct {
+	bus *dbus.Conn
+}
+
+// ActiveConnections returns the names of the NetworkManager active connections and the SSIDs of the
+// access points of their Wi-Fi devices.
+func (n networkManager) ActiveConnections(_ context.Context) (names []string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't get active connections from NetworkManager"))
+
+	v, err := n.bus.Object(nmDbusRegisteredName, nmDbusObjectPath).GetProperty(nmDbusInterface + ".ActiveConnections")
+	if err != nil {
+		return nil, err
+	}
+	connections, ok := v.Value().([]dbus.ObjectPath)
+	if !ok {
+		return nil, errors.New(gotext.Get("unexpected type %T for active connections", v.Value()))
+	}
+
+	for _, c := range connections {
+		conn := n.bus.Object(nmDbusRegisteredName, c)
+		v, err := conn.GetProperty(nmDbusInterface + ".Connection.Active.Id")
+		if err != nil {
+			return nil, err
+		}
+		if id, ok := v.Value().(string); ok && id != "" {
+			names = append(names, id)
+		}
+
+		v, err = conn.GetProperty(nmDbusInterface + ".Connection.Active.Devices")
+		if err != nil {
+			return nil, err
+		}
+		devices, _ := v.Value().([]dbus.ObjectPath)
+		for _, d := range devices {
+			v, err := n.bus.Object(nmDbusRegisteredName, d).GetProperty(nmDbusInterface + ".Device.Wireless.ActiveAccessPoint")
+			if err != nil {
+				// Not a Wi-Fi device
+				continue
+			}
+			ap, ok := v.Value().(dbus.ObjectPath)
+			if !ok || ap == "/" {
+				continue
+			}
+			v, err = n.bus.Object(nmDbusRegisteredName, ap).GetProperty(nmDbusInterface + ".AccessPoint.Ssid")
+			if err != nil {
+				return nil, err
+			}
+			if ssid, ok := v.Value().([]byte); ok && len(ssid) > 0 {
+				names = append(names, string(ssid))
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// parseNetworks returns the networks, one per line, of the entry value. Empty lines and comments are ignored.
+func parseNetworks(value string) (networks []string) {
+	for _, l := range strings.Split(value, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		networks = append(networks, l)
+	}
+	return networks
+}
+
+// isOnNetworks returns true if one of the active connections or Wi-Fi networks of the machine is in networks.
+// If NetworkManager is not running, the machine is considered as not connected to any of them.
+func (m *Manager) isOnNetworks(ctx context.Context, networks []string) (bool, error) {
+	active, err := m.networkState.ActiveConnections(ctx)
+	if err != nil {
+		var dbusErr dbus.Error
+		if errors.As(err, &dbusErr) && dbusErr.Name == errDBusServiceUnknownName {
+			log.Warning(ctx, gotext.Get("Can't check the networks of the proxy policy as NetworkManager is not running: %s", dbusErr.Error()))
+			return false, nil
+		}
+		return false, err
+	}
+
+	for _, name := range active {
+		if slices.Contains(networks, name) {
+			log.Debugf(ctx, "Connected to network %q of the proxy policy", name)
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	"golang.org/x/sys/unix"
+)
+
+// userDir is a directory owned by a user, like a home directory or a Wine prefix, in which root writes files.
+// The directory is opened without following any symlink and its files are only accessed relatively to it, without
+// following symlinks either: the user can't redirect the writes of root to a file of their choice.
+type userDir struct {
+	fd   int
+	path string
+	uid  int
+	gid  int
+}
+
+// openUserDir opens the directory at the absolute path p. It fails if any component of p is a symlink.
+func openUserDir(p string) (d *userDir, err error) {
+	if !filepath.IsAbs(p) {
+		return nil, errors.New(gotext.Get("%s is not an absolute path", p))
+	}
+
+	fd, err := unix.Open("/", unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	d = &userDir{fd: fd, path: "/"}
+	for _, c := range strings.Split(filepath.Clean(p), "/") {
+		if c == "" {
+			continue
+		}
+		next, err := d.openDir(c)
+		d.close()
+		if err != nil {
+			return nil, err
+		}
+		d = next
+	}
+	return d, nil
+}
+
+// openDir opens the subdirectory name of the directory. It fails if name is a symlink.
+func (d *userDir) openDir(name string) (sub *userDir, err error) {
+	p := filepath.Join(d.path, name)
+	fd, err := unix.Openat(d.fd, name, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if errors.Is(err, unix.ELOOP) || errors.Is(err, unix.ENOTDIR) {
+		return nil, errors.New(gotext.Get("%s is a symbolic link or not a directory", p))
+	} else if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: p, Err: err}
+	}
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		unix.Close(fd)
+		return nil, &fs.PathError{Op: "stat", Path: p, Err: err}
+	}
+	return &userDir{fd: fd, path: p, uid: int(st.Uid), gid: int(st.Gid)}, nil
+}
+
+// mkdir opens the subdirectory name of the directory, creating it with perm, owned by the owner of the directory,
+// if needed. It fails if name is a symlink.
+func (d *userDir) mkdir(name string, perm fs.FileMode) (sub *userDir, err error) {
+	err = unix.Mkdirat(d.fd, name, uint32(perm))
+	if err != nil && !errors.Is(err, unix.EEXIST) {
+		return nil, &fs.PathError{Op: "mkdir", Path: filepath.Join(d.path, name), Err: err}
+	}
+	created := err == nil
+
+	sub, err = d.openDir(name)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return sub, nil
+	}
+	if err := unix.Fchown(sub.fd, d.uid, d.gid); err != nil {
+		sub.close()
+		return nil, &fs.PathError{Op: "chown", Path: sub.path, Err: err}
+	}
+	sub.uid, sub.gid = d.uid, d.gid
+	return sub, nil
+}
+
+// close releases the directory.
+func (d *userDir) close() {
+	unix.Close(d.fd)
+}
+
+// readFile returns the content and the permissions of the regular file name of the directory. It fails with
+// fs.ErrNotExist if the file doesn't exist, and if it is a symlink, not a regular file or not owned by the owner
+// of the directory.
+func (d *userDir) readFile(name string) (content []byte, perm fs.FileMode, err error) {
+	p := filepath.Join(d.path, name)
+	fd, err := unix.Openat(d.fd, name, unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if errors.Is(err, unix.ENOENT) {
+		return nil, 0, &fs.PathError{Op: "open", Path: p, Err: fs.ErrNotExist}
+	} else if errors.Is(err, unix.ELOOP) {
+		return nil, 0, errors.New(gotext.Get("%s is a symbolic link", p))
+	} else if err != nil {
+		return nil, 0, &fs.PathError{Op: "open", Path: p, Err: err}
+	}
+	f := os.NewFile(uintptr(fd), p)
+	defer f.Close()
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return nil, 0, &fs.PathError{Op: "stat", Path: p, Err: err}
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFREG {
+		return nil, 0, errors.New(gotext.Get("%s is not a regular file", p))
+	}
+	// A file of another user, like a hard link to a system file, would be replaced by a copy owned by the user.
+	if int(st.Uid) != d.uid {
+		return nil, 0, errors.New(gotext.Get("%s is not owned by the owner of %s", p, d.path))
+	}
+
+	content, err = io.ReadAll(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	return content, fs.FileMode(st.Mode).Perm(), nil
+}
+
+// writeFile atomically replaces the file name of the directory with content, owned by the owner of the directory
+// with perm. The temporary file is created exclusively, without following symlinks, and the existing name is
+// replaced by the rename, even if it is a symlink.
+func (d *userDir) writeFile(name string, content []byte, perm fs.FileMode) (err error) {
+	p := filepath.Join(d.path, name)
+	tmp := name + ".adsys.new"
+
+	// Remove a leftover temporary file, or anything the user put in its place. A symlink is removed, not its target.
+	if err := unix.Unlinkat(d.fd, tmp, 0); err != nil && !errors.Is(err, unix.ENOENT) {
+		return &fs.PathError{Op: "remove", Path: filepath.Join(d.path, tmp), Err: err}
+	}
+	fd, err := unix.Openat(d.fd, tmp, unix.O_WRONLY|unix.O_CREAT|unix.O_EXCL|unix.O_NOFOLLOW|unix.O_CLOEXEC, uint32(perm))
+	if err != nil {
+		return &fs.PathError{Op: "create", Path: filepath.Join(d.path, tmp), Err: err}
+	}
+	f := os.NewFile(uintptr(fd), filepath.Join(d.path, tmp))
+	defer func() {
+		if err != nil {
+			_ = unix.Unlinkat(d.fd, tmp, 0)
+		}
+	}()
+
+	if err := unix.Fchown(fd, d.uid, d.gid); err != nil {
+		f.Close()
+		return &fs.PathError{Op: "chown", Path: f.Name(), Err: err}
+	}
+	// The umask was applied at creation.
+	if err := unix.Fchmod(fd, uint32(perm)); err != nil {
+		f.Close()
+		return &fs.PathError{Op: "chmod", Path: f.Name(), Err: err}
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := unix.Renameat(d.fd, tmp, d.fd, name); err != nil {
+		return &os.LinkError{Op: "rename", Old: f.Name(), New: p, Err: err}
+	}
+	return nil
+}
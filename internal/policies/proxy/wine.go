@@ -0,0 +1,180 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// wineInternetSettingsKey is the registry section holding the proxy settings, as written in a Wine user.reg file.
+const wineInternetSettingsKey = `[Software\\Microsoft\\Windows\\CurrentVersion\\Internet Settings]`
+
+// wineManagedValues are the registry values of the Internet Settings key handled by the manager.
+var wineManagedValues = []string{"ProxyEnable", "ProxyServer", "ProxyOverride", "AutoConfigURL"}
+
+// applyWineProxy writes the proxy settings in the user.reg registry file of each opted-in Wine prefix.
+// Any failure, like a prefix which is not initialized, is only logged as the settings are a convenience
+// for Windows-only tooling.
+func (m *Manager) applyWineProxy(ctx context.Context, settings map[string]string) {
+	if len(m.winePrefixes) == 0 {
+		return
+	}
+
+	values := wineProxyValues(settings)
+	for user, prefix := range m.winePrefixes {
+		log.Debugf(ctx, "Applying proxy settings to Wine prefix %s of %s", prefix, user)
+		if err := updateWineUserReg(prefix, values); err != nil {
+			log.Warning(ctx, gotext.Get("Could not apply proxy settings to Wine prefix %s of %s: %v", prefix, user, err))
+		}
+	}
+}
+
+// wineProxyValues converts the proxy settings to the Windows Internet Settings registry values, already
+// formatted for a Wine registry file.
+func wineProxyValues(settings map[string]string) map[string]string {
+	var servers []string
+	for _, scheme := range []string{"http", "https", "ftp", "socks"} {
+		if s := wineProxyServer(settings[scheme]); s != "" {
+			servers = append(servers, fmt.Sprintf("%s=%s", scheme, s))
+		}
+	}
+
+	var overrides []string
+	for _, o := range strings.Split(settings["no-proxy"], ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			overrides = append(overrides, o)
+		}
+	}
+
+	values := map[string]string{"ProxyEnable": "dword:00000000"}
+	if len(servers) > 0 {
+		values["ProxyEnable"] = "dword:00000001"
+		values["ProxyServer"] = quoteWineString(strings.Join(servers, ";"))
+	}
+	if len(overrides) > 0 {
+		values["ProxyOverride"] = quoteWineString(strings.Join(overrides, ";"))
+	}
+	if settings["auto"] != "" {
+		values["AutoConfigURL"] = quoteWineString(settings["auto"])
+	}
+
+	return values
+}
+
+// wineProxyServer returns the host:port part of a proxy URL, as expected by Windows.
+func wineProxyServer(proxyURL string) string {
+	proxyURL = strings.TrimSpace(proxyURL)
+	if proxyURL == "" {
+		return ""
+	}
+	if u, err := url.Parse(proxyURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return proxyURL
+}
+
+// quoteWineString returns s as a Wine registry string value.
+func quoteWineString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return fmt.Sprintf(`"%s"`, s)
+}
+
+// updateWineUserReg replaces the managed values of the Internet Settings key in the user.reg registry file of the
+// given Wine prefix. Other keys and values are left untouched. As the prefix belongs to the user, neither it nor
+// user.reg can be a symlink, and the file is kept owned by the prefix owner.
+func updateWineUserReg(prefix string, values map[string]string) (err error) {
+	path := filepath.Join(prefix, "user.reg")
+	defer decorate.OnError(&err, gotext.Get("can't update Wine registry %s", path))
+
+	d, err := openUserDir(prefix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return errors.New(gotext.Get("Wine prefix is not initialized"))
+	} else if err != nil {
+		return err
+	}
+	defer d.close()
+
+	content, perm, err := d.readFile("user.reg")
+	if errors.Is(err, fs.ErrNotExist) {
+		return errors.New(gotext.Get("Wine prefix is not initialized"))
+	} else if err != nil {
+		return err
+	}
+	newContent := setWineInternetSettings(string(content), values)
+	if newContent == string(content) {
+		return nil
+	}
+
+	return d.writeFile("user.reg", []byte(newContent), perm)
+}
+
+// setWineInternetSettings returns the Wine registry content with the managed values of the Internet Settings
+// key set to values, creating the key if needed.
+func setWineInternetSettings(content string, values map[string]string) string {
+	var managed []string
+	for _, name := range wineManagedValues {
+		if v, ok := values[name]; ok {
+			managed = append(managed, fmt.Sprintf(`"%s"=%s`, name, v))
+		}
+	}
+
+	var lines, out []string
+	if c := strings.TrimRight(content, "\n"); c != "" {
+		lines = strings.Split(c, "\n")
+	}
+	inSection, found := false, false
+	// appendManaged inserts the managed values at the end of the current section, before any separating blank line.
+	appendManaged := func() {
+		i := len(out)
+		for i > 0 && out[i-1] == "" {
+			i--
+		}
+		tail := slices.Clone(out[i:])
+		out = append(append(out[:i], managed...), tail...)
+	}
+	for _, l := range lines {
+		if strings.HasPrefix(l, "[") {
+			if inSection {
+				appendManaged()
+			}
+			inSection = strings.HasPrefix(l, wineInternetSettingsKey)
+			found = found || inSection
+		} else if inSection && isWineManagedValue(l) {
+			continue
+		}
+		out = append(out, l)
+	}
+	if inSection {
+		appendManaged()
+	}
+
+	if !found {
+		if len(out) > 0 && out[len(out)-1] != "" {
+			out = append(out, "")
+		}
+		out = append(out, wineInternetSettingsKey)
+		out = append(out, managed...)
+	}
+
+	return strings.Join(out, "\n") + "\n"
+}
+
+// isWineManagedValue returns true if the registry line sets one of the managed values.
+func isWineManagedValue(line string) bool {
+	for _, name := range wineManagedValues {
+		if strings.HasPrefix(line, fmt.Sprintf(`"%s"=`, name)) {
+			return true
+		}
+	}
+	return false
+}
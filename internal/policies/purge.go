@@ -0,0 +1,174 @@
+package policies
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/consts"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// StaleFile is a file managed by a policy type which none of the policies applied on the machine uses anymore.
+type StaleFile struct {
+	Rule string
+	Path string
+	// Removed is true if the file was purged.
+	Removed bool
+}
+
+// PurgeStaleFiles lists the files managed by the policy types absent from the policies applied to the machine and
+// to all its users, restricted to types if set, and removes them if apply is true.
+// Only the policy types whose files are all owned by adsys can be purged: the systemd units are removed without
+// being stopped, and systemd is reloaded if any was.
+func (m *Manager) PurgeStaleFiles(ctx context.Context, types []string, apply bool) (files []StaleFile, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to purge stale managed files"))
+
+	if apply && m.IsReplaying() {
+		return nil, errors.New(gotext.Get("the daemon is in replay mode"))
+	}
+
+	for _, t := range types {
+		if _, ok := m.purgeablePaths[t]; !ok {
+			return nil, errors.New(gotext.Get("files of %s policy can't be purged: must be one of %s", t, strings.Join(purgeableRules(m.purgeablePaths), ", ")))
+		}
+	}
+
+	// Prevent a machine refresh from writing the files we are purging.
+	m.muMu.Lock()
+	if _, ok := m.objectMu[m.hostname]; !ok {
+		m.objectMu[m.hostname] = &sync.Mutex{}
+	}
+	m.objectMu[m.hostname].Lock()
+	defer m.objectMu[m.hostname].Unlock()
+	m.muMu.Unlock()
+
+	used, err := m.usedRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reload bool
+	for _, rule := range purgeableRules(m.purgeablePaths) {
+		if len(types) > 0 && !slices.Contains(types, rule) {
+			continue
+		}
+		if used[rule] {
+			if len(types) > 0 {
+				return nil, errors.New(gotext.Get("%s policy is still used by the applied policies", rule))
+			}
+			continue
+		}
+
+		for _, pattern := range m.purgeablePaths[rule] {
+			paths, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range paths {
+				f := StaleFile{Rule: rule, Path: p}
+				if apply {
+					log.Infof(ctx, "Purging %s, managed by %s policy", p, rule)
+					if err := os.RemoveAll(p); err != nil {
+						return nil, err
+					}
+					f.Removed = true
+					if rule == "units" {
+						// Only remove the drop-in directory if there are no other drop-ins.
+						_ = os.Remove(filepath.Dir(p))
+					}
+					reload = reload || strings.HasPrefix(p, m.systemUnitDir+string(os.PathSeparator))
+				}
+				files = append(files, f)
+			}
+		}
+	}
+
+	if reload {
+		if err := m.systemdCaller.DaemonReload(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// usedRules returns the policy types with entries in the policies applied to the machine or to any of its users.
+// The entries conditioned on the machine facts are counted even if they don't apply, as they can later on.
+func (m *Manager) usedRules(ctx context.Context) (used map[string]bool, err error) {
+	// Without the machine policies, every policy type would be considered as unused.
+	if _, err := os.Stat(filepath.Join(m.policiesCacheDir, m.hostname)); err != nil {
+		return nil, errors.New(gotext.Get("no policy applied for %q: %v", m.hostname, err))
+	}
+
+	objects, err := os.ReadDir(m.policiesCacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	used = make(map[string]bool)
+	for _, o := range objects {
+		if !o.IsDir() {
+			continue
+		}
+		pols, err := NewFromCache(ctx, filepath.Join(m.policiesCacheDir, o.Name()))
+		if err != nil {
+			return nil, errors.New(gotext.Get("can't read the policies applied to %q: %v", o.Name(), err))
+		}
+		for rule, entries := range pols.GetUniqueRules() {
+			if len(entries) > 0 {
+				used[rule] = true
+			}
+		}
+		pols.Close()
+	}
+	return used, nil
+}
+
+// purgeableRules returns the sorted policy types of paths.
+func purgeableRules(paths map[string][]string) []string {
+	rules := make([]string, 0, len(paths))
+	for rule := range paths {
+		rules = append(rules, rule)
+	}
+	slices.Sort(rules)
+	return rules
+}
+
+// purgeablePaths returns the glob patterns of the files and directories only owned by each policy manager, which
+// can be removed as is once the policy is not used anymore.
+// The policy types editing shared files, loading their configuration elsewhere, like apparmor or catrust, or
+// tracking their state in the user sessions are not listed.
+func purgeablePaths(args options) map[string][]string {
+	sudoersDir := cmp.Or(args.sudoersDir, consts.DefaultSudoersDir)
+	policyKitDir := cmp.Or(args.policyKitDir, consts.DefaultPolicyKitDir)
+
+	return map[string][]string{
+		"privilege": {
+			filepath.Join(sudoersDir, "99-adsys-privilege-enforcement*"),
+			filepath.Join(policyKitDir, "localauthority.conf.d", "99-adsys-privilege-enforcement.conf"),
+			filepath.Join(policyKitDir, "rules.d", "*-adsys-privilege-*.rules"),
+			filepath.Join(args.systemUnitDir, "adsys-privilege-expiry.*"),
+		},
+		"mount":       {filepath.Join(args.systemUnitDir, "adsys-*.mount")},
+		"jobs":        {filepath.Join(args.systemUnitDir, "adsys-job-*"), filepath.Join(args.stateDir, "jobs")},
+		"sysctl":      {filepath.Join(args.sysctlDir, "99-adsys.conf")},
+		"network":     {filepath.Join(args.networkConnsDir, "adsys-*.nmconnection")},
+		"environment": {filepath.Join(args.environmentDir, "90adsys-env.conf")},
+		"limits":      {filepath.Join(args.limitsDir, "99-adsys.conf")},
+		"timedate": {
+			filepath.Join(args.timeDateDir, "systemd", "timesyncd.conf.d", "adsys.conf"),
+			filepath.Join(args.timeDateDir, "chrony", "sources.d", "adsys.sources"),
+		},
+		"audit": {filepath.Join(args.auditRulesDir, "99-adsys.rules")},
+		"grub":  {filepath.Join(args.grubDefaultsDir, "99-adsys.cfg")},
+		"units": {filepath.Join(args.systemUnitDir, "*.d", "99-adsys.conf")},
+	}
+}
@@ -0,0 +1,125 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// replayState is the imported cache a manager in replay mode reads policies from.
+type replayState struct {
+	cacheDir string
+	hostname string
+}
+
+// StartReplay switches the manager to replay mode: policies are only read from the cache imported in cacheDir,
+// where hostname is the machine the cache was exported from, and are never applied to the system.
+func (m *Manager) StartReplay(cacheDir, hostname string) {
+	m.replay.Store(&replayState{cacheDir: cacheDir, hostname: hostname})
+}
+
+// StopReplay switches the manager back to applying policies from the daemon cache.
+func (m *Manager) StopReplay() {
+	m.replay.Store(nil)
+}
+
+// IsReplaying returns true if the manager is in replay mode.
+func (m *Manager) IsReplaying() bool {
+	return m.replay.Load() != nil
+}
+
+// cachedPoliciesDir returns the policies cache directory in use and the name of the machine it belongs to.
+func (m *Manager) cachedPoliciesDir() (dir, hostname string) {
+	if r := m.replay.Load(); r != nil {
+		return filepath.Join(r.cacheDir, PoliciesCacheBaseName), r.hostname
+	}
+	return m.policiesCacheDir, m.hostname
+}
+
+// cachedObjectName returns the name of objectName in the policies cache in use, which differs for the
+// machine in replay mode.
+func (m *Manager) cachedObjectName(objectName string) string {
+	if _, hostname := m.cachedPoliciesDir(); objectName == m.hostname {
+		return hostname
+	}
+	return objectName
+}
+
+// ReplayPolicies computes the rules of the imported cached policies for objectName, or for the machine the cache
+// was exported from if isComputer is true, and logs them. Nothing is written to the system.
+func (m *Manager) ReplayPolicies(ctx context.Context, objectName string, isComputer bool) (err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to replay policy for %q", objectName))
+
+	r := m.replay.Load()
+	if r == nil {
+		return errors.New(gotext.Get("the daemon is not in replay mode"))
+	}
+	if isComputer {
+		objectName = r.hostname
+	}
+	ctx = log.WithObjectName(ctx, objectName)
+
+	pols, err := NewFromCache(ctx, filepath.Join(r.cacheDir, PoliciesCacheBaseName, objectName))
+	if err != nil {
+		return err
+	}
+	defer pols.Close()
+
+	applicable, err := pols.applicableTo(ctx, m.facts.Collect(ctx))
+	if err != nil {
+		return err
+	}
+	rules := applicable.GetUniqueRules()
+	if !m.GetSubscriptionState(ctx) {
+		if filteredRules := filterRules(ctx, rules); len(filteredRules) > 0 {
+			log.Warning(ctx, gotext.Get("Rules from the following policy types would be filtered out as the machine is not enrolled to Ubuntu Pro: %s", strings.Join(filteredRules, ", ")))
+		}
+	}
+
+	log.Info(ctx, gotext.Get("Replaying policies for %s (machine: %v)", objectName, isComputer))
+	var types []string
+	for t := range rules {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		for _, e := range rules[t] {
+			if e.Disabled {
+				log.Infof(ctx, "%s: %s (disabled)", t, e.Key)
+				continue
+			}
+			log.Infof(ctx, "%s: %s=%s", t, e.Key, strings.ReplaceAll(strings.TrimSpace(e.Value), "\n", `\n`))
+		}
+	}
+
+	return nil
+}
+
+// ReplayedUsers returns the users with policies in the imported cache.
+func (m *Manager) ReplayedUsers() (users []string, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to list users with imported policies"))
+
+	r := m.replay.Load()
+	if r == nil {
+		return nil, errors.New(gotext.Get("the daemon is not in replay mode"))
+	}
+
+	entries, err := os.ReadDir(filepath.Join(r.cacheDir, PoliciesCacheBaseName))
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() || e.Name() == r.hostname {
+			continue
+		}
+		users = append(users, e.Name())
+	}
+	return users, nil
+}
@@ -0,0 +1,67 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/restart"
+	"github.com/ubuntu/decorate"
+	"gopkg.in/yaml.v3"
+)
+
+// RestartHintsFileName is the file, in the policies cache of an object, listing the policies whose changes during
+// its last policy refresh need a relogin or reboot to take full effect.
+const RestartHintsFileName = "restart"
+
+// saveRestartHints writes the restart hints in the policies cache directory p. The file is removed if there is none.
+func saveRestartHints(p string, hints []restart.Hint) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't save restart hints to %s", p))
+
+	path := filepath.Join(p, RestartHintsFileName)
+	if len(hints) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	d, err := yaml.Marshal(hints)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, d, 0600)
+}
+
+// RestartHints returns the policies whose changes during the last policy refresh of objectName need a relogin or
+// reboot to take full effect.
+func (m *Manager) RestartHints(ctx context.Context, objectName string, isMachine bool) (hints []restart.Hint, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to get restart hints for %q (machine: %v)", objectName, isMachine))
+
+	log.Infof(ctx, "Get restart hints for %q (machine: %t)", objectName, isMachine)
+
+	cacheDir, hostname := m.cachedPoliciesDir()
+	if isMachine {
+		objectName = hostname
+	}
+	p := filepath.Join(cacheDir, m.cachedObjectName(objectName))
+
+	if _, err := os.Stat(p); err != nil {
+		return nil, errors.New(gotext.Get("policies were not applied for %q: %v", objectName, err))
+	}
+
+	d, err := os.ReadFile(filepath.Join(p, RestartHintsFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(d, &hints); err != nil {
+		return nil, err
+	}
+	return hints, nil
+}
@@ -0,0 +1,71 @@
+// Package restart collects the hints, given by the policy managers, that the changes they applied only take full
+// effect once the users log in again or the machine is rebooted, so that they can be reported to administrators.
+//
+// The collector is carried by the context given to the policy managers. Requiring a restart on a context without
+// collector is a no-op.
+package restart
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+)
+
+// Kind is what needs to be restarted for the changes of a policy to take full effect.
+type Kind string
+
+const (
+	// Relogin is a change only read when the user sessions start.
+	Relogin Kind = "relogin"
+	// Reboot is a change only read when the machine starts.
+	Reboot Kind = "reboot"
+)
+
+// Hint is a policy whose applied changes need a restart to take full effect.
+type Hint struct {
+	// Rule is the policy type which changed, like "proxy".
+	Rule string
+	// Kind is what needs to be restarted.
+	Kind Kind
+	// Details is a human readable explanation of the change.
+	Details string `yaml:",omitempty"`
+}
+
+// Collector gathers the hints given during a policy refresh. It is safe for concurrent use.
+type Collector struct {
+	mu    sync.Mutex
+	hints []Hint
+}
+
+// collectorKey is the context key of the collector.
+type collectorKey struct{}
+
+// WithCollector returns a copy of ctx recording any hint in c.
+func WithCollector(ctx context.Context, c *Collector) context.Context {
+	return context.WithValue(ctx, collectorKey{}, c)
+}
+
+// Require adds the hints to the collector of ctx, if any.
+func Require(ctx context.Context, hints ...Hint) {
+	c, ok := ctx.Value(collectorKey{}).(*Collector)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hints = append(c.hints, hints...)
+}
+
+// Hints returns the given hints, sorted by rule and kind, without duplicates.
+func (c *Collector) Hints() []Hint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hints := slices.Clone(c.hints)
+	slices.SortFunc(hints, func(a, b Hint) int {
+		return cmp.Or(cmp.Compare(a.Rule, b.Rule), cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Details, b.Details))
+	})
+	return slices.Compact(hints)
+}
@@ -0,0 +1,63 @@
+package restart_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/restart"
+)
+
+func TestCollector(t *testing.T) {
+	t.Parallel()
+
+	proxy := restart.Hint{Rule: "proxy", Kind: restart.Relogin, Details: "proxy settings changed"}
+	environment := restart.Hint{Rule: "environment", Kind: restart.Relogin}
+	limits := restart.Hint{Rule: "limits", Kind: restart.Relogin}
+	proxyReboot := restart.Hint{Rule: "proxy", Kind: restart.Reboot}
+
+	tests := map[string]struct {
+		required [][]restart.Hint
+
+		want []restart.Hint
+	}{
+		"No hint given":                        {},
+		"Hints are sorted by rule and kind":    {required: [][]restart.Hint{{proxyReboot, proxy}, {limits, environment}}, want: []restart.Hint{environment, limits, proxyReboot, proxy}},
+		"Duplicated hints are only given once": {required: [][]restart.Hint{{proxy, limits}, {proxy}, {limits}}, want: []restart.Hint{limits, proxy}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &restart.Collector{}
+			ctx := restart.WithCollector(context.Background(), c)
+
+			// Managers give hints concurrently
+			var wg sync.WaitGroup
+			for _, hints := range tc.required {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					restart.Require(ctx, hints...)
+				}()
+			}
+			wg.Wait()
+
+			got := c.Hints()
+			if tc.want == nil {
+				require.Empty(t, got, "Hints should return no hint")
+				return
+			}
+			require.Equal(t, tc.want, got, "Hints should return the given hints")
+		})
+	}
+}
+
+func TestRequireWithoutCollector(t *testing.T) {
+	t.Parallel()
+
+	require.NotPanics(t, func() {
+		restart.Require(context.Background(), restart.Hint{Rule: "proxy", Kind: restart.Relogin})
+	}, "Require should be a no-op without collector")
+}
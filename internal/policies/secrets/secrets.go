@@ -0,0 +1,51 @@
+// Package secrets resolves, at apply time, the references to secrets set in the policies, so that credentials don't
+// have to be stored in the GPOs.
+//
+// A reference is of the form <backend>://<path>#<field>, like vault://secret/proxy#password, where the backend
+// is the secret store, like HashiCorp Vault or a keyring, holding the secret.
+//
+// The resolver is pluggable. The default one, Inline, doesn't resolve any reference: the values of the policies are
+// used as is, including the credentials embedded in them.
+package secrets
+
+import (
+	"context"
+	"regexp"
+)
+
+// Resolver resolves the references to secrets set in the policies.
+type Resolver interface {
+	// Resolve returns the secret referenced by value. A value which is not a reference handled by the resolver is
+	// returned as is.
+	Resolve(ctx context.Context, value string) (string, error)
+}
+
+// Inline is the default resolver. It returns the values as is, without resolving any reference.
+type Inline struct{}
+
+// Resolve returns value as is.
+func (Inline) Resolve(_ context.Context, value string) (string, error) {
+	return value, nil
+}
+
+// Reference is a parsed reference to a secret.
+type Reference struct {
+	// Backend is the secret store holding the secret, like vault.
+	Backend string
+	// Path is the path of the secret in the backend.
+	Path string
+	// Field is the field of the secret to use, if any.
+	Field string
+}
+
+// referenceRe matches the references to secrets: <backend>://<path>#<field>.
+var referenceRe = regexp.MustCompile(`^([a-z][a-z0-9+.-]*)://([^#]+)(?:#(.+))?$`)
+
+// ParseReference parses value as a reference to a secret. ok is false if value is not a reference.
+func ParseReference(value string) (ref Reference, ok bool) {
+	m := referenceRe.FindStringSubmatch(value)
+	if m == nil {
+		return Reference{}, false
+	}
+	return Reference{Backend: m[1], Path: m[2], Field: m[3]}, true
+}
@@ -0,0 +1,50 @@
+package secrets_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/secrets"
+)
+
+func TestInline(t *testing.T) {
+	t.Parallel()
+
+	for _, value := range []string{"", "password", "vault://secret/proxy#password"} {
+		got, err := secrets.Inline{}.Resolve(context.Background(), value)
+		require.NoError(t, err, "Resolve should not have returned an error but did")
+		require.Equal(t, value, got, "Inline resolver should return the value as is")
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		value string
+
+		want   secrets.Reference
+		wantOk bool
+	}{
+		"Reference with field":              {value: "vault://secret/proxy#password", want: secrets.Reference{Backend: "vault", Path: "secret/proxy", Field: "password"}, wantOk: true},
+		"Reference without field":           {value: "keyring://adsys/proxy", want: secrets.Reference{Backend: "keyring", Path: "adsys/proxy"}, wantOk: true},
+		"Field can contain a #":             {value: "vault://secret/proxy#pass#word", want: secrets.Reference{Backend: "vault", Path: "secret/proxy", Field: "pass#word"}, wantOk: true},
+		"Not a reference without backend":   {value: "secret/proxy#password"},
+		"Not a reference without path":      {value: "vault://#password"},
+		"Not a reference with empty field":  {value: "vault://secret/proxy#"},
+		"Not a reference with uppercase":    {value: "Vault://secret/proxy"},
+		"Not a reference for plain secrets": {value: "p@ssw0rd"},
+		"Not a reference for empty value":   {value: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := secrets.ParseReference(tc.value)
+			require.Equal(t, tc.wantOk, ok, "ParseReference returned an unexpected ok")
+			require.Equal(t, tc.want, got, "ParseReference returned an unexpected reference")
+		})
+	}
+}
@@ -0,0 +1,270 @@
+// Package snaps provides a manager to install and remove snaps.
+//
+// The policy is only supported on computers. It has two entries:
+//   - install: the snaps to install, one per line.
+//   - remove: the snaps to remove, one per line.
+//
+// The snaps are installed and removed with the snap command. The snaps installed by adsys are recorded in a state
+// file, so that only them can be removed: a snap installed otherwise is never removed, even if listed in the remove
+// entry. A snap which was already installed when listed in the install entry is not managed by adsys either.
+//
+// Snaps not listed in the install entry anymore are kept installed: they need to be listed in the remove entry to be
+// removed.
+//
+// Installing a snap requires the snap store to be reachable. If it isn't, the installation is deferred to the next
+// refresh without failing the policy.
+package snaps
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+// managedFileName is the name of the state file listing the snaps installed by adsys.
+const managedFileName = "managed"
+
+// snapNameRe matches a snap name, as accepted by the snap store.
+var snapNameRe = regexp.MustCompile(`^[a-z0-9](-?[a-z0-9])*$`)
+
+// offlineMarkers are parts of the snap command output when the snap store can't be reached.
+var offlineMarkers = []string{
+	"unable to contact snap store",
+	"persistent network error",
+	"network is unreachable",
+	"temporary failure in name resolution",
+}
+
+// Manager installs and removes the snaps of the snaps policy.
+type Manager struct {
+	stateDir string
+	snapCmd  []string
+}
+
+type options struct {
+	snapCmd []string
+}
+
+// Option reprents an optional function to change the snaps manager.
+type Option func(*options)
+
+// WithSnapCmd overrides the default command used to list, install and remove snaps.
+func WithSnapCmd(cmd []string) Option {
+	return func(o *options) {
+		o.snapCmd = cmd
+	}
+}
+
+// New creates a manager recording the snaps it installs in the snaps/ subdirectory of stateDir.
+func New(stateDir string, opts ...Option) *Manager {
+	// defaults
+	args := options{
+		snapCmd: []string{"snap"},
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Manager{
+		stateDir: filepath.Join(stateDir, "snaps"),
+		snapCmd:  args.snapCmd,
+	}
+}
+
+// ApplyPolicy installs the snaps of the install entry and removes the snaps of the remove entry which were
+// installed by adsys.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply snaps policy to %s", objectName))
+
+	// Snaps policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	var install, remove []string
+	for _, e := range entries {
+		if e.Key != "install" && e.Key != "remove" {
+			ignored.RecordUnsupportedKey(ctx, "snaps", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled {
+			continue
+		}
+		names, err := parseNames(e.Key, e.Value)
+		if err != nil {
+			return err
+		}
+		if e.Key == "install" {
+			install = names
+		} else {
+			remove = names
+		}
+	}
+	for _, name := range install {
+		if slices.Contains(remove, name) {
+			return errors.New(gotext.Get("snap %q is listed to be both installed and removed", name))
+		}
+	}
+
+	managed, err := m.managedSnaps()
+	if err != nil {
+		return err
+	}
+	if len(install) == 0 && len(remove) == 0 && len(managed) == 0 {
+		return nil
+	}
+
+	log.Debugf(ctx, "Applying snaps policy to %s", objectName)
+
+	installed, err := m.installedSnaps(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Forget the managed snaps which were removed outside of adsys.
+	managed = slices.DeleteFunc(managed, func(name string) bool { return !slices.Contains(installed, name) })
+
+	for _, name := range remove {
+		if !slices.Contains(installed, name) {
+			continue
+		}
+		if !slices.Contains(managed, name) {
+			log.Warning(ctx, gotext.Get("Snap %q was not installed by adsys: not removing it", name))
+			continue
+		}
+		log.Info(ctx, gotext.Get("Removing snap %s", name))
+		if _, err := m.run(ctx, "remove", name); err != nil {
+			return errors.Join(err, m.saveManaged(managed))
+		}
+		managed = slices.DeleteFunc(managed, func(n string) bool { return n == name })
+	}
+
+	for _, name := range install {
+		if slices.Contains(installed, name) {
+			continue
+		}
+		log.Info(ctx, gotext.Get("Installing snap %s", name))
+		out, err := m.run(ctx, "install", name)
+		if err != nil && isOffline(out) {
+			log.Warning(ctx, gotext.Get("Snap store is unreachable: deferring installation of snap %s to next refresh", name))
+			continue
+		}
+		if err != nil {
+			return errors.Join(err, m.saveManaged(managed))
+		}
+		managed = append(managed, name)
+	}
+
+	return m.saveManaged(managed)
+}
+
+// parseNames returns the snap names, one per line, of the value of the install or remove entry.
+// Empty lines are ignored.
+func parseNames(key, value string) (names []string, err error) {
+	for _, name := range strings.Split(value, "\n") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !snapNameRe.MatchString(name) {
+			return nil, errors.New(gotext.Get("invalid snap name %q in %s", name, key))
+		}
+		if slices.Contains(names, name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// installedSnaps returns the names of the snaps installed on the system.
+func (m *Manager) installedSnaps(ctx context.Context) (names []string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't list installed snaps"))
+
+	out, err := m.run(ctx, "list")
+	if err != nil {
+		return nil, err
+	}
+
+	// The first line is the header, if any snap is installed.
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	for _, l := range lines[1:] {
+		fields := strings.Fields(l)
+		if len(fields) == 0 {
+			continue
+		}
+		names = append(names, fields[0])
+	}
+	return names, nil
+}
+
+// managedSnaps returns the snaps installed by adsys, as recorded in the state file.
+func (m *Manager) managedSnaps() (names []string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't read snaps installed by adsys"))
+
+	data, err := os.ReadFile(filepath.Join(m.stateDir, managedFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(data)), nil
+}
+
+// saveManaged records the snaps installed by adsys in the state file, or removes it if there are none.
+func (m *Manager) saveManaged(names []string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't save snaps installed by adsys"))
+
+	p := filepath.Join(m.stateDir, managedFileName)
+	if len(names) == 0 {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	slices.Sort(names)
+	if err := os.MkdirAll(m.stateDir, 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(p+".new", []byte(strings.Join(names, "\n")+"\n"), 0600); err != nil {
+		return err
+	}
+	return os.Rename(p+".new", p)
+}
+
+// run executes the snap command with args and returns its combined output.
+func (m *Manager) run(ctx context.Context, args ...string) ([]byte, error) {
+	args = append(slices.Clone(m.snapCmd[1:]), args...)
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, m.snapCmd[0], args...)
+	trace.Command(ctx, "snaps", cmd.Args)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return out, errors.New(gotext.Get("failed to run %q: %v\n%s", strings.Join(cmd.Args, " "), err, string(out)))
+	}
+	return out, nil
+}
+
+// isOffline returns true if the snap command output reports that the snap store can't be reached.
+func isOffline(out []byte) bool {
+	o := strings.ToLower(string(out))
+	return slices.ContainsFunc(offlineMarkers, func(marker string) bool { return strings.Contains(o, marker) })
+}
@@ -0,0 +1,188 @@
+package snaps_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/snaps"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{
+		{Key: "install", Value: "firefox\nvlc"},
+		{Key: "remove", Value: "gimp"},
+	}
+
+	tests := map[string]struct {
+		entries    []entry.Entry
+		secondCall []entry.Entry
+		user       bool
+
+		installed []string
+		managed   []string
+		snapMode  string
+
+		wantErr bool
+	}{
+		"Computer, snaps are installed":                        {},
+		"Computer, duplicated and empty lines are ignored":     {entries: []entry.Entry{{Key: "install", Value: "\n firefox \n\nfirefox\n"}}},
+		"Computer, installed snaps are not reinstalled":        {installed: []string{"firefox", "vlc"}, managed: []string{"vlc"}},
+		"Computer, managed snaps are removed":                  {installed: []string{"gimp"}, managed: []string{"gimp"}},
+		"Computer, unmanaged snaps are not removed":            {installed: []string{"gimp"}},
+		"Computer, already installed snaps are not managed":    {installed: []string{"firefox"}},
+		"Computer, snaps removed outside of adsys are dropped": {managed: []string{"gimp", "vlc"}},
+		"Computer, snaps not in the policy anymore are kept":   {entries: []entry.Entry{{Key: "install", Value: "firefox"}}, installed: []string{"vlc"}, managed: []string{"vlc"}},
+		"Computer, disabled entries change nothing":            {entries: []entry.Entry{{Key: "install", Disabled: true}, {Key: "remove", Disabled: true}}, installed: []string{"gimp"}, managed: []string{"gimp"}},
+		"Computer, no entries and no managed snaps":            {entries: []entry.Entry{}, installed: []string{"gimp"}},
+		"Computer, unexpected entry key is ignored":            {entries: []entry.Entry{{Key: "refresh", Value: "firefox"}}},
+		"Computer, offline defers installation":                {snapMode: "offline"},
+		"Computer, offline still removes managed snaps":        {installed: []string{"gimp"}, managed: []string{"gimp"}, snapMode: "offline"},
+		"User, policy is ignored":                              {user: true},
+
+		// Reconciliation
+		"Computer, installed snaps can be removed on next refresh":  {secondCall: []entry.Entry{{Key: "remove", Value: "vlc"}}},
+		"Computer, unmanaged snaps are not removed on next refresh": {installed: []string{"firefox"}, secondCall: []entry.Entry{{Key: "remove", Value: "firefox\nvlc"}}},
+
+		// Error cases
+		"Error on invalid snap name":                 {entries: []entry.Entry{{Key: "install", Value: "Firefox"}}, wantErr: true},
+		"Error on snap name with spaces":             {entries: []entry.Entry{{Key: "install", Value: "firefox --classic"}}, wantErr: true},
+		"Error on snap to both install and remove":   {entries: []entry.Entry{{Key: "install", Value: "firefox"}, {Key: "remove", Value: "firefox"}}, wantErr: true},
+		"Error on listing snaps failing":             {snapMode: "fail-list", wantErr: true},
+		"Error on installing snap failing":           {snapMode: "fail-install", wantErr: true},
+		"Error on removing snap failing keeps state": {installed: []string{"gimp"}, managed: []string{"gimp"}, snapMode: "fail-remove", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			rootDir := t.TempDir()
+			stateDir := filepath.Join(rootDir, "state")
+			installedFile := filepath.Join(rootDir, "installed")
+			if tc.installed != nil {
+				err := os.WriteFile(installedFile, []byte(strings.Join(tc.installed, "\n")+"\n"), 0600)
+				require.NoError(t, err, "Setup: Can't write installed snaps")
+			}
+			if tc.managed != nil {
+				err := os.MkdirAll(filepath.Join(stateDir, "snaps"), 0700)
+				require.NoError(t, err, "Setup: Can't create snaps state directory")
+				err = os.WriteFile(filepath.Join(stateDir, "snaps", "managed"), []byte(strings.Join(tc.managed, "\n")+"\n"), 0600)
+				require.NoError(t, err, "Setup: Can't write managed snaps")
+			}
+			mode := tc.snapMode
+			if mode == "" {
+				mode = "online"
+			}
+
+			m := snaps.New(stateDir, snaps.WithSnapCmd(mockSnapCmd(t, rootDir, mode)))
+
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				// We don't return here as we want to check that the state is as expected even in error cases
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.secondCall != nil {
+				err = m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.secondCall)
+				require.NoError(t, err, "Second ApplyPolicy failed but shouldn't have")
+			}
+
+			testutils.CompareTreesWithFiltering(t, rootDir, testutils.GoldenPath(t), testutils.UpdateEnabled())
+		})
+	}
+}
+
+// mockSnapCmd returns a snap command keeping the installed snaps in rootDir/installed and logging its calls in
+// rootDir/commands.
+func mockSnapCmd(t *testing.T, rootDir, mode string) []string {
+	t.Helper()
+
+	return []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockSnap", "--", rootDir, mode}
+}
+
+func TestMockSnap(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := os.Args
+	for len(args) > 0 {
+		if args[0] != "--" {
+			args = args[1:]
+			continue
+		}
+		args = args[1:]
+		break
+	}
+	// First args after -- are the directory of the fake snapd and the mode, then the snap command arguments
+	rootDir, mode, args := args[0], args[1], args[2:]
+	installedFile := filepath.Join(rootDir, "installed")
+
+	f, err := os.OpenFile(filepath.Join(rootDir, "commands"), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	require.NoError(t, err, "Setup: Can't open snap commands file")
+	_, err = f.WriteString(strings.Join(args, " ") + "\n")
+	require.NoError(t, err, "Setup: Can't write snap commands file")
+	f.Close()
+
+	var installed []string
+	if data, err := os.ReadFile(installedFile); err == nil {
+		installed = strings.Fields(string(data))
+	}
+
+	fail := func(msg string) {
+		fmt.Fprintln(os.Stderr, msg)
+		// nolint:gocritic // we want to exit with an error without running the deferred calls
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		if mode == "fail-list" {
+			fail("error: cannot list snaps")
+		}
+		if len(installed) == 0 {
+			fmt.Fprintln(os.Stderr, "No snaps are installed yet. Try 'snap install hello-world'.")
+			return
+		}
+		fmt.Println("Name  Version  Rev  Tracking  Publisher  Notes")
+		for _, name := range installed {
+			fmt.Printf("%s  1.0  1  latest/stable  canonical✓  -\n", name)
+		}
+		return
+	case "install":
+		switch mode {
+		case "offline":
+			fail(`error: unable to contact snap store`)
+		case "fail-install":
+			fail(fmt.Sprintf("error: snap %q not found", args[1]))
+		}
+		installed = append(installed, args[1])
+	case "remove":
+		if mode == "fail-remove" {
+			fail(fmt.Sprintf("error: cannot remove %q: snap is in use", args[1]))
+		}
+		installed = slices.DeleteFunc(installed, func(n string) bool { return n == args[1] })
+	default:
+		fail(fmt.Sprintf("error: unknown command %q", args[0]))
+	}
+
+	err = os.WriteFile(installedFile, []byte(strings.Join(installed, "\n")+"\n"), 0600)
+	require.NoError(t, err, "Setup: Can't write installed snaps")
+}
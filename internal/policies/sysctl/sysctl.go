@@ -0,0 +1,219 @@
+// Package sysctl provides a manager to apply kernel parameters with sysctl.
+//
+// The policy is only supported on computers. The kernel parameters of the
+// sysctl entry, one "key = value" assignment per line, are written to a file
+// managed by adsys in the sysctl configuration directory, and applied by
+// reloading all sysctl configuration files with sysctl --system.
+//
+// Each key is validated against the kernel parameters exposed in /proc/sys
+// before writing anything.
+//
+// The file is only written, and the configuration reloaded, when its content
+// changed. If there are no parameters to apply, the managed file is removed,
+// and the configuration is reloaded if it existed.
+package sysctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+// sysctlFileName is the name of the file, in the sysctl configuration directory, managed by adsys.
+// It is ordered late so that its parameters override the ones set by the distribution and other packages.
+const sysctlFileName = "99-adsys.conf"
+
+// Manager applies the kernel parameters of the sysctl policy.
+type Manager struct {
+	sysctlDir  string
+	procSysDir string
+	sysctlCmd  []string
+}
+
+type options struct {
+	procSysDir string
+	sysctlCmd  []string
+}
+
+// Option reprents an optional function to change the sysctl manager.
+type Option func(*options)
+
+// WithProcSysDir specifies a personalized directory for the kernel parameters, used to validate the keys.
+func WithProcSysDir(path string) Option {
+	return func(o *options) {
+		o.procSysDir = path
+	}
+}
+
+// WithSysctlCmd overrides the default command reloading the sysctl configuration.
+func WithSysctlCmd(cmd []string) Option {
+	return func(o *options) {
+		o.sysctlCmd = cmd
+	}
+}
+
+// New creates a manager with a specific sysctl configuration directory.
+func New(sysctlDir string, opts ...Option) *Manager {
+	// defaults
+	args := options{
+		procSysDir: "/proc/sys",
+		sysctlCmd:  []string{"sysctl", "--system"},
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Manager{
+		sysctlDir:  sysctlDir,
+		procSysDir: args.procSysDir,
+		sysctlCmd:  args.sysctlCmd,
+	}
+}
+
+// ApplyPolicy writes the kernel parameters of the policy to the managed sysctl file and reloads the sysctl
+// configuration if it changed.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply sysctl policy to %s", objectName))
+
+	// Sysctl policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	sysctlPath := filepath.Join(m.sysctlDir, sysctlFileName)
+
+	var content string
+	for _, e := range entries {
+		if e.Key != "sysctl" {
+			ignored.RecordUnsupportedKey(ctx, "sysctl", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled {
+			continue
+		}
+		if content, err = m.parseParameters(e.Value); err != nil {
+			return err
+		}
+	}
+
+	if content == "" {
+		if _, err := os.Stat(sysctlPath); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		log.Debug(ctx, gotext.Get("Removing sysctl configuration %s", sysctlPath))
+		if removed, err := tamper.Remove(ctx, "sysctl", sysctlPath); err != nil || !removed {
+			return err
+		}
+		return m.reload(ctx)
+	}
+
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(m.sysctlDir, 0755); err != nil {
+		return err
+	}
+
+	oldContent, err := os.ReadFile(sysctlPath)
+	if err == nil && string(oldContent) == content {
+		log.Debug(ctx, gotext.Get("Sysctl configuration %s is up to date", sysctlPath))
+		return nil
+	}
+
+	log.Debugf(ctx, "Applying sysctl policy to %s", objectName)
+	// The sysctl configuration is world-readable, as the kernel parameters are.
+	if written, err := tamper.Write(ctx, "sysctl", sysctlPath, []byte(content), 0644); err != nil || !written {
+		return err
+	}
+
+	return m.reload(ctx)
+}
+
+// reload applies the sysctl configuration files of the system.
+func (m *Manager) reload(ctx context.Context) error {
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, m.sysctlCmd[0], m.sysctlCmd[1:]...)
+	trace.Command(ctx, "sysctl", cmd.Args)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return errors.New(gotext.Get("failed to reload sysctl configuration: %v\n%s", err, string(out)))
+	}
+	return nil
+}
+
+// parseParameters validates the kernel parameters, one "key = value" per line, of the sysctl entry value and
+// returns the content of the managed sysctl file. Empty lines and comments are ignored.
+func (m *Manager) parseParameters(value string) (content string, err error) {
+	var params []string
+	for _, l := range strings.Split(value, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, ";") {
+			continue
+		}
+
+		key, v, found := strings.Cut(l, "=")
+		key, v = strings.TrimSpace(key), strings.TrimSpace(v)
+		if !found || key == "" {
+			return "", errors.New(gotext.Get("invalid sysctl parameter %q: expecting key = value", l))
+		}
+		if err := m.checkKey(key); err != nil {
+			return "", err
+		}
+		params = append(params, fmt.Sprintf("%s = %s", key, v))
+	}
+
+	if len(params) == 0 {
+		return "", nil
+	}
+
+	return fmt.Sprintf(`# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+
+%s
+`, strings.Join(params, "\n")), nil
+}
+
+// checkKey returns an error if the key is not a kernel parameter exposed in the /proc/sys directory.
+// As for sysctl, dots and slashes are swapped in the key if its first separator is a dot.
+func (m *Manager) checkKey(key string) error {
+	p := key
+	if i := strings.IndexAny(key, "./"); i != -1 && key[i] == '.' {
+		p = strings.Map(func(r rune) rune {
+			switch r {
+			case '.':
+				return '/'
+			case '/':
+				return '.'
+			}
+			return r
+		}, key)
+	}
+
+	for _, elem := range strings.Split(p, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return errors.New(gotext.Get("invalid sysctl key %q", key))
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(m.procSysDir, p))
+	if err != nil || info.IsDir() {
+		return errors.New(gotext.Get("unknown sysctl key %q", key))
+	}
+	return nil
+}
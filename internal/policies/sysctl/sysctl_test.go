@@ -0,0 +1,149 @@
+package sysctl_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/sysctl"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{{Key: "sysctl", Value: "net.ipv4.ip_forward = 1\nvm.swappiness=10"}}
+
+	tests := map[string]struct {
+		entries []entry.Entry
+		user    bool
+
+		sysctlFileAlreadyExists bool
+		readOnlySysctlDir       bool
+		sysctlCmdError          bool
+
+		wantReload bool
+		wantErr    bool
+	}{
+		"Computer, parameters are written and applied":          {wantReload: true},
+		"Computer, unchanged parameters are not applied again":  {sysctlFileAlreadyExists: true},
+		"Computer, changed parameters are written and applied":  {entries: []entry.Entry{{Key: "sysctl", Value: "kernel.dmesg_restrict = 1"}}, sysctlFileAlreadyExists: true, wantReload: true},
+		"Computer, comments and blank lines are ignored":        {entries: []entry.Entry{{Key: "sysctl", Value: "# Enable routing\n\n net.ipv4.ip_forward = 1 \n; Reduce swapping\nvm.swappiness = 10\n"}}, sysctlFileAlreadyExists: true},
+		"Computer, keys with slashes are validated":             {entries: []entry.Entry{{Key: "sysctl", Value: "net/ipv4/ip_forward = 1"}}, wantReload: true},
+		"Computer, no entries removes existing parameters":      {entries: []entry.Entry{}, sysctlFileAlreadyExists: true, wantReload: true},
+		"Computer, disabled entry removes existing parameters":  {entries: []entry.Entry{{Key: "sysctl", Disabled: true}}, sysctlFileAlreadyExists: true, wantReload: true},
+		"Computer, only comments removes existing parameters":   {entries: []entry.Entry{{Key: "sysctl", Value: "# nothing\n"}}, sysctlFileAlreadyExists: true, wantReload: true},
+		"Computer, no entries and no existing parameters":       {entries: []entry.Entry{}},
+		"Computer, no entries and read-only sysctl directory":   {entries: []entry.Entry{}, readOnlySysctlDir: true},
+		"Computer, unexpected entry key is ignored":             {entries: []entry.Entry{{Key: "sysctl-foo", Value: "vm.swappiness = 10"}}},
+		"User, policy is ignored":                               {user: true},
+		"User, existing parameters are kept":                    {entries: []entry.Entry{}, sysctlFileAlreadyExists: true, user: true},
+		"Error on unknown key":                                  {entries: []entry.Entry{{Key: "sysctl", Value: "net.ipv4.does_not_exist = 1"}}, sysctlFileAlreadyExists: true, wantErr: true},
+		"Error on key being a directory":                        {entries: []entry.Entry{{Key: "sysctl", Value: "net.ipv4 = 1"}}, wantErr: true},
+		"Error on key escaping proc sys directory":              {entries: []entry.Entry{{Key: "sysctl", Value: "../sys/vm/swappiness = 1"}}, wantErr: true},
+		"Error on key with empty element":                       {entries: []entry.Entry{{Key: "sysctl", Value: "vm..swappiness = 1"}}, wantErr: true},
+		"Error on parameter not being an assignment":            {entries: []entry.Entry{{Key: "sysctl", Value: "vm.swappiness"}}, wantErr: true},
+		"Error on parameter without key":                        {entries: []entry.Entry{{Key: "sysctl", Value: "= 1"}}, wantErr: true},
+		"Error on read-only sysctl directory":                   {readOnlySysctlDir: true, wantErr: true},
+		"Error on sysctl command failing":                       {sysctlCmdError: true, wantReload: true, wantErr: true},
+		"Error on sysctl command failing on removed parameters": {entries: []entry.Entry{}, sysctlFileAlreadyExists: true, sysctlCmdError: true, wantReload: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			sysctlDir := filepath.Join(t.TempDir(), "sysctl.d")
+			if tc.sysctlFileAlreadyExists {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "sysctl_dir"), sysctlDir)
+			}
+			if tc.readOnlySysctlDir {
+				require.NoError(t, os.MkdirAll(sysctlDir, 0750), "Setup: can't create sysctl dir")
+				testutils.MakeReadOnly(t, sysctlDir)
+			}
+
+			sysctlOutputFile := filepath.Join(t.TempDir(), "sysctl-output")
+			sysctlCmd := mockSysctlCmd(t, sysctlOutputFile)
+			if tc.sysctlCmdError {
+				sysctlCmd = append(sysctlCmd, "-Exit1")
+			}
+
+			m := sysctl.New(sysctlDir,
+				sysctl.WithProcSysDir(filepath.Join(testutils.TestFamilyPath(t), "proc", "sys")),
+				sysctl.WithSysctlCmd(sysctlCmd))
+
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				// We don't return here as we want to check that the sysctl dir is in the expected state even in error cases
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.readOnlySysctlDir {
+				// nolint:gosec //false positive, this is a directory
+				require.NoError(t, os.Chmod(sysctlDir, 0750), "Setup: can't restore sysctl dir permissions")
+			}
+			testutils.CompareTreesWithFiltering(t, sysctlDir, filepath.Join(testutils.GoldenPath(t), "etc", "sysctl.d"), testutils.UpdateEnabled())
+
+			got, err := os.ReadFile(sysctlOutputFile)
+			if !tc.wantReload {
+				require.Error(t, err, "sysctl should not have been called")
+				return
+			}
+			require.NoError(t, err, "sysctl should have been called")
+			require.Equal(t, "--system\n", string(got), "sysctl should have been called once to reload all configuration files")
+		})
+	}
+}
+
+func mockSysctlCmd(t *testing.T, outputFile string) []string {
+	t.Helper()
+
+	return []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockSysctl", "--", outputFile, "--system"}
+}
+
+func TestMockSysctl(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := os.Args
+	for len(args) > 0 {
+		if args[0] != "--" {
+			args = args[1:]
+			continue
+		}
+		args = args[1:]
+		break
+	}
+	// First arg after -- is the output file to write to
+	outputFile, args := args[0], args[1:]
+
+	var wantExit bool
+	if args[len(args)-1] == "-Exit1" {
+		wantExit = true
+		args = args[:len(args)-1]
+	}
+
+	f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	require.NoError(t, err, "Setup: Can't open sysctl output file")
+	defer f.Close()
+	_, err = f.WriteString(strings.Join(args, " ") + "\n")
+	require.NoError(t, err, "Setup: Can't write sysctl output file")
+
+	if wantExit {
+		f.Close()
+		// nolint:gocritic // we want to exit with an error without running the deferred calls
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,235 @@
+// Package tamper handles the managed files which were modified locally since adsys last wrote them.
+//
+// The guard records, in the state directory, the checksum of the content adsys last wrote to each managed file.
+// Before rewriting or removing a file, its content on disk is compared with it: if it differs, the file was
+// modified locally and the mode configured for the policy type decides what happens:
+//   - overwrite, the default, replaces the local changes, as adsys always did;
+//   - preserve keeps the local changes and skips the write, with a warning on each refresh until the file is restored
+//     or the mode changes;
+//   - backup-then-overwrite copies the modified file next to it, with the BackupSuffix suffix, before replacing it.
+//
+// Files adsys never wrote, like the ones of a previous version, and files removed locally are not considered as
+// modified. The guard is carried by the context given to the policy managers. Writing without guard always
+// overwrites the file.
+package tamper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+	"gopkg.in/yaml.v3"
+)
+
+// Mode is how a policy type handles its managed files modified locally.
+type Mode string
+
+const (
+	// Overwrite replaces the local changes.
+	Overwrite Mode = "overwrite"
+	// Preserve keeps the local changes and skips the write.
+	Preserve Mode = "preserve"
+	// BackupThenOverwrite saves the modified file before replacing it.
+	BackupThenOverwrite Mode = "backup-then-overwrite"
+)
+
+const (
+	// StateFileName is the name of the file, in the state directory, recording the checksum of each managed file.
+	StateFileName = "tamper.yaml"
+	// BackupSuffix is appended to the path of a modified file to back it up. It is not matched by the configuration
+	// file patterns of the managed directories, like *.conf, so that the backup is not loaded.
+	BackupSuffix = ".adsys-modified"
+)
+
+// Guard compares the managed files with the content adsys last wrote to handle their local changes. It is safe
+// for concurrent use.
+type Guard struct {
+	statePath string
+	modes     map[string]Mode
+
+	mu        sync.Mutex
+	checksums map[string]string
+}
+
+// New returns a guard storing its state in stateDir, handling the modified files of each policy type with its mode
+// in modes. Policy types without mode overwrite them.
+func New(stateDir string, modes map[string]Mode) (*Guard, error) {
+	for rule, mode := range modes {
+		switch mode {
+		case Overwrite, Preserve, BackupThenOverwrite:
+		default:
+			return nil, errors.New(gotext.Get("unknown mode %q for modified files of %s policy: must be %q, %q or %q",
+				mode, rule, Overwrite, Preserve, BackupThenOverwrite))
+		}
+	}
+
+	return &Guard{
+		statePath: filepath.Join(stateDir, StateFileName),
+		modes:     modes,
+	}, nil
+}
+
+// guardKey is the context key of the guard.
+type guardKey struct{}
+
+// WithGuard returns a copy of ctx handling the modified files with g.
+func WithGuard(ctx context.Context, g *Guard) context.Context {
+	return context.WithValue(ctx, guardKey{}, g)
+}
+
+// Write atomically writes content with perm to the managed file path of the rule policy type, unless it was
+// modified locally and the mode of rule preserves it. It returns if the file was written.
+func Write(ctx context.Context, rule, path string, content []byte, perm fs.FileMode) (written bool, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't write %s", path))
+
+	g, _ := ctx.Value(guardKey{}).(*Guard)
+	if ok, err := g.handleModified(ctx, rule, path); err != nil || !ok {
+		return false, err
+	}
+
+	if err := os.WriteFile(path+".new", content, perm); err != nil {
+		return false, err
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		return false, err
+	}
+	g.record(ctx, path, checksum(content))
+	return true, nil
+}
+
+// Remove removes the managed file path of the rule policy type, unless it was modified locally and the mode of rule
+// preserves it. It returns if the file was removed, which is false if it didn't exist.
+func Remove(ctx context.Context, rule, path string) (removed bool, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't remove %s", path))
+
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	g, _ := ctx.Value(guardKey{}).(*Guard)
+	if ok, err := g.handleModified(ctx, rule, path); err != nil || !ok {
+		return false, err
+	}
+
+	if err := os.Remove(path); err != nil {
+		return false, err
+	}
+	g.record(ctx, path, "")
+	return true, nil
+}
+
+// handleModified checks if path was modified since adsys last wrote it and applies the mode of rule if it was.
+// It returns if adsys can replace the file.
+func (g *Guard) handleModified(ctx context.Context, rule, path string) (replace bool, err error) {
+	if g == nil {
+		return true, nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.load(); err != nil {
+		log.Warningf(ctx, "Can't load the checksums of the managed files, not checking if %s was modified: %v", path, err)
+		return true, nil
+	}
+	want, ok := g.checksums[path]
+	if !ok {
+		return true, nil
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	if checksum(content) == want {
+		return true, nil
+	}
+
+	switch g.modes[rule] {
+	case Preserve:
+		log.Warning(ctx, gotext.Get("%s was modified locally since adsys last wrote it: keeping the local changes, the %s policy is not applied to this file", path, rule))
+		return false, nil
+	case BackupThenOverwrite:
+		backup := path + BackupSuffix
+		// The backup can hold local secrets: only root can read it.
+		if err := os.WriteFile(backup+".new", content, 0600); err != nil {
+			return false, err
+		}
+		if err := os.Rename(backup+".new", backup); err != nil {
+			return false, err
+		}
+		log.Warning(ctx, gotext.Get("%s was modified locally since adsys last wrote it: the local changes are saved to %s and replaced by the %s policy", path, backup, rule))
+	default:
+		log.Warning(ctx, gotext.Get("%s was modified locally since adsys last wrote it: the local changes are replaced by the %s policy", path, rule))
+	}
+	return true, nil
+}
+
+// record saves the checksum of the content adsys just wrote to path, or forgets path if sum is empty.
+func (g *Guard) record(ctx context.Context, path, sum string) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.save(path, sum); err != nil {
+		log.Warningf(ctx, "Local changes to %s won't be detected: %v", path, err)
+	}
+}
+
+// save updates the checksum of path in the state file, or removes it if sum is empty.
+func (g *Guard) save(path, sum string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't record the checksum of %s", path))
+
+	if err := g.load(); err != nil {
+		return err
+	}
+	if sum == "" {
+		delete(g.checksums, path)
+	} else {
+		g.checksums[path] = sum
+	}
+
+	d, err := yaml.Marshal(g.checksums)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(g.statePath), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(g.statePath+".new", d, 0600); err != nil {
+		return err
+	}
+	return os.Rename(g.statePath+".new", g.statePath)
+}
+
+// load reads the state file. It is read again each time, as the privilege grants expiry rewrites the managed
+// files, and records their checksum, from another process.
+func (g *Guard) load() error {
+	checksums := make(map[string]string)
+	d, err := os.ReadFile(g.statePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := yaml.Unmarshal(d, &checksums); err != nil {
+		return err
+	}
+	g.checksums = checksums
+	return nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
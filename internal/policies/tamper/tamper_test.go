@@ -0,0 +1,193 @@
+package tamper_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+)
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		mode           tamper.Mode
+		notWritten     bool
+		modifiedOnDisk bool
+		removedOnDisk  bool
+		otherRule      bool
+		noGuard        bool
+
+		wantWritten bool
+		wantContent string
+		wantBackup  string
+	}{
+		"Unmodified file is overwritten":                 {mode: tamper.Preserve, wantWritten: true, wantContent: "new policy"},
+		"File never written by adsys is overwritten":     {mode: tamper.Preserve, notWritten: true, wantWritten: true, wantContent: "new policy"},
+		"File removed locally is written again":          {mode: tamper.Preserve, removedOnDisk: true, wantWritten: true, wantContent: "new policy"},
+		"Modified file of another rule is overwritten":   {mode: tamper.Preserve, modifiedOnDisk: true, otherRule: true, wantWritten: true, wantContent: "new policy"},
+		"Modified file is overwritten without guard":     {mode: tamper.Preserve, modifiedOnDisk: true, noGuard: true, wantWritten: true, wantContent: "new policy"},
+		"Modified file is overwritten by default":        {modifiedOnDisk: true, wantWritten: true, wantContent: "new policy"},
+		"Modified file is overwritten in overwrite mode": {mode: tamper.Overwrite, modifiedOnDisk: true, wantWritten: true, wantContent: "new policy"},
+		"Modified file is kept in preserve mode":         {mode: tamper.Preserve, modifiedOnDisk: true, wantContent: "local edit"},
+		"Modified file is backed up in backup-then-overwrite mode": {
+			mode: tamper.BackupThenOverwrite, modifiedOnDisk: true, wantWritten: true, wantContent: "new policy", wantBackup: "local edit"},
+		"Unmodified file is not backed up in backup-then-overwrite mode": {
+			mode: tamper.BackupThenOverwrite, wantWritten: true, wantContent: "new policy"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			path := filepath.Join(dir, "managed.conf")
+			ctx := context.Background()
+			if !tc.noGuard {
+				modes := map[string]tamper.Mode{}
+				if tc.mode != "" {
+					rule := "sysctl"
+					if tc.otherRule {
+						rule = "limits"
+					}
+					modes[rule] = tc.mode
+				}
+				g, err := tamper.New(filepath.Join(dir, "state"), modes)
+				require.NoError(t, err, "Setup: New should not fail")
+				ctx = tamper.WithGuard(ctx, g)
+			}
+
+			if tc.notWritten {
+				require.NoError(t, os.WriteFile(path, []byte("previous content"), 0600), "Setup: can't write managed file")
+			} else {
+				written, err := tamper.Write(ctx, "sysctl", path, []byte("old policy"), 0600)
+				require.NoError(t, err, "Setup: first Write should not fail")
+				require.True(t, written, "Setup: first Write should write the file")
+			}
+			if tc.modifiedOnDisk {
+				require.NoError(t, os.WriteFile(path, []byte("local edit"), 0600), "Setup: can't modify managed file")
+			}
+			if tc.removedOnDisk {
+				require.NoError(t, os.Remove(path), "Setup: can't remove managed file")
+			}
+
+			written, err := tamper.Write(ctx, "sysctl", path, []byte("new policy"), 0600)
+			require.NoError(t, err, "Write should not fail")
+			require.Equal(t, tc.wantWritten, written, "Write should return if the file was written")
+
+			got, err := os.ReadFile(path)
+			require.NoError(t, err, "Managed file should exist")
+			require.Equal(t, tc.wantContent, string(got), "Managed file content is not the expected one")
+
+			backup, err := os.ReadFile(path + tamper.BackupSuffix)
+			if tc.wantBackup == "" {
+				require.ErrorIs(t, err, os.ErrNotExist, "No backup should be created")
+			} else {
+				require.NoError(t, err, "Backup should be created")
+				require.Equal(t, tc.wantBackup, string(backup), "Backup should have the local changes")
+			}
+
+			if !tc.wantWritten || tc.noGuard {
+				return
+			}
+			// Once written, the file is not considered as modified anymore, even if preserved.
+			g, err := tamper.New(filepath.Join(dir, "state"), map[string]tamper.Mode{"sysctl": tamper.Preserve})
+			require.NoError(t, err, "Setup: New should not fail")
+			written, err = tamper.Write(tamper.WithGuard(context.Background(), g), "sysctl", path, []byte("newer policy"), 0600)
+			require.NoError(t, err, "Write should not fail")
+			require.True(t, written, "Write after the policy one should write the file")
+		})
+	}
+}
+
+func TestWriteRecordedByAnotherGuard(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "managed.conf")
+	modes := map[string]tamper.Mode{"privilege": tamper.Preserve}
+	g, err := tamper.New(filepath.Join(dir, "state"), modes)
+	require.NoError(t, err, "Setup: New should not fail")
+	ctx := tamper.WithGuard(context.Background(), g)
+	_, err = tamper.Write(ctx, "privilege", path, []byte("policy"), 0600)
+	require.NoError(t, err, "Setup: Write should not fail")
+
+	// Another process, like the privilege grants expiry, rewrites the file with its own guard.
+	other, err := tamper.New(filepath.Join(dir, "state"), modes)
+	require.NoError(t, err, "Setup: New should not fail")
+	_, err = tamper.Write(tamper.WithGuard(context.Background(), other), "privilege", path, []byte("expired grants removed"), 0600)
+	require.NoError(t, err, "Setup: Write from another guard should not fail")
+
+	written, err := tamper.Write(ctx, "privilege", path, []byte("new policy"), 0600)
+	require.NoError(t, err, "Write should not fail")
+	require.True(t, written, "Write should not consider the file written by another guard as modified")
+}
+
+func TestRemove(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		mode           tamper.Mode
+		modifiedOnDisk bool
+		notExisting    bool
+
+		wantRemoved bool
+		wantBackup  bool
+	}{
+		"Unmodified file is removed":                               {mode: tamper.Preserve, wantRemoved: true},
+		"Modified file is removed in overwrite mode":               {mode: tamper.Overwrite, modifiedOnDisk: true, wantRemoved: true},
+		"Modified file is kept in preserve mode":                   {mode: tamper.Preserve, modifiedOnDisk: true},
+		"Modified file is backed up in backup-then-overwrite mode": {mode: tamper.BackupThenOverwrite, modifiedOnDisk: true, wantRemoved: true, wantBackup: true},
+		"Missing file is not removed":                              {mode: tamper.Preserve, notExisting: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			path := filepath.Join(dir, "managed.conf")
+			g, err := tamper.New(filepath.Join(dir, "state"), map[string]tamper.Mode{"sysctl": tc.mode})
+			require.NoError(t, err, "Setup: New should not fail")
+			ctx := tamper.WithGuard(context.Background(), g)
+
+			if !tc.notExisting {
+				_, err = tamper.Write(ctx, "sysctl", path, []byte("policy"), 0600)
+				require.NoError(t, err, "Setup: Write should not fail")
+			}
+			if tc.modifiedOnDisk {
+				require.NoError(t, os.WriteFile(path, []byte("local edit"), 0600), "Setup: can't modify managed file")
+			}
+
+			removed, err := tamper.Remove(ctx, "sysctl", path)
+			require.NoError(t, err, "Remove should not fail")
+			require.Equal(t, tc.wantRemoved, removed, "Remove should return if the file was removed")
+
+			_, err = os.Stat(path)
+			if tc.wantRemoved || tc.notExisting {
+				require.ErrorIs(t, err, os.ErrNotExist, "Managed file should not exist")
+			} else {
+				require.NoError(t, err, "Managed file should be kept")
+			}
+			_, err = os.Stat(path + tamper.BackupSuffix)
+			if tc.wantBackup {
+				require.NoError(t, err, "Backup should be created")
+			} else {
+				require.ErrorIs(t, err, os.ErrNotExist, "No backup should be created")
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	_, err := tamper.New(t.TempDir(), map[string]tamper.Mode{"sysctl": tamper.Preserve, "units": tamper.BackupThenOverwrite})
+	require.NoError(t, err, "New should accept known modes")
+
+	_, err = tamper.New(t.TempDir(), map[string]tamper.Mode{"sysctl": "keep"})
+	require.Error(t, err, "New should fail on unknown mode")
+}
@@ -0,0 +1,295 @@
+// Package timedate provides a manager to set the timezone and the NTP servers of the machine.
+//
+// The policy is only supported on computers. It supports the following entries:
+//   - timezone: the name of the timezone in the tz database, like Europe/Paris, set with
+//     timedatectl set-timezone when it differs from the current one.
+//   - ntp-servers: the NTP servers, separated by commas or new lines, written to a systemd-timesyncd
+//     drop-in managed by adsys, and to a chrony sources file managed by adsys if chrony is installed.
+//
+// The timezone and each server are validated before changing anything. The time
+// synchronization services are restarted when their configuration changed.
+//
+// As there is no previous timezone to restore, the timezone is left as is when the
+// policy doesn't set it anymore. The managed NTP configuration files are removed.
+package timedate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/trace"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// timesyncdDropIn is the path, relative to the configuration directory, of the systemd-timesyncd drop-in
+	// managed by adsys.
+	timesyncdDropIn = "systemd/timesyncd.conf.d/adsys.conf"
+	// chronySources is the path, relative to the configuration directory, of the chrony sources file
+	// managed by adsys.
+	chronySources = "chrony/sources.d/adsys.sources"
+)
+
+var (
+	// timezoneRe matches the valid timezone names.
+	timezoneRe = regexp.MustCompile(`^[A-Za-z0-9_+-]+(/[A-Za-z0-9_+-]+)*$`)
+	// hostnameRe matches the valid host names.
+	hostnameRe = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)*[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.?$`)
+)
+
+// Manager sets the timezone and the NTP servers of the timedate policy.
+type Manager struct {
+	confDir        string
+	zoneinfoDir    string
+	timedatectlCmd []string
+	restartCmd     []string
+}
+
+type options struct {
+	confDir        string
+	zoneinfoDir    string
+	timedatectlCmd []string
+	restartCmd     []string
+}
+
+// Option reprents an optional function to change the timedate manager.
+type Option func(*options)
+
+// WithConfDir specifies a personalized directory for the localtime link and the timesyncd and chrony
+// configuration.
+func WithConfDir(p string) Option {
+	return func(o *options) {
+		o.confDir = p
+	}
+}
+
+// WithZoneinfoDir specifies a personalized tz database directory, used to validate the timezone.
+func WithZoneinfoDir(p string) Option {
+	return func(o *options) {
+		o.zoneinfoDir = p
+	}
+}
+
+// WithTimedatectlCmd overrides the default command setting the timezone. The timezone is appended to it.
+func WithTimedatectlCmd(cmd []string) Option {
+	return func(o *options) {
+		o.timedatectlCmd = cmd
+	}
+}
+
+// WithRestartCmd overrides the default command restarting the time synchronization services.
+func WithRestartCmd(cmd []string) Option {
+	return func(o *options) {
+		o.restartCmd = cmd
+	}
+}
+
+// New creates a manager setting the timezone and the NTP servers.
+func New(opts ...Option) *Manager {
+	// defaults
+	args := options{
+		confDir:        "/etc",
+		zoneinfoDir:    "/usr/share/zoneinfo",
+		timedatectlCmd: []string{"timedatectl", "set-timezone"},
+		restartCmd:     []string{"systemctl", "try-restart", "systemd-timesyncd.service", "chrony.service"},
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Manager{
+		confDir:        args.confDir,
+		zoneinfoDir:    args.zoneinfoDir,
+		timedatectlCmd: args.timedatectlCmd,
+		restartCmd:     args.restartCmd,
+	}
+}
+
+// ApplyPolicy sets the timezone of the policy if it changed, and writes its NTP servers to the managed
+// timesyncd and chrony configuration, or removes them if there are none.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply timedate policy to %s", objectName))
+
+	// Timedate policies are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	var timezone string
+	var servers []string
+	for _, e := range entries {
+		if e.Disabled {
+			continue
+		}
+		switch e.Key {
+		case "timezone":
+			timezone = strings.TrimSpace(e.Value)
+			if timezone == "" {
+				continue
+			}
+			if err := m.checkTimezone(timezone); err != nil {
+				return err
+			}
+		case "ntp-servers":
+			if servers, err = parseServers(e.Value); err != nil {
+				return err
+			}
+		default:
+			ignored.RecordUnsupportedKey(ctx, "timedate", e.Key, e.Value)
+		}
+	}
+
+	log.Debugf(ctx, "Applying timedate policy to %s", objectName)
+
+	if err := m.setTimezone(ctx, timezone); err != nil {
+		return err
+	}
+
+	var timesyncdContent, chronyContent string
+	if len(servers) > 0 {
+		timesyncdContent = fmt.Sprintf("[Time]\nNTP=%s\n", strings.Join(servers, " "))
+		var sources []string
+		for _, s := range servers {
+			sources = append(sources, fmt.Sprintf("server %s iburst", s))
+		}
+		chronyContent = strings.Join(sources, "\n") + "\n"
+	}
+
+	timesyncdChanged, err := m.writeConf(ctx, timesyncdDropIn, timesyncdContent)
+	if err != nil {
+		return err
+	}
+	var chronyChanged bool
+	// Only configure chrony if it is installed
+	if _, err := os.Stat(filepath.Join(m.confDir, "chrony")); err == nil {
+		if chronyChanged, err = m.writeConf(ctx, chronySources, chronyContent); err != nil {
+			return err
+		}
+	}
+
+	if timesyncdChanged || chronyChanged {
+		m.restart(ctx)
+	}
+
+	return nil
+}
+
+// checkTimezone returns an error if timezone is not in the tz database.
+func (m *Manager) checkTimezone(timezone string) error {
+	if !timezoneRe.MatchString(timezone) {
+		return errors.New(gotext.Get("invalid timezone %q", timezone))
+	}
+	info, err := os.Stat(filepath.Join(m.zoneinfoDir, timezone))
+	if err != nil || !info.Mode().IsRegular() {
+		return errors.New(gotext.Get("unknown timezone %q: it is not in the tz database", timezone))
+	}
+	return nil
+}
+
+// setTimezone sets the timezone of the machine if it is not already the current one.
+func (m *Manager) setTimezone(ctx context.Context, timezone string) error {
+	if timezone == "" {
+		return nil
+	}
+
+	// The localtime link points to the current timezone in the tz database
+	if target, err := os.Readlink(filepath.Join(m.confDir, "localtime")); err == nil {
+		if _, current, found := strings.Cut(target, "zoneinfo/"); found && current == timezone {
+			log.Debug(ctx, gotext.Get("Timezone is already %s", timezone))
+			return nil
+		}
+	}
+
+	log.Debug(ctx, gotext.Get("Setting timezone to %s", timezone))
+	// #nosec G204 - We are in control of the arguments, and the timezone is validated
+	cmd := exec.CommandContext(ctx, m.timedatectlCmd[0], append(m.timedatectlCmd[1:], timezone)...)
+	trace.Command(ctx, "timedate", cmd.Args)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return errors.New(gotext.Get("failed to set timezone to %s: %v\n%s", timezone, err, string(out)))
+	}
+	return nil
+}
+
+// writeConf writes content, with the adsys header, to the managed configuration file at the path relative
+// to the configuration directory, or removes it if content is empty. It returns true if the file changed.
+func (m *Manager) writeConf(ctx context.Context, relPath, content string) (changed bool, err error) {
+	p := filepath.Join(m.confDir, relPath)
+
+	if content == "" {
+		if _, err := os.Stat(p); errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		log.Debug(ctx, gotext.Get("Removing NTP configuration %s", p))
+		return true, os.Remove(p)
+	}
+
+	content = fmt.Sprintf(`# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+
+%s`, content)
+
+	oldContent, err := os.ReadFile(p)
+	if err == nil && string(oldContent) == content {
+		log.Debug(ctx, gotext.Get("NTP configuration %s is up to date", p))
+		return false, nil
+	}
+
+	// nolint:gosec // G301 match distribution permission
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return false, err
+	}
+	log.Debug(ctx, gotext.Get("Writing NTP configuration %s", p))
+	// nolint:gosec // G306 NTP configuration is world-readable, as in the distribution
+	if err := os.WriteFile(p+".new", []byte(content), 0644); err != nil {
+		return false, err
+	}
+	return true, os.Rename(p+".new", p)
+}
+
+// restart restarts the running time synchronization services so that they use the new NTP servers.
+// Failures are only logged, as the configuration is written and will be used on next start.
+func (m *Manager) restart(ctx context.Context) {
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, m.restartCmd[0], m.restartCmd[1:]...)
+	trace.Command(ctx, "timedate", cmd.Args)
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		log.Warning(ctx, gotext.Get("Failed to restart the time synchronization services: %v\n%s", err, string(out)))
+	}
+}
+
+// parseServers validates the NTP servers, separated by commas or white spaces, of the entry value and returns
+// them without duplicates.
+func parseServers(value string) (servers []string, err error) {
+	for _, s := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' }) {
+		if net.ParseIP(s) == nil && !hostnameRe.MatchString(s) {
+			return nil, errors.New(gotext.Get("invalid NTP server %q: expecting a host name or an IP address", s))
+		}
+		if slices.Contains(servers, s) {
+			continue
+		}
+		servers = append(servers, s)
+	}
+	return servers, nil
+}
@@ -0,0 +1,178 @@
+package timedate_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/timedate"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultEntries := []entry.Entry{
+		{Key: "timezone", Value: "Europe/Paris"},
+		{Key: "ntp-servers", Value: "ntp1.example.com, ntp2.example.com"},
+	}
+
+	tests := map[string]struct {
+		entries []entry.Entry
+		user    bool
+
+		currentTimezone    string
+		chronyInstalled    bool
+		ntpConfExists      bool
+		timedatectlCmdFail bool
+		restartCmdFail     bool
+
+		wantTimezone string
+		wantRestart  bool
+		wantErr      bool
+	}{
+		"Computer, timezone and NTP servers are set":                {wantTimezone: "Europe/Paris", wantRestart: true},
+		"Computer, changed timezone is set":                         {currentTimezone: "America/New_York", wantTimezone: "Europe/Paris", wantRestart: true},
+		"Computer, unchanged timezone is not set again":             {currentTimezone: "Europe/Paris", wantRestart: true},
+		"Computer, NTP servers are written for chrony if installed": {chronyInstalled: true, wantTimezone: "Europe/Paris", wantRestart: true},
+		"Computer, unchanged NTP servers do not restart services":   {ntpConfExists: true, currentTimezone: "Europe/Paris"},
+		"Computer, changed NTP servers restart services":            {entries: []entry.Entry{{Key: "ntp-servers", Value: "ntp3.example.com"}}, ntpConfExists: true, wantRestart: true},
+		"Computer, NTP servers can be IP addresses":                 {entries: []entry.Entry{{Key: "ntp-servers", Value: "192.0.2.1\n2001:db8::1"}}, wantRestart: true},
+		"Computer, NTP servers are deduplicated":                    {entries: []entry.Entry{{Key: "ntp-servers", Value: "ntp1.example.com,\nntp2.example.com\n ntp1.example.com,"}}, chronyInstalled: true, wantRestart: true},
+		"Computer, empty timezone leaves the timezone unchanged":    {entries: []entry.Entry{{Key: "timezone", Value: " "}}, currentTimezone: "America/New_York"},
+		"Computer, no NTP servers removes existing configuration":   {entries: []entry.Entry{{Key: "timezone", Value: "Europe/Paris"}}, ntpConfExists: true, wantTimezone: "Europe/Paris", wantRestart: true},
+		"Computer, no entries removes existing configuration":       {entries: []entry.Entry{}, ntpConfExists: true, wantRestart: true},
+		"Computer, disabled entries remove existing configuration":  {entries: []entry.Entry{{Key: "timezone", Disabled: true}, {Key: "ntp-servers", Disabled: true}}, ntpConfExists: true, wantRestart: true},
+		"Computer, no entries and no existing configuration":        {entries: []entry.Entry{}},
+		"Computer, unexpected entry key is ignored":                 {entries: []entry.Entry{{Key: "ntp-pools", Value: "pool.example.com"}}},
+		"Computer, restart failing only warns":                      {restartCmdFail: true, wantTimezone: "Europe/Paris", wantRestart: true},
+		"User, policy is ignored":                                   {user: true},
+		"User, existing configuration is kept":                      {entries: []entry.Entry{}, ntpConfExists: true, user: true},
+
+		"Error on timezone not in tz database":          {entries: []entry.Entry{{Key: "timezone", Value: "Europe/Atlantis"}}, ntpConfExists: true, wantErr: true},
+		"Error on timezone being a directory":           {entries: []entry.Entry{{Key: "timezone", Value: "Europe"}}, wantErr: true},
+		"Error on timezone escaping tz database":        {entries: []entry.Entry{{Key: "timezone", Value: "../ntp_conf/systemd/timesyncd.conf.d/adsys.conf"}}, wantErr: true},
+		"Error on timezone being an absolute path":      {entries: []entry.Entry{{Key: "timezone", Value: "/etc/passwd"}}, wantErr: true},
+		"Error on invalid NTP server":                   {entries: []entry.Entry{{Key: "ntp-servers", Value: "ntp1.example.com;reboot"}}, ntpConfExists: true, wantErr: true},
+		"Error on invalid NTP server before timezone":   {entries: []entry.Entry{{Key: "timezone", Value: "Europe/Paris"}, {Key: "ntp-servers", Value: "-ntp.example.com"}}, wantErr: true},
+		"Error on timedatectl failing keeps NTP config": {entries: []entry.Entry{{Key: "timezone", Value: "UTC"}}, ntpConfExists: true, timedatectlCmdFail: true, wantTimezone: "UTC", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = defaultEntries
+			}
+
+			confDir := filepath.Join(t.TempDir(), "etc")
+			if tc.ntpConfExists {
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "ntp_conf"), confDir)
+			} else {
+				require.NoError(t, os.MkdirAll(confDir, 0750), "Setup: can't create configuration dir")
+			}
+			if tc.chronyInstalled {
+				require.NoError(t, os.MkdirAll(filepath.Join(confDir, "chrony"), 0750), "Setup: can't create chrony dir")
+			}
+			if tc.currentTimezone != "" {
+				require.NoError(t, os.Symlink(filepath.Join("/usr/share/zoneinfo", tc.currentTimezone), filepath.Join(confDir, "localtime")),
+					"Setup: can't create localtime link")
+			}
+
+			timedatectlOutputFile := filepath.Join(t.TempDir(), "timedatectl-output")
+			timedatectlCmd := mockCmd(t, timedatectlOutputFile, "set-timezone")
+			if tc.timedatectlCmdFail {
+				timedatectlCmd = append(timedatectlCmd, "-Exit1")
+			}
+			restartOutputFile := filepath.Join(t.TempDir(), "restart-output")
+			restartCmd := mockCmd(t, restartOutputFile, "try-restart", "systemd-timesyncd.service", "chrony.service")
+			if tc.restartCmdFail {
+				restartCmd = append(restartCmd, "-Exit1")
+			}
+
+			m := timedate.New(
+				timedate.WithConfDir(confDir),
+				timedate.WithZoneinfoDir(filepath.Join(testutils.TestFamilyPath(t), "zoneinfo")),
+				timedate.WithTimedatectlCmd(timedatectlCmd),
+				timedate.WithRestartCmd(restartCmd))
+
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				// We don't return here as we want to check that the configuration is in the expected state even in error cases
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			// The localtime link is only set by timedatectl and doesn't need to be compared
+			require.NoError(t, os.RemoveAll(filepath.Join(confDir, "localtime")), "Teardown: can't remove localtime link")
+			testutils.CompareTreesWithFiltering(t, confDir, filepath.Join(testutils.GoldenPath(t), "etc"), testutils.UpdateEnabled())
+
+			got, err := os.ReadFile(timedatectlOutputFile)
+			if tc.wantTimezone == "" {
+				require.Error(t, err, "timedatectl should not have been called")
+			} else {
+				require.NoError(t, err, "timedatectl should have been called")
+				require.Equal(t, "set-timezone "+tc.wantTimezone+"\n", string(got), "timedatectl should have been called once to set the timezone")
+			}
+
+			got, err = os.ReadFile(restartOutputFile)
+			if !tc.wantRestart {
+				require.Error(t, err, "time synchronization services should not have been restarted")
+				return
+			}
+			require.NoError(t, err, "time synchronization services should have been restarted")
+			require.Equal(t, "try-restart systemd-timesyncd.service chrony.service\n", string(got), "time synchronization services should have been restarted once")
+		})
+	}
+}
+
+func mockCmd(t *testing.T, outputFile string, args ...string) []string {
+	t.Helper()
+
+	return append([]string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockCmd", "--", outputFile}, args...)
+}
+
+func TestMockCmd(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := os.Args
+	for len(args) > 0 {
+		if args[0] != "--" {
+			args = args[1:]
+			continue
+		}
+		args = args[1:]
+		break
+	}
+	// First arg after -- is the output file to write to
+	outputFile, args := args[0], args[1:]
+
+	// Arguments can be appended by the manager after the failure flag
+	var wantExit bool
+	if i := slices.Index(args, "-Exit1"); i != -1 {
+		wantExit = true
+		args = slices.Delete(args, i, i+1)
+	}
+
+	f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	require.NoError(t, err, "Setup: Can't open command output file")
+	defer f.Close()
+	_, err = f.WriteString(strings.Join(args, " ") + "\n")
+	require.NoError(t, err, "Setup: Can't write command output file")
+
+	if wantExit {
+		f.Close()
+		// nolint:gocritic // we want to exit with an error without running the deferred calls
+		os.Exit(1)
+	}
+}
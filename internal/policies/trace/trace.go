@@ -0,0 +1,274 @@
+// Package trace records, for one policy refresh, what each policy manager received and produced in a bundle
+// directory, which can be archived for support escalations.
+//
+// The bundle is carried by the context given to the policy managers. Recording on a context without bundle is a
+// no-op. Failing to record is only logged, as tracing never fails a refresh.
+//
+// Each object whose policy is applied gets a directory in the bundle, and each policy type a directory in it, with
+// any of:
+//   - entries.yaml: the entries given to the manager;
+//   - files.diff: the changes of the files managed by the manager, as a unified diff;
+//   - commands: the commands run by the manager, one per line;
+//   - error: the error returned by the manager.
+//
+// Credentials are redacted from all of them: the passwords embedded in URLs, like the proxy ones, and the values of
+// the settings whose name looks like a password, secret, token or pre-shared key.
+package trace
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pmezard/go-difflib/difflib"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"gopkg.in/yaml.v3"
+)
+
+// maxFileSize is the size above which a managed file is not part of the snapshots.
+const maxFileSize = 1024 * 1024
+
+// redacted replaces the credentials in the bundle.
+const redacted = "<redacted>"
+
+var (
+	// urlCredentialsRe matches the user name and password embedded in a URL, with or without scheme, like in the
+	// proxy settings and the mount units.
+	urlCredentialsRe = regexp.MustCompile(`(^|[\s,;="'(#]|//)([^\s/@:,;="'()#]+):([^\s/@,;()]+)@`)
+	// secretSettingRe matches the lines setting a password, secret, token or pre-shared key.
+	secretSettingRe = regexp.MustCompile(`(?im)^(\s*[\w.-]*(?:password|passwd|secret|token|psk)[\w.-]*\s*[=:]\s*)\S.*$`)
+	// secretKeyRe matches the entry keys whose value is a password, secret, token or pre-shared key.
+	secretKeyRe = regexp.MustCompile(`(?i)password|passwd|secret|token|psk`)
+)
+
+// Bundle is the directory where a refresh is recorded. It is safe for concurrent use.
+type Bundle struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// bundleKey is the context key of the bundle.
+type bundleKey struct{}
+
+// New creates the bundle directory dir.
+func New(dir string) (*Bundle, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Bundle{dir: dir}, nil
+}
+
+// Dir returns the bundle directory.
+func (b *Bundle) Dir() string {
+	return b.dir
+}
+
+// WithBundle returns a copy of ctx recording in b.
+func WithBundle(ctx context.Context, b *Bundle) context.Context {
+	return context.WithValue(ctx, bundleKey{}, b)
+}
+
+// WithObject returns a copy of ctx recording in the directory of objectName in its bundle, if any.
+func WithObject(ctx context.Context, objectName string) context.Context {
+	b, ok := ctx.Value(bundleKey{}).(*Bundle)
+	if !ok {
+		return ctx
+	}
+	return WithBundle(ctx, &Bundle{dir: filepath.Join(b.dir, objectName)})
+}
+
+// Enabled returns true if ctx records in a bundle.
+func Enabled(ctx context.Context) bool {
+	_, ok := ctx.Value(bundleKey{}).(*Bundle)
+	return ok
+}
+
+// Entries records the entries given to the manager of rule.
+func Entries(ctx context.Context, rule string, entries []entry.Entry) {
+	var redactedEntries []entry.Entry
+	for _, e := range entries {
+		if secretKeyRe.MatchString(e.Key) && e.Value != "" {
+			e.Value = redacted
+		}
+		e.Value = redact(e.Value)
+		redactedEntries = append(redactedEntries, e)
+	}
+
+	d, err := yaml.Marshal(redactedEntries)
+	if err != nil {
+		log.Warningf(ctx, "Can't trace entries of %s: %v", rule, err)
+		return
+	}
+	write(ctx, rule, "entries.yaml", string(d))
+}
+
+// Command records a command run by the manager of rule.
+func Command(ctx context.Context, rule string, args []string) {
+	var redactedArgs []string
+	for _, arg := range args {
+		redactedArgs = append(redactedArgs, redact(arg))
+	}
+	write(ctx, rule, "commands", strings.Join(redactedArgs, " ")+"\n")
+}
+
+// Error records the error returned by the manager of rule, if any.
+func Error(ctx context.Context, rule string, err error) {
+	if err == nil {
+		return
+	}
+	write(ctx, rule, "error", redact(err.Error())+"\n")
+}
+
+// Files is the content of some managed files, mapped by their path.
+type Files map[string]string
+
+// Snapshot returns the content of the regular files matching the glob patterns, and of the ones in the matching
+// directories. Files bigger than 1 MiB are skipped. It returns nil if ctx doesn't record in a bundle.
+func Snapshot(ctx context.Context, patterns ...string) Files {
+	if !Enabled(ctx) {
+		return nil
+	}
+
+	var roots []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Warningf(ctx, "Can't snapshot %s for tracing: %v", pattern, err)
+			continue
+		}
+		roots = append(roots, matches...)
+	}
+
+	files := make(Files)
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if info.Size() > maxFileSize {
+				return nil
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files[path] = string(content)
+			return nil
+		})
+		if err != nil {
+			log.Warningf(ctx, "Can't snapshot %s for tracing: %v", root, err)
+		}
+	}
+	return files
+}
+
+// Changes records the unified diff of the managed files of rule between the before and after snapshots. Nothing is
+// recorded if no file changed.
+func Changes(ctx context.Context, rule string, before, after Files) {
+	if !Enabled(ctx) {
+		return
+	}
+
+	var paths []string
+	for p := range before {
+		paths = append(paths, p)
+	}
+	for p := range after {
+		if _, ok := before[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	slices.Sort(paths)
+
+	var diffs strings.Builder
+	for _, p := range paths {
+		old, oldExists := before[p]
+		cur, curExists := after[p]
+		if oldExists == curExists && old == cur {
+			continue
+		}
+		from, to := "a"+p, "b"+p
+		if !oldExists {
+			from = "/dev/null"
+		}
+		if !curExists {
+			to = "/dev/null"
+		}
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        splitLines(redact(old)),
+			B:        splitLines(redact(cur)),
+			FromFile: from,
+			ToFile:   to,
+			Context:  3,
+		})
+		if err != nil {
+			log.Warningf(ctx, "Can't trace changes of %s: %v", p, err)
+			continue
+		}
+		// Files whose only changes are redacted credentials still appear as changed.
+		if diff == "" {
+			diff = fmt.Sprintf("--- %s\n+++ %s\n@@ credentials changed @@\n", from, to)
+		}
+		diffs.WriteString(diff)
+	}
+
+	if diffs.Len() == 0 {
+		return
+	}
+	write(ctx, rule, "files.diff", diffs.String())
+}
+
+// write appends content to the file name in the directory of rule in the bundle of ctx, if any.
+func write(ctx context.Context, rule, name, content string) {
+	b, ok := ctx.Value(bundleKey{}).(*Bundle)
+	if !ok {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	dir := filepath.Join(b.dir, rule)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Warningf(ctx, "Can't write trace of %s: %v", rule, err)
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		log.Warningf(ctx, "Can't write trace of %s: %v", rule, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		log.Warningf(ctx, "Can't write trace of %s: %v", rule, err)
+	}
+}
+
+// splitLines splits s in lines, keeping their line feed.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// redact replaces the credentials of s.
+func redact(s string) string {
+	s = urlCredentialsRe.ReplaceAllString(s, "${1}${2}:"+redacted+"@")
+	return secretSettingRe.ReplaceAllString(s, "${1}"+redacted)
+}
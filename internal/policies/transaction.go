@@ -0,0 +1,195 @@
+package policies
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// transaction is the backup of the files managed by the policy managers, taken before a transactional refresh, so
+// that all their changes can be rolled back if any of them fails.
+type transaction struct {
+	patterns []string
+	files    map[string]backup
+	// dirs are the directories which existed before the refresh.
+	dirs map[string]struct{}
+}
+
+// backup is a managed file, or symlink, as it was before the refresh.
+type backup struct {
+	mode     fs.FileMode
+	uid, gid int
+	content  []byte
+	target   string
+}
+
+// newTransaction backs up the files matching the glob patterns of the managed paths of each policy manager, and the
+// ones in the matching directories.
+func newTransaction(managedPaths map[string][]string) (t *transaction, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't back up managed files"))
+
+	t = &transaction{
+		files: make(map[string]backup),
+		dirs:  make(map[string]struct{}),
+	}
+	for _, patterns := range managedPaths {
+		t.patterns = append(t.patterns, patterns...)
+	}
+
+	err = t.walk(func(path string, d fs.DirEntry) error {
+		if d.IsDir() {
+			t.dirs[path] = struct{}{}
+			return nil
+		}
+		b, err := readBackup(path)
+		if err != nil {
+			return err
+		}
+		t.files[path] = b
+		return nil
+	})
+	return t, err
+}
+
+// walk calls fn for every directory, regular file and symlink matching the patterns of the transaction, or in the
+// matching directories. Symlinks are not followed.
+func (t *transaction) walk(fn func(path string, d fs.DirEntry) error) error {
+	for _, pattern := range t.patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		for _, root := range matches {
+			err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() && !d.Type().IsRegular() && d.Type()&fs.ModeSymlink == 0 {
+					return nil
+				}
+				return fn(path, d)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readBackup returns the content, permissions and owner of the file or symlink at path.
+func readBackup(path string) (b backup, err error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return b, err
+	}
+	b.mode = fi.Mode()
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		b.uid, b.gid = int(st.Uid), int(st.Gid)
+	}
+
+	if fi.Mode()&fs.ModeSymlink != 0 {
+		b.target, err = os.Readlink(path)
+		return b, err
+	}
+	b.content, err = os.ReadFile(path)
+	return b, err
+}
+
+// rollback restores the managed files as they were when the transaction started: the files changed or removed since
+// then are restored, and the ones created are removed, with their directories. Restoring continues on errors, which
+// are all returned.
+func (t *transaction) rollback(ctx context.Context) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't roll back managed files"))
+
+	var errs []error
+	var created, createdDirs []string
+	if err := t.walk(func(path string, d fs.DirEntry) error {
+		if d.IsDir() {
+			if _, ok := t.dirs[path]; !ok {
+				createdDirs = append(createdDirs, path)
+			}
+			return nil
+		}
+		if _, ok := t.files[path]; !ok {
+			created = append(created, path)
+		}
+		return nil
+	}); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, path := range created {
+		log.Debugf(ctx, "Rolling back %s: removing it", path)
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	for path, b := range t.files {
+		if current, err := readBackup(path); err == nil && current.equal(b) {
+			continue
+		}
+		log.Debugf(ctx, "Rolling back %s: restoring it", path)
+		if err := b.restore(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Remove the deepest directories first. Directories with files not managed by adsys are kept.
+	slices.Sort(createdDirs)
+	slices.Reverse(createdDirs)
+	for _, dir := range createdDirs {
+		if err := os.Remove(dir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Debugf(ctx, "Not removing directory %s created during the refresh: %v", dir, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// equal returns true if both backups have the same type, content, permissions and owner.
+func (b backup) equal(o backup) bool {
+	return b.mode == o.mode && b.uid == o.uid && b.gid == o.gid && b.target == o.target && bytes.Equal(b.content, o.content)
+}
+
+// restore writes back the file or symlink at path, creating its parent directories if needed.
+func (b backup) restore(path string) error {
+	// nolint:gosec // G301 match the usual system configuration directories permission
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".adsys-rollback"
+	_ = os.Remove(tmp)
+	if b.mode&fs.ModeSymlink != 0 {
+		if err := os.Symlink(b.target, tmp); err != nil {
+			return err
+		}
+		if err := os.Lchown(tmp, b.uid, b.gid); err != nil {
+			return err
+		}
+		return os.Rename(tmp, path)
+	}
+
+	if err := os.WriteFile(tmp, b.content, b.mode.Perm()); err != nil {
+		return err
+	}
+	// Apply the permissions regardless of the umask, and the owner.
+	if err := os.Chmod(tmp, b.mode.Perm()); err != nil {
+		return err
+	}
+	if err := os.Chown(tmp, b.uid, b.gid); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
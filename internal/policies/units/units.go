@@ -0,0 +1,243 @@
+// Package units provides a manager to override settings of existing systemd units with drop-ins.
+//
+// The policy is only supported on computers. Each line of the unit-overrides entry sets a directive of a unit, in the
+// "unit;Section;Key=Value" format, like "cups.service;Service;Restart=always". The directives of each unit are written,
+// in order, to a 99-adsys.conf drop-in in the <unit>.d directory of the system unit directory. The same key can be set
+// multiple times, for instance to reset a list with an empty value first.
+//
+// Unit names, sections and keys are validated before writing anything. Only the [Unit] section and the section of the
+// unit type, like [Service] for services, can be set.
+//
+// On each refresh, the drop-ins are reconciled with the policy: drop-ins of units which are not in the policy anymore
+// are removed, and systemd is reloaded if any drop-in changed. The units are not restarted.
+package units
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/ignored"
+	"github.com/ubuntu/adsys/internal/policies/tamper"
+	"github.com/ubuntu/decorate"
+)
+
+// dropInFileName is the name of the drop-in, in the unit drop-in directory, managed by adsys.
+// It is ordered late so that it overrides the drop-ins of the distribution and other packages.
+const dropInFileName = "99-adsys.conf"
+
+var (
+	// unitNameRe matches the names of the units, including template instances, which can be overridden.
+	unitNameRe = regexp.MustCompile(`^[A-Za-z0-9:_.\\-]+(@[A-Za-z0-9:_.\\-]*)?\.(service|socket|timer|mount|automount|path|swap|slice|target)$`)
+	// directiveKeyRe matches the keys of the unit directives, like Restart or ExecStart.
+	directiveKeyRe = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+)
+
+// Manager applies the systemd unit overrides of the units policy.
+type Manager struct {
+	systemUnitDir string
+	systemdCaller systemdCaller
+}
+
+type systemdCaller interface {
+	DaemonReload(context.Context) error
+}
+
+// New creates a manager writing the drop-ins in the systemUnitDir unit directory.
+func New(systemUnitDir string, systemdCaller systemdCaller) *Manager {
+	return &Manager{
+		systemUnitDir: systemUnitDir,
+		systemdCaller: systemdCaller,
+	}
+}
+
+// directive is a setting of a unit section.
+type directive struct {
+	section string
+	key     string
+	value   string
+}
+
+// ApplyPolicy reconciles the managed drop-ins with the unit overrides of the policy, and reloads systemd if any of
+// them changed.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply systemd unit overrides policy to %s", objectName))
+
+	// Unit overrides are only supported on computers
+	if !isComputer {
+		return nil
+	}
+
+	log.Debugf(ctx, "Applying systemd unit overrides policy to %s", objectName)
+
+	overrides := make(map[string][]directive)
+	for _, e := range entries {
+		if e.Key != "unit-overrides" {
+			ignored.RecordUnsupportedKey(ctx, "units", e.Key, e.Value)
+			continue
+		}
+		if e.Disabled {
+			continue
+		}
+		if overrides, err = parseOverrides(e.Value); err != nil {
+			return err
+		}
+	}
+
+	var changed bool
+	// Withdrawn overrides are removed first.
+	current, err := filepath.Glob(filepath.Join(m.systemUnitDir, "*.d", dropInFileName))
+	if err != nil {
+		return err
+	}
+	for _, p := range current {
+		dir := filepath.Dir(p)
+		if _, ok := overrides[strings.TrimSuffix(filepath.Base(dir), ".d")]; ok {
+			continue
+		}
+		log.Debugf(ctx, "Removing systemd unit drop-in %s", p)
+		if removed, err := tamper.Remove(ctx, "units", p); err != nil {
+			return err
+		} else if !removed {
+			continue
+		}
+		// Only remove the drop-in directory if there are no other drop-ins.
+		_ = os.Remove(dir)
+		changed = true
+	}
+
+	units := make([]string, 0, len(overrides))
+	for unit := range overrides {
+		units = append(units, unit)
+	}
+	slices.Sort(units)
+	for _, unit := range units {
+		written, err := m.writeDropIn(ctx, unit, dropInContent(overrides[unit]))
+		if err != nil {
+			return err
+		}
+		if written {
+			log.Debugf(ctx, "Updated systemd unit drop-in of %s", unit)
+		}
+		changed = changed || written
+	}
+
+	if !changed {
+		return nil
+	}
+	return m.systemdCaller.DaemonReload(ctx)
+}
+
+// parseOverrides parses the unit directives, one per line, of the entry value, in the "unit;Section;Key=Value"
+// format. Empty lines and comments are ignored.
+func parseOverrides(value string) (overrides map[string][]directive, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to parse systemd unit overrides"))
+
+	overrides = make(map[string][]directive)
+	for _, l := range strings.Split(value, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(l, ";", 3)
+		if len(fields) != 3 {
+			return nil, errors.New(gotext.Get("override %q is badly formatted: expecting unit;Section;Key=Value", l))
+		}
+		unit, section := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])
+		key, value, found := strings.Cut(fields[2], "=")
+		if !found {
+			return nil, errors.New(gotext.Get("override %q is badly formatted: expecting unit;Section;Key=Value", l))
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		if !unitNameRe.MatchString(unit) || len(unit) > 255 {
+			return nil, errors.New(gotext.Get("override %q has an invalid unit name %q", l, unit))
+		}
+		// The units generated by adsys are managed by their own policies.
+		if strings.HasPrefix(unit, "adsys-") {
+			return nil, errors.New(gotext.Get("override %q targets unit %q managed by adsys", l, unit))
+		}
+		if typeSection := unitTypeSection(unit); section != "Unit" && (typeSection == "" || section != typeSection) {
+			if typeSection == "" {
+				return nil, errors.New(gotext.Get("override %q has an invalid section %q: only Unit is allowed", l, section))
+			}
+			return nil, errors.New(gotext.Get("override %q has an invalid section %q: only Unit and %s are allowed", l, section, typeSection))
+		}
+		if !directiveKeyRe.MatchString(key) {
+			return nil, errors.New(gotext.Get("override %q has an invalid key %q", l, key))
+		}
+		// A trailing backslash would continue the directive on the next line.
+		if strings.ContainsFunc(value, unicode.IsControl) || strings.HasSuffix(value, `\`) {
+			return nil, errors.New(gotext.Get("override %q has an invalid value %q", l, value))
+		}
+
+		overrides[unit] = append(overrides[unit], directive{section: section, key: key, value: value})
+	}
+
+	return overrides, nil
+}
+
+// unitTypeSection returns the name of the section of the unit type, like Service for cups.service. It is empty for
+// targets, which have no type specific section.
+func unitTypeSection(unit string) string {
+	t := strings.TrimPrefix(filepath.Ext(unit), ".")
+	if t == "target" {
+		return ""
+	}
+	return strings.ToUpper(t[:1]) + t[1:]
+}
+
+// dropInContent returns the content of the drop-in setting the directives. The directives are grouped by section,
+// in the order the sections first appear, keeping their order.
+func dropInContent(directives []directive) string {
+	var sections []string
+	for _, d := range directives {
+		if !slices.Contains(sections, d.section) {
+			sections = append(sections, d.section)
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString(`# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+`)
+	for _, s := range sections {
+		out.WriteString(fmt.Sprintf("\n[%s]\n", s))
+		for _, d := range directives {
+			if d.section != s {
+				continue
+			}
+			out.WriteString(fmt.Sprintf("%s=%s\n", d.key, d.value))
+		}
+	}
+	return out.String()
+}
+
+// writeDropIn writes the drop-in of unit if its content changed, creating the drop-in directory if needed.
+func (m *Manager) writeDropIn(ctx context.Context, unit, content string) (written bool, err error) {
+	dir := filepath.Join(m.systemUnitDir, unit+".d")
+	p := filepath.Join(dir, dropInFileName)
+	defer decorate.OnError(&err, gotext.Get("can't save %s", p))
+
+	if oldContent, err := os.ReadFile(p); err == nil && string(oldContent) == content {
+		return false, nil
+	}
+
+	// nolint:gosec // G301 match systemd permission
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, err
+	}
+	// Unit files need to be world-readable.
+	return tamper.Write(ctx, "units", p, []byte(content), 0644)
+}
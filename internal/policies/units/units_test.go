@@ -0,0 +1,137 @@
+package units_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/units"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	const defaultOverrides = "cups.service;Service;Restart=always\ncups.service;Unit;StartLimitIntervalSec=0\nssh.socket;Socket;ListenStream=\nssh.socket;Socket;ListenStream=2222"
+
+	tests := map[string]struct {
+		entries      []entry.Entry
+		secondCall   []entry.Entry
+		user         bool
+		otherDropIns bool
+
+		failReload bool
+
+		wantErr           bool
+		wantErrSecondCall bool
+	}{
+		"Computer, drop-ins are generated and systemd reloaded": {},
+		"Computer, comments and blank lines are ignored":        {entries: []entry.Entry{{Key: "unit-overrides", Value: "# Keep printing\n\n  cups.service ; Service ; Restart = always  \n"}}},
+		"Computer, template instance unit":                      {entries: []entry.Entry{{Key: "unit-overrides", Value: "getty@tty1.service;Service;TTYVTDisallocate=no"}}},
+		"Computer, target only sets its Unit section":           {entries: []entry.Entry{{Key: "unit-overrides", Value: "multi-user.target;Unit;Wants=cups.service"}}},
+		"Computer, value with equal signs and specifiers":       {entries: []entry.Entry{{Key: "unit-overrides", Value: "cups.service;Service;Environment=CUPS_DEBUG=1 INSTANCE=%i"}}},
+		"Computer, other drop-ins are kept":                     {otherDropIns: true},
+		"Computer, unsupported key is ignored":                  {entries: []entry.Entry{{Key: "unit-foo", Value: "cups.service;Service;Restart=always"}}},
+		"Computer, no entries":                                  {entries: []entry.Entry{}},
+		"Computer, disabled entry":                              {entries: []entry.Entry{{Key: "unit-overrides", Value: defaultOverrides, Disabled: true}}},
+		"User, policy is ignored":                               {user: true},
+
+		// Reconciliation
+		"Computer, unchanged drop-ins are not reloaded":              {secondCall: []entry.Entry{{Key: "unit-overrides", Value: defaultOverrides}}},
+		"Computer, drop-ins of removed units are withdrawn":          {secondCall: []entry.Entry{{Key: "unit-overrides", Value: "cups.service;Service;Restart=always\ncups.service;Unit;StartLimitIntervalSec=0"}}},
+		"Computer, updated directive rewrites its drop-in":           {secondCall: []entry.Entry{{Key: "unit-overrides", Value: "cups.service;Service;Restart=on-failure\ncups.service;Unit;StartLimitIntervalSec=0\nssh.socket;Socket;ListenStream=\nssh.socket;Socket;ListenStream=2222"}}},
+		"Computer, new unit is added":                                {entries: []entry.Entry{{Key: "unit-overrides", Value: "cups.service;Service;Restart=always"}}, secondCall: []entry.Entry{{Key: "unit-overrides", Value: defaultOverrides}}},
+		"Computer, no entries removes all drop-ins":                  {secondCall: []entry.Entry{}},
+		"Computer, disabled entry removes all drop-ins":              {secondCall: []entry.Entry{{Key: "unit-overrides", Value: defaultOverrides, Disabled: true}}},
+		"Computer, withdrawing drop-ins keeps other drop-ins":        {otherDropIns: true, secondCall: []entry.Entry{}},
+		"Computer, invalid override keeps current ones on next call": {secondCall: []entry.Entry{{Key: "unit-overrides", Value: "cups.service;Service;Restart=always\nnot a unit;Service;Restart=always"}}, wantErrSecondCall: true},
+
+		// Error cases
+		"Error on override with missing field":         {entries: []entry.Entry{{Key: "unit-overrides", Value: "cups.service;Restart=always"}}, wantErr: true},
+		"Error on override without value":              {entries: []entry.Entry{{Key: "unit-overrides", Value: "cups.service;Service;Restart"}}, wantErr: true},
+		"Error on invalid unit name":                   {entries: []entry.Entry{{Key: "unit-overrides", Value: "cups service;Service;Restart=always"}}, wantErr: true},
+		"Error on unit name without type":              {entries: []entry.Entry{{Key: "unit-overrides", Value: "cups;Service;Restart=always"}}, wantErr: true},
+		"Error on unit name with path":                 {entries: []entry.Entry{{Key: "unit-overrides", Value: "../cups.service;Service;Restart=always"}}, wantErr: true},
+		"Error on unit generated by adsys":             {entries: []entry.Entry{{Key: "unit-overrides", Value: "adsys-job-cleanup.service;Service;Restart=always"}}, wantErr: true},
+		"Error on section of another unit type":        {entries: []entry.Entry{{Key: "unit-overrides", Value: "cups.service;Socket;ListenStream=631"}}, wantErr: true},
+		"Error on Install section":                     {entries: []entry.Entry{{Key: "unit-overrides", Value: "cups.service;Install;WantedBy=multi-user.target"}}, wantErr: true},
+		"Error on target type section":                 {entries: []entry.Entry{{Key: "unit-overrides", Value: "multi-user.target;Target;Wants=cups.service"}}, wantErr: true},
+		"Error on invalid directive key":               {entries: []entry.Entry{{Key: "unit-overrides", Value: "cups.service;Service;restart always=yes"}}, wantErr: true},
+		"Error on value continued on next line":        {entries: []entry.Entry{{Key: "unit-overrides", Value: `cups.service;Service;ExecStartPre=/bin/true \`}}, wantErr: true},
+		"Error on daemon reload failing":               {failReload: true, wantErr: true},
+		"Error on invalid override doesn't change any": {entries: []entry.Entry{{Key: "unit-overrides", Value: defaultOverrides + "\ncups.service;Service;Bad Key=1"}}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.entries == nil {
+				tc.entries = []entry.Entry{{Key: "unit-overrides", Value: defaultOverrides}}
+			}
+
+			rootDir := t.TempDir()
+			systemUnitDir := filepath.Join(rootDir, "etc", "systemd", "system")
+			if tc.otherDropIns {
+				for _, dir := range []string{"cups.service.d", "ssh.socket.d"} {
+					require.NoError(t, os.MkdirAll(filepath.Join(systemUnitDir, dir), 0750), "Setup: can't create drop-in directory")
+					// nolint:gosec // G306 - Unit files need to be world-readable.
+					require.NoError(t, os.WriteFile(filepath.Join(systemUnitDir, dir, "10-local.conf"), []byte("[Unit]\nDescription=Local\n"), 0644), "Setup: can't create other drop-in")
+				}
+			}
+
+			systemdCaller := &mockSystemdCaller{fail: tc.failReload}
+			m := units.New(systemUnitDir, systemdCaller)
+
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.secondCall != nil {
+				systemdCaller.record("--- second call")
+				err = m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.secondCall)
+				if tc.wantErrSecondCall {
+					require.Error(t, err, "Second ApplyPolicy should have failed but didn't")
+				} else {
+					require.NoError(t, err, "Second ApplyPolicy failed but shouldn't have")
+				}
+			}
+
+			testutils.CompareTreesWithFiltering(t, rootDir, testutils.GoldenPath(t), testutils.UpdateEnabled())
+
+			got := strings.Join(systemdCaller.calls, "\n") + "\n"
+			want := testutils.LoadWithUpdateFromGolden(t, got, testutils.WithGoldenPath(testutils.GoldenPath(t)+"-systemd_calls"))
+			require.Equal(t, want, got, "Systemd calls don't match")
+		})
+	}
+}
+
+type mockSystemdCaller struct {
+	fail bool
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (s *mockSystemdCaller) record(call string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+}
+
+func (s *mockSystemdCaller) DaemonReload(_ context.Context) error {
+	s.record("daemon-reload")
+	if s.fail {
+		return errors.New("failed to daemon-reload")
+	}
+	return nil
+}
@@ -0,0 +1,573 @@
+// Package telemetry exports the policy refreshes to an OpenTelemetry collector, as traces and duration metrics, over
+// OTLP/HTTP with the JSON encoding.
+//
+// Each refresh is a span, under which the apply of each policy manager nests as a child span. The spans of a refresh,
+// and the durations of the refresh and of each policy manager, are exported when the refresh span ends.
+// The current state of the daemon, like the one of the domain controllers, is exported as gauges when it changes.
+// Failures counted by a process, like the ones of the krb5 library, are exported as counters.
+//
+// Exporting is best effort: failures are only logged, and never fail a refresh. All functions are no-ops on a nil
+// exporter or span, which is used when no endpoint is configured.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/consts"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+)
+
+const (
+	// defaultTimeout is the default maximum time of each export request.
+	defaultTimeout = 5 * time.Second
+	// scopeName is the instrumentation scope of the exported spans and metrics.
+	scopeName = "github.com/ubuntu/adsys"
+
+	// refreshDurationMetric is the histogram of the durations of the refreshes.
+	refreshDurationMetric = "adsys.refresh.duration"
+	// applyDurationMetric is the histogram of the durations of the policy managers applies.
+	applyDurationMetric = "adsys.policy.apply.duration"
+)
+
+// Attributes of the spans and metrics.
+const (
+	attrObjectName = "adsys.object.name"
+	attrObjectType = "adsys.object.type"
+	attrPolicyType = "adsys.policy.type"
+	attrChanged    = "adsys.policy.changed"
+	attrStatus     = "adsys.status"
+)
+
+// durationBounds are the explicit bounds, in seconds, of the buckets of the duration histograms.
+var durationBounds = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300}
+
+// Exporter sends the refreshes spans and metrics to an OTLP/HTTP endpoint.
+type Exporter struct {
+	endpoint string
+	client   *http.Client
+	resource []keyValue
+}
+
+type options struct {
+	timeout     time.Duration
+	serviceName string
+}
+
+// Option reprents an optional function to change the telemetry exporter.
+type Option func(*options)
+
+// WithTimeout specifies the maximum time of each export request.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithServiceName specifies the name of the service exporting the spans and metrics.
+func WithServiceName(name string) Option {
+	return func(o *options) {
+		o.serviceName = name
+	}
+}
+
+// New returns an exporter to the OTLP/HTTP endpoint, like http://collector:4318, identifying the spans and metrics
+// as coming from hostname.
+func New(endpoint, hostname string, opts ...Option) (*Exporter, error) {
+	// Set default options
+	args := options{
+		timeout:     defaultTimeout,
+		serviceName: "adsysd",
+	}
+
+	// Apply given options
+	for _, f := range opts {
+		f(&args)
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New(gotext.Get("invalid OTLP endpoint %q: must be an http or https URL", endpoint))
+	}
+
+	return &Exporter{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   &http.Client{Timeout: args.timeout},
+		resource: []keyValue{
+			stringAttr("service.name", args.serviceName),
+			stringAttr("service.version", consts.Version),
+			stringAttr("host.name", hostname),
+		},
+	}, nil
+}
+
+// Span is a timed operation of a refresh.
+type Span struct {
+	exporter *Exporter
+	refresh  *refresh
+
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	metric   string
+	start    time.Time
+	attrs    []keyValue
+}
+
+// refresh collects the ended spans of a refresh, which are exported together.
+type refresh struct {
+	mu    sync.Mutex
+	spans []spanData
+}
+
+// spanData is an ended span.
+type spanData struct {
+	span *Span
+	end  time.Time
+	err  error
+}
+
+// spanKey is the context key of the current span.
+type spanKey struct{}
+
+// StartRefresh starts the span of the refresh of objectName. The spans of the policy managers started with the
+// returned context nest under it.
+func (e *Exporter) StartRefresh(ctx context.Context, objectName string, isComputer bool) (context.Context, *Span) {
+	if e == nil {
+		return ctx, nil
+	}
+
+	objectType := "user"
+	if isComputer {
+		objectType = "computer"
+	}
+	s := &Span{
+		exporter: e,
+		refresh:  &refresh{},
+		traceID:  newID(16),
+		spanID:   newID(8),
+		name:     "refresh " + objectType,
+		metric:   refreshDurationMetric,
+		start:    time.Now(),
+		attrs:    []keyValue{stringAttr(attrObjectName, objectName), stringAttr(attrObjectType, objectType)},
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// StartApply starts the span of the apply of the rule policy manager, nested under the refresh span of ctx.
+// It returns nil if there is no refresh span.
+func StartApply(ctx context.Context, rule string) *Span {
+	parent, ok := ctx.Value(spanKey{}).(*Span)
+	if !ok || parent == nil {
+		return nil
+	}
+
+	attrs := append([]keyValue{}, parent.attrs...)
+	return &Span{
+		exporter: parent.exporter,
+		refresh:  parent.refresh,
+		traceID:  parent.traceID,
+		spanID:   newID(8),
+		parentID: parent.spanID,
+		name:     "apply " + rule,
+		metric:   applyDurationMetric,
+		start:    time.Now(),
+		attrs:    append(attrs, stringAttr(attrPolicyType, rule)),
+	}
+}
+
+// SetChanged records whether the operation of the span changed the machine.
+func (s *Span) SetChanged(changed bool) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, keyValue{Key: attrChanged, Value: anyValue{BoolValue: &changed}})
+}
+
+// End ends the span, which failed if err is not nil. Ending the refresh span exports all the spans of the refresh.
+func (s *Span) End(ctx context.Context, err error) {
+	if s == nil {
+		return
+	}
+
+	s.refresh.mu.Lock()
+	s.refresh.spans = append(s.refresh.spans, spanData{span: s, end: time.Now(), err: err})
+	spans := s.refresh.spans
+	s.refresh.mu.Unlock()
+
+	if s.parentID != "" {
+		return
+	}
+
+	// The refresh context can be exceeded, but the spans must still be exported.
+	ctx = context.WithoutCancel(ctx)
+	if err := s.exporter.post(ctx, "traces", tracesRequest(s.exporter.resource, spans)); err != nil {
+		log.Warningf(ctx, "Can't export refresh traces: %v", err)
+	}
+	if err := s.exporter.post(ctx, "metrics", metricsRequest(s.exporter.resource, spans)); err != nil {
+		log.Warningf(ctx, "Can't export refresh metrics: %v", err)
+	}
+}
+
+// Gauge is the current value of a metric.
+type Gauge struct {
+	Name        string
+	Description string
+	Unit        string
+	Attributes  map[string]string
+	Value       int64
+}
+
+// ExportGauges exports the current value of gauges. The gauges with the same name are data points of the same
+// metric, identified by their attributes.
+func (e *Exporter) ExportGauges(ctx context.Context, gauges []Gauge) {
+	if e == nil || len(gauges) == 0 {
+		return
+	}
+
+	if err := e.post(context.WithoutCancel(ctx), "metrics", gaugesRequest(e.resource, gauges, time.Now())); err != nil {
+		log.Warningf(ctx, "Can't export metrics: %v", err)
+	}
+}
+
+// Counter is the total of a metric since Start, like the number of failures since a process started.
+type Counter struct {
+	Name        string
+	Description string
+	Unit        string
+	Attributes  map[string]string
+	Start       time.Time
+	Value       int64
+}
+
+// ExportCounters exports the current totals of counters, as monotonic cumulative sums. The counters with the same
+// name are data points of the same metric, identified by their attributes.
+func (e *Exporter) ExportCounters(ctx context.Context, counters []Counter) {
+	if e == nil || len(counters) == 0 {
+		return
+	}
+
+	if err := e.post(context.WithoutCancel(ctx), "metrics", countersRequest(e.resource, counters, time.Now())); err != nil {
+		log.Warningf(ctx, "Can't export metrics: %v", err)
+	}
+}
+
+// post sends the JSON encoded request to the signal path of the endpoint.
+func (e *Exporter) post(ctx context.Context, signal string, request any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/v1/%s", e.endpoint, signal), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.New(gotext.Get("OTLP endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(msg))))
+	}
+	return nil
+}
+
+// newID returns a random hex encoded identifier of n bytes.
+func newID(n int) string {
+	b := make([]byte, n)
+	// crypto/rand never fails on Linux.
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// OTLP/HTTP JSON encoding of the requests.
+// 64 bits integers are encoded as strings, and the identifiers as hexadecimal strings.
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+func stringAttr(key, value string) keyValue {
+	return keyValue{Key: key, Value: anyValue{StringValue: &value}}
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpSpan struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes"`
+	Status            status     `json:"status"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	spanKindInternal = 1
+	statusCodeOk     = 1
+	statusCodeError  = 2
+	// aggregationTemporalityDelta is used as each data point is a single refresh or apply.
+	aggregationTemporalityDelta = 1
+	// aggregationTemporalityCumulative is used by counters, which are totals since their start.
+	aggregationTemporalityCumulative = 2
+)
+
+// tracesRequest returns the export request of the spans.
+func tracesRequest(res []keyValue, spans []spanData) any {
+	var otlpSpans []otlpSpan
+	for _, d := range spans {
+		st := status{Code: statusCodeOk}
+		if d.err != nil {
+			st = status{Code: statusCodeError, Message: d.err.Error()}
+		}
+		otlpSpans = append(otlpSpans, otlpSpan{
+			TraceID:           d.span.traceID,
+			SpanID:            d.span.spanID,
+			ParentSpanID:      d.span.parentID,
+			Name:              d.span.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: unixNano(d.span.start),
+			EndTimeUnixNano:   unixNano(d.end),
+			Attributes:        d.span.attrs,
+			Status:            st,
+		})
+	}
+
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": resource{Attributes: res},
+			"scopeSpans": []any{map[string]any{
+				"scope": scope{Name: scopeName, Version: consts.Version},
+				"spans": otlpSpans,
+			}},
+		}},
+	}
+}
+
+type histogramDataPoint struct {
+	Attributes        []keyValue `json:"attributes"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	Count             string     `json:"count"`
+	Sum               float64    `json:"sum"`
+	BucketCounts      []string   `json:"bucketCounts"`
+	ExplicitBounds    []float64  `json:"explicitBounds"`
+}
+
+// metricsRequest returns the export request of the durations of the spans, as one histogram data point per span.
+// The object name is not part of the metrics attributes to keep their cardinality low.
+func metricsRequest(res []keyValue, spans []spanData) any {
+	points := make(map[string][]histogramDataPoint)
+	for _, d := range spans {
+		var attrs []keyValue
+		for _, a := range d.span.attrs {
+			if a.Key == attrObjectType || a.Key == attrPolicyType || a.Key == attrChanged {
+				attrs = append(attrs, a)
+			}
+		}
+		st := "ok"
+		if d.err != nil {
+			st = "error"
+		}
+		attrs = append(attrs, stringAttr(attrStatus, st))
+
+		seconds := d.end.Sub(d.span.start).Seconds()
+		counts := make([]string, len(durationBounds)+1)
+		bucket := len(durationBounds)
+		for i, b := range durationBounds {
+			if seconds <= b {
+				bucket = i
+				break
+			}
+		}
+		for i := range counts {
+			counts[i] = "0"
+		}
+		counts[bucket] = "1"
+
+		points[d.span.metric] = append(points[d.span.metric], histogramDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: unixNano(d.span.start),
+			TimeUnixNano:      unixNano(d.end),
+			Count:             "1",
+			Sum:               seconds,
+			BucketCounts:      counts,
+			ExplicitBounds:    durationBounds,
+		})
+	}
+
+	var metrics []any
+	for _, m := range []struct{ name, description string }{
+		{refreshDurationMetric, "Duration of the policy refreshes."},
+		{applyDurationMetric, "Duration of the apply of the policy managers."},
+	} {
+		if len(points[m.name]) == 0 {
+			continue
+		}
+		metrics = append(metrics, map[string]any{
+			"name":        m.name,
+			"description": m.description,
+			"unit":        "s",
+			"histogram": map[string]any{
+				"aggregationTemporality": aggregationTemporalityDelta,
+				"dataPoints":             points[m.name],
+			},
+		})
+	}
+
+	return resourceMetrics(res, metrics)
+}
+
+type gaugeDataPoint struct {
+	Attributes   []keyValue `json:"attributes"`
+	TimeUnixNano string     `json:"timeUnixNano"`
+	AsInt        string     `json:"asInt"`
+}
+
+// gaugesRequest returns the export request of the gauges at t, as one metric per gauge name in order of appearance.
+func gaugesRequest(res []keyValue, gauges []Gauge, t time.Time) any {
+	var names []string
+	byName := make(map[string][]Gauge)
+	for _, g := range gauges {
+		if _, ok := byName[g.Name]; !ok {
+			names = append(names, g.Name)
+		}
+		byName[g.Name] = append(byName[g.Name], g)
+	}
+
+	var metrics []any
+	for _, name := range names {
+		var points []gaugeDataPoint
+		for _, g := range byName[name] {
+			points = append(points, gaugeDataPoint{
+				Attributes:   sortedAttrs(g.Attributes),
+				TimeUnixNano: unixNano(t),
+				AsInt:        strconv.FormatInt(g.Value, 10),
+			})
+		}
+		metrics = append(metrics, map[string]any{
+			"name":        name,
+			"description": byName[name][0].Description,
+			"unit":        byName[name][0].Unit,
+			"gauge":       map[string]any{"dataPoints": points},
+		})
+	}
+
+	return resourceMetrics(res, metrics)
+}
+
+type sumDataPoint struct {
+	Attributes        []keyValue `json:"attributes"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	AsInt             string     `json:"asInt"`
+}
+
+// countersRequest returns the export request of the counters at t, as one metric per counter name in order of
+// appearance.
+func countersRequest(res []keyValue, counters []Counter, t time.Time) any {
+	var names []string
+	byName := make(map[string][]Counter)
+	for _, c := range counters {
+		if _, ok := byName[c.Name]; !ok {
+			names = append(names, c.Name)
+		}
+		byName[c.Name] = append(byName[c.Name], c)
+	}
+
+	var metrics []any
+	for _, name := range names {
+		var points []sumDataPoint
+		for _, c := range byName[name] {
+			points = append(points, sumDataPoint{
+				Attributes:        sortedAttrs(c.Attributes),
+				StartTimeUnixNano: unixNano(c.Start),
+				TimeUnixNano:      unixNano(t),
+				AsInt:             strconv.FormatInt(c.Value, 10),
+			})
+		}
+		metrics = append(metrics, map[string]any{
+			"name":        name,
+			"description": byName[name][0].Description,
+			"unit":        byName[name][0].Unit,
+			"sum": map[string]any{
+				"aggregationTemporality": aggregationTemporalityCumulative,
+				"isMonotonic":            true,
+				"dataPoints":             points,
+			},
+		})
+	}
+
+	return resourceMetrics(res, metrics)
+}
+
+// sortedAttrs returns the attributes, sorted by key.
+func sortedAttrs(attributes map[string]string) []keyValue {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	var attrs []keyValue
+	for _, k := range keys {
+		attrs = append(attrs, stringAttr(k, attributes[k]))
+	}
+	return attrs
+}
+
+// resourceMetrics returns the export request of the metrics of the resource.
+func resourceMetrics(res []keyValue, metrics []any) any {
+	return map[string]any{
+		"resourceMetrics": []any{map[string]any{
+			"resource": resource{Attributes: res},
+			"scopeMetrics": []any{map[string]any{
+				"scope":   scope{Name: scopeName, Version: consts.Version},
+				"metrics": metrics,
+			}},
+		}},
+	}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,405 @@
+package telemetry_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/telemetry"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		endpoint string
+
+		wantErr bool
+	}{
+		"HTTP endpoint":                    {endpoint: "http://collector:4318"},
+		"HTTPS endpoint with trailing /":   {endpoint: "https://collector.example.com/otlp/"},
+		"Error on endpoint without scheme": {endpoint: "collector:4318", wantErr: true},
+		"Error on non HTTP endpoint":       {endpoint: "grpc://collector:4317", wantErr: true},
+		"Error on endpoint without host":   {endpoint: "http://", wantErr: true},
+		"Error on invalid endpoint":        {endpoint: "http://%zz", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := telemetry.New(tc.endpoint, "myhost")
+			if tc.wantErr {
+				require.Error(t, err, "New should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "New should not have failed")
+		})
+	}
+}
+
+func TestRefreshSpans(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		isComputer      bool
+		failingManager  bool
+		failingRefresh  bool
+		collectorStatus int
+
+		wantObjectType string
+	}{
+		"Computer refresh":                  {isComputer: true, wantObjectType: "computer"},
+		"User refresh":                      {wantObjectType: "user"},
+		"Failing manager":                   {isComputer: true, failingManager: true, failingRefresh: true, wantObjectType: "computer"},
+		"Collector errors don't fail spans": {isComputer: true, collectorStatus: http.StatusServiceUnavailable, wantObjectType: "computer"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := newCollector(t, tc.collectorStatus)
+			e, err := telemetry.New(c.URL, "myhost")
+			require.NoError(t, err, "Setup: New should not have failed")
+
+			ctx, refresh := e.StartRefresh(context.Background(), "myobject", tc.isComputer)
+			require.NotNil(t, refresh, "StartRefresh should return a span")
+
+			privilege := telemetry.StartApply(ctx, "privilege")
+			privilege.SetChanged(true)
+			var managerErr error
+			if tc.failingManager {
+				managerErr = errors.New("privilege failure")
+			}
+			privilege.End(ctx, managerErr)
+
+			proxy := telemetry.StartApply(ctx, "proxy")
+			proxy.SetChanged(false)
+			proxy.End(ctx, nil)
+
+			require.Empty(t, c.requests(), "Nothing should be exported before the refresh span ends")
+
+			var refreshErr error
+			if tc.failingRefresh {
+				refreshErr = errors.New("refresh failure")
+			}
+			refresh.End(ctx, refreshErr)
+
+			reqs := c.requests()
+			require.Len(t, reqs["/v1/traces"], 1, "Refresh traces should be exported once")
+			require.Len(t, reqs["/v1/metrics"], 1, "Refresh metrics should be exported once")
+
+			// Traces
+			var traces struct {
+				ResourceSpans []struct {
+					Resource   struct{ Attributes []keyValue }
+					ScopeSpans []struct {
+						Spans []struct {
+							TraceID, SpanID, ParentSpanID, Name string
+							StartTimeUnixNano, EndTimeUnixNano  string
+							Attributes                          []keyValue
+							Status                              struct {
+								Code    int
+								Message string
+							}
+						}
+					}
+				}
+			}
+			require.NoError(t, json.Unmarshal(reqs["/v1/traces"][0], &traces), "Traces should be valid JSON")
+			require.Len(t, traces.ResourceSpans, 1, "Traces should have one resource")
+			res := attributes(traces.ResourceSpans[0].Resource.Attributes)
+			require.Equal(t, "adsysd", res["service.name"], "Resource should be the daemon")
+			require.Equal(t, "myhost", res["host.name"], "Resource should have the host name")
+
+			require.Len(t, traces.ResourceSpans[0].ScopeSpans, 1, "Traces should have one scope")
+			spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+			require.Len(t, spans, 3, "Refresh and managers spans should be exported")
+
+			root := spans[2]
+			require.Equal(t, "refresh "+tc.wantObjectType, root.Name, "Refresh span is exported last")
+			require.Empty(t, root.ParentSpanID, "Refresh span should have no parent")
+			require.Len(t, root.TraceID, 32, "Trace ID should be 16 hex encoded bytes")
+			require.Len(t, root.SpanID, 16, "Span ID should be 8 hex encoded bytes")
+			require.Equal(t, map[string]any{"adsys.object.name": "myobject", "adsys.object.type": tc.wantObjectType},
+				attributes(root.Attributes), "Refresh span attributes should be the object ones")
+
+			wantManagers := []struct {
+				name    string
+				rule    string
+				changed bool
+				failed  bool
+			}{
+				{name: "apply privilege", rule: "privilege", changed: true, failed: tc.failingManager},
+				{name: "apply proxy", rule: "proxy"},
+			}
+			for i, want := range wantManagers {
+				s := spans[i]
+				require.Equal(t, want.name, s.Name, "Manager span name should be the applied policy")
+				require.Equal(t, root.TraceID, s.TraceID, "Manager span should be in the refresh trace")
+				require.Equal(t, root.SpanID, s.ParentSpanID, "Manager span should nest under the refresh span")
+				require.NotEqual(t, root.SpanID, s.SpanID, "Manager span should have its own ID")
+				require.Equal(t, map[string]any{
+					"adsys.object.name":    "myobject",
+					"adsys.object.type":    tc.wantObjectType,
+					"adsys.policy.type":    want.rule,
+					"adsys.policy.changed": want.changed,
+				}, attributes(s.Attributes), "Manager span attributes should be the object and policy ones")
+				start, err := strconv.ParseInt(s.StartTimeUnixNano, 10, 64)
+				require.NoError(t, err, "Manager span start should be a number of nanoseconds")
+				end, err := strconv.ParseInt(s.EndTimeUnixNano, 10, 64)
+				require.NoError(t, err, "Manager span end should be a number of nanoseconds")
+				require.GreaterOrEqual(t, end, start, "Manager span should end after its start")
+
+				if want.failed {
+					require.Equal(t, 2, s.Status.Code, "Failed manager span should have an error status")
+					require.Equal(t, "privilege failure", s.Status.Message, "Failed manager span should have the error message")
+				} else {
+					require.Equal(t, 1, s.Status.Code, "Manager span should have an ok status")
+				}
+			}
+			if tc.failingRefresh {
+				require.Equal(t, 2, root.Status.Code, "Failed refresh span should have an error status")
+			} else {
+				require.Equal(t, 1, root.Status.Code, "Refresh span should have an ok status")
+			}
+
+			// Metrics
+			var metrics struct {
+				ResourceMetrics []struct {
+					ScopeMetrics []struct {
+						Metrics []struct {
+							Name, Unit string
+							Histogram  struct {
+								AggregationTemporality int
+								DataPoints             []struct {
+									Attributes     []keyValue
+									Count          string
+									Sum            float64
+									BucketCounts   []string
+									ExplicitBounds []float64
+								}
+							}
+						}
+					}
+				}
+			}
+			require.NoError(t, json.Unmarshal(reqs["/v1/metrics"][0], &metrics), "Metrics should be valid JSON")
+			got := metrics.ResourceMetrics[0].ScopeMetrics[0].Metrics
+			require.Len(t, got, 2, "Refresh and apply durations should be exported")
+
+			require.Equal(t, "adsys.refresh.duration", got[0].Name, "First metric should be the refresh duration")
+			require.Len(t, got[0].Histogram.DataPoints, 1, "Refresh duration should have one data point")
+			wantStatus := "ok"
+			if tc.failingRefresh {
+				wantStatus = "error"
+			}
+			require.Equal(t, map[string]any{"adsys.object.type": tc.wantObjectType, "adsys.status": wantStatus},
+				attributes(got[0].Histogram.DataPoints[0].Attributes), "Refresh duration attributes should not have the object name")
+
+			require.Equal(t, "adsys.policy.apply.duration", got[1].Name, "Second metric should be the apply duration")
+			require.Len(t, got[1].Histogram.DataPoints, 2, "Apply duration should have a data point per manager")
+			for _, m := range got {
+				require.Equal(t, "s", m.Unit, "Durations should be in seconds")
+				require.Equal(t, 1, m.Histogram.AggregationTemporality, "Durations should be delta histograms")
+				for _, p := range m.Histogram.DataPoints {
+					require.Equal(t, "1", p.Count, "Each data point should count one operation")
+					require.Len(t, p.BucketCounts, len(p.ExplicitBounds)+1, "Histogram should have one bucket more than its bounds")
+					require.Contains(t, p.BucketCounts, "1", "Operation should be counted in one bucket")
+					require.GreaterOrEqual(t, p.Sum, 0.0, "Duration can't be negative")
+				}
+			}
+			wantManagerStatus := "ok"
+			if tc.failingManager {
+				wantManagerStatus = "error"
+			}
+			require.Equal(t, map[string]any{
+				"adsys.object.type":    tc.wantObjectType,
+				"adsys.policy.type":    "privilege",
+				"adsys.policy.changed": true,
+				"adsys.status":         wantManagerStatus,
+			}, attributes(got[1].Histogram.DataPoints[0].Attributes), "Apply duration attributes should be the policy ones")
+		})
+	}
+}
+
+func TestExportGauges(t *testing.T) {
+	t.Parallel()
+
+	c := newCollector(t, 0)
+	e, err := telemetry.New(c.URL, "myhost")
+	require.NoError(t, err, "Setup: New should not have failed")
+
+	e.ExportGauges(context.Background(), []telemetry.Gauge{
+		{Name: "adsys.dc.down", Description: "Down", Unit: "1", Attributes: map[string]string{"adsys.dc.host": "dc1"}, Value: 1},
+		{Name: "adsys.dc.failures", Unit: "1", Attributes: map[string]string{"adsys.dc.host": "dc1"}, Value: 3},
+		{Name: "adsys.dc.down", Description: "Down", Unit: "1", Attributes: map[string]string{"adsys.dc.host": "dc2"}, Value: 0},
+	})
+
+	reqs := c.requests()
+	require.Len(t, reqs["/v1/metrics"], 1, "Gauges should be exported at once")
+	require.Empty(t, reqs["/v1/traces"], "Gauges should not export traces")
+
+	var metrics struct {
+		ResourceMetrics []struct {
+			Resource     struct{ Attributes []keyValue }
+			ScopeMetrics []struct {
+				Metrics []struct {
+					Name, Description, Unit string
+					Gauge                   struct {
+						DataPoints []struct {
+							Attributes []keyValue
+							AsInt      string
+						}
+					}
+				}
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(reqs["/v1/metrics"][0], &metrics), "Metrics should be valid JSON")
+	require.Equal(t, "myhost", attributes(metrics.ResourceMetrics[0].Resource.Attributes)["host.name"], "Resource should have the host name")
+	got := metrics.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	require.Len(t, got, 2, "Gauges should be grouped by name")
+
+	require.Equal(t, "adsys.dc.down", got[0].Name, "Gauges should be exported in order of appearance")
+	require.Equal(t, "Down", got[0].Description, "Gauge should have its description")
+	require.Len(t, got[0].Gauge.DataPoints, 2, "Gauges with the same name should be data points of the same metric")
+	require.Equal(t, "1", got[0].Gauge.DataPoints[0].AsInt, "Data point should have the gauge value")
+	require.Equal(t, map[string]any{"adsys.dc.host": "dc1"}, attributes(got[0].Gauge.DataPoints[0].Attributes), "Data point should have the gauge attributes")
+	require.Equal(t, "0", got[0].Gauge.DataPoints[1].AsInt, "Data point should have the gauge value")
+
+	require.Equal(t, "adsys.dc.failures", got[1].Name, "Gauges should be exported in order of appearance")
+	require.Equal(t, "3", got[1].Gauge.DataPoints[0].AsInt, "Data point should have the gauge value")
+}
+
+func TestExportCounters(t *testing.T) {
+	t.Parallel()
+
+	c := newCollector(t, 0)
+	e, err := telemetry.New(c.URL, "myhost", telemetry.WithServiceName("adsysctl"))
+	require.NoError(t, err, "Setup: New should not have failed")
+
+	start := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	e.ExportCounters(context.Background(), []telemetry.Counter{
+		{Name: "adsys.krb5.context_init_failures", Description: "Failures", Unit: "1", Start: start, Value: 2},
+	})
+
+	reqs := c.requests()
+	require.Len(t, reqs["/v1/metrics"], 1, "Counters should be exported at once")
+	require.Empty(t, reqs["/v1/traces"], "Counters should not export traces")
+
+	var metrics struct {
+		ResourceMetrics []struct {
+			Resource     struct{ Attributes []keyValue }
+			ScopeMetrics []struct {
+				Metrics []struct {
+					Name, Description, Unit string
+					Sum                     struct {
+						AggregationTemporality int
+						IsMonotonic            bool
+						DataPoints             []struct {
+							Attributes        []keyValue
+							StartTimeUnixNano string
+							AsInt             string
+						}
+					}
+				}
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(reqs["/v1/metrics"][0], &metrics), "Metrics should be valid JSON")
+	require.Equal(t, "adsysctl", attributes(metrics.ResourceMetrics[0].Resource.Attributes)["service.name"], "Resource should have the service name")
+	got := metrics.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	require.Len(t, got, 1, "Counters should be grouped by name")
+
+	require.Equal(t, "adsys.krb5.context_init_failures", got[0].Name, "Counter should have its name")
+	require.Equal(t, "Failures", got[0].Description, "Counter should have its description")
+	require.True(t, got[0].Sum.IsMonotonic, "Counter should be a monotonic sum")
+	require.Equal(t, 2, got[0].Sum.AggregationTemporality, "Counter should be cumulative")
+	require.Len(t, got[0].Sum.DataPoints, 1, "Counter should have one data point")
+	require.Equal(t, "2", got[0].Sum.DataPoints[0].AsInt, "Data point should have the counter value")
+	require.Equal(t, strconv.FormatInt(start.UnixNano(), 10), got[0].Sum.DataPoints[0].StartTimeUnixNano, "Data point should start when the counter started")
+}
+
+func TestDisabled(t *testing.T) {
+	t.Parallel()
+
+	var e *telemetry.Exporter
+	ctx, refresh := e.StartRefresh(context.Background(), "myobject", true)
+	require.Nil(t, refresh, "StartRefresh should return no span without exporter")
+
+	apply := telemetry.StartApply(ctx, "privilege")
+	require.Nil(t, apply, "StartApply should return no span without refresh span")
+
+	// Calls on nil spans are no-ops.
+	apply.SetChanged(true)
+	apply.End(ctx, nil)
+	refresh.End(ctx, nil)
+	e.ExportGauges(ctx, []telemetry.Gauge{{Name: "adsys.dc.down"}})
+	e.ExportCounters(ctx, []telemetry.Counter{{Name: "adsys.krb5.context_init_failures"}})
+}
+
+type keyValue struct {
+	Key   string
+	Value struct {
+		StringValue *string
+		BoolValue   *bool
+	}
+}
+
+// attributes returns the attributes values by key.
+func attributes(kvs []keyValue) map[string]any {
+	r := make(map[string]any)
+	for _, kv := range kvs {
+		switch {
+		case kv.Value.StringValue != nil:
+			r[kv.Key] = *kv.Value.StringValue
+		case kv.Value.BoolValue != nil:
+			r[kv.Key] = *kv.Value.BoolValue
+		}
+	}
+	return r
+}
+
+// collector is an OTLP/HTTP collector recording the requests bodies by path.
+type collector struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	reqs map[string][][]byte
+}
+
+// newCollector starts a collector answering with status, or 200 if it's 0.
+func newCollector(t *testing.T, status int) *collector {
+	t.Helper()
+
+	c := &collector{reqs: make(map[string][][]byte)}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil || r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		c.mu.Lock()
+		c.reqs[r.URL.Path] = append(c.reqs[r.URL.Path], body)
+		c.mu.Unlock()
+		if status != 0 {
+			w.WriteHeader(status)
+		}
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func (c *collector) requests() map[string][][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reqs
+}
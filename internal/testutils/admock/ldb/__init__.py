@@ -74,8 +74,10 @@ accounts = {}
 
 ##############################
 
-# Only called on user/machine, returns correct account object
+# Called on user/machine, returns correct account object, or on OU paths
 def Dn(samdb, dn):
+    if dn in OUs:
+        return OUs[dn]
     return accounts[dn.lower()]
 
 
@@ -170,6 +172,7 @@ class Account:
     def __init__(self, name, dn):
         self.name = name
         self.parentDn = dn
+        self.attributes = {}
         accounts[name.lower()] = self
 
     def parent(self):
@@ -194,6 +197,13 @@ o.addGPO(GPO("IT GPO"))
 o = OU("/example/IT/ITDep1")
 o.addGPO(GPO("ITDep1 GPO"))
 o.addAccount("hostname1")
+accounts["hostname1"].attributes = {
+    "description": [b"Kiosk of the Paris office"],
+    "location": [b"paris"],
+    "departmentNumber": [b"42", b"43"],
+    "info": [b"First line\nSecond\tline"],
+}
+o.addAccount("hostnameWithUnreadableAttributes")
 o.addAccount("hostnameWithTru")
 o.addAccount("hostnameWithLongName")
 
@@ -69,6 +69,13 @@ class SamDB:
             return [r]
 
 
+        # Account attributes
+        elif str(base).lower() in ldb.accounts:
+            account = ldb.accounts[str(base).lower()]
+            if account.name == "hostnameWithUnreadableAttributes":
+                raise Exception("Insufficient access rights to read attributes")
+            return [{k: v for k, v in account.attributes.items() if k in attrs}]
+
         # GPO Attribute
         gpo = ldb.GPOs[base]
         if gpo.nTSecurityDescriptor[0] == "MISSING":
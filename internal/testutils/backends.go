@@ -30,6 +30,13 @@ func FormatBackendCalls(t *testing.T, backend backends.Backend) string {
 	}
 	got.WriteString(serverLine)
 
+	site, err := backend.Site(context.Background())
+	siteLine := fmt.Sprintf("* Site(): %s\n", site)
+	if err != nil {
+		siteLine = fmt.Sprintf("* Site ERROR(): %s\n", err)
+	}
+	got.WriteString(siteLine)
+
 	isOnline, err := backend.IsOnline()
 	isOnlineLine := fmt.Sprintf("* IsOnline(): %t\n", isOnline)
 	if err != nil {